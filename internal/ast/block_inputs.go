@@ -0,0 +1,207 @@
+package ast
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadBlockInputs reads the input definitions of a local block workflow.
+// Remote blocks and references which can't be read are skipped, any problem
+// with the reference itself is reported by isValidBlockReference.
+func loadBlockInputs(wd, ref string) (map[string]*InputParam, bool) {
+	if !strings.HasPrefix(ref, "./") && !strings.HasPrefix(ref, "../") {
+		return nil, false
+	}
+
+	if !strings.HasSuffix(ref, ".laq.yaml") && !strings.HasSuffix(ref, ".laq.yml") {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(filepath.Join(wd, ref)) // #nosec G304 - block path is relative to the workflow
+	if err != nil {
+		return nil, false
+	}
+
+	var block struct {
+		Inputs map[string]*InputParam `yaml:"inputs"`
+	}
+	if err := yaml.Unmarshal(data, &block); err != nil {
+		return nil, false
+	}
+
+	return block.Inputs, true
+}
+
+// validateBlockInputs checks the with values of a step using a local block
+// against the block's input definitions. Values containing expressions are
+// only known at runtime so just the input names are checked for them.
+func (v *Validator) validateBlockInputs(path string, step *Step) {
+	inputs, ok := loadBlockInputs(v.wd, step.Uses)
+	if !ok {
+		return
+	}
+
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		param := inputs[name]
+		if _, exists := step.With[name]; exists || !param.Required || param.Default != nil {
+			continue
+		}
+
+		field := "with"
+		if step.With == nil {
+			field = "uses"
+		}
+		v.result.AddFieldError(path, field, fmt.Sprintf("missing required input %q for block %s", name, step.Uses))
+	}
+
+	keys := make([]string, 0, len(step.With))
+	for key := range step.With {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := fmt.Sprintf("with.%s", key)
+
+		param, exists := inputs[key]
+		if !exists {
+			message := fmt.Sprintf("unknown input %q for block %s", key, step.Uses)
+			if suggestion := closestName(key, names); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %q?", suggestion)
+			} else if len(names) > 0 {
+				message += fmt.Sprintf(", expected one of: %s", strings.Join(names, ", "))
+			}
+
+			v.result.AddFieldError(path, field, message)
+			continue
+		}
+
+		if err := checkLiteralInput(step.With[key], param); err != nil {
+			v.result.AddFieldError(path, field, fmt.Sprintf("input %q %s", key, err))
+		}
+	}
+}
+
+// checkLiteralInput checks a literal with value against the input's type
+// and allowed values.
+func checkLiteralInput(value interface{}, param *InputParam) error {
+	if s, ok := value.(string); ok && strings.Contains(s, "${{") {
+		return nil
+	}
+
+	switch param.Type {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("must be a string, got %s", yamlTypeName(value))
+		}
+	case "integer":
+		if _, ok := value.(int); !ok {
+			return fmt.Errorf("must be an integer, got %s", yamlTypeName(value))
+		}
+	case "number":
+		switch value.(type) {
+		case int, float64:
+		default:
+			return fmt.Errorf("must be a number, got %s", yamlTypeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a boolean, got %s", yamlTypeName(value))
+		}
+	case "array":
+		if _, ok := value.([]interface{}); !ok {
+			return fmt.Errorf("must be an array, got %s", yamlTypeName(value))
+		}
+	case "object":
+		if _, ok := value.(map[string]interface{}); !ok {
+			return fmt.Errorf("must be an object, got %s", yamlTypeName(value))
+		}
+	}
+
+	if len(param.Enum) == 0 || param.Multiple {
+		return nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return nil
+	}
+
+	allowed := make([]string, len(param.Enum))
+	for i, option := range param.Enum {
+		if option.Value == s {
+			return nil
+		}
+		allowed[i] = option.Value
+	}
+
+	return fmt.Errorf("must be one of: %s", strings.Join(allowed, ", "))
+}
+
+func yamlTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case int:
+		return "integer"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// closestName returns the candidate closest to name when it is likely to be
+// a misspelling of it.
+func closestName(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/2+1
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr := make([]int, len(b)+1)
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(min(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev = curr
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,138 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WorkflowDoc documents the interface of a workflow, describing what callers
+// need to provide and what they get back when using it as a block.
+type WorkflowDoc struct {
+	Name        string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string     `json:"description,omitempty" yaml:"description,omitempty"`
+	Inputs      []ParamDoc `json:"inputs" yaml:"inputs"`
+	Outputs     []ParamDoc `json:"outputs" yaml:"outputs"`
+}
+
+// ParamDoc documents a single input or output of a workflow.
+type ParamDoc struct {
+	Name        string      `json:"name" yaml:"name"`
+	Type        string      `json:"type,omitempty" yaml:"type,omitempty"`
+	Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool        `json:"required,omitempty" yaml:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty" yaml:"default,omitempty"`
+	Enum        []string    `json:"enum,omitempty" yaml:"enum,omitempty"`
+}
+
+// Describe returns the documentation of the workflow's inputs and outputs,
+// sorted by name. Outputs which are set but not declared are included
+// without a type.
+func (w *Workflow) Describe() *WorkflowDoc {
+	doc := &WorkflowDoc{
+		Inputs:  []ParamDoc{},
+		Outputs: []ParamDoc{},
+	}
+
+	if w.Metadata != nil {
+		doc.Name = w.Metadata.Name
+		doc.Description = w.Metadata.Description
+	}
+
+	for name, param := range w.Inputs {
+		doc.Inputs = append(doc.Inputs, ParamDoc{
+			Name:        name,
+			Type:        param.Type,
+			Description: param.Description,
+			Required:    param.Required && param.Default == nil,
+			Default:     param.Default,
+			Enum:        param.EnumValues(),
+		})
+	}
+
+	outputs := make(map[string]*OutputSchema, len(w.Outputs))
+	for name, output := range w.Outputs {
+		outputs[name] = output
+	}
+	if w.Workflow != nil {
+		for name := range w.Workflow.Outputs {
+			if _, ok := outputs[name]; !ok {
+				outputs[name] = nil
+			}
+		}
+	}
+
+	for name, output := range outputs {
+		param := ParamDoc{Name: name}
+		if output != nil {
+			param.Type = output.Type
+			param.Description = output.Description
+		}
+		doc.Outputs = append(doc.Outputs, param)
+	}
+
+	sort.Slice(doc.Inputs, func(i, j int) bool { return doc.Inputs[i].Name < doc.Inputs[j].Name })
+	sort.Slice(doc.Outputs, func(i, j int) bool { return doc.Outputs[i].Name < doc.Outputs[j].Name })
+
+	return doc
+}
+
+// Markdown renders the documentation as markdown, suitable for editor hovers.
+func (d *WorkflowDoc) Markdown() string {
+	var b strings.Builder
+
+	if d.Name != "" {
+		fmt.Fprintf(&b, "**%s**\n\n", d.Name)
+	}
+	if d.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", d.Description)
+	}
+
+	for _, section := range []struct {
+		title  string
+		params []ParamDoc
+	}{
+		{"Inputs", d.Inputs},
+		{"Outputs", d.Outputs},
+	} {
+		if len(section.params) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s:\n", section.title)
+		for _, param := range section.params {
+			fmt.Fprintf(&b, "- `%s`%s\n", param.Name, param.Summary())
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// Summary describes the parameter's type, constraints and purpose in a
+// single line.
+func (p ParamDoc) Summary() string {
+	var details []string
+	if p.Type != "" {
+		details = append(details, p.Type)
+	}
+	if p.Required {
+		details = append(details, "required")
+	}
+	if p.Default != nil {
+		details = append(details, fmt.Sprintf("default: %v", p.Default))
+	}
+	if len(p.Enum) > 0 {
+		details = append(details, fmt.Sprintf("one of: %s", strings.Join(p.Enum, ", ")))
+	}
+
+	var summary string
+	if len(details) > 0 {
+		summary = fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+	}
+	if p.Description != "" {
+		summary += " - " + p.Description
+	}
+
+	return summary
+}
@@ -1,9 +1,21 @@
 package ast
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 )
 
+// fileFunctionPattern matches literal file('...') calls in expressions so the
+// files they read can be tracked as workflow dependencies.
+var fileFunctionPattern = regexp.MustCompile(`file\(\s*['"]([^'"]+)['"]\s*\)`)
+
+// inputReferencePattern matches references to other inputs within an
+// expression, e.g. ${{ inputs.name }}.
+var inputReferencePattern = regexp.MustCompile(`\binputs\.([a-zA-Z_][a-zA-Z0-9_]*)`)
+
 // Workflow helper methods
 
 // GetAgent retrieves an agent by name
@@ -37,6 +49,36 @@ func (w *Workflow) GetSteps() []*Step {
 	return w.Workflow.Steps
 }
 
+// GetPersistence returns what is kept of the runs of the workflow, full when
+// it isn't set.
+func (w *Workflow) GetPersistence() string {
+	if w.Persistence == "" {
+		return PersistenceFull
+	}
+	return w.Persistence
+}
+
+// KeepsRunData reports whether the inputs, outputs, errors and progress text
+// of the runs of the workflow are kept.
+func (w *Workflow) KeepsRunData() bool {
+	return w.GetPersistence() == PersistenceFull
+}
+
+// StepCostLabels returns the cost center and owner of a step, falling back to
+// those of the workflow
+func (w *Workflow) StepCostLabels(step *Step) (costCenter, owner string) {
+	if w.Metadata != nil {
+		costCenter, owner = w.Metadata.CostCenter, w.Metadata.Owner
+	}
+	if step.CostCenter != "" {
+		costCenter = step.CostCenter
+	}
+	if step.Owner != "" {
+		owner = step.Owner
+	}
+	return costCenter, owner
+}
+
 // GetInputParam retrieves an input parameter by name
 func (w *Workflow) GetInputParam(name string) (*InputParam, bool) {
 	if w.Inputs == nil {
@@ -73,6 +115,91 @@ func (w *Workflow) ListStepIDs() []string {
 	return ids
 }
 
+// LocalReferences returns the relative paths of every local file the workflow
+// depends on, this includes scripts, blocks and files read through the file()
+// expression function by steps and agent tools. Paths are relative to the
+// directory of the workflow file and returned sorted without duplicates.
+func (w *Workflow) LocalReferences() []string {
+	seen := make(map[string]bool)
+
+	for _, agent := range w.Agents {
+		for _, tool := range agent.Tools {
+			for _, ref := range []string{tool.Uses, tool.Script} {
+				if isLocalReference(ref) {
+					seen[ref] = true
+				}
+			}
+		}
+	}
+
+	for _, step := range w.GetSteps() {
+		for _, ref := range step.LocalReferences() {
+			seen[ref] = true
+		}
+	}
+
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	return refs
+}
+
+// InputDefaultOrder returns the names of all inputs ordered so that every
+// input comes after the inputs its default expression references. An error
+// is returned if input defaults reference each other in a cycle.
+func (w *Workflow) InputDefaultOrder() ([]string, error) {
+	names := make([]string, 0, len(w.Inputs))
+	for name := range w.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("input defaults form a cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+		for _, dep := range w.Inputs[name].DefaultReferences() {
+			if _, ok := w.Inputs[dep]; !ok {
+				continue
+			}
+
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
 // IsAgentStep returns true if this is an agent execution step
 func (s *Step) IsAgentStep() bool {
 	return s.Agent != "" && s.Prompt != ""
@@ -88,6 +215,12 @@ func (s *Step) IsWhileStep() bool {
 	return s.While != ""
 }
 
+// IsForEachStep returns true if this step runs its sub steps or agent prompt
+// once per item of a list
+func (s *Step) IsForEachStep() bool {
+	return s.ForEach != ""
+}
+
 // IsScriptStep returns true if this is a script execution step
 func (s *Step) IsScriptStep() bool {
 	return s.Run != ""
@@ -98,9 +231,91 @@ func (s *Step) IsContainerStep() bool {
 	return s.Container != ""
 }
 
+// IsExportStep returns true if this step exports data to a file
+func (s *Step) IsExportStep() bool {
+	return s.Export != nil
+}
+
+// IsIngestStep returns true if this step extracts text from documents
+func (s *Step) IsIngestStep() bool {
+	return s.Ingest != nil
+}
+
+// IsExtractStep returns true if this step extracts structured outputs from text
+func (s *Step) IsExtractStep() bool {
+	return s.Extract != nil
+}
+
+// IsClassifyStep returns true if this step classifies text into a label
+func (s *Step) IsClassifyStep() bool {
+	return s.Classify != nil
+}
+
+// IsSummarizeStep returns true if this step summarizes text
+func (s *Step) IsSummarizeStep() bool {
+	return s.Summarize != nil
+}
+
+// IsTranslateStep returns true if this step translates text
+func (s *Step) IsTranslateStep() bool {
+	return s.Translate != nil
+}
+
+// IsDiffStep returns true if this step compares two values
+func (s *Step) IsDiffStep() bool {
+	return s.Diff != nil
+}
+
+// IsRaceStep returns true if this step races branches of steps
+func (s *Step) IsRaceStep() bool {
+	return s.Race != nil
+}
+
+// IsEnsembleStep returns true if this step reduces the answers of several agents
+func (s *Step) IsEnsembleStep() bool {
+	return s.Ensemble != nil
+}
+
+// IsPIIStep returns true if this step masks personally identifiable information
+func (s *Step) IsPIIStep() bool {
+	return s.PII != nil
+}
+
+// IsParallelStep returns true if this step runs a group of steps concurrently
+func (s *Step) IsParallelStep() bool {
+	return s.Parallel != nil
+}
+
+// IsKVStep returns true if this step reads or writes a value kept between runs
+func (s *Step) IsKVStep() bool {
+	return s.KV != nil
+}
+
+// IsDedupeStep returns true if this step skips the steps after it for keys seen before
+func (s *Step) IsDedupeStep() bool {
+	return s.Dedupe != nil
+}
+
+// IsHTTPStep returns true if this step sends an HTTP request
+func (s *Step) IsHTTPStep() bool {
+	return s.HTTP != nil
+}
+
+// IsIssueStep returns true if this step creates or updates an issue in an issue tracker
+func (s *Step) IsIssueStep() bool {
+	return s.Issue != nil
+}
+
+// IsAssertStep returns true if this step checks expressions about the results of earlier steps
+func (s *Step) IsAssertStep() bool {
+	return len(s.Assert) > 0
+}
+
 // GetStepType returns the type of step as a string
 func (s *Step) GetStepType() string {
 	switch {
+	case s.IsForEachStep():
+		return "for_each"
 	case s.IsAgentStep():
 		return "agent"
 	case s.IsBlockStep():
@@ -109,6 +324,38 @@ func (s *Step) GetStepType() string {
 		return "script"
 	case s.IsContainerStep():
 		return "container"
+	case s.IsExportStep():
+		return "export"
+	case s.IsIngestStep():
+		return "ingest"
+	case s.IsExtractStep():
+		return "extract"
+	case s.IsClassifyStep():
+		return "classify"
+	case s.IsSummarizeStep():
+		return "summarize"
+	case s.IsTranslateStep():
+		return "translate"
+	case s.IsDiffStep():
+		return "diff"
+	case s.IsRaceStep():
+		return "race"
+	case s.IsEnsembleStep():
+		return "ensemble"
+	case s.IsPIIStep():
+		return "pii"
+	case s.IsParallelStep():
+		return "parallel"
+	case s.IsKVStep():
+		return "kv"
+	case s.IsDedupeStep():
+		return "dedupe"
+	case s.IsHTTPStep():
+		return "http"
+	case s.IsIssueStep():
+		return "issue"
+	case s.IsAssertStep():
+		return "assert"
 	default:
 		return "unknown"
 	}
@@ -123,9 +370,75 @@ func (s *Step) HasOutput(name string) bool {
 	return exists
 }
 
+// DefaultRepairAttempts is the number of times the response of an agent
+// step with outputs is repaired when the step doesn't set repair.
+const DefaultRepairAttempts = 2
+
+// RepairAttempts returns the number of times the response of the agent
+// step is sent back to the agent to be corrected when it doesn't match the
+// step's outputs, DefaultRepairAttempts unless the step sets repair.
+func (s *Step) RepairAttempts() int {
+	if s.Repair != nil {
+		return *s.Repair
+	}
+	return DefaultRepairAttempts
+}
+
+// LocalReferences returns the relative paths of local files referenced by
+// the step and any of its nested steps.
+func (s *Step) LocalReferences() []string {
+	var refs []string
+
+	if isLocalReference(s.Uses) {
+		refs = append(refs, s.Uses)
+	}
+
+	// scripts may be invoked with arguments, e.g. "./script.sh --flag"
+	if fields := strings.Fields(s.Run); len(fields) > 0 && isLocalReference(fields[0]) {
+		refs = append(refs, fields[0])
+	}
+
+	for _, match := range fileFunctionPattern.FindAllStringSubmatch(s.Prompt, -1) {
+		refs = append(refs, match[1])
+	}
+
+	for _, child := range s.Steps {
+		refs = append(refs, child.LocalReferences()...)
+	}
+
+	return refs
+}
+
+func isLocalReference(ref string) bool {
+	return strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "../")
+}
+
 // IsCustom returns true if this agent has a custom configuration
 func (a *Agent) IsCustom() bool {
-	return a.Model != ""
+	return a.Model != "" || a.Tier != ""
+}
+
+// ProviderName returns the name the agent's provider is registered under.
+// Agents using an openai-compatible provider get a provider of their own for
+// each endpoint and model, named after a hash of both.
+func (a *Agent) ProviderName() string {
+	if a.Provider != OpenAICompatibleProvider {
+		return a.Provider
+	}
+
+	headers := make([]string, 0, len(a.Headers))
+	for name, value := range a.Headers {
+		headers = append(headers, name+": "+value)
+	}
+	sort.Strings(headers)
+
+	hash := sha256.New()
+	for _, part := range append([]string{a.BaseURL, a.APIKeyEnv, a.Model}, headers...) {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+
+	return fmt.Sprintf("%s-%x", a.Provider, hash.Sum(nil)[:6])
 }
 
 // HasTool checks if the agent has a specific tool
@@ -169,6 +482,43 @@ func (ip *InputParam) HasDefault() bool {
 	return ip.Default != nil
 }
 
+// DefaultReferences returns the names of the inputs referenced by the
+// default value expression, sorted and without duplicates.
+func (ip *InputParam) DefaultReferences() []string {
+	def, ok := ip.Default.(string)
+	if !ok || !strings.Contains(def, "${{") {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var refs []string
+	for _, match := range inputReferencePattern.FindAllStringSubmatch(def, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			refs = append(refs, match[1])
+		}
+	}
+	sort.Strings(refs)
+
+	return refs
+}
+
+// HasExpressionDefault returns true if the default value is an expression
+// which needs to be evaluated when the workflow starts.
+func (ip *InputParam) HasExpressionDefault() bool {
+	def, ok := ip.Default.(string)
+	return ok && strings.Contains(def, "${{")
+}
+
+// EnumValues returns the allowed values of an enum input
+func (ip *InputParam) EnumValues() []string {
+	values := make([]string, len(ip.Enum))
+	for i, option := range ip.Enum {
+		values[i] = option.Value
+	}
+	return values
+}
+
 // GetTypeString returns the type as a string, defaulting to "string" if not specified
 func (ip *InputParam) GetTypeString() string {
 	if ip.Type == "" {
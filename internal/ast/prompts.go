@@ -0,0 +1,138 @@
+package ast
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Prompt is a named prompt of the workflow's prompt library, which agent
+// steps use by setting their prompt to its name and version, e.g.
+// review@v3, or to an experiment splitting runs between its versions, e.g.
+// review@90% v3, 10% v4.
+type Prompt struct {
+	// Description explains what the prompt is for
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Versions maps each version of the prompt to its text, which may contain
+	// expressions, e.g. v3: "Review this diff: ${{ inputs.diff }}"
+	Versions map[string]string `yaml:"versions" json:"versions" jsonschema:"required"`
+
+	Position Position `yaml:"-" json:"-"`
+}
+
+// PromptRef is a reference to a prompt of the workflow's prompt library.
+type PromptRef struct {
+	// Name is the name of the prompt
+	Name string
+	// Variants are the versions the reference chooses between, with the
+	// percentage of runs using each. A reference to a single version has a
+	// single variant with a weight of 100.
+	Variants []PromptVariant
+}
+
+// PromptVariant is a version of a prompt used by a percentage of runs.
+type PromptVariant struct {
+	Version string
+	Weight  int
+}
+
+// IsExperiment reports whether the reference splits runs between versions.
+func (r *PromptRef) IsExperiment() bool {
+	return len(r.Variants) > 1
+}
+
+// String returns the reference as it's written in a step.
+func (r *PromptRef) String() string {
+	if !r.IsExperiment() {
+		return r.Name + "@" + r.Variants[0].Version
+	}
+
+	variants := make([]string, len(r.Variants))
+	for i, variant := range r.Variants {
+		variants[i] = fmt.Sprintf("%d%% %s", variant.Weight, variant.Version)
+	}
+	return r.Name + "@" + strings.Join(variants, ", ")
+}
+
+var (
+	promptRefPattern     = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)@(.+)$`)
+	promptVersionPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	promptVariantPattern = regexp.MustCompile(`^(\d+)\s*%\s*([a-zA-Z0-9_.-]+)$`)
+)
+
+// ParsePromptRef parses the prompt of a step as a reference to a prompt of
+// the library, e.g. review@v3 or review@90% v3, 10% v4. It returns false
+// when the prompt isn't written as a reference, and an error when it's a
+// malformed one.
+func ParsePromptRef(prompt string) (*PromptRef, bool, error) {
+	match := promptRefPattern.FindStringSubmatch(strings.TrimSpace(prompt))
+	if match == nil {
+		return nil, false, nil
+	}
+
+	ref := &PromptRef{Name: match[1]}
+	spec := strings.TrimSpace(match[2])
+	if promptVersionPattern.MatchString(spec) {
+		ref.Variants = []PromptVariant{{Version: spec, Weight: 100}}
+		return ref, true, nil
+	}
+
+	if !strings.Contains(spec, "%") {
+		return nil, false, nil
+	}
+
+	total := 0
+	for _, part := range strings.Split(spec, ",") {
+		variant := promptVariantPattern.FindStringSubmatch(strings.TrimSpace(part))
+		if variant == nil {
+			return ref, true, fmt.Errorf("invalid experiment %q, use percentages of versions such as %s@90%% v3, 10%% v4", spec, ref.Name)
+		}
+
+		weight, _ := strconv.Atoi(variant[1])
+		if weight == 0 {
+			continue
+		}
+		ref.Variants = append(ref.Variants, PromptVariant{Version: variant[2], Weight: weight})
+		total += weight
+	}
+
+	if total != 100 {
+		return ref, true, fmt.Errorf("the percentages of experiment %q add up to %d%%, they must add up to 100%%", spec, total)
+	}
+
+	return ref, true, nil
+}
+
+// StepPromptRef returns the reference of an agent step to a prompt of the
+// workflow's library, false when its prompt is text rather than the name
+// of a prompt in the library.
+func (w *Workflow) StepPromptRef(step *Step) (*PromptRef, bool) {
+	ref, ok, err := ParsePromptRef(step.Prompt)
+	if !ok || err != nil {
+		return nil, false
+	}
+	if _, exists := w.Prompts[ref.Name]; !exists {
+		return nil, false
+	}
+
+	return ref, true
+}
+
+// StepPromptTemplates returns the text of the prompt of an agent step, or
+// of every version its reference to the prompt library may use.
+func (w *Workflow) StepPromptTemplates(step *Step) []string {
+	ref, ok := w.StepPromptRef(step)
+	if !ok {
+		return []string{step.Prompt}
+	}
+
+	prompt := w.Prompts[ref.Name]
+	templates := make([]string, 0, len(ref.Variants))
+	for _, variant := range ref.Variants {
+		if text, ok := prompt.Versions[variant.Version]; ok {
+			templates = append(templates, text)
+		}
+	}
+	return templates
+}
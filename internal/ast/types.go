@@ -109,6 +109,10 @@ type Workflow struct {
 	// These inputs built before anything else and can be used anywhere across the workflow
 	// file, including in prompts, conditions, and outputs, agents, steps, e.t.c.
 	Inputs map[string]*InputParam `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	// Outputs declares the type and purpose of the values the workflow returns, the
+	// values themselves are set in workflow.outputs. Declared outputs are checked when
+	// the workflow completes, which is useful for workflows used as blocks by others.
+	Outputs map[string]*OutputSchema `yaml:"outputs,omitempty" json:"outputs,omitempty"`
 	// Metadata contains descriptive information about the workflow such as name, description, and author.
 	Metadata *WorkflowMetadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
 	// Agents defines AI agents that can be referenced in workflow steps.
@@ -117,6 +121,24 @@ type Workflow struct {
 	// Requirements specifies the runtime programs needed to execute this workflow.
 	// These will requirements will be installed on the machine running the workflow.
 	Requirements *Requirements `yaml:"requirements,omitempty" json:"requirements,omitempty"`
+	// Context declares facts and constraints shared by every agent in the workflow.
+	// They are rendered through expressions and appended to the system prompt of
+	// every agent so that common instructions don't need to be repeated.
+	Context *WorkflowContext `yaml:"context,omitempty" json:"context,omitempty"`
+	// Prompts is the workflow's library of named prompts with versions. Agent steps
+	// use a version by setting their prompt to e.g. review@v3, or split runs between
+	// versions with an experiment such as review@90% v3, 10% v4. The version each run
+	// used is recorded in the run history.
+	Prompts map[string]*Prompt `yaml:"prompts,omitempty" json:"prompts,omitempty"`
+	// Triage asks an agent to diagnose the run when it fails. The failing step, its
+	// error and the recent events are sent to the agent, and its diagnosis and
+	// suggested fix are added to the run summary and the run history.
+	Triage *Triage `yaml:"triage,omitempty" json:"triage,omitempty"`
+	// Persistence controls what is kept of each run in the run history, the run
+	// log and the server's executions: "full" keeps everything, "metadata" keeps
+	// the status, timings and usage of the run but not its inputs, outputs,
+	// errors or progress text, and "none" keeps nothing. Defaults to "full".
+	Persistence string `yaml:"persistence,omitempty" json:"persistence,omitempty" jsonschema:"enum=full,enum=metadata,enum=none"`
 	// Workflow contains the main workflow definition including inputs, steps, and outputs.
 	Workflow *WorkflowDef `yaml:"workflow" json:"workflow" validate:"required"`
 
@@ -134,6 +156,26 @@ type Requirements struct {
 	Position Position `yaml:"-" json:"-"`
 }
 
+// WorkflowContext holds the global context appended to every agent's system prompt
+type WorkflowContext struct {
+	// Facts lists information every agent should know, e.g. "The customer is {{ inputs.customer }}"
+	Facts []string `yaml:"facts,omitempty" json:"facts,omitempty"`
+	// Constraints lists rules every agent must follow, e.g. "Respond in British English"
+	Constraints []string `yaml:"constraints,omitempty" json:"constraints,omitempty"`
+
+	Position Position `yaml:"-" json:"-"`
+}
+
+// Triage configures the diagnosis of failed runs
+type Triage struct {
+	// Agent is the name of the agent which diagnoses the failure
+	Agent string `yaml:"agent" json:"agent" jsonschema:"required"`
+	// Instructions are added to the prompt sent to the agent, e.g. "Our scripts run on Python 3.12"
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+
+	Position Position `yaml:"-" json:"-"`
+}
+
 // RuntimeType represents supported runtime environments for executing scripts and tools
 type RuntimeType string
 
@@ -144,15 +186,20 @@ var (
 	RuntimeTypeGo RuntimeType = "go"
 	// RuntimeTypePython represents Python runtime for Python script execution
 	RuntimeTypePython RuntimeType = "python"
+	// RuntimeTypeOllama represents a local Ollama server for running models
+	RuntimeTypeOllama RuntimeType = "ollama"
 )
 
 // Runtime specifies a required runtime environment with an optional version constraint
 type Runtime struct {
 	// Name specifies the runtime.
-	Name RuntimeType `yaml:"name" json:"name" jsonschema:"enum=node,enum=go,enum=python,default=go"`
+	Name RuntimeType `yaml:"name" json:"name" jsonschema:"enum=node,enum=go,enum=python,enum=ollama,default=go"`
 	// Version optionally specifies a version constraint for the runtime (e.g., "18.0.0", "3.9")
 	// Leave empty to install the latest version.
 	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	// Models lists the models an ollama runtime needs, e.g. llama3.1. Missing
+	// models are pulled before the workflow runs.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
 }
 
 // WorkflowMetadata contains descriptive information about the workflow for documentation and discovery
@@ -161,18 +208,42 @@ type WorkflowMetadata struct {
 	Name string `yaml:"name" json:"name" validate:"required"`
 	// Description provides a detailed explanation of what the workflow does and its purpose
 	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// CostCenter attributes the cost of the workflow's steps to a cost center in cost reports
+	// and metrics, steps may override it
+	CostCenter string `yaml:"cost_center,omitempty" json:"cost_center,omitempty"`
+	// Owner is the team or person responsible for the workflow's costs, steps may override it
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	// Schedule runs the workflow on a cron expression while it's served by laq serve
+	Schedule *Schedule `yaml:"schedule,omitempty" json:"schedule,omitempty"`
 
 	Position Position `yaml:"-" json:"-"`
 }
 
+// Schedule runs a workflow on a cron expression while it's served by laq serve
+type Schedule struct {
+	// Cron is the cron expression the workflow runs on, e.g. "0 9 * * 1-5" or "@daily"
+	Cron string `yaml:"cron" json:"cron"`
+	// Timezone is the IANA time zone the cron expression is in, e.g. "Europe/London", the
+	// server's local time zone when it isn't set
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	// Inputs are the inputs of the scheduled runs
+	Inputs map[string]interface{} `yaml:"inputs,omitempty" json:"inputs,omitempty"`
+	// Overlap is what happens when a run is due while the previous scheduled run is still going:
+	// skip the run, queue it until the previous run finishes, or cancel the previous run. Defaults
+	// to skip
+	Overlap string `yaml:"overlap,omitempty" json:"overlap,omitempty" jsonschema:"enum=skip,enum=queue,enum=cancel_previous"`
+}
+
 // Agent represents an AI agent configuration that can be used in workflow steps to perform tasks requiring intelligence
 type Agent struct {
 	// Name is the identifier for this agent (used internally, not in schema)
 	Name string `yaml:"-" json:"name,omitempty" jsonschema:"-"`
 	// Provider specifies the AI service provider
-	Provider string `yaml:"provider,omitempty" json:"provider,omitempty" jsonschema:"enum=anthropic,enum=openai,enum=local"`
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty" jsonschema:"enum=anthropic,enum=openai,enum=openai-compatible,enum=local"`
 	// Model specifies the specific AI model to use.
 	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+	// Tier lets the engine choose the model from the provider's capability tier, based on model pricing, latency and the remaining run budget. Used instead of model
+	Tier string `yaml:"tier,omitempty" json:"tier,omitempty" jsonschema:"enum=fast,enum=balanced,enum=best"`
 	// Temperature controls randomness in AI responses (0.0 = deterministic, 1.0 = very creative)
 	Temperature *float64 `yaml:"temperature,omitempty" json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
 	// SystemPrompt provides instructions that define the agent's role and behavior
@@ -183,14 +254,57 @@ type Agent struct {
 	TopP *float64 `yaml:"top_p,omitempty" json:"top_p,omitempty" validate:"omitempty,min=0,max=1"`
 	// Tools defines the tools and capabilities available to this agent
 	Tools []*Tool `yaml:"tools,omitempty" json:"tools,omitempty"`
+	// ToolResultFormat is how tool results in the agent's responses appear in step outputs, "text" prefixes
+	// them with "Tool result:" and "json" keeps them as JSON so they can be parsed as outputs. Defaults to "text"
+	ToolResultFormat string `yaml:"tool_result_format,omitempty" json:"tool_result_format,omitempty" jsonschema:"enum=text,enum=json"`
 	// With provides additional configuration parameters for the referenced agent
 	With map[string]interface{} `yaml:"with,omitempty" json:"with,omitempty"`
 	// Config provides additional agent-specific configuration options
 	Config map[string]interface{} `yaml:"config,omitempty" json:"config,omitempty"`
+	// Timeout is the maximum time to wait for a single model request, e.g. "30s"
+	Timeout *Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// HedgeAfter starts a second identical model request if the first hasn't responded within this duration, the first response received is used
+	HedgeAfter *Duration `yaml:"hedge_after,omitempty" json:"hedge_after,omitempty"`
+	// Fallback names another agent of the workflow which is used instead of this agent while its
+	// provider is unavailable, that is while the provider's circuit breaker is open
+	Fallback string `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	// BaseURL is the URL of the chat completions API of an openai-compatible provider, e.g. a
+	// vLLM server, an Azure OpenAI resource or a LiteLLM gateway, such as "http://localhost:8000/v1"
+	BaseURL string `yaml:"base_url,omitempty" json:"base_url,omitempty"`
+	// APIKeyEnv is the environment variable holding the API key of an openai-compatible provider,
+	// requests are sent without an API key when it isn't set
+	APIKeyEnv string `yaml:"api_key_env,omitempty" json:"api_key_env,omitempty"`
+	// Headers are sent with each request to an openai-compatible provider, environment variables in
+	// their values are expanded, e.g. "api-key: ${AZURE_OPENAI_KEY}"
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
 
 	Position Position `yaml:"-" json:"-"`
 }
 
+// Persistence settings of a workflow, see Workflow.Persistence.
+const (
+	PersistenceFull     = "full"
+	PersistenceMetadata = "metadata"
+	PersistenceNone     = "none"
+)
+
+// Overlap policies of a schedule, see Schedule.Overlap.
+const (
+	OverlapSkip           = "skip"
+	OverlapQueue          = "queue"
+	OverlapCancelPrevious = "cancel_previous"
+)
+
+// Tool result formats of an agent, see Agent.ToolResultFormat.
+const (
+	ToolResultFormatText = "text"
+	ToolResultFormatJSON = "json"
+)
+
+// OpenAICompatibleProvider is the provider of agents using a service which
+// speaks the OpenAI chat completions API at their own base URL.
+const OpenAICompatibleProvider = "openai-compatible"
+
 // ToolType represents the different categories of tools available to agents
 type ToolType string
 
@@ -299,16 +413,34 @@ type WorkflowDef struct {
 	Steps []*Step `yaml:"steps" json:"steps" jsonschema:"required,minLength=1"`
 	// Outputs defines the values that will be returned when the workflow completes
 	Outputs map[string]interface{} `yaml:"outputs,omitempty" json:"outputs,omitempty"`
+	// Timeout limits how long the whole workflow may run, e.g. "10m". The step running when
+	// it's reached is cancelled and the workflow fails
+	Timeout *Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Budget limits the estimated cost and tokens of the whole workflow. The step running when
+	// it's exceeded is cancelled and the workflow fails
+	Budget *RunBudget `yaml:"budget,omitempty" json:"budget,omitempty"`
 
 	Position Position `yaml:"-" json:"-"`
 }
 
 // OutputSchema defines the expected structure and type for a workflow or block output parameter
 type OutputSchema struct {
-	// Type specifies the data type of the output (string, number, boolean, object, array)
-	Type string `yaml:"type"`
+	// Type specifies the data type of the output (string, integer, boolean, object, array)
+	Type string `yaml:"type,omitempty" json:"type,omitempty" jsonschema:"enum=string,enum=integer,enum=boolean,enum=object,enum=array"`
 	// Description explains what this output represents and when it's available
-	Description string `yaml:"description,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// UnmarshalYAML implements custom unmarshaling for OutputSchema to handle shorthand syntax
+func (o *OutputSchema) UnmarshalYAML(value *yaml.Node) error {
+	// Handle shorthand syntax like "summary: string"
+	if value.Kind == yaml.ScalarNode {
+		o.Type = value.Value
+		return nil
+	}
+
+	type outputSchemaAlias OutputSchema
+	return value.Decode((*outputSchemaAlias)(o))
 }
 
 // InputParam defines an input parameter that can be passed to a workflow with validation and constraints
@@ -320,7 +452,9 @@ type InputParam struct {
 	// Required indicates whether this input must be provided when the workflow starts.
 	// If not provided, the workflow will fail.
 	Required bool `yaml:"required,omitempty" json:"required,omitempty" jsonschema:"default=false"`
-	// Default provides a fallback value when this input is not specified
+	// Default provides a fallback value when this input is not specified. String
+	// defaults may contain expressions, e.g. ${{ env.USER }} or ${{ now() }}, which
+	// are evaluated when the workflow starts and can reference other inputs.
 	Default interface{} `yaml:"default,omitempty" json:"default,omitempty"`
 	// Pattern defines a regular expression that string inputs must match
 	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
@@ -332,8 +466,12 @@ type InputParam struct {
 	MinItems *int `yaml:"min_items,omitempty" json:"min_items,omitempty"`
 	// MaxItems sets the maximum number of elements for array inputs
 	MaxItems *int `yaml:"max_items,omitempty" json:"max_items,omitempty"`
-	// Enum restricts string inputs to a specific set of allowed values
-	Enum []string `yaml:"enum,omitempty" json:"enum,omitempty"`
+	// Enum restricts string inputs to a specific set of allowed values. Each value can
+	// either be a plain string or an object with a value and a human-readable label.
+	Enum []EnumOption `yaml:"enum,omitempty" json:"enum,omitempty"`
+	// Multiple allows an enum input to accept a list of the allowed values rather
+	// than a single value
+	Multiple bool `yaml:"multiple,omitempty" json:"multiple,omitempty" jsonschema:"default=false"`
 
 	Position Position `yaml:"-" json:"-"`
 }
@@ -366,13 +504,26 @@ type Step struct {
 	// While specifies a condition that must be true for the step to execute. The expression
 	// must evaluate to a boolean.
 	While string `yaml:"while,omitempty" json:"while,omitempty" jsonschema:"oneof_required=while"`
+	// ForEach is a list expression, e.g. ${{ steps.fetch.outputs.items }}, the step's sub steps or
+	// agent prompt run once per item. The item and its index are ${{ each.item }} and ${{ each.index }},
+	// the outputs of each iteration are collected into the list steps.<id>.outputs
+	ForEach string `yaml:"for_each,omitempty" json:"for_each,omitempty"`
+	// MaxParallel is how many iterations of a for_each step run at the same time, defaults to 1
+	MaxParallel *int `yaml:"max_parallel,omitempty" json:"max_parallel,omitempty" jsonschema:"minimum=1"`
 	// Steps defines a list of sub steps to execute in sequence. In general it is discouraged to use
 	// sub steps unless you are using a while loop or some other control flow mechanism.
 	Steps []*Step `yaml:"steps,omitempty" json:"steps,omitempty"`
 	// Agent specifies which AI agent to use for this step (references an agent defined in the agents section)
 	Agent string `yaml:"agent,omitempty" json:"agent,omitempty" jsonschema:"oneof_required=agent"`
-	// Prompt provides instructions or questions for the AI agent to process
+	// Prompt provides instructions or questions for the AI agent to process, or names a version of
+	// a prompt in the prompts section, e.g. review@v3, or an experiment between its versions, e.g.
+	// review@90% v3, 10% v4
 	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+	// Session continues the conversation of the earlier agent steps with the same session, so
+	// the agent remembers what it was asked and answered. Steps in a session must use the same
+	// agent, providers which keep their own sessions such as claude-code resume them rather than
+	// being sent the conversation again
+	Session string `yaml:"session,omitempty" json:"session,omitempty"`
 	// Uses references a predefined block, workflow, or action to execute
 	Uses string `yaml:"uses,omitempty" json:"uses,omitempty" jsonschema:"oneof_required=uses"`
 	// Run contains a bash script to execute directly in this step, this can call out to other
@@ -384,6 +535,56 @@ type Step struct {
 	Container string `yaml:"container,omitempty" json:"container,omitempty" jsonschema:"oneof_required=container"`
 	// Command defines the command and arguments to execute in a container
 	Command []string `yaml:"command,omitempty" json:"command,omitempty"`
+	// Resources requests hardware such as GPUs for container and run steps
+	Resources *StepResources `yaml:"resources,omitempty" json:"resources,omitempty"`
+	// Export writes a list of objects, such as the structured output of an agent step,
+	// to a CSV or XLSX file
+	Export *ExportStep `yaml:"export,omitempty" json:"export,omitempty" jsonschema:"oneof_required=export"`
+	// Ingest extracts the text of PDF, HTML and DOCX documents as chunks for use in prompts
+	Ingest *IngestStep `yaml:"ingest,omitempty" json:"ingest,omitempty" jsonschema:"oneof_required=ingest"`
+	// Extract asks an agent to extract the fields declared in outputs from some text,
+	// using the provider's structured output support
+	Extract *ExtractStep `yaml:"extract,omitempty" json:"extract,omitempty" jsonschema:"oneof_required=extract"`
+	// Classify asks an agent to choose one label from a label set for some text, along
+	// with its confidence in the label
+	Classify *ClassifyStep `yaml:"classify,omitempty" json:"classify,omitempty" jsonschema:"oneof_required=classify"`
+	// Summarize asks an agent to summarize some text, splitting text which is too long for
+	// one request into chunks which are summarized and then combined
+	Summarize *SummarizeStep `yaml:"summarize,omitempty" json:"summarize,omitempty" jsonschema:"oneof_required=summarize"`
+	// Translate asks an agent to translate some text into another language, detecting the
+	// language of the text
+	Translate *TranslateStep `yaml:"translate,omitempty" json:"translate,omitempty" jsonschema:"oneof_required=translate"`
+	// Diff compares two texts or JSON values, e.g. the previous and new output of a prompt,
+	// and outputs the changes along with a unified diff
+	Diff *DiffStep `yaml:"diff,omitempty" json:"diff,omitempty" jsonschema:"oneof_required=diff"`
+	// Race runs alternative branches of steps concurrently, e.g. asking two models the same
+	// question, and continues with the first branch to succeed, cancelling the others
+	Race *RaceStep `yaml:"race,omitempty" json:"race,omitempty" jsonschema:"oneof_required=race"`
+	// Ensemble sends the same prompt to several agents and reduces their answers to one by
+	// majority vote, a judge agent or an expression scoring each answer
+	Ensemble *EnsembleStep `yaml:"ensemble,omitempty" json:"ensemble,omitempty" jsonschema:"oneof_required=ensemble"`
+	// PII detects personally identifiable information such as emails and credit card numbers
+	// in some text and masks it, e.g. before the text is sent to a model
+	PII *PIIStep `yaml:"pii,omitempty" json:"pii,omitempty" jsonschema:"oneof_required=pii"`
+	// Parallel runs a group of independent steps concurrently and merges their outputs
+	// into the outputs of the step, keyed by the id of each step
+	Parallel *ParallelStep `yaml:"parallel,omitempty" json:"parallel,omitempty" jsonschema:"oneof_required=parallel"`
+	// KV reads or writes a value the workflow keeps between runs, e.g. the cursor of a scheduled
+	// workflow or the IDs of the items it has already processed
+	KV *KVStep `yaml:"kv,omitempty" json:"kv,omitempty" jsonschema:"oneof_required=kv"`
+	// Dedupe stops the steps after it when its key has already been seen, e.g. the ID of a
+	// webhook event which was delivered twice. Keys are remembered like kv step values
+	Dedupe *DedupeStep `yaml:"dedupe,omitempty" json:"dedupe,omitempty" jsonschema:"oneof_required=dedupe"`
+	// HTTP calls a REST API and outputs the status, headers and body of the response, without
+	// running curl in a run or container step
+	HTTP *HTTPStep `yaml:"http,omitempty" json:"http,omitempty" jsonschema:"oneof_required=http"`
+	// Issue creates or updates an issue in Jira or Linear, e.g. to file a ticket for what an
+	// agent triaged. Issues created with a key are only created once per key
+	Issue *IssueStep `yaml:"issue,omitempty" json:"issue,omitempty" jsonschema:"oneof_required=issue"`
+	// Assert checks expressions about the results of earlier steps, e.g. that an agent chose
+	// the expected label, and fails the step showing the actual and expected values of the
+	// assertions which are false
+	Assert []Assertion `yaml:"assert,omitempty" json:"assert,omitempty" jsonschema:"oneof_required=assert"`
 	// With provides input parameters for the referenced script, workflow or block
 	With map[string]interface{} `yaml:"with,omitempty" json:"with,omitempty"`
 	// Updates defines changes to make to the workflow state when this step completes
@@ -395,10 +596,489 @@ type Step struct {
 	SkipIf string `yaml:"skip_if,omitempty" json:"skip_if,omitempty"`
 	// Outputs defines values that this step makes available to subsequent steps and the final workflow output
 	Outputs map[string]schema.JSON `yaml:"outputs,omitempty" json:"outputs,omitempty"`
+	// Repair is the number of times the response of an agent step with outputs is sent back to
+	// the agent, along with why it isn't valid JSON or doesn't match the outputs, to be corrected.
+	// The step fails when the response is still invalid. Responses are repaired
+	// DefaultRepairAttempts times by default, 0 accepts invalid responses as they are
+	Repair *int `yaml:"repair,omitempty" json:"repair,omitempty" jsonschema:"minimum=0"`
+	// Compress shrinks the prompt of an agent step which doesn't fit in the context window of
+	// its model, dropping the sections of the prompt with the lowest priority first and then
+	// summarizing the longest values of its expressions. Prompts aren't compressed by default
+	Compress *PromptCompression `yaml:"compress,omitempty" json:"compress,omitempty"`
+	// Budget limits what a while or ensemble step may spend. Once a limit is reached the step
+	// stops early with the results it has so far and sets its budget_exhausted output
+	Budget *StepBudget `yaml:"budget,omitempty" json:"budget,omitempty"`
+	// Retry retries the step when it fails, e.g. because of a flaky API or a rate limit
+	Retry *StepRetry `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// ContinueOnError continues the workflow when the step fails, once any retries are exhausted.
+	// The failure is recorded in the step's status and error, e.g. ${{ steps.fetch.error }}
+	ContinueOnError bool `yaml:"continue_on_error,omitempty" json:"continue_on_error,omitempty"`
+	// Timeout limits how long each attempt of the step may run, e.g. "30s". The step is cancelled
+	// and fails with a timed out error when it's reached, which retry and on_failure handle
+	// like any other failure
+	Timeout *Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// OnFailure is a list of steps to run when the step fails, e.g. to notify someone or fall back
+	// to a default. The workflow continues when they succeed, their outputs are available as
+	// ${{ steps.<id>.steps.<handler_id>.output }}
+	OnFailure []*Step `yaml:"on_failure,omitempty" json:"on_failure,omitempty"`
+	// CostCenter attributes the cost of this step to a cost center in cost reports and metrics,
+	// defaults to the cost center of the workflow
+	CostCenter string `yaml:"cost_center,omitempty" json:"cost_center,omitempty"`
+	// Owner is the team or person responsible for the cost of this step, defaults to the
+	// owner of the workflow
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
 
 	Position Position `yaml:"-" json:"-"`
 }
 
+// StepResources defines the hardware a container or run step needs, the step
+// fails before it starts when the host can't provide it
+type StepResources struct {
+	// GPUs is the number of GPUs to make available to the step, or "all"
+	GPUs string `yaml:"gpus,omitempty" json:"gpus,omitempty" jsonschema:"oneof_type=string;integer"`
+	// Devices lists host devices to make available to a container step, e.g. /dev/dri
+	Devices []string `yaml:"devices,omitempty" json:"devices,omitempty"`
+}
+
+// ExportStep converts a list of objects into a table, or a list of review findings
+// into SARIF or GitHub annotations, and writes it to a file
+type ExportStep struct {
+	// From is an expression evaluating to a list of objects, e.g. ${{ steps.extract.outputs.rows }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// Path is the file to write, relative to the workflow
+	Path string `yaml:"path" json:"path" jsonschema:"required"`
+	// Format of the file, defaults to the extension of the path. sarif and github write findings
+	// with a file, line, severity and message as SARIF or GitHub check run annotations
+	Format string `yaml:"format,omitempty" json:"format,omitempty" jsonschema:"enum=csv,enum=xlsx,enum=sarif,enum=github"`
+	// Columns lists the object keys to write, in order. Defaults to the properties of
+	// the output schema of the referenced step, or the keys of the objects.
+	Columns []string `yaml:"columns,omitempty" json:"columns,omitempty"`
+	// Tool names the reviewer in SARIF files, defaults to the name of the workflow
+	Tool string `yaml:"tool,omitempty" json:"tool,omitempty"`
+}
+
+// IngestStep extracts the text of documents and splits it into chunks
+type IngestStep struct {
+	// From is the path of the document to ingest relative to the workflow, or an expression
+	// evaluating to a path or a list of paths, e.g. ${{ inputs.document }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// Format of the documents, defaults to the extension of each path
+	Format string `yaml:"format,omitempty" json:"format,omitempty" jsonschema:"enum=pdf,enum=html,enum=docx"`
+	// ChunkSize is the maximum number of characters in a chunk, defaults to 2000
+	ChunkSize int `yaml:"chunk_size,omitempty" json:"chunk_size,omitempty" jsonschema:"minimum=1"`
+	// ChunkOverlap is the number of characters repeated from the end of the previous chunk, defaults to 200
+	ChunkOverlap *int `yaml:"chunk_overlap,omitempty" json:"chunk_overlap,omitempty" jsonschema:"minimum=0"`
+}
+
+// ExtractStep extracts the step's outputs from text with an agent. Responses which
+// don't match the outputs are sent back to the agent to be corrected.
+type ExtractStep struct {
+	// Agent is the agent that extracts the outputs, it must be defined in the agents section
+	Agent string `yaml:"agent" json:"agent" jsonschema:"required"`
+	// From is the text to extract the outputs from, e.g. ${{ steps.ingest.outputs.text }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// Instructions optionally explain what to extract, e.g. "amounts are in euros"
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+	// Retries is the number of times an invalid response is retried, defaults to 2
+	Retries *int `yaml:"retries,omitempty" json:"retries,omitempty" jsonschema:"minimum=0"`
+}
+
+// ClassifyStep classifies text into one of a set of labels with an agent
+type ClassifyStep struct {
+	// Agent is the agent that classifies the text, it must be defined in the agents section
+	Agent string `yaml:"agent" json:"agent" jsonschema:"required"`
+	// From is the text to classify, e.g. ${{ inputs.ticket }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// Labels is the set of labels to choose from
+	Labels []ClassifyLabel `yaml:"labels" json:"labels" jsonschema:"required,minItems=1"`
+	// Instructions optionally explain how to classify the text
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+	// Examples are few-shot examples, a list of objects with text and label, or an
+	// expression evaluating to one, e.g. ${{ state.labelled_tickets }}
+	Examples interface{} `yaml:"examples,omitempty" json:"examples,omitempty" jsonschema:"oneof_type=string;array"`
+	// Samples is the number of times the text is classified, the confidence is then the
+	// share of samples that chose the label rather than the agent's own estimate. Defaults to 1
+	Samples int `yaml:"samples,omitempty" json:"samples,omitempty" jsonschema:"minimum=1,maximum=10"`
+	// MinConfidence is the confidence below which the fallback label is used instead
+	MinConfidence float64 `yaml:"min_confidence,omitempty" json:"min_confidence,omitempty" jsonschema:"minimum=0,maximum=1"`
+	// Fallback is the label used when the confidence is below min_confidence, e.g. "unknown"
+	Fallback string `yaml:"fallback,omitempty" json:"fallback,omitempty"`
+	// Retries is the number of times an invalid response is retried, defaults to 2
+	Retries *int `yaml:"retries,omitempty" json:"retries,omitempty" jsonschema:"minimum=0"`
+}
+
+// SummarizeStep summarizes text with an agent. Text longer than a chunk is split into
+// chunks, each chunk is summarized and the summaries are combined into the summary
+type SummarizeStep struct {
+	// Agent is the agent that writes the summaries, it must be defined in the agents section
+	Agent string `yaml:"agent" json:"agent" jsonschema:"required"`
+	// From is the text to summarize, or a list of texts or chunks such as
+	// ${{ steps.ingest.outputs.chunks }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// Instructions optionally explain what the summary should focus on
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+	// Length is the target length of the summary in words
+	Length int `yaml:"length,omitempty" json:"length,omitempty" jsonschema:"minimum=1"`
+	// Format of the summary, defaults to paragraphs
+	Format string `yaml:"format,omitempty" json:"format,omitempty" jsonschema:"enum=paragraphs,enum=bullets,enum=outline"`
+	// ChunkSize is the maximum number of characters summarized in one request, defaults to 12000
+	ChunkSize int `yaml:"chunk_size,omitempty" json:"chunk_size,omitempty" jsonschema:"minimum=1"`
+}
+
+// TranslateStep translates text into another language with an agent
+type TranslateStep struct {
+	// Agent is the agent that translates the text, it must be defined in the agents section
+	Agent string `yaml:"agent" json:"agent" jsonschema:"required"`
+	// From is the text to translate, e.g. ${{ inputs.description }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// To is the language to translate the text into, a name or code such as French or fr
+	To string `yaml:"to" json:"to" jsonschema:"required"`
+	// Source is the language of the text, it's detected when not set
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+	// Glossary maps terms to the translation they must be given, or is an expression
+	// evaluating to such a map, e.g. ${{ state.glossary.fr }}
+	Glossary interface{} `yaml:"glossary,omitempty" json:"glossary,omitempty" jsonschema:"oneof_type=string;object"`
+	// Instructions optionally explain how to translate the text, e.g. the tone to use
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+	// Retries is the number of times an invalid response is retried, defaults to 2
+	Retries *int `yaml:"retries,omitempty" json:"retries,omitempty" jsonschema:"minimum=0"`
+}
+
+// DiffStep compares two texts or JSON values
+type DiffStep struct {
+	// From is the original text or value, e.g. ${{ state.previous_copy }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// To is the new text or value, e.g. ${{ steps.write.outputs.copy }}
+	To string `yaml:"to" json:"to" jsonschema:"required"`
+	// Format of the values, json compares them structurally. Defaults to json when both
+	// values are objects or lists, otherwise text
+	Format string `yaml:"format,omitempty" json:"format,omitempty" jsonschema:"enum=text,enum=json"`
+	// Context is the number of unchanged lines shown around each change in the diff, defaults to 3
+	Context *int `yaml:"context,omitempty" json:"context,omitempty" jsonschema:"minimum=0"`
+	// Path optionally writes the unified diff to a file, relative to the workflow
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// PIIStep masks the personally identifiable information found in some text
+type PIIStep struct {
+	// From is the text to mask, e.g. ${{ inputs.message }}
+	From string `yaml:"from" json:"from" jsonschema:"required"`
+	// Types of information to mask, defaults to all of them
+	Types []string `yaml:"types,omitempty" json:"types,omitempty" jsonschema:"enum=email,enum=phone,enum=credit_card,enum=national_id"`
+	// Mode is mask, replacing each value with its type e.g. [EMAIL], or tokenize, replacing
+	// each value with a token e.g. [EMAIL_1] which the unmaskPII function turns back into the
+	// value later in the run. Defaults to mask
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty" jsonschema:"enum=mask,enum=tokenize"`
+}
+
+// RaceStep runs branches of steps concurrently and keeps the result of one of them
+type RaceStep struct {
+	// Branches are the alternatives to run, the steps of each branch run in sequence
+	Branches []*RaceBranch `yaml:"branches" json:"branches" jsonschema:"required,minItems=2"`
+	// Best is an expression scoring a branch once it completes, e.g.
+	// ${{ steps.review.outputs.score }}. The branch with the highest score wins, so every
+	// branch runs to completion. Defaults to the first branch to succeed
+	Best string `yaml:"best,omitempty" json:"best,omitempty"`
+	// Timeout limits how long the branches run, branches still running are cancelled
+	Timeout *Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// RaceBranch is one of the alternatives of a race step
+type RaceBranch struct {
+	// ID identifies the branch in the outputs of the race step
+	ID string `yaml:"id" json:"id" jsonschema:"required"`
+	// Steps are run in sequence, the response of the last step is the response of the race step
+	Steps []*Step `yaml:"steps" json:"steps" jsonschema:"required,minItems=1"`
+}
+
+// ParallelStep runs a group of steps concurrently
+type ParallelStep struct {
+	// Steps are run at the same time, so they can't reference each other's outputs
+	Steps []*Step `yaml:"steps" json:"steps" jsonschema:"required,minItems=1"`
+	// MaxConcurrency is how many of the steps run at the same time, defaults to all of them
+	MaxConcurrency *int `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty" jsonschema:"minimum=1"`
+}
+
+// KVStep gets, sets or deletes a value the workflow keeps between runs. Each workflow
+// has its own keys
+type KVStep struct {
+	// Action is get, set or delete, defaults to get
+	Action string `yaml:"action,omitempty" json:"action,omitempty" jsonschema:"enum=get,enum=set,enum=delete"`
+	// Key of the value, e.g. cursor or seen_${{ inputs.id }}
+	Key string `yaml:"key" json:"key" jsonschema:"required"`
+	// Value to set, required by set
+	Value interface{} `yaml:"value,omitempty" json:"value,omitempty"`
+	// Default is the value output by get when the key isn't set
+	Default interface{} `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// DedupeStep records a key the first time it's seen. When the key has been seen before
+// the steps after the dedupe step are skipped, either the rest of the workflow or, for a
+// dedupe step among sub steps, the rest of those sub steps
+type DedupeStep struct {
+	// Key identifies the item being processed, e.g. ${{ inputs.event_id }}
+	Key string `yaml:"key" json:"key" jsonschema:"required"`
+	// TTL is how long a key is remembered, e.g. "24h". Keys are remembered forever by default
+	TTL *Duration `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+}
+
+// Assertion is an expression of an assert step which must be true, e.g.
+// ${{ steps.classify.outputs.label == "urgent" }}
+type Assertion struct {
+	// That is the expression asserted. The values compared by assertions such as a == b or
+	// a > b are shown when they fail
+	That string `yaml:"that" json:"that" jsonschema:"required"`
+	// Message explains what failed when the assertion is false
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// UnmarshalYAML implements custom unmarshaling for Assertion so that plain
+// expressions are accepted as well as that/message objects
+func (a *Assertion) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		a.That = value.Value
+		return nil
+	}
+
+	type assertionAlias Assertion
+	var temp assertionAlias
+	if err := value.Decode(&temp); err != nil {
+		return err
+	}
+
+	*a = Assertion(temp)
+	return nil
+}
+
+// UnmarshalJSON implements custom unmarshaling for Assertion so that plain
+// expressions are accepted as well as that/message objects
+func (a *Assertion) UnmarshalJSON(data []byte) error {
+	var that string
+	if err := json.Unmarshal(data, &that); err == nil {
+		a.That = that
+		return nil
+	}
+
+	type assertionAlias Assertion
+	var temp assertionAlias
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+
+	*a = Assertion(temp)
+	return nil
+}
+
+// HTTPStep sends an HTTP request. Responses with a status of 400 or more fail the step
+type HTTPStep struct {
+	// Method of the request, defaults to GET
+	Method string `yaml:"method,omitempty" json:"method,omitempty" jsonschema:"enum=GET,enum=POST,enum=PUT,enum=PATCH,enum=DELETE,enum=HEAD,enum=OPTIONS"`
+	// URL of the request, which may contain expressions
+	URL string `yaml:"url" json:"url" jsonschema:"required"`
+	// Headers of the request
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// Body of the request. Strings are sent as they are, other values are sent as JSON
+	Body interface{} `yaml:"body,omitempty" json:"body,omitempty"`
+	// Auth adds an Authorization header to the request
+	Auth *HTTPAuth `yaml:"auth,omitempty" json:"auth,omitempty"`
+}
+
+// HTTPAuth authenticates an HTTP request with either a bearer token or a username and
+// password
+type HTTPAuth struct {
+	// Token is sent as a bearer token, e.g. ${{ env.API_TOKEN }}
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+	// Username is sent with Password using basic authentication
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	// Password is sent with Username using basic authentication
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+}
+
+// Actions of an issue step, see IssueStep.Action.
+const (
+	IssueActionCreate = "create"
+	IssueActionUpdate = "update"
+)
+
+// IssueStep creates or updates an issue in an issue tracker. Text fields may contain
+// expressions
+type IssueStep struct {
+	// Tracker is the issue tracker, jira or linear
+	Tracker string `yaml:"tracker" json:"tracker" jsonschema:"required,enum=jira,enum=linear"`
+	// Action is create or update, defaults to create
+	Action string `yaml:"action,omitempty" json:"action,omitempty" jsonschema:"enum=create,enum=update"`
+	// URL is the site of a Jira instance, e.g. https://acme.atlassian.net. Linear uses its
+	// public API unless it's set
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+	// Token authenticates with the tracker, e.g. ${{ env.JIRA_TOKEN }}
+	Token string `yaml:"token" json:"token" jsonschema:"required"`
+	// Email is the Jira Cloud account of the token. Without it the token is sent as a bearer
+	// token, as Jira Data Center personal access tokens are
+	Email string `yaml:"email,omitempty" json:"email,omitempty"`
+	// Project is the Jira project key or the Linear team ID issues are created in, required
+	// by create
+	Project string `yaml:"project,omitempty" json:"project,omitempty"`
+	// ID is the key or ID of the issue to update, required by update
+	ID string `yaml:"id,omitempty" json:"id,omitempty"`
+	// Title of the issue, required by create
+	Title string `yaml:"title,omitempty" json:"title,omitempty"`
+	// Description of the issue
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Type is the Jira issue type, defaults to Task
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+	// Priority is the name of a Jira priority, or a Linear priority: none, urgent, high,
+	// medium, low or a number from 0 to 4
+	Priority string `yaml:"priority,omitempty" json:"priority,omitempty"`
+	// Labels are Jira labels or the IDs of Linear labels
+	Labels []string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	// Assignee is the account ID of the Jira user or the ID of the Linear user
+	Assignee string `yaml:"assignee,omitempty" json:"assignee,omitempty"`
+	// Fields are other fields of the issue, sent to the tracker as they are, e.g. Jira
+	// custom fields
+	Fields map[string]interface{} `yaml:"fields,omitempty" json:"fields,omitempty"`
+	// Key makes creating an issue idempotent, e.g. ${{ inputs.alert_id }}. The issue created
+	// for a key is remembered like kv step values and later runs with the same key output it
+	// rather than creating another
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+}
+
+// Actions of a kv step, see KVStep.Action.
+const (
+	KVActionGet    = "get"
+	KVActionSet    = "set"
+	KVActionDelete = "delete"
+)
+
+// EnsembleStep asks several agents the same question and reduces their answers to one.
+// Without a judge or best the answer given by the most agents wins
+type EnsembleStep struct {
+	// Agents answer the prompt, they must be defined in the agents section. An agent may
+	// be listed more than once to sample it again
+	Agents []string `yaml:"agents" json:"agents" jsonschema:"required,minItems=2"`
+	// Prompt is sent to every agent
+	Prompt string `yaml:"prompt" json:"prompt" jsonschema:"required"`
+	// Judge is an agent which reads every answer and chooses the best one
+	Judge string `yaml:"judge,omitempty" json:"judge,omitempty"`
+	// Instructions optionally tell the judge how to choose, e.g. the criteria to use
+	Instructions string `yaml:"instructions,omitempty" json:"instructions,omitempty"`
+	// Best is an expression scoring an answer, the answer with the highest score wins.
+	// The answer being scored is the output of the step, e.g.
+	// ${{ length(steps.answer.output) }}
+	Best string `yaml:"best,omitempty" json:"best,omitempty"`
+	// Retries is the number of times an invalid response of the judge is retried,
+	// defaults to 2
+	Retries *int `yaml:"retries,omitempty" json:"retries,omitempty" jsonschema:"minimum=0"`
+}
+
+// StepBudget limits the cost, tokens and time a while or ensemble step may spend, zero
+// values are unlimited. The run's budget also stops the step early once it's spent
+type StepBudget struct {
+	// Cost is the most the step may spend in USD, e.g. 0.50
+	Cost float64 `yaml:"cost,omitempty" json:"cost,omitempty" jsonschema:"minimum=0"`
+	// Tokens is the most prompt and completion tokens the step may use
+	Tokens int `yaml:"tokens,omitempty" json:"tokens,omitempty" jsonschema:"minimum=0"`
+	// Duration is the longest the step may run, e.g. "5m"
+	Duration *Duration `yaml:"duration,omitempty" json:"duration,omitempty"`
+}
+
+// PromptCompression configures how the prompt of an agent step is compressed when it doesn't
+// fit in the context window of its model
+type PromptCompression struct {
+	// Summarizer is the agent which summarizes the longest values of the prompt's expressions
+	// once dropping sections isn't enough, usually one using a cheap model. Without a
+	// summarizer only sections are dropped
+	Summarizer string `yaml:"summarizer,omitempty" json:"summarizer,omitempty"`
+	// ContextWindow is the number of tokens the model accepts, defaults to the context window
+	// of the model in the routing table
+	ContextWindow int `yaml:"context_window,omitempty" json:"context_window,omitempty" jsonschema:"minimum=0"`
+}
+
+// RunBudget limits the cost and tokens a whole workflow may spend, zero values are unlimited
+type RunBudget struct {
+	// Cost is the most the workflow may spend in USD, e.g. 2.00
+	Cost float64 `yaml:"cost,omitempty" json:"cost,omitempty" jsonschema:"minimum=0"`
+	// Tokens is the most prompt and completion tokens the workflow may use
+	Tokens int `yaml:"tokens,omitempty" json:"tokens,omitempty" jsonschema:"minimum=0"`
+}
+
+// StepRetry defines how often and how soon a step which failed is attempted again
+type StepRetry struct {
+	// MaxAttempts is how many times the step is attempted in total, including the first attempt
+	MaxAttempts int `yaml:"max_attempts" json:"max_attempts" jsonschema:"required,minimum=1"`
+	// Delay is how long to wait before the first retry, e.g. "2s", defaults to 1s
+	Delay *Duration `yaml:"delay,omitempty" json:"delay,omitempty"`
+	// Backoff is how the delay grows with each retry: constant, linear or exponential, defaults to exponential
+	Backoff string `yaml:"backoff,omitempty" json:"backoff,omitempty" jsonschema:"enum=constant,enum=linear,enum=exponential"`
+	// RetryIf is a condition evaluated after each failure, the step is only retried when it's true.
+	// The error and the number of the failed attempt are ${{ retry.error }} and ${{ retry.attempt }}
+	RetryIf string `yaml:"retry_if,omitempty" json:"retry_if,omitempty"`
+}
+
+// ClassifyLabel is a label of a classify step along with a description that
+// helps the agent choose it
+type ClassifyLabel struct {
+	// Value is the label returned when it's chosen
+	Value string `yaml:"value" json:"value" jsonschema:"required"`
+	// Description explains when the label applies
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// UnmarshalYAML implements custom unmarshaling for ClassifyLabel so that plain
+// string values are accepted as well as value/description objects
+func (l *ClassifyLabel) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		l.Value = value.Value
+		return nil
+	}
+
+	type classifyLabelAlias ClassifyLabel
+	var temp classifyLabelAlias
+	if err := value.Decode(&temp); err != nil {
+		return err
+	}
+
+	*l = ClassifyLabel(temp)
+	return nil
+}
+
+// UnmarshalJSON implements custom unmarshaling for ClassifyLabel so that plain
+// string values are accepted as well as value/description objects
+func (l *ClassifyLabel) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		l.Value = value
+		return nil
+	}
+
+	type classifyLabelAlias ClassifyLabel
+	var temp classifyLabelAlias
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+
+	*l = ClassifyLabel(temp)
+	return nil
+}
+
+// JSONSchema allows a label to be written as a string or an object
+func (ClassifyLabel) JSONSchema() *jsonschema.Schema {
+	properties := jsonschema.NewProperties()
+	properties.Set("value", &jsonschema.Schema{Type: "string", Description: "Value is the label returned when it's chosen"})
+	properties.Set("description", &jsonschema.Schema{Type: "string", Description: "Description explains when the label applies"})
+
+	return &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "string"},
+			{
+				Type:                 "object",
+				Properties:           properties,
+				Required:             []string{"value"},
+				AdditionalProperties: jsonschema.FalseSchema,
+			},
+		},
+	}
+}
+
 func (s Step) JSONSchemaExtend(schema *jsonschema.Schema) {
 	schema.DependentRequired = map[string][]string{
 		"agent": []string{
@@ -407,6 +1087,79 @@ func (s Step) JSONSchemaExtend(schema *jsonschema.Schema) {
 	}
 }
 
+// EnumOption is a single allowed value of an enum input along with an optional
+// label used when presenting the value to users
+type EnumOption struct {
+	// Value is the value passed to the workflow when this option is selected
+	Value string `yaml:"value" json:"value" jsonschema:"required"`
+	// Label is a human-readable name for the option, defaults to the value
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+}
+
+// UnmarshalYAML implements custom unmarshaling for EnumOption so that plain
+// string values are accepted as well as value/label objects
+func (e *EnumOption) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		e.Value = value.Value
+		return nil
+	}
+
+	type enumOptionAlias EnumOption
+	var temp enumOptionAlias
+	if err := value.Decode(&temp); err != nil {
+		return err
+	}
+
+	*e = EnumOption(temp)
+	return nil
+}
+
+// UnmarshalJSON implements custom unmarshaling for EnumOption so that plain
+// string values are accepted as well as value/label objects
+func (e *EnumOption) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		e.Value = value
+		return nil
+	}
+
+	type enumOptionAlias EnumOption
+	var temp enumOptionAlias
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return err
+	}
+
+	*e = EnumOption(temp)
+	return nil
+}
+
+// JSONSchema allows an enum option to be written as a string or an object
+func (EnumOption) JSONSchema() *jsonschema.Schema {
+	properties := jsonschema.NewProperties()
+	properties.Set("value", &jsonschema.Schema{Type: "string", Description: "Value is the value passed to the workflow when this option is selected"})
+	properties.Set("label", &jsonschema.Schema{Type: "string", Description: "Label is a human-readable name for the option, defaults to the value"})
+
+	return &jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "string"},
+			{
+				Type:                 "object",
+				Properties:           properties,
+				Required:             []string{"value"},
+				AdditionalProperties: jsonschema.FalseSchema,
+			},
+		},
+	}
+}
+
+// DisplayLabel returns the label of the option, falling back to the value
+func (e EnumOption) DisplayLabel() string {
+	if e.Label != "" {
+		return e.Label
+	}
+	return e.Value
+}
+
 // Duration wraps time.Duration with custom YAML/JSON marshaling for human-readable duration strings
 type Duration struct {
 	time.Duration
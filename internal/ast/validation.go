@@ -3,19 +3,44 @@ package ast
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/lacquerai/lacquer/internal/cron"
 )
 
 var (
-	ValidProviders = []string{"anthropic", "openai", "local"}
-	ValidRuntimes  = []string{"go", "node", "python"}
-	ValidStepTypes = []string{"agent", "uses", "run", "container", "action", "while"}
+	ValidProviders = []string{"anthropic", "openai", OpenAICompatibleProvider, "local"}
+	ValidRuntimes  = []string{"go", "node", "python", "ollama"}
+	ValidStepTypes = []string{"agent", "uses", "run", "container", "action", "while", "export", "ingest", "extract", "classify", "summarize", "translate", "diff", "pii", "race", "ensemble", "parallel", "kv", "dedupe", "http", "issue", "assert", "for_each"}
 	ValidToolTypes = []string{"uses", "script", "mcp"}
+	// ValidOfficialTools lists the tools available with uses: lacquer/<name>
+	ValidOfficialTools     = []string{"calculator", "fetch-page", "web-search"}
+	ValidTiers             = []string{"fast", "balanced", "best"}
+	ValidToolResultFormats = []string{ToolResultFormatText, ToolResultFormatJSON}
+
+	ValidExportFormats  = []string{"csv", "xlsx", "sarif", "github"}
+	ValidIngestFormats  = []string{"pdf", "html", "docx"}
+	ValidSummaryFormats = []string{"paragraphs", "bullets", "outline"}
+	ValidDiffFormats    = []string{"text", "json"}
+	ValidPIITypes       = []string{"email", "phone", "credit_card", "national_id"}
+	ValidPIIModes       = []string{"mask", "tokenize"}
+	ValidKVActions      = []string{KVActionGet, KVActionSet, KVActionDelete}
+	ValidIssueActions   = []string{IssueActionCreate, IssueActionUpdate}
+	ValidIssueTrackers  = []string{"jira", "linear"}
+	ValidRetryBackoffs  = []string{"constant", "linear", "exponential"}
+	ValidPersistence    = []string{PersistenceFull, PersistenceMetadata, PersistenceNone}
+	ValidOverlaps       = []string{OverlapSkip, OverlapQueue, OverlapCancelPrevious}
+
+	ValidOutputTypes = []string{"string", "integer", "boolean", "array", "object"}
 )
 
 func ListToReadable(list []string) string {
@@ -115,6 +140,8 @@ type Validator struct {
 	wd       string
 	workflow *Workflow
 	result   *ValidationResult
+	// sessions is the agent used by each session's steps
+	sessions map[string]string
 }
 
 // NewValidator creates a new AST validator
@@ -124,6 +151,7 @@ func NewValidator(w *Workflow) *Validator {
 		wd:       wd,
 		workflow: w,
 		result:   &ValidationResult{Valid: true},
+		sessions: make(map[string]string),
 	}
 }
 
@@ -144,6 +172,10 @@ func (v *Validator) ValidateWorkflow() *ValidationResult {
 		v.validateInputs(w.Inputs, "inputs")
 	}
 
+	if w.Outputs != nil {
+		v.validateOutputs()
+	}
+
 	if w.Agents != nil {
 		v.validateAgents()
 	}
@@ -152,6 +184,26 @@ func (v *Validator) ValidateWorkflow() *ValidationResult {
 		v.validateRequirements()
 	}
 
+	if w.Context != nil {
+		v.validateContext()
+	}
+
+	if w.Triage != nil {
+		v.validateTriage()
+	}
+
+	if w.Prompts != nil {
+		v.validatePrompts()
+	}
+
+	if w.Metadata != nil && w.Metadata.Schedule != nil {
+		v.validateSchedule()
+	}
+
+	if w.Persistence != "" && !slices.Contains(ValidPersistence, w.Persistence) {
+		v.result.AddFieldError("persistence", "", fmt.Sprintf("persistence must be one of: %s", strings.Join(ValidPersistence, ", ")))
+	}
+
 	v.validateWorkflowDef()
 
 	return v.result
@@ -170,6 +222,77 @@ func (v *Validator) validateRequirements() {
 		if !isValidRuntime {
 			v.result.AddFieldError("requirements", fmt.Sprintf("runtimes[%d]", i), fmt.Sprintf("runtimes must be one of: %s", ListToReadable(ValidRuntimes)))
 		}
+
+		field := fmt.Sprintf("runtimes[%d]", i)
+		if rr.Name == RuntimeTypeOllama {
+			if rr.Version != "" {
+				v.result.AddFieldError("requirements", field+".version", "the ollama runtime uses the installed ollama and does not support a version")
+			}
+			for j, model := range rr.Models {
+				if strings.TrimSpace(model) == "" {
+					v.result.AddFieldError("requirements", fmt.Sprintf("%s.models[%d]", field, j), "models cannot be empty")
+				}
+			}
+		} else if len(rr.Models) > 0 {
+			v.result.AddFieldError("requirements", field+".models", "models can only be used with the ollama runtime")
+		}
+	}
+}
+
+// validateContext validates the workflow's global context
+func (v *Validator) validateContext() {
+	for i, fact := range v.workflow.Context.Facts {
+		if strings.TrimSpace(fact) == "" {
+			v.result.AddFieldError("context", fmt.Sprintf("facts[%d]", i), "facts cannot be empty")
+		}
+	}
+
+	for i, constraint := range v.workflow.Context.Constraints {
+		if strings.TrimSpace(constraint) == "" {
+			v.result.AddFieldError("context", fmt.Sprintf("constraints[%d]", i), "constraints cannot be empty")
+		}
+	}
+}
+
+// validateTriage validates the diagnosis of failed runs
+func (v *Validator) validateTriage() {
+	agent := v.workflow.Triage.Agent
+	if agent == "" {
+		v.result.AddFieldError("triage", "agent", "triage must specify an agent")
+		return
+	}
+
+	if _, ok := v.workflow.Agents[agent]; !ok {
+		v.result.AddFieldError("triage", "agent", fmt.Sprintf("agent %q must exist in the agents section", agent))
+	}
+}
+
+func (v *Validator) validateSchedule() {
+	schedule := v.workflow.Metadata.Schedule
+
+	if _, err := cron.Parse(schedule.Cron); err != nil {
+		v.result.AddFieldError("metadata.schedule", "cron", err.Error())
+	}
+
+	if schedule.Timezone != "" {
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			v.result.AddFieldError("metadata.schedule", "timezone", fmt.Sprintf("unknown time zone %q", schedule.Timezone))
+		}
+	}
+
+	if schedule.Overlap != "" && !slices.Contains(ValidOverlaps, schedule.Overlap) {
+		v.result.AddFieldError("metadata.schedule", "overlap", fmt.Sprintf("overlap must be one of: %s", strings.Join(ValidOverlaps, ", ")))
+	}
+
+	names := make([]string, 0, len(schedule.Inputs))
+	for name := range schedule.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := v.workflow.Inputs[name]; !ok {
+			v.result.AddFieldError("metadata.schedule", "inputs", fmt.Sprintf("input %q must exist in the inputs section", name))
+		}
 	}
 }
 
@@ -190,12 +313,20 @@ func (v *Validator) validateAgents() {
 
 // validateAgent validates a single agent
 func (v *Validator) validateAgent(agent *Agent, path string) {
-	if agent.Model == "" {
+	if agent.Model == "" && agent.Tier == "" {
 		v.result.AddError(path, "agent must specify a model")
 		return
 	}
 
-	if agent.Model != "" {
+	if agent.Model != "" && agent.Tier != "" {
+		v.result.AddFieldError(path, "tier", "tier cannot be used with model")
+	}
+
+	if agent.Tier != "" && !slices.Contains(ValidTiers, agent.Tier) {
+		v.result.AddFieldError(path, "tier", fmt.Sprintf("tier must be one of: %s", ListToReadable(ValidTiers)))
+	}
+
+	if agent.Model != "" || agent.Tier != "" {
 		if agent.Provider == "" {
 			v.result.AddFieldError(path, "provider", "provider is required when using a model")
 		} else {
@@ -225,9 +356,80 @@ func (v *Validator) validateAgent(agent *Agent, path string) {
 		v.result.AddFieldError(path, "max_tokens", "max_tokens must be positive")
 	}
 
+	if agent.Timeout != nil && agent.Timeout.Duration <= 0 {
+		v.result.AddFieldError(path, "timeout", "timeout must be positive")
+	}
+
+	if agent.HedgeAfter != nil {
+		if agent.HedgeAfter.Duration <= 0 {
+			v.result.AddFieldError(path, "hedge_after", "hedge_after must be positive")
+		} else if agent.Timeout != nil && agent.HedgeAfter.Duration >= agent.Timeout.Duration {
+			v.result.AddFieldError(path, "hedge_after", "hedge_after must be less than timeout")
+		}
+	}
+
+	if agent.ToolResultFormat != "" && !slices.Contains(ValidToolResultFormats, agent.ToolResultFormat) {
+		v.result.AddFieldError(path, "tool_result_format", fmt.Sprintf("tool_result_format must be one of: %s", ListToReadable(ValidToolResultFormats)))
+	}
+
+	v.validateAgentEndpoint(agent, path)
+
+	if agent.Fallback != "" {
+		v.validateAgentFallback(agent, path)
+	}
+
 	v.validateTools(agent.Tools, fmt.Sprintf("%s.tools", path))
 }
 
+// validateAgentFallback ensures the fallback of an agent is another agent
+// and that following the fallbacks doesn't lead back to the agent.
+func (v *Validator) validateAgentFallback(agent *Agent, path string) {
+	if _, ok := v.workflow.Agents[agent.Fallback]; !ok {
+		v.result.AddFieldError(path, "fallback", fmt.Sprintf("agent %q must exist in the agents section", agent.Fallback))
+		return
+	}
+
+	seen := map[*Agent]bool{agent: true}
+	for next := v.workflow.Agents[agent.Fallback]; next != nil; next = v.workflow.Agents[next.Fallback] {
+		if seen[next] {
+			v.result.AddFieldError(path, "fallback", "fallback agents cannot form a cycle")
+			return
+		}
+		seen[next] = true
+	}
+}
+
+// validateAgentEndpoint validates the endpoint of an agent using an
+// openai-compatible provider, other providers have no endpoint settings.
+func (v *Validator) validateAgentEndpoint(agent *Agent, path string) {
+	if agent.Provider != OpenAICompatibleProvider {
+		fields := []struct {
+			name string
+			set  bool
+		}{
+			{"base_url", agent.BaseURL != ""},
+			{"api_key_env", agent.APIKeyEnv != ""},
+			{"headers", len(agent.Headers) > 0},
+		}
+		for _, field := range fields {
+			if field.set {
+				v.result.AddFieldError(path, field.name, fmt.Sprintf("%s can only be used with the %s provider", field.name, OpenAICompatibleProvider))
+			}
+		}
+		return
+	}
+
+	if agent.Tier != "" {
+		v.result.AddFieldError(path, "tier", fmt.Sprintf("tier cannot be used with the %s provider, set a model instead", OpenAICompatibleProvider))
+	}
+
+	if agent.BaseURL == "" {
+		v.result.AddFieldError(path, "base_url", fmt.Sprintf("base_url is required with the %s provider", OpenAICompatibleProvider))
+	} else if u, err := url.Parse(agent.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.result.AddFieldError(path, "base_url", "base_url must be an absolute http or https URL")
+	}
+}
+
 // validateTools validates agent tools
 func (v *Validator) validateTools(tools []*Tool, path string) {
 	toolNames := make(map[string]bool)
@@ -281,6 +483,8 @@ func (v *Validator) validateTool(tool *Tool, path string) {
 	if tool.Uses != "" {
 		if err := isValidBlockReference(v.wd, tool.Uses); err != nil {
 			v.result.AddFieldError(path, "uses", err.Error())
+		} else if tool.IsOfficialTool() {
+			v.validateOfficialTool(tool, path)
 		}
 	}
 
@@ -297,6 +501,14 @@ func (v *Validator) validateTool(tool *Tool, path string) {
 	v.validateToolConfig(tool, path)
 }
 
+// validateOfficialTool checks that an official tool exists
+func (v *Validator) validateOfficialTool(tool *Tool, path string) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(tool.Uses, "lacquer/"), "@")
+	if !slices.Contains(ValidOfficialTools, name) {
+		v.result.AddFieldError(path, "uses", fmt.Sprintf("unknown official tool %s, must be one of: %s", tool.Uses, strings.Join(ValidOfficialTools, ", ")))
+	}
+}
+
 // validateScriptTool validates script-specific configuration
 func (v *Validator) validateScriptTool(tool *Tool, path string) {
 	if strings.HasPrefix(tool.Script, "./") || strings.HasPrefix(tool.Script, "/") {
@@ -409,6 +621,22 @@ func (v *Validator) validateWorkflowDef() {
 		return
 	}
 
+	if workflow.Timeout != nil && workflow.Timeout.Duration <= 0 {
+		v.result.AddFieldError(path, "timeout", "timeout must be greater than 0")
+	}
+
+	if budget := workflow.Budget; budget != nil {
+		if budget.Cost < 0 {
+			v.result.AddFieldError(path, "budget.cost", "cost must be at least 0")
+		}
+		if budget.Tokens < 0 {
+			v.result.AddFieldError(path, "budget.tokens", "tokens must be at least 0")
+		}
+		if budget.Cost == 0 && budget.Tokens == 0 {
+			v.result.AddFieldError(path, "budget", "budget must limit at least one of cost or tokens")
+		}
+	}
+
 	v.validateSteps()
 }
 
@@ -423,6 +651,10 @@ func (v *Validator) validateInputs(inputs map[string]*InputParam, path string) {
 
 		v.validateInputParam(param, paramPath)
 	}
+
+	if _, err := v.workflow.InputDefaultOrder(); err != nil {
+		v.result.AddError(path, err.Error())
+	}
 }
 
 // validateInputParam validates a single input parameter
@@ -450,6 +682,65 @@ func (v *Validator) validateInputParam(param *InputParam, path string) {
 	if param.MinItems != nil && param.MaxItems != nil && *param.MinItems > *param.MaxItems {
 		v.result.AddFieldError(path, "min_items", "min_items cannot be greater than max_items")
 	}
+
+	seen := make(map[string]bool, len(param.Enum))
+	for i, option := range param.Enum {
+		if option.Value == "" {
+			v.result.AddFieldError(path, fmt.Sprintf("enum[%d]", i), "enum value cannot be empty")
+		} else if seen[option.Value] {
+			v.result.AddFieldError(path, fmt.Sprintf("enum[%d]", i), fmt.Sprintf("duplicate enum value: %s", option.Value))
+		}
+		seen[option.Value] = true
+	}
+
+	if param.Multiple {
+		if len(param.Enum) == 0 {
+			v.result.AddFieldError(path, "multiple", "multiple can only be used with enum inputs")
+		}
+
+		if param.Type != "" && param.Type != "array" {
+			v.result.AddFieldError(path, "type", "inputs accepting multiple values must be of type array")
+		}
+	}
+}
+
+// validateOutputs validates the declared outputs against the outputs set by
+// the workflow
+func (v *Validator) validateOutputs() {
+	names := make([]string, 0, len(v.workflow.Outputs))
+	for name := range v.workflow.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		output := v.workflow.Outputs[name]
+		outputPath := fmt.Sprintf("outputs.%s", name)
+
+		if !isValidIdentifier(name) {
+			v.result.AddError(outputPath, "output name must be a valid identifier")
+		}
+
+		if output != nil && output.Type != "" && !contains(ValidOutputTypes, output.Type) {
+			v.result.AddFieldError(outputPath, "type", fmt.Sprintf("invalid type: %s", output.Type))
+		}
+
+		if _, ok := v.workflow.Workflow.Outputs[name]; !ok {
+			v.result.AddError(outputPath, fmt.Sprintf("declared output %s is not set in workflow.outputs", name))
+		}
+	}
+
+	set := make([]string, 0, len(v.workflow.Workflow.Outputs))
+	for name := range v.workflow.Workflow.Outputs {
+		set = append(set, name)
+	}
+	sort.Strings(set)
+
+	for _, name := range set {
+		if _, ok := v.workflow.Outputs[name]; !ok {
+			v.result.AddError(fmt.Sprintf("workflow.outputs.%s", name), fmt.Sprintf("output %s is not declared in outputs", name))
+		}
+	}
 }
 
 // validateSteps validates all workflow steps
@@ -502,6 +793,63 @@ func (v *Validator) validateStep(step *Step, path string) {
 		stepTypes["while"] = true
 	}
 
+	// a for_each step runs either sub steps or an agent prompt per item
+	if step.ForEach != "" && step.Agent == "" {
+		stepTypes["for_each"] = true
+	}
+
+	if step.Export != nil {
+		stepTypes["export"] = true
+	}
+
+	if step.Ingest != nil {
+		stepTypes["ingest"] = true
+	}
+
+	if step.Extract != nil {
+		stepTypes["extract"] = true
+	}
+
+	if step.Classify != nil {
+		stepTypes["classify"] = true
+	}
+	if step.Summarize != nil {
+		stepTypes["summarize"] = true
+	}
+	if step.Translate != nil {
+		stepTypes["translate"] = true
+	}
+	if step.Diff != nil {
+		stepTypes["diff"] = true
+	}
+	if step.PII != nil {
+		stepTypes["pii"] = true
+	}
+	if step.Race != nil {
+		stepTypes["race"] = true
+	}
+	if step.Ensemble != nil {
+		stepTypes["ensemble"] = true
+	}
+	if step.Parallel != nil {
+		stepTypes["parallel"] = true
+	}
+	if step.KV != nil {
+		stepTypes["kv"] = true
+	}
+	if step.Dedupe != nil {
+		stepTypes["dedupe"] = true
+	}
+	if step.HTTP != nil {
+		stepTypes["http"] = true
+	}
+	if step.Issue != nil {
+		stepTypes["issue"] = true
+	}
+	if step.Assert != nil {
+		stepTypes["assert"] = true
+	}
+
 	if len(stepTypes) == 0 {
 		v.result.AddError(path, fmt.Sprintf("step must specify either %s", ListToReadable(ValidStepTypes)))
 	} else if len(stepTypes) > 1 {
@@ -518,9 +866,49 @@ func (v *Validator) validateStep(step *Step, path string) {
 		v.validateAgentStep(path, step)
 	}
 
+	if step.Repair != nil {
+		if step.Agent == "" || len(step.Outputs) == 0 {
+			v.result.AddFieldError(path, "repair", "repair can only be used by agent steps with outputs")
+		} else if *step.Repair < 0 {
+			v.result.AddFieldError(path, "repair", "repair must be a positive number of attempts")
+		}
+	}
+
+	if step.Compress != nil {
+		v.validatePromptCompression(path, step)
+	}
+
+	if step.ForEach != "" {
+		v.validateForEachStep(path, step)
+	} else if step.MaxParallel != nil {
+		v.result.AddFieldError(path, "max_parallel", "max_parallel can only be used by for_each steps")
+	}
+
+	if step.Budget != nil {
+		v.validateStepBudget(path, step)
+	}
+
+	if step.Retry != nil {
+		v.validateStepRetry(path, step.Retry)
+	}
+
+	if step.Timeout != nil && step.Timeout.Duration <= 0 {
+		v.result.AddFieldError(path, "timeout", "timeout must be greater than 0")
+	}
+
+	if len(step.OnFailure) > 0 {
+		v.validateOnFailure(path, step.OnFailure)
+	}
+
+	if step.Session != "" {
+		v.validateStepSession(path, step)
+	}
+
 	if step.Uses != "" {
 		if err := isValidBlockReference(v.wd, step.Uses); err != nil {
 			v.result.AddFieldError(path, "uses", err.Error())
+		} else {
+			v.validateBlockInputs(path, step)
 		}
 	}
 
@@ -540,67 +928,887 @@ func (v *Validator) validateStep(step *Step, path string) {
 		}
 	}
 
+	if step.Export != nil {
+		v.validateExportStep(path, step.Export)
+	}
+
+	if step.Ingest != nil {
+		v.validateIngestStep(path, step.Ingest)
+	}
+
+	if step.Extract != nil {
+		v.validateExtractStep(path, step)
+	}
+
+	if step.Classify != nil {
+		v.validateClassifyStep(path, step.Classify)
+	}
+
+	if step.Summarize != nil {
+		v.validateSummarizeStep(path, step.Summarize)
+	}
+
+	if step.Translate != nil {
+		v.validateTranslateStep(path, step.Translate)
+	}
+
+	if step.Diff != nil {
+		v.validateDiffStep(path, step.Diff)
+	}
+
+	if step.PII != nil {
+		v.validatePIIStep(path, step.PII)
+	}
+
+	if step.Race != nil {
+		v.validateRaceStep(path, step.Race)
+	}
+
+	if step.Ensemble != nil {
+		v.validateEnsembleStep(path, step.Ensemble)
+	}
+
+	if step.Parallel != nil {
+		v.validateParallelStep(path, step.Parallel)
+	}
+
+	if step.KV != nil {
+		v.validateKVStep(path, step.KV)
+	}
+
+	if step.Dedupe != nil {
+		v.validateDedupeStep(path, step.Dedupe)
+	}
+
+	if step.HTTP != nil {
+		v.validateHTTPStep(path, step.HTTP)
+	}
+
+	if step.Issue != nil {
+		v.validateIssueStep(path, step.Issue)
+	}
+
+	if step.Assert != nil {
+		v.validateAssertStep(path, step.Assert)
+	}
+
+	if step.Resources != nil {
+		v.validateResources(path, step)
+	}
+
 	if step.While != "" {
 		v.validateWhileStep(path, step)
 	}
 }
 
-func (v *Validator) validateWhileStep(path string, step *Step) {
-	if step.While == "" {
-		v.result.AddFieldError(path, "while", "while step must have a condition")
-		return
+func (v *Validator) validateExportStep(path string, export *ExportStep) {
+	if strings.TrimSpace(export.From) == "" {
+		v.result.AddFieldError(path, "export.from", "export step must specify the data to export in from")
 	}
 
-	if len(step.Steps) == 0 {
-		v.result.AddFieldError(path, "steps", "while step must have sub-steps")
+	if strings.TrimSpace(export.Path) == "" {
+		v.result.AddFieldError(path, "export.path", "export step must specify a path")
 		return
 	}
 
-	stepIDs := make(map[string]bool)
-	for i, subStep := range step.Steps {
-		subStepPath := fmt.Sprintf("%s.steps[%d]", path, i)
-		v.validateStep(subStep, subStepPath)
-		if stepIDs[subStep.ID] {
-			v.result.AddError(subStepPath, fmt.Sprintf("duplicate step ID: %s", subStep.ID))
+	if export.Format != "" {
+		if !slices.Contains(ValidExportFormats, export.Format) {
+			v.result.AddFieldError(path, "export.format", fmt.Sprintf("format must be one of: %s", strings.Join(ValidExportFormats, ", ")))
 		}
-		stepIDs[subStep.ID] = true
+		return
+	}
+
+	if strings.Contains(export.Path, "${{") {
+		return
+	}
+
+	if ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(export.Path)), "."); !slices.Contains(ValidExportFormats, ext) {
+		v.result.AddFieldError(path, "export.path", fmt.Sprintf("cannot determine the format of %s, set format to one of: %s", export.Path, strings.Join(ValidExportFormats, ", ")))
 	}
 }
 
-func (v *Validator) validateAgentStep(path string, step *Step) {
-	valid := true
+func (v *Validator) validateExtractStep(path string, step *Step) {
+	extract := step.Extract
 
-	if step.Agent == "" {
-		v.result.AddFieldError(path, "agent", "agent is required when prompt is specified")
-		valid = false
+	if strings.TrimSpace(extract.From) == "" {
+		v.result.AddFieldError(path, "extract.from", "extract step must specify the text to extract from in from")
 	}
 
-	if step.Prompt == "" {
-		v.result.AddFieldError(path, "prompt", "prompt is required when agent is specified")
-		valid = false
+	if len(step.Outputs) == 0 {
+		v.result.AddFieldError(path, "outputs", "extract step must declare the fields to extract in outputs")
 	}
 
-	if !valid {
+	if extract.Retries != nil && *extract.Retries < 0 {
+		v.result.AddFieldError(path, "extract.retries", "retries must be at least 0")
+	}
+
+	if extract.Agent == "" {
+		v.result.AddFieldError(path, "extract.agent", "extract step must specify an agent")
 		return
 	}
 
-	if v.workflow.Agents == nil {
-		v.result.AddFieldError(path, "agent", fmt.Sprintf("please define an agents section with valid configuration for agent %s", step.Agent))
+	if _, ok := v.workflow.Agents[extract.Agent]; !ok {
+		v.result.AddFieldError(path, "extract.agent", fmt.Sprintf("agent %q must exist in the agents section", extract.Agent))
+	}
+}
+
+func (v *Validator) validateSummarizeStep(path string, summarize *SummarizeStep) {
+	if strings.TrimSpace(summarize.From) == "" {
+		v.result.AddFieldError(path, "summarize.from", "summarize step must specify the text to summarize in from")
 	}
 
-	if _, ok := v.workflow.Agents[step.Agent]; !ok {
-		v.result.AddFieldError(path, "agent", fmt.Sprintf("agent %q must exist in the agents section", step.Agent))
+	if summarize.Format != "" && !slices.Contains(ValidSummaryFormats, summarize.Format) {
+		v.result.AddFieldError(path, "summarize.format", fmt.Sprintf("format must be one of: %s", strings.Join(ValidSummaryFormats, ", ")))
+	}
+
+	if summarize.Length < 0 {
+		v.result.AddFieldError(path, "summarize.length", "length must be a positive number of words")
+	}
+
+	if summarize.ChunkSize < 0 {
+		v.result.AddFieldError(path, "summarize.chunk_size", "chunk_size must be a positive number of characters")
+	}
+
+	if summarize.Agent == "" {
+		v.result.AddFieldError(path, "summarize.agent", "summarize step must specify an agent")
+		return
+	}
+
+	if _, ok := v.workflow.Agents[summarize.Agent]; !ok {
+		v.result.AddFieldError(path, "summarize.agent", fmt.Sprintf("agent %q must exist in the agents section", summarize.Agent))
+	}
+}
+
+func (v *Validator) validateTranslateStep(path string, translate *TranslateStep) {
+	if strings.TrimSpace(translate.From) == "" {
+		v.result.AddFieldError(path, "translate.from", "translate step must specify the text to translate in from")
+	}
+
+	if strings.TrimSpace(translate.To) == "" {
+		v.result.AddFieldError(path, "translate.to", "translate step must specify the target language in to")
+	}
+
+	if translate.Retries != nil && *translate.Retries < 0 {
+		v.result.AddFieldError(path, "translate.retries", "retries must be at least 0")
+	}
+
+	switch glossary := translate.Glossary.(type) {
+	case nil:
+	case string:
+		if !strings.Contains(glossary, "${{") {
+			v.result.AddFieldError(path, "translate.glossary", "glossary must be a map of terms to translations or an expression evaluating to one")
+		}
+	case map[string]interface{}:
+		terms := make([]string, 0, len(glossary))
+		for term := range glossary {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+
+		for _, term := range terms {
+			if _, ok := glossary[term].(string); !ok {
+				v.result.AddFieldError(path, "translate.glossary."+term, fmt.Sprintf("the translation of %s must be a string", term))
+			}
+		}
+	default:
+		v.result.AddFieldError(path, "translate.glossary", "glossary must be a map of terms to translations or an expression evaluating to one")
+	}
+
+	if translate.Agent == "" {
+		v.result.AddFieldError(path, "translate.agent", "translate step must specify an agent")
+		return
+	}
+
+	if _, ok := v.workflow.Agents[translate.Agent]; !ok {
+		v.result.AddFieldError(path, "translate.agent", fmt.Sprintf("agent %q must exist in the agents section", translate.Agent))
 	}
 }
 
+func (v *Validator) validateDiffStep(path string, diff *DiffStep) {
+	if strings.TrimSpace(diff.From) == "" {
+		v.result.AddFieldError(path, "diff.from", "diff step must specify the original value in from")
+	}
+
+	if strings.TrimSpace(diff.To) == "" {
+		v.result.AddFieldError(path, "diff.to", "diff step must specify the new value in to")
+	}
+
+	if diff.Format != "" && !slices.Contains(ValidDiffFormats, diff.Format) {
+		v.result.AddFieldError(path, "diff.format", fmt.Sprintf("format must be one of: %s", strings.Join(ValidDiffFormats, ", ")))
+	}
+
+	if diff.Context != nil && *diff.Context < 0 {
+		v.result.AddFieldError(path, "diff.context", "context must be at least 0")
+	}
+}
+
+func (v *Validator) validatePIIStep(path string, pii *PIIStep) {
+	if strings.TrimSpace(pii.From) == "" {
+		v.result.AddFieldError(path, "pii.from", "pii step must specify the text to mask in from")
+	}
+
+	for i, t := range pii.Types {
+		if !slices.Contains(ValidPIITypes, t) {
+			v.result.AddFieldError(path, fmt.Sprintf("pii.types[%d]", i), fmt.Sprintf("type must be one of: %s", strings.Join(ValidPIITypes, ", ")))
+		}
+	}
+
+	if pii.Mode != "" && !slices.Contains(ValidPIIModes, pii.Mode) {
+		v.result.AddFieldError(path, "pii.mode", fmt.Sprintf("mode must be one of: %s", strings.Join(ValidPIIModes, ", ")))
+	}
+}
+
+func (v *Validator) validateKVStep(path string, kv *KVStep) {
+	if strings.TrimSpace(kv.Key) == "" {
+		v.result.AddFieldError(path, "kv.key", "kv step must specify a key")
+	}
+
+	switch kv.Action {
+	case "", KVActionGet:
+		if kv.Value != nil {
+			v.result.AddFieldError(path, "kv.value", "value can only be used by the set action")
+		}
+	case KVActionSet:
+		if kv.Value == nil {
+			v.result.AddFieldError(path, "kv.value", "set action must specify a value")
+		}
+	case KVActionDelete:
+		if kv.Value != nil {
+			v.result.AddFieldError(path, "kv.value", "value can only be used by the set action")
+		}
+	default:
+		v.result.AddFieldError(path, "kv.action", fmt.Sprintf("action must be one of: %s", strings.Join(ValidKVActions, ", ")))
+	}
+
+	if kv.Default != nil && kv.Action != "" && kv.Action != KVActionGet {
+		v.result.AddFieldError(path, "kv.default", "default can only be used by the get action")
+	}
+}
+
+func (v *Validator) validateDedupeStep(path string, dedupe *DedupeStep) {
+	if strings.TrimSpace(dedupe.Key) == "" {
+		v.result.AddFieldError(path, "dedupe.key", "dedupe step must specify a key")
+	}
+
+	if dedupe.TTL != nil && dedupe.TTL.Duration <= 0 {
+		v.result.AddFieldError(path, "dedupe.ttl", "ttl must be greater than 0")
+	}
+}
+
+func (v *Validator) validateAssertStep(path string, assertions []Assertion) {
+	if len(assertions) == 0 {
+		v.result.AddFieldError(path, "assert", "assert step must specify at least one assertion")
+	}
+
+	for i, assertion := range assertions {
+		field := fmt.Sprintf("assert[%d].that", i)
+		switch {
+		case strings.TrimSpace(assertion.That) == "":
+			v.result.AddFieldError(path, field, "assertion must specify an expression")
+		case !strings.Contains(assertion.That, "${{"):
+			v.result.AddFieldError(path, field, fmt.Sprintf("assertion %q must be an expression such as ${{ steps.id.output == \"value\" }}", assertion.That))
+		}
+	}
+}
+
+// ValidHTTPMethods lists the methods of an http step
+var ValidHTTPMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions}
+
+func (v *Validator) validateHTTPStep(path string, spec *HTTPStep) {
+	if spec.Method != "" && !slices.Contains(ValidHTTPMethods, strings.ToUpper(spec.Method)) {
+		v.result.AddFieldError(path, "http.method", fmt.Sprintf("method must be one of: %s", ListToReadable(ValidHTTPMethods)))
+	}
+
+	if strings.TrimSpace(spec.URL) == "" {
+		v.result.AddFieldError(path, "http.url", "http step must specify a url")
+	} else if !strings.Contains(spec.URL, "${{") {
+		if u, err := url.Parse(spec.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.result.AddFieldError(path, "http.url", "url must be an absolute http or https url")
+		}
+	}
+
+	if spec.Auth != nil {
+		hasToken := spec.Auth.Token != ""
+		hasBasic := spec.Auth.Username != "" || spec.Auth.Password != ""
+		switch {
+		case hasToken && hasBasic:
+			v.result.AddFieldError(path, "http.auth", "auth must specify either a token or a username and password, not both")
+		case !hasToken && !hasBasic:
+			v.result.AddFieldError(path, "http.auth", "auth must specify either a token or a username and password")
+		case hasBasic && spec.Auth.Username == "":
+			v.result.AddFieldError(path, "http.auth.username", "basic auth must specify a username")
+		}
+
+		for name := range spec.Headers {
+			if strings.EqualFold(name, "Authorization") {
+				v.result.AddFieldError(path, "http.headers", "the Authorization header can't be set along with auth")
+			}
+		}
+	}
+}
+
+func (v *Validator) validateIssueStep(path string, spec *IssueStep) {
+	if !slices.Contains(ValidIssueTrackers, spec.Tracker) {
+		v.result.AddFieldError(path, "issue.tracker", fmt.Sprintf("tracker must be one of: %s", strings.Join(ValidIssueTrackers, ", ")))
+	}
+
+	if strings.TrimSpace(spec.Token) == "" {
+		v.result.AddFieldError(path, "issue.token", "issue step must specify a token, e.g. ${{ env.JIRA_TOKEN }}")
+	}
+
+	if spec.URL != "" && !strings.Contains(spec.URL, "${{") {
+		if u, err := url.Parse(spec.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.result.AddFieldError(path, "issue.url", "url must be an absolute http or https url")
+		}
+	}
+
+	switch spec.Tracker {
+	case "jira":
+		if strings.TrimSpace(spec.URL) == "" {
+			v.result.AddFieldError(path, "issue.url", "jira issue steps must specify the url of the jira site")
+		}
+	case "linear":
+		if spec.Email != "" {
+			v.result.AddFieldError(path, "issue.email", "email can only be used with jira")
+		}
+		if spec.Type != "" {
+			v.result.AddFieldError(path, "issue.type", "type can only be used with jira")
+		}
+	}
+
+	switch spec.Action {
+	case "", IssueActionCreate:
+		if strings.TrimSpace(spec.Project) == "" {
+			v.result.AddFieldError(path, "issue.project", "create must specify the project, a jira project key or a linear team id")
+		}
+		if strings.TrimSpace(spec.Title) == "" {
+			v.result.AddFieldError(path, "issue.title", "create must specify the title of the issue")
+		}
+		if spec.ID != "" {
+			v.result.AddFieldError(path, "issue.id", "id can only be used by the update action")
+		}
+	case IssueActionUpdate:
+		if strings.TrimSpace(spec.ID) == "" {
+			v.result.AddFieldError(path, "issue.id", "update must specify the id of the issue")
+		}
+		if spec.Key != "" {
+			v.result.AddFieldError(path, "issue.key", "key can only be used by the create action")
+		}
+	default:
+		v.result.AddFieldError(path, "issue.action", fmt.Sprintf("action must be one of: %s", strings.Join(ValidIssueActions, ", ")))
+	}
+}
+
+// maxClassifySamples limits how many times a classify step samples the agent
+const maxClassifySamples = 10
+
+func (v *Validator) validateClassifyStep(path string, classify *ClassifyStep) {
+	if strings.TrimSpace(classify.From) == "" {
+		v.result.AddFieldError(path, "classify.from", "classify step must specify the text to classify in from")
+	}
+
+	if classify.Agent == "" {
+		v.result.AddFieldError(path, "classify.agent", "classify step must specify an agent")
+	} else if _, ok := v.workflow.Agents[classify.Agent]; !ok {
+		v.result.AddFieldError(path, "classify.agent", fmt.Sprintf("agent %q must exist in the agents section", classify.Agent))
+	}
+
+	labels := make(map[string]bool, len(classify.Labels))
+	for i, label := range classify.Labels {
+		field := fmt.Sprintf("classify.labels[%d]", i)
+		if strings.TrimSpace(label.Value) == "" {
+			v.result.AddFieldError(path, field, "label value cannot be empty")
+			continue
+		}
+		if labels[label.Value] {
+			v.result.AddFieldError(path, field, fmt.Sprintf("duplicate label %s", label.Value))
+		}
+		labels[label.Value] = true
+	}
+
+	if len(classify.Labels) == 0 {
+		v.result.AddFieldError(path, "classify.labels", "classify step must specify at least one label")
+	}
+
+	if classify.Samples < 0 || classify.Samples > maxClassifySamples {
+		v.result.AddFieldError(path, "classify.samples", fmt.Sprintf("samples must be between 1 and %d", maxClassifySamples))
+	}
+
+	if classify.MinConfidence < 0 || classify.MinConfidence > 1 {
+		v.result.AddFieldError(path, "classify.min_confidence", "min_confidence must be between 0 and 1")
+	}
+
+	if classify.MinConfidence > 0 && classify.Fallback == "" {
+		v.result.AddFieldError(path, "classify.fallback", "fallback label is required when min_confidence is set")
+	}
+
+	if classify.Fallback != "" && classify.MinConfidence == 0 {
+		v.result.AddFieldError(path, "classify.min_confidence", "min_confidence is required when a fallback label is set")
+	}
+
+	if classify.Retries != nil && *classify.Retries < 0 {
+		v.result.AddFieldError(path, "classify.retries", "retries must be at least 0")
+	}
+
+	v.validateClassifyExamples(path, classify.Examples, labels)
+}
+
+// validateClassifyExamples checks examples written in the workflow, examples
+// from expressions are checked when the step runs.
+func (v *Validator) validateClassifyExamples(path string, examples interface{}, labels map[string]bool) {
+	switch examples := examples.(type) {
+	case nil:
+	case string:
+		if !strings.Contains(examples, "${{") {
+			v.result.AddFieldError(path, "classify.examples", "examples must be a list of objects with text and label, or an expression")
+		}
+	case []interface{}:
+		for i, example := range examples {
+			field := fmt.Sprintf("classify.examples[%d]", i)
+
+			item, ok := example.(map[string]interface{})
+			if !ok {
+				v.result.AddFieldError(path, field, "example must be an object with text and label")
+				continue
+			}
+
+			if text, _ := item["text"].(string); text == "" {
+				v.result.AddFieldError(path, field, "example must specify the text")
+			}
+
+			label, _ := item["label"].(string)
+			if label == "" {
+				v.result.AddFieldError(path, field, "example must specify the label")
+			} else if len(labels) > 0 && !labels[label] && !strings.Contains(label, "${{") {
+				v.result.AddFieldError(path, field, fmt.Sprintf("example label %s is not one of the step's labels", label))
+			}
+		}
+	default:
+		v.result.AddFieldError(path, "classify.examples", "examples must be a list of objects with text and label, or an expression")
+	}
+}
+
+// defaultIngestChunkSize mirrors ingest.DefaultChunkSize
+const defaultIngestChunkSize = 2000
+
+func (v *Validator) validateIngestStep(path string, ingest *IngestStep) {
+	if ingest.ChunkSize < 0 {
+		v.result.AddFieldError(path, "ingest.chunk_size", "chunk_size must be greater than 0")
+	}
+
+	if ingest.ChunkOverlap != nil {
+		size := ingest.ChunkSize
+		if size == 0 {
+			size = defaultIngestChunkSize
+		}
+		if *ingest.ChunkOverlap < 0 || *ingest.ChunkOverlap >= size {
+			v.result.AddFieldError(path, "ingest.chunk_overlap", fmt.Sprintf("chunk_overlap must be at least 0 and less than the chunk size of %d", size))
+		}
+	}
+
+	if strings.TrimSpace(ingest.From) == "" {
+		v.result.AddFieldError(path, "ingest.from", "ingest step must specify the document to ingest in from")
+		return
+	}
+
+	if ingest.Format != "" {
+		if !slices.Contains(ValidIngestFormats, ingest.Format) {
+			v.result.AddFieldError(path, "ingest.format", fmt.Sprintf("format must be one of: %s", strings.Join(ValidIngestFormats, ", ")))
+		}
+		return
+	}
+
+	if strings.Contains(ingest.From, "${{") {
+		return
+	}
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(ingest.From)), ".")
+	if ext == "htm" {
+		ext = "html"
+	}
+	if !slices.Contains(ValidIngestFormats, ext) {
+		v.result.AddFieldError(path, "ingest.from", fmt.Sprintf("cannot determine the format of %s, set format to one of: %s", ingest.From, strings.Join(ValidIngestFormats, ", ")))
+	}
+}
+
+func (v *Validator) validateResources(path string, step *Step) {
+	if step.Container == "" && step.Run == "" {
+		v.result.AddFieldError(path, "resources", "resources can only be used with container or run steps")
+		return
+	}
+
+	if gpus := step.Resources.GPUs; gpus != "" && gpus != "all" {
+		if count, err := strconv.Atoi(gpus); err != nil || count < 1 {
+			v.result.AddFieldError(path, "resources.gpus", "gpus must be a positive number or \"all\"")
+		}
+	}
+
+	if len(step.Resources.Devices) > 0 && step.Container == "" {
+		v.result.AddFieldError(path, "resources.devices", "devices can only be used with container steps")
+		return
+	}
+
+	for i, device := range step.Resources.Devices {
+		host, _, _ := strings.Cut(device, ":")
+		if !strings.HasPrefix(host, "/dev/") {
+			v.result.AddFieldError(path, fmt.Sprintf("resources.devices[%d]", i), fmt.Sprintf("device %s must be a path in /dev", device))
+		}
+	}
+}
+
+func (v *Validator) validateWhileStep(path string, step *Step) {
+	if step.While == "" {
+		v.result.AddFieldError(path, "while", "while step must have a condition")
+		return
+	}
+
+	if len(step.Steps) == 0 {
+		v.result.AddFieldError(path, "steps", "while step must have sub-steps")
+		return
+	}
+
+	stepIDs := make(map[string]bool)
+	for i, subStep := range step.Steps {
+		subStepPath := fmt.Sprintf("%s.steps[%d]", path, i)
+		v.validateStep(subStep, subStepPath)
+		if stepIDs[subStep.ID] {
+			v.result.AddError(subStepPath, fmt.Sprintf("duplicate step ID: %s", subStep.ID))
+		}
+		stepIDs[subStep.ID] = true
+	}
+}
+
+func (v *Validator) validateForEachStep(path string, step *Step) {
+	if !strings.Contains(step.ForEach, "${{") {
+		v.result.AddFieldError(path, "for_each", "for_each must be an expression evaluating to a list, e.g. ${{ steps.fetch.outputs.items }}")
+	}
+
+	if step.MaxParallel != nil && *step.MaxParallel < 1 {
+		v.result.AddFieldError(path, "max_parallel", "max_parallel must be at least 1")
+	}
+
+	if step.Agent != "" {
+		if len(step.Steps) > 0 {
+			v.result.AddFieldError(path, "steps", "for_each step can run either sub-steps or an agent prompt, not both")
+		}
+		return
+	}
+
+	if len(step.Steps) == 0 {
+		v.result.AddFieldError(path, "steps", "for_each step must have sub-steps or an agent prompt")
+		return
+	}
+
+	stepIDs := make(map[string]bool)
+	for i, subStep := range step.Steps {
+		subStepPath := fmt.Sprintf("%s.steps[%d]", path, i)
+		v.validateStep(subStep, subStepPath)
+		if stepIDs[subStep.ID] {
+			v.result.AddError(subStepPath, fmt.Sprintf("duplicate step ID: %s", subStep.ID))
+		}
+		stepIDs[subStep.ID] = true
+	}
+}
+
+func (v *Validator) validateRaceStep(path string, race *RaceStep) {
+	if len(race.Branches) < 2 {
+		v.result.AddFieldError(path, "race.branches", "race step must have at least 2 branches")
+	}
+
+	branchIDs := make(map[string]bool)
+	for i, branch := range race.Branches {
+		branchPath := fmt.Sprintf("%s.race.branches[%d]", path, i)
+
+		if branch.ID == "" {
+			v.result.AddFieldError(branchPath, "id", "branch ID is required")
+		} else if !isValidIdentifier(branch.ID) {
+			v.result.AddFieldError(branchPath, "id", "branch ID must be a valid identifier")
+		} else if branchIDs[branch.ID] {
+			v.result.AddFieldError(branchPath, "id", fmt.Sprintf("duplicate branch ID: %s", branch.ID))
+		}
+		branchIDs[branch.ID] = true
+
+		if len(branch.Steps) == 0 {
+			v.result.AddFieldError(branchPath, "steps", "branch must have steps")
+			continue
+		}
+
+		stepIDs := make(map[string]bool)
+		for j, subStep := range branch.Steps {
+			subStepPath := fmt.Sprintf("%s.steps[%d]", branchPath, j)
+			v.validateStep(subStep, subStepPath)
+			if stepIDs[subStep.ID] {
+				v.result.AddError(subStepPath, fmt.Sprintf("duplicate step ID: %s", subStep.ID))
+			}
+			stepIDs[subStep.ID] = true
+		}
+	}
+
+	if race.Best != "" && !strings.Contains(race.Best, "${{") {
+		v.result.AddFieldError(path, "race.best", "best must be an expression scoring a branch, e.g. ${{ steps.review.outputs.score }}")
+	}
+
+	if race.Timeout != nil && race.Timeout.Duration <= 0 {
+		v.result.AddFieldError(path, "race.timeout", "timeout must be greater than 0")
+	}
+}
+
+func (v *Validator) validateParallelStep(path string, parallel *ParallelStep) {
+	if len(parallel.Steps) == 0 {
+		v.result.AddFieldError(path, "parallel.steps", "parallel step must have steps")
+	}
+
+	if parallel.MaxConcurrency != nil && *parallel.MaxConcurrency < 1 {
+		v.result.AddFieldError(path, "parallel.max_concurrency", "max_concurrency must be at least 1")
+	}
+
+	stepIDs := make(map[string]bool)
+	for i, subStep := range parallel.Steps {
+		subStepPath := fmt.Sprintf("%s.parallel.steps[%d]", path, i)
+		v.validateStep(subStep, subStepPath)
+		if stepIDs[subStep.ID] {
+			v.result.AddError(subStepPath, fmt.Sprintf("duplicate step ID: %s", subStep.ID))
+		}
+		stepIDs[subStep.ID] = true
+	}
+}
+
+func (v *Validator) validateOnFailure(path string, handlers []*Step) {
+	stepIDs := make(map[string]bool)
+	for i, subStep := range handlers {
+		subStepPath := fmt.Sprintf("%s.on_failure[%d]", path, i)
+		v.validateStep(subStep, subStepPath)
+		if stepIDs[subStep.ID] {
+			v.result.AddError(subStepPath, fmt.Sprintf("duplicate step ID: %s", subStep.ID))
+		}
+		stepIDs[subStep.ID] = true
+	}
+}
+
+func (v *Validator) validateEnsembleStep(path string, ensemble *EnsembleStep) {
+	if len(ensemble.Agents) < 2 {
+		v.result.AddFieldError(path, "ensemble.agents", "ensemble step must have at least 2 agents")
+	}
+
+	for i, agent := range ensemble.Agents {
+		if _, ok := v.workflow.Agents[agent]; !ok {
+			v.result.AddFieldError(path, fmt.Sprintf("ensemble.agents[%d]", i), fmt.Sprintf("agent %q must exist in the agents section", agent))
+		}
+	}
+
+	if strings.TrimSpace(ensemble.Prompt) == "" {
+		v.result.AddFieldError(path, "ensemble.prompt", "ensemble step must specify a prompt")
+	}
+
+	if ensemble.Judge != "" && ensemble.Best != "" {
+		v.result.AddFieldError(path, "ensemble.best", "ensemble step can't have both a judge and best")
+	}
+
+	if ensemble.Judge != "" {
+		if _, ok := v.workflow.Agents[ensemble.Judge]; !ok {
+			v.result.AddFieldError(path, "ensemble.judge", fmt.Sprintf("agent %q must exist in the agents section", ensemble.Judge))
+		}
+	} else if ensemble.Instructions != "" {
+		v.result.AddFieldError(path, "ensemble.instructions", "instructions are only used by a judge")
+	}
+
+	if ensemble.Best != "" && !strings.Contains(ensemble.Best, "${{") {
+		v.result.AddFieldError(path, "ensemble.best", "best must be an expression scoring an answer, e.g. ${{ length(steps.answer.output) }}")
+	}
+
+	if ensemble.Retries != nil && *ensemble.Retries < 0 {
+		v.result.AddFieldError(path, "ensemble.retries", "retries must be at least 0")
+	}
+}
+
+// validateStepSession validates that the steps of a session are agent
+// steps using the same agent.
+func (v *Validator) validateStepSession(path string, step *Step) {
+	if step.Agent == "" {
+		v.result.AddFieldError(path, "session", "session can only be used by agent steps")
+		return
+	}
+
+	agent, ok := v.sessions[step.Session]
+	if !ok {
+		v.sessions[step.Session] = step.Agent
+		return
+	}
+
+	if agent != step.Agent {
+		v.result.AddFieldError(path, "session", fmt.Sprintf("steps of session %q must use the same agent, earlier steps use %q", step.Session, agent))
+	}
+}
+
+// validateStepBudget validates the budget of a while or ensemble step.
+func (v *Validator) validateStepBudget(path string, step *Step) {
+	budget := step.Budget
+	if !step.IsWhileStep() && !step.IsEnsembleStep() {
+		v.result.AddFieldError(path, "budget", "budget can only be used by while and ensemble steps")
+		return
+	}
+
+	if budget.Cost < 0 {
+		v.result.AddFieldError(path, "budget.cost", "cost must be at least 0")
+	}
+	if budget.Tokens < 0 {
+		v.result.AddFieldError(path, "budget.tokens", "tokens must be at least 0")
+	}
+	if budget.Duration != nil && budget.Duration.Duration < 0 {
+		v.result.AddFieldError(path, "budget.duration", "duration must be at least 0")
+	}
+	if budget.Cost == 0 && budget.Tokens == 0 && (budget.Duration == nil || budget.Duration.Duration == 0) {
+		v.result.AddFieldError(path, "budget", "budget must limit at least one of cost, tokens or duration")
+	}
+}
+
+// validatePromptCompression validates the prompt compression of an agent step.
+func (v *Validator) validatePromptCompression(path string, step *Step) {
+	compress := step.Compress
+	if step.Agent == "" || step.Prompt == "" {
+		v.result.AddFieldError(path, "compress", "compress can only be used by agent steps")
+		return
+	}
+
+	if compress.Summarizer != "" {
+		if _, ok := v.workflow.Agents[compress.Summarizer]; !ok {
+			v.result.AddFieldError(path, "compress.summarizer", fmt.Sprintf("agent %q must exist in the agents section", compress.Summarizer))
+		}
+	}
+	if compress.ContextWindow < 0 {
+		v.result.AddFieldError(path, "compress.context_window", "context_window must be at least 0")
+	}
+}
+
+// validateStepRetry validates the retry policy of a step.
+func (v *Validator) validateStepRetry(path string, retry *StepRetry) {
+	if retry.MaxAttempts < 1 {
+		v.result.AddFieldError(path, "retry.max_attempts", "max_attempts must be at least 1")
+	}
+	if retry.Delay != nil && retry.Delay.Duration < 0 {
+		v.result.AddFieldError(path, "retry.delay", "delay must be at least 0")
+	}
+	if retry.Backoff != "" && !slices.Contains(ValidRetryBackoffs, retry.Backoff) {
+		v.result.AddFieldError(path, "retry.backoff", fmt.Sprintf("backoff must be one of: %s", strings.Join(ValidRetryBackoffs, ", ")))
+	}
+}
+
+func (v *Validator) validateAgentStep(path string, step *Step) {
+	valid := true
+
+	if step.Agent == "" {
+		v.result.AddFieldError(path, "agent", "agent is required when prompt is specified")
+		valid = false
+	}
+
+	if step.Prompt == "" {
+		v.result.AddFieldError(path, "prompt", "prompt is required when agent is specified")
+		valid = false
+	}
+
+	if !valid {
+		return
+	}
+
+	if v.workflow.Agents == nil {
+		v.result.AddFieldError(path, "agent", fmt.Sprintf("please define an agents section with valid configuration for agent %s", step.Agent))
+	}
+
+	if _, ok := v.workflow.Agents[step.Agent]; !ok {
+		v.result.AddFieldError(path, "agent", fmt.Sprintf("agent %q must exist in the agents section", step.Agent))
+	}
+
+	v.validatePromptRef(path, step)
+}
+
+// validatePrompts validates the workflow's prompt library
+func (v *Validator) validatePrompts() {
+	names := make([]string, 0, len(v.workflow.Prompts))
+	for name := range v.workflow.Prompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prompt := v.workflow.Prompts[name]
+		path := "prompts." + name
+
+		if !isValidIdentifier(name) {
+			v.result.AddError(path, "prompt name must be a valid identifier")
+		}
+
+		if prompt == nil || len(prompt.Versions) == 0 {
+			v.result.AddFieldError(path, "versions", "prompt must have at least one version")
+			continue
+		}
+
+		versions := make([]string, 0, len(prompt.Versions))
+		for version := range prompt.Versions {
+			versions = append(versions, version)
+		}
+		sort.Strings(versions)
+
+		for _, version := range versions {
+			text := prompt.Versions[version]
+			if !promptVersionPattern.MatchString(version) {
+				v.result.AddFieldError(path, "versions."+version, "version must only contain letters, digits, '.', '-' and '_'")
+			}
+			if strings.TrimSpace(text) == "" {
+				v.result.AddFieldError(path, "versions."+version, "prompt text cannot be empty")
+			}
+		}
+	}
+}
+
+// validatePromptRef validates the reference of an agent step to a prompt of
+// the library. Prompts written like a reference are only treated as one
+// when the workflow has a prompt library.
+func (v *Validator) validatePromptRef(path string, step *Step) {
+	if len(v.workflow.Prompts) == 0 {
+		return
+	}
+
+	ref, ok, err := ParsePromptRef(step.Prompt)
+	if !ok {
+		return
+	}
+
+	prompt, exists := v.workflow.Prompts[ref.Name]
+	if !exists {
+		v.result.AddFieldError(path, "prompt", fmt.Sprintf("prompt %q must exist in the prompts section", ref.Name))
+		return
+	}
+	if err != nil {
+		v.result.AddFieldError(path, "prompt", err.Error())
+		return
+	}
+	if prompt == nil {
+		return
+	}
+
+	for _, variant := range ref.Variants {
+		if _, ok := prompt.Versions[variant.Version]; !ok {
+			v.result.AddFieldError(path, "prompt", fmt.Sprintf("prompt %q has no version %q", ref.Name, variant.Version))
+		}
+	}
+}
+
+// Patterns used by validation, compiled once rather than for every workflow validated
+var (
+	identifierPattern       = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	lacquerRefPattern       = regexp.MustCompile(`^lacquer/[a-z0-9-]+(@v[0-9]+(\.[0-9]+)*)?$`)
+	githubRefPattern        = regexp.MustCompile(`^github\.com/[^/]+/[^/]+(@[^/]+)?$`)
+	urlPattern              = regexp.MustCompile(`^https?://[^\s/$.?#].[^\s]*$`)
+	templateDurationPattern = regexp.MustCompile(`^[0-9]+[a-zA-Z]+$`)
+)
+
 // isValidIdentifier checks if a string is a valid identifier
 func isValidIdentifier(s string) bool {
 	if s == "" {
 		return false
 	}
 	// Must start with letter or underscore, followed by letters, digits, or underscores
-	matched, _ := regexp.MatchString(`^[a-zA-Z_][a-zA-Z0-9_]*$`, s)
-	return matched
+	return identifierPattern.MatchString(s)
 }
 
 // isValidBlockReference checks if a block reference is valid
@@ -610,8 +1818,7 @@ func isValidBlockReference(wd, ref string) error {
 	}
 
 	if strings.HasPrefix(ref, "lacquer/") {
-		matched, _ := regexp.MatchString(`^lacquer/[a-z0-9-]+(@v[0-9]+(\.[0-9]+)*)?$`, ref)
-		if matched {
+		if lacquerRefPattern.MatchString(ref) {
 			return nil
 		}
 
@@ -619,8 +1826,7 @@ func isValidBlockReference(wd, ref string) error {
 	}
 
 	if strings.HasPrefix(ref, "github.com/") {
-		matched, _ := regexp.MatchString(`^github\.com/[^/]+/[^/]+(@[^/]+)?$`, ref)
-		if matched {
+		if githubRefPattern.MatchString(ref) {
 			return nil
 		}
 
@@ -668,8 +1874,7 @@ func isValidURL(urlStr string) bool {
 		return false
 	}
 
-	matched, _ := regexp.MatchString(`^https?://[^\s/$.?#].[^\s]*$`, urlStr)
-	return matched
+	return urlPattern.MatchString(urlStr)
 }
 
 // isValidDuration checks if a duration string is valid
@@ -684,8 +1889,7 @@ func isValidDuration(duration string) bool {
 
 // parseTemplateDuration parses duration strings similar to time.ParseDuration
 func parseTemplateDuration(s string) (time.Duration, error) {
-	matched, _ := regexp.MatchString(`^[0-9]+[a-zA-Z]+$`, s)
-	if !matched {
+	if !templateDurationPattern.MatchString(s) {
 		return 0, fmt.Errorf("invalid duration format")
 	}
 
@@ -0,0 +1,282 @@
+// Package bench benchmarks model providers, sending a set of prompts to
+// models and measuring the latency, throughput, failures and cost of the
+// requests.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/routing"
+)
+
+// DefaultPrompt is the prompt benchmarked when none are given.
+const DefaultPrompt = "Explain in three sentences why the sky is blue."
+
+// maxErrors is how many distinct errors a result keeps.
+const maxErrors = 3
+
+// Target is a model of a provider to benchmark.
+type Target struct {
+	Provider string `json:"provider" yaml:"provider"`
+	Model    string `json:"model" yaml:"model"`
+}
+
+// ParseTarget parses a target written as provider/model, e.g.
+// anthropic/claude-sonnet-4.
+func ParseTarget(s string) (Target, error) {
+	name, model, ok := strings.Cut(s, "/")
+	if !ok || name == "" || model == "" {
+		return Target{}, fmt.Errorf("invalid target %q, must be provider/model such as anthropic/claude-sonnet-4", s)
+	}
+	return Target{Provider: name, Model: model}, nil
+}
+
+func (t Target) String() string {
+	return t.Provider + "/" + t.Model
+}
+
+// Options configure a benchmark.
+type Options struct {
+	// Prompts are sent to the model in turn, each Runs times
+	Prompts []string
+	Runs    int
+	// Concurrency is how many requests are sent at the same time
+	Concurrency int
+	// MaxTokens limits the completion of each request, unlimited when zero
+	MaxTokens int
+	// Timeout limits each request, unlimited when zero
+	Timeout time.Duration
+	// Router prices the requests, their cost is unknown when it's nil or
+	// the model isn't in its table
+	Router *routing.Router
+}
+
+// Latency are the percentiles of the latency of the successful requests.
+type Latency struct {
+	Min  time.Duration `json:"min" yaml:"min"`
+	P50  time.Duration `json:"p50" yaml:"p50"`
+	P90  time.Duration `json:"p90" yaml:"p90"`
+	P99  time.Duration `json:"p99" yaml:"p99"`
+	Max  time.Duration `json:"max" yaml:"max"`
+	Mean time.Duration `json:"mean" yaml:"mean"`
+}
+
+// Result is the outcome of benchmarking a target.
+type Result struct {
+	Target
+	// Resolved is the model the requests were sent to, when the target's
+	// model is an alias
+	Resolved string  `json:"resolved,omitempty" yaml:"resolved,omitempty"`
+	Requests int     `json:"requests" yaml:"requests"`
+	Failures int     `json:"failures" yaml:"failures"`
+	Latency  Latency `json:"latency" yaml:"latency"`
+	// TokensPerSecond is the completion tokens generated per second of a
+	// request, across the successful requests
+	TokensPerSecond float64 `json:"tokens_per_second" yaml:"tokens_per_second"`
+	// RequestsPerSecond is the successful requests completed per second of
+	// the benchmark, which grows with the concurrency until the provider's
+	// rate limits are reached
+	RequestsPerSecond float64 `json:"requests_per_second" yaml:"requests_per_second"`
+	PromptTokens      int     `json:"prompt_tokens" yaml:"prompt_tokens"`
+	CompletionTokens  int     `json:"completion_tokens" yaml:"completion_tokens"`
+	// Cost is the estimated cost in USD of the requests, nil when the
+	// pricing of the model is unknown
+	Cost     *float64      `json:"cost,omitempty" yaml:"cost,omitempty"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	// Errors are the first distinct errors of the failed requests
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// FailureRate is the fraction of the requests which failed.
+func (r *Result) FailureRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Failures) / float64(r.Requests)
+}
+
+// Failed returns the result of a target which couldn't be benchmarked at
+// all, e.g. because the provider's credentials are missing.
+func Failed(target Target, err error) *Result {
+	return &Result{Target: target, Errors: []string{err.Error()}}
+}
+
+type sample struct {
+	latency time.Duration
+	usage   int
+	prompt  int
+	err     error
+}
+
+// Run benchmarks the model of a provider, sending each prompt Runs times.
+// Failed requests are counted rather than stopping the benchmark.
+func Run(ctx context.Context, pr provider.Provider, target Target, model string, options Options) *Result {
+	prompts := options.Prompts
+	if len(prompts) == 0 {
+		prompts = []string{DefaultPrompt}
+	}
+	runs := max(options.Runs, 1)
+	concurrency := max(options.Concurrency, 1)
+
+	requests := make(chan string)
+	samples := make(chan sample)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for prompt := range requests {
+				samples <- send(ctx, pr, model, prompt, options)
+			}
+		}()
+	}
+
+	start := time.Now()
+	go func() {
+		defer close(requests)
+		for range runs {
+			for _, prompt := range prompts {
+				select {
+				case requests <- prompt:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	var collected []sample
+	for s := range samples {
+		collected = append(collected, s)
+	}
+
+	result := summarize(target, collected, time.Since(start))
+	if model != target.Model {
+		result.Resolved = model
+	}
+	result.Cost = cost(options.Router, target, model, result)
+
+	return result
+}
+
+func send(ctx context.Context, pr provider.Provider, model, prompt string, options Options) sample {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	request := &provider.Request{
+		Model: model,
+		Messages: []provider.Message{{
+			Role:    "user",
+			Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(prompt)},
+		}},
+	}
+	if options.MaxTokens > 0 {
+		request.MaxTokens = &options.MaxTokens
+	}
+
+	start := time.Now()
+	_, usage, err := pr.Generate(provider.GenerateContext{StepID: "bench", RunID: "bench", Context: ctx}, request, nil)
+	s := sample{latency: time.Since(start), err: err}
+	if err == nil && ctx.Err() != nil {
+		s.err = ctx.Err()
+	}
+	if usage != nil {
+		s.prompt = usage.PromptTokens
+		s.usage = usage.CompletionTokens
+	}
+
+	return s
+}
+
+// summarize adds up the samples of a target.
+func summarize(target Target, samples []sample, duration time.Duration) *Result {
+	result := &Result{Target: target, Requests: len(samples), Duration: duration}
+
+	var latencies []time.Duration
+	var busy time.Duration
+	seen := make(map[string]bool)
+	for _, s := range samples {
+		result.PromptTokens += s.prompt
+		result.CompletionTokens += s.usage
+
+		if s.err != nil {
+			result.Failures++
+			if message := s.err.Error(); !seen[message] && len(result.Errors) < maxErrors {
+				seen[message] = true
+				result.Errors = append(result.Errors, message)
+			}
+			continue
+		}
+
+		latencies = append(latencies, s.latency)
+		busy += s.latency
+	}
+
+	if len(latencies) == 0 {
+		return result
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.Latency = Latency{
+		Min:  latencies[0],
+		P50:  percentile(latencies, 50),
+		P90:  percentile(latencies, 90),
+		P99:  percentile(latencies, 99),
+		Max:  latencies[len(latencies)-1],
+		Mean: busy / time.Duration(len(latencies)),
+	}
+
+	var completed int
+	for _, s := range samples {
+		if s.err == nil {
+			completed += s.usage
+		}
+	}
+	if busy > 0 {
+		result.TokensPerSecond = float64(completed) / busy.Seconds()
+	}
+	if duration > 0 {
+		result.RequestsPerSecond = float64(len(latencies)) / duration.Seconds()
+	}
+
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// cost estimates the cost of the requests from the pricing of the model
+// they were sent to, or of the target's alias.
+func cost(router *routing.Router, target Target, model string, result *Result) *float64 {
+	if router == nil {
+		return nil
+	}
+
+	pricing, ok := router.Lookup(target.Provider, model)
+	if !ok {
+		pricing, ok = router.Lookup(target.Provider, target.Model)
+	}
+	if !ok {
+		return nil
+	}
+
+	cost := pricing.Cost(result.PromptTokens, result.CompletionTokens)
+	return &cost
+}
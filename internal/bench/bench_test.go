@@ -0,0 +1,122 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/routing"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyProvider fails every nth request and records the most requests it
+// served at the same time.
+type flakyProvider struct {
+	*provider.MockProvider
+	failEvery int
+	delay     time.Duration
+	calls     atomic.Int32
+	active    atomic.Int32
+	peak      atomic.Int32
+}
+
+func (p *flakyProvider) Generate(gtx provider.GenerateContext, request *provider.Request, progressChan chan<- pkgEvents.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
+	active := p.active.Add(1)
+	defer p.active.Add(-1)
+	for peak := p.peak.Load(); active > peak && !p.peak.CompareAndSwap(peak, active); peak = p.peak.Load() {
+	}
+
+	select {
+	case <-time.After(p.delay):
+	case <-gtx.Context.Done():
+		return nil, nil, gtx.Context.Err()
+	}
+
+	if call := p.calls.Add(1); p.failEvery > 0 && int(call)%p.failEvery == 0 {
+		return nil, nil, errors.New("rate limit exceeded")
+	}
+	return p.MockProvider.Generate(gtx, request, progressChan)
+}
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("anthropic/claude-sonnet-4")
+	require.NoError(t, err)
+	assert.Equal(t, Target{Provider: "anthropic", Model: "claude-sonnet-4"}, target)
+	assert.Equal(t, "anthropic/claude-sonnet-4", target.String())
+
+	for _, invalid := range []string{"claude-sonnet-4", "anthropic/", "/gpt-4o"} {
+		_, err := ParseTarget(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRun(t *testing.T) {
+	mock := provider.NewMockProvider("openai", nil)
+	mock.SetResponse("first", "one")
+	mock.SetResponse("second", "two")
+	pr := &flakyProvider{MockProvider: mock, failEvery: 4, delay: 10 * time.Millisecond}
+
+	router := routing.NewRouter([]routing.Model{{Provider: "openai", Model: "gpt-4o-mini", InputPrice: 1, OutputPrice: 2}})
+	result := Run(context.Background(), pr, Target{Provider: "openai", Model: "gpt-4o-mini"}, "gpt-4o-mini", Options{
+		Prompts:     []string{"first", "second"},
+		Runs:        4,
+		Concurrency: 2,
+		Router:      router,
+	})
+
+	assert.Equal(t, 8, result.Requests)
+	assert.Equal(t, 2, result.Failures)
+	assert.Equal(t, 0.25, result.FailureRate())
+	assert.Equal(t, []string{"rate limit exceeded"}, result.Errors)
+	assert.Equal(t, int32(2), pr.peak.Load())
+
+	assert.Equal(t, 60, result.PromptTokens)
+	assert.Equal(t, 120, result.CompletionTokens)
+	require.NotNil(t, result.Cost)
+	assert.InDelta(t, (60*1.0+120*2.0)/1_000_000, *result.Cost, 1e-12)
+
+	assert.GreaterOrEqual(t, result.Latency.Min, 10*time.Millisecond)
+	assert.LessOrEqual(t, result.Latency.P50, result.Latency.P90)
+	assert.LessOrEqual(t, result.Latency.P90, result.Latency.P99)
+	assert.LessOrEqual(t, result.Latency.P99, result.Latency.Max)
+	assert.Greater(t, result.TokensPerSecond, 0.0)
+	assert.Greater(t, result.RequestsPerSecond, 0.0)
+	assert.Empty(t, result.Resolved)
+}
+
+func TestRun_Timeout(t *testing.T) {
+	pr := &flakyProvider{MockProvider: provider.NewMockProvider("anthropic", nil), delay: time.Second}
+
+	result := Run(context.Background(), pr, Target{Provider: "anthropic", Model: "claude-sonnet-4"}, "claude-sonnet-4-20250514", Options{
+		Runs:    2,
+		Timeout: 10 * time.Millisecond,
+		Router:  routing.NewRouter(routing.DefaultModels()),
+	})
+
+	assert.Equal(t, 2, result.Requests)
+	assert.Equal(t, 2, result.Failures)
+	assert.Equal(t, 1.0, result.FailureRate())
+	assert.Equal(t, []string{context.DeadlineExceeded.Error()}, result.Errors)
+	assert.Zero(t, result.Latency)
+	assert.Equal(t, "claude-sonnet-4-20250514", result.Resolved)
+	require.NotNil(t, result.Cost)
+	assert.Zero(t, *result.Cost)
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 10; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 5*time.Millisecond, percentile(latencies, 50))
+	assert.Equal(t, 9*time.Millisecond, percentile(latencies, 90))
+	assert.Equal(t, 10*time.Millisecond, percentile(latencies, 99))
+	assert.Equal(t, time.Millisecond, percentile(latencies[:1], 50))
+}
@@ -0,0 +1,210 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// containerWorkspace is where the workspace is mounted inside containers
+	// run by container steps.
+	containerWorkspace = "/workspace"
+
+	// EnvInContainer overrides the detection of whether laq is itself running
+	// in a container, set it to true or false.
+	EnvInContainer = "LACQUER_IN_CONTAINER"
+
+	// EnvHostWorkspace is the path on the docker host of the directory
+	// mounted at EnvWorkspace, used when laq runs in a container and the
+	// mounts of that container can't be inspected, e.g. in Kubernetes.
+	EnvHostWorkspace = "LACQUER_HOST_WORKSPACE"
+
+	// EnvWorkspace is the path the workspace is mounted at inside the laq
+	// container, defaults to /workspace.
+	EnvWorkspace = "LACQUER_WORKSPACE"
+)
+
+// dockerMount is a bind mount of the container laq is running in.
+type dockerMount struct {
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+}
+
+// dockerEnvironment describes how the docker daemon used by container steps
+// relates to laq. When laq itself runs in a container and talks to the
+// daemon over a mounted socket, bind mounts are resolved by the daemon on the
+// host so workspace paths have to be translated to their host paths.
+type dockerEnvironment struct {
+	inContainer bool
+	mounts      []dockerMount
+
+	// env is added to the environment of docker commands, it selects the
+	// rootless daemon socket when that's the only daemon available.
+	env []string
+}
+
+// detectDockerEnvironment inspects the environment laq is running in.
+func detectDockerEnvironment(ctx context.Context) *dockerEnvironment {
+	env := &dockerEnvironment{
+		inContainer: detectContainer("/", os.Getenv),
+		env:         rootlessDockerHost(os.Getenv, fileExists),
+	}
+
+	if !env.inContainer {
+		return env
+	}
+
+	env.mounts = env.inspectOwnMounts(ctx)
+	log.Debug().
+		Int("mounts", len(env.mounts)).
+		Msg("Running in a container, translating container step workspace mounts to host paths")
+
+	return env
+}
+
+// detectContainer reports whether the process is running in a container,
+// looking for the marker files of docker and podman, a kubernetes service
+// environment and the cgroup of the init process.
+func detectContainer(root string, getenv func(string) string) bool {
+	if value := getenv(EnvInContainer); value != "" {
+		inContainer, err := strconv.ParseBool(value)
+		if err == nil {
+			return inContainer
+		}
+	}
+
+	if getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+
+	for _, marker := range []string{".dockerenv", "run/.containerenv"} {
+		if fileExists(filepath.Join(root, marker)) {
+			return true
+		}
+	}
+
+	cgroup, err := os.ReadFile(filepath.Join(root, "proc/1/cgroup")) // #nosec G304 - fixed path
+	if err != nil {
+		return false
+	}
+
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "libpod"} {
+		if bytes.Contains(cgroup, []byte(runtime)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rootlessDockerHost returns the DOCKER_HOST environment for a rootless
+// daemon when no daemon socket is configured and only the rootless socket of
+// the current user exists.
+func rootlessDockerHost(getenv func(string) string, exists func(string) bool) []string {
+	if getenv("DOCKER_HOST") != "" || exists("/var/run/docker.sock") {
+		return nil
+	}
+
+	runtimeDir := getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		return nil
+	}
+
+	socket := filepath.Join(runtimeDir, "docker.sock")
+	if !exists(socket) {
+		return nil
+	}
+
+	return []string{"DOCKER_HOST=unix://" + socket}
+}
+
+// inspectOwnMounts returns the bind mounts of the container laq is running
+// in. Docker uses the container id as the hostname so the container can
+// inspect itself when the daemon socket is mounted.
+func (e *dockerEnvironment) inspectOwnMounts(ctx context.Context) []dockerMount {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	cmd := e.command(ctx, "inspect", "--format", "{{json .Mounts}}", hostname)
+	output, err := cmd.Output()
+	if err != nil {
+		log.Debug().Err(err).Msg("Unable to inspect the mounts of the laq container")
+		return nil
+	}
+
+	var mounts []dockerMount
+	if err := json.Unmarshal(output, &mounts); err != nil {
+		return nil
+	}
+
+	return mounts
+}
+
+// hostPath translates a path in the laq container to the path the docker
+// daemon sees. Paths which aren't inside a mount are returned unchanged.
+func (e *dockerEnvironment) hostPath(path string, getenv func(string) string) string {
+	if !e.inContainer {
+		return path
+	}
+
+	mounts := e.mounts
+	if hostWorkspace := getenv(EnvHostWorkspace); hostWorkspace != "" {
+		workspace := getenv(EnvWorkspace)
+		if workspace == "" {
+			workspace = containerWorkspace
+		}
+		mounts = append([]dockerMount{{Source: hostWorkspace, Destination: workspace}}, mounts...)
+	}
+
+	// the most specific mount wins when mounts are nested
+	sorted := make([]dockerMount, len(mounts))
+	copy(sorted, mounts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Destination) > len(sorted[j].Destination)
+	})
+
+	for _, mount := range sorted {
+		rel, err := filepath.Rel(mount.Destination, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+
+		return filepath.Join(mount.Source, rel)
+	}
+
+	log.Warn().
+		Str("path", path).
+		Msgf("Running in a container but %s is not a mounted path, set %s to the host path of the workspace", path, EnvHostWorkspace)
+
+	return path
+}
+
+// command creates a docker command using the detected daemon.
+func (e *dockerEnvironment) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...) // #nosec G204 - args are controlled by the executor
+	if len(e.env) > 0 {
+		cmd.Env = append(os.Environ(), e.env...)
+	}
+
+	return cmd
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,109 @@
+package block
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func envFunc(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestDetectContainer(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		env    map[string]string
+		expect bool
+	}{
+		{name: "host", expect: false},
+		{name: "docker marker", files: map[string]string{".dockerenv": ""}, expect: true},
+		{name: "podman marker", files: map[string]string{"run/.containerenv": ""}, expect: true},
+		{name: "kubernetes", env: map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1"}, expect: true},
+		{name: "cgroup", files: map[string]string{"proc/1/cgroup": "0::/system.slice/docker-abc.scope"}, expect: true},
+		{name: "host cgroup", files: map[string]string{"proc/1/cgroup": "0::/init.scope"}, expect: false},
+		{name: "override", files: map[string]string{".dockerenv": ""}, env: map[string]string{EnvInContainer: "false"}, expect: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := detectContainer(root, envFunc(tt.env)); got != tt.expect {
+				t.Errorf("detectContainer() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRootlessDockerHost(t *testing.T) {
+	exists := func(paths ...string) func(string) bool {
+		return func(path string) bool {
+			for _, p := range paths {
+				if p == path {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	env := envFunc(map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000"})
+
+	got := rootlessDockerHost(env, exists("/run/user/1000/docker.sock"))
+	if len(got) != 1 || got[0] != "DOCKER_HOST=unix:///run/user/1000/docker.sock" {
+		t.Errorf("expected rootless docker host, got %v", got)
+	}
+
+	if got := rootlessDockerHost(env, exists("/var/run/docker.sock", "/run/user/1000/docker.sock")); got != nil {
+		t.Errorf("expected the default socket to be used, got %v", got)
+	}
+
+	withHost := envFunc(map[string]string{"XDG_RUNTIME_DIR": "/run/user/1000", "DOCKER_HOST": "tcp://docker:2375"})
+	if got := rootlessDockerHost(withHost, exists("/run/user/1000/docker.sock")); got != nil {
+		t.Errorf("expected DOCKER_HOST to be respected, got %v", got)
+	}
+}
+
+func TestDockerEnvironment_HostPath(t *testing.T) {
+	env := &dockerEnvironment{
+		inContainer: true,
+		mounts: []dockerMount{
+			{Source: "/home/runner/work", Destination: "/workspace"},
+			{Source: "/home/runner/cache", Destination: "/workspace/.cache"},
+		},
+	}
+	noEnv := envFunc(nil)
+
+	tests := map[string]string{
+		"/workspace":               "/home/runner/work",
+		"/workspace/project":       "/home/runner/work/project",
+		"/workspace/.cache/models": "/home/runner/cache/models",
+		"/tmp/unmounted":           "/tmp/unmounted",
+		"/workspace-other/project": "/workspace-other/project",
+	}
+	for path, expect := range tests {
+		if got := env.hostPath(path, noEnv); got != expect {
+			t.Errorf("hostPath(%q) = %q, want %q", path, got, expect)
+		}
+	}
+
+	explicit := envFunc(map[string]string{EnvHostWorkspace: "/builds/repo", EnvWorkspace: "/src"})
+	if got := (&dockerEnvironment{inContainer: true}).hostPath("/src/flows", explicit); got != "/builds/repo/flows" {
+		t.Errorf("expected the configured host workspace to be used, got %q", got)
+	}
+
+	if got := (&dockerEnvironment{}).hostPath("/workspace/project", explicit); got != "/workspace/project" {
+		t.Errorf("expected paths to be unchanged outside a container, got %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package block
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -9,10 +10,17 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lacquerai/lacquer/internal/execcontext"
 )
 
+// processWaitDelay is how long a cancelled script's output is waited for
+// once it has been killed.
+const processWaitDelay = 5 * time.Second
+
 // BashExecutor executes Bash script blocks
 type BashExecutor struct {
 	cacheDir string
@@ -60,7 +68,21 @@ func (e *BashExecutor) ExecuteRaw(execCtx *execcontext.ExecutionContext, block *
 	execInput.Env["LOG_LEVEL"] = os.Getenv("LOG_LEVEL")
 	execInput.Env["LACQUER_INPUTS"] = string(inputJSON)
 
+	if block.GPUs != "" {
+		available, err := availableGPUs(execCtx.Context.Context)
+		if err != nil {
+			return nil, err
+		}
+
+		devices, err := visibleGPUs(block.GPUs, available)
+		if err != nil {
+			return nil, err
+		}
+		execInput.Env["CUDA_VISIBLE_DEVICES"] = devices
+	}
+
 	cmd := exec.CommandContext(execCtx.Context.Context, "bash", scriptPath) // #nosec G204 - scriptPath is controlled internally
+	killProcessGroup(cmd)
 
 	jsonInput, err := json.Marshal(execInput)
 	if err != nil {
@@ -79,30 +101,23 @@ func (e *BashExecutor) ExecuteRaw(execCtx *execcontext.ExecutionContext, block *
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
 	}
 
-	done := make(chan error)
-	go func() {
-		done <- cmd.Run()
-	}()
+	if err := cmd.Run(); err != nil {
+		// the script was killed because the step timed out or the run was
+		// cancelled, its output is incomplete
+		if ctxErr := execCtx.Context.Context.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("block execution cancelled: %w", ctxErr)
+		}
 
-	select {
-	case err := <-done:
-		if err != nil {
-			// Check if there's an error in stderr
-			if stderr.Len() > 0 {
-				var execErr ExecutionError
-				if jsonErr := json.Unmarshal(stderr.Bytes(), &execErr); jsonErr == nil {
-					return nil, fmt.Errorf("%s failed: %s", block.Script, execErr.Message)
-				}
-				return nil, fmt.Errorf("%s failed: %s", block.Script, stderr.String())
+		// Check if there's an error in stderr
+		if stderr.Len() > 0 {
+			var execErr ExecutionError
+			if jsonErr := json.Unmarshal(stderr.Bytes(), &execErr); jsonErr == nil {
+				return nil, fmt.Errorf("%s failed: %s", block.Script, execErr.Message)
 			}
-
-			return nil, fmt.Errorf("%s failed: %w: %s", block.Script, err, stdout.String())
+			return nil, fmt.Errorf("%s failed: %s", block.Script, stderr.String())
 		}
-	case <-execCtx.Context.Context.Done():
-		if cmd.Process != nil {
-			_ = cmd.Process.Kill()
-		}
-		return nil, fmt.Errorf("block execution timeout")
+
+		return nil, fmt.Errorf("%s failed: %w: %s", block.Script, err, stdout.String())
 	}
 
 	var output map[string]interface{}
@@ -142,3 +157,55 @@ func (e *BashExecutor) getOrPrepare(block *Block) (string, error) {
 
 	return scriptPath, nil
 }
+
+// availableGPUs returns the devices of the GPUs a script may use, those in
+// CUDA_VISIBLE_DEVICES when laq was limited to some GPUs itself, otherwise
+// the indexes of the NVIDIA GPUs on the host.
+func availableGPUs(ctx context.Context) ([]string, error) {
+	if devices := parentGPUs(); devices != nil {
+		return devices, nil
+	}
+
+	output, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=index", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("step requests GPUs but none were found, nvidia-smi failed: %w", err)
+	}
+
+	return strings.Fields(string(output)), nil
+}
+
+// parentGPUs returns the devices in the CUDA_VISIBLE_DEVICES of laq, or nil
+// when it isn't set.
+func parentGPUs() []string {
+	value, ok := os.LookupEnv("CUDA_VISIBLE_DEVICES")
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	devices := []string{}
+	for _, device := range strings.Split(value, ",") {
+		if device = strings.TrimSpace(device); device != "" {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// visibleGPUs returns the CUDA_VISIBLE_DEVICES value which makes the
+// requested number of the available GPUs available to a script.
+func visibleGPUs(gpus string, available []string) (string, error) {
+	requested := len(available)
+	if gpus != "all" {
+		count, err := strconv.Atoi(gpus)
+		if err != nil || count < 1 {
+			return "", fmt.Errorf("invalid gpus %q, must be a positive number or \"all\"", gpus)
+		}
+		requested = count
+	}
+
+	if requested == 0 || requested > len(available) {
+		return "", fmt.Errorf("step requests %s GPUs but only %d are available", gpus, len(available))
+	}
+
+	return strings.Join(available[:requested], ","), nil
+}
@@ -3,7 +3,9 @@ package block
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/lacquerai/lacquer/internal/execcontext"
 )
@@ -79,3 +81,85 @@ echo "{\"sum\": $sum}"
 		t.Errorf("Expected sum to be 8.0, got %v", sum)
 	}
 }
+
+func TestBashExecutor_Cancelled(t *testing.T) {
+	executor, err := NewBashExecutor(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create Bash executor: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	execCtx := &execcontext.ExecutionContext{
+		RunID:   "test-run",
+		Cwd:     t.TempDir(),
+		Context: execcontext.RunContext{Context: ctx},
+	}
+
+	// the sleep started by the script holds its output open until it's
+	// killed along with the script
+	start := time.Now()
+	_, err = executor.Execute(execCtx, &Block{
+		Name:    "test-sleep-block",
+		Runtime: RuntimeBash,
+		Script:  "sleep 10\necho done\n",
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Expected the block to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Expected the block to stop promptly, it took %s", elapsed)
+	}
+}
+
+func TestVisibleGPUs(t *testing.T) {
+	tests := []struct {
+		gpus      string
+		available []string
+		expect    string
+		wantErr   bool
+	}{
+		{gpus: "1", available: []string{"0", "1"}, expect: "0"},
+		{gpus: "2", available: []string{"0", "1"}, expect: "0,1"},
+		{gpus: "all", available: []string{"0", "1", "2"}, expect: "0,1,2"},
+		{gpus: "1", available: []string{"2", "3"}, expect: "2"},
+		{gpus: "all", available: []string{"GPU-8b1f", "GPU-3c2a"}, expect: "GPU-8b1f,GPU-3c2a"},
+		{gpus: "3", available: []string{"0", "1"}, wantErr: true},
+		{gpus: "all", available: nil, wantErr: true},
+		{gpus: "0", available: []string{"0", "1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := visibleGPUs(tt.gpus, tt.available)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("visibleGPUs(%q, %v) expected an error", tt.gpus, tt.available)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("visibleGPUs(%q, %v) unexpected error: %v", tt.gpus, tt.available, err)
+		} else if got != tt.expect {
+			t.Errorf("visibleGPUs(%q, %v) = %q, want %q", tt.gpus, tt.available, got, tt.expect)
+		}
+	}
+}
+
+func TestAvailableGPUs_ParentVisibleDevices(t *testing.T) {
+	t.Setenv("CUDA_VISIBLE_DEVICES", "2, 3")
+
+	available, err := availableGPUs(context.Background())
+	if err != nil {
+		t.Fatalf("availableGPUs() unexpected error: %v", err)
+	}
+	if strings.Join(available, ",") != "2,3" {
+		t.Errorf("availableGPUs() = %v, want [2 3]", available)
+	}
+
+	devices, err := visibleGPUs("1", available)
+	if err != nil || devices != "2" {
+		t.Errorf("visibleGPUs(\"1\", %v) = %q, %v, want \"2\"", available, devices, err)
+	}
+}
@@ -3,22 +3,36 @@ package block
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/rs/zerolog/log"
 )
 
+// containerRemoveTimeout limits how long removing the container of a
+// cancelled step may take.
+const containerRemoveTimeout = 30 * time.Second
+
 // DockerExecutor executes Docker blocks
 type DockerExecutor struct {
 	pullTimeout  time.Duration
 	buildTimeout time.Duration
+
+	envOnce sync.Once
+	env     *dockerEnvironment
+
+	gpuOnce sync.Once
+	gpuErr  error
 }
 
 // NewDockerExecutor creates a new Docker block executor
@@ -67,6 +81,7 @@ func (e *DockerExecutor) Execute(execCtx *execcontext.ExecutionContext, block *B
 			return nil, fmt.Errorf("failed to pull image: %w", err)
 		}
 	}
+	block.ImageDigest = e.imageDigest(execCtx.Context.Context, imageName)
 
 	execInput := ExecutionInput{
 		Inputs: inputs,
@@ -82,7 +97,32 @@ func (e *DockerExecutor) Execute(execCtx *execcontext.ExecutionContext, block *B
 		return nil, fmt.Errorf("failed to marshal input: %w", err)
 	}
 
-	args := []string{"run", "--rm"}
+	// the container is named so that it can be removed when the run is
+	// cancelled, killing the docker client doesn't stop it
+	containerName, err := newContainerName()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"run", "--rm", "--name", containerName}
+	if execCtx.Cwd != "" {
+		workspace, err := filepath.Abs(execCtx.Cwd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve workspace: %w", err)
+		}
+
+		hostWorkspace := e.environment(execCtx.Context.Context).hostPath(workspace, os.Getenv)
+		args = append(args, "-v", fmt.Sprintf("%s:%s", hostWorkspace, containerWorkspace), "-w", containerWorkspace)
+	}
+	if block.GPUs != "" {
+		if err := e.checkGPUSupport(execCtx.Context.Context); err != nil {
+			return nil, err
+		}
+		args = append(args, "--gpus", block.GPUs)
+	}
+	for _, device := range block.Devices {
+		args = append(args, "--device", device)
+	}
 	args = append(args, "-e", fmt.Sprintf("LACQUER_INPUTS=%s", string(inputJSON)))
 	for key, value := range execInput.Env {
 		args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
@@ -94,7 +134,7 @@ func (e *DockerExecutor) Execute(execCtx *execcontext.ExecutionContext, block *B
 		args = append(args, block.Command...)
 	}
 
-	cmd := exec.CommandContext(execCtx.Context.Context, "docker", args...)
+	cmd := e.environment(execCtx.Context.Context).command(execCtx.Context.Context, args...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -102,6 +142,11 @@ func (e *DockerExecutor) Execute(execCtx *execcontext.ExecutionContext, block *B
 
 	err = cmd.Run()
 	if err != nil {
+		if ctxErr := execCtx.Context.Context.Err(); ctxErr != nil {
+			e.removeContainer(execCtx.Context.Context, containerName)
+			return nil, fmt.Errorf("container execution cancelled: %w", ctxErr)
+		}
+
 		// Check for error in stderr
 		if stderr.Len() > 0 {
 			var execErr ExecutionError
@@ -205,7 +250,7 @@ func (e *DockerExecutor) buildImageFromLocal(execCtx *execcontext.ExecutionConte
 	}
 
 	args := []string{"build", "-t", imageName, "-f", relDockerfilePath, buildContext}
-	cmd := exec.CommandContext(buildCtx, "docker", args...) // #nosec G204 - args are controlled and validated
+	cmd := e.environment(buildCtx).command(buildCtx, args...)
 	cmd.Dir = buildContext
 
 	var stderr bytes.Buffer
@@ -236,20 +281,44 @@ func (e *DockerExecutor) generateImageName(dockerfilePath string) (string, error
 
 // imageExists checks if a Docker image exists locally
 func (e *DockerExecutor) imageExists(ctx context.Context, imageName string) bool {
-	cmd := exec.CommandContext(ctx, "docker", "image", "inspect", imageName)
+	cmd := e.environment(ctx).command(ctx, "image", "inspect", imageName)
 	return cmd.Run() == nil
 }
 
 func (e *DockerExecutor) checkDockerAvailable() error {
-	cmd := exec.Command("docker", "version", "--format", "{{.Server.Version}}")
+	cmd := e.environment(context.Background()).command(context.Background(), "version", "--format", "{{.Server.Version}}")
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("docker daemon not available or not running")
 	}
 	return nil
 }
 
+// checkGPUSupport checks that the docker daemon can provide GPUs to
+// containers, i.e. the NVIDIA Container Toolkit is installed.
+func (e *DockerExecutor) checkGPUSupport(ctx context.Context) error {
+	e.gpuOnce.Do(func() {
+		env := e.environment(ctx)
+		output, err := env.command(ctx, "info", "--format", "{{json .Runtimes}}").Output()
+		if err == nil && bytes.Contains(output, []byte("nvidia")) {
+			return
+		}
+
+		// the toolkit can provide --gpus without registering a runtime, it is
+		// only visible on the host though
+		if !env.inContainer {
+			if _, err := exec.LookPath("nvidia-container-cli"); err == nil {
+				return
+			}
+		}
+
+		e.gpuErr = fmt.Errorf("step requests GPUs but the docker daemon has no GPU support, install the NVIDIA Container Toolkit on the docker host")
+	})
+
+	return e.gpuErr
+}
+
 func (e *DockerExecutor) pullImageIfNeeded(ctx context.Context, image string) error {
-	cmd := exec.CommandContext(ctx, "docker", "image", "inspect", image)
+	cmd := e.environment(ctx).command(ctx, "image", "inspect", image)
 	if err := cmd.Run(); err == nil {
 		return nil
 	}
@@ -257,7 +326,7 @@ func (e *DockerExecutor) pullImageIfNeeded(ctx context.Context, image string) er
 	pullCtx, cancel := context.WithTimeout(ctx, e.pullTimeout)
 	defer cancel()
 
-	cmd = exec.CommandContext(pullCtx, "docker", "pull", image)
+	cmd = e.environment(pullCtx).command(pullCtx, "pull", image)
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -268,3 +337,49 @@ func (e *DockerExecutor) pullImageIfNeeded(ctx context.Context, image string) er
 
 	return nil
 }
+
+// imageDigest returns the digest of a local image, the digest of its
+// repository when it was pulled or its ID when it was built, empty when it
+// can't be inspected.
+func (e *DockerExecutor) imageDigest(ctx context.Context, image string) string {
+	cmd := e.environment(ctx).command(ctx, "image", "inspect", "--format", "{{if .RepoDigests}}{{index .RepoDigests 0}}{{else}}{{.Id}}{{end}}", image)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		log.Debug().Err(err).Str("image", image).Msg("Failed to inspect the digest of image")
+		return ""
+	}
+
+	return strings.TrimSpace(stdout.String())
+}
+
+// removeContainer force removes a container left running by a cancelled
+// step. ctx is already cancelled, the removal gets a context of its own.
+func (e *DockerExecutor) removeContainer(ctx context.Context, name string) {
+	removeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), containerRemoveTimeout)
+	defer cancel()
+
+	if err := e.environment(removeCtx).command(removeCtx, "rm", "--force", name).Run(); err != nil {
+		log.Warn().Err(err).Str("container", name).Msg("Failed to remove container of cancelled step")
+	}
+}
+
+// newContainerName returns a unique name for the container of a step.
+func newContainerName() (string, error) {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate container name: %w", err)
+	}
+
+	return "lacquer-" + hex.EncodeToString(suffix), nil
+}
+
+// environment returns the docker environment, detecting it on first use.
+func (e *DockerExecutor) environment(ctx context.Context) *dockerEnvironment {
+	e.envOnce.Do(func() {
+		e.env = detectDockerEnvironment(ctx)
+	})
+
+	return e.env
+}
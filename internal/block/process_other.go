@@ -0,0 +1,12 @@
+//go:build !unix
+
+package block
+
+import "os/exec"
+
+// killProcessGroup only kills the command itself when its context is
+// cancelled, its output is closed after a short delay in case processes it
+// started still hold it open.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = processWaitDelay
+}
@@ -33,10 +33,13 @@ type Block struct {
 	Image    string            `yaml:"image,omitempty"`    // For docker blocks
 	Command  []string          `yaml:"command,omitempty"`  // For docker blocks
 	Env      map[string]string `yaml:"env,omitempty"`      // For docker blocks
+	GPUs     string            `yaml:"gpus,omitempty"`     // For docker and bash blocks
+	Devices  []string          `yaml:"devices,omitempty"`  // For docker blocks
 
 	// Cached data
 	ModTime      time.Time `yaml:"-"`
 	CompiledPath string    `yaml:"-"` // For go blocks
+	ImageDigest  string    `yaml:"-"` // For docker blocks, the digest of the image it ran
 }
 
 // InputSchema defines the schema for a block input parameter
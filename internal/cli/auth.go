@@ -0,0 +1,415 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/keychain"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/provider/anthropic"
+	"github.com/lacquerai/lacquer/internal/provider/openai"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// keychainSet and keychainDelete change the OS keychain (can be mocked for
+// testing)
+var (
+	keychainSet    = keychain.Set
+	keychainDelete = keychain.Delete
+)
+
+// authBreakers are the circuit breakers of providers reported by auth status
+// (can be mocked for testing)
+var authBreakers = provider.DefaultBreakers
+
+// authCmd represents the auth command
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage provider API keys",
+	Long: `Manage the API keys of providers stored in the OS keychain: the macOS Keychain,
+the Windows Credential Manager or the Secret Service (libsecret) on Linux.
+
+API keys in environment variables, such as ANTHROPIC_API_KEY, take precedence
+over those in the keychain.`,
+}
+
+// authLoginCmd represents the auth login command
+var authLoginCmd = &cobra.Command{
+	Use:   "login <provider>",
+	Short: "Store the API key of a provider in the OS keychain",
+	Long: `Store the API key of a provider in the OS keychain, so it doesn't need to be
+kept in environment variables or configuration files.
+
+The key is prompted for, or read from stdin when it isn't a terminal.`,
+	Example: `
+  laq auth login anthropic                    # Prompt for the Anthropic API key
+  echo "$OPENAI_API_KEY" | laq auth login openai # Read the OpenAI API key from stdin`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: provider.APIKeyProviders,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := authLogin(cmd.InOrStdin(), cmd.OutOrStdout(), args[0]); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// authLogoutCmd represents the auth logout command
+var authLogoutCmd = &cobra.Command{
+	Use:       "logout <provider>",
+	Short:     "Remove the API key of a provider from the OS keychain",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: provider.APIKeyProviders,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := authLogout(cmd.OutOrStdout(), args[0]); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// authStatusCmd represents the auth status command
+var authStatusCmd = &cobra.Command{
+	Use:   "status [workflow.laq.yaml]",
+	Short: "Check the API keys of providers",
+	Long: `Check the API key of each provider with a minimal authenticated request, listing
+its models, and report which providers are usable and where their keys were
+found. Keys are masked.
+
+Given a workflow, the providers of its agents are checked with the agents'
+configuration and the command fails when any of them isn't usable, so
+missing keys are found before running the workflow.
+
+Providers whose circuit breaker is open, after repeated failed requests,
+are reported along with when requests to them are retried.`,
+	Example: `
+  laq auth status                    # Check every provider
+  laq auth status workflow.laq.yaml  # Check the providers of a workflow`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file := ""
+		if len(args) > 0 {
+			file = args[0]
+		}
+
+		if err := authStatus(cmd.Context(), cmd.OutOrStdout(), file); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	authCmd.AddCommand(authLoginCmd, authLogoutCmd, authStatusCmd)
+	rootCmd.AddCommand(authCmd)
+}
+
+func authLogin(in io.Reader, w io.Writer, name string) error {
+	if err := validateAPIKeyProvider(name); err != nil {
+		return err
+	}
+
+	key, err := readAPIKey(in, w, name)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("no API key was given")
+	}
+
+	if err := keychainSet(name, key); err != nil {
+		return err
+	}
+
+	style.Success(w, fmt.Sprintf("Stored the %s API key in the keychain", name))
+	return nil
+}
+
+func authLogout(w io.Writer, name string) error {
+	if err := validateAPIKeyProvider(name); err != nil {
+		return err
+	}
+
+	err := keychainDelete(name)
+	if errors.Is(err, keychain.ErrNotFound) {
+		style.Info(w, fmt.Sprintf("No %s API key is stored in the keychain", name))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	style.Success(w, fmt.Sprintf("Removed the %s API key from the keychain", name))
+	return nil
+}
+
+func validateAPIKeyProvider(name string) error {
+	if !slices.Contains(provider.APIKeyProviders, name) {
+		return fmt.Errorf("unknown provider %q, must be one of %s", name, strings.Join(provider.APIKeyProviders, ", "))
+	}
+	return nil
+}
+
+// readAPIKey prompts for the API key without echoing it when in is a
+// terminal, and otherwise reads it from in.
+func readAPIKey(in io.Reader, w io.Writer, name string) (string, error) {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprintf(w, "Paste your %s API key: ", name)
+		key, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(w)
+		if err != nil {
+			return "", fmt.Errorf("failed to read API key: %w", err)
+		}
+		return strings.TrimSpace(string(key)), nil
+	}
+
+	key, err := io.ReadAll(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key: %w", err)
+	}
+	return strings.TrimSpace(string(key)), nil
+}
+
+const (
+	credentialUsable  = "ok"
+	credentialInvalid = "invalid"
+	credentialMissing = "missing"
+)
+
+// credentialCheckTimeout limits how long the request checking a provider's
+// API key may take.
+const credentialCheckTimeout = 15 * time.Second
+
+// ProviderCredential is the result of checking the API key of a provider.
+type ProviderCredential struct {
+	Provider string `json:"provider" yaml:"provider"`
+	Required bool   `json:"required,omitempty" yaml:"required,omitempty"`
+	Source   string `json:"source,omitempty" yaml:"source,omitempty"`
+	Key      string `json:"key,omitempty" yaml:"key,omitempty"`
+	Status   string `json:"status" yaml:"status"`
+	Models   int    `json:"models,omitempty" yaml:"models,omitempty"`
+	Error    string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Circuit is the state of the provider's circuit breaker when it isn't
+	// closed, or has counted failures.
+	Circuit *provider.BreakerStatus `json:"circuit,omitempty" yaml:"circuit,omitempty"`
+}
+
+// newCredentialProvider creates the provider used to check an API key (can
+// be mocked for testing)
+var newCredentialProvider = func(name string, config map[string]interface{}) (provider.Provider, error) {
+	switch name {
+	case "anthropic":
+		return anthropic.NewProvider(config)
+	case "openai":
+		return openai.NewProvider(config)
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+}
+
+// apiKeyEnvVars lists the environment variables each provider reads its API
+// key from.
+var apiKeyEnvVars = map[string][]string{
+	"anthropic": anthropic.APIKeyEnvVars,
+	"openai":    openai.APIKeyEnvVars,
+}
+
+func authStatus(ctx context.Context, w io.Writer, file string) error {
+	configs := make(map[string]map[string]interface{})
+	required := make(map[string]bool)
+	if file != "" {
+		yamlParser, err := parser.NewYAMLParser()
+		if err != nil {
+			return fmt.Errorf("failed to create parser: %w", err)
+		}
+
+		workflow, err := yamlParser.ParseFile(file)
+		if err != nil {
+			return err
+		}
+
+		for _, agent := range workflow.Agents {
+			if slices.Contains(provider.APIKeyProviders, agent.Provider) {
+				configs[agent.Provider] = agent.Config
+				required[agent.Provider] = true
+			}
+		}
+	}
+
+	credentials := make([]*ProviderCredential, 0, len(provider.APIKeyProviders))
+	var unusable []string
+	for _, name := range provider.APIKeyProviders {
+		credential := checkProviderCredential(ctx, name, configs[name])
+		credential.Required = required[name]
+		if circuit := authBreakers.Status(name); circuit.State != provider.BreakerClosed || circuit.Failures > 0 {
+			credential.Circuit = &circuit
+		}
+		if credential.Required && credential.Status != credentialUsable {
+			unusable = append(unusable, name)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	switch viper.GetString("output") {
+	case "json":
+		style.PrintJSON(w, credentials)
+	case "yaml":
+		style.PrintYAML(w, credentials)
+	default:
+		printProviderCredentials(w, credentials)
+	}
+
+	if len(unusable) > 0 {
+		return fmt.Errorf("the workflow requires providers which aren't usable: %s", strings.Join(unusable, ", "))
+	}
+
+	return nil
+}
+
+// checkProviderCredential finds the API key of the provider, in the agent
+// configuration, the environment or the keychain, and checks it by listing
+// the provider's models.
+func checkProviderCredential(ctx context.Context, name string, config map[string]interface{}) *ProviderCredential {
+	credential := &ProviderCredential{Provider: name}
+
+	key := ""
+	if platform, _ := config["platform"].(string); platform != "" {
+		credential.Source = "platform " + platform
+	} else if configKey, _ := config["api_key"].(string); configKey != "" {
+		key, credential.Source = configKey, "workflow"
+	} else {
+		for _, envVar := range apiKeyEnvVars[name] {
+			if value := strings.TrimSpace(os.Getenv(envVar)); value != "" {
+				key, credential.Source = value, envVar
+				break
+			}
+		}
+		if key == "" {
+			if value := provider.APIKeyFromKeychain(name); value != "" {
+				key, credential.Source = value, "keychain"
+			}
+		}
+	}
+
+	if credential.Source == "" {
+		credential.Status = credentialMissing
+		credential.Error = fmt.Sprintf("no API key, set %s or run laq auth login %s", apiKeyEnvVars[name][0], name)
+		return credential
+	}
+	credential.Key = maskKey(key)
+
+	pr, err := newCredentialProvider(name, config)
+	if err != nil {
+		credential.Status = credentialInvalid
+		credential.Error = err.Error()
+		return credential
+	}
+	defer pr.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, credentialCheckTimeout)
+	defer cancel()
+
+	models, err := pr.ListModels(ctx)
+	if err != nil {
+		credential.Status = credentialInvalid
+		credential.Error = err.Error()
+		return credential
+	}
+
+	credential.Status = credentialUsable
+	credential.Models = len(models)
+	return credential
+}
+
+// maskKey hides all but the prefix and last four characters of a key.
+func maskKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) < 16 {
+		return "****"
+	}
+
+	prefix := key[:3]
+	if i := strings.LastIndex(key[:min(len(key)-4, 8)], "-"); i > 0 {
+		prefix = key[:i+1]
+	}
+	return prefix + "..." + key[len(key)-4:]
+}
+
+func printProviderCredentials(w io.Writer, credentials []*ProviderCredential) {
+	fmt.Fprintln(w, style.TitleStyle.Render("Provider credentials"))
+
+	width := 0
+	for _, credential := range credentials {
+		width = max(width, len(credential.Provider))
+	}
+
+	for _, credential := range credentials {
+		icon := style.SuccessIcon()
+		if credential.Status != credentialUsable {
+			icon = style.ErrorIcon()
+			if !credential.Required {
+				icon = style.WarningIcon()
+			}
+		}
+
+		line := fmt.Sprintf("  %s %-*s", icon, width, credential.Provider)
+		if credential.Source != "" {
+			line += "  " + style.InfoStyle.Render(credential.Source)
+		}
+		if credential.Key != "" {
+			line += "  " + style.MutedStyle.Render(credential.Key)
+		}
+		if credential.Required {
+			line += "  " + style.AccentStyle.Render("required")
+		}
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+
+		if credential.Error != "" {
+			fmt.Fprintf(w, "    %s\n", style.MutedStyle.Render(credential.Error))
+		}
+		if credential.Circuit != nil {
+			fmt.Fprintf(w, "    %s\n", style.WarningStyle.Render(describeCircuit(credential.Circuit)))
+		}
+	}
+
+	// providers without API keys, such as openai-compatible ones, only
+	// have their circuit breakers reported
+	var others []provider.BreakerStatus
+	for _, status := range authBreakers.Statuses() {
+		if !slices.Contains(provider.APIKeyProviders, status.Provider) {
+			others = append(others, status)
+		}
+	}
+	if len(others) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, style.TitleStyle.Render("Circuit breakers"))
+		for _, status := range others {
+			fmt.Fprintf(w, "  %s %s\n", style.WarningIcon(), status.Provider)
+			fmt.Fprintf(w, "    %s\n", style.WarningStyle.Render(describeCircuit(&status)))
+		}
+	}
+}
+
+// describeCircuit describes the state of a provider's circuit breaker.
+func describeCircuit(status *provider.BreakerStatus) string {
+	switch status.State {
+	case provider.BreakerOpen:
+		return fmt.Sprintf("circuit breaker open after repeated failures, requests fail fast until %s", status.OpenUntil.Local().Format(time.TimeOnly))
+	case provider.BreakerHalfOpen:
+		return "circuit breaker half-open, the next request probes whether the provider has recovered"
+	default:
+		return fmt.Sprintf("circuit breaker closed, %d consecutive failed requests", status.Failures)
+	}
+}
@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/keychain"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/provider/anthropic"
+	"github.com/lacquerai/lacquer/internal/provider/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeychain replaces the OS keychain with an in memory one.
+func fakeKeychain(t *testing.T) map[string]string {
+	t.Helper()
+
+	secrets := map[string]string{}
+	originalSet, originalDelete := keychainSet, keychainDelete
+	t.Cleanup(func() { keychainSet, keychainDelete = originalSet, originalDelete })
+
+	keychainSet = func(account, secret string) error {
+		secrets[account] = secret
+		return nil
+	}
+	keychainDelete = func(account string) error {
+		if _, ok := secrets[account]; !ok {
+			return keychain.ErrNotFound
+		}
+		delete(secrets, account)
+		return nil
+	}
+
+	return secrets
+}
+
+func TestAuthLogin(t *testing.T) {
+	secrets := fakeKeychain(t)
+
+	var out bytes.Buffer
+	require.NoError(t, authLogin(strings.NewReader("sk-ant-secret\n"), &out, "anthropic"))
+	assert.Equal(t, map[string]string{"anthropic": "sk-ant-secret"}, secrets)
+	assert.Contains(t, out.String(), "Stored the anthropic API key in the keychain")
+
+	err := authLogin(strings.NewReader(""), &out, "openai")
+	assert.EqualError(t, err, "no API key was given")
+
+	err = authLogin(strings.NewReader("key"), &out, "acme")
+	assert.EqualError(t, err, `unknown provider "acme", must be one of anthropic, openai`)
+}
+
+func TestAuthLogout(t *testing.T) {
+	secrets := fakeKeychain(t)
+	secrets["openai"] = "sk-secret"
+
+	var out bytes.Buffer
+	require.NoError(t, authLogout(&out, "openai"))
+	assert.Empty(t, secrets)
+	assert.Contains(t, out.String(), "Removed the openai API key from the keychain")
+
+	out.Reset()
+	require.NoError(t, authLogout(&out, "openai"))
+	assert.Contains(t, out.String(), "No openai API key is stored in the keychain")
+}
+
+// credentialProvider lists models, or fails when it has an error.
+type credentialProvider struct {
+	provider.Provider
+	err error
+}
+
+func (p *credentialProvider) ListModels(context.Context) ([]provider.Info, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []provider.Info{{ID: "claude-sonnet-4"}, {ID: "claude-opus-4"}}, nil
+}
+
+func (p *credentialProvider) Close() error { return nil }
+
+func TestAuthStatus(t *testing.T) {
+	for _, envVar := range append(anthropic.APIKeyEnvVars, openai.APIKeyEnvVars...) {
+		t.Setenv(envVar, "")
+	}
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-REDACTED")
+	stubAuthBreakers(t)
+
+	original := newCredentialProvider
+	t.Cleanup(func() { newCredentialProvider = original })
+	newCredentialProvider = func(name string, config map[string]interface{}) (provider.Provider, error) {
+		if config["api_key"] == "sk-revoked-0123456789" {
+			return &credentialProvider{err: errors.New("401 Unauthorized")}, nil
+		}
+		return &credentialProvider{}, nil
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, authStatus(context.Background(), &out, ""))
+	assert.Equal(t, `Provider credentials
+  ✓ anthropic  ANTHROPIC_API_KEY  sk-ant-...cdef
+  ⚠ openai
+    no API key, set OPENAI_API_KEY or run laq auth login openai
+`, re.ReplaceAllString(out.String(), ""))
+
+	file := filepath.Join(t.TempDir(), "workflow.laq.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`version: "1.0"
+agents:
+  writer:
+    provider: anthropic
+    model: claude-sonnet-4
+  reviewer:
+    provider: openai
+    model: gpt-4o
+    config:
+      api_key: sk-revoked-0123456789
+workflow:
+  steps:
+    - id: write
+      agent: writer
+      prompt: "Write a haiku"
+`), 0600))
+
+	out.Reset()
+	err := authStatus(context.Background(), &out, file)
+	assert.EqualError(t, err, "the workflow requires providers which aren't usable: openai")
+	assert.Equal(t, `Provider credentials
+  ✓ anthropic  ANTHROPIC_API_KEY  sk-ant-...cdef  required
+  ✗ openai     workflow  sk-...6789  required
+    401 Unauthorized
+`, re.ReplaceAllString(out.String(), ""))
+}
+
+// stubAuthBreakers replaces the circuit breakers reported by auth status
+// with ones only kept in memory.
+func stubAuthBreakers(t *testing.T) *provider.Breakers {
+	t.Helper()
+
+	original := authBreakers
+	t.Cleanup(func() { authBreakers = original })
+	authBreakers = provider.NewBreakers("")
+	return authBreakers
+}
+
+func TestAuthStatus_CircuitBreakers(t *testing.T) {
+	for _, envVar := range append(anthropic.APIKeyEnvVars, openai.APIKeyEnvVars...) {
+		t.Setenv(envVar, "")
+	}
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-REDACTED")
+	t.Setenv("OPENAI_API_KEY", "sk-proj-0123456789abcdef")
+
+	original := newCredentialProvider
+	t.Cleanup(func() { newCredentialProvider = original })
+	newCredentialProvider = func(string, map[string]interface{}) (provider.Provider, error) {
+		return &credentialProvider{}, nil
+	}
+
+	breakers := stubAuthBreakers(t)
+	breakers.Configure(provider.BreakerConfig{Failures: 2, Cooldown: time.Minute})
+	breakers.Record("anthropic", errors.New("529 overloaded"))
+	breakers.Record("anthropic", errors.New("529 overloaded"))
+	breakers.Record("openai", errors.New("429 rate limited"))
+	breakers.Record("openai-compatible-3f2a", errors.New("connection refused"))
+
+	until := breakers.Status("anthropic").OpenUntil.Local().Format(time.TimeOnly)
+
+	var out bytes.Buffer
+	require.NoError(t, authStatus(context.Background(), &out, ""))
+	assert.Equal(t, `Provider credentials
+  ✓ anthropic  ANTHROPIC_API_KEY  sk-ant-...cdef
+    circuit breaker open after repeated failures, requests fail fast until `+until+`
+  ✓ openai     OPENAI_API_KEY  sk-proj-...cdef
+    circuit breaker closed, 1 consecutive failed requests
+
+Circuit breakers
+  ⚠ openai-compatible-3f2a
+    circuit breaker closed, 1 consecutive failed requests
+`, re.ReplaceAllString(out.String(), ""))
+}
+
+func TestMaskKey(t *testing.T) {
+	assert.Equal(t, "", maskKey(""))
+	assert.Equal(t, "****", maskKey("short-key"))
+	assert.Equal(t, "sk-ant-...wxyz", maskKey("sk-ant-REDACTED"))
+	assert.Equal(t, "sk-proj-...wxyz", maskKey("sk-proj-abcdefghijklmnopqrstuvwxyz"))
+	assert.Equal(t, "abc...wxyz", maskKey("abcdefghijklmnopqrstuvwxyz"))
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/bench"
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/routing"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	benchRuns        int
+	benchConcurrency int
+	benchPrompts     []string
+	benchPromptsFile string
+	benchMaxTokens   int
+	benchTimeout     time.Duration
+)
+
+// benchCmd represents the bench command
+var benchCmd = &cobra.Command{
+	Use:   "bench <provider/model>...",
+	Short: "Benchmark the latency, throughput and cost of models",
+	Long: `Send a set of prompts to models a number of times and report the latency
+percentiles, throughput, failure rate and estimated cost of the requests.
+
+Use this to compare models before building workflows around them, and to check
+the credentials and rate limits of providers. Models are given as
+provider/model, the same as the provider and model of agents. Costs are
+estimated from the routing table, including the routing.models of the config
+file, and are left out for models it doesn't price.
+
+The command fails when every request to a model failed.`,
+	Example: `
+  laq bench anthropic/claude-sonnet-4                          # Benchmark a model
+  laq bench openai/gpt-4o-mini openai/gpt-4.1-mini -n 10       # Compare models over 10 runs
+  laq bench anthropic/claude-3-5-haiku --concurrency 8 -n 40   # Check rate limits
+  laq bench openai/gpt-4o --prompts-file prompts.txt           # Benchmark your own prompts
+  laq bench openai/gpt-4o --output json                        # Results as JSON`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		options := bench.Options{
+			Prompts:     benchPrompts,
+			Runs:        benchRuns,
+			Concurrency: benchConcurrency,
+			MaxTokens:   benchMaxTokens,
+			Timeout:     benchTimeout,
+		}
+		err := readBenchPrompts(benchPromptsFile, &options)
+		if err == nil {
+			err = runBench(cmd.Context(), cmd.OutOrStdout(), args, options, newBenchProvider)
+		}
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	benchCmd.Flags().IntVarP(&benchRuns, "runs", "n", 5, "number of times each prompt is sent to each model")
+	benchCmd.Flags().IntVar(&benchConcurrency, "concurrency", 1, "number of requests sent to a model at the same time")
+	benchCmd.Flags().StringArrayVarP(&benchPrompts, "prompt", "p", nil, "prompt to send, can be repeated (default a short explanation)")
+	benchCmd.Flags().StringVar(&benchPromptsFile, "prompts-file", "", "file of prompts to send, one per line")
+	benchCmd.Flags().IntVar(&benchMaxTokens, "max-tokens", 256, "maximum tokens of each completion, 0 for the model's default")
+	benchCmd.Flags().DurationVar(&benchTimeout, "timeout", 2*time.Minute, "timeout of each request")
+
+	rootCmd.AddCommand(benchCmd)
+}
+
+// readBenchPrompts adds the prompts of a file, one per line, to the
+// benchmark's prompts. Blank lines are skipped.
+func readBenchPrompts(file string, options *bench.Options) error {
+	if file == "" {
+		return nil
+	}
+
+	f, err := os.Open(file) // #nosec G304 - file is given by the user
+	if err != nil {
+		return fmt.Errorf("failed to read prompts: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if prompt := strings.TrimSpace(scanner.Text()); prompt != "" {
+			options.Prompts = append(options.Prompts, prompt)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read prompts: %w", err)
+	}
+	if len(options.Prompts) == 0 {
+		return fmt.Errorf("no prompts found in %s", file)
+	}
+
+	return nil
+}
+
+func newBenchProvider(name string) (provider.Provider, error) {
+	return engine.NewModelProvider(name, nil)
+}
+
+// runBench benchmarks the targets one after the other, so that they don't
+// compete for bandwidth or the rate limits of a provider.
+func runBench(ctx context.Context, w io.Writer, args []string, options bench.Options, newProvider func(string) (provider.Provider, error)) error {
+	targets := make([]bench.Target, len(args))
+	for i, arg := range args {
+		target, err := bench.ParseTarget(arg)
+		if err != nil {
+			return err
+		}
+		targets[i] = target
+	}
+
+	var routingModels []routing.Model
+	if err := viper.UnmarshalKey("routing.models", &routingModels); err != nil {
+		return fmt.Errorf("invalid routing configuration: %w", err)
+	}
+	options.Router = routing.NewRouter(append(routing.DefaultModels(), routingModels...))
+
+	// the models of providers are always listed, so that bad credentials
+	// are reported before any prompt is sent
+	registry := provider.NewRegistry(true)
+	failures := make(map[string]error)
+
+	var results []*bench.Result
+	for _, target := range targets {
+		result := benchTarget(ctx, registry, failures, target, options, newProvider)
+		results = append(results, result)
+	}
+
+	switch viper.GetString("output") {
+	case "json":
+		style.PrintJSON(w, results)
+	case "yaml":
+		style.PrintYAML(w, results)
+	default:
+		printBenchResults(w, options, results)
+	}
+
+	var failed []string
+	for _, result := range results {
+		if result.Failures == result.Requests {
+			failed = append(failed, result.String())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("every request failed for %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+func benchTarget(ctx context.Context, registry *provider.Registry, failures map[string]error, target bench.Target, options bench.Options, newProvider func(string) (provider.Provider, error)) *bench.Result {
+	if err, ok := failures[target.Provider]; ok {
+		return bench.Failed(target, err)
+	}
+
+	pr, err := registry.GetProviderByName(target.Provider)
+	if err != nil {
+		if pr, err = newProvider(target.Provider); err == nil {
+			err = registry.RegisterProviderContext(ctx, pr)
+		}
+		if err != nil {
+			failures[target.Provider] = err
+			return bench.Failed(target, err)
+		}
+	}
+
+	model, err := registry.ModelAlias(target.Provider, target.Model)
+	if err != nil {
+		return bench.Failed(target, err)
+	}
+
+	return bench.Run(ctx, pr, target, model, options)
+}
+
+func printBenchResults(w io.Writer, options bench.Options, results []*bench.Result) {
+	prompts := max(len(options.Prompts), 1)
+	fmt.Fprintln(w, style.TitleStyle.Render(fmt.Sprintf("Benchmark of %d prompt(s) × %d run(s), concurrency %d", prompts, max(options.Runs, 1), max(options.Concurrency, 1))))
+
+	width := len("MODEL")
+	for _, result := range results {
+		width = max(width, len(result.String()))
+	}
+
+	row := fmt.Sprintf("%%-%ds  %%5s  %%7s  %%8s  %%8s  %%8s  %%7s  %%6s  %%10s", width)
+	fmt.Fprintln(w, style.MutedStyle.Render(fmt.Sprintf(row, "MODEL", "REQS", "FAILED", "P50", "P90", "P99", "TOK/S", "REQ/S", "COST")))
+
+	for _, result := range results {
+		failed, cost := "-", "-"
+		if result.Requests > 0 {
+			failed = fmt.Sprintf("%.0f%%", result.FailureRate()*100)
+		}
+		if result.Cost != nil {
+			cost = formatCost(*result.Cost)
+		}
+		fmt.Fprintf(w, row+"\n",
+			result.String(),
+			fmt.Sprint(result.Requests),
+			failed,
+			formatLatency(result.Latency.P50),
+			formatLatency(result.Latency.P90),
+			formatLatency(result.Latency.P99),
+			fmt.Sprintf("%.1f", result.TokensPerSecond),
+			fmt.Sprintf("%.2f", result.RequestsPerSecond),
+			cost,
+		)
+	}
+
+	for _, result := range results {
+		if result.Resolved != "" {
+			fmt.Fprintln(w, style.MutedStyle.Render(fmt.Sprintf("%s resolved to %s", result.String(), result.Resolved)))
+		}
+		for _, err := range result.Errors {
+			fmt.Fprintln(w, style.ErrorStyle.Render(fmt.Sprintf("%s: %s", result.String(), err)))
+		}
+	}
+}
+
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
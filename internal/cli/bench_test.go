@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/bench"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBench(t *testing.T) {
+	newProvider := func(name string) (provider.Provider, error) {
+		if name == "anthropic" {
+			return nil, errors.New("ANTHROPIC_API_KEY is not set")
+		}
+		mock := provider.NewMockProvider(name, []provider.Info{{ID: "gpt-4o-mini"}})
+		mock.SetResponse(bench.DefaultPrompt, "Rayleigh scattering.")
+		return mock, nil
+	}
+
+	var out bytes.Buffer
+	err := runBench(context.Background(), &out, []string{"openai/gpt-4o-mini", "anthropic/claude-sonnet-4"}, bench.Options{Runs: 2}, newProvider)
+	assert.EqualError(t, err, "every request failed for anthropic/claude-sonnet-4")
+
+	lines := bytes.Split([]byte(re.ReplaceAllString(out.String(), "")), []byte("\n"))
+	require.Len(t, lines, 6)
+	assert.Equal(t, "Benchmark of 1 prompt(s) × 2 run(s), concurrency 1", string(lines[0]))
+	assert.Regexp(t, `^MODEL\s+REQS\s+FAILED\s+P50\s+P90\s+P99\s+TOK/S\s+REQ/S\s+COST$`, string(lines[1]))
+	assert.Regexp(t, `^openai/gpt-4o-mini\s+2\s+0%\s+\S+\s+\S+\s+\S+\s+\S+\s+\S+\s+\$0\.0000$`, string(lines[2]))
+	assert.Regexp(t, `^anthropic/claude-sonnet-4\s+0\s+-\s+-\s+-\s+-\s+0\.0\s+0\.00\s+-$`, string(lines[3]))
+	assert.Equal(t, "anthropic/claude-sonnet-4: ANTHROPIC_API_KEY is not set", string(lines[4]))
+
+	err = runBench(context.Background(), &out, []string{"gpt-4o-mini"}, bench.Options{}, newProvider)
+	assert.Error(t, err)
+}
+
+func TestReadBenchPrompts(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prompts.txt")
+	require.NoError(t, os.WriteFile(file, []byte("Summarize the news.\n\n  Translate hello to French.  \n"), 0600))
+
+	options := bench.Options{Prompts: []string{"Write a haiku."}}
+	require.NoError(t, readBenchPrompts(file, &options))
+	assert.Equal(t, []string{"Write a haiku.", "Summarize the news.", "Translate hello to French."}, options.Prompts)
+
+	require.NoError(t, os.WriteFile(file, []byte("\n"), 0600))
+	assert.Error(t, readBenchPrompts(file, &bench.Options{}))
+	assert.Error(t, readBenchPrompts(filepath.Join(t.TempDir(), "missing.txt"), &bench.Options{}))
+}
@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/registry"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// blocksCmd represents the blocks command
+var blocksCmd = &cobra.Command{
+	Use:   "blocks",
+	Short: "Discover and add reusable blocks",
+	Long: `Search the public block index, and any private registries configured under
+blocks.registries, for reusable blocks and add them to workflows.
+
+Private registries are configured in .lacquer/config.yaml:
+
+  blocks:
+    registries:
+      - name: internal
+        url: https://blocks.example.com/index.json
+        token: $BLOCKS_TOKEN`,
+}
+
+var blocksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available blocks",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		blocks, err := newRegistryClient().List(cmd.Context())
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+
+		printBlocks(cmd.OutOrStdout(), blocks)
+	},
+}
+
+var blocksSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search blocks by name, description and tags",
+	Example: `
+  laq blocks search http       # Find blocks for making HTTP requests
+  laq blocks search pdf text   # Find blocks matching both words`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		blocks, err := newRegistryClient().Search(cmd.Context(), strings.Join(args, " "))
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+
+		printBlocks(cmd.OutOrStdout(), blocks)
+	},
+}
+
+var blocksShowCmd = &cobra.Command{
+	Use:   "show <block>",
+	Short: "Show the README, inputs and outputs of a block",
+	Example: `
+  laq blocks show lacquer/http-request      # Show the latest version
+  laq blocks show lacquer/http-request@v1   # Show a specific version`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		block, err := newRegistryClient().Get(cmd.Context(), args[0])
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+
+		w := cmd.OutOrStdout()
+		switch viper.GetString("output") {
+		case "json":
+			style.PrintJSON(w, block)
+		case "yaml":
+			style.PrintYAML(w, block)
+		default:
+			printWorkflowDoc(w, block.Ref(), block.Describe())
+			if block.Readme != "" {
+				fmt.Fprintf(w, "\n%s\n%s\n", style.InfoStyle.Render("README"), strings.TrimSpace(block.Readme))
+			}
+		}
+	},
+}
+
+var blocksAddCmd = &cobra.Command{
+	Use:   "add <block> <workflow.laq.yaml>",
+	Short: "Add a block as a step of a workflow",
+	Long: `Add a block as a new step at the end of a workflow. The step pins the block's
+version and lists the block's required inputs under with, ready to be filled in.`,
+	Example: `
+  laq blocks add lacquer/http-request@v1 workflow.laq.yml             # Add a step using the block
+  laq blocks add lacquer/http-request workflow.laq.yml --id fetch     # Add the latest version with a custom step id`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		block, err := newRegistryClient().Get(cmd.Context(), args[0])
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+
+		id, _ := cmd.Flags().GetString("id")
+		stepID, err := addBlockStep(args[1], block, id)
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+
+		style.Success(cmd.OutOrStdout(), fmt.Sprintf("Added step %s using %s to %s", stepID, block.Ref(), args[1]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(blocksCmd)
+	blocksCmd.AddCommand(blocksListCmd, blocksSearchCmd, blocksShowCmd, blocksAddCmd)
+
+	blocksAddCmd.Flags().String("id", "", "id of the new step (defaults to the block name)")
+}
+
+// newRegistryClient creates a client for the configured private registries
+// followed by the public block index.
+func newRegistryClient() *registry.Client {
+	var sources []registry.Source
+	if err := viper.UnmarshalKey("blocks.registries", &sources); err != nil {
+		style.Warning(os.Stderr, fmt.Sprintf("ignoring invalid blocks.registries configuration: %s", err))
+		sources = nil
+	}
+
+	index := viper.GetString("blocks.index")
+	if index == "" {
+		index = registry.DefaultIndexURL
+	}
+	sources = append(sources, registry.Source{Name: "lacquer", URL: index})
+
+	return registry.NewClient(sources, registry.WithCache(filepath.Join(utils.LacquerCacheDir, "blocks"), registry.DefaultCacheTTL))
+}
+
+func printBlocks(w io.Writer, blocks []*registry.Block) {
+	switch viper.GetString("output") {
+	case "json":
+		style.PrintJSON(w, blocks)
+		return
+	case "yaml":
+		style.PrintYAML(w, blocks)
+		return
+	}
+
+	if len(blocks) == 0 {
+		fmt.Fprintln(w, style.MutedStyle.Render("No blocks found"))
+		return
+	}
+
+	width := 0
+	for _, block := range blocks {
+		width = max(width, len(block.Ref()))
+	}
+
+	for _, block := range blocks {
+		fmt.Fprintf(w, "%s  %s\n", style.AccentStyle.Render(fmt.Sprintf("%-*s", width, block.Ref())), block.Description)
+	}
+}
+
+// addBlockStep appends a step using the block to the workflow file and
+// returns the id of the new step. The file is edited in place so that its
+// comments are kept.
+func addBlockStep(file string, block *registry.Block, id string) (string, error) {
+	data, err := os.ReadFile(file) // #nosec G304 - file is provided by the user
+	if err != nil {
+		return "", err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("%s is not a workflow", file)
+	}
+
+	workflow := mappingValue(doc.Content[0], "workflow")
+	if workflow == nil {
+		workflow = &yaml.Node{Kind: yaml.MappingNode}
+		appendMapping(doc.Content[0], "workflow", workflow)
+	}
+
+	steps := mappingValue(workflow, "steps")
+	if steps == nil {
+		steps = &yaml.Node{Kind: yaml.SequenceNode}
+		appendMapping(workflow, "steps", steps)
+	}
+	if steps.Kind != yaml.SequenceNode {
+		return "", fmt.Errorf("workflow.steps in %s is not a list", file)
+	}
+
+	existing := make(map[string]bool)
+	for _, step := range steps.Content {
+		if stepID := mappingValue(step, "id"); stepID != nil {
+			existing[stepID.Value] = true
+		}
+	}
+
+	if id == "" {
+		id = uniqueStepID(strings.ReplaceAll(path.Base(block.Name), "-", "_"), existing)
+	} else if existing[id] {
+		return "", fmt.Errorf("step %s already exists in %s", id, file)
+	}
+
+	step := &yaml.Node{Kind: yaml.MappingNode}
+	appendMapping(step, "id", scalarNode(id))
+	appendMapping(step, "uses", scalarNode(block.Ref()))
+
+	var required []string
+	for name, param := range block.Inputs {
+		if param.Required && param.Default == nil {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+
+	if len(required) > 0 {
+		with := &yaml.Node{Kind: yaml.MappingNode}
+		for _, name := range required {
+			value := scalarNode("")
+			value.Style = yaml.DoubleQuotedStyle
+			value.LineComment = inputComment(block.Inputs[name])
+			appendMapping(with, name, value)
+		}
+		appendMapping(step, "with", with)
+	}
+
+	steps.Content = append(steps.Content, step)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+
+	return id, os.WriteFile(file, buf.Bytes(), info.Mode().Perm())
+}
+
+func uniqueStepID(base string, existing map[string]bool) string {
+	id := base
+	for i := 2; existing[id]; i++ {
+		id = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	return id
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func appendMapping(node *yaml.Node, key string, value *yaml.Node) {
+	node.Content = append(node.Content, scalarNode(key), value)
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// inputComment describes a required input next to its placeholder value.
+func inputComment(param *ast.InputParam) string {
+	comment := "# required"
+	if param.Type != "" {
+		comment += " " + param.Type
+	}
+
+	if description, _, _ := strings.Cut(strings.TrimSpace(param.Description), "\n"); description != "" {
+		comment += " - " + description
+	}
+
+	return comment
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddBlockStep(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "workflow.laq.yml")
+	require.NoError(t, os.WriteFile(file, []byte(`version: "1.0"
+# fetches things
+workflow:
+  steps:
+    - id: http_request
+      run: echo "hello"
+`), 0600))
+
+	block := &registry.Block{
+		Name:    "lacquer/http-request",
+		Version: "v1",
+		Inputs: map[string]*ast.InputParam{
+			"url":     {Type: "string", Description: "The URL to request", Required: true},
+			"method":  {Type: "string", Default: "GET"},
+			"headers": {Type: "object", Required: true},
+		},
+	}
+
+	id, err := addBlockStep(file, block, "")
+	require.NoError(t, err)
+	assert.Equal(t, "http_request_2", id)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `version: "1.0"
+# fetches things
+workflow:
+  steps:
+    - id: http_request
+      run: echo "hello"
+    - id: http_request_2
+      uses: lacquer/http-request@v1
+      with:
+        headers: "" # required object
+        url: "" # required string - The URL to request
+`, string(data))
+
+	_, err = addBlockStep(file, block, "http_request")
+	assert.EqualError(t, err, "step http_request already exists in "+file)
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/spf13/viper"
+)
+
+// newCipher creates the cipher encrypting the run history and step results
+// kept on this machine, or nil when encryption isn't configured.
+func newCipher() (*encryption.Cipher, error) {
+	return parseCipher(viper.GetViper())
+}
+
+// parseCipher reads the encryption key from config, environment variables
+// in it are expanded, falling back to LACQUER_ENCRYPTION_KEY and then the OS
+// keychain when encryption.keychain is set.
+func parseCipher(config *viper.Viper) (*encryption.Cipher, error) {
+	value := os.ExpandEnv(config.GetString("encryption.key"))
+	if value == "" {
+		value = os.Getenv("LACQUER_ENCRYPTION_KEY")
+	}
+
+	var key []byte
+	var err error
+	switch {
+	case value != "":
+		key, err = encryption.ParseKey(value)
+	case config.GetBool("encryption.keychain"):
+		key, err = encryption.KeychainKey()
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption configuration: %w", err)
+	}
+
+	return encryption.NewCipher(key)
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/utils"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCipher(t *testing.T) {
+	t.Setenv("LACQUER_ENCRYPTION_KEY", "")
+
+	config := viper.New()
+	cipher, err := parseCipher(config)
+	require.NoError(t, err)
+	assert.Nil(t, cipher)
+
+	t.Setenv("HISTORY_KEY", "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+	config.Set("encryption.key", "$HISTORY_KEY")
+	cipher, err = parseCipher(config)
+	require.NoError(t, err)
+	assert.NotNil(t, cipher)
+
+	config.Set("encryption.key", "not-a-key")
+	_, err = parseCipher(config)
+	assert.ErrorContains(t, err, "invalid encryption configuration: encryption key must be 32 bytes")
+
+	config.Set("encryption.key", "")
+	t.Setenv("LACQUER_ENCRYPTION_KEY", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	cipher, err = parseCipher(config)
+	require.NoError(t, err)
+	assert.NotNil(t, cipher)
+}
+
+func TestRunWorkflow_EncryptedRunLog(t *testing.T) {
+	cacheDir := utils.LacquerCacheDir
+	utils.LacquerCacheDir = t.TempDir()
+	defer func() { utils.LacquerCacheDir = cacheDir }()
+	t.Setenv("LACQUER_ENCRYPTION_KEY", "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+
+	workflowFile := filepath.Join(t.TempDir(), "workflow.laq.yml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(`version: "1.0"
+inputs:
+  prompt:
+    type: string
+workflow:
+  steps:
+    - id: fail
+      run: echo "${{ inputs.prompt }}" >&2 && exit 1
+`), 0o600))
+
+	opts, err := runnerOptions()
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = runWorkflow(execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  &out,
+		StdErr:  &out,
+	}, workflowFile, map[string]interface{}{"prompt": "confidential-prompt"}, opts...)
+	// the error of the step, written to the run log, holds the prompt
+	require.ErrorContains(t, err, "confidential-prompt")
+
+	logs, err := os.ReadDir(filepath.Join(utils.LacquerCacheDir, "logs"))
+	require.NoError(t, err)
+	require.NotEmpty(t, logs)
+	for _, entry := range logs {
+		data, err := os.ReadFile(filepath.Join(utils.LacquerCacheDir, "logs", entry.Name()))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "confidential-prompt", entry.Name())
+	}
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// explainCmd represents the explain command
+var explainCmd = &cobra.Command{
+	Use:   "explain <workflow.laq.yaml>",
+	Short: "Describe the inputs and outputs of a workflow or block",
+	Long: `Describe the interface of a workflow or block: the inputs it accepts, including
+their types, defaults and allowed values, and the outputs it provides.
+
+Use this to find out how to call a block from another workflow without reading
+its source.`,
+	Example: `
+  laq explain ./blocks/summarize.laq.yaml               # Describe a block
+  laq explain --output json ./blocks/summarize.laq.yaml # Describe a block as JSON`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := explainWorkflow(cmd.OutOrStdout(), args[0]); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
+
+func explainWorkflow(w io.Writer, file string) error {
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		return fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	workflow, err := yamlParser.ParseFile(file)
+	if err != nil {
+		return err
+	}
+
+	doc := workflow.Describe()
+
+	switch viper.GetString("output") {
+	case "json":
+		style.PrintJSON(w, doc)
+	case "yaml":
+		style.PrintYAML(w, doc)
+	default:
+		printWorkflowDoc(w, file, doc)
+	}
+
+	return nil
+}
+
+func printWorkflowDoc(w io.Writer, file string, doc *ast.WorkflowDoc) {
+	title := doc.Name
+	if title == "" {
+		title = file
+	}
+
+	fmt.Fprintln(w, style.TitleStyle.Render(title))
+	if doc.Description != "" {
+		fmt.Fprintln(w, doc.Description)
+	}
+
+	for _, section := range []struct {
+		title  string
+		params []ast.ParamDoc
+	}{
+		{"Inputs", doc.Inputs},
+		{"Outputs", doc.Outputs},
+	} {
+		fmt.Fprintf(w, "\n%s\n", style.InfoStyle.Render(section.title))
+		if len(section.params) == 0 {
+			fmt.Fprintln(w, style.MutedStyle.Render("  none"))
+			continue
+		}
+
+		for _, param := range section.params {
+			fmt.Fprintf(w, "  %s%s\n", style.AccentStyle.Render(param.Name), style.MutedStyle.Render(param.Summary()))
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainWorkflow(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "summarize.laq.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`version: "1.0"
+metadata:
+  name: summarize
+  description: Summarizes text
+inputs:
+  text:
+    type: string
+    description: The text to summarize
+    required: true
+  length:
+    type: string
+    enum: [short, long]
+    default: short
+outputs:
+  summary:
+    type: string
+    description: The summary
+workflow:
+  steps:
+    - id: summarize
+      run: echo "summary"
+  outputs:
+    summary: ${{ steps.summarize.output }}
+`), 0600))
+
+	var out bytes.Buffer
+	require.NoError(t, explainWorkflow(&out, file))
+
+	assert.Equal(t, `summarize
+Summarizes text
+
+Inputs
+  length (string, default: short, one of: short, long)
+  text (string, required) - The text to summarize
+
+Outputs
+  summary (string) - The summary
+`, re.ReplaceAllString(out.String(), ""))
+}
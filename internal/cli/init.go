@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -132,7 +133,7 @@ type ProjectAnswers struct {
 }
 
 // createWorkflow makes the initial API call to create a workflow
-func (wm *WorkflowManager) createWorkflow() (string, error) {
+func (wm *WorkflowManager) createWorkflow(ctx context.Context) (string, error) {
 	requestData := initRequest{
 		Description:    wm.answers.description,
 		ModelProviders: wm.answers.modelProviders,
@@ -145,11 +146,13 @@ func (wm *WorkflowManager) createWorkflow() (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := http.Post(
-		lacquerAPIBaseURL+"/v1/workflows/init",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, lacquerAPIBaseURL+"/v1/workflows/init", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to call init API: %w", err)
 	}
@@ -175,12 +178,22 @@ func (wm *WorkflowManager) createWorkflow() (string, error) {
 	return result.ID, nil
 }
 
-// pollWorkflowResults polls the workflow until completion and returns the results
-func (wm *WorkflowManager) pollWorkflowResults(workflowID string) (pollResultMsg, error) {
+// pollWorkflowResults polls the workflow until completion and returns the results,
+// polling stops when ctx is cancelled
+func (wm *WorkflowManager) pollWorkflowResults(ctx context.Context, workflowID string) (pollResultMsg, error) {
 	for {
-		time.Sleep(3 * time.Second)
+		select {
+		case <-time.After(3 * time.Second):
+		case <-ctx.Done():
+			return pollResultMsg{}, ctx.Err()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, lacquerAPIBaseURL+"/v1/workflows/"+workflowID+"/results", nil)
+		if err != nil {
+			return pollResultMsg{}, fmt.Errorf("failed to create request: %w", err)
+		}
 
-		resp, err := http.Get(lacquerAPIBaseURL + "/v1/workflows/" + workflowID + "/results")
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			return pollResultMsg{}, fmt.Errorf("failed to poll workflow: %w", err)
 		}
@@ -258,9 +271,9 @@ func (wm *WorkflowManager) saveGeneratedFiles(result pollResultMsg) (map[string]
 }
 
 // runWorkflowProcess handles the complete workflow creation, polling, and file saving
-func (wm *WorkflowManager) runWorkflowProcess() (map[string]string, error) {
+func (wm *WorkflowManager) runWorkflowProcess(ctx context.Context) (map[string]string, error) {
 	// Create workflow
-	workflowID, err := wm.createWorkflow()
+	workflowID, err := wm.createWorkflow(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -270,7 +283,7 @@ func (wm *WorkflowManager) runWorkflowProcess() (map[string]string, error) {
 	}
 
 	// Poll for results
-	result, err := wm.pollWorkflowResults(workflowID)
+	result, err := wm.pollWorkflowResults(ctx, workflowID)
 	if err != nil {
 		return nil, err
 	}
@@ -286,6 +299,8 @@ func (wm *WorkflowManager) runWorkflowProcess() (map[string]string, error) {
 
 // Model represents the wizard state
 type model struct {
+	// ctx cancels the API calls and polling of the wizard
+	ctx              context.Context
 	step             Step
 	projectNameInput textinput.Model
 	description      textinput.Model
@@ -369,7 +384,7 @@ func runNonInteractiveInit(runCtx execcontext.RunContext, flags InitFlags) error
 		out: runCtx.StdOut,
 	}
 
-	generatedFiles, err := wm.runWorkflowProcess()
+	generatedFiles, err := wm.runWorkflowProcess(runCtx.Context)
 	if err != nil {
 		return fmt.Errorf("failed to generate workflow: %w", err)
 	}
@@ -669,7 +684,7 @@ type initRequest struct {
 // Call init API
 func (m model) callInitAPI() tea.Cmd {
 	return func() tea.Msg {
-		workflowID, err := m.workflowMgr.createWorkflow()
+		workflowID, err := m.workflowMgr.createWorkflow(m.ctx)
 		if err != nil {
 			return errorMsg{err: err}
 		}
@@ -681,7 +696,7 @@ func (m model) callInitAPI() tea.Cmd {
 // Poll workflow results
 func (m model) pollWorkflow() tea.Cmd {
 	return tea.Tick(time.Second*5, func(t time.Time) tea.Msg {
-		result, err := m.workflowMgr.pollWorkflowResults(m.workflowID)
+		result, err := m.workflowMgr.pollWorkflowResults(m.ctx, m.workflowID)
 		if err != nil {
 			return errorMsg{err: err}
 		}
@@ -866,7 +881,9 @@ func initializeProjectInteractive(runCtx execcontext.RunContext, flags InitFlags
 	}
 
 	// Run the interactive wizard with pre-filled values
-	p := tea.NewProgram(initialModelWithFlags(flags), tea.WithAltScreen())
+	m := initialModelWithFlags(flags)
+	m.ctx = runCtx.Context
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(runCtx.Context))
 	if _, err := p.Run(); err != nil {
 		style.Error(runCtx, fmt.Sprintf("Failed to run setup wizard: %v", err))
 		os.Exit(1)
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// inputsCmd represents the inputs command
+var inputsCmd = &cobra.Command{
+	Use:   "inputs <workflow.laq.yaml>",
+	Short: "Print a template of the inputs of a workflow",
+	Long: `Print a ready to edit inputs file for a workflow. Every input is listed with
+its default, or a placeholder when it has none, and its type, description and
+constraints as comments.
+
+Save the template, fill in the values and pass it to laq run with --input-file.
+Inputs whose default is an expression are commented out so the expression is
+evaluated when the workflow starts.`,
+	Example: `
+  laq inputs workflow.laq.yaml > inputs.yaml               # Write a YAML template
+  laq inputs --output json workflow.laq.yaml > inputs.json # Write a JSON template
+  laq run workflow.laq.yaml --input-file inputs.yaml      # Run with the filled in template`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := printInputsTemplate(cmd.OutOrStdout(), args[0]); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inputsCmd)
+}
+
+func printInputsTemplate(w io.Writer, file string) error {
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		return fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	workflow, err := yamlParser.ParseFile(file)
+	if err != nil {
+		return err
+	}
+
+	if viper.GetString("output") == "json" {
+		return writeInputsJSON(w, workflow)
+	}
+
+	return writeInputsYAML(w, file, workflow)
+}
+
+// writeInputsJSON writes the inputs of the workflow as a JSON object. JSON
+// has no comments so inputs whose default is an expression are left out.
+func writeInputsJSON(w io.Writer, workflow *ast.Workflow) error {
+	inputs := make(map[string]interface{}, len(workflow.Inputs))
+	for name, param := range workflow.Inputs {
+		if param.HasExpressionDefault() {
+			continue
+		}
+		inputs[name] = inputTemplateValue(param)
+	}
+
+	data, err := json.MarshalIndent(inputs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode inputs: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// writeInputsYAML writes the inputs of the workflow as YAML with the details
+// of each input as comments above it.
+func writeInputsYAML(w io.Writer, file string, workflow *ast.Workflow) error {
+	var sb strings.Builder
+
+	name := filepath.Base(file)
+	if workflow.Metadata != nil && workflow.Metadata.Name != "" {
+		name = workflow.Metadata.Name
+	}
+	fmt.Fprintf(&sb, "# Inputs of %s\n", name)
+	fmt.Fprintf(&sb, "# Run with: laq run %s --input-file <this file>\n", file)
+
+	if len(workflow.Inputs) == 0 {
+		sb.WriteString("# The workflow has no inputs\n")
+	}
+
+	names := make([]string, 0, len(workflow.Inputs))
+	for name := range workflow.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		param := workflow.Inputs[name]
+
+		sb.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimSpace(param.Description), "\n") {
+			if line != "" {
+				fmt.Fprintf(&sb, "# %s\n", strings.TrimRight(line, " "))
+			}
+		}
+		fmt.Fprintf(&sb, "# %s\n", strings.Join(inputDetails(param), ", "))
+
+		value, err := yaml.Marshal(map[string]interface{}{name: inputTemplateValue(param)})
+		if err != nil {
+			return fmt.Errorf("failed to encode input %s: %w", name, err)
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(string(value), "\n"), "\n") {
+			if param.HasExpressionDefault() {
+				sb.WriteString("# ")
+			}
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// inputDetails describes the type and constraints of an input.
+func inputDetails(param *ast.InputParam) []string {
+	details := []string{"type: " + param.GetTypeString()}
+	if param.Required && param.Default == nil {
+		details = append(details, "required")
+	}
+	if param.Default != nil {
+		details = append(details, fmt.Sprintf("default: %v", param.Default))
+	}
+	if len(param.Enum) > 0 {
+		allowed := "one of: "
+		if param.Multiple {
+			allowed = "any of: "
+		}
+		details = append(details, allowed+strings.Join(enumOptions(param), ", "))
+	}
+	if param.Pattern != "" {
+		details = append(details, "pattern: "+param.Pattern)
+	}
+	if param.Minimum != nil {
+		details = append(details, fmt.Sprintf("minimum: %v", *param.Minimum))
+	}
+	if param.Maximum != nil {
+		details = append(details, fmt.Sprintf("maximum: %v", *param.Maximum))
+	}
+	if param.MinItems != nil {
+		details = append(details, fmt.Sprintf("min items: %d", *param.MinItems))
+	}
+	if param.MaxItems != nil {
+		details = append(details, fmt.Sprintf("max items: %d", *param.MaxItems))
+	}
+
+	return details
+}
+
+// enumOptions lists the allowed values of an enum input, each followed by
+// its label when it has one.
+func enumOptions(param *ast.InputParam) []string {
+	options := make([]string, len(param.Enum))
+	for i, option := range param.Enum {
+		options[i] = option.Value
+		if label := option.DisplayLabel(); label != option.Value {
+			options[i] += " (" + label + ")"
+		}
+	}
+	return options
+}
+
+// inputTemplateValue returns the value of an input in the template, its
+// default or otherwise a placeholder of its type.
+func inputTemplateValue(param *ast.InputParam) interface{} {
+	if param.Default != nil {
+		return param.Default
+	}
+
+	if len(param.Enum) > 0 {
+		if param.Multiple {
+			return []string{param.Enum[0].Value}
+		}
+		return param.Enum[0].Value
+	}
+
+	switch param.GetTypeString() {
+	case "integer":
+		if param.Minimum != nil {
+			return int(math.Ceil(*param.Minimum))
+		}
+		return 0
+	case "boolean":
+		return false
+	case "array":
+		return []interface{}{}
+	case "object":
+		return map[string]interface{}{}
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const inputsTestWorkflow = `version: "1.0"
+metadata:
+  name: summarize
+inputs:
+  text:
+    type: string
+    description: The text to summarize
+    required: true
+  length:
+    type: string
+    enum:
+      - short
+      - value: long
+        label: A few paragraphs
+    default: short
+  tags:
+    type: array
+    max_items: 3
+  retries:
+    type: integer
+    minimum: 1
+  author:
+    type: string
+    default: ${{ env.USER }}
+workflow:
+  steps:
+    - id: summarize
+      run: echo "summary"
+`
+
+func TestPrintInputsTemplate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "summarize.laq.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(inputsTestWorkflow), 0600))
+
+	var out bytes.Buffer
+	require.NoError(t, printInputsTemplate(&out, file))
+
+	assert.Equal(t, `# Inputs of summarize
+# Run with: laq run `+file+` --input-file <this file>
+
+# type: string, default: ${{ env.USER }}
+# author: ${{ env.USER }}
+
+# type: string, default: short, one of: short, long (A few paragraphs)
+length: short
+
+# type: integer, minimum: 1
+retries: 1
+
+# type: array, max items: 3
+tags: []
+
+# The text to summarize
+# type: string, required
+text: ""
+`, out.String())
+
+	// the template is a valid inputs file as it is
+	inputsFile := filepath.Join(t.TempDir(), "inputs.yaml")
+	require.NoError(t, os.WriteFile(inputsFile, out.Bytes(), 0600))
+
+	inputs, err := readInputFile(inputsFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"length":  "short",
+		"retries": 1,
+		"tags":    []interface{}{},
+		"text":    "",
+	}, inputs)
+}
+
+func TestPrintInputsTemplate_JSON(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "summarize.laq.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(inputsTestWorkflow), 0600))
+
+	var out bytes.Buffer
+	viper.Set("output", "json")
+	defer viper.Set("output", "text")
+	require.NoError(t, printInputsTemplate(&out, file))
+
+	assert.JSONEq(t, `{"length": "short", "retries": 1, "tags": [], "text": ""}`, out.String())
+}
+
+func TestReadInputFile(t *testing.T) {
+	dir := t.TempDir()
+
+	jsonFile := filepath.Join(dir, "inputs.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"topic": "go", "count": 2}`), 0600))
+	inputs, err := readInputFile(jsonFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"topic": "go", "count": float64(2)}, inputs)
+
+	yamlFile := filepath.Join(dir, "inputs.yml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte("# comment\ntopic: go\noptions:\n  verbose: true\n"), 0600))
+	inputs, err = readInputFile(yamlFile)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"topic": "go", "options": map[string]interface{}{"verbose": true}}, inputs)
+
+	invalidFile := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalidFile, []byte(`{"topic":`), 0600))
+	_, err = readInputFile(invalidFile)
+	assert.ErrorContains(t, err, "failed to parse input file")
+
+	_, err = readInputFile(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to open input file")
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/lacquerai/lacquer/internal/lsp"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/spf13/cobra"
+)
+
+// lspCmd represents the lsp command
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server for workflow files",
+	Long: `Serve the Language Server Protocol over stdin and stdout, for editors to
+run as the language server of .laq.yaml files.
+
+The server provides:
+- Diagnostics of the errors laq validate reports, as files are edited
+- Completion of step IDs, agent names and the inputs, steps and state of
+  expressions
+- Hover documentation of expression functions
+`,
+	Example: `
+  laq lsp    # Started by an editor, which talks to it over stdio`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		server, err := lsp.NewServer(Version)
+		if err == nil {
+			err = server.Serve(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		}
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
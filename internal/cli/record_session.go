@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/style"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recordProvider string
+	recordModel    string
+	recordForce    bool
+)
+
+// recordSessionCmd represents the record-session command
+var recordSessionCmd = &cobra.Command{
+	Use:   "record-session [output.laq.yaml]",
+	Short: "Turn an interactive session with a model into a workflow",
+	Long: `Chat with a model, or with claude-code using the local provider, and turn the
+session into a reusable workflow once it ends.
+
+Type /done, or send EOF, to end the session: the model is asked for a workflow
+doing the same work, with the agents, steps and tools the session used, and
+the workflow is validated before it's written. Type /quit to end the session
+without writing a workflow.`,
+	Example: `
+  laq record-session --model claude-sonnet-4-20250514 research.laq.yaml # Record a session with Anthropic
+  laq record-session --provider local --model sonnet research.laq.yaml # Record a claude-code session`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		output := "workflow.laq.yaml"
+		if len(args) > 0 {
+			output = args[0]
+		}
+
+		if err := recordSessionCommand(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout(), output); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recordSessionCmd)
+
+	recordSessionCmd.Flags().StringVar(&recordProvider, "provider", "anthropic", "provider of the session's model (anthropic, openai or local)")
+	recordSessionCmd.Flags().StringVar(&recordModel, "model", "", "model to hold the session with")
+	recordSessionCmd.Flags().BoolVar(&recordForce, "force", false, "overwrite the output file when it exists")
+	_ = recordSessionCmd.MarkFlagRequired("model")
+}
+
+func recordSessionCommand(ctx context.Context, in io.Reader, w io.Writer, output string) error {
+	if err := checkRecordOutput(output, recordForce); err != nil {
+		return err
+	}
+
+	pr, err := engine.NewModelProvider(recordProvider, nil)
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+
+	return recordSession(ctx, in, w, engine.NewSessionRecorder(pr, recordModel), output)
+}
+
+// checkRecordOutput refuses to overwrite an existing workflow unless forced,
+// before any of the session is spent.
+func checkRecordOutput(output string, force bool) error {
+	if force {
+		return nil
+	}
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+	}
+
+	return nil
+}
+
+// recordSession reads the prompts of the session from in until it ends and
+// writes the workflow synthesized from it to output.
+func recordSession(ctx context.Context, in io.Reader, w io.Writer, recorder *engine.SessionRecorder, output string) error {
+	fmt.Fprintln(w, style.MutedStyle.Render("Recording the session, type /done to turn it into a workflow or /quit to leave without one."))
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		fmt.Fprint(w, style.AccentStyle.Render("> "))
+		if !scanner.Scan() {
+			fmt.Fprintln(w)
+			break
+		}
+
+		prompt := strings.TrimSpace(scanner.Text())
+		if prompt == "/done" {
+			break
+		}
+		if prompt == "/quit" {
+			fmt.Fprintln(w, style.MutedStyle.Render("Session ended without writing a workflow."))
+			return nil
+		}
+		if prompt == "" {
+			continue
+		}
+
+		response, err := recorder.Send(ctx, prompt, func(event pkgEvents.ExecutionEvent) {
+			if call, ok := event.Payload().(*pkgEvents.ToolCall); ok {
+				fmt.Fprintln(w, style.MutedStyle.Render("  using "+call.Tool))
+			}
+		})
+		if err != nil {
+			style.Error(w, err.Error())
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n\n", response)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read the session: %w", err)
+	}
+
+	fmt.Fprintln(w, style.MutedStyle.Render("Building a workflow from the session..."))
+	data, err := recorder.Synthesize(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	style.Success(w, fmt.Sprintf("Wrote the workflow to %s, run it with laq run %s", output, output))
+	return nil
+}
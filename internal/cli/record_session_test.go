@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/pkg/lacquertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sessionWorkflow = `version: "1.0"
+agents:
+  writer:
+    provider: anthropic
+    model: test-model
+workflow:
+  steps:
+    - id: write
+      agent: writer
+      prompt: Write a haiku
+`
+
+func TestRecordSession(t *testing.T) {
+	pr := lacquertest.NewProvider("anthropic", []string{"test-model"},
+		lacquertest.Text("Autumn moonlight"),
+		lacquertest.Text("```yaml\n"+sessionWorkflow+"```"),
+	)
+	output := filepath.Join(t.TempDir(), "haiku.laq.yaml")
+
+	var out bytes.Buffer
+	err := recordSession(context.Background(), strings.NewReader("Write a haiku\n\n/done\n"), &out, engine.NewSessionRecorder(pr, "test-model"), output)
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), "Autumn moonlight")
+	assert.Len(t, pr.Requests(), 2)
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, sessionWorkflow, string(data))
+}
+
+func TestRecordSession_Quit(t *testing.T) {
+	pr := lacquertest.NewProvider("anthropic", []string{"test-model"}, lacquertest.Text("Autumn moonlight"))
+	output := filepath.Join(t.TempDir(), "haiku.laq.yaml")
+
+	var out bytes.Buffer
+	err := recordSession(context.Background(), strings.NewReader("Write a haiku\n/quit\n"), &out, engine.NewSessionRecorder(pr, "test-model"), output)
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, output)
+	assert.Len(t, pr.Requests(), 1)
+}
+
+func TestCheckRecordOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "haiku.laq.yaml")
+	assert.NoError(t, checkRecordOutput(output, false))
+
+	require.NoError(t, os.WriteFile(output, []byte(sessionWorkflow), 0644))
+	assert.EqualError(t, checkRecordOutput(output, false), output+" already exists, use --force to overwrite it")
+	assert.NoError(t, checkRecordOutput(output, true))
+}
@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/remote"
+	"github.com/lacquerai/lacquer/internal/style"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/spf13/viper"
+)
+
+// remoteStatusTimeout is how long to wait for the outcome of a remote run
+// once its events have been streamed
+const remoteStatusTimeout = 30 * time.Second
+
+// remoteServerURL is the lacquer server remote runs are sent to, from
+// --server or remote.url in config, environment variables in it are
+// expanded.
+func remoteServerURL() string {
+	if remoteServer != "" {
+		return remoteServer
+	}
+	if url := os.ExpandEnv(viper.GetString("remote.url")); url != "" {
+		return url
+	}
+	return os.Getenv("LACQUER_SERVER_URL")
+}
+
+// remoteAPIKey is the API key or bearer token remote runs authenticate
+// with, from remote.api_key in config or LACQUER_API_KEY.
+func remoteAPIKey() string {
+	if key := os.ExpandEnv(viper.GetString("remote.api_key")); key != "" {
+		return key
+	}
+	return os.Getenv("LACQUER_API_KEY")
+}
+
+// validateRemoteFlags ensures --remote isn't combined with flags which
+// only apply to runs on this machine.
+func validateRemoteFlags() error {
+	switch {
+	case remoteServerURL() == "":
+		return fmt.Errorf("--remote requires the URL of a lacquer server, set with --server or remote.url in config")
+	case watchMode:
+		return fmt.Errorf("--remote cannot be combined with --watch")
+	case resumeRun != "":
+		return fmt.Errorf("--remote cannot be combined with --resume")
+	case fromStep != "" || untilStep != "" || onlyStep != "" || fixturesFile != "":
+		return fmt.Errorf("--remote cannot be combined with --from-step, --until-step, --only-step or --fixtures")
+	case stateFile != "":
+		return fmt.Errorf("--remote cannot be combined with --state-file")
+	case lockModels || updateLock:
+		return fmt.Errorf("--remote cannot be combined with --lock or --update-lock, the server's laq.lock is used")
+	case budget > 0:
+		return fmt.Errorf("--remote cannot be combined with --budget")
+	case schedule != "":
+		return fmt.Errorf("--remote cannot be combined with --schedule")
+	case eventLog != "" || metricsAddr != "":
+		return fmt.Errorf("--remote cannot be combined with --event-log or --metrics-addr, the server records the events of its runs")
+	}
+	return nil
+}
+
+// isWorkflowFile reports whether a target of laq run --remote is a local
+// workflow file rather than the ID of a workflow loaded by the server.
+func isWorkflowFile(target string) bool {
+	if _, err := os.Stat(target); err == nil {
+		return true
+	}
+	return strings.HasSuffix(target, ".laq.yaml") || strings.HasSuffix(target, ".laq.yml")
+}
+
+// runRemote runs a workflow on a lacquer server, showing its progress as it
+// runs and its outcome as a local run does. A local workflow file is
+// validated and sent to the server, anything else is the ID of a workflow
+// the server has loaded.
+func runRemote(ctx execcontext.RunContext, client *remote.Client, target string, inputs map[string]interface{}) error {
+	started, err := startRemote(ctx, client, target, inputs)
+	if err != nil {
+		return reportRun(ctx, target, nil, err)
+	}
+
+	if !structuredOutput() {
+		fmt.Fprintf(ctx.StdOut, "%s Running %s on %s as %s\n", style.InfoIcon(), started.WorkflowID, client.Server(), started.RunID)
+	}
+
+	events := make(chan pkgEvents.ExecutionEvent, 100)
+	listenerChan := make(chan pkgEvents.ExecutionEvent, 100)
+	listener := progressListener(ctx)
+	listened := make(chan struct{})
+	go func() {
+		defer close(listened)
+		listener.StartListening(listenerChan)
+		for range listenerChan {
+		}
+	}()
+
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- client.Events(ctx.Context, started.RunID, events)
+	}()
+
+	steps := newRemoteSteps()
+	for event := range events {
+		steps.add(event)
+		listenerChan <- event
+	}
+	close(listenerChan)
+	<-listened
+	listener.StopListening()
+
+	if err := <-streamed; err != nil {
+		if ctx.Context.Err() != nil {
+			fmt.Fprintf(ctx.StdErr, "\n%s\n", style.MutedStyle.Render("Stopped following run "+started.RunID+", it continues on "+client.Server()))
+			return reportRun(ctx, target, nil, ctx.Context.Err())
+		}
+		return reportRun(ctx, target, nil, err)
+	}
+
+	// the run has finished once its events end, its outcome is fetched
+	// even when laq is interrupted meanwhile
+	statusCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx.Context), remoteStatusTimeout)
+	defer cancel()
+
+	status, err := client.Execution(statusCtx, started.RunID)
+	if err != nil {
+		return reportRun(ctx, target, nil, fmt.Errorf("failed to get the outcome of run %s: %w", started.RunID, err))
+	}
+
+	result := &engine.ExecutionResult{
+		WorkflowFile: target,
+		RunID:        status.RunID,
+		Status:       status.Status,
+		StartTime:    status.StartTime,
+		Duration:     status.Duration,
+		StepsTotal:   len(steps.results),
+		StepResults:  steps.results,
+		Inputs:       status.Inputs,
+		Outputs:      status.Outputs,
+		Error:        status.Error,
+		Triage:       status.Triage,
+	}
+	if status.EndTime != nil {
+		result.EndTime = *status.EndTime
+	}
+
+	if status.Status == "failed" {
+		err = errors.New(status.Error)
+		if status.Triage != nil {
+			err = &engine.TriagedError{Err: err, Triage: status.Triage}
+		}
+	}
+
+	return reportRun(ctx, target, result, err)
+}
+
+// startRemote starts a run of the target on the server.
+func startRemote(ctx execcontext.RunContext, client *remote.Client, target string, inputs map[string]interface{}) (*remote.Started, error) {
+	if !isWorkflowFile(target) {
+		return client.Execute(ctx.Context, target, inputs)
+	}
+
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	// the workflow is validated before it's sent, so that its errors point
+	// at the file as laq validate shows them
+	if _, err := yamlParser.ParseFile(target); err != nil {
+		return nil, err
+	}
+
+	source, err := os.ReadFile(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow file: %w", err)
+	}
+
+	return client.Submit(ctx.Context, target, source, inputs)
+}
+
+// remoteSteps builds the results of the steps of a remote run from its
+// events, the server only keeps the outcome of the run as a whole. Steps
+// which were skipped send no events and have no result.
+type remoteSteps struct {
+	results []engine.StepExecutionResult
+	retries map[string]int
+}
+
+func newRemoteSteps() *remoteSteps {
+	return &remoteSteps{retries: make(map[string]int)}
+}
+
+func (s *remoteSteps) add(event pkgEvents.ExecutionEvent) {
+	var status string
+	switch event.Type {
+	case pkgEvents.EventStepRetrying:
+		s.retries[event.StepID]++
+		return
+	case pkgEvents.EventStepCompleted:
+		status = "completed"
+	case pkgEvents.EventStepFailed, pkgEvents.EventStepTimedOut:
+		status = "failed"
+	case pkgEvents.EventStepSkipped:
+		status = "skipped"
+	default:
+		return
+	}
+
+	s.results = append(s.results, engine.StepExecutionResult{
+		StepID:    event.StepID,
+		Status:    status,
+		StartTime: event.StartTime,
+		EndTime:   event.Timestamp,
+		Duration:  event.Duration,
+		Error:     event.Error,
+		Retries:   s.retries[event.StepID],
+	})
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/remote"
+	"github.com/lacquerai/lacquer/internal/server"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const remoteWorkflow = `version: "1.0"
+metadata:
+  name: remote
+inputs:
+  fail:
+    type: boolean
+    default: false
+workflow:
+  steps:
+    - id: greet
+      run: echo "hello"
+    - id: fail
+      run: exit 1
+      condition: ${{ inputs.fail }}
+  outputs:
+    greeting: ${{ steps.greet.output }}
+`
+
+// startRemoteServer starts a lacquer server which has loaded the workflow
+// as "loaded" and allows workflows to be submitted.
+func startRemoteServer(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	workflowFile := filepath.Join(dir, "loaded.laq.yaml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(remoteWorkflow), 0o600))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	config := server.DefaultConfig()
+	config.Host = "127.0.0.1"
+	config.Port = port
+	config.WorkflowFiles = []string{workflowFile}
+	config.EnableMetrics = false
+	config.EnableUI = false
+	config.EnableScheduler = false
+	config.AllowSubmit = true
+
+	srv, err := server.New(config)
+	require.NoError(t, err)
+	require.NoError(t, srv.LoadWorkflows())
+	require.NoError(t, srv.Start())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Stop(ctx)
+	})
+
+	// give the server time to start listening
+	time.Sleep(100 * time.Millisecond)
+	return "http://" + srv.GetAddr()
+}
+
+func TestRunRemote_StructuredOutput(t *testing.T) {
+	viper.Set("output", "json")
+	defer viper.Set("output", "text")
+
+	client, err := remote.NewClient(startRemoteServer(t), "", nil)
+	require.NoError(t, err)
+
+	workflowFile := filepath.Join(t.TempDir(), "submitted.laq.yaml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(remoteWorkflow), 0o600))
+
+	run := func(target string, fail bool) (RunSummary, string, error) {
+		var stdout, stderr bytes.Buffer
+		err := runRemote(execcontext.RunContext{
+			Context: context.Background(),
+			StdOut:  &stdout,
+			StdErr:  &stderr,
+		}, client, target, map[string]interface{}{"fail": fail})
+
+		var summary RunSummary
+		if stdout.Len() > 0 {
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &summary), stdout.String())
+		}
+		return summary, stderr.String(), err
+	}
+
+	summary, _, err := run(workflowFile, false)
+	require.NoError(t, err)
+	assert.NotEmpty(t, summary.RunID)
+	assert.Equal(t, "completed", summary.Status)
+	assert.Equal(t, map[string]interface{}{"greeting": "hello\n"}, summary.Outputs)
+	// steps which were skipped send no events, only the steps which ran are
+	// summarized
+	require.Len(t, summary.Steps, 1)
+	assert.Equal(t, "greet", summary.Steps[0].ID)
+	assert.Equal(t, "completed", summary.Steps[0].Status)
+
+	summary, stderr, err := run("loaded", true)
+	require.Error(t, err)
+	assert.Equal(t, "failed", summary.Status)
+	assert.NotEmpty(t, summary.Error)
+	require.Len(t, summary.Steps, 2)
+	assert.Equal(t, "failed", summary.Steps[1].Status)
+	assert.Contains(t, stderr, "Error")
+
+	summary, stderr, err = run("missing", false)
+	require.Error(t, err)
+	assert.Empty(t, summary.RunID)
+	assert.Contains(t, stderr, "not found")
+}
+
+func TestValidateRemoteFlags(t *testing.T) {
+	remoteServer = "http://runner:8080"
+	defer func() { remoteServer = "" }()
+	require.NoError(t, validateRemoteFlags())
+
+	watchMode = true
+	defer func() { watchMode = false }()
+	assert.EqualError(t, validateRemoteFlags(), "--remote cannot be combined with --watch")
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	reportBy    string
+	reportSince string
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report on the history of workflow runs",
+	Long:  `Report on the workflow runs recorded on this machine.`,
+}
+
+// reportCostsCmd represents the report costs command
+var reportCostsCmd = &cobra.Command{
+	Use:   "costs",
+	Short: "Report the token usage and estimated cost of runs",
+	Long: `Add up the token usage and estimated cost of the steps of recorded runs by
+cost center, owner or workflow, for chargeback, or by the version of the
+library's prompt used by agent steps, to compare prompt experiments.
+
+Steps are labelled with the cost_center and owner of the step, or otherwise
+those of the workflow's metadata. Steps without a label are reported as
+(unassigned).`,
+	Example: `
+  laq report costs                                 # Costs by cost center over the last 30 days
+  laq report costs --by owner --since 7d           # Costs by owner over the last week
+  laq report costs --by workflow --since 2024-01-01 # Costs by workflow since a date
+  laq report costs --by prompt --since 7d          # Costs by prompt version over the last week
+  laq report costs --output json                   # Costs as JSON`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := newHistoryStore()
+		if err == nil {
+			err = reportCosts(cmd.OutOrStdout(), store, reportBy, reportSince, time.Now())
+		}
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	reportCostsCmd.Flags().StringVar(&reportBy, "by", history.ByCostCenter, fmt.Sprintf("label to group costs by (%s)", strings.Join(history.Groupings, ", ")))
+	reportCostsCmd.Flags().StringVar(&reportSince, "since", "30d", "report runs started since a duration ago (30d, 12h) or a date (2024-01-31)")
+
+	reportCmd.AddCommand(reportCostsCmd)
+	rootCmd.AddCommand(reportCmd)
+}
+
+func reportCosts(w io.Writer, store *history.Store, by, since string, now time.Time) error {
+	start, err := history.ParseSince(since, now)
+	if err != nil {
+		return err
+	}
+
+	runs, err := store.List(start)
+	if err != nil {
+		return err
+	}
+
+	report, err := history.CostReport(runs, by)
+	if err != nil {
+		return err
+	}
+
+	switch viper.GetString("output") {
+	case "json":
+		style.PrintJSON(w, report)
+	case "yaml":
+		style.PrintYAML(w, report)
+	default:
+		printCostReport(w, by, since, report)
+	}
+
+	return nil
+}
+
+func printCostReport(w io.Writer, by, since string, report []*history.CostGroup) {
+	title := "Costs by " + strings.ReplaceAll(by, "_", " ")
+	if since != "" {
+		title += " since " + since
+	}
+	fmt.Fprintln(w, style.TitleStyle.Render(title))
+
+	if len(report) == 0 {
+		fmt.Fprintln(w, style.MutedStyle.Render("No runs found"))
+		return
+	}
+
+	width := len(by)
+	for _, group := range report {
+		width = max(width, len(group.Key))
+	}
+
+	row := fmt.Sprintf("%%-%ds  %%6s  %%6s  %%10s  %%10s", width)
+	fmt.Fprintln(w, style.MutedStyle.Render(fmt.Sprintf(row, strings.ToUpper(by), "RUNS", "STEPS", "TOKENS", "COST")))
+
+	var total history.Usage
+	var steps int
+	for _, group := range report {
+		total.Add(group.Usage)
+		steps += group.Steps
+		fmt.Fprintf(w, row+"\n", group.Key, fmt.Sprint(group.Runs), fmt.Sprint(group.Steps), fmt.Sprint(group.Usage.TotalTokens), formatCost(group.Usage.Cost))
+	}
+
+	fmt.Fprintln(w, style.AccentStyle.Render(fmt.Sprintf(row, "Total", "", fmt.Sprint(steps), fmt.Sprint(total.TotalTokens), formatCost(total.Cost))))
+}
+
+func formatCost(cost float64) string {
+	return fmt.Sprintf("$%.4f", cost)
+}
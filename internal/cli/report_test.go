@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportCosts(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	store := history.NewStore(filepath.Join(t.TempDir(), "history"))
+
+	var out bytes.Buffer
+	require.NoError(t, reportCosts(&out, store, history.ByCostCenter, "30d", now))
+	assert.Equal(t, "Costs by cost center since 30d\nNo runs found\n", re.ReplaceAllString(out.String(), ""))
+
+	require.NoError(t, store.Save(&history.Run{
+		RunID:     "recent",
+		Workflow:  "triage",
+		StartTime: now.AddDate(0, 0, -1),
+		Steps: []history.Step{
+			{StepID: "classify", CostCenter: "support", Usage: history.Usage{TotalTokens: 1200, Cost: 0.012}},
+			{StepID: "notify"},
+		},
+	}))
+	require.NoError(t, store.Save(&history.Run{
+		RunID:     "old",
+		Workflow:  "triage",
+		StartTime: now.AddDate(0, 0, -60),
+		Steps:     []history.Step{{StepID: "classify", CostCenter: "support", Usage: history.Usage{TotalTokens: 1000, Cost: 0.01}}},
+	}))
+
+	out.Reset()
+	require.NoError(t, reportCosts(&out, store, history.ByCostCenter, "30d", now))
+	assert.Equal(t, `Costs by cost center since 30d
+COST_CENTER     RUNS   STEPS      TOKENS        COST
+support            1       1        1200     $0.0120
+(unassigned)       1       1           0     $0.0000
+Total                      2        1200     $0.0120
+`, re.ReplaceAllString(out.String(), ""))
+
+	err := reportCosts(&out, store, "model", "30d", now)
+	assert.EqualError(t, err, `invalid grouping "model", must be one of cost_center, owner, workflow, prompt`)
+
+	err = reportCosts(&out, store, history.ByCostCenter, "last week", now)
+	assert.Error(t, err)
+}
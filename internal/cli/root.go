@@ -24,6 +24,7 @@ var (
 	outputFormat string
 	quiet        bool
 	verbose      bool
+	normalize    string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -39,11 +40,13 @@ Visit https://lacquer.ai/docs for documentation and examples.`,
 		if cmd.Name() != "update" {
 			go triggerBackgroundUpdateCheck()
 		}
+		recordCommandUsage(cmd)
 	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
 		if cmd.Name() != "update" {
 			showUpdateNotificationIfAvailable()
 		}
+		flushUsage()
 	},
 }
 
@@ -81,12 +84,14 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "output format (text, json, yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress non-essential output")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().StringVar(&normalize, "normalize", "none", "unicode normalization applied to step outputs (none, nfc, nfkc)")
 
 	// Bind flags to viper
 	_ = viper.BindPFlag("log-level", rootCmd.PersistentFlags().Lookup("log-level"))
 	_ = viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
 	_ = viper.BindPFlag("quiet", rootCmd.PersistentFlags().Lookup("quiet"))
 	_ = viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	_ = viper.BindPFlag("normalize", rootCmd.PersistentFlags().Lookup("normalize"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -165,6 +170,10 @@ func triggerBackgroundUpdateCheck() {
 	// Create a dummy command for the update check (won't be used for output)
 	dummyCmd := &cobra.Command{}
 
+	ctx, cancel := context.WithTimeout(context.Background(), backgroundCheckTimeout)
+	defer cancel()
+	dummyCmd.SetContext(ctx)
+
 	// This will check for updates and cache the result for future use
 	// It runs silently in the background and doesn't print anything
 	checkForUpdate(dummyCmd, false, false)
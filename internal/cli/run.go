@@ -3,23 +3,33 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/lacquerai/lacquer/internal/cron"
 	"github.com/lacquerai/lacquer/internal/engine"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/kv"
 	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/remote"
 	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/utils"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
 )
 
 // runCmd represents the run command
@@ -34,15 +44,68 @@ This command:
 - Executes workflow steps sequentially with proper error handling
 - Provides real-time progress updates and logging
 - Supports graceful shutdown on interruption signals
+
+With --watch the workflow is re-validated and re-run whenever the workflow
+file or any local script, block or file() it references changes. Results of
+steps which are unchanged since the previous run are reused, and --until-step
+can be used to stop each run after the step being worked on.
+
+A subset of the workflow can be run with --from-step, --until-step and
+--only-step. Steps which are not executed are satisfied using the outputs
+recorded by the previous run of the workflow, or by values provided in a
+fixtures file (JSON or YAML) mapping step IDs to their outputs.
+
+The progress of every run is checkpointed after each step. A run which fails
+or is interrupted can be continued from its last completed step with --resume
+and the run ID, using the inputs it was started with. Steps which have changed
+since the run are executed again.
+
+A run can start from the final state of an earlier run, exported with laq runs
+state export, with --state-file.
+
+With --lock the model aliases of agents, such as claude-sonnet-4, are locked to
+the snapshots they resolve to in a laq.lock next to the workflow, which every
+later run of the workflow uses. A warning is shown when an alias resolves to
+another snapshot upstream, --update-lock locks it to the new snapshot.
+
+Runs started on a schedule, by cron or a CI schedule, can pass the cron
+expression of the schedule with --schedule. Expressions can then read it as
+trigger.schedule and adjust to it, e.g. with cron_matches(), is_weekend() and
+business_days_between().
+
+With --remote the workflow runs on a lacquer server started with laq serve,
+at --server or remote.url in config, authenticating with remote.api_key in
+config or LACQUER_API_KEY. A workflow file is validated and sent to the server,
+which must allow it with laq serve --allow-submit, anything else is the ID of
+a workflow the server has loaded. Its progress is streamed back and shown as
+for a local run, and its outputs are printed once it finishes. Interrupting
+laq stops following the run, which continues on the server.
+
+With --output json or --output yaml no progress is shown, and a summary of the
+run with the status, duration and token usage of each step and the outputs of
+the workflow is printed once it finishes, whether it succeeded or failed.
 `,
 
-	Args: cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if resumeRun != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Example: `
   laq run workflow.laq.yaml                    # Run workflow with default settings
   laq run workflow.laq.yaml --input key=value # Provide input parameters
   laq run workflow.laq.yaml --input-json '{"key": "value"}' # Provide input parameters as JSON
+  laq run workflow.laq.yaml --input-file inputs.yaml # Provide input parameters from a JSON or YAML file
   laq run workflow.laq.yaml --output json     # JSON output for automation
-  laq run workflow.laq.yaml --save-state      # Persist state for debugging`,
+  laq run workflow.laq.yaml --save-state      # Persist state for debugging
+  laq run workflow.laq.yaml --watch --until-step summarize # Re-run up to a step on change
+  laq run workflow.laq.yaml --from-step summarize # Re-use earlier step outputs from the last run
+  laq run workflow.laq.yaml --only-step summarize --fixtures fixtures.yaml # Run a single step
+  laq run workflow.laq.yaml --state-file state.json # Start from the state exported from an earlier run
+  laq run --resume run_1a2b3c4d5e6f7a8b         # Resume a failed run from its last completed step
+  laq run workflow.laq.yaml --remote --server http://runner:8080 # Run the workflow on a lacquer server
+  laq run review --remote --input pr=42         # Run the workflow with ID review loaded by the server in remote.url`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Setup signal handling for graceful shutdown
 		ctx, cancel := context.WithCancel(context.Background())
@@ -57,28 +120,22 @@ This command:
 			cancel()
 		}()
 
-		// Apply timeout if specified
-		if timeout > 0 {
-			ctx, cancel = context.WithTimeout(ctx, timeout)
-			defer cancel()
-		}
-
-		runCtx := execcontext.RunContext{
-			Context: ctx,
-			StdOut:  cmd.OutOrStdout(),
-			StdErr:  cmd.OutOrStderr(),
+		if resumeRun != "" {
+			if err := validateResumeFlags(); err != nil {
+				fmt.Fprintf(cmd.OutOrStderr(), "%s\n", err)
+				os.Exit(1)
+			}
 		}
 
 		inputsMap := make(map[string]interface{})
 
 		if inputFile != "" {
-			file, err := os.Open(inputFile) // #nosec G304 - inputFile is from CLI args
+			var err error
+			inputsMap, err = readInputFile(inputFile)
 			if err != nil {
-				fmt.Fprintf(cmd.OutOrStderr(), "failed to open input file: %s\n", err)
+				fmt.Fprintf(cmd.OutOrStderr(), "%s\n", err)
 				os.Exit(1)
 			}
-			_ = json.NewDecoder(file).Decode(&inputsMap)
-			_ = file.Close()
 		} else if inputJSONRaw != "" {
 			_ = json.Unmarshal([]byte(inputJSONRaw), &inputsMap)
 		}
@@ -87,7 +144,79 @@ This command:
 			inputsMap[k] = v
 		}
 
-		err := runWorkflow(runCtx, args[0], inputsMap)
+		if remoteRun {
+			if err := validateRemoteFlags(); err != nil {
+				fmt.Fprintf(cmd.OutOrStderr(), "%s\n", err)
+				os.Exit(1)
+			}
+
+			client, err := remote.NewClient(remoteServerURL(), remoteAPIKey(), nil)
+			if err != nil {
+				fmt.Fprintf(cmd.OutOrStderr(), "%s\n", err)
+				os.Exit(1)
+			}
+
+			if timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			runCtx := execcontext.RunContext{
+				Context: ctx,
+				StdOut:  cmd.OutOrStdout(),
+				StdErr:  cmd.OutOrStderr(),
+			}
+			if err := runRemote(runCtx, client, args[0], inputsMap); err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+
+		trigger, err := runTrigger()
+		if err != nil {
+			fmt.Fprintf(cmd.OutOrStderr(), "%s\n", err)
+			os.Exit(1)
+		}
+
+		opts, err := runnerOptions()
+		if err != nil {
+			fmt.Fprintf(cmd.OutOrStderr(), "%s\n", err)
+			os.Exit(1)
+		}
+
+		if metricsAddr != "" {
+			metrics, stop, err := serveRunMetrics(metricsAddr)
+			if err != nil {
+				fmt.Fprintf(cmd.OutOrStderr(), "%s\n", err)
+				os.Exit(1)
+			}
+			defer stop()
+			opts = append(opts, engine.WithMetrics(metrics))
+		}
+
+		if watchMode {
+			watchWorkflow(ctx, cmd, args[0], inputsMap, opts)
+			return
+		}
+
+		// Apply timeout if specified
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		runCtx := execcontext.RunContext{
+			Context: ctx,
+			StdOut:  cmd.OutOrStdout(),
+			StdErr:  cmd.OutOrStderr(),
+			Trigger: trigger,
+		}
+
+		if resumeRun != "" {
+			err = resumeWorkflow(runCtx, resumeRun, opts...)
+		} else {
+			err = runWorkflow(runCtx, args[0], inputsMap, opts...)
+		}
 		if err != nil {
 			os.Exit(1)
 		}
@@ -101,6 +230,37 @@ var (
 	inputJSONRaw string
 	maxRetries   int
 	timeout      time.Duration
+
+	// Development flags
+	watchMode     bool
+	watchDebounce time.Duration
+	untilStep     string
+	fromStep      string
+	onlyStep      string
+	fixturesFile  string
+	resumeRun     string
+
+	// State flags
+	stateFile string
+
+	// Output flags
+	previewLength int
+	eventLog      string
+	metricsAddr   string
+
+	// Cost flags
+	budget float64
+
+	// Lock flags
+	lockModels bool
+	updateLock bool
+
+	// Trigger flags
+	schedule string
+
+	// Remote flags
+	remoteRun    bool
+	remoteServer string
 )
 
 func init() {
@@ -109,51 +269,360 @@ func init() {
 	// Input flags
 	runCmd.Flags().StringToStringVarP(&inputs, "input", "i", map[string]string{}, "input parameters (key=value)")
 	runCmd.Flags().StringVarP(&inputJSONRaw, "input-json", "j", "", "input parameters as JSON")
-	runCmd.Flags().StringVarP(&inputFile, "input-file", "f", "", "input parameters from file")
+	runCmd.Flags().StringVarP(&inputFile, "input-file", "f", "", "input parameters from a JSON or YAML file")
 
 	runCmd.Flags().IntVar(&maxRetries, "max-retries", 3, "maximum number of retries for failed steps")
 	runCmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "overall execution timeout")
+
+	// Development flags
+	runCmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "re-run the workflow when it or any referenced file changes")
+	runCmd.Flags().DurationVar(&watchDebounce, "watch-debounce", 300*time.Millisecond, "time to wait for changes to settle before re-running")
+	runCmd.Flags().StringVar(&untilStep, "until-step", "", "stop the workflow after the step with this ID completes")
+	runCmd.Flags().StringVar(&fromStep, "from-step", "", "start the workflow at the step with this ID")
+	runCmd.Flags().StringVar(&onlyStep, "only-step", "", "only run the step with this ID")
+	runCmd.Flags().StringVar(&fixturesFile, "fixtures", "", "file of step outputs to use for steps which are not run")
+	runCmd.Flags().StringVar(&stateFile, "state-file", "", "initial workflow state from a JSON or YAML file, such as one written by laq runs state export")
+	runCmd.Flags().StringVar(&resumeRun, "resume", "", "resume the failed or interrupted run with this ID from its last completed step")
+	runCmd.Flags().Float64Var(&budget, "budget", 0, "cost budget of the run in USD, agents with a tier are routed to cheaper models as it is spent")
+	runCmd.Flags().IntVar(&previewLength, "preview-length", engine.DefaultPreviewLength, "maximum characters of prompt and tool call previews shown while running, 0 for no limit")
+	runCmd.Flags().StringVar(&eventLog, "event-log", "", "file every event of the run is appended to as a JSON line, defaults to event_log in config")
+	runCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "address to expose Prometheus metrics of the run on at /metrics while it runs, e.g. :9090")
+	runCmd.Flags().BoolVar(&lockModels, "lock", false, "lock the model aliases of agents to the snapshots they resolve to in a laq.lock next to the workflow")
+	runCmd.Flags().BoolVar(&updateLock, "update-lock", false, "lock model aliases which resolve to another snapshot than the one in laq.lock to the new snapshot")
+	runCmd.Flags().BoolVar(&remoteRun, "remote", false, "run the workflow on a lacquer server started with laq serve, showing its progress here")
+	runCmd.Flags().StringVar(&remoteServer, "server", "", "URL of the lacquer server --remote runs on, defaults to remote.url in config")
+	runCmd.Flags().StringVar(&schedule, "schedule", "", "cron expression of the schedule the run was started on, e.g. by cron or a CI schedule, available to expressions as trigger.schedule")
 }
 
-func runWorkflow(ctx execcontext.RunContext, workflowFile string, inputs map[string]interface{}) error {
-	runner := engine.NewRunner(engine.NewProgressTracker(ctx.StdOut, "", 0))
-	result, err := runner.RunWorkflow(ctx, workflowFile, inputs)
+// runTrigger returns what started the run, a schedule when --schedule is
+// set.
+func runTrigger() (execcontext.Trigger, error) {
+	if schedule == "" {
+		return execcontext.Trigger{Type: execcontext.TriggerCLI}, nil
+	}
+
+	if _, err := cron.Parse(schedule); err != nil {
+		return execcontext.Trigger{}, fmt.Errorf("invalid --schedule: %w", err)
+	}
+
+	return execcontext.Trigger{Type: execcontext.TriggerSchedule, Schedule: schedule}, nil
+}
+
+// runnerOptions builds the runner options for the partial execution flags.
+func runnerOptions() ([]engine.RunnerOption, error) {
+	cipher, err := newCipher()
 	if err != nil {
-		switch e := err.(type) {
-		case *engine.InputValidationResult:
-			printValidationErrors(ctx, e)
-		case *parser.MultiErrorEnhanced:
-			result := NewValidationResult(workflowFile)
-			result.CollectError(err)
-			summary := ValidationSummary{
-				Total:   1,
-				Results: []ValidationResult{*result},
-				Invalid: 1,
-			}
+		return nil, err
+	}
+
+	opts := []engine.RunnerOption{
+		engine.WithStepStore(engine.NewStepStore(filepath.Join(utils.LacquerCacheDir, "runs"), cipher)),
+		engine.WithRunLog(filepath.Join(utils.LacquerCacheDir, "logs"), cipher),
+		engine.WithRunHistory(newHistoryStoreWithCipher(cipher)),
+		engine.WithCheckpoints(engine.NewCheckpointStore(filepath.Join(utils.LacquerCacheDir, "checkpoints"), cipher)),
+		engine.WithKVStore(kv.NewStore(filepath.Join(utils.LacquerCacheDir, "kv"), cipher)),
+		engine.WithPreviewLength(previewLength),
+		engine.WithBudget(budget),
+		engine.WithCircuitBreakers(provider.DefaultBreakers),
+	}
+
+	if path := eventLogPath(); path != "" {
+		opts = append(opts, engine.WithEventLog(path, cipher))
+	}
+
+	if lockModels || updateLock {
+		opts = append(opts, engine.WithModelLock(updateLock))
+	}
+
+	from, until := fromStep, untilStep
+	if onlyStep != "" {
+		if from != "" || until != "" {
+			return nil, fmt.Errorf("--only-step cannot be combined with --from-step or --until-step")
+		}
+		from, until = onlyStep, onlyStep
+	}
+
+	if from != "" {
+		opts = append(opts, engine.WithFromStep(from))
+	}
+
+	if until != "" {
+		opts = append(opts, engine.WithUntilStep(until))
+	}
+
+	if fixturesFile != "" {
+		fixtures, err := loadStepFixtures(fixturesFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, engine.WithStepFixtures(fixtures))
+	}
+
+	if stateFile != "" {
+		state, err := readStateFile(stateFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, engine.WithInitialState(state))
+	}
+
+	return opts, nil
+}
+
+// eventLogPath is the file events of runs are appended to, from --event-log
+// or event_log in config, environment variables in it are expanded.
+func eventLogPath() string {
+	if eventLog != "" {
+		return eventLog
+	}
+	return os.ExpandEnv(viper.GetString("event_log"))
+}
+
+// validateResumeFlags ensures --resume isn't combined with flags which
+// change what a run does, a resumed run continues as it was started.
+func validateResumeFlags() error {
+	switch {
+	case watchMode:
+		return fmt.Errorf("--resume cannot be combined with --watch")
+	case fromStep != "" || onlyStep != "":
+		return fmt.Errorf("--resume cannot be combined with --from-step or --only-step")
+	case len(inputs) > 0 || inputFile != "" || inputJSONRaw != "":
+		return fmt.Errorf("--resume cannot be combined with inputs, the run continues with the inputs it was started with")
+	case stateFile != "":
+		return fmt.Errorf("--resume cannot be combined with --state-file, the run continues with the state it had")
+	}
+	return nil
+}
+
+// loadStepFixtures reads a JSON or YAML file mapping step IDs to the output
+// each step should be treated as having produced.
+func loadStepFixtures(path string) (map[string]*engine.CachedStep, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path is from CLI args
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures file: %w", err)
+	}
+
+	// YAML is a superset of JSON so a single decoder handles both formats
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures file: %w", err)
+	}
+
+	fixtures := make(map[string]*engine.CachedStep, len(raw))
+	for id, value := range raw {
+		fixtures[id] = engine.NewFixtureStep(id, value)
+	}
+
+	return fixtures, nil
+}
+
+// readStateFile reads the initial state of a run from a JSON or YAML file,
+// such as one written by laq runs state export.
+func readStateFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path is from CLI args
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	// YAML is a superset of JSON so a single decoder handles both formats
+	var state map[string]interface{}
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	return state, nil
+}
+
+// readInputFile reads the inputs of a run from a JSON file, or a YAML file
+// such as one written by laq inputs.
+func readInputFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path is from CLI args
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+
+	inputs := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(data, &inputs)
+	default:
+		err = json.Unmarshal(data, &inputs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input file %s: %w", path, err)
+	}
+
+	if inputs == nil {
+		inputs = make(map[string]interface{})
+	}
+
+	return inputs, nil
+}
+
+func runWorkflow(ctx execcontext.RunContext, workflowFile string, inputs map[string]interface{}, opts ...engine.RunnerOption) error {
+	if !isTerminal(ctx.StdOut) {
+		opts = append(opts, engine.WithPlainEvents())
+	}
+
+	runner := engine.NewRunner(progressListener(ctx), opts...)
+	result, err := runner.RunWorkflow(ctx, workflowFile, inputs)
+	return reportRun(ctx, workflowFile, result, err)
+}
+
+// resumeWorkflow continues the run with the given ID from its checkpoint.
+func resumeWorkflow(ctx execcontext.RunContext, runID string, opts ...engine.RunnerOption) error {
+	if !isTerminal(ctx.StdOut) {
+		opts = append(opts, engine.WithPlainEvents())
+	}
+
+	runner := engine.NewRunner(progressListener(ctx), opts...)
+	result, err := runner.ResumeWorkflow(ctx, runID)
+	return reportRun(ctx, runID, result, err)
+}
 
-			printValidationSummary(ctx, summary)
-		default:
-			printGenericError(ctx, err)
+// progressListener shows the progress of a run, which is hidden when the
+// result of the run is printed as JSON or YAML.
+func progressListener(ctx execcontext.RunContext) pkgEvents.Listener {
+	if structuredOutput() {
+		return &pkgEvents.NoopListener{}
+	}
+	return engine.NewProgressTracker(ctx.StdOut, "", 0)
+}
+
+// structuredOutput reports whether results are printed as JSON or YAML
+// rather than text.
+func structuredOutput() bool {
+	switch viper.GetString("output") {
+	case "json", "yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+// reportRun prints the outcome of a run of the workflow, returning the error
+// of the run.
+func reportRun(ctx execcontext.RunContext, workflowFile string, result *engine.ExecutionResult, err error) error {
+	if result != nil {
+		recordStepUsage(result)
+	}
+
+	// structured output only writes the summary of the run to stdout, so
+	// that it can be parsed, and errors go to stderr. Runs which didn't start,
+	// e.g. because of invalid inputs, have no summary.
+	if structuredOutput() {
+		if err != nil {
+			printRunError(execcontext.RunContext{Context: ctx.Context, StdOut: ctx.StdErr, StdErr: ctx.StdErr}, workflowFile, err)
+		}
+		if result != nil {
+			printRunSummary(ctx.StdOut, newRunSummary(result))
 		}
+		return err
+	}
 
+	if err != nil {
+		printRunError(ctx, workflowFile, err)
 		return err
 	}
 
-	outputResults(ctx, result)
+	printExecutionSummary(ctx, result)
 	return nil
 }
 
-func outputResults(w io.Writer, result *engine.ExecutionResult) {
-	outputFormat := viper.GetString("output")
+// printRunError prints why a run of the workflow failed.
+func printRunError(ctx execcontext.RunContext, workflowFile string, err error) {
+	switch e := err.(type) {
+	case *engine.InputValidationResult:
+		printValidationErrors(ctx, e)
+	case *parser.MultiErrorEnhanced:
+		result := NewValidationResult(workflowFile)
+		result.CollectError(err)
+		summary := ValidationSummary{
+			Total:   1,
+			Results: []ValidationResult{*result},
+			Invalid: 1,
+		}
 
-	switch outputFormat {
-	case "json":
-		style.PrintJSON(w, result.Outputs)
-	case "yaml":
-		style.PrintYAML(w, result.Outputs)
+		printValidationSummary(ctx, summary)
 	default:
-		printExecutionSummary(w, result)
+		printGenericError(ctx, err)
+
+		var triaged *engine.TriagedError
+		if errors.As(err, &triaged) {
+			printFailureTriage(ctx.StdErr, triaged.Triage)
+		}
+
+		var resumable *engine.ResumableError
+		if errors.As(err, &resumable) {
+			fmt.Fprintf(ctx.StdErr, "\n%s\n", style.MutedStyle.Render("Resume the run from its last completed step with: laq run --resume "+resumable.RunID))
+		}
+	}
+}
+
+// RunSummary is the outcome of a run printed by laq run with --output json or
+// --output yaml.
+type RunSummary struct {
+	RunID      string                    `json:"run_id" yaml:"run_id"`
+	Workflow   string                    `json:"workflow" yaml:"workflow"`
+	Status     string                    `json:"status" yaml:"status"`
+	Error      string                    `json:"error,omitempty" yaml:"error,omitempty"`
+	StartTime  time.Time                 `json:"start_time" yaml:"start_time"`
+	EndTime    time.Time                 `json:"end_time" yaml:"end_time"`
+	DurationMs int64                     `json:"duration_ms" yaml:"duration_ms"`
+	Steps      []StepRunSummary          `json:"steps" yaml:"steps"`
+	TokenUsage *engine.TokenUsageSummary `json:"token_usage,omitempty" yaml:"token_usage,omitempty"`
+	Outputs    map[string]interface{}    `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	Triage     *engine.FailureTriage     `json:"triage,omitempty" yaml:"triage,omitempty"`
+}
+
+// StepRunSummary is the outcome of a step of a run.
+type StepRunSummary struct {
+	ID         string             `json:"id" yaml:"id"`
+	Type       string             `json:"type,omitempty" yaml:"type,omitempty"`
+	Status     string             `json:"status" yaml:"status"`
+	Error      string             `json:"error,omitempty" yaml:"error,omitempty"`
+	DurationMs int64              `json:"duration_ms" yaml:"duration_ms"`
+	Retries    int                `json:"retries,omitempty" yaml:"retries,omitempty"`
+	TokenUsage *engine.TokenUsage `json:"token_usage,omitempty" yaml:"token_usage,omitempty"`
+}
+
+// newRunSummary summarizes the result of a run.
+func newRunSummary(result *engine.ExecutionResult) RunSummary {
+	summary := RunSummary{
+		RunID:      result.RunID,
+		Workflow:   result.WorkflowFile,
+		Status:     result.Status,
+		Error:      result.Error,
+		StartTime:  result.StartTime,
+		EndTime:    result.EndTime,
+		DurationMs: result.Duration.Milliseconds(),
+		Steps:      make([]StepRunSummary, 0, len(result.StepResults)),
+		TokenUsage: result.TokenUsage,
+		Outputs:    result.Outputs,
+		Triage:     result.Triage,
+	}
+
+	for _, step := range result.StepResults {
+		summary.Steps = append(summary.Steps, StepRunSummary{
+			ID:         step.StepID,
+			Type:       step.StepType,
+			Status:     step.Status,
+			Error:      step.Error,
+			DurationMs: step.Duration.Milliseconds(),
+			Retries:    step.Retries,
+			TokenUsage: step.TokenUsage,
+		})
 	}
+
+	return summary
+}
+
+func printRunSummary(w io.Writer, summary RunSummary) {
+	if viper.GetString("output") == "yaml" {
+		style.PrintYAML(w, summary)
+		return
+	}
+	style.PrintJSON(w, summary)
 }
 
 func printExecutionSummary(w io.Writer, result *engine.ExecutionResult) {
@@ -229,3 +698,23 @@ func printValidationErrors(w io.Writer, validationResult *engine.InputValidation
 func printGenericError(ctx execcontext.RunContext, err error) {
 	fmt.Fprintf(ctx.StdErr, "\n%s Error: %s\n", style.ErrorIcon(), style.ErrorStyle.Render(err.Error()))
 }
+
+// printFailureTriage prints the diagnosis of a failed run by the workflow's
+// triage agent.
+func printFailureTriage(w io.Writer, triage *engine.FailureTriage) {
+	title := "Triage"
+	if triage.StepID != "" {
+		title += " of step " + triage.StepID
+	}
+
+	fmt.Fprintf(w, "\n%s\n\n", style.TitleStyle.Render(title))
+	fmt.Fprintf(w, "%s\n%s\n\n", style.AccentStyle.Render("Diagnosis"), triage.Diagnosis)
+	fmt.Fprintf(w, "%s\n%s\n", style.AccentStyle.Render("Suggested fix"), triage.SuggestedFix)
+}
+
+// isTerminal reports whether the writer is a terminal, output written
+// anywhere else shouldn't contain any styling.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -22,7 +22,10 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/lacquerai/lacquer/internal/engine"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -165,6 +168,11 @@ func (ts *TestServer) handleNormalMode(w http.ResponseWriter, r *http.Request) {
 	response := responses[index]
 	ts.callIndex[path]++
 
+	if isStreamingRequest(r) {
+		ts.streamResponse(w, response)
+		return
+	}
+
 	// Try to determine if response is JSON
 	var jsonData interface{}
 	if json.Unmarshal([]byte(response), &jsonData) == nil {
@@ -175,6 +183,146 @@ func (ts *TestServer) handleNormalMode(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(response))
 }
 
+// isStreamingRequest reports whether the request asks for the response to
+// be streamed as server-sent events.
+func isStreamingRequest(r *http.Request) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false
+	}
+
+	var request struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(body, &request) == nil && request.Stream
+}
+
+// streamResponse replays a recorded response as the server-sent events the
+// provider would have streamed for it.
+func (ts *TestServer) streamResponse(w http.ResponseWriter, response json.RawMessage) {
+	var events []interface{}
+	var err error
+	switch ts.provider {
+	case "anthropic":
+		events, err = anthropicStreamEvents(response)
+	case "openai":
+		events, err = openaiStreamChunks(response)
+	default:
+		err = fmt.Errorf("streaming isn't supported for provider %s", ts.provider)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		if typed, ok := event.(map[string]interface{}); ok && ts.provider == "anthropic" {
+			_, _ = fmt.Fprintf(w, "event: %s\n", typed["type"])
+		}
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	if ts.provider == "openai" {
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+// anthropicStreamEvents splits a recorded message into the events of the
+// messages streaming API.
+func anthropicStreamEvents(response json.RawMessage) ([]interface{}, error) {
+	var message map[string]interface{}
+	if err := json.Unmarshal(response, &message); err != nil {
+		return nil, err
+	}
+
+	content, _ := message["content"].([]interface{})
+	start := make(map[string]interface{}, len(message))
+	for k, v := range message {
+		start[k] = v
+	}
+	start["content"] = []interface{}{}
+	start["stop_reason"] = nil
+
+	events := []interface{}{map[string]interface{}{"type": "message_start", "message": start}}
+	for i, raw := range content {
+		block, _ := raw.(map[string]interface{})
+		var delta map[string]interface{}
+		switch block["type"] {
+		case "text":
+			delta = map[string]interface{}{"type": "text_delta", "text": block["text"]}
+			block = map[string]interface{}{"type": "text", "text": ""}
+		case "tool_use":
+			input, err := json.Marshal(block["input"])
+			if err != nil {
+				return nil, err
+			}
+			delta = map[string]interface{}{"type": "input_json_delta", "partial_json": string(input)}
+			block = map[string]interface{}{"type": "tool_use", "id": block["id"], "name": block["name"], "input": map[string]interface{}{}}
+		}
+
+		events = append(events, map[string]interface{}{"type": "content_block_start", "index": i, "content_block": block})
+		if delta != nil {
+			events = append(events, map[string]interface{}{"type": "content_block_delta", "index": i, "delta": delta})
+		}
+		events = append(events, map[string]interface{}{"type": "content_block_stop", "index": i})
+	}
+
+	return append(events,
+		map[string]interface{}{
+			"type":  "message_delta",
+			"delta": map[string]interface{}{"stop_reason": message["stop_reason"], "stop_sequence": message["stop_sequence"]},
+			"usage": message["usage"],
+		},
+		map[string]interface{}{"type": "message_stop"},
+	), nil
+}
+
+// openaiStreamChunks splits a recorded chat completion into the chunks of
+// the chat completions streaming API, the last chunk reporting the usage.
+func openaiStreamChunks(response json.RawMessage) ([]interface{}, error) {
+	var completion map[string]interface{}
+	if err := json.Unmarshal(response, &completion); err != nil {
+		return nil, err
+	}
+
+	chunk := func(choices []interface{}, usage interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id":      completion["id"],
+			"object":  "chat.completion.chunk",
+			"created": completion["created"],
+			"model":   completion["model"],
+			"choices": choices,
+			"usage":   usage,
+		}
+	}
+
+	var chunks []interface{}
+	choices, _ := completion["choices"].([]interface{})
+	for _, raw := range choices {
+		choice, _ := raw.(map[string]interface{})
+		message, _ := choice["message"].(map[string]interface{})
+
+		delta := map[string]interface{}{"role": message["role"], "content": message["content"]}
+		if toolCalls, ok := message["tool_calls"].([]interface{}); ok {
+			for i, call := range toolCalls {
+				if call, ok := call.(map[string]interface{}); ok {
+					call["index"] = i
+				}
+			}
+			delta["tool_calls"] = toolCalls
+		}
+
+		chunks = append(chunks,
+			chunk([]interface{}{map[string]interface{}{"index": choice["index"], "delta": delta}}, nil),
+			chunk([]interface{}{map[string]interface{}{"index": choice["index"], "delta": map[string]interface{}{}, "finish_reason": choice["finish_reason"]}}, nil),
+		)
+	}
+
+	return append(chunks, chunk([]interface{}{}, completion["usage"])), nil
+}
+
 // handleCaptureMode acts as a reverse proxy and captures responses
 func (ts *TestServer) handleCaptureMode(w http.ResponseWriter, r *http.Request) {
 	if ts.proxyURL == "" {
@@ -517,3 +665,79 @@ func camelToSnake(s string) string {
 
 	return strings.ToLower(string(result))
 }
+
+func TestPrintFailureTriage(t *testing.T) {
+	var out bytes.Buffer
+	printFailureTriage(&out, &engine.FailureTriage{
+		StepID:       "load",
+		Diagnosis:    "The report file doesn't exist.",
+		SuggestedFix: "Create reports/q3.csv or pass a different path.",
+	})
+
+	assert.Equal(t, `
+Triage of step load
+
+Diagnosis
+The report file doesn't exist.
+
+Suggested fix
+Create reports/q3.csv or pass a different path.
+`, re.ReplaceAllString(out.String(), ""))
+}
+
+func TestRunWorkflow_StructuredOutput(t *testing.T) {
+	viper.Set("output", "json")
+	defer viper.Set("output", "text")
+
+	dir := t.TempDir()
+	workflowFile := filepath.Join(dir, "workflow.laq.yml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(`version: "1.0"
+metadata:
+  name: structured-output
+workflow:
+  steps:
+    - id: greet
+      run: echo "hello"
+    - id: fail
+      run: exit 1
+      condition: ${{ inputs.fail }}
+  outputs:
+    greeting: ${{ steps.greet.output }}
+inputs:
+  fail:
+    type: boolean
+    default: false
+`), 0o600))
+
+	run := func(fail bool) (RunSummary, string, error) {
+		var stdout, stderr bytes.Buffer
+		err := runWorkflow(execcontext.RunContext{
+			Context: context.Background(),
+			StdOut:  &stdout,
+			StdErr:  &stderr,
+		}, workflowFile, map[string]interface{}{"fail": fail})
+
+		// stdout only has the summary of the run
+		var summary RunSummary
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &summary), stdout.String())
+		return summary, stderr.String(), err
+	}
+
+	summary, _, err := run(false)
+	require.NoError(t, err)
+	assert.NotEmpty(t, summary.RunID)
+	assert.Equal(t, "completed", summary.Status)
+	assert.Equal(t, map[string]interface{}{"greeting": "hello\n"}, summary.Outputs)
+	require.Len(t, summary.Steps, 2)
+	assert.Equal(t, "greet", summary.Steps[0].ID)
+	assert.Equal(t, "completed", summary.Steps[0].Status)
+	assert.Equal(t, "skipped", summary.Steps[1].Status)
+
+	summary, stderr, err := run(true)
+	require.Error(t, err)
+	assert.Equal(t, "failed", summary.Status)
+	assert.NotEmpty(t, summary.Error)
+	require.Len(t, summary.Steps, 2)
+	assert.Equal(t, "failed", summary.Steps[1].Status)
+	assert.Contains(t, stderr, "Error")
+}
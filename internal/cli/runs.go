@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	runsExportSince  string
+	runsExportFormat string
+	runsStateFormat  string
+)
+
+// runsCmd represents the runs command
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Manage the history of workflow runs",
+	Long: `Manage the workflow runs recorded on this machine.
+
+Runs are pruned as they're recorded according to the history.max_age and
+history.max_size configuration, by default keeping 90 days and up to 100MB
+of runs. Set either to 0 to keep runs regardless of it.
+
+  history:
+    max_age: 30d
+    max_size: 500MB`,
+}
+
+// runsExportCmd represents the runs export command
+var runsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the history of workflow runs",
+	Long: `Export recorded runs for external analytics.
+
+JSONL has a line with the full record of each run, including its inputs,
+outputs and steps. CSV has a row with the token usage and estimated cost of
+each step of each run.`,
+	Example: `
+  laq runs export --since 30d > runs.jsonl           # Runs of the last 30 days as JSONL
+  laq runs export --since 2024-01-01 --format csv    # Steps of the runs since a date as CSV`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := newHistoryStore()
+		if err == nil {
+			err = exportRuns(cmd.OutOrStdout(), store, runsExportSince, runsExportFormat, time.Now())
+		}
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// runsPruneCmd represents the runs prune command
+var runsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove runs according to the retention configuration",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// records are removed without being read so no key is needed
+		store := history.NewStore(filepath.Join(utils.LacquerCacheDir, "history"),
+			history.WithRunLogs(filepath.Join(utils.LacquerCacheDir, "logs")),
+			history.WithStepResults(filepath.Join(utils.LacquerCacheDir, "runs")),
+		)
+		removed, err := store.Prune(historyRetention(), time.Now())
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+
+		if !viper.GetBool("quiet") {
+			style.Success(cmd.OutOrStdout(), fmt.Sprintf("Removed %d runs", removed))
+		}
+	},
+}
+
+// runsStateCmd represents the runs state command
+var runsStateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage the final state of workflow runs",
+}
+
+// runsStateExportCmd represents the runs state export command
+var runsStateExportCmd = &cobra.Command{
+	Use:   "export <run_id>",
+	Short: "Export the final state of a completed run",
+	Long: `Export the final state of a completed run as JSON or YAML.
+
+The exported file can seed the state of another run with laq run --state-file,
+so that incremental pipelines, such as a daily run which remembers the IDs it
+has already processed, can carry their state from one run to the next.
+
+The state is recorded by runs which complete, unless the workflow's
+persistence is metadata or none.`,
+	Example: `
+  laq runs state export run_1a2b3c4d5e6f7a8b > state.json       # Export the state of a run
+  laq run daily.laq.yml --state-file state.json                  # Seed the next run with it`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := newHistoryStore()
+		if err == nil {
+			err = exportRunState(cmd.OutOrStdout(), store, args[0], runsStateFormat)
+		}
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	runsStateExportCmd.Flags().StringVar(&runsStateFormat, "format", "json", "export format (json, yaml)")
+	runsStateCmd.AddCommand(runsStateExportCmd)
+
+	runsExportCmd.Flags().StringVar(&runsExportSince, "since", "", "export runs started since a duration ago (30d, 12h) or a date (2024-01-31), defaults to every run")
+	runsExportCmd.Flags().StringVar(&runsExportFormat, "format", history.FormatJSONL, fmt.Sprintf("export format (%s)", strings.Join(history.ExportFormats, ", ")))
+
+	runsCmd.AddCommand(runsExportCmd, runsPruneCmd, runsStateCmd)
+	rootCmd.AddCommand(runsCmd)
+}
+
+// newHistoryStore opens the run history with the configured retention and
+// encryption.
+func newHistoryStore() (*history.Store, error) {
+	cipher, err := newCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	return newHistoryStoreWithCipher(cipher), nil
+}
+
+func newHistoryStoreWithCipher(cipher *encryption.Cipher) *history.Store {
+	return history.NewStore(filepath.Join(utils.LacquerCacheDir, "history"),
+		history.WithRetention(historyRetention()),
+		history.WithRunLogs(filepath.Join(utils.LacquerCacheDir, "logs")),
+		history.WithStepResults(filepath.Join(utils.LacquerCacheDir, "runs")),
+		history.WithCipher(cipher),
+	)
+}
+
+// historyRetention reads the retention of the run history from the
+// configuration.
+func historyRetention() history.Retention {
+	return parseHistoryRetention(viper.GetViper())
+}
+
+// parseHistoryRetention reads the retention of the run history from config,
+// using the default for limits which aren't configured.
+func parseHistoryRetention(config *viper.Viper) history.Retention {
+	retention := history.DefaultRetention
+
+	if config.IsSet("history.max_age") {
+		age, err := history.ParseMaxAge(config.GetString("history.max_age"))
+		if err != nil {
+			style.Warning(os.Stderr, fmt.Sprintf("ignoring invalid history.max_age configuration: %s", err))
+		} else {
+			retention.MaxAge = age
+		}
+	}
+
+	if config.IsSet("history.max_size") {
+		size, err := history.ParseSize(config.GetString("history.max_size"))
+		if err != nil {
+			style.Warning(os.Stderr, fmt.Sprintf("ignoring invalid history.max_size configuration: %s", err))
+		} else {
+			retention.MaxSize = size
+		}
+	}
+
+	return retention
+}
+
+func exportRuns(w io.Writer, store *history.Store, since, format string, now time.Time) error {
+	start, err := history.ParseSince(since, now)
+	if err != nil {
+		return err
+	}
+
+	runs, err := store.List(start)
+	if err != nil {
+		return err
+	}
+
+	return history.Export(w, runs, format)
+}
+
+// exportRunState writes the final state of the run in the given format.
+func exportRunState(w io.Writer, store *history.Store, runID, format string) error {
+	run, err := store.Get(runID)
+	if err != nil {
+		return err
+	}
+
+	if run.Status != "completed" {
+		return fmt.Errorf("run %s did not complete, only completed runs record their final state", runID)
+	}
+	if run.State == nil {
+		return fmt.Errorf("run %s recorded no state, its workflow has no state or doesn't persist the data of its runs", runID)
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(run.State)
+	case "yaml":
+		return yaml.NewEncoder(w).Encode(run.State)
+	default:
+		return fmt.Errorf("unsupported format %q, use json or yaml", format)
+	}
+}
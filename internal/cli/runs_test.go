@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRuns(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	store := history.NewStore(filepath.Join(t.TempDir(), "history"))
+
+	for i, id := range []string{"recent", "old"} {
+		require.NoError(t, store.Save(&history.Run{
+			RunID:     id,
+			Workflow:  "triage",
+			StartTime: now.AddDate(0, 0, -40*i),
+			Steps:     []history.Step{{StepID: "classify", CostCenter: "support", Usage: history.Usage{TotalTokens: 100, Cost: 0.01}}},
+		}))
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, exportRuns(&out, store, "30d", history.FormatCSV, now))
+	assert.Equal(t, `run_id,workflow,workflow_file,status,start_time,end_time,duration_ms,step_id,step_status,step_duration_ms,cost_center,owner,prompt,prompt_tokens,completion_tokens,total_tokens,cost
+recent,triage,,,2024-03-31T12:00:00Z,,0,classify,,0,support,,,0,0,100,0.01
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, exportRuns(&out, store, "", history.FormatJSONL, now))
+	assert.Equal(t, 2, bytes.Count(out.Bytes(), []byte("\n")))
+
+	assert.Error(t, exportRuns(&out, store, "", "xml", now))
+}
+
+func TestExportRunState(t *testing.T) {
+	store := history.NewStore(filepath.Join(t.TempDir(), "history"))
+	require.NoError(t, store.Save(&history.Run{
+		RunID:  "daily",
+		Status: "completed",
+		State:  map[string]interface{}{"processed": []interface{}{"a1", "b2"}},
+	}))
+	require.NoError(t, store.Save(&history.Run{RunID: "failed", Status: "failed"}))
+	require.NoError(t, store.Save(&history.Run{RunID: "redacted", Status: "completed"}))
+
+	var out bytes.Buffer
+	require.NoError(t, exportRunState(&out, store, "daily", "json"))
+	assert.JSONEq(t, `{"processed": ["a1", "b2"]}`, out.String())
+
+	out.Reset()
+	require.NoError(t, exportRunState(&out, store, "daily", "yaml"))
+	assert.Equal(t, "processed:\n    - a1\n    - b2\n", out.String())
+
+	// the exported state can be read back with --state-file
+	path := filepath.Join(t.TempDir(), "state.yml")
+	require.NoError(t, os.WriteFile(path, out.Bytes(), 0600))
+	state, err := readStateFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"processed": []interface{}{"a1", "b2"}}, state)
+
+	assert.ErrorContains(t, exportRunState(&out, store, "failed", "json"), "did not complete")
+	assert.ErrorContains(t, exportRunState(&out, store, "redacted", "json"), "recorded no state")
+	assert.ErrorContains(t, exportRunState(&out, store, "missing", "json"), "no run found")
+	assert.ErrorContains(t, exportRunState(&out, store, "daily", "xml"), "unsupported format")
+}
+
+func TestParseHistoryRetention(t *testing.T) {
+	config := viper.New()
+	assert.Equal(t, history.DefaultRetention, parseHistoryRetention(config))
+
+	config.Set("history.max_age", "30d")
+	config.Set("history.max_size", "0")
+	assert.Equal(t, history.Retention{MaxAge: 30 * 24 * time.Hour}, parseHistoryRetention(config))
+
+	config.Set("history.max_age", "forever")
+	assert.Equal(t, history.DefaultRetention.MaxAge, parseHistoryRetention(config).MaxAge)
+}
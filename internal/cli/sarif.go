@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"path/filepath"
+
+	"github.com/lacquerai/lacquer/internal/parser"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// sarifLog is a SARIF 2.1.0 log, as read by code scanning tools such as
+// GitHub's, with the subset of the format validate reports.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	HelpURI          string       `json:"helpUri,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// newSARIFLog converts diagnostics into a SARIF log with a single run of
+// laq, listing every rule so that results can refer to them by index.
+func newSARIFLog(diagnostics []parser.Diagnostic) sarifLog {
+	rules := make([]sarifRule, 0, len(parser.Rules))
+	indexes := make(map[string]int, len(parser.Rules))
+	for i, rule := range parser.Rules {
+		rules = append(rules, sarifRule{
+			ID:               rule.ID,
+			ShortDescription: sarifMessage{Text: rule.Description},
+			HelpURI:          parser.DocsURL,
+		})
+		indexes[rule.ID] = i
+	}
+
+	results := make([]sarifResult, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		index, ok := indexes[diagnostic.Rule]
+		if !ok {
+			index = len(rules)
+			indexes[diagnostic.Rule] = index
+			rules = append(rules, sarifRule{ID: diagnostic.Rule, ShortDescription: sarifMessage{Text: diagnostic.Rule}})
+		}
+
+		location := sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(diagnostic.File)},
+		}
+		if diagnostic.Line > 0 {
+			location.Region = &sarifRegion{
+				StartLine:   diagnostic.Line,
+				StartColumn: diagnostic.Column,
+				EndColumn:   diagnostic.EndColumn,
+			}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    diagnostic.Rule,
+			RuleIndex: index,
+			Level:     sarifLevel(diagnostic.Severity),
+			Message:   sarifMessage{Text: diagnostic.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "laq",
+				Version:        Version,
+				InformationURI: parser.DocsURL,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+func sarifLevel(severity parser.ErrorSeverity) string {
+	switch severity {
+	case parser.SeverityError:
+		return "error"
+	case parser.SeverityWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
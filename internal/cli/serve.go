@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lacquerai/lacquer/internal/engine"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/history"
 	"github.com/lacquerai/lacquer/internal/server"
 	"github.com/lacquerai/lacquer/internal/style"
 	"github.com/spf13/cobra"
@@ -24,6 +27,27 @@ var (
 	serveWorkflowDir string
 	serveMetrics     bool
 	serveCORS        bool
+	serveWebUI       bool
+	serveMaxWait     time.Duration
+	serveWatch       bool
+	serveScheduler   bool
+	serveAllowSubmit bool
+
+	// Load shedding
+	serveMaxMemoryUsage       float64
+	serveMaxDiskUsage         float64
+	serveMaxProviderErrorRate float64
+	serveProviderErrorWindow  time.Duration
+
+	// Events
+	servePreviewLength int
+	serveEventLogDir   string
+
+	// Persistence
+	serveStore string
+
+	// Authentication
+	serveAPIKeysFile string
 )
 
 // serveCmd represents the serve command
@@ -42,6 +66,9 @@ The server provides:
   laq serve workflow.laq.yaml                    # Serve single workflow
   laq serve workflow1.laq.yaml workflow2.laq.yaml # Serve multiple workflows  
   laq serve --workflow-dir ./workflows          # Serve all workflows in directory
+  laq serve --watch --workflow-dir ./workflows  # Reload workflows when they change
+  laq serve --scheduler=false workflow.laq.yaml # Don't run workflows on their schedules
+  laq serve --allow-submit workflow.laq.yaml    # Also run workflows sent by laq run --remote
   laq serve --port 8080 --host 0.0.0.0         # Custom host and port
   laq serve --concurrency 10 workflow.laq.yaml # Allow 10 concurrent executions`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -51,23 +78,24 @@ The server provides:
 			StdErr:  cmd.OutOrStderr(),
 		}
 
-		// Collect workflow files from args and directory
-		workflowFiles := args
+		// The server finds the workflow files in the directory itself, so
+		// that files added to it are picked up when workflows are reloaded
+		workflowFiles := append(args, serveWorkflows...)
+		found := len(workflowFiles)
 		if serveWorkflowDir != "" {
 			dirFiles, err := findWorkflowFiles(serveWorkflowDir)
 			if err != nil {
 				style.Error(runCtx, fmt.Sprintf("Failed to scan workflow directory: %v", err))
 				os.Exit(1)
 			}
-			workflowFiles = append(workflowFiles, dirFiles...)
+			found += len(dirFiles)
 		}
 
-		if len(workflowFiles) == 0 && len(serveWorkflows) == 0 {
+		if found == 0 {
 			style.Error(runCtx, "No workflow files specified. Use arguments or --workflow-dir")
 			os.Exit(1)
 		}
 
-		workflowFiles = append(workflowFiles, serveWorkflows...)
 		startServer(runCtx, workflowFiles)
 	},
 }
@@ -80,6 +108,7 @@ func init() {
 	serveCmd.Flags().StringVar(&serveHost, "host", "localhost", "server host")
 	serveCmd.Flags().IntVar(&serveConcurrency, "concurrency", 5, "maximum concurrent executions")
 	serveCmd.Flags().DurationVar(&serveTimeout, "timeout", 30*time.Minute, "default execution timeout")
+	serveCmd.Flags().DurationVar(&serveMaxWait, "max-wait", server.DefaultMaxWait, "maximum time a request to execute a workflow with wait=true waits for the run to finish")
 
 	// Workflow specification
 	serveCmd.Flags().StringSliceVarP(&serveWorkflows, "workflow", "w", []string{}, "workflow files to serve")
@@ -88,19 +117,71 @@ func init() {
 	// Features
 	serveCmd.Flags().BoolVar(&serveMetrics, "metrics", true, "enable Prometheus metrics endpoint")
 	serveCmd.Flags().BoolVar(&serveCORS, "cors", true, "enable CORS headers")
+	serveCmd.Flags().BoolVar(&serveWebUI, "ui", true, "serve the web UI for the run history at /ui/")
+	serveCmd.Flags().BoolVar(&serveWatch, "watch", false, "reload workflow files when they change, defaults to serve.watch in config")
+	serveCmd.Flags().BoolVar(&serveScheduler, "scheduler", true, "run workflows with a schedule in their metadata on their cron expressions")
+	serveCmd.Flags().BoolVar(&serveAllowSubmit, "allow-submit", false, "let clients with the execute scope run workflows they send, such as laq run --remote, defaults to serve.allow_submit in config")
+	serveCmd.Flags().IntVar(&servePreviewLength, "preview-length", engine.DefaultPreviewLength, "maximum characters of prompt and tool call previews in streamed events, 0 for no limit")
+	serveCmd.Flags().StringVar(&serveEventLogDir, "event-log-dir", "", "directory the events of each run are written to as <run_id>.jsonl, defaults to serve.event_log_dir in config")
+	serveCmd.Flags().StringVar(&serveAPIKeysFile, "api-keys-file", "", "YAML file of the API keys clients authenticate with and their scopes, defaults to serve.auth.api_keys_file in config")
+	serveCmd.Flags().StringVar(&serveStore, "store", "", "database executions are persisted to, sqlite://<path> or postgres://<connection>, defaults to serve.store in config")
+
+	// Load shedding
+	serveCmd.Flags().Float64Var(&serveMaxMemoryUsage, "max-memory-usage", 0, "refuse new executions while more than this percentage of system memory is in use, 0 to disable")
+	serveCmd.Flags().Float64Var(&serveMaxDiskUsage, "max-disk-usage", 0, "refuse new executions while more than this percentage of the disk holding the lacquer cache is in use, 0 to disable")
+	serveCmd.Flags().Float64Var(&serveMaxProviderErrorRate, "max-provider-error-rate", 0, "refuse new executions while the error rate of a model provider is above this fraction between 0 and 1, 0 to disable")
+	serveCmd.Flags().DurationVar(&serveProviderErrorWindow, "provider-error-window", server.DefaultProviderErrorWindow, "period provider error rates are measured over")
 }
 
 func startServer(runCtx execcontext.RunContext, workflowFiles []string) {
+	cipher, err := newCipher()
+	if err != nil {
+		style.Error(runCtx, err.Error())
+		os.Exit(1)
+	}
+
+	auth, err := parseServerAuth(viper.GetViper(), serveAPIKeysFile)
+	if err != nil {
+		style.Error(runCtx, err.Error())
+		os.Exit(1)
+	}
+
+	notifications, err := parseNotifications(viper.GetViper())
+	if err != nil {
+		style.Error(runCtx, err.Error())
+		os.Exit(1)
+	}
+
 	// Create server configuration
 	config := &server.Config{
-		Host:          serveHost,
-		Port:          servePort,
-		Concurrency:   serveConcurrency,
-		Timeout:       serveTimeout,
-		EnableMetrics: serveMetrics,
-		EnableCORS:    serveCORS,
-		WorkflowFiles: workflowFiles,
-		WorkflowDir:   serveWorkflowDir,
+		Host:               serveHost,
+		Port:               servePort,
+		Concurrency:        serveConcurrency,
+		Timeout:            serveTimeout,
+		EnableMetrics:      serveMetrics,
+		EnableCORS:         serveCORS,
+		EnableUI:           serveWebUI,
+		WorkflowFiles:      workflowFiles,
+		WorkflowDir:        serveWorkflowDir,
+		PreviewLength:      servePreviewLength,
+		HistoryRetention:   historyRetention(),
+		HistoryCipher:      cipher,
+		MaxWait:            serveMaxWait,
+		CallbackSecret:     callbackSecret(),
+		EventLogDir:        eventLogDir(),
+		ExecutionStore:     executionStore(),
+		ExecutionRetention: parseExecutionRetention(viper.GetViper()),
+		WatchWorkflows:     serveWatch || viper.GetBool("serve.watch"),
+		EnableScheduler:    serveScheduler,
+		AllowSubmit:        serveAllowSubmit || viper.GetBool("serve.allow_submit"),
+		Auth:               auth,
+		Notifications:      notifications,
+		LoadShedding: server.LoadShedding{
+			MaxMemoryUsage:       serveMaxMemoryUsage,
+			MaxDiskUsage:         serveMaxDiskUsage,
+			MaxProviderErrorRate: serveMaxProviderErrorRate,
+			ProviderErrorWindow:  serveProviderErrorWindow,
+		},
 	}
 
 	// Create server
@@ -121,9 +202,21 @@ func startServer(runCtx execcontext.RunContext, workflowFiles []string) {
 		style.Success(runCtx, fmt.Sprintf("Lacquer server starting at http://%s", srv.GetAddr()))
 		fmt.Fprintf(runCtx, "📋 Loaded workflows: %d\n", srv.GetWorkflowCount())
 		fmt.Fprintf(runCtx, "🚀 API: http://%s/api/v1/workflows\n", srv.GetAddr())
+		if config.WatchWorkflows {
+			fmt.Fprintf(runCtx, "👀 Reloading workflows when they change\n")
+		}
+		if config.AllowSubmit {
+			fmt.Fprintf(runCtx, "📨 Running submitted workflows\n")
+		}
+		if serveScheduler {
+			fmt.Fprintf(runCtx, "⏰ Schedules: http://%s/api/v1/schedules\n", srv.GetAddr())
+		}
 		if serveMetrics {
 			fmt.Fprintf(runCtx, "📊 Metrics: http://%s/metrics\n", srv.GetAddr())
 		}
+		if serveWebUI {
+			fmt.Fprintf(runCtx, "🔎 Runs: http://%s/ui/\n", srv.GetAddr())
+		}
 	}
 
 	// Start server with graceful shutdown
@@ -133,6 +226,109 @@ func startServer(runCtx execcontext.RunContext, workflowFiles []string) {
 	}
 }
 
+// callbackSecret reads the secret callbacks are signed with from config,
+// environment variables in it are expanded, falling back to
+// LACQUER_CALLBACK_SECRET.
+func callbackSecret() string {
+	if secret := os.ExpandEnv(viper.GetString("serve.callback_secret")); secret != "" {
+		return secret
+	}
+	return os.Getenv("LACQUER_CALLBACK_SECRET")
+}
+
+// eventLogDir is the directory the events of the server's runs are written
+// to, from --event-log-dir or serve.event_log_dir in config.
+func eventLogDir() string {
+	if serveEventLogDir != "" {
+		return serveEventLogDir
+	}
+	return os.ExpandEnv(viper.GetString("serve.event_log_dir"))
+}
+
+// executionStore is the URL of the database the server's executions are
+// persisted to, from --store or serve.store in config, where environment
+// variables are expanded.
+func executionStore() string {
+	if serveStore != "" {
+		return serveStore
+	}
+	return os.ExpandEnv(viper.GetString("serve.store"))
+}
+
+// parseExecutionRetention reads the retention of the execution store from
+// config, using the default for limits which aren't configured.
+func parseExecutionRetention(config *viper.Viper) server.ExecutionRetention {
+	retention := server.DefaultExecutionRetention
+
+	if config.IsSet("serve.retention.max_age") {
+		age, err := history.ParseMaxAge(config.GetString("serve.retention.max_age"))
+		if err != nil {
+			style.Warning(os.Stderr, fmt.Sprintf("ignoring invalid serve.retention.max_age configuration: %s", err))
+		} else {
+			retention.MaxAge = age
+		}
+	}
+
+	if config.IsSet("serve.retention.max_executions") {
+		count, err := strconv.Atoi(config.GetString("serve.retention.max_executions"))
+		if err != nil || count < 0 {
+			style.Warning(os.Stderr, fmt.Sprintf("ignoring invalid serve.retention.max_executions configuration: %q is not a number of at least 0", config.GetString("serve.retention.max_executions")))
+		} else {
+			retention.MaxExecutions = count
+		}
+	}
+
+	return retention
+}
+
+// parseServerAuth reads the API keys and the secret of bearer tokens the
+// server authenticates clients with. API keys are read from apiKeysFile,
+// or serve.auth.api_keys_file in config, along with LACQUER_API_KEY which is
+// an admin key. The secret is serve.auth.token_secret in config, where
+// environment variables are expanded, falling back to LACQUER_TOKEN_SECRET.
+func parseServerAuth(config *viper.Viper, apiKeysFile string) (server.Auth, error) {
+	var auth server.Auth
+
+	if apiKeysFile == "" {
+		apiKeysFile = os.ExpandEnv(config.GetString("serve.auth.api_keys_file"))
+	}
+	if apiKeysFile != "" {
+		keys, err := server.LoadAPIKeys(apiKeysFile)
+		if err != nil {
+			return auth, err
+		}
+		auth.APIKeys = keys
+	}
+
+	if key := os.Getenv("LACQUER_API_KEY"); key != "" {
+		auth.APIKeys = append(auth.APIKeys, server.APIKey{Name: "LACQUER_API_KEY", Key: key, Scopes: []server.Scope{server.ScopeAdmin}})
+	}
+
+	auth.TokenSecret = os.ExpandEnv(config.GetString("serve.auth.token_secret"))
+	if auth.TokenSecret == "" {
+		auth.TokenSecret = os.Getenv("LACQUER_TOKEN_SECRET")
+	}
+
+	return auth, nil
+}
+
+// parseNotifications reads the webhooks notified when runs finish from
+// serve.notifications in config, where environment variables in their URLs
+// and secrets are expanded.
+func parseNotifications(config *viper.Viper) (server.Notifications, error) {
+	var notifications server.Notifications
+	if err := config.UnmarshalKey("serve.notifications", &notifications); err != nil {
+		return notifications, fmt.Errorf("invalid serve.notifications configuration: %w", err)
+	}
+
+	notifications = notifications.ExpandEnv()
+	if err := notifications.Validate(); err != nil {
+		return notifications, fmt.Errorf("invalid serve.notifications configuration: %w", err)
+	}
+
+	return notifications, nil
+}
+
 // findWorkflowFiles finds workflow files in a directory
 func findWorkflowFiles(dir string) ([]string, error) {
 	var files []string
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/server"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExecutionRetention(t *testing.T) {
+	config := viper.New()
+	assert.Equal(t, server.DefaultExecutionRetention, parseExecutionRetention(config))
+
+	config.Set("serve.retention.max_age", "7d")
+	config.Set("serve.retention.max_executions", 1000)
+	assert.Equal(t, server.ExecutionRetention{MaxAge: 7 * 24 * time.Hour, MaxExecutions: 1000}, parseExecutionRetention(config))
+
+	config.Set("serve.retention.max_executions", "lots")
+	assert.Equal(t, server.DefaultExecutionRetention.MaxExecutions, parseExecutionRetention(config).MaxExecutions)
+}
+
+func TestParseServerAuth(t *testing.T) {
+	t.Setenv("LACQUER_API_KEY", "")
+	t.Setenv("LACQUER_TOKEN_SECRET", "")
+
+	config := viper.New()
+	auth, err := parseServerAuth(config, "")
+	require.NoError(t, err)
+	assert.False(t, auth.Enabled())
+
+	path := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("keys:\n  - name: ci\n    key: ci-key\n    scopes: [execute]\n"), 0600))
+	config.Set("serve.auth.api_keys_file", path)
+	config.Set("serve.auth.token_secret", "${LACQUER_TEST_SECRET}")
+	t.Setenv("LACQUER_TEST_SECRET", "secret")
+	t.Setenv("LACQUER_API_KEY", "admin-key")
+
+	auth, err = parseServerAuth(config, "")
+	require.NoError(t, err)
+	assert.Equal(t, []server.APIKey{
+		{Name: "ci", Key: "ci-key", Scopes: []server.Scope{server.ScopeExecute}},
+		{Name: "LACQUER_API_KEY", Key: "admin-key", Scopes: []server.Scope{server.ScopeAdmin}},
+	}, auth.APIKeys)
+	assert.Equal(t, "secret", auth.TokenSecret)
+
+	_, err = parseServerAuth(config, filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestParseNotifications(t *testing.T) {
+	config := viper.New()
+	notifications, err := parseNotifications(config)
+	require.NoError(t, err)
+	assert.Empty(t, notifications.Webhooks)
+
+	t.Setenv("LACQUER_TEST_WEBHOOK_SECRET", "secret")
+	config.Set("serve.notifications.webhooks", []map[string]any{
+		{"name": "chat", "url": "https://hooks.example.com/runs", "secret": "${LACQUER_TEST_WEBHOOK_SECRET}", "on": []string{"failed"}, "payload": map[string]string{"text": "${{ workflow_id }} failed"}},
+	})
+	notifications, err = parseNotifications(config)
+	require.NoError(t, err)
+	assert.Equal(t, []server.Webhook{
+		{Name: "chat", URL: "https://hooks.example.com/runs", Secret: "secret", On: []string{"failed"}, Payload: map[string]string{"text": "${{ workflow_id }} failed"}},
+	}, notifications.Webhooks)
+
+	config.Set("serve.notifications.webhooks", []map[string]any{{"url": "hooks.example.com"}})
+	_, err = parseNotifications(config)
+	assert.EqualError(t, err, "invalid serve.notifications configuration: invalid webhook 1: invalid url 'hooks.example.com', must be an absolute http or https URL")
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/telemetry"
+	"github.com/lacquerai/lacquer/internal/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// telemetryFlushTimeout limits how long reporting usage may delay a command
+// from exiting.
+const telemetryFlushTimeout = 2 * time.Second
+
+// telemetryCmd represents the telemetry command
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Manage anonymous usage metrics",
+	Long: `Manage the anonymous usage metrics which help the maintainers prioritize
+features. Telemetry is disabled until you enable it.
+
+When enabled, Lacquer counts which commands are run and the types of the steps
+of the workflows you run, and reports the counts once a day along with the
+version, operating system and a random install ID. No workflow content,
+inputs, outputs, prompts, file names, errors or API keys are ever collected.
+
+Setting DO_NOT_TRACK=1 or LACQUER_TELEMETRY=off disables telemetry even when
+it's enabled.`,
+}
+
+var telemetryEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Opt in to anonymous usage metrics",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := newTelemetryCollector().SetEnabled(true); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+		style.Success(cmd.OutOrStdout(), "Telemetry enabled, thank you! Run laq telemetry show to see what's reported")
+	},
+}
+
+var telemetryDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Opt out of anonymous usage metrics",
+	Long:  `Opt out of anonymous usage metrics, discarding any usage which hasn't been reported.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := newTelemetryCollector().SetEnabled(false); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+		style.Success(cmd.OutOrStdout(), "Telemetry disabled")
+	},
+}
+
+var telemetryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether anonymous usage metrics are enabled",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := telemetryStatus(cmd.OutOrStdout(), newTelemetryCollector()); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var telemetryShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Preview the usage which would be reported",
+	Long:  `Print the usage recorded since the last report, exactly as it would be sent.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := telemetryShow(cmd.OutOrStdout(), newTelemetryCollector()); err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	telemetryCmd.AddCommand(telemetryEnableCmd, telemetryDisableCmd, telemetryStatusCmd, telemetryShowCmd)
+	rootCmd.AddCommand(telemetryCmd)
+}
+
+// newTelemetryCollector creates the collector of this installation, the
+// endpoint can be changed with telemetry.endpoint.
+func newTelemetryCollector() *telemetry.Collector {
+	endpoint := viper.GetString("telemetry.endpoint")
+	if endpoint == "" {
+		endpoint = telemetry.DefaultEndpoint
+	}
+
+	return telemetry.NewCollector(filepath.Join(utils.LacquerRootDir, "telemetry.json"), endpoint, Version)
+}
+
+func telemetryStatus(w io.Writer, collector *telemetry.Collector) error {
+	state, err := collector.Load()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case state.Enabled && telemetry.DisabledByEnv():
+		style.Info(w, "Telemetry is enabled but disabled by DO_NOT_TRACK or LACQUER_TELEMETRY")
+	case state.Enabled:
+		style.Info(w, "Telemetry is enabled")
+		if !state.LastSent.IsZero() {
+			fmt.Fprintln(w, style.MutedStyle.Render("Last reported "+state.LastSent.Local().Format(time.RFC1123)))
+		}
+	default:
+		style.Info(w, "Telemetry is disabled, run laq telemetry enable to opt in")
+	}
+
+	return nil
+}
+
+func telemetryShow(w io.Writer, collector *telemetry.Collector) error {
+	state, err := collector.Load()
+	if err != nil {
+		return err
+	}
+
+	if !state.Enabled {
+		style.Info(w, "Telemetry is disabled, nothing is recorded or reported")
+		return nil
+	}
+
+	if viper.GetString("output") == "yaml" {
+		style.PrintYAML(w, state.Pending)
+		return nil
+	}
+
+	style.PrintJSON(w, state.Pending)
+	return nil
+}
+
+// recordCommandUsage counts the command when telemetry is enabled.
+func recordCommandUsage(cmd *cobra.Command) {
+	if !cmd.HasParent() {
+		return
+	}
+
+	command := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if err := newTelemetryCollector().RecordCommand(command); err != nil {
+		log.Debug().Err(err).Msg("Failed to record command usage")
+	}
+}
+
+// recordStepUsage counts the types of the steps of a run when telemetry is
+// enabled.
+func recordStepUsage(result *engine.ExecutionResult) {
+	types := make([]string, 0, len(result.StepResults))
+	for _, step := range result.StepResults {
+		if step.StepType != "" {
+			types = append(types, step.StepType)
+		}
+	}
+
+	if err := newTelemetryCollector().RecordStepTypes(types); err != nil {
+		log.Debug().Err(err).Msg("Failed to record step usage")
+	}
+}
+
+// flushUsage reports the recorded usage when it's due.
+func flushUsage() {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+	defer cancel()
+
+	if err := newTelemetryCollector().Flush(ctx, time.Now()); err != nil {
+		log.Debug().Err(err).Msg("Failed to report usage")
+	}
+}
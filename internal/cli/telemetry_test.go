@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetryShow(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("LACQUER_TELEMETRY", "")
+
+	collector := telemetry.NewCollector(filepath.Join(t.TempDir(), "telemetry.json"), "http://localhost", "v1.2.3")
+
+	var out bytes.Buffer
+	require.NoError(t, telemetryStatus(&out, collector))
+	assert.Contains(t, out.String(), "Telemetry is disabled, run laq telemetry enable to opt in")
+
+	out.Reset()
+	require.NoError(t, telemetryShow(&out, collector))
+	assert.Contains(t, out.String(), "Telemetry is disabled, nothing is recorded or reported")
+
+	require.NoError(t, collector.SetEnabled(true))
+	require.NoError(t, collector.RecordCommand("run"))
+	require.NoError(t, collector.RecordStepTypes([]string{"agent"}))
+
+	out.Reset()
+	require.NoError(t, telemetryShow(&out, collector))
+
+	var report telemetry.Report
+	require.NoError(t, json.Unmarshal([]byte(re.ReplaceAllString(out.String(), "")), &report))
+	assert.Equal(t, "v1.2.3", report.Version)
+	assert.Equal(t, map[string]int{"run": 1}, report.Commands)
+	assert.Equal(t, map[string]int{"agent": 1}, report.StepTypes)
+
+	t.Setenv("DO_NOT_TRACK", "1")
+	out.Reset()
+	require.NoError(t, telemetryStatus(&out, collector))
+	assert.Contains(t, out.String(), "Telemetry is enabled but disabled by DO_NOT_TRACK or LACQUER_TELEMETRY")
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/utils"
+	"github.com/lacquerai/lacquer/internal/webui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	uiHost string
+	uiPort int
+)
+
+// uiCmd represents the ui command
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse the history of workflow runs in a web UI",
+	Long: `Serve a web UI for the workflow runs recorded on this machine.
+
+List runs, drill into their steps and transcripts, compare two runs side by
+side, and chart the daily duration and cost of each workflow. Transcripts
+are read from the run logs of the runs.`,
+	Example: `
+  laq ui                # Browse runs at http://localhost:8081
+  laq ui --port 9000    # Browse runs on another port`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := newHistoryStore()
+		if err == nil {
+			err = serveUI(cmd, webui.NewHandler(store, filepath.Join(utils.LacquerCacheDir, "logs")))
+		}
+		if err != nil {
+			style.Error(cmd.ErrOrStderr(), err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	uiCmd.Flags().StringVar(&uiHost, "host", "localhost", "host to serve the web UI on")
+	uiCmd.Flags().IntVarP(&uiPort, "port", "p", 8081, "port to serve the web UI on")
+
+	rootCmd.AddCommand(uiCmd)
+}
+
+// serveUI serves the web UI until the command is interrupted.
+func serveUI(cmd *cobra.Command, handler http.Handler) error {
+	addr := net.JoinHostPort(uiHost, fmt.Sprint(uiPort))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to serve the web UI on %s: %w", addr, err)
+	}
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	style.Success(cmd.OutOrStdout(), fmt.Sprintf("Browse runs at http://%s", listener.Addr()))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
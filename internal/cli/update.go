@@ -5,6 +5,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -26,6 +27,10 @@ const (
 	updateCacheFile = ".lacquer/update_cache.json"
 	cacheExpiry     = 2 * time.Hour
 	githubAPIURL    = "https://api.github.com/repos/lacquerai/lacquer/releases/latest"
+
+	// backgroundCheckTimeout limits how long the update check made while
+	// running other commands may take
+	backgroundCheckTimeout = 10 * time.Second
 )
 
 type UpdateInfo struct {
@@ -89,7 +94,7 @@ func checkForUpdate(cmd *cobra.Command, verbose bool, withoutCache bool) *Update
 		}
 	}
 
-	latest, downloadURL, err := fetchLatestVersion()
+	latest, downloadURL, err := fetchLatestVersion(cmd.Context())
 	if err != nil {
 		if verbose {
 			fmt.Fprintf(cmd.ErrOrStderr(), "%s Failed to check for updates: %s\n", style.ErrorIcon(), err)
@@ -147,7 +152,7 @@ func performUpdate(cmd *cobra.Command, force bool) {
 
 	fmt.Fprintf(cmd.OutOrStdout(), "%s Downloading laq %s...\n", style.InfoIcon(), updateInfo.LatestVersion)
 
-	binary, err := downloadAndExtractBinary(updateInfo.DownloadURL)
+	binary, err := downloadAndExtractBinary(cmd.Context(), updateInfo.DownloadURL)
 	if err != nil {
 		fmt.Fprintf(cmd.ErrOrStderr(), "%s Failed to download update: %s\n", style.ErrorIcon(), err)
 		return
@@ -165,8 +170,13 @@ func performUpdate(cmd *cobra.Command, force bool) {
 }
 
 // fetchLatestVersion gets the latest version from GitHub API
-func fetchLatestVersion() (version, downloadURL string, err error) {
-	resp, err := http.Get(githubAPIURL)
+func fetchLatestVersion(ctx context.Context) (version, downloadURL string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, githubAPIURL, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to fetch release info: %w", err)
 	}
@@ -193,8 +203,13 @@ func fetchLatestVersion() (version, downloadURL string, err error) {
 }
 
 // downloadAndExtractBinary downloads the archive and extracts the laq binary
-func downloadAndExtractBinary(url string) (io.Reader, error) {
-	resp, err := http.Get(url) // #nosec G107 - URL comes from GitHub API
+func downloadAndExtractBinary(ctx context.Context, url string) (io.Reader, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil) // #nosec G107 - URL comes from GitHub API
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download archive: %w", err)
 	}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/engine"
 	"github.com/lacquerai/lacquer/internal/execcontext"
 	"github.com/lacquerai/lacquer/internal/parser"
 	"github.com/lacquerai/lacquer/internal/style"
@@ -31,12 +33,18 @@ This command checks:
 - Agent reference validation
 - Step dependency analysis
 - Variable interpolation syntax
+
+With --smoke, every prompt and expression is also rendered with sample
+inputs generated from the inputs' schemas, catching template errors which
+only show when a workflow runs.
 `,
 	Example: `
   laq validate workflow.laq.yaml           # Validate single file
   laq validate *.laq.yaml                  # Validate multiple files
   laq validate --recursive ./workflows    # Validate directory recursively
-  laq validate --output json workflow.laq.yaml  # JSON output for CI/CD`,
+  laq validate --output json workflow.laq.yaml  # JSON output for CI/CD
+  laq validate --format sarif -r . > laq.sarif   # SARIF for code scanning
+  laq validate --smoke workflow.laq.yaml   # Render templates with sample inputs`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		runCtx := execcontext.RunContext{
@@ -52,15 +60,29 @@ This command checks:
 }
 
 var (
-	recursive bool
-	showAll   bool
+	recursive      bool
+	showAll        bool
+	validateFormat string
+	validateSmoke  bool
+)
+
+// Formats of the diagnostics printed by validate.
+const (
+	diagnosticsJSON  = "json"
+	diagnosticsSARIF = "sarif"
 )
 
+// ruleInvalidWorkflow is the rule of errors which the parser didn't
+// report with a position.
+const ruleInvalidWorkflow = "invalid-workflow"
+
 func init() {
 	rootCmd.AddCommand(validateCmd)
 
 	validateCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "recursively validate files in directories")
 	validateCmd.Flags().BoolVar(&showAll, "show-all", false, "show all validation results, including successful ones")
+	validateCmd.Flags().StringVar(&validateFormat, "format", "", "print the diagnostics of every file as json or sarif, for editors and CI annotations")
+	validateCmd.Flags().BoolVar(&validateSmoke, "smoke", false, "render every prompt and expression with sample inputs to catch template errors")
 }
 
 // ValidationResult represents the result of validating a workflow
@@ -112,9 +134,37 @@ func (v *ValidationResult) CollectError(err error) {
 	}
 }
 
+// Diagnostics returns the errors and warnings of the file. Errors the parser
+// didn't report with a position are at the start of the file.
+func (v *ValidationResult) Diagnostics() []parser.Diagnostic {
+	if v.EnhancedError != nil {
+		diagnostics := v.EnhancedError.Diagnostics()
+		for i := range diagnostics {
+			if diagnostics[i].File == "" {
+				diagnostics[i].File = v.File
+			}
+		}
+		return diagnostics
+	}
+
+	diagnostics := make([]parser.Diagnostic, 0, len(v.Errors))
+	for _, message := range v.Errors {
+		diagnostics = append(diagnostics, parser.Diagnostic{
+			File:     v.File,
+			Line:     1,
+			Column:   1,
+			Severity: parser.SeverityError,
+			Rule:     ruleInvalidWorkflow,
+			Message:  message,
+		})
+	}
+	return diagnostics
+}
+
 // ValidationIssue represents a detailed validation issue
 type ValidationIssue struct {
 	ID         string           `json:"id" yaml:"id"`
+	Rule       string           `json:"rule" yaml:"rule"`
 	Severity   string           `json:"severity" yaml:"severity"`
 	Title      string           `json:"title" yaml:"title"`
 	Message    string           `json:"message" yaml:"message"`
@@ -141,9 +191,31 @@ type ValidationSummary struct {
 	Results  []ValidationResult `json:"results" yaml:"results"`
 }
 
+// validationDiagnostics are the diagnostics of every file validated, as
+// printed by --format json
+type validationDiagnostics struct {
+	Diagnostics []parser.Diagnostic `json:"diagnostics"`
+}
+
+// Diagnostics returns the errors and warnings of every file, in the order
+// the files were given.
+func (s ValidationSummary) Diagnostics() []parser.Diagnostic {
+	diagnostics := make([]parser.Diagnostic, 0)
+	for _, result := range s.Results {
+		diagnostics = append(diagnostics, result.Diagnostics()...)
+	}
+	return diagnostics
+}
+
 func validateWorkflows(runCtx execcontext.RunContext, args []string) error {
 	start := time.Now()
 
+	if validateFormat != "" && validateFormat != diagnosticsJSON && validateFormat != diagnosticsSARIF {
+		err := fmt.Errorf("invalid format %q, must be json or sarif", validateFormat)
+		style.Error(runCtx, err.Error())
+		return err
+	}
+
 	files, err := collectFiles(args, recursive)
 	if err != nil {
 		style.Error(runCtx, fmt.Sprintf("Failed to collect files: %v", err))
@@ -163,14 +235,14 @@ func validateWorkflows(runCtx execcontext.RunContext, args []string) error {
 
 	results := make([]ValidationResult, 0, len(files))
 
-	for _, file := range files {
-		result := validateSingleFile(yamlParser, file)
+	for _, parsed := range parser.ParseFiles(yamlParser, files) {
+		result := validationResultFor(parsed)
 		results = append(results, *result)
 
-		if !viper.GetBool("quiet") && viper.GetString("output") == "text" {
+		if !viper.GetBool("quiet") && viper.GetString("output") == "text" && validateFormat == "" {
 			if result.Valid {
 				if showAll {
-					style.Success(runCtx, fmt.Sprintf("%s (%v)", file, result.Duration))
+					style.Success(runCtx, fmt.Sprintf("%s (%v)", parsed.Filename, result.Duration))
 				}
 			}
 		}
@@ -192,10 +264,14 @@ func validateWorkflows(runCtx execcontext.RunContext, args []string) error {
 
 	// Output results
 	outputFormat := viper.GetString("output")
-	switch outputFormat {
-	case "json":
+	switch {
+	case validateFormat == diagnosticsSARIF:
+		style.PrintJSON(runCtx, newSARIFLog(summary.Diagnostics()))
+	case validateFormat == diagnosticsJSON:
+		style.PrintJSON(runCtx, validationDiagnostics{Diagnostics: summary.Diagnostics()})
+	case outputFormat == "json":
 		style.PrintJSON(runCtx, summary)
-	case "yaml":
+	case outputFormat == "yaml":
 		style.PrintYAML(runCtx, summary)
 	default:
 		printValidationSummary(runCtx, summary)
@@ -208,20 +284,24 @@ func validateWorkflows(runCtx execcontext.RunContext, args []string) error {
 	return nil
 }
 
-func validateSingleFile(p parser.Parser, filename string) *ValidationResult {
-	start := time.Now()
-	result := NewValidationResult(filename)
-
-	// Parse and validate the file
-	_, err := p.ParseFile(filename)
-	result.Duration = time.Since(start)
-	if err != nil {
-		result.CollectError(err)
+// validationResultFor converts the result of parsing and validating a file
+// into its validation result
+func validationResultFor(parsed parser.FileResult) *ValidationResult {
+	result := NewValidationResult(parsed.Filename)
+	result.Duration = parsed.Duration
+	if parsed.Err != nil {
+		result.CollectError(parsed.Err)
 		return result
 	}
 
+	if validateSmoke {
+		start := time.Now()
+		result.CollectError(smokeTestWorkflow(parsed.Filename, parsed.Workflow))
+		result.Duration += time.Since(start)
+	}
+
 	log.Debug().
-		Str("file", filename).
+		Str("file", parsed.Filename).
 		Bool("valid", result.Valid).
 		Dur("duration", result.Duration).
 		Int("issues", len(result.Issues)).
@@ -230,10 +310,34 @@ func validateSingleFile(p parser.Parser, filename string) *ValidationResult {
 	return result
 }
 
+// smokeTestWorkflow renders the templates of a parsed workflow with sample
+// inputs, returning the templates which failed at their positions
+func smokeTestWorkflow(filename string, workflow *ast.Workflow) error {
+	issues, err := engine.SmokeTest(workflow)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+
+	source, err := os.ReadFile(filename) // #nosec G304 - filename was just parsed
+	if err != nil {
+		return err
+	}
+
+	pathErrors := make([]parser.PathError, 0, len(issues))
+	for _, issue := range issues {
+		pathErrors = append(pathErrors, parser.PathError{Path: issue.Path, Message: issue.Message})
+	}
+	return parser.ReportPathErrors(source, filename, parser.RuleTemplate, "Template error", pathErrors)
+}
+
 // convertEnhancedErrorToIssue converts an enhanced error to a validation issue
 func convertEnhancedErrorToIssue(err *parser.EnhancedError) *ValidationIssue {
 	issue := &ValidationIssue{
 		ID:       err.ID,
+		Rule:     err.Rule,
 		Severity: string(err.Severity),
 		Title:    err.Title,
 		Message:  err.Message,
@@ -67,6 +67,142 @@ func Test_InvalidMaxTokens(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
 
+func Test_InvalidBlockInputs(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidOutputs(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidTier(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidToolResultFormat(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidResources(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidExport(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidIngest(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidExtract(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidClassify(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidSummarize(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidTranslate(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidDiff(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidPii(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidRace(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidParallel(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidOnFailure(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidKv(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidDedupe(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidHttp(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidIssue(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidAssert(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidPrompts(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidTimeout(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidWorkflowBudget(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidSchedule(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidCompress(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidEnsemble(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidBudget(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidSession(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidForEach(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidRetry(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidOpenaiCompatible(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidPersistence(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_InvalidAgentFallback(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
 func Test_DuplicateToolName(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
@@ -75,6 +211,10 @@ func Test_ToolMultipleTypes(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
 
+func Test_UnknownOfficialTool(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
 func Test_InvalidMcpAuth(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
@@ -83,6 +223,10 @@ func Test_InvalidInputType(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
 
+func Test_InvalidEnumInputs(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
 func Test_InputMinMaxConflict(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
@@ -102,6 +246,10 @@ func Test_InvalidRuntime(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
 
+func Test_InvalidOllamaRequirements(t *testing.T) {
+	newSingleDirectoryValidateTest(t)
+}
+
 func Test_EmptyCondition(t *testing.T) {
 	newSingleDirectoryValidateTest(t)
 }
@@ -140,6 +288,31 @@ func newSingleDirectoryValidateTest(t *testing.T) {
 	assertGoldenFile(t, directory, stdout, stderr)
 }
 
+func Test_DiagnosticsJson(t *testing.T) {
+	validateFormat = diagnosticsJSON
+	defer func() { validateFormat = "" }()
+
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_DiagnosticsSarif(t *testing.T) {
+	validateFormat = diagnosticsSARIF
+	defer func() { validateFormat = "" }()
+
+	newSingleDirectoryValidateTest(t)
+}
+
+func Test_SmokeTemplates(t *testing.T) {
+	validateSmoke = true
+	validateFormat = diagnosticsJSON
+	defer func() {
+		validateSmoke = false
+		validateFormat = ""
+	}()
+
+	newSingleDirectoryValidateTest(t)
+}
+
 func assertGoldenFile(t *testing.T, directory string, stdout *safeBuffer, stderr *safeBuffer) {
 	t.Helper()
 
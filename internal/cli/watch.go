@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+// watchWorkflow runs the workflow and then re-runs it every time the
+// workflow file or one of the local files it references changes. Step
+// results are cached between runs so that only steps affected by a change
+// are executed again. Returns once the context is cancelled.
+func watchWorkflow(ctx context.Context, cmd *cobra.Command, workflowFile string, inputs map[string]interface{}, opts []engine.RunnerOption) {
+	watcher := watch.New(nil, watch.WithDebounce(watchDebounce))
+	opts = append(opts, engine.WithStepCache(engine.NewStepCache()))
+
+	for {
+		// take the snapshot before running so that changes made while the
+		// workflow is executing trigger another run straight away.
+		watcher.SetPaths(watchPaths(workflowFile, watcher.Paths()))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		if timeout > 0 {
+			runCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+
+		_ = runWorkflow(execcontext.RunContext{
+			Context: runCtx,
+			StdOut:  cmd.OutOrStdout(),
+			StdErr:  cmd.OutOrStderr(),
+			Trigger: execcontext.Trigger{Type: execcontext.TriggerWatch},
+		}, workflowFile, inputs, opts...)
+		cancel()
+
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n", style.MutedStyle.Render(fmt.Sprintf("Watching %d file(s) for changes, press Ctrl+C to exit", len(watcher.Paths()))))
+
+		changed, err := watcher.Wait(ctx)
+		if err != nil {
+			return
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n\n", style.InfoStyle.Render(fmt.Sprintf("Change detected in %s, re-running workflow", strings.Join(relativePaths(changed), ", "))))
+	}
+}
+
+// watchPaths returns the workflow file along with every local file it
+// references. If the workflow can't be parsed the previously watched paths
+// are kept so that fixing the workflow still triggers a re-run.
+func watchPaths(workflowFile string, previous []string) []string {
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		return previous
+	}
+
+	workflow, err := yamlParser.ParseFile(workflowFile)
+	if err != nil {
+		if len(previous) == 0 {
+			return []string{workflowFile}
+		}
+		return previous
+	}
+
+	wd := filepath.Dir(workflowFile)
+	paths := []string{workflowFile}
+	for _, ref := range workflow.LocalReferences() {
+		paths = append(paths, filepath.Join(wd, ref))
+	}
+
+	return paths
+}
+
+func relativePaths(paths []string) []string {
+	rel := make([]string, len(paths))
+	for i, path := range paths {
+		if r, err := filepath.Rel(".", path); err == nil {
+			path = r
+		}
+		rel[i] = path
+	}
+
+	return rel
+}
@@ -0,0 +1,80 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	monday := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	sunday := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr    string
+		matches []time.Time
+		misses  []time.Time
+	}{
+		{expr: "0 9 * * 1-5", matches: []time.Time{monday}, misses: []time.Time{monday.Add(time.Minute), sunday.Add(9 * time.Hour)}},
+		{expr: "*/15 * * * *", matches: []time.Time{monday, monday.Add(45 * time.Minute)}, misses: []time.Time{monday.Add(10 * time.Minute)}},
+		{expr: "@weekly", matches: []time.Time{sunday}, misses: []time.Time{monday}},
+		{expr: "0 0 * * 7", matches: []time.Time{sunday}},
+		{expr: "0 9 1 JAN *", matches: []time.Time{monday}, misses: []time.Time{monday.AddDate(0, 1, 0)}},
+		// the day matches either field when both are restricted
+		{expr: "0 0 1,15 * sun", matches: []time.Time{sunday, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)}, misses: []time.Time{time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			require.NoError(t, err)
+
+			for _, date := range tt.matches {
+				assert.True(t, schedule.Matches(date), date)
+			}
+			for _, date := range tt.misses {
+				assert.False(t, schedule.Matches(date), date)
+			}
+		})
+	}
+
+	for _, expr := range []string{"60 * * * *", "* * * * mon-sun/0", "5-1 * * * *", "* * * foo *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// Friday
+	friday := time.Date(2024, 1, 5, 17, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		from time.Time
+		next time.Time
+	}{
+		{expr: "* * * * *", from: friday, next: time.Date(2024, 1, 5, 17, 31, 0, 0, time.UTC)},
+		{expr: "0 9 * * 1-5", from: friday, next: time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)},
+		{expr: "30 17 * * *", from: time.Date(2024, 1, 5, 17, 30, 0, 0, time.UTC), next: time.Date(2024, 1, 6, 17, 30, 0, 0, time.UTC)},
+		{expr: "@monthly", from: time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC), next: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", from: friday, next: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 30 2 *", from: friday},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.next, schedule.Next(tt.from))
+		})
+	}
+
+	// the next time is in the location of the time it follows
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	schedule, err := Parse("0 9 * * *")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC), schedule.Next(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).In(london)).UTC())
+}
@@ -0,0 +1,292 @@
+// Package diff compares texts line by line and JSON values structurally,
+// producing both a list of changes and a human-readable unified diff.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	// DefaultContext is the default number of unchanged lines shown around
+	// each change in a unified diff.
+	DefaultContext = 3
+
+	OpAdded   = "added"
+	OpRemoved = "removed"
+	OpChanged = "changed"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{FormatText, FormatJSON}
+
+// Line is a line of a line diff. Old and New are the 1-based line numbers of
+// the line in each text, zero when the line isn't in that text.
+type Line struct {
+	Op   string
+	Old  int
+	New  int
+	Text string
+}
+
+// Change is a difference between two JSON values at a path such as
+// lines[1].qty, the path is empty for the values themselves.
+type Change struct {
+	Path string
+	Op   string
+	Old  interface{}
+	New  interface{}
+}
+
+// Lines compares two texts line by line. Unchanged lines have an empty op.
+func Lines(oldText, newText string) []Line {
+	dmp := diffmatchpatch.New()
+	a, b, lines := dmp.DiffLinesToChars(terminate(oldText), terminate(newText))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lines)
+
+	var result []Line
+	oldLine, newLine := 0, 0
+	for _, d := range diffs {
+		for _, text := range splitLines(d.Text) {
+			line := Line{Text: text}
+			switch d.Type {
+			case diffmatchpatch.DiffInsert:
+				newLine++
+				line.Op, line.New = OpAdded, newLine
+			case diffmatchpatch.DiffDelete:
+				oldLine++
+				line.Op, line.Old = OpRemoved, oldLine
+			default:
+				oldLine++
+				newLine++
+				line.Old, line.New = oldLine, newLine
+			}
+			result = append(result, line)
+		}
+	}
+
+	return result
+}
+
+// Unified formats a line diff as a unified diff with context unchanged lines
+// around each change. It's empty when the texts are the same.
+func Unified(lines []Line, oldName, newName string, context int) string {
+	if context < 0 {
+		context = 0
+	}
+
+	var hunks [][2]int
+	for i, line := range lines {
+		if line.Op == "" {
+			continue
+		}
+
+		start := max(i-context, 0)
+		end := min(i+context+1, len(lines))
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+	}
+
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("--- " + oldName + "\n")
+	builder.WriteString("+++ " + newName + "\n")
+
+	for _, hunk := range hunks {
+		oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+		for _, line := range lines[hunk[0]:hunk[1]] {
+			if line.Old > 0 {
+				if oldStart == 0 {
+					oldStart = line.Old
+				}
+				oldCount++
+			}
+			if line.New > 0 {
+				if newStart == 0 {
+					newStart = line.New
+				}
+				newCount++
+			}
+		}
+
+		// an empty range starts at the line before it
+		if oldCount == 0 {
+			oldStart = precedingLine(lines[:hunk[0]], func(l Line) int { return l.Old })
+		}
+		if newCount == 0 {
+			newStart = precedingLine(lines[:hunk[0]], func(l Line) int { return l.New })
+		}
+
+		builder.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount)))
+		for _, line := range lines[hunk[0]:hunk[1]] {
+			switch line.Op {
+			case OpAdded:
+				builder.WriteString("+")
+			case OpRemoved:
+				builder.WriteString("-")
+			default:
+				builder.WriteString(" ")
+			}
+			builder.WriteString(line.Text + "\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// Values compares two JSON values. Objects are compared by key, in sorted
+// order, and lists by index.
+func Values(oldValue, newValue interface{}) ([]Change, error) {
+	oldValue, err := normalize(oldValue)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err = normalize(newValue)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	compare("", oldValue, newValue, &changes)
+	return changes, nil
+}
+
+// Indent formats a JSON value with sorted keys, one property or item per
+// line, so that it can be compared line by line.
+func Indent(value interface{}) (string, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func compare(path string, oldValue, newValue interface{}, changes *[]Change) {
+	switch oldTyped := oldValue.(type) {
+	case map[string]interface{}:
+		newTyped, ok := newValue.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(oldTyped)+len(newTyped))
+		for key := range oldTyped {
+			keys = append(keys, key)
+		}
+		for key := range newTyped {
+			if _, ok := oldTyped[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			oldItem, inOld := oldTyped[key]
+			newItem, inNew := newTyped[key]
+			child := joinPath(path, key)
+			switch {
+			case !inNew:
+				*changes = append(*changes, Change{Path: child, Op: OpRemoved, Old: oldItem})
+			case !inOld:
+				*changes = append(*changes, Change{Path: child, Op: OpAdded, New: newItem})
+			default:
+				compare(child, oldItem, newItem, changes)
+			}
+		}
+		return
+	case []interface{}:
+		newTyped, ok := newValue.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < max(len(oldTyped), len(newTyped)); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(newTyped):
+				*changes = append(*changes, Change{Path: child, Op: OpRemoved, Old: oldTyped[i]})
+			case i >= len(oldTyped):
+				*changes = append(*changes, Change{Path: child, Op: OpAdded, New: newTyped[i]})
+			default:
+				compare(child, oldTyped[i], newTyped[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(oldValue, newValue) {
+		*changes = append(*changes, Change{Path: path, Op: OpChanged, Old: oldValue, New: newValue})
+	}
+}
+
+// normalize converts a value to the types produced by decoding JSON so that
+// e.g. integers and floats compare equal.
+func normalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("value is not JSON: %w", err)
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, fmt.Errorf("value is not JSON: %w", err)
+	}
+
+	return normalized, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// terminate ends the text with a newline so that its last line compares
+// equal to the same line followed by others.
+func terminate(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func precedingLine(lines []Line, number func(Line) int) int {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if n := number(lines[i]); n > 0 {
+			return n
+		}
+	}
+
+	return 0
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	lines := Lines("one\ntwo\nthree", "one\n2\nthree\nfour\n")
+
+	assert.Equal(t, []Line{
+		{Old: 1, New: 1, Text: "one"},
+		{Op: OpRemoved, Old: 2, Text: "two"},
+		{Op: OpAdded, New: 2, Text: "2"},
+		{Old: 3, New: 3, Text: "three"},
+		{Op: OpAdded, New: 4, Text: "four"},
+	}, lines)
+
+	assert.Empty(t, Unified(Lines("same\n", "same"), "a", "b", DefaultContext))
+}
+
+func TestUnified(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	newText := "a\nB\nc\nd\ne\nf\ng\nh\ni\n"
+
+	assert.Equal(t, "--- before\n+++ after\n"+
+		"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"+
+		"@@ -9,2 +9 @@\n i\n-j\n", Unified(Lines(oldText, newText), "before", "after", 1))
+
+	// hunks closer than twice the context are merged
+	assert.Equal(t, "--- before\n+++ after\n"+
+		"@@ -1,10 +1,9 @@\n a\n-b\n+B\n c\n d\n e\n f\n g\n h\n i\n-j\n", Unified(Lines(oldText, newText), "before", "after", 4))
+
+	// an insertion at the start has an empty old range
+	assert.Equal(t, "--- before\n+++ after\n@@ -0,0 +1 @@\n+new\n", Unified(Lines("", "new"), "before", "after", 3))
+}
+
+func TestValues(t *testing.T) {
+	changes, err := Values(
+		map[string]interface{}{"title": "Spring sale", "price": 10, "tags": []interface{}{"a", "b"}, "draft": true},
+		map[string]interface{}{"title": "Summer sale", "price": 10.0, "tags": []interface{}{"a"}, "author": "kim"},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Change{
+		{Path: "author", Op: OpAdded, New: "kim"},
+		{Path: "draft", Op: OpRemoved, Old: true},
+		{Path: "tags[1]", Op: OpRemoved, Old: "b"},
+		{Path: "title", Op: OpChanged, Old: "Spring sale", New: "Summer sale"},
+	}, changes)
+
+	changes, err = Values([]interface{}{1}, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Op: OpChanged, Old: []interface{}{1.0}, New: map[string]interface{}{}}}, changes)
+}
@@ -0,0 +1,159 @@
+// Package encryption encrypts the files Lacquer keeps on the local machine,
+// such as the run history, as they hold prompts and outputs which may
+// include sensitive data.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size in bytes of an encryption key, for AES-256.
+const KeySize = 32
+
+// header marks the start of encrypted data, data without it is plaintext.
+var header = []byte("LQENC1\x00")
+
+// ErrKeyRequired is returned when opening encrypted data without a key.
+var ErrKeyRequired = errors.New("data is encrypted, configure encryption.key or encryption.keychain to read it")
+
+// Cipher encrypts data with AES-256-GCM. A nil cipher leaves data in
+// plaintext.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a 32 byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// ParseKey decodes a base64 or hex encoded key.
+func ParseKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+
+	if key, err := base64.StdEncoding.DecodeString(value); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+
+	if key, err := hex.DecodeString(value); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("encryption key must be %d bytes encoded as base64 or hex, generate one with: openssl rand -base64 %d", KeySize, KeySize)
+}
+
+// GenerateKey returns a new random base64 encoded key.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Seal encrypts the data, or returns it as is when the cipher is nil.
+func (c *Cipher) Seal(data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(header)+len(nonce)+len(data)+c.aead.Overhead())
+	out = append(out, header...)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, data, header), nil
+}
+
+// Open decrypts data sealed by Seal. Plaintext data is returned as is, so
+// files written before encryption was enabled can still be read.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrKeyRequired
+	}
+
+	data = data[len(header):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, errors.New("failed to decrypt data, the encryption key may have changed")
+	}
+
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether the data was sealed by a cipher.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, header)
+}
+
+// linePrefix starts every line sealed by SealLine, the base64 encoding of
+// the header
+var linePrefix = base64.StdEncoding.EncodeToString(header[:6])
+
+// SealLine encrypts a line of a line based file, such as a JSON lines log,
+// as base64 so that it stays on one line, or returns it as is when the
+// cipher is nil.
+func (c *Cipher) SealLine(line []byte) ([]byte, error) {
+	if c == nil {
+		return line, nil
+	}
+
+	sealed, err := c.Seal(line)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(out, sealed)
+	return out, nil
+}
+
+// OpenLine decrypts a line sealed by SealLine. Plaintext lines are returned
+// as is.
+func (c *Cipher) OpenLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, []byte(linePrefix)) {
+		return line, nil
+	}
+
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)))
+	n, err := base64.StdEncoding.Decode(sealed, bytes.TrimSpace(line))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted line: %w", err)
+	}
+
+	return c.Open(sealed[:n])
+}
@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/keychain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher_SealOpen(t *testing.T) {
+	encoded, err := GenerateKey()
+	require.NoError(t, err)
+	key, err := ParseKey(encoded)
+	require.NoError(t, err)
+
+	c, err := NewCipher(key)
+	require.NoError(t, err)
+
+	sealed, err := c.Seal([]byte(`{"prompt":"secret"}`))
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(sealed))
+	assert.NotContains(t, string(sealed), "secret")
+
+	opened, err := c.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"prompt":"secret"}`, string(opened))
+
+	// plaintext written before encryption was enabled is still readable
+	opened, err = c.Open([]byte(`{"prompt":"plain"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"prompt":"plain"}`, string(opened))
+
+	var none *Cipher
+	_, err = none.Open(sealed)
+	assert.ErrorIs(t, err, ErrKeyRequired)
+
+	plain, err := none.Seal([]byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(plain))
+
+	other, err := NewCipher(make([]byte, KeySize))
+	require.NoError(t, err)
+	_, err = other.Open(sealed)
+	assert.EqualError(t, err, "failed to decrypt data, the encryption key may have changed")
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = c.Open(sealed)
+	assert.Error(t, err)
+}
+
+func TestCipher_SealOpenLine(t *testing.T) {
+	c, err := NewCipher(make([]byte, KeySize))
+	require.NoError(t, err)
+
+	sealed, err := c.SealLine([]byte(`{"text":"secret"}`))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "secret")
+	assert.NotContains(t, string(sealed), "\n")
+
+	opened, err := c.OpenLine(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"text":"secret"}`, string(opened))
+
+	// lines written before encryption was enabled are still readable
+	opened, err = c.OpenLine([]byte(`{"text":"plain"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"text":"plain"}`, string(opened))
+
+	var none *Cipher
+	plain, err := none.SealLine([]byte("line"))
+	require.NoError(t, err)
+	assert.Equal(t, "line", string(plain))
+
+	_, err = none.OpenLine(sealed)
+	assert.ErrorIs(t, err, ErrKeyRequired)
+}
+
+func TestParseKey(t *testing.T) {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	parsed, err := ParseKey(base64.StdEncoding.EncodeToString(key) + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	parsed, err = ParseKey(hex.EncodeToString(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = ParseKey("too-short")
+	assert.Error(t, err)
+
+	_, err = NewCipher([]byte("short"))
+	assert.EqualError(t, err, "encryption key must be 32 bytes, got 5")
+}
+
+func TestKeychainKey(t *testing.T) {
+	original := keychainGet
+	t.Cleanup(func() { keychainGet = original })
+
+	keychainGet = func(account string) (string, error) {
+		assert.Equal(t, KeychainAccount, account)
+		return "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=", nil
+	}
+	key, err := KeychainKey()
+	require.NoError(t, err)
+	assert.Len(t, key, KeySize)
+
+	keychainGet = func(string) (string, error) { return "", keychain.ErrNotFound }
+	_, err = KeychainKey()
+	assert.EqualError(t, err, "failed to read encryption key: secret not found in the keychain")
+}
@@ -0,0 +1,23 @@
+package encryption
+
+import (
+	"fmt"
+
+	"github.com/lacquerai/lacquer/internal/keychain"
+)
+
+// KeychainAccount is the account of the key in the OS keychain.
+const KeychainAccount = "encryption"
+
+// keychainGet reads a secret from the OS keychain (can be mocked for testing)
+var keychainGet = keychain.Get
+
+// KeychainKey reads the key stored in the OS keychain.
+func KeychainKey() ([]byte, error) {
+	value, err := keychainGet(KeychainAccount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %w", err)
+	}
+
+	return ParseKey(value)
+}
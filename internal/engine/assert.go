@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/rs/zerolog/log"
+)
+
+// executeAssertStep evaluates every assertion of the step, failing it with
+// the actual and expected values of those which are false. The step outputs
+// the number of assertions which passed.
+func (e *Executor) executeAssertStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	log.Debug().
+		Str("step_id", step.ID).
+		Int("assertions", len(step.Assert)).
+		Msg("Executing assert step")
+
+	var failures []string
+	for i, assertion := range step.Assert {
+		result, err := e.templateEngine.Assert(assertion.That, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate assertion %d: %w", i+1, err)
+		}
+		if !result.Passed {
+			failures = append(failures, describeFailedAssertion(assertion, result))
+		}
+	}
+
+	switch len(failures) {
+	case 0:
+		return NewStepResult(map[string]interface{}{
+			"passed": len(step.Assert),
+		}, "passed"), nil
+	case 1:
+		return nil, fmt.Errorf("assertion failed: %s", failures[0])
+	default:
+		return nil, fmt.Errorf("%d of %d assertions failed: %s", len(failures), len(step.Assert), strings.Join(failures, "; "))
+	}
+}
+
+// describeFailedAssertion explains a failed assertion by its message, or its
+// expression, followed by the values it expected and got, e.g.
+// label: expected "urgent", got "low".
+func describeFailedAssertion(assertion ast.Assertion, result *expression.Assertion) string {
+	label := assertion.Message
+	if label == "" {
+		label = strings.TrimSpace(assertion.That)
+	}
+
+	var expected string
+	switch result.Op {
+	case "":
+		expected = "true"
+	case "==":
+		expected = formatAssertedValue(result.Expected)
+	case "!=":
+		expected = "not " + formatAssertedValue(result.Expected)
+	default:
+		expected = result.Op + " " + formatAssertedValue(result.Expected)
+	}
+
+	return fmt.Sprintf("%s: expected %s, got %s", label, expected, formatAssertedValue(result.Actual))
+}
+
+// formatAssertedValue quotes strings so that "3" and 3 can be told apart.
+func formatAssertedValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return fmt.Sprintf("%q", text)
+	}
+	return expression.ValueToString(value)
+}
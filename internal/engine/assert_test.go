@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ExecuteAssertStep(t *testing.T) {
+	inputs := map[string]interface{}{"count": 2, "label": "low"}
+
+	tests := []struct {
+		name       string
+		assertions []ast.Assertion
+		err        string
+	}{
+		{
+			name: "Passing assertions",
+			assertions: []ast.Assertion{
+				{That: "${{ steps.greet.output == \"hello\" }}"},
+				{That: "${{ inputs.count > 1 }}"},
+				{That: "${{ contains(steps.greet.output, \"ell\") }}"},
+			},
+		},
+		{
+			name:       "Failed comparison",
+			assertions: []ast.Assertion{{That: "${{ steps.greet.output == \"goodbye\" }}"}},
+			err:        `assertion failed: ${{ steps.greet.output == "goodbye" }}: expected "goodbye", got "hello"`,
+		},
+		{
+			name:       "Failed assertion with a message",
+			assertions: []ast.Assertion{{That: "${{ inputs.count >= 3 }}", Message: "at least 3 items"}},
+			err:        "assertion failed: at least 3 items: expected >= 3, got 2",
+		},
+		{
+			name:       "Failed expression",
+			assertions: []ast.Assertion{{That: "${{ contains(inputs.label, \"urgent\") }}"}},
+			err:        `assertion failed: ${{ contains(inputs.label, "urgent") }}: expected true, got false`,
+		},
+		{
+			name: "Several failed assertions",
+			assertions: []ast.Assertion{
+				{That: "${{ inputs.label != \"low\" }}", Message: "label"},
+				{That: "${{ inputs.count > 1 }}"},
+				{That: "${{ inputs.count < 1 }}", Message: "count"},
+			},
+			err: `2 of 3 assertions failed: label: expected not "low", got "low"; count: expected < 1, got 2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []*ast.Step{
+				{ID: "greet", Run: "echo -n hello"},
+				{ID: "check", Assert: tt.assertions},
+			}
+
+			execCtx, _, err := runTestWorkflow(t, createTestWorkflow(steps), inputs)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			result, ok := execCtx.GetStepResult("check")
+			require.True(t, ok)
+			assert.Equal(t, map[string]interface{}{"passed": len(tt.assertions)}, result.Output["outputs"])
+		})
+	}
+}
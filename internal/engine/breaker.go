@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/events"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/rs/zerolog/log"
+)
+
+// breakers returns the circuit breakers of the run, nil when providers
+// aren't guarded by breakers.
+func (e *Executor) breakers() *provider.Breakers {
+	if e.config == nil {
+		return nil
+	}
+	return e.config.Breakers
+}
+
+// recordProviderOutcome records the outcome of a request to a provider in
+// its circuit breaker and tells the provider observer, err is
+// context.Canceled for requests cancelled by the run.
+func (e *Executor) recordProviderOutcome(name string, err error) {
+	e.breakers().Record(name, err)
+
+	if e.config != nil && e.config.ProviderObserver != nil && !errors.Is(err, context.Canceled) {
+		e.config.ProviderObserver.ObserveProviderRequest(name, err)
+	}
+}
+
+// availableAgent returns the agent, or its fallback while the circuit
+// breaker of its provider is open. Fallbacks are followed until one whose
+// provider is available, or which has no fallback, is found.
+func (e *Executor) availableAgent(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent) *ast.Agent {
+	breakers := e.breakers()
+	if breakers == nil {
+		return agent
+	}
+
+	// fallbacks can't form a cycle in valid workflows, the limit only
+	// guards against unvalidated ones
+	for range len(execCtx.Workflow.Agents) {
+		if agent.Fallback == "" || breakers.Status(agent.ProviderName()).State != provider.BreakerOpen {
+			break
+		}
+
+		fallback, ok := execCtx.Workflow.GetAgent(agent.Fallback)
+		if !ok {
+			break
+		}
+
+		log.Warn().
+			Str("step_id", step.ID).
+			Str("agent", agent.Name).
+			Str("provider", agent.ProviderName()).
+			Str("fallback", fallback.Name).
+			Msg("Provider is unavailable, using the fallback agent")
+
+		if e.progressChan != nil {
+			actionID := fmt.Sprintf("%s-fallback", step.ID)
+			e.progressChan <- events.NewGenericActionEvent(step.ID, actionID, execCtx.RunID, fmt.Sprintf("%s is unavailable, falling back to agent %s", agent.Provider, agent.Fallback))
+			e.progressChan <- events.NewGenericActionCompletedEvent(step.ID, actionID, execCtx.RunID)
+		}
+
+		agent = fallback
+	}
+
+	return agent
+}
+
+// fallbackAgent returns the fallback of the agent when the request which
+// failed with err may succeed with another model, see
+// provider.ErrorCategory.Fallback.
+func (e *Executor) fallbackAgent(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent, err error) (*ast.Agent, bool) {
+	if err == nil || agent.Fallback == "" || execCtx.Context.Context.Err() != nil {
+		return nil, false
+	}
+
+	category := provider.ErrorCategoryOf(err)
+	if !category.Fallback() {
+		return nil, false
+	}
+
+	fallback, ok := execCtx.Workflow.GetAgent(agent.Fallback)
+	if !ok {
+		return nil, false
+	}
+
+	log.Warn().
+		Err(err).
+		Str("step_id", step.ID).
+		Str("agent", agent.Name).
+		Str("category", string(category)).
+		Str("fallback", fallback.Name).
+		Msg("Model request failed, using the fallback agent")
+
+	if e.progressChan != nil {
+		actionID := fmt.Sprintf("%s-fallback-%s", step.ID, fallback.Name)
+		e.progressChan <- events.NewGenericActionEvent(step.ID, actionID, execCtx.RunID, fmt.Sprintf("%s request failed (%s), falling back to agent %s", agent.Provider, category, agent.Fallback))
+		e.progressChan <- events.NewGenericActionCompletedEvent(step.ID, actionID, execCtx.RunID)
+	}
+
+	return fallback, true
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/events"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+// stepBudget tracks what a while or ensemble step has spent against its
+// budget and the run's budget.
+type stepBudget struct {
+	e      *Executor
+	budget *ast.StepBudget
+	start  time.Time
+	cost   float64
+	tokens int
+}
+
+// newStepBudget starts tracking the spend of the step from now on, the
+// step's budget may be nil in which case only the run's budget applies.
+func (e *Executor) newStepBudget(step *ast.Step) *stepBudget {
+	cost, tokens := e.spend()
+	return &stepBudget{
+		e:      e,
+		budget: step.Budget,
+		start:  time.Now(),
+		cost:   cost,
+		tokens: tokens,
+	}
+}
+
+// exhausted returns why the step has to stop, or an empty string while it
+// is within its budget. Spend is measured across the run so work running
+// alongside the step counts towards its budget too.
+func (b *stepBudget) exhausted() string {
+	cost, tokens := b.e.spend()
+	if b.e.config.Budget > 0 && cost >= b.e.config.Budget {
+		return fmt.Sprintf("the run's budget of $%.2f is spent", b.e.config.Budget)
+	}
+
+	if b.budget == nil {
+		return ""
+	}
+
+	switch {
+	case b.budget.Cost > 0 && cost-b.cost >= b.budget.Cost:
+		return fmt.Sprintf("the step's cost budget of $%.2f is spent", b.budget.Cost)
+	case b.budget.Tokens > 0 && tokens-b.tokens >= b.budget.Tokens:
+		return fmt.Sprintf("the step's budget of %d tokens is spent", b.budget.Tokens)
+	case b.budget.Duration != nil && b.budget.Duration.Duration > 0 && time.Since(b.start) >= b.budget.Duration.Duration:
+		return fmt.Sprintf("the step's time budget of %s has elapsed", b.budget.Duration.Duration)
+	}
+
+	return ""
+}
+
+// stopEarly reports that the step stopped early because of its budget.
+func (e *Executor) stopEarly(execCtx *execcontext.ExecutionContext, step *ast.Step, reason string) {
+	log.Info().
+		Str("step_id", step.ID).
+		Str("reason", reason).
+		Msg("Step stopped early, its budget is exhausted")
+
+	if e.progressChan != nil {
+		actionID := fmt.Sprintf("%s-budget", step.ID)
+		e.progressChan <- events.NewGenericActionEvent(step.ID, actionID, execCtx.RunID, fmt.Sprintf("Stopped early, %s", reason))
+		e.progressChan <- events.NewGenericActionCompletedEvent(step.ID, actionID, execCtx.RunID)
+	}
+}
+
+// setBudgetOutputs sets the outputs telling whether the step stopped early
+// because of its budget and why.
+func setBudgetOutputs(outputs map[string]interface{}, reason string) {
+	outputs["budget_exhausted"] = reason != ""
+	if reason != "" {
+		outputs["budget_reason"] = reason
+	}
+}
+
+// RunBudgetExceededError is returned by a run which spent more than the
+// workflow's budget.
+type RunBudgetExceededError struct {
+	Budget ast.RunBudget
+	Cost   float64
+	Tokens int
+}
+
+func (e *RunBudgetExceededError) Error() string {
+	if e.Budget.Cost > 0 && e.Cost > e.Budget.Cost {
+		return fmt.Sprintf("workflow exceeded its budget of $%.2f, spent $%.4f", e.Budget.Cost, e.Cost)
+	}
+	return fmt.Sprintf("workflow exceeded its budget of %d tokens, used %d", e.Budget.Tokens, e.Tokens)
+}
+
+// withRunBudget cancels the context of the run once the workflow's budget
+// is exceeded. The returned function restores the context of the run and
+// returns the error the workflow failed with, err or the exceeded budget.
+func (e *Executor) withRunBudget(execCtx *execcontext.ExecutionContext) func(err error) error {
+	budget := execCtx.Workflow.Workflow.Budget
+	if budget == nil {
+		return func(err error) error { return err }
+	}
+
+	parent := execCtx.Context.Context
+	ctx, cancel := context.WithCancelCause(parent)
+	execCtx.Context.Context = ctx
+
+	e.spendMu.Lock()
+	e.exceedBudget = cancel
+	e.checkRunBudget()
+	e.spendMu.Unlock()
+
+	return func(err error) error {
+		var budgetErr *RunBudgetExceededError
+		exceeded := errors.As(context.Cause(ctx), &budgetErr) && parent.Err() == nil
+
+		e.spendMu.Lock()
+		e.exceedBudget = nil
+		e.spendMu.Unlock()
+		cancel(nil)
+		execCtx.Context.Context = parent
+
+		if !exceeded {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", budgetErr, err)
+		}
+
+		// the last step exceeded the budget, nothing has reported the
+		// failure yet
+		if e.progressChan != nil {
+			e.progressChan <- pkgEvents.ExecutionEvent{
+				Type:      pkgEvents.EventWorkflowFailed,
+				Timestamp: time.Now(),
+				RunID:     execCtx.RunID,
+				Error:     budgetErr.Error(),
+				Metadata:  runClockMetadata(execCtx),
+			}
+		}
+		return budgetErr
+	}
+}
+
+// checkRunBudget cancels the run when its spend exceeds the workflow's
+// budget, spendMu must be held.
+func (e *Executor) checkRunBudget() {
+	if e.exceedBudget == nil {
+		return
+	}
+
+	budget := e.execCtx.Workflow.Workflow.Budget
+	if (budget.Cost > 0 && e.spent > budget.Cost) || (budget.Tokens > 0 && e.tokens > budget.Tokens) {
+		log.Warn().
+			Str("run_id", e.execCtx.RunID).
+			Float64("cost", e.spent).
+			Int("tokens", e.tokens).
+			Msg("Workflow exceeded its budget, cancelling the run")
+
+		e.exceedBudget(&RunBudgetExceededError{Budget: *budget, Cost: e.spent, Tokens: e.tokens})
+	}
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/routing"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// budgetWorkflow returns three agent steps and the provider answering them,
+// each request uses 15 tokens costing $0.00006 with withBudgetPrices.
+func budgetWorkflow(budget *ast.RunBudget) (*ast.Workflow, *scriptedProvider) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock("outline"),
+			provider.NewTextBlock("draft"),
+			provider.NewTextBlock("final"),
+		},
+	}
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "outline", Agent: "writer", Prompt: "Outline the post"},
+		{ID: "draft", Agent: "writer", Prompt: "Draft the post"},
+		{ID: "final", Agent: "writer", Prompt: "Polish the post"},
+	})
+	workflow.Workflow.Budget = budget
+	workflow.Agents = map[string]*ast.Agent{
+		"writer": {Name: "writer", Provider: pr.name, Model: "test-model"},
+	}
+
+	return workflow, pr
+}
+
+// withBudgetPrices prices the model of budgetWorkflow.
+func withBudgetPrices() testRunOption {
+	config := DefaultExecutorConfig()
+	config.RoutingModels = []routing.Model{{Provider: "openai", Model: "test-model", InputPrice: 2, OutputPrice: 8}}
+	return withExecutorConfig(config)
+}
+
+func TestExecuteWorkflow_RunBudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  *ast.RunBudget
+		wantErr string
+	}{
+		{
+			name:    "tokens",
+			budget:  &ast.RunBudget{Tokens: 20},
+			wantErr: "workflow exceeded its budget of 20 tokens, used 30",
+		},
+		{
+			name:    "cost",
+			budget:  &ast.RunBudget{Cost: 0.0001},
+			wantErr: "workflow exceeded its budget of $0.00, spent $0.0001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow, pr := budgetWorkflow(tt.budget)
+			execCtx, events, err := runTestWorkflow(t, workflow, nil, withProviders(pr), withBudgetPrices())
+			require.Error(t, err)
+
+			var budgetErr *RunBudgetExceededError
+			require.ErrorAs(t, err, &budgetErr)
+			assert.EqualError(t, budgetErr, tt.wantErr)
+			assert.Equal(t, 30, budgetErr.Tokens)
+
+			// the step exceeding the budget completes, the run stops after it
+			draft, _ := execCtx.GetStepResult("draft")
+			assert.Equal(t, execcontext.StepStatusCompleted, draft.Status)
+			final, _ := execCtx.GetStepResult("final")
+			assert.NotEqual(t, execcontext.StepStatusCompleted, final.Status)
+
+			assert.Equal(t, pkgEvents.EventWorkflowFailed, events[len(events)-1].Type)
+			assert.NoError(t, execCtx.Context.Context.Err())
+		})
+	}
+}
+
+func TestExecuteWorkflow_WithinRunBudget(t *testing.T) {
+	workflow, pr := budgetWorkflow(&ast.RunBudget{Cost: 1, Tokens: 1000})
+	execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr), withBudgetPrices())
+	require.NoError(t, err)
+
+	summary := execCtx.GetExecutionSummary()
+	usage := summary.ModelUsage["openai/test-model"]
+	assert.Equal(t, 30, usage.PromptTokens)
+	assert.Equal(t, 15, usage.CompletionTokens)
+	assert.Equal(t, 45, usage.TotalTokens)
+	assert.InDelta(t, 0.00018, usage.Cost, 1e-9)
+	assert.InDelta(t, summary.EstimatedCost, usage.Cost, 1e-9)
+}
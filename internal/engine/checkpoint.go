@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/rs/zerolog/log"
+)
+
+// Checkpoint is the progress of a run, recorded after each of its top-level
+// steps so that a run which failed or was interrupted can be resumed from
+// its last completed step.
+type Checkpoint struct {
+	RunID        string                     `json:"run_id"`
+	WorkflowFile string                     `json:"workflow_file"`
+	Inputs       map[string]interface{}     `json:"inputs"`
+	State        map[string]interface{}     `json:"state"`
+	Steps        map[string]*CheckpointStep `json:"steps"`
+	// NextStep is the index of the top-level step the run continues at
+	NextStep  int       `json:"next_step"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckpointStep is the result of a completed top-level step of a run along
+// with the fingerprint of the step when it ran.
+type CheckpointStep struct {
+	CachedStep
+	Fingerprint string `json:"fingerprint"`
+}
+
+// ResumableError is returned by a failed run which has a checkpoint it can
+// be resumed from.
+type ResumableError struct {
+	RunID string
+	Err   error
+}
+
+func (e *ResumableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ResumableError) Unwrap() error {
+	return e.Err
+}
+
+// CheckpointStore persists the checkpoints of runs to disk, one file per run.
+type CheckpointStore struct {
+	dir    string
+	cipher *encryption.Cipher
+}
+
+// NewCheckpointStore creates a checkpoint store which keeps its files in dir,
+// encrypted with cipher unless it's nil.
+func NewCheckpointStore(dir string, cipher *encryption.Cipher) *CheckpointStore {
+	return &CheckpointStore{dir: dir, cipher: cipher}
+}
+
+// Save records the checkpoint, replacing the previous checkpoint of the run.
+func (s *CheckpointStore) Save(checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint of run %s: %w", checkpoint.RunID, err)
+	}
+
+	data, err = s.cipher.Seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt checkpoint of run %s: %w", checkpoint.RunID, err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	// write to a temporary file first so an interrupted write never leaves a
+	// corrupt checkpoint behind
+	path := s.path(checkpoint.RunID)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write checkpoint of run %s: %w", checkpoint.RunID, err)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// Load returns the checkpoint of the run.
+func (s *CheckpointStore) Load(runID string) (*Checkpoint, error) {
+	if err := validateRunID(runID); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.path(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no checkpoint found for run %s, only runs which failed or were interrupted can be resumed", runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint of run %s: %w", runID, err)
+	}
+
+	data, err = s.cipher.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint of run %s: %w", runID, err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint of run %s: %w", runID, err)
+	}
+
+	return &checkpoint, nil
+}
+
+// Delete removes the checkpoint of the run, a run without a checkpoint is
+// not an error.
+func (s *CheckpointStore) Delete(runID string) error {
+	if err := validateRunID(runID); err != nil {
+		return err
+	}
+
+	err := os.Remove(s.path(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *CheckpointStore) path(runID string) string {
+	return filepath.Join(s.dir, runID+".json")
+}
+
+// validateRunID ensures the run ID can't be used to reach files outside of
+// the store.
+func validateRunID(runID string) error {
+	if runID == "" || strings.ContainsAny(runID, `/\`) || strings.Contains(runID, "..") {
+		return fmt.Errorf("invalid run ID %q", runID)
+	}
+	return nil
+}
+
+// checkpointFingerprints computes the fingerprints of the top-level steps of
+// the workflow for checkpoints. The workflow is identified by its absolute
+// path so a run can be resumed from any directory.
+func checkpointFingerprints(workflow *ast.Workflow, inputs map[string]interface{}) map[string]string {
+	abs := *workflow
+	if path, err := filepath.Abs(workflow.SourceFile); err == nil {
+		abs.SourceFile = path
+	}
+
+	return stepFingerprints(&abs, inputs)
+}
+
+// saveCheckpoint records the progress of the top-level workflow, next is
+// the index of the step the run would continue at.
+func (e *Executor) saveCheckpoint(execCtx *execcontext.ExecutionContext, next int) {
+	if e.config.Checkpoints == nil || execCtx.Parent != nil {
+		return
+	}
+
+	workflowFile := execCtx.Workflow.SourceFile
+	if path, err := filepath.Abs(workflowFile); err == nil {
+		workflowFile = path
+	}
+
+	checkpoint := &Checkpoint{
+		RunID:        execCtx.RunID,
+		WorkflowFile: workflowFile,
+		Inputs:       execCtx.Inputs,
+		State:        execCtx.GetAllState(),
+		Steps:        make(map[string]*CheckpointStep),
+		NextStep:     next,
+		UpdatedAt:    time.Now(),
+	}
+
+	for _, step := range execCtx.Workflow.GetSteps() {
+		result, ok := execCtx.GetStepResult(step.ID)
+		if !ok || result.Status != execcontext.StepStatusCompleted {
+			continue
+		}
+
+		checkpoint.Steps[step.ID] = &CheckpointStep{
+			CachedStep: CachedStep{
+				StepID:   step.ID,
+				Output:   result.Output,
+				Response: result.Response,
+			},
+			Fingerprint: e.checkpointFingerprints[step.ID],
+		}
+	}
+
+	if err := e.config.Checkpoints.Save(checkpoint); err != nil {
+		log.Warn().Err(err).Str("run_id", execCtx.RunID).Msg("Failed to save checkpoint")
+	}
+}
+
+// resumeCheckpoint prepares the executor to continue the run of the
+// checkpoint. Completed steps up to the step the run continues at are
+// restored, unless their definition has changed since, in which case the
+// run continues at the first step which changed.
+func (e *Executor) resumeCheckpoint(execCtx *execcontext.ExecutionContext) {
+	checkpoint := e.config.Resume
+	steps := execCtx.Workflow.GetSteps()
+
+	next := min(checkpoint.NextStep, len(steps))
+	for i, step := range steps[:next] {
+		saved, ok := checkpoint.Steps[step.ID]
+		if ok && saved.Fingerprint != e.checkpointFingerprints[step.ID] {
+			log.Warn().
+				Str("run_id", execCtx.RunID).
+				Str("step_id", step.ID).
+				Msg("Step has changed since the run, resuming from it")
+			next = i
+			break
+		}
+	}
+
+	e.fromIndex = next
+	e.config.SeedResults = make(map[string]*CachedStep, len(checkpoint.Steps))
+	for id, saved := range checkpoint.Steps {
+		cached := saved.CachedStep
+		e.config.SeedResults[id] = &cached
+	}
+
+	// the state is only as recorded when the run continues where it left off,
+	// otherwise the state updates of the restored steps are applied again
+	e.resumeState = next == checkpoint.NextStep
+	if e.resumeState {
+		execCtx.UpdateState(checkpoint.State)
+	}
+
+	log.Info().
+		Str("run_id", execCtx.RunID).
+		Int("step", next+1).
+		Msg("Resuming workflow run")
+}
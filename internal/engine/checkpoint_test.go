@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const checkpointTestWorkflow = `version: "1.0"
+inputs:
+  dir:
+    type: string
+    required: true
+state:
+  count: 0
+workflow:
+  steps:
+    - id: first
+      run: "echo first >> ${{ inputs.dir }}/runs.txt && echo one"
+      updates:
+        count: "${{ state.count + 1 }}"
+    - id: second
+      run: "test -f ${{ inputs.dir }}/ready && echo two"
+  outputs:
+    first: ${{ steps.first.output }}
+    second: ${{ steps.second.output }}
+    count: ${{ state.count }}
+`
+
+// runCheckpointedWorkflow runs the workflow, which fails at its second step
+// until the ready file exists, returning the ID of the failed run.
+func runCheckpointedWorkflow(t *testing.T, runner *Runner, dir, workflow string) string {
+	t.Helper()
+
+	file := filepath.Join(dir, "workflow.laq.yml")
+	require.NoError(t, os.WriteFile(file, []byte(workflow), 0600))
+
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	_, err := runner.RunWorkflow(ctx, file, map[string]interface{}{"dir": dir})
+	require.Error(t, err)
+
+	var resumable *ResumableError
+	require.True(t, errors.As(err, &resumable))
+	return resumable.RunID
+}
+
+func TestRunner_ResumeWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	store := NewCheckpointStore(filepath.Join(t.TempDir(), "checkpoints"), nil)
+	runner := NewRunner(nil, WithCheckpoints(store))
+
+	runID := runCheckpointedWorkflow(t, runner, dir, checkpointTestWorkflow)
+
+	checkpoint, err := store.Load(runID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, checkpoint.NextStep)
+	assert.Equal(t, filepath.Join(dir, "workflow.laq.yml"), checkpoint.WorkflowFile)
+	assert.EqualValues(t, 1, checkpoint.State["count"])
+	require.Contains(t, checkpoint.Steps, "first")
+	assert.NotContains(t, checkpoint.Steps, "second")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ready"), nil, 0600))
+
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	result, err := runner.ResumeWorkflow(ctx, runID)
+	require.NoError(t, err)
+
+	assert.Equal(t, runID, result.RunID)
+	assert.Equal(t, "one", strings.TrimSpace(result.Outputs["first"].(string)))
+	assert.Equal(t, "two", strings.TrimSpace(result.Outputs["second"].(string)))
+	// the state is restored rather than the updates of the first step
+	// applied again
+	assert.Equal(t, "1", result.Outputs["count"])
+
+	// the first step isn't run again
+	runs, err := os.ReadFile(filepath.Join(dir, "runs.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(runs))
+
+	// the checkpoint of a completed run is removed
+	_, err = store.Load(runID)
+	assert.ErrorContains(t, err, "no checkpoint found for run "+runID)
+}
+
+func TestRunner_ResumeWorkflowChangedStep(t *testing.T) {
+	dir := t.TempDir()
+	store := NewCheckpointStore(filepath.Join(t.TempDir(), "checkpoints"), nil)
+	runner := NewRunner(nil, WithCheckpoints(store))
+
+	runID := runCheckpointedWorkflow(t, runner, dir, checkpointTestWorkflow)
+
+	// changing the first step means its result can't be used
+	changed := strings.Replace(checkpointTestWorkflow, "echo one", "echo uno", 1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "workflow.laq.yml"), []byte(changed), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ready"), nil, 0600))
+
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	result, err := runner.ResumeWorkflow(ctx, runID)
+	require.NoError(t, err)
+
+	assert.Equal(t, "uno", strings.TrimSpace(result.Outputs["first"].(string)))
+	assert.Equal(t, "1", result.Outputs["count"])
+
+	runs, err := os.ReadFile(filepath.Join(dir, "runs.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "first\nfirst\n", string(runs))
+}
+
+func TestRunner_ResumeWorkflowWithoutCheckpoints(t *testing.T) {
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	_, err := NewRunner(nil).ResumeWorkflow(ctx, "run_1234")
+	assert.ErrorContains(t, err, "checkpoints are not enabled")
+}
+
+func TestCheckpointStore(t *testing.T) {
+	cipher, err := encryption.NewCipher(make([]byte, encryption.KeySize))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	store := NewCheckpointStore(dir, cipher)
+
+	checkpoint := &Checkpoint{
+		RunID:        "run_1234",
+		WorkflowFile: "/workflows/report.laq.yml",
+		Inputs:       map[string]interface{}{"token": "a secret"},
+		State:        map[string]interface{}{},
+		Steps: map[string]*CheckpointStep{
+			"fetch": {CachedStep: CachedStep{StepID: "fetch", Response: "data"}, Fingerprint: "abc"},
+		},
+		NextStep: 1,
+	}
+	require.NoError(t, store.Save(checkpoint))
+
+	data, err := os.ReadFile(filepath.Join(dir, "run_1234.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "a secret")
+
+	loaded, err := store.Load("run_1234")
+	require.NoError(t, err)
+	assert.Equal(t, "a secret", loaded.Inputs["token"])
+	assert.Equal(t, "data", loaded.Steps["fetch"].Response)
+	assert.Equal(t, "abc", loaded.Steps["fetch"].Fingerprint)
+
+	_, err = NewCheckpointStore(dir, nil).Load("run_1234")
+	assert.ErrorIs(t, err, encryption.ErrKeyRequired)
+
+	require.NoError(t, store.Delete("run_1234"))
+	require.NoError(t, store.Delete("run_1234"))
+	_, err = store.Load("run_1234")
+	assert.ErrorContains(t, err, "no checkpoint found")
+
+	_, err = store.Load("../history/run_1234")
+	assert.ErrorContains(t, err, "invalid run ID")
+}
@@ -0,0 +1,287 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// classifySampleTemperature is used when sampling an agent which doesn't set
+// a temperature, so that the samples can disagree.
+const classifySampleTemperature = 1.0
+
+// classifyExample is a few-shot example of a classify step.
+type classifyExample struct {
+	text  string
+	label string
+}
+
+// classifyVote is the label chosen by one sample.
+type classifyVote struct {
+	label      string
+	confidence float64
+	reason     string
+}
+
+// executeClassifyStep asks an agent to choose one of the step's labels for
+// some text. With a single sample the confidence is the agent's estimate,
+// with more it's the share of samples that chose the label, which is better
+// calibrated than a model's own estimate.
+func (e *Executor) executeClassifyStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Classify
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("agent", spec.Agent).
+		Msg("Executing classify step")
+
+	agent, pr, decision, err := e.resolveStepAgent(execCtx, step, spec.Agent)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := e.buildClassifyPrompt(execCtx, step)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := max(spec.Samples, 1)
+	if samples > 1 && agent.Temperature == nil {
+		sampled := *agent
+		temperature := classifySampleTemperature
+		sampled.Temperature = &temperature
+		agent = &sampled
+	}
+
+	retries := defaultStructuredRetries
+	if spec.Retries != nil {
+		retries = *spec.Retries
+	}
+
+	responseSchema := &provider.ResponseSchema{
+		Name:        "classify_" + step.ID,
+		Description: "Record the label chosen for the text",
+		Schema:      classifySchema(spec.Labels),
+	}
+
+	usage := &execcontext.TokenUsage{}
+	votes := make([]classifyVote, 0, samples)
+	for i := 0; i < samples; i++ {
+		response, sampleUsage, err := e.generateStructured(execCtx, step, pr, agent, prompt, responseSchema, retries)
+		usage.Add(sampleUsage)
+		if err != nil {
+			e.recordSpend(agent, usage)
+			return nil, err
+		}
+
+		vote := classifyVote{label: fmt.Sprintf("%v", response["label"])}
+		vote.confidence, _ = response["confidence"].(float64)
+		vote.confidence = math.Min(math.Max(vote.confidence, 0), 1)
+		vote.reason, _ = response["reason"].(string)
+		votes = append(votes, vote)
+	}
+	e.recordSpend(agent, usage)
+
+	outputs := tallyClassifyVotes(spec.Labels, votes)
+	if spec.MinConfidence > 0 && outputs["confidence"].(float64) < spec.MinConfidence {
+		log.Debug().
+			Str("step_id", step.ID).
+			Interface("label", outputs["label"]).
+			Interface("confidence", outputs["confidence"]).
+			Msg("Classification confidence below min_confidence, using fallback label")
+
+		outputs["label"] = spec.Fallback
+	}
+
+	result := NewStepResult(outputs)
+	result.TokenUsage = usage
+	result.Routing = decision
+
+	return result, nil
+}
+
+// tallyClassifyVotes chooses the label with the most votes, ties are broken
+// by the mean confidence and then by the order of the labels.
+func tallyClassifyVotes(labels []ast.ClassifyLabel, votes []classifyVote) map[string]interface{} {
+	if len(votes) == 1 {
+		return map[string]interface{}{
+			"label":      votes[0].label,
+			"confidence": votes[0].confidence,
+			"reason":     votes[0].reason,
+		}
+	}
+
+	counts := make(map[string]int)
+	confidence := make(map[string]float64)
+	for _, vote := range votes {
+		counts[vote.label]++
+		confidence[vote.label] += vote.confidence
+	}
+
+	best := ""
+	for _, label := range labels {
+		count := counts[label.Value]
+		if count == 0 {
+			continue
+		}
+		if best == "" || count > counts[best] || (count == counts[best] && confidence[label.Value] > confidence[best]) {
+			best = label.Value
+		}
+	}
+
+	// the reason of the most confident sample that chose the label
+	var reason string
+	reasonConfidence := -1.0
+	for _, vote := range votes {
+		if vote.label == best && vote.confidence > reasonConfidence {
+			reason = vote.reason
+			reasonConfidence = vote.confidence
+		}
+	}
+
+	tally := make(map[string]interface{}, len(counts))
+	for label, count := range counts {
+		tally[label] = count
+	}
+
+	return map[string]interface{}{
+		"label":      best,
+		"confidence": float64(counts[best]) / float64(len(votes)),
+		"reason":     reason,
+		"votes":      tally,
+	}
+}
+
+// buildClassifyPrompt renders the labels, instructions, examples and the text
+// to classify.
+func (e *Executor) buildClassifyPrompt(execCtx *execcontext.ExecutionContext, step *ast.Step) (string, error) {
+	spec := step.Classify
+
+	text, err := e.templateEngine.Render(spec.From, execCtx)
+	if err != nil {
+		return "", fmt.Errorf("failed to render from: %w", err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Classify the text below with exactly one of these labels:\n")
+	for _, label := range spec.Labels {
+		builder.WriteString("- " + label.Value)
+		if label.Description != "" {
+			builder.WriteString(": " + label.Description)
+		}
+		builder.WriteString("\n")
+	}
+
+	if spec.Instructions != "" {
+		instructions, err := e.templateEngine.Render(spec.Instructions, execCtx)
+		if err != nil {
+			return "", fmt.Errorf("failed to render instructions: %w", err)
+		}
+		builder.WriteString("\n" + expression.ValueToString(instructions) + "\n")
+	}
+
+	if spec.Examples != nil {
+		rendered, err := e.renderValueRecursively(spec.Examples, execCtx)
+		if err != nil {
+			return "", fmt.Errorf("failed to render examples: %w", err)
+		}
+
+		examples, err := classifyExamples(rendered)
+		if err != nil {
+			return "", err
+		}
+
+		if len(examples) > 0 {
+			builder.WriteString("\nExamples:\n")
+			for _, example := range examples {
+				builder.WriteString("<example>\n<text>\n" + example.text + "\n</text>\n<label>" + example.label + "</label>\n</example>\n")
+			}
+		}
+	}
+
+	builder.WriteString("\nRespond with the label, your confidence from 0 to 1 that the label is correct and a one sentence reason.\n")
+	builder.WriteString("\n<text>\n")
+	builder.WriteString(expression.ValueToString(text))
+	builder.WriteString("\n</text>")
+
+	return builder.String(), nil
+}
+
+// classifyExamples converts rendered examples, a list of objects with text
+// and label or the same list as JSON, to examples.
+func classifyExamples(value interface{}) ([]classifyExample, error) {
+	if s, ok := value.(string); ok {
+		if strings.TrimSpace(s) == "" {
+			return nil, nil
+		}
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, fmt.Errorf("examples must be a list of objects with text and label")
+		}
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		// lists from workflow state may be typed, e.g. []map[string]interface{}
+		data, err := json.Marshal(value)
+		if err != nil || json.Unmarshal(data, &items) != nil {
+			return nil, fmt.Errorf("examples must be a list of objects with text and label, got %T", value)
+		}
+	}
+
+	examples := make([]classifyExample, 0, len(items))
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("examples[%d] must be an object with text and label", i)
+		}
+
+		text := expression.ValueToString(fields["text"])
+		label := expression.ValueToString(fields["label"])
+		if fields["text"] == nil || fields["label"] == nil {
+			return nil, fmt.Errorf("examples[%d] must have a text and a label", i)
+		}
+
+		examples = append(examples, classifyExample{text: text, label: label})
+	}
+
+	return examples, nil
+}
+
+// classifySchema is the response schema of a classify step, the label is
+// constrained to the step's labels.
+func classifySchema(labels []ast.ClassifyLabel) schema.JSON {
+	values := make([]interface{}, len(labels))
+	for i, label := range labels {
+		values[i] = label.Value
+	}
+
+	return schema.JSON{
+		Type: "object",
+		Properties: map[string]schema.JSON{
+			"label": {
+				Type:        "string",
+				Enum:        values,
+				Description: "The label that best fits the text",
+			},
+			"confidence": {
+				Type:        "number",
+				Maximum:     1,
+				Description: "Confidence from 0 to 1 that the label is correct",
+			},
+			"reason": {
+				Type:        "string",
+				Description: "One sentence explaining the choice of label",
+			},
+		},
+		Required:             []string{"label", "confidence", "reason"},
+		AdditionalProperties: false,
+	}
+}
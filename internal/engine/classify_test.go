@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func classifyWorkflow(pr *scriptedProvider, classify *ast.ClassifyStep) *ast.Workflow {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "triage", Classify: classify}})
+	workflow.Agents = map[string]*ast.Agent{
+		"classifier": {Name: "classifier", Provider: pr.name, Model: "test-model"},
+	}
+	return workflow
+}
+
+func classifyResponse(id, label string, confidence float64) provider.ContentBlockParamUnion {
+	input, _ := json.Marshal(map[string]interface{}{"label": label, "confidence": confidence, "reason": "because " + label})
+	return provider.NewToolUseBlock(id, input, "classify_triage")
+}
+
+func TestExecutor_ExecuteClassifyStep(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "anthropic",
+		responses: []provider.ContentBlockParamUnion{
+			classifyResponse("call_1", "question", 0.9), // not a label, retried
+			classifyResponse("call_2", "bug", 0.8),
+		},
+	}
+
+	workflow := classifyWorkflow(pr, &ast.ClassifyStep{
+		Agent: "classifier",
+		From:  "${{ inputs.ticket }}",
+		Labels: []ast.ClassifyLabel{
+			{Value: "bug", Description: "Something is broken"},
+			{Value: "feature"},
+		},
+		Examples: "${{ state.labelled }}",
+	})
+
+	labelled := []interface{}{
+		map[string]interface{}{"text": "Add dark mode", "label": "feature"},
+	}
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"ticket": "The export button crashes the app"}, withProviders(pr), withState(map[string]interface{}{"labelled": labelled}))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("triage")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"label": "bug", "confidence": 0.8, "reason": "because bug"}, result.Output["outputs"])
+
+	require.Len(t, pr.requests, 2)
+	request := pr.requests[0]
+	assert.Equal(t, []interface{}{"bug", "feature"}, request.ResponseSchema.Schema.Properties["label"].Enum)
+
+	prompt := request.GetPrompt()
+	assert.Contains(t, prompt, "- bug: Something is broken\n- feature\n")
+	assert.Contains(t, prompt, "<example>\n<text>\nAdd dark mode\n</text>\n<label>feature</label>\n</example>")
+	assert.Contains(t, prompt, "<text>\nThe export button crashes the app\n</text>")
+	assert.Contains(t, pr.requests[1].Messages[2].Content[0].OfToolResult.Content, `label: must be one of ["bug","feature"]`)
+}
+
+func TestExecutor_ExecuteClassifyStep_Samples(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "anthropic",
+		responses: []provider.ContentBlockParamUnion{
+			classifyResponse("call_1", "bug", 0.9),
+			classifyResponse("call_2", "feature", 0.99),
+			classifyResponse("call_3", "bug", 0.6),
+		},
+	}
+
+	workflow := classifyWorkflow(pr, &ast.ClassifyStep{
+		Agent:         "classifier",
+		From:          "${{ inputs.ticket }}",
+		Labels:        []ast.ClassifyLabel{{Value: "bug"}, {Value: "feature"}},
+		Samples:       3,
+		MinConfidence: 0.7,
+		Fallback:      "needs_review",
+	})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"ticket": "The export button crashes the app"}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("triage")
+	require.True(t, ok)
+
+	// two of three samples agree, which is below min_confidence
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "needs_review", outputs["label"])
+	assert.InDelta(t, 2.0/3.0, outputs["confidence"], 1e-9)
+	assert.Equal(t, "because bug", outputs["reason"])
+	assert.Equal(t, map[string]interface{}{"bug": 2, "feature": 1}, outputs["votes"])
+	assert.Equal(t, 45, result.TokenUsage.TotalTokens)
+
+	// samples use a temperature so that they can disagree
+	require.Len(t, pr.requests, 3)
+	require.NotNil(t, pr.requests[0].Temperature)
+	assert.Equal(t, classifySampleTemperature, *pr.requests[0].Temperature)
+}
+
+func TestTallyClassifyVotes(t *testing.T) {
+	labels := []ast.ClassifyLabel{{Value: "a"}, {Value: "b"}}
+
+	// ties are broken by confidence
+	outputs := tallyClassifyVotes(labels, []classifyVote{{label: "a", confidence: 0.5}, {label: "b", confidence: 0.9}})
+	assert.Equal(t, "b", outputs["label"])
+	assert.Equal(t, 0.5, outputs["confidence"])
+}
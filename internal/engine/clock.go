@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+// clockSkewThreshold is how far the wall clock can move during a run before
+// the run's final event notes it.
+const clockSkewThreshold = time.Second
+
+// clockSkew returns how far the wall clock moved against the monotonic clock
+// between start and now. Times without a monotonic reading, such as times
+// restored from a checkpoint, have no skew.
+func clockSkew(start, now time.Time) time.Duration {
+	return now.Round(0).Sub(start.Round(0)) - now.Sub(start)
+}
+
+// runClockMetadata returns the metadata of the completion or failure event
+// of a run, noting the clock skew when the wall clock was adjusted during
+// the run so that consumers of the events know that the timestamps of its
+// events can't be compared. Durations aren't affected, they are measured
+// with the monotonic clock.
+func runClockMetadata(execCtx *execcontext.ExecutionContext) map[string]interface{} {
+	skew := clockSkew(execCtx.StartTime, time.Now())
+	if skew.Abs() < clockSkewThreshold {
+		return nil
+	}
+
+	log.Warn().
+		Str("run_id", execCtx.RunID).
+		Dur("clock_skew", skew).
+		Msg("Wall clock was adjusted during the run, event timestamps may be skewed")
+
+	return map[string]interface{}{pkgEvents.MetadataClockSkew: skew}
+}
@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkew(t *testing.T) {
+	start := time.Now()
+	assert.Less(t, clockSkew(start, time.Now()).Abs(), time.Millisecond)
+	assert.Zero(t, clockSkew(start, start.Add(time.Hour)))
+
+	// times restored from a checkpoint have no monotonic reading
+	assert.Zero(t, clockSkew(start.Round(0).Add(-time.Hour), time.Now()))
+}
+
+func TestExecutor_StepEventTimes(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "ask", Agent: "fast", Prompt: "Why is the sky blue?"},
+		{ID: "fail", Agent: "slow", Prompt: "Why is the sky blue?", Timeout: &ast.Duration{Duration: 20 * time.Millisecond}},
+	})
+	workflow.Agents = modelDelayAgents()
+
+	_, events, err := runTestWorkflow(t, workflow, nil, withProviders(newModelDelayProvider()))
+	require.Error(t, err)
+
+	var ended int
+	for _, event := range events {
+		if event.Type != pkgEvents.EventStepCompleted && event.Type != pkgEvents.EventStepFailed {
+			continue
+		}
+		ended++
+
+		require.False(t, event.StartTime.IsZero(), event.StepID)
+		assert.Equal(t, event.Duration, event.Timestamp.Sub(event.StartTime), event.StepID)
+		assert.GreaterOrEqual(t, event.Duration, 10*time.Millisecond, event.StepID)
+	}
+	assert.Equal(t, 2, ended)
+}
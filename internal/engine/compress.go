@@ -0,0 +1,344 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+// sectionPattern matches the markers around a section of a prompt which can
+// be dropped when the prompt is compressed, e.g.
+//
+//	<!-- section name=tickets priority=1 -->
+//	Related tickets: ${{ steps.tickets.output }}
+//	<!-- /section -->
+var sectionPattern = regexp.MustCompile(`<!--\s*(/?)section\b(.*?)-->`)
+
+// sectionAttributePattern matches the name=value attributes of a section.
+var sectionAttributePattern = regexp.MustCompile(`(\w+)\s*=\s*("[^"]*"|\S+)`)
+
+// minSummarizedTokens is the estimated size below which values aren't worth
+// summarizing.
+const minSummarizedTokens = 200
+
+// promptPart is text of a prompt outside of sections, or a section which
+// can be dropped.
+type promptPart struct {
+	section  bool
+	name     string
+	priority int
+	dropped  bool
+	pieces   []promptPiece
+}
+
+// promptPiece is literal text of a prompt or the rendered value of one of
+// its expressions.
+type promptPiece struct {
+	text       string
+	expression string
+}
+
+// compression records what was done to a prompt to fit it in the context
+// window of its model.
+type compression struct {
+	promptTokens     int
+	compressedTokens int
+	contextWindow    int
+	dropped          []string
+	summarized       []string
+	usage            *execcontext.TokenUsage
+}
+
+// estimateTokens roughly estimates the tokens of text, about four
+// characters per token.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// parsePromptSections splits a prompt template into the text outside of
+// sections and its sections.
+func parsePromptSections(template string) ([]promptPart, error) {
+	var parts []promptPart
+	var open *promptPart
+	last := 0
+
+	for _, match := range sectionPattern.FindAllStringSubmatchIndex(template, -1) {
+		text := template[last:match[0]]
+		last = match[1]
+		closing := template[match[2]:match[3]] == "/"
+
+		if closing {
+			if open == nil {
+				return nil, fmt.Errorf("section closed at offset %d was never opened", match[0])
+			}
+			open.pieces = []promptPiece{{text: text}}
+			parts = append(parts, *open)
+			open = nil
+			continue
+		}
+
+		if open != nil {
+			return nil, fmt.Errorf("sections can't be nested, section %s isn't closed", open.name)
+		}
+		parts = append(parts, promptPart{pieces: []promptPiece{{text: text}}})
+
+		open = &promptPart{section: true, name: fmt.Sprintf("section %d", countSections(parts)+1)}
+		for _, attribute := range sectionAttributePattern.FindAllStringSubmatch(template[match[4]:match[5]], -1) {
+			value := strings.Trim(attribute[2], `"`)
+			switch attribute[1] {
+			case "name":
+				open.name = value
+			case "priority":
+				priority, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("priority of %s must be an integer, got %q", open.name, value)
+				}
+				open.priority = priority
+			}
+		}
+	}
+
+	if open != nil {
+		return nil, fmt.Errorf("section %s isn't closed", open.name)
+	}
+
+	return append(parts, promptPart{pieces: []promptPiece{{text: template[last:]}}}), nil
+}
+
+func countSections(parts []promptPart) int {
+	count := 0
+	for _, part := range parts {
+		if part.section {
+			count++
+		}
+	}
+	return count
+}
+
+// renderPromptParts renders the expressions of each part separately so that
+// their values can be summarized.
+func (e *Executor) renderPromptParts(execCtx *execcontext.ExecutionContext, parts []promptPart) error {
+	for i := range parts {
+		template := parts[i].pieces[0].text
+		var pieces []promptPiece
+		last := 0
+		for _, match := range expression.VariablePattern.FindAllStringSubmatchIndex(template, -1) {
+			pieces = append(pieces, promptPiece{text: template[last:match[0]]})
+			last = match[1]
+
+			rendered, err := e.renderString(template[match[0]:match[1]], execCtx)
+			if err != nil {
+				return err
+			}
+
+			// escaped expressions are literal text
+			piece := promptPiece{text: rendered}
+			if match[2] == -1 {
+				piece.expression = strings.TrimSpace(template[match[4]:match[5]])
+			}
+			pieces = append(pieces, piece)
+		}
+		parts[i].pieces = append(pieces, promptPiece{text: template[last:]})
+	}
+
+	return nil
+}
+
+// joinPromptParts returns the prompt made of the parts which weren't
+// dropped.
+func joinPromptParts(parts []promptPart) string {
+	var builder strings.Builder
+	for _, part := range parts {
+		if part.dropped {
+			continue
+		}
+		for _, piece := range part.pieces {
+			builder.WriteString(piece.text)
+		}
+	}
+	return builder.String()
+}
+
+// renderCompressedPrompt renders the prompt of a step with compress, and
+// compresses it when it doesn't fit in the context window of the agent's
+// model along with the agent's system prompt and response. Sections with
+// the lowest priority are dropped first, the last of them first, then the
+// longest values of expressions are summarized by the summarizer. A prompt
+// which still doesn't fit is sent as it is.
+func (e *Executor) renderCompressedPrompt(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent) (string, *compression, error) {
+	parts, err := parsePromptSections(step.Prompt)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid prompt sections: %w", err)
+	}
+	if err := e.renderPromptParts(execCtx, parts); err != nil {
+		return "", nil, fmt.Errorf("failed to render prompt template: %w", err)
+	}
+
+	prompt := joinPromptParts(parts)
+
+	contextWindow := step.Compress.ContextWindow
+	if contextWindow == 0 {
+		if model, ok := e.router.Lookup(agent.Provider, agent.Model); ok {
+			contextWindow = model.ContextWindow
+		}
+	}
+	if contextWindow == 0 {
+		log.Debug().
+			Str("step_id", step.ID).
+			Str("model", agent.Model).
+			Msg("Context window of the model is unknown, the prompt isn't compressed")
+		return prompt, nil, nil
+	}
+
+	completionTokens := defaultCompletionTokens
+	if agent.MaxTokens != nil {
+		completionTokens = *agent.MaxTokens
+	}
+	limit := contextWindow - completionTokens - estimateTokens(agent.SystemPrompt)
+
+	promptTokens := estimateTokens(prompt)
+	if promptTokens <= limit {
+		return prompt, nil, nil
+	}
+
+	result := &compression{promptTokens: promptTokens, contextWindow: contextWindow}
+
+	sections := make([]int, 0, len(parts))
+	for i, part := range parts {
+		if part.section {
+			sections = append(sections, i)
+		}
+	}
+	slices.SortStableFunc(sections, func(a, b int) int {
+		if c := cmp.Compare(parts[a].priority, parts[b].priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(b, a)
+	})
+	for _, i := range sections {
+		if estimateTokens(prompt) <= limit {
+			break
+		}
+		parts[i].dropped = true
+		result.dropped = append(result.dropped, parts[i].name)
+		prompt = joinPromptParts(parts)
+	}
+
+	if estimateTokens(prompt) > limit && step.Compress.Summarizer != "" {
+		if err := e.summarizePromptValues(execCtx, step, parts, limit, result); err != nil {
+			return "", result, err
+		}
+		prompt = joinPromptParts(parts)
+	}
+
+	result.compressedTokens = estimateTokens(prompt)
+	if result.compressedTokens > limit {
+		log.Warn().
+			Str("step_id", step.ID).
+			Int("tokens", result.compressedTokens).
+			Int("context_window", contextWindow).
+			Msg("Prompt doesn't fit in the context window of the model once compressed")
+	}
+
+	e.sendPromptCompressed(execCtx, step, result)
+
+	return prompt, result, nil
+}
+
+// summarizePromptValues summarizes the longest values of the expressions of
+// the parts which weren't dropped until the prompt fits in limit.
+func (e *Executor) summarizePromptValues(execCtx *execcontext.ExecutionContext, step *ast.Step, parts []promptPart, limit int, result *compression) error {
+	summarizer, pr, _, err := e.resolveStepAgent(execCtx, step, step.Compress.Summarizer)
+	if err != nil {
+		return err
+	}
+
+	type value struct{ part, piece int }
+	var values []value
+	for i, part := range parts {
+		if part.dropped {
+			continue
+		}
+		for j, piece := range part.pieces {
+			if piece.expression != "" && estimateTokens(piece.text) >= minSummarizedTokens {
+				values = append(values, value{i, j})
+			}
+		}
+	}
+	slices.SortStableFunc(values, func(a, b value) int {
+		return cmp.Compare(len(parts[b.part].pieces[b.piece].text), len(parts[a.part].pieces[a.piece].text))
+	})
+
+	result.usage = &execcontext.TokenUsage{}
+	defer e.recordSpend(summarizer, result.usage)
+
+	for i, v := range values {
+		excess := estimateTokens(joinPromptParts(parts)) - limit
+		if excess <= 0 {
+			break
+		}
+
+		piece := &parts[v.part].pieces[v.piece]
+		tokens := estimateTokens(piece.text)
+		target := max(tokens-excess, tokens/4, minSummarizedTokens/2)
+
+		// roughly three words for every four tokens
+		prompt := fmt.Sprintf("Summarize the following text in at most %d words. Keep the facts, names and numbers a reader would need and leave out everything else. Respond with the summary only.\n\n<text>\n%s\n</text>", target*3/4, piece.text)
+		summary, usage, err := e.generateText(execCtx, step, pr, summarizer, fmt.Sprintf("%s-compress-%d", step.ID, i), prompt)
+		result.usage.Add(usage)
+		if err != nil {
+			return fmt.Errorf("failed to summarize %s: %w", piece.expression, err)
+		}
+
+		piece.text = summary
+		result.summarized = append(result.summarized, piece.expression)
+	}
+
+	return nil
+}
+
+// sendPromptCompressed reports what was done to compress the prompt of a
+// step.
+func (e *Executor) sendPromptCompressed(execCtx *execcontext.ExecutionContext, step *ast.Step, result *compression) {
+	log.Info().
+		Str("step_id", step.ID).
+		Int("prompt_tokens", result.promptTokens).
+		Int("compressed_tokens", result.compressedTokens).
+		Strs("dropped_sections", result.dropped).
+		Strs("summarized", result.summarized).
+		Msg("Compressed prompt to fit in the context window of the model")
+
+	if e.progressChan == nil {
+		return
+	}
+
+	metadata := map[string]interface{}{
+		pkgEvents.MetadataPromptTokens:     result.promptTokens,
+		pkgEvents.MetadataCompressedTokens: result.compressedTokens,
+		pkgEvents.MetadataContextWindow:    result.contextWindow,
+	}
+	if len(result.dropped) > 0 {
+		metadata[pkgEvents.MetadataDroppedSections] = result.dropped
+	}
+	if len(result.summarized) > 0 {
+		metadata[pkgEvents.MetadataSummarized] = result.summarized
+	}
+
+	e.progressChan <- pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventPromptCompressed,
+		Timestamp: time.Now(),
+		RunID:     execCtx.RunID,
+		StepID:    step.ID,
+		Metadata:  metadata,
+	}
+}
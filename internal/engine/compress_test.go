@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePromptSections(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     []promptPart
+		wantErr  string
+	}{
+		{
+			name:     "no sections",
+			template: "Why is the sky blue?",
+			want:     []promptPart{{pieces: []promptPiece{{text: "Why is the sky blue?"}}}},
+		},
+		{
+			name:     "sections",
+			template: "Answer the question.\n<!-- section name=\"tickets\" priority=2 -->Tickets<!-- /section -->\n<!-- section priority=-1 -->Docs<!-- /section -->",
+			want: []promptPart{
+				{pieces: []promptPiece{{text: "Answer the question.\n"}}},
+				{section: true, name: "tickets", priority: 2, pieces: []promptPiece{{text: "Tickets"}}},
+				{pieces: []promptPiece{{text: "\n"}}},
+				{section: true, name: "section 2", priority: -1, pieces: []promptPiece{{text: "Docs"}}},
+				{pieces: []promptPiece{{text: ""}}},
+			},
+		},
+		{
+			name:     "nested",
+			template: "<!-- section name=a --><!-- section name=b --><!-- /section --><!-- /section -->",
+			wantErr:  "sections can't be nested, section a isn't closed",
+		},
+		{
+			name:     "not closed",
+			template: "<!-- section name=a -->Tickets",
+			wantErr:  "section a isn't closed",
+		},
+		{
+			name:     "not opened",
+			template: "Tickets<!-- /section -->",
+			wantErr:  "section closed at offset 7 was never opened",
+		},
+		{
+			name:     "invalid priority",
+			template: "<!-- section priority=low -->Tickets<!-- /section -->",
+			wantErr:  `priority of section 1 must be an integer, got "low"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := parsePromptSections(tt.template)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, parts)
+		})
+	}
+}
+
+// compressWorkflow returns an agent step with compress whose model accepts
+// 1000 tokens, 900 of which are left for the prompt.
+func compressWorkflow(pr *scriptedProvider, prompt string, compress *ast.PromptCompression) *ast.Workflow {
+	compress.ContextWindow = 1000
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "answer", Agent: "analyst", Prompt: prompt, Compress: compress},
+	})
+	maxTokens := 100
+	workflow.Agents = map[string]*ast.Agent{
+		"analyst": {Name: "analyst", Provider: pr.name, Model: "test-model", MaxTokens: &maxTokens},
+		"cheap":   {Name: "cheap", Provider: pr.name, Model: "test-model"},
+	}
+	return workflow
+}
+
+func requestPrompt(request *provider.Request) string {
+	return request.Messages[0].Content[0].OfText.Text
+}
+
+func compressedEvent(t *testing.T, events []pkgEvents.ExecutionEvent) *pkgEvents.PromptCompressed {
+	t.Helper()
+
+	for _, event := range events {
+		if event.Type == pkgEvents.EventPromptCompressed {
+			return event.Payload().(*pkgEvents.PromptCompressed)
+		}
+	}
+	require.Fail(t, "no prompt_compressed event")
+	return nil
+}
+
+func TestExecuteWorkflow_PromptCompression_DropsSections(t *testing.T) {
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{provider.NewTextBlock("42")}}
+	prompt := "Answer ${{ inputs.question }}\n" +
+		"<!-- section name=docs priority=2 -->Docs: ${{ inputs.docs }}<!-- /section -->\n" +
+		"<!-- section name=tickets priority=1 -->Tickets: ${{ inputs.tickets }}<!-- /section -->\n" +
+		"<!-- section name=chat priority=1 -->Chat: ${{ inputs.chat }}<!-- /section -->"
+
+	workflow := compressWorkflow(pr, prompt, &ast.PromptCompression{})
+	_, events, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"question": "the question",
+		"docs":     strings.Repeat("d", 2000),
+		"tickets":  strings.Repeat("t", 1000),
+		"chat":     strings.Repeat("c", 1000),
+	}, withProviders(pr))
+	require.NoError(t, err)
+
+	// the lowest priority sections are dropped, the last of them first, until
+	// the prompt fits
+	require.Len(t, pr.requests, 1)
+	sent := requestPrompt(pr.requests[0])
+	assert.Equal(t, "Answer the question\nDocs: "+strings.Repeat("d", 2000)+"\nTickets: "+strings.Repeat("t", 1000)+"\n", sent)
+
+	compressed := compressedEvent(t, events)
+	assert.Equal(t, []string{"chat"}, compressed.DroppedSections)
+	assert.Empty(t, compressed.Summarized)
+	assert.Equal(t, 1000, compressed.ContextWindow)
+	assert.Greater(t, compressed.PromptTokens, 900)
+	assert.LessOrEqual(t, compressed.CompressedTokens, 900)
+}
+
+func TestExecuteWorkflow_PromptCompression_Summarizes(t *testing.T) {
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{
+		provider.NewTextBlock("the notes, summarized"),
+		provider.NewTextBlock("42"),
+	}}
+
+	workflow := compressWorkflow(pr, "Answer using the notes: ${{ inputs.notes }}", &ast.PromptCompression{Summarizer: "cheap"})
+	execCtx, events, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"notes": strings.Repeat("n", 8000),
+	}, withProviders(pr))
+	require.NoError(t, err)
+
+	require.Len(t, pr.requests, 2)
+	assert.Contains(t, requestPrompt(pr.requests[0]), "Summarize the following text")
+	assert.Equal(t, "Answer using the notes: the notes, summarized", requestPrompt(pr.requests[1]))
+
+	compressed := compressedEvent(t, events)
+	assert.Equal(t, []string{"inputs.notes"}, compressed.Summarized)
+
+	// the summarizer's tokens are part of the step's usage
+	result, _ := execCtx.GetStepResult("answer")
+	assert.Equal(t, 30, result.TokenUsage.TotalTokens)
+}
+
+func TestExecuteWorkflow_PromptCompression_Fits(t *testing.T) {
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{provider.NewTextBlock("42")}}
+
+	workflow := compressWorkflow(pr, "Answer ${{ inputs.question }}<!-- section priority=1 --> using the docs<!-- /section -->", &ast.PromptCompression{Summarizer: "cheap"})
+	_, events, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"question": "the question",
+	}, withProviders(pr))
+	require.NoError(t, err)
+
+	// section markers are removed from prompts which fit
+	require.Len(t, pr.requests, 1)
+	assert.Equal(t, "Answer the question using the docs", requestPrompt(pr.requests[0]))
+	assert.NotContains(t, eventTypes(events, "answer"), pkgEvents.EventPromptCompressed)
+}
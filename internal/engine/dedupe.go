@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/rs/zerolog/log"
+)
+
+// dedupeKeyPrefix keeps the keys of dedupe steps apart from the keys of kv
+// steps in the workflow's key-value store
+const dedupeKeyPrefix = "dedupe:"
+
+// executeDedupeStep records the time the key of the step was first seen,
+// outputting whether it had already been seen within the TTL of the step.
+// Checking and recording the key is a single update of the store so that
+// duplicate events handled at the same time aren't both processed, the key
+// is released again if the run fails so that the event can be retried.
+func (e *Executor) executeDedupeStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Dedupe
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Msg("Executing dedupe step")
+
+	if execCtx.KV == nil {
+		return nil, fmt.Errorf("dedupe step requires a key-value store")
+	}
+
+	rendered, err := e.templateEngine.Render(spec.Key, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render key: %w", err)
+	}
+	key := expression.ValueToString(rendered)
+
+	var ttl time.Duration
+	if spec.TTL != nil {
+		ttl = spec.TTL.Duration
+	}
+
+	now := time.Now().UTC()
+	claim := now.Format(time.RFC3339Nano)
+	firstSeen := now
+	duplicate := false
+	_, _, err = execCtx.KV.Update(dedupeKeyPrefix+key, func(value interface{}, ok bool) (interface{}, bool) {
+		if !ok {
+			return claim, true
+		}
+
+		seen, err := time.Parse(time.RFC3339Nano, expression.ValueToString(value))
+		if err != nil || (ttl > 0 && now.Sub(seen) >= ttl) {
+			return claim, true
+		}
+
+		firstSeen, duplicate = seen, true
+		return nil, false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !duplicate {
+		e.dedupeMu.Lock()
+		if e.dedupeClaims == nil {
+			e.dedupeClaims = make(map[string]string)
+		}
+		e.dedupeClaims[dedupeKeyPrefix+key] = claim
+		e.dedupeMu.Unlock()
+	}
+
+	return NewStepResult(map[string]interface{}{
+		"key":        key,
+		"duplicate":  duplicate,
+		"first_seen": firstSeen.Format(time.RFC3339),
+	}, fmt.Sprint(duplicate)), nil
+}
+
+// releaseDedupeClaims removes the keys recorded by the dedupe steps of a
+// run which failed, so that a redelivery of the event it was processing
+// isn't mistaken for a duplicate. Keys recorded again by another run since
+// are kept.
+func (e *Executor) releaseDedupeClaims(execCtx *execcontext.ExecutionContext) {
+	e.dedupeMu.Lock()
+	defer e.dedupeMu.Unlock()
+
+	for key, claim := range e.dedupeClaims {
+		if _, err := execCtx.KV.CompareAndDelete(key, claim); err != nil {
+			log.Warn().
+				Err(err).
+				Str("run_id", execCtx.RunID).
+				Str("key", key).
+				Msg("Failed to release dedupe key of failed run")
+		}
+	}
+	e.dedupeClaims = nil
+}
+
+// isDuplicate reports whether the step is a dedupe step which found its key
+// had already been seen.
+func isDuplicate(execCtx *execcontext.ExecutionContext, step *ast.Step) bool {
+	if !step.IsDedupeStep() {
+		return false
+	}
+
+	result, ok := execCtx.GetStepResult(step.ID)
+	if !ok || result.Status != execcontext.StepStatusCompleted {
+		return false
+	}
+
+	outputs, _ := result.Output["outputs"].(map[string]interface{})
+	duplicate, _ := outputs["duplicate"].(bool)
+	return duplicate
+}
+
+// skipSteps marks steps which won't run as skipped.
+func skipSteps(execCtx *execcontext.ExecutionContext, steps []*ast.Step) {
+	now := time.Now()
+	for _, step := range steps {
+		execCtx.SetStepResult(step.ID, &execcontext.StepResult{
+			StepID:    step.ID,
+			Status:    execcontext.StepStatusSkipped,
+			StartTime: now,
+			EndTime:   now,
+		})
+	}
+}
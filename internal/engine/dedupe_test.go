@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ExecuteDedupeStep(t *testing.T) {
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+	steps := []*ast.Step{
+		{ID: "guard", Dedupe: &ast.DedupeStep{Key: "${{ inputs.event_id }}"}},
+		{ID: "process", Run: "echo -n 'processed ${{ inputs.event_id }}'"},
+	}
+
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"event_id": "evt_1"}, withKV(bucket))
+	require.NoError(t, err)
+
+	guard, _ := execCtx.GetStepResult("guard")
+	assert.Equal(t, "false", guard.Response)
+	process, _ := execCtx.GetStepResult("process")
+	assert.Equal(t, "processed evt_1", process.Response)
+
+	// the same event delivered again stops the workflow at the guard
+	execCtx, _, err = runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"event_id": "evt_1"}, withKV(bucket))
+	require.NoError(t, err)
+
+	guard, _ = execCtx.GetStepResult("guard")
+	assert.Equal(t, true, guard.Output["outputs"].(map[string]interface{})["duplicate"])
+	process, _ = execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusSkipped, process.Status)
+
+	// other events are processed
+	execCtx, _, err = runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"event_id": "evt_2"}, withKV(bucket))
+	require.NoError(t, err)
+
+	process, _ = execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusCompleted, process.Status)
+}
+
+func TestExecutor_ExecuteDedupeStep_TTL(t *testing.T) {
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+	_, _, err := bucket.Set(dedupeKeyPrefix+"evt_1", time.Now().Add(-2*time.Hour).UTC().Format(time.RFC3339Nano))
+	require.NoError(t, err)
+
+	steps := []*ast.Step{
+		{ID: "guard", Dedupe: &ast.DedupeStep{Key: "evt_1", TTL: &ast.Duration{Duration: time.Hour}}},
+		{ID: "process", Run: "echo -n 'processed'"},
+	}
+
+	// the key was seen longer ago than the TTL
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow(steps), nil, withKV(bucket))
+	require.NoError(t, err)
+
+	process, _ := execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusCompleted, process.Status)
+
+	execCtx, _, err = runTestWorkflow(t, createTestWorkflow(steps), nil, withKV(bucket))
+	require.NoError(t, err)
+
+	process, _ = execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusSkipped, process.Status)
+}
+
+func TestExecutor_ExecuteDedupeStep_SubSteps(t *testing.T) {
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+	steps := []*ast.Step{
+		{
+			ID:      "items",
+			ForEach: "${{ inputs.items }}",
+			Steps: []*ast.Step{
+				{ID: "guard", Dedupe: &ast.DedupeStep{Key: "${{ each.item }}"}},
+				{ID: "process", Run: "echo -n '${{ each.item }}'"},
+			},
+		},
+		{ID: "done", Run: "echo -n 'done'"},
+	}
+
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"items": []interface{}{"a", "b", "a"}}, withKV(bucket))
+	require.NoError(t, err)
+
+	// only the iteration with the repeated item stops early
+	items, _ := execCtx.GetStepResult("items")
+	iterations := items.Output["outputs"].([]interface{})
+	require.Len(t, iterations, 3)
+	assert.Equal(t, "a", iterations[0].(map[string]interface{})["process"].(map[string]interface{})["output"])
+	assert.Equal(t, "b", iterations[1].(map[string]interface{})["process"].(map[string]interface{})["output"])
+	assert.Empty(t, iterations[2].(map[string]interface{})["process"])
+
+	done, _ := execCtx.GetStepResult("done")
+	assert.Equal(t, execcontext.StepStatusCompleted, done.Status)
+}
+
+func TestExecutor_ExecuteDedupeStep_FailedRun(t *testing.T) {
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+	steps := []*ast.Step{
+		{ID: "guard", Dedupe: &ast.DedupeStep{Key: "${{ inputs.event_id }}"}},
+		{ID: "process", Run: "exit ${{ inputs.code }}"},
+	}
+
+	// the first delivery fails after the guard, so the event wasn't
+	// processed and its key is released
+	_, _, err := runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"event_id": "evt_1", "code": 1}, withKV(bucket))
+	require.Error(t, err)
+
+	_, ok, err := bucket.Get(dedupeKeyPrefix + "evt_1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// the redelivery is processed rather than skipped as a duplicate
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"event_id": "evt_1", "code": 0}, withKV(bucket))
+	require.NoError(t, err)
+
+	guard, _ := execCtx.GetStepResult("guard")
+	assert.Equal(t, false, guard.Output["outputs"].(map[string]interface{})["duplicate"])
+	process, _ := execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusCompleted, process.Status)
+
+	// once it has been processed later deliveries are duplicates
+	execCtx, _, err = runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"event_id": "evt_1", "code": 1}, withKV(bucket))
+	require.NoError(t, err)
+
+	process, _ = execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusSkipped, process.Status)
+}
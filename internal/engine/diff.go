@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/diff"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/rs/zerolog/log"
+)
+
+// executeDiffStep compares two texts line by line or two JSON values
+// structurally and outputs the changes along with a unified diff.
+func (e *Executor) executeDiffStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Diff
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Msg("Executing diff step")
+
+	oldValue, err := e.templateEngine.Render(spec.From, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render from: %w", err)
+	}
+
+	newValue, err := e.templateEngine.Render(spec.To, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render to: %w", err)
+	}
+
+	format := spec.Format
+	if format == "" {
+		format = detectDiffFormat(oldValue, newValue)
+	}
+
+	contextLines := diff.DefaultContext
+	if spec.Context != nil {
+		contextLines = *spec.Context
+	}
+
+	var oldText, newText string
+	var changes []interface{}
+	switch format {
+	case diff.FormatJSON:
+		oldValue, err = decodeDiffValue(oldValue)
+		if err != nil {
+			return nil, fmt.Errorf("from is not JSON: %w", err)
+		}
+		newValue, err = decodeDiffValue(newValue)
+		if err != nil {
+			return nil, fmt.Errorf("to is not JSON: %w", err)
+		}
+
+		valueChanges, err := diff.Values(oldValue, newValue)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = make([]interface{}, 0, len(valueChanges))
+		for _, change := range valueChanges {
+			item := map[string]interface{}{"path": change.Path, "op": change.Op}
+			if change.Op != diff.OpAdded {
+				item["old"] = change.Old
+			}
+			if change.Op != diff.OpRemoved {
+				item["new"] = change.New
+			}
+			changes = append(changes, item)
+		}
+
+		// compare the indented values so that the diff shows one property per line
+		if oldText, err = diff.Indent(oldValue); err != nil {
+			return nil, err
+		}
+		if newText, err = diff.Indent(newValue); err != nil {
+			return nil, err
+		}
+	default:
+		oldText = expression.ValueToString(oldValue)
+		newText = expression.ValueToString(newValue)
+	}
+
+	lines := diff.Lines(oldText, newText)
+
+	additions, deletions := 0, 0
+	lineChanges := make([]interface{}, 0)
+	for _, line := range lines {
+		switch line.Op {
+		case diff.OpAdded:
+			additions++
+			lineChanges = append(lineChanges, map[string]interface{}{"op": line.Op, "line": line.New, "text": line.Text})
+		case diff.OpRemoved:
+			deletions++
+			lineChanges = append(lineChanges, map[string]interface{}{"op": line.Op, "line": line.Old, "text": line.Text})
+		}
+	}
+	if format != diff.FormatJSON {
+		changes = lineChanges
+	}
+
+	unified := diff.Unified(lines, "from", "to", contextLines)
+
+	outputs := map[string]interface{}{
+		"changed":   len(changes) > 0,
+		"format":    format,
+		"diff":      unified,
+		"changes":   changes,
+		"additions": additions,
+		"deletions": deletions,
+	}
+
+	if spec.Path != "" {
+		rendered, err := e.templateEngine.Render(spec.Path, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render path: %w", err)
+		}
+		path := expression.ValueToString(rendered)
+
+		target := path
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(execCtx.Cwd, target)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+
+		if err := os.WriteFile(target, []byte(unified), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		outputs["path"] = path
+	}
+
+	return NewStepResult(outputs), nil
+}
+
+// detectDiffFormat returns json when both values are objects or lists,
+// either rendered as such or as JSON text, otherwise text.
+func detectDiffFormat(values ...interface{}) string {
+	for _, value := range values {
+		decoded, err := decodeDiffValue(value)
+		if err != nil {
+			return diff.FormatText
+		}
+
+		switch decoded.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return diff.FormatText
+		}
+	}
+
+	return diff.FormatJSON
+}
+
+// decodeDiffValue decodes values rendered as JSON text, such as the outputs
+// of scripts, and converts other values to the types produced by decoding
+// JSON.
+func decodeDiffValue(value interface{}) (interface{}, error) {
+	var data []byte
+	if s, ok := value.(string); ok {
+		data = []byte(strings.TrimSpace(s))
+	} else {
+		var err error
+		if data, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ExecuteDiffStep_Text(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "compare", Diff: &ast.DiffStep{
+		From: "${{ inputs.previous }}",
+		To:   "${{ inputs.current }}",
+		Path: "diffs/copy.diff",
+	}}})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"previous": "Summer sale\nEverything must go\n",
+		"current":  "Summer sale\nEverything is 20% off\n",
+	})
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("compare")
+	require.True(t, ok)
+
+	unified := "--- from\n+++ to\n@@ -1,2 +1,2 @@\n Summer sale\n-Everything must go\n+Everything is 20% off\n"
+	assert.Equal(t, map[string]interface{}{
+		"changed": true,
+		"format":  "text",
+		"diff":    unified,
+		"changes": []interface{}{
+			map[string]interface{}{"op": "removed", "line": 2, "text": "Everything must go"},
+			map[string]interface{}{"op": "added", "line": 2, "text": "Everything is 20% off"},
+		},
+		"additions": 1,
+		"deletions": 1,
+		"path":      "diffs/copy.diff",
+	}, result.Output["outputs"])
+
+	written, err := os.ReadFile(filepath.Join(execCtx.Cwd, "diffs", "copy.diff"))
+	require.NoError(t, err)
+	assert.Equal(t, unified, string(written))
+}
+
+func TestExecutor_ExecuteDiffStep_JSON(t *testing.T) {
+	contextLines := 0
+	workflow := createTestWorkflow([]*ast.Step{{ID: "compare", Diff: &ast.DiffStep{
+		From:    "${{ inputs.previous }}",
+		To:      "${{ inputs.current }}",
+		Context: &contextLines,
+	}}})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"previous": `{"title": "Sale", "price": 10}`,
+		"current":  map[string]interface{}{"title": "Sale", "price": 8, "badge": "new"},
+	})
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("compare")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "json", outputs["format"])
+	assert.Equal(t, true, outputs["changed"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"path": "badge", "op": "added", "new": "new"},
+		map[string]interface{}{"path": "price", "op": "changed", "old": 10.0, "new": 8.0},
+	}, outputs["changes"])
+	assert.Equal(t, "--- from\n+++ to\n@@ -2 +2,2 @@\n-  \"price\": 10,\n+  \"badge\": \"new\",\n+  \"price\": 8,\n", outputs["diff"])
+}
+
+func TestExecutor_ExecuteDiffStep_Unchanged(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "compare", Diff: &ast.DiffStep{From: "same", To: "same"}}})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, nil)
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("compare")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, false, outputs["changed"])
+	assert.Equal(t, "", outputs["diff"])
+}
@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// ensembleCandidate is the answer of one agent of an ensemble step.
+type ensembleCandidate struct {
+	agent  string
+	model  string
+	answer string
+	usage  *execcontext.TokenUsage
+	score  float64
+	err    error
+	// skipped is set when the agent wasn't asked as the budget was
+	// exhausted.
+	skipped bool
+}
+
+// executeEnsembleStep sends the step's prompt to each of its agents
+// concurrently and reduces their answers to one, chosen by a judge agent,
+// by the best expression or otherwise by majority vote. Agents which fail
+// are left out of the reduction, the step only fails when every agent does.
+//
+// Steps with a budget ask their agents one at a time and stop asking once
+// the budget is exhausted, reducing the answers received so far. When the
+// budget runs out before the judge is asked the answers are reduced by vote.
+func (e *Executor) executeEnsembleStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Ensemble
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Strs("agents", spec.Agents).
+		Msg("Executing ensemble step")
+
+	rendered, err := e.templateEngine.Render(spec.Prompt, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render prompt: %w", err)
+	}
+	prompt := expression.ValueToString(rendered)
+
+	budget := e.newStepBudget(step)
+	var exhausted string
+	var exhaustedMu sync.Mutex
+
+	ask := func(candidate *ensembleCandidate, actionID string) {
+		if reason := budget.exhausted(); reason != "" {
+			exhaustedMu.Lock()
+			exhausted = reason
+			exhaustedMu.Unlock()
+			candidate.skipped = true
+			return
+		}
+
+		agent, pr, _, err := e.resolveStepAgent(execCtx, step, candidate.agent)
+		if err != nil {
+			candidate.err = err
+			return
+		}
+		candidate.model = agent.Model
+
+		candidate.answer, candidate.usage, candidate.err = e.generateText(execCtx, step, pr, agent, actionID, prompt)
+		e.recordSpend(agent, candidate.usage)
+	}
+
+	candidates := make([]*ensembleCandidate, len(spec.Agents))
+	var wg sync.WaitGroup
+	for i, name := range spec.Agents {
+		candidates[i] = &ensembleCandidate{agent: name}
+		actionID := fmt.Sprintf("ensemble-%d", i)
+
+		if step.Budget != nil {
+			ask(candidates[i], actionID)
+			continue
+		}
+
+		wg.Add(1)
+		go func(candidate *ensembleCandidate) {
+			defer wg.Done()
+			ask(candidate, actionID)
+		}(candidates[i])
+	}
+	wg.Wait()
+
+	usage := &execcontext.TokenUsage{}
+	var answered []*ensembleCandidate
+	var failures []string
+	for _, candidate := range candidates {
+		usage.Add(candidate.usage)
+		if candidate.skipped {
+			continue
+		}
+		if candidate.err != nil {
+			log.Debug().
+				Err(candidate.err).
+				Str("step_id", step.ID).
+				Str("agent", candidate.agent).
+				Msg("Ensemble agent failed")
+			failures = append(failures, fmt.Sprintf("%s: %s", candidate.agent, candidate.err))
+			continue
+		}
+		answered = append(answered, candidate)
+	}
+
+	if len(answered) == 0 {
+		if exhausted != "" {
+			return nil, fmt.Errorf("no agent of the ensemble answered before the budget was exhausted, %s", exhausted)
+		}
+		return nil, fmt.Errorf("every agent of the ensemble failed: %s", strings.Join(failures, "; "))
+	}
+
+	if spec.Judge != "" && exhausted == "" {
+		exhausted = budget.exhausted()
+	}
+	if exhausted != "" {
+		e.stopEarly(execCtx, step, exhausted)
+	}
+
+	outputs := map[string]interface{}{}
+	var winner *ensembleCandidate
+	switch {
+	case spec.Judge != "" && exhausted == "":
+		var reason string
+		var judgeUsage *execcontext.TokenUsage
+		winner, reason, judgeUsage, err = e.judgeEnsemble(execCtx, step, prompt, answered)
+		usage.Add(judgeUsage)
+		if err != nil {
+			return nil, err
+		}
+		outputs["reason"] = reason
+	case spec.Best != "":
+		for _, candidate := range answered {
+			candidateCtx := execCtx.NewChild(nil)
+			candidateCtx.SetStepResult(step.ID, &execcontext.StepResult{
+				StepID:   step.ID,
+				Status:   execcontext.StepStatusCompleted,
+				Output:   ensembleCandidateResult(candidate).Output,
+				Response: candidate.answer,
+			})
+
+			candidate.score, err = e.evaluateScore(candidateCtx, spec.Best)
+			if err != nil {
+				return nil, fmt.Errorf("failed to score the answer of %s: %w", candidate.agent, err)
+			}
+			if winner == nil || candidate.score > winner.score {
+				winner = candidate
+			}
+		}
+	default:
+		var votes int
+		winner, votes = tallyEnsembleVotes(answered)
+		outputs["votes"] = votes
+		outputs["agreement"] = float64(votes) / float64(len(answered))
+	}
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("agent", winner.agent).
+		Msg("Ensemble answer chosen")
+
+	candidateOutputs := make([]interface{}, 0, len(candidates))
+	for _, candidate := range candidates {
+		candidateOutput := map[string]interface{}{
+			"agent": candidate.agent,
+			"model": candidate.model,
+		}
+		switch {
+		case candidate.skipped:
+			candidateOutput["skipped"] = true
+		case candidate.err != nil:
+			candidateOutput["error"] = candidate.err.Error()
+		default:
+			candidateOutput["answer"] = candidate.answer
+			candidateOutput["chosen"] = candidate == winner
+			if spec.Best != "" {
+				candidateOutput["score"] = candidate.score
+			}
+		}
+		candidateOutputs = append(candidateOutputs, candidateOutput)
+	}
+
+	outputs["answer"] = winner.answer
+	outputs["agent"] = winner.agent
+	outputs["candidates"] = candidateOutputs
+	setBudgetOutputs(outputs, exhausted)
+
+	result := NewStepResult(outputs, winner.answer)
+	result.TokenUsage = usage
+
+	return result, nil
+}
+
+// ensembleCandidateResult is the result of an ensemble step while its best
+// expression scores the candidate's answer.
+func ensembleCandidateResult(candidate *ensembleCandidate) *StepResult {
+	return NewStepResult(map[string]interface{}{
+		"answer": candidate.answer,
+		"agent":  candidate.agent,
+		"model":  candidate.model,
+	}, candidate.answer)
+}
+
+// tallyEnsembleVotes chooses the answer given by the most agents, comparing
+// answers regardless of case, whitespace and trailing punctuation. Ties are
+// broken by the order of the agents.
+func tallyEnsembleVotes(candidates []*ensembleCandidate) (*ensembleCandidate, int) {
+	counts := make(map[string]int)
+	for _, candidate := range candidates {
+		counts[normalizeEnsembleAnswer(candidate.answer)]++
+	}
+
+	var winner *ensembleCandidate
+	for _, candidate := range candidates {
+		if winner == nil || counts[normalizeEnsembleAnswer(candidate.answer)] > counts[normalizeEnsembleAnswer(winner.answer)] {
+			winner = candidate
+		}
+	}
+
+	return winner, counts[normalizeEnsembleAnswer(winner.answer)]
+}
+
+func normalizeEnsembleAnswer(answer string) string {
+	answer = strings.ToLower(strings.Join(strings.Fields(answer), " "))
+	return strings.TrimRight(answer, ".!")
+}
+
+// judgeEnsemble asks the judge agent to choose the best of the answers.
+func (e *Executor) judgeEnsemble(execCtx *execcontext.ExecutionContext, step *ast.Step, prompt string, candidates []*ensembleCandidate) (*ensembleCandidate, string, *execcontext.TokenUsage, error) {
+	spec := step.Ensemble
+
+	agent, pr, _, err := e.resolveStepAgent(execCtx, step, spec.Judge)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Several assistants answered the question below. Choose the best answer.\n")
+	if spec.Instructions != "" {
+		instructions, err := e.templateEngine.Render(spec.Instructions, execCtx)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("failed to render instructions: %w", err)
+		}
+		builder.WriteString("\n" + expression.ValueToString(instructions) + "\n")
+	}
+	builder.WriteString("\n<question>\n" + prompt + "\n</question>\n")
+	for i, candidate := range candidates {
+		builder.WriteString(fmt.Sprintf("\n<answer number=\"%d\">\n%s\n</answer>\n", i+1, candidate.answer))
+	}
+	builder.WriteString("\nRespond with the number of the best answer and a one sentence reason.")
+
+	retries := defaultStructuredRetries
+	if spec.Retries != nil {
+		retries = *spec.Retries
+	}
+
+	responseSchema := &provider.ResponseSchema{
+		Name:        "ensemble_" + step.ID,
+		Description: "Record the number of the best answer",
+		Schema:      ensembleJudgeSchema(len(candidates)),
+	}
+
+	response, usage, err := e.generateStructured(execCtx, step, pr, agent, builder.String(), responseSchema, retries)
+	e.recordSpend(agent, usage)
+	if err != nil {
+		return nil, "", usage, err
+	}
+
+	choice, _ := response["choice"].(float64)
+	if int(choice) < 1 || int(choice) > len(candidates) {
+		return nil, "", usage, fmt.Errorf("judge chose answer %v, there are %d answers", response["choice"], len(candidates))
+	}
+
+	reason, _ := response["reason"].(string)
+	return candidates[int(choice)-1], reason, usage, nil
+}
+
+// ensembleJudgeSchema is the response schema of the judge of an ensemble
+// step, the choice is the 1-based number of an answer.
+func ensembleJudgeSchema(answers int) schema.JSON {
+	return schema.JSON{
+		Type: "object",
+		Properties: map[string]schema.JSON{
+			"choice": {
+				Type:        "integer",
+				Minimum:     1,
+				Maximum:     float64(answers),
+				Description: "The number of the best answer",
+			},
+			"reason": {
+				Type:        "string",
+				Description: "One sentence explaining why the answer is the best",
+			},
+		},
+		Required:             []string{"choice", "reason"},
+		AdditionalProperties: false,
+	}
+}
@@ -0,0 +1,238 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modelAnswerProvider answers with the answer of the request's model, or
+// fails when the model has none. Requests with a response schema are
+// answered with the judge's choice.
+type modelAnswerProvider struct {
+	answers  map[string]string
+	choice   int
+	requests []*provider.Request
+	mu       sync.Mutex
+}
+
+func (p *modelAnswerProvider) Generate(_ provider.GenerateContext, request *provider.Request, _ chan<- pkgEvents.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, request)
+	p.mu.Unlock()
+
+	response := provider.NewTextBlock(p.answers[request.Model])
+	if request.ResponseSchema != nil {
+		input, _ := json.Marshal(map[string]interface{}{"choice": p.choice, "reason": "it is the most complete"})
+		response = provider.NewToolUseBlock("call_1", input, request.ResponseSchema.Name)
+	} else if _, ok := p.answers[request.Model]; !ok {
+		return nil, nil, errors.New("model overloaded")
+	}
+
+	return []provider.Message{{
+		Role:    "assistant",
+		Content: []provider.ContentBlockParamUnion{response},
+	}}, &execcontext.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+}
+
+func (p *modelAnswerProvider) GetName() string { return "anthropic" }
+
+func (p *modelAnswerProvider) ListModels(context.Context) ([]provider.Info, error) {
+	return []provider.Info{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "broken"}}, nil
+}
+
+func (p *modelAnswerProvider) Close() error { return nil }
+
+func ensembleWorkflow(step *ast.Step) *ast.Workflow {
+	workflow := createTestWorkflow([]*ast.Step{step})
+	workflow.Agents = map[string]*ast.Agent{}
+	for _, model := range []string{"a", "b", "c", "broken"} {
+		workflow.Agents[model] = &ast.Agent{Name: model, Provider: "anthropic", Model: model}
+	}
+	return workflow
+}
+
+func TestExecutor_ExecuteEnsembleStep_Vote(t *testing.T) {
+	pr := &modelAnswerProvider{answers: map[string]string{"a": "Lyon", "b": "Paris.", "c": " paris "}}
+
+	workflow := ensembleWorkflow(&ast.Step{ID: "answer", Ensemble: &ast.EnsembleStep{
+		Agents: []string{"a", "b", "c", "broken"},
+		Prompt: "${{ inputs.question }}",
+	}})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "What is the capital of France?"}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("answer")
+	require.True(t, ok)
+	assert.Equal(t, "Paris.", result.Output["output"])
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "b", outputs["agent"])
+	assert.Equal(t, 2, outputs["votes"])
+	assert.InDelta(t, 2.0/3.0, outputs["agreement"], 1e-9)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"agent": "a", "model": "a", "answer": "Lyon", "chosen": false},
+		map[string]interface{}{"agent": "b", "model": "b", "answer": "Paris.", "chosen": true},
+		map[string]interface{}{"agent": "c", "model": "c", "answer": "paris", "chosen": false},
+		map[string]interface{}{"agent": "broken", "model": "broken", "error": "model generation failed: model overloaded"},
+	}, outputs["candidates"])
+	assert.Equal(t, 45, result.TokenUsage.TotalTokens)
+
+	require.Len(t, pr.requests, 4)
+	assert.Equal(t, "What is the capital of France?", pr.requests[0].GetPrompt())
+}
+
+func TestExecutor_ExecuteEnsembleStep_Judge(t *testing.T) {
+	pr := &modelAnswerProvider{
+		answers: map[string]string{"a": "Paris", "b": "Paris, on the Seine", "c": "Lyon"},
+		choice:  2,
+	}
+
+	workflow := ensembleWorkflow(&ast.Step{ID: "answer", Ensemble: &ast.EnsembleStep{
+		Agents:       []string{"a", "b"},
+		Prompt:       "${{ inputs.question }}",
+		Judge:        "c",
+		Instructions: "Prefer the most complete answer",
+	}})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "What is the capital of France?"}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("answer")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "Paris, on the Seine", outputs["answer"])
+	assert.Equal(t, "b", outputs["agent"])
+	assert.Equal(t, "it is the most complete", outputs["reason"])
+	assert.Equal(t, 45, result.TokenUsage.TotalTokens)
+
+	require.Len(t, pr.requests, 3)
+	judge := pr.requests[2]
+	assert.Equal(t, "c", judge.Model)
+	assert.Equal(t, float64(2), judge.ResponseSchema.Schema.Properties["choice"].Maximum)
+
+	prompt := judge.GetPrompt()
+	assert.Contains(t, prompt, "Prefer the most complete answer")
+	assert.Contains(t, prompt, "<question>\nWhat is the capital of France?\n</question>")
+	assert.Contains(t, prompt, "<answer number=\"1\">\nParis\n</answer>\n\n<answer number=\"2\">\nParis, on the Seine\n</answer>")
+}
+
+func TestExecutor_ExecuteEnsembleStep_Best(t *testing.T) {
+	pr := &modelAnswerProvider{answers: map[string]string{"a": "Paris", "b": "Paris, on the Seine"}}
+
+	workflow := ensembleWorkflow(&ast.Step{ID: "answer", Ensemble: &ast.EnsembleStep{
+		Agents: []string{"a", "b"},
+		Prompt: "${{ inputs.question }}",
+		Best:   "${{ length(steps.answer.output) }}",
+	}})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "What is the capital of France?"}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("answer")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "b", outputs["agent"])
+
+	candidates := outputs["candidates"].([]interface{})
+	assert.Equal(t, 5.0, candidates[0].(map[string]interface{})["score"])
+	assert.Equal(t, 19.0, candidates[1].(map[string]interface{})["score"])
+}
+
+func TestExecutor_ExecuteEnsembleStep_AllFail(t *testing.T) {
+	pr := &modelAnswerProvider{}
+
+	workflow := ensembleWorkflow(&ast.Step{ID: "answer", Ensemble: &ast.EnsembleStep{
+		Agents: []string{"broken", "broken"},
+		Prompt: "${{ inputs.question }}",
+	}})
+
+	_, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "What is the capital of France?"}, withProviders(pr))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "every agent of the ensemble failed: broken: model generation failed: model overloaded")
+}
+
+func TestExecutor_ExecuteEnsembleStep_BudgetExhausted(t *testing.T) {
+	pr := &modelAnswerProvider{
+		answers: map[string]string{"a": "Lyon", "b": "Paris", "c": "Paris"},
+		choice:  2,
+	}
+
+	// each answer uses 15 tokens so the budget is spent after two agents
+	workflow := ensembleWorkflow(&ast.Step{
+		ID: "answer",
+		Ensemble: &ast.EnsembleStep{
+			Agents: []string{"a", "b", "c"},
+			Prompt: "${{ inputs.question }}",
+			Judge:  "c",
+		},
+		Budget: &ast.StepBudget{Tokens: 20},
+	})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "What is the capital of France?"}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("answer")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, true, outputs["budget_exhausted"])
+	assert.Equal(t, "the step's budget of 20 tokens is spent", outputs["budget_reason"])
+
+	// the judge isn't asked, the answers so far are reduced by vote
+	assert.Equal(t, "a", outputs["agent"])
+	assert.Equal(t, 1, outputs["votes"])
+	assert.NotContains(t, outputs, "reason")
+	assert.Equal(t, map[string]interface{}{"agent": "c", "model": "", "skipped": true}, outputs["candidates"].([]interface{})[2])
+
+	require.Len(t, pr.requests, 2)
+	assert.Equal(t, 30, result.TokenUsage.TotalTokens)
+}
+
+func TestExecutor_ExecuteEnsembleStep_WithinBudget(t *testing.T) {
+	pr := &modelAnswerProvider{answers: map[string]string{"a": "Paris", "b": "Paris"}}
+
+	workflow := ensembleWorkflow(&ast.Step{
+		ID: "answer",
+		Ensemble: &ast.EnsembleStep{
+			Agents: []string{"a", "b"},
+			Prompt: "${{ inputs.question }}",
+		},
+		Budget: &ast.StepBudget{Tokens: 100},
+	})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "What is the capital of France?"}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("answer")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, false, outputs["budget_exhausted"])
+	assert.NotContains(t, outputs, "budget_reason")
+	assert.Equal(t, 2, outputs["votes"])
+}
+
+func TestTallyEnsembleVotes(t *testing.T) {
+	// ties are broken by the order of the agents
+	winner, votes := tallyEnsembleVotes([]*ensembleCandidate{
+		{agent: "a", answer: "yes"},
+		{agent: "b", answer: "No"},
+		{agent: "c", answer: "no!"},
+		{agent: "d", answer: "Yes."},
+	})
+	assert.Equal(t, "a", winner.agent)
+	assert.Equal(t, 2, votes)
+}
@@ -0,0 +1,19 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+)
+
+// fileDigest returns the SHA-256 digest of a file, e.g. sha256:9f86d0...,
+// empty when it can't be read.
+func fileDigest(path string) string {
+	data, err := os.ReadFile(path) // #nosec G304 - path is a block referenced by the workflow
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
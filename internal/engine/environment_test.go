@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteWorkflow_StepEnvironment(t *testing.T) {
+	pr := &scriptedProvider{name: "anthropic", snapshot: "test-model-20250101", responses: []provider.ContentBlockParamUnion{
+		provider.NewTextBlock("LGTM"),
+	}}
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "review", Agent: "reviewer", Prompt: "Review the diff"},
+		{ID: "lint", Run: "echo -n 'ok'"},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"reviewer": {Name: "reviewer", Provider: pr.name, Model: "test-model"},
+	}
+
+	registry := provider.NewRegistry(false)
+	require.NoError(t, registry.RegisterProvider(pr))
+
+	executor, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, DefaultExecutorConfig(), workflow, registry, &Runner{})
+	require.NoError(t, err)
+	// the runtimes would otherwise be downloaded
+	executor.(*Executor).runtimes = map[string]string{"go": "go1.24.4"}
+
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{Context: context.Background()}, workflow, map[string]interface{}{}, t.TempDir())
+	eventsChan, collector := collectProgressEvents()
+	err = executor.ExecuteWorkflow(execCtx, eventsChan)
+	close(eventsChan)
+	collector.waitForCompletion()
+	require.NoError(t, err)
+
+	review, ok := execCtx.GetStepResult("review")
+	require.True(t, ok)
+	assert.Equal(t, &execcontext.StepEnvironment{Provider: "anthropic", Model: "test-model", ModelSnapshot: "test-model-20250101"}, review.Environment)
+
+	lint, ok := execCtx.GetStepResult("lint")
+	require.True(t, ok)
+	assert.Equal(t, &execcontext.StepEnvironment{Runtimes: map[string]string{"go": "go1.24.4"}}, lint.Environment)
+
+	// the environment is recorded in the run history
+	result := &ExecutionResult{RunID: execCtx.RunID}
+	collectExecutionResults(execCtx, result)
+	run := newHistoryRun(workflow, result)
+	require.Len(t, run.Steps, 2)
+	assert.Equal(t, &history.Environment{Provider: "anthropic", Model: "test-model", ModelSnapshot: "test-model-20250101"}, run.Steps[0].Environment)
+	assert.Equal(t, &history.Environment{Runtimes: map[string]string{"go": "go1.24.4"}}, run.Steps[1].Environment)
+}
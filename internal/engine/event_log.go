@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/utils"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+)
+
+// DefaultPreviewLength is the default maximum number of characters of event
+// text, such as prompt previews and tool calls, delivered to progress
+// listeners.
+const DefaultPreviewLength = 200
+
+// RunLog records every event of a run, with its full untruncated text, as
+// JSON lines. Lines are encrypted when the log has a cipher, as the text of
+// events holds prompts and outputs.
+type RunLog struct {
+	path   string
+	file   *os.File
+	cipher *encryption.Cipher
+}
+
+// OpenRunLog creates the run log for the given run ID in dir, encrypting
+// its events with the cipher when it isn't nil.
+func OpenRunLog(dir, runID string, cipher *encryption.Cipher) (*RunLog, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create run log directory: %w", err)
+	}
+
+	runLog, err := appendLog(filepath.Join(dir, runID+".jsonl"), cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run log: %w", err)
+	}
+
+	return runLog, nil
+}
+
+// OpenEventLog opens the event log at path, creating it and its directory
+// when they don't exist. Events are appended to the log, so one log can hold
+// the events of many runs. Events are encrypted with the cipher when it isn't
+// nil.
+func OpenEventLog(path string, cipher *encryption.Cipher) (*RunLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create event log directory: %w", err)
+	}
+
+	eventLog, err := appendLog(path, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+
+	return eventLog, nil
+}
+
+func appendLog(path string, cipher *encryption.Cipher) (*RunLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600) // #nosec G304 - path is a run log or configured by the user
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunLog{
+		path:   path,
+		file:   file,
+		cipher: cipher,
+	}, nil
+}
+
+// Path returns the location of the run log.
+func (l *RunLog) Path() string {
+	return l.path
+}
+
+// Write appends the event to the run log. Styling is always removed as the
+// log is not intended to be rendered by a terminal.
+func (l *RunLog) Write(event pkgEvents.ExecutionEvent) error {
+	line, err := json.Marshal(stripEventStyling(event))
+	if err != nil {
+		return err
+	}
+
+	line, err = l.cipher.SealLine(line)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt event: %w", err)
+	}
+
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the underlying file.
+func (l *RunLog) Close() error {
+	return l.file.Close()
+}
+
+// prepareEvent readies an event for the progress listener, removing styling
+// for plain text listeners and truncating the event text to the preview
+// length. Truncated events are marked in their metadata along with the
+// location of the run log holding the full text. Token deltas are never
+// truncated, they're chunks of a longer text rather than previews.
+func (r *Runner) prepareEvent(event pkgEvents.ExecutionEvent, runLog *RunLog) pkgEvents.ExecutionEvent {
+	if r.plainEvents {
+		event = stripEventStyling(event)
+	}
+
+	if r.previewLength <= 0 || event.Type == pkgEvents.EventTokenDelta {
+		return event
+	}
+
+	text := truncateEventText(event.Text, r.previewLength)
+	if text == event.Text {
+		return event
+	}
+
+	metadata := make(map[string]interface{}, len(event.Metadata)+2)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata["truncated"] = true
+	if runLog != nil {
+		metadata["run_log"] = runLog.Path()
+	}
+
+	event.Text = text
+	event.Metadata = metadata
+
+	return event
+}
+
+// truncateEventText shortens text to maxLength visible characters, styled
+// text is truncated without breaking its escape sequences.
+func truncateEventText(text string, maxLength int) string {
+	if !strings.Contains(text, "\x1b") {
+		return utils.TruncateText(text, maxLength)
+	}
+
+	if ansi.StringWidth(text) <= maxLength {
+		return text
+	}
+
+	return ansi.Truncate(text, maxLength+3, "...")
+}
+
+// stripEventStyling removes ANSI styling from every text field of the event.
+func stripEventStyling(event pkgEvents.ExecutionEvent) pkgEvents.ExecutionEvent {
+	event.Text = ansi.Strip(event.Text)
+	event.Error = ansi.Strip(event.Error)
+
+	if len(event.Diagnostics) > 0 {
+		diagnostics := make([]string, len(event.Diagnostics))
+		for i, diagnostic := range event.Diagnostics {
+			diagnostics[i] = ansi.Strip(diagnostic)
+		}
+		event.Diagnostics = diagnostics
+	}
+
+	return event
+}
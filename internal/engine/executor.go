@@ -1,13 +1,18 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/lacquerai/lacquer/internal/ast"
@@ -19,9 +24,13 @@ import (
 	"github.com/lacquerai/lacquer/internal/provider/anthropic"
 	"github.com/lacquerai/lacquer/internal/provider/claudecode"
 	"github.com/lacquerai/lacquer/internal/provider/openai"
+	"github.com/lacquerai/lacquer/internal/routing"
 	"github.com/lacquerai/lacquer/internal/runtime"
+	"github.com/lacquerai/lacquer/internal/runtime/ollama"
+	"github.com/lacquerai/lacquer/internal/schema"
 	"github.com/lacquerai/lacquer/internal/tools"
 	"github.com/lacquerai/lacquer/internal/tools/mcp"
+	"github.com/lacquerai/lacquer/internal/tools/official"
 	"github.com/lacquerai/lacquer/internal/tools/script"
 	"github.com/lacquerai/lacquer/internal/utils"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
@@ -45,7 +54,44 @@ type Executor struct {
 	blockManager   *block.Manager
 	runner         *Runner
 
-	execCtx *execcontext.ExecutionContext
+	execCtx      *execcontext.ExecutionContext
+	fingerprints map[string]string
+	fromIndex    int
+
+	// checkpointFingerprints are the fingerprints of the top-level steps
+	// recorded in checkpoints, resumeState is set when a resumed run
+	// continues with the state recorded by its checkpoint.
+	checkpointFingerprints map[string]string
+	resumeState            bool
+
+	router  *routing.Router
+	spendMu sync.Mutex
+	spent   float64
+	tokens  int
+
+	// exceedBudget cancels the run once the workflow's budget is exceeded,
+	// nil when the workflow has no budget
+	exceedBudget context.CancelCauseFunc
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*agentSession
+
+	// ollama is the session of the ollama runtime required by the workflow,
+	// it is closed once the workflow has finished.
+	ollama *ollama.Session
+
+	// moderator checks prompts and outputs of models, nil when there are
+	// no moderation policies.
+	moderator *moderator
+
+	// runtimes is the version of each runtime required by the workflow,
+	// recorded in the environment of script steps.
+	runtimes map[string]string
+
+	// dedupeClaims are the keys recorded by the dedupe steps of the run,
+	// with the value recorded for each, released when the run fails.
+	dedupeMu     sync.Mutex
+	dedupeClaims map[string]string
 }
 
 // ExecutorConfig defines the runtime behavior and limits for workflow execution.
@@ -57,6 +103,75 @@ type ExecutorConfig struct {
 	MaxRetries         int           `yaml:"max_retries"`
 	RetryDelay         time.Duration `yaml:"retry_delay"`
 	EnableMetrics      bool          `yaml:"enable_metrics"`
+
+	// UntilStep stops the workflow once the top-level step with the given
+	// ID has completed. Workflow outputs are not collected for partial runs.
+	UntilStep string `yaml:"-"`
+
+	// StepCache, when set, is used to reuse results of unchanged top-level
+	// steps from previous runs and is updated with the results of this run.
+	StepCache *StepCache `yaml:"-"`
+
+	// FromStep starts the workflow at the top-level step with the given ID.
+	// Every step before it is restored from SeedResults instead of being
+	// executed.
+	FromStep string `yaml:"-"`
+
+	// SeedResults contains the results, keyed by step ID, used for steps
+	// which are not executed when FromStep is set.
+	SeedResults map[string]*CachedStep `yaml:"-"`
+
+	// Checkpoints, when set, records the progress of the run after each
+	// top-level step so that it can be resumed if it fails.
+	Checkpoints *CheckpointStore `yaml:"-"`
+
+	// Resume continues the run recorded by the checkpoint, its completed
+	// top-level steps are restored instead of being executed.
+	Resume *Checkpoint `yaml:"-"`
+
+	// Normalization is the unicode normalization form applied to step
+	// outputs. Invalid UTF-8 in step outputs is always repaired.
+	Normalization utils.Normalization `yaml:"normalization"`
+
+	// Budget is the cost budget of the run in USD. Agents which specify a
+	// tier are routed to cheaper models as the budget is spent, zero means
+	// the run has no budget.
+	Budget float64 `yaml:"budget"`
+
+	// RoutingModels are added to the default routing table used for agents
+	// which specify a tier, replacing default entries for the same model.
+	RoutingModels []routing.Model `yaml:"routing_models"`
+
+	// Search configures the backends of the official web-search tool.
+	Search official.SearchConfig `yaml:"search"`
+
+	// ProviderObserver, when set, is told the outcome of every request sent
+	// to a model provider.
+	ProviderObserver ProviderObserver `yaml:"-"`
+	// Metrics, when set, record the duration of steps and model requests,
+	// token usage, failed tool calls and retries
+	Metrics *Metrics `yaml:"-"`
+
+	// Breakers, when set, fail requests to providers which keep failing
+	// fast, and switch agents with a fallback to it while they do.
+	Breakers *provider.Breakers `yaml:"-"`
+
+	// Moderation are the policies checking the prompts sent to models and
+	// their responses.
+	Moderation Moderation `yaml:"-"`
+
+	// ModelLock, when set, pins the model aliases of agents to the
+	// snapshots locked in the workflow's lock file.
+	ModelLock *ModelLock `yaml:"-"`
+}
+
+// ProviderObserver is told the outcome of requests sent to model providers,
+// for example to track their error rates.
+type ProviderObserver interface {
+	// ObserveProviderRequest is called once a request to the named provider
+	// has finished, err is nil when it succeeded. Requests cancelled by the
+	// run aren't observed.
+	ObserveProviderRequest(provider string, err error)
 }
 
 // DefaultExecutorConfig returns production-ready configuration values with
@@ -97,10 +212,20 @@ func NewExecutor(ctx execcontext.RunContext, config *ExecutorConfig, workflow *a
 
 	// Only initialize providers that are used in the workflow
 	requiredProviders := getRequiredProviders(workflow)
-	if err := initializeRequiredProviders(registry, requiredProviders); err != nil {
+	for _, name := range config.Moderation.apiProviders() {
+		if _, ok := requiredProviders[name]; !ok {
+			requiredProviders[name] = nil
+		}
+	}
+	if err := initializeRequiredProviders(ctx.Context, registry, requiredProviders); err != nil {
 		return nil, fmt.Errorf("failed to initialize required providers: %w", err)
 	}
 
+	moderator, err := newModerator(config.Moderation, registry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid moderation configuration: %w", err)
+	}
+
 	cacheDir := filepath.Join(os.TempDir(), "laq-blocks")
 	blockManager, err := block.NewManager(cacheDir)
 	if err != nil {
@@ -113,30 +238,51 @@ func NewExecutor(ctx execcontext.RunContext, config *ExecutorConfig, workflow *a
 	}
 
 	// install any requirements so any script steps can be executed
+	var ollamaSession *ollama.Session
+	runtimes := make(map[string]string)
 	if workflow.Requirements != nil {
 		for _, runtime := range workflow.Requirements.Runtimes {
-			if runtime.Version != "" {
-				_, err := runtimeManager.Get(ctx.Context, string(runtime.Name), runtime.Version)
+			if runtime.Name == ast.RuntimeTypeOllama {
+				ollamaSession, err = ollama.NewClient("").Prepare(ctx.Context, runtime.Models)
 				if err != nil {
-					return nil, fmt.Errorf("failed to get runtime %s: %w", runtime.Name, err)
+					return nil, fmt.Errorf("failed to prepare ollama runtime: %w", err)
 				}
 				continue
 			}
 
-			_, err := runtimeManager.GetLatest(ctx.Context, string(runtime.Name))
+			version := runtime.Version
+			if version == "" {
+				version, err = runtimeManager.LatestVersion(ctx.Context, string(runtime.Name))
+				if err != nil {
+					return nil, fmt.Errorf("failed to get latest runtime %s: %w", runtime.Name, err)
+				}
+			}
+
+			_, err := runtimeManager.Get(ctx.Context, string(runtime.Name), version)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get latest runtime %s: %w", runtime.Name, err)
+				return nil, fmt.Errorf("failed to get runtime %s: %w", runtime.Name, err)
 			}
+			runtimes[string(runtime.Name)] = version
 		}
 	}
 
 	toolRegistry := tools.NewRegistry()
 
-	if err := initializeToolProviders(toolRegistry, workflow, cacheDir); err != nil {
+	if err := initializeToolProviders(ctx.Context, toolRegistry, workflow, cacheDir, config); err != nil {
+		if ollamaSession != nil {
+			_ = ollamaSession.Close(ctx.Context)
+		}
 		return nil, fmt.Errorf("failed to initialize tool providers: %w", err)
 	}
 
-	return &Executor{
+	if err := validateAgentTools(workflow, registry, toolRegistry); err != nil {
+		if ollamaSession != nil {
+			_ = ollamaSession.Close(ctx.Context)
+		}
+		return nil, err
+	}
+
+	executor := &Executor{
 		templateEngine: expression.NewTemplateEngine(),
 		modelRegistry:  registry,
 		toolRegistry:   toolRegistry,
@@ -144,16 +290,30 @@ func NewExecutor(ctx execcontext.RunContext, config *ExecutorConfig, workflow *a
 		outputParser:   NewOutputParser(),
 		blockManager:   blockManager,
 		runner:         runner,
-	}, nil
+		router:         routing.NewRouter(append(routing.DefaultModels(), config.RoutingModels...)),
+		ollama:         ollamaSession,
+		moderator:      moderator,
+		runtimes:       runtimes,
+	}
+	executor.registerLanguageFunctions()
+
+	return executor, nil
 }
 
 // ExecuteWorkflow runs the complete workflow, executing steps sequentially while
 // respecting dependencies and conditional logic. Progress events are sent to the
 // provided channel for real-time monitoring. Returns an error if any step fails
 // or if workflow output collection encounters issues.
-func (e *Executor) ExecuteWorkflow(execCtx *execcontext.ExecutionContext, progressChan chan<- pkgEvents.ExecutionEvent) error {
+func (e *Executor) ExecuteWorkflow(execCtx *execcontext.ExecutionContext, progressChan chan<- pkgEvents.ExecutionEvent) (err error) {
 	e.execCtx = execCtx
 	e.progressChan = progressChan
+	e.dedupeClaims = nil
+	defer e.closeRuntimes()
+	defer func() {
+		if err != nil {
+			e.releaseDedupeClaims(execCtx)
+		}
+	}()
 	log.Info().
 		Str("workflow", getWorkflowNameFromContext(execCtx)).
 		Str("run_id", execCtx.RunID).
@@ -168,11 +328,35 @@ func (e *Executor) ExecuteWorkflow(execCtx *execcontext.ExecutionContext, progre
 		}
 	}
 
-	if err := e.executeSteps(execCtx, execCtx.Workflow.Workflow.Steps); err != nil {
+	if e.config.StepCache != nil {
+		e.fingerprints = stepFingerprints(execCtx.Workflow, execCtx.Inputs)
+	}
+
+	if e.config.Checkpoints != nil || e.config.Resume != nil {
+		e.checkpointFingerprints = checkpointFingerprints(execCtx.Workflow, execCtx.Inputs)
+	}
+
+	if e.config.Resume != nil {
+		e.resumeCheckpoint(execCtx)
+	} else {
+		e.saveCheckpoint(execCtx, 0)
+	}
+
+	if err := e.resolveFromStep(execCtx.Workflow); err != nil {
 		return err
 	}
 
-	if err := e.collectWorkflowOutputs(execCtx); err != nil {
+	finishTimeout := e.withWorkflowTimeout(execCtx)
+	finishBudget := e.withRunBudget(execCtx)
+	err = finishTimeout(finishBudget(e.executeSteps(execCtx, execCtx.Workflow.Workflow.Steps)))
+	if errors.Is(err, errUntilStepReached) {
+		log.Info().
+			Str("run_id", execCtx.RunID).
+			Str("step_id", e.config.UntilStep).
+			Msg("Stopping workflow after requested step")
+	} else if err != nil {
+		return err
+	} else if err := e.collectWorkflowOutputs(execCtx); err != nil {
 		log.Error().
 			Err(err).
 			Str("run_id", execCtx.RunID).
@@ -185,6 +369,7 @@ func (e *Executor) ExecuteWorkflow(execCtx *execcontext.ExecutionContext, progre
 			Type:      pkgEvents.EventWorkflowCompleted,
 			Timestamp: time.Now(),
 			RunID:     execCtx.RunID,
+			Metadata:  runClockMetadata(execCtx),
 		}
 	}
 
@@ -196,6 +381,22 @@ func (e *Executor) ExecuteWorkflow(execCtx *execcontext.ExecutionContext, progre
 	return nil
 }
 
+// closeRuntimes releases the runtimes prepared for the workflow, unloading
+// the ollama models it loaded.
+func (e *Executor) closeRuntimes() {
+	if e.ollama == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := e.ollama.Close(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to clean up ollama runtime")
+	}
+	e.ollama = nil
+}
+
 func (e *Executor) executeSteps(execCtx *execcontext.ExecutionContext, steps []*ast.Step) error {
 	for i, step := range steps {
 		if execCtx.IsCancelled() {
@@ -205,15 +406,36 @@ func (e *Executor) executeSteps(execCtx *execcontext.ExecutionContext, steps []*
 
 		execCtx.CurrentStepIndex = i
 
+		if execCtx.Parent == nil {
+			if cached, ok := e.lookupCachedStep(step, i); ok {
+				e.restoreStep(execCtx, step, cached)
+				if e.config.UntilStep == step.ID {
+					return errUntilStepReached
+				}
+				continue
+			}
+		}
+
+		// the duration is measured with the monotonic clock, the start and
+		// end times are the step's event and result timestamps
 		stepStart := time.Now()
 		err := e.executeStep(execCtx, step)
-		stepDuration := time.Since(stepStart)
+		stepEnd := time.Now()
+		stepDuration := stepEnd.Sub(stepStart)
+		if err != errStepSkipped {
+			status := "completed"
+			if err != nil {
+				status = "failed"
+			}
+			e.metrics().observeStep(step.GetStepType(), status, stepDuration)
+		}
 		if err != nil {
 			if err == errStepSkipped {
 				log.Debug().
 					Str("run_id", execCtx.RunID).
 					Str("step_id", step.ID).
 					Msg("Step skipped")
+				e.saveCheckpoint(execCtx, i+1)
 				continue
 			}
 
@@ -223,35 +445,55 @@ func (e *Executor) executeSteps(execCtx *execcontext.ExecutionContext, steps []*
 				Str("step_id", step.ID).
 				Msg("Step execution failed")
 
+			e.sendStepTimedOut(execCtx, step, i+1, err)
+
 			// Send step failed event
 			if e.progressChan != nil {
-				e.progressChan <- pkgEvents.ExecutionEvent{
+				event := pkgEvents.ExecutionEvent{
 					Type:      pkgEvents.EventStepFailed,
-					Timestamp: time.Now(),
+					Timestamp: stepEnd,
 					RunID:     execCtx.RunID,
 					StepID:    step.ID,
 					StepIndex: i + 1,
 					Duration:  stepDuration,
+					StartTime: stepStart,
 					Error:     err.Error(),
 				}
+				if category := provider.ErrorCategoryOf(err); category != provider.ErrorUnknown {
+					event.Metadata = map[string]interface{}{pkgEvents.MetadataErrorCategory: string(category)}
+				}
+				e.progressChan <- event
 			}
 
 			result := &execcontext.StepResult{
 				StepID:    step.ID,
 				Status:    execcontext.StepStatusFailed,
 				StartTime: stepStart,
-				EndTime:   time.Now(),
+				EndTime:   stepEnd,
 				Duration:  stepDuration,
 				Error:     err,
 			}
+			if failed, ok := execCtx.GetStepResult(step.ID); ok {
+				result.Retries = failed.Retries
+			}
 			execCtx.SetStepResult(step.ID, result)
 
-			if e.progressChan != nil {
+			if err = e.recoverStep(execCtx, step, result); err == nil {
+				e.saveCheckpoint(execCtx, i+1)
+				if execCtx.Parent == nil && e.config.UntilStep == step.ID {
+					return errUntilStepReached
+				}
+				continue
+			}
+
+			// sub steps report their failure to the step that runs them
+			if e.progressChan != nil && execCtx.Parent == nil {
 				e.progressChan <- pkgEvents.ExecutionEvent{
 					Type:      pkgEvents.EventWorkflowFailed,
 					Timestamp: time.Now(),
 					RunID:     execCtx.RunID,
 					Error:     err.Error(),
+					Metadata:  runClockMetadata(execCtx),
 				}
 			}
 
@@ -259,18 +501,167 @@ func (e *Executor) executeSteps(execCtx *execcontext.ExecutionContext, steps []*
 		} else if e.progressChan != nil {
 			e.progressChan <- pkgEvents.ExecutionEvent{
 				Type:      pkgEvents.EventStepCompleted,
-				Timestamp: time.Now(),
+				Timestamp: stepEnd,
 				RunID:     execCtx.RunID,
 				StepID:    step.ID,
 				StepIndex: i + 1,
 				Duration:  stepDuration,
+				StartTime: stepStart,
+				Metadata:  stepCostMetadata(execCtx, step),
+			}
+		}
+
+		if execCtx.Parent == nil {
+			e.cacheStepResult(execCtx, step)
+			e.saveCheckpoint(execCtx, i+1)
+
+			if e.config.UntilStep == step.ID {
+				return errUntilStepReached
 			}
 		}
+
+		if isDuplicate(execCtx, step) {
+			skipSteps(execCtx, steps[i+1:])
+			log.Info().
+				Str("run_id", execCtx.RunID).
+				Str("step_id", step.ID).
+				Msg("Key has already been seen, skipping the remaining steps")
+			return nil
+		}
 	}
 
 	return nil
 }
 
+// stepCostMetadata returns the cost labels and usage of a completed top-level
+// step for its completion event. The usage of sub steps is included in the
+// usage of the step which runs them so they have none.
+func stepCostMetadata(execCtx *execcontext.ExecutionContext, step *ast.Step) map[string]interface{} {
+	if execCtx.Parent != nil {
+		return nil
+	}
+
+	metadata := make(map[string]interface{})
+	costCenter, owner := execCtx.Workflow.StepCostLabels(step)
+	if costCenter != "" {
+		metadata[pkgEvents.MetadataCostCenter] = costCenter
+	}
+	if owner != "" {
+		metadata[pkgEvents.MetadataOwner] = owner
+	}
+
+	if result, ok := execCtx.GetStepResult(step.ID); ok && result.TokenUsage != nil {
+		metadata[pkgEvents.MetadataTokens] = result.TokenUsage.TotalTokens
+		metadata[pkgEvents.MetadataCost] = result.TokenUsage.Cost
+	}
+
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// resolveFromStep locates the step the workflow should start from and
+// ensures every step before it has a result to restore.
+func (e *Executor) resolveFromStep(workflow *ast.Workflow) error {
+	if e.config.FromStep == "" {
+		return nil
+	}
+
+	var missing []string
+	for i, step := range workflow.GetSteps() {
+		if step.ID == e.config.FromStep {
+			e.fromIndex = i
+			if len(missing) > 0 {
+				return fmt.Errorf("cannot start from step %q, no previous results or fixtures for step(s): %s", e.config.FromStep, strings.Join(missing, ", "))
+			}
+			return nil
+		}
+
+		if _, ok := e.config.SeedResults[step.ID]; !ok {
+			missing = append(missing, step.ID)
+		}
+	}
+
+	return fmt.Errorf("step %q does not exist in workflow", e.config.FromStep)
+}
+
+// lookupCachedStep returns a previously recorded result for the top-level
+// step at the given index if the step should not be executed. Steps before
+// the start step are restored from the seed results, any other step is
+// restored from the step cache when its fingerprint is unchanged.
+func (e *Executor) lookupCachedStep(step *ast.Step, index int) (*CachedStep, bool) {
+	if index < e.fromIndex {
+		cached, ok := e.config.SeedResults[step.ID]
+		return cached, ok
+	}
+
+	if e.config.StepCache == nil || !isCacheableStep(step) {
+		return nil, false
+	}
+
+	return e.config.StepCache.Get(e.fingerprints[step.ID])
+}
+
+// restoreStep applies a previously recorded step result in place of
+// executing the step, state updates are re-applied against the result
+// unless the state of a resumed run has been restored as it was.
+func (e *Executor) restoreStep(execCtx *execcontext.ExecutionContext, step *ast.Step, cached *CachedStep) {
+	log.Debug().
+		Str("run_id", execCtx.RunID).
+		Str("step_id", step.ID).
+		Msg("Reusing cached step result")
+
+	stepIndex := execCtx.CurrentStepIndex + 1
+	if e.progressChan != nil {
+		e.progressChan <- pkgEvents.ExecutionEvent{
+			Type:      pkgEvents.EventStepStarted,
+			Timestamp: time.Now(),
+			RunID:     execCtx.RunID,
+			StepID:    step.ID,
+			StepIndex: stepIndex,
+		}
+	}
+
+	restoreCachedStep(execCtx, step, cached)
+	execCtx.IncrementCurrentStep()
+	if !e.resumeState || stepIndex > e.fromIndex {
+		e.applyStateUpdates(execCtx, step)
+	}
+
+	if e.progressChan != nil {
+		e.progressChan <- pkgEvents.ExecutionEvent{
+			Type:      pkgEvents.EventStepCompleted,
+			Timestamp: time.Now(),
+			RunID:     execCtx.RunID,
+			StepID:    step.ID,
+			StepIndex: stepIndex,
+			Text:      "cached",
+			Metadata: map[string]interface{}{
+				pkgEvents.MetadataCached: true,
+			},
+		}
+	}
+}
+
+// cacheStepResult stores the result of a completed step in the step cache.
+func (e *Executor) cacheStepResult(execCtx *execcontext.ExecutionContext, step *ast.Step) {
+	if e.config.StepCache == nil || !isCacheableStep(step) {
+		return
+	}
+
+	result, ok := execCtx.GetStepResult(step.ID)
+	if !ok || result.Status != execcontext.StepStatusCompleted {
+		return
+	}
+
+	e.config.StepCache.Set(e.fingerprints[step.ID], &CachedStep{
+		StepID:   step.ID,
+		Output:   result.Output,
+		Response: result.Response,
+	})
+}
+
 // getWorkflowNameFromContext extracts workflow name from execution context
 func getWorkflowNameFromContext(execCtx *execcontext.ExecutionContext) string {
 	if execCtx.Workflow.Metadata != nil && execCtx.Workflow.Metadata.Name != "" {
@@ -280,7 +671,8 @@ func getWorkflowNameFromContext(execCtx *execcontext.ExecutionContext) string {
 }
 
 var (
-	errStepSkipped = fmt.Errorf("step skipped")
+	errStepSkipped      = fmt.Errorf("step skipped")
+	errUntilStepReached = fmt.Errorf("until step reached")
 )
 
 // executeStep executes a single workflow step
@@ -329,12 +721,16 @@ func (e *Executor) executeStep(execCtx *execcontext.ExecutionContext, step *ast.
 		}
 	}
 
-	var stepResult *StepResult
-	if step.IsWhileStep() {
-		stepResult, err = e.executeWhileStep(execCtx, step)
-	} else {
-		stepResult, err = e.collectStepResults(execCtx, step)
-	}
+	stepResult, err := e.executeWithRetry(execCtx, step, result, func() (*StepResult, error) {
+		return e.runWithTimeout(execCtx, step, func(stepCtx *execcontext.ExecutionContext) (*StepResult, error) {
+			if step.IsWhileStep() {
+				return e.executeWhileStep(stepCtx, step)
+			} else if step.IsForEachStep() {
+				return e.executeForEachStep(stepCtx, step)
+			}
+			return e.collectStepResults(stepCtx, step)
+		})
+	})
 	if err != nil {
 		result.Status = execcontext.StepStatusFailed
 		result.Error = err
@@ -343,11 +739,20 @@ func (e *Executor) executeStep(execCtx *execcontext.ExecutionContext, step *ast.
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(start)
-	result.Response = stepResult.Response
 	execCtx.IncrementCurrentStep()
 
+	// model, tool and script output may contain invalid or inconsistently
+	// encoded text, sanitize it once here so that templates, events, logs
+	// and the JSON APIs all see the same valid UTF-8.
 	result.Status = execcontext.StepStatusCompleted
-	result.Output = stepResult.Output
+	result.Response = utils.SanitizeText(stepResult.Response, e.config.Normalization)
+	result.Output, _ = utils.SanitizeValue(stepResult.Output, e.config.Normalization).(map[string]interface{})
+	result.TokenUsage = stepResult.TokenUsage
+	result.Routing = stepResult.Routing
+	result.Prompt = stepResult.Prompt
+	result.Environment = stepResult.Environment
+	result.Repairs = stepResult.Repairs
+	result.RepairUsage = stepResult.RepairUsage
 
 	// set the step result before the updates so that we can reference any outputs
 	// of the current step in the updates
@@ -355,6 +760,54 @@ func (e *Executor) executeStep(execCtx *execcontext.ExecutionContext, step *ast.
 
 	// now let's update the state if there are any updates, we can no reference the
 	// current step if needed.
+	e.applyStateUpdates(execCtx, step)
+
+	return nil
+}
+
+// recoverStep runs the on_failure steps of a failed step and returns nil when
+// the workflow should continue, which it does when the step has
+// continue_on_error set or its on_failure steps succeed. The outputs of the
+// on_failure steps become the outputs of the failed step.
+func (e *Executor) recoverStep(execCtx *execcontext.ExecutionContext, step *ast.Step, result *execcontext.StepResult) error {
+	if len(step.OnFailure) == 0 {
+		if !step.ContinueOnError {
+			return result.Error
+		}
+
+		log.Warn().
+			Err(result.Error).
+			Str("run_id", execCtx.RunID).
+			Str("step_id", step.ID).
+			Msg("Step failed, continuing")
+		return nil
+	}
+
+	log.Info().
+		Str("run_id", execCtx.RunID).
+		Str("step_id", step.ID).
+		Msg("Step failed, running on_failure steps")
+
+	handlerCtx := execCtx.NewChild(step.OnFailure)
+	err := e.executeSteps(handlerCtx, step.OnFailure)
+
+	handlerOutputs := make(map[string]interface{}, len(handlerCtx.StepResults))
+	for _, handler := range handlerCtx.StepResults {
+		handlerOutputs[handler.StepID] = handler.Output
+	}
+	result.Output = map[string]interface{}{"steps": handlerOutputs}
+	execCtx.SetStepResult(step.ID, result)
+
+	if err != nil && !step.ContinueOnError {
+		return fmt.Errorf("on_failure of step %s failed: %w", step.ID, err)
+	}
+
+	return nil
+}
+
+// applyStateUpdates renders the step's state updates and applies them to the
+// execution context.
+func (e *Executor) applyStateUpdates(execCtx *execcontext.ExecutionContext, step *ast.Step) {
 	if step.Updates != nil {
 		updates := make(map[string]interface{})
 		for key, value := range step.Updates {
@@ -373,16 +826,33 @@ func (e *Executor) executeStep(execCtx *execcontext.ExecutionContext, step *ast.
 		}
 
 		execCtx.UpdateState(updates)
-	}
 
-	return nil
+		if e.progressChan != nil {
+			e.progressChan <- events.NewStateUpdatedEvent(step.ID, execCtx.RunID, updates)
+		}
+	}
 }
 
 // StepResult contains the execution result of a workflow step, including
 // structured output data and the raw response from the execution.
 type StepResult struct {
-	Output   map[string]interface{}
-	Response string
+	Output     map[string]interface{}
+	Response   string
+	TokenUsage *execcontext.TokenUsage
+	Routing    *routing.Decision
+	// Prompt is the version of the library's prompt used by an agent step,
+	// e.g. review@v3
+	Prompt string
+	// Environment is the versions of the dependencies the step ran with
+	Environment *execcontext.StepEnvironment
+	// Repairs is the number of times the response was sent back to the agent
+	// to be corrected, RepairUsage is the part of TokenUsage they used
+	Repairs     int
+	RepairUsage *execcontext.TokenUsage
+
+	// compression is what was done to fit the prompt of an agent step in
+	// the context window of its model, nil when it wasn't compressed
+	compression *compression
 }
 
 // NewStepResult creates a StepResult from execution output, automatically
@@ -441,24 +911,79 @@ func (e *Executor) collectStepResults(execCtx *execcontext.ExecutionContext, ste
 		return e.executeScriptStep(execCtx, step)
 	case step.IsContainerStep():
 		return e.executeContainerStep(execCtx, step)
+	case step.IsExportStep():
+		return e.executeExportStep(execCtx, step)
+	case step.IsIngestStep():
+		return e.executeIngestStep(execCtx, step)
+	case step.IsExtractStep():
+		return e.executeExtractStep(execCtx, step)
+	case step.IsClassifyStep():
+		return e.executeClassifyStep(execCtx, step)
+	case step.IsSummarizeStep():
+		return e.executeSummarizeStep(execCtx, step)
+	case step.IsTranslateStep():
+		return e.executeTranslateStep(execCtx, step)
+	case step.IsDiffStep():
+		return e.executeDiffStep(execCtx, step)
+	case step.IsPIIStep():
+		return e.executePIIStep(execCtx, step)
+	case step.IsRaceStep():
+		return e.executeRaceStep(execCtx, step)
+	case step.IsEnsembleStep():
+		return e.executeEnsembleStep(execCtx, step)
+	case step.IsParallelStep():
+		return e.executeParallelStep(execCtx, step)
+	case step.IsKVStep():
+		return e.executeKVStep(execCtx, step)
+	case step.IsDedupeStep():
+		return e.executeDedupeStep(execCtx, step)
+	case step.IsHTTPStep():
+		return e.executeHTTPStep(execCtx, step)
+	case step.IsIssueStep():
+		return e.executeIssueStep(execCtx, step)
+	case step.IsAssertStep():
+		return e.executeAssertStep(execCtx, step)
 	default:
 		return nil, fmt.Errorf("unknown step type for step %s", step.ID)
 	}
 }
 
-func (e *Executor) parseAgentOutput(step *ast.Step, response string) (*StepResult, error) {
+func (e *Executor) parseAgentOutput(step *ast.Step, response string) (*StepResult, []string) {
 	// if there is no output schema, return the raw response as there is nothing to parse
 	if len(step.Outputs) == 0 {
 		return NewStepResult(response), nil
 	}
 
 	stepOutput := e.outputParser.ParseStepOutput(step, response)
-	return NewStepResult(stepOutput), nil
+	outputs, _ := stepOutput.(map[string]interface{})
+	if outputs == nil {
+		return NewStepResult(stepOutput), []string{"the response is not a JSON object"}
+	}
+
+	return NewStepResult(stepOutput), agentOutputSchema(step.Outputs).Validate(outputs)
+}
+
+// agentOutputSchema is the schema of the JSON object an agent step with
+// outputs responds with.
+func agentOutputSchema(outputs map[string]schema.JSON) schema.JSON {
+	required := make([]string, 0, len(outputs))
+	for name := range outputs {
+		required = append(required, name)
+	}
+	sort.Strings(required)
+
+	return schema.JSON{
+		Type:       "object",
+		Properties: outputs,
+		Required:   required,
+	}
 }
 
 func (e *Executor) executeWhileStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
 	iterationCount := 0
+	budget := e.newStepBudget(step)
 
+	var exhausted string
 	subExecCtx := execCtx.NewChild(step.Steps)
 	for {
 		condition, err := e.templateEngine.Render(step.While, execCtx)
@@ -475,66 +1000,286 @@ func (e *Executor) executeWhileStep(execCtx *execcontext.ExecutionContext, step
 			break
 		}
 
+		if exhausted = budget.exhausted(); exhausted != "" {
+			e.stopEarly(execCtx, step, exhausted)
+			break
+		}
+
 		err = e.executeSteps(subExecCtx, step.Steps)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return NewChildStepResult(subExecCtx, step), nil
+	result := NewChildStepResult(subExecCtx, step)
+	setBudgetOutputs(result.Output, exhausted)
+
+	return result, nil
 }
 
 // executeAgentStep executes a step that uses an AI agent
 func (e *Executor) executeAgentStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	return e.executeAgentPrompt(execCtx, step, "")
+}
+
+// executeAgentPrompt sends the prompt of an agent step to its agent. The ids
+// of the step's actions are prefixed with actionPrefix so the prompts of the
+// concurrent iterations of a for_each step can be told apart. When the
+// request fails in a way another model may not, such as a rate limit, the
+// prompt is sent to the agent's fallback instead.
+func (e *Executor) executeAgentPrompt(execCtx *execcontext.ExecutionContext, step *ast.Step, actionPrefix string) (*StepResult, error) {
 	agent, exists := execCtx.Workflow.GetAgent(step.Agent)
 	if !exists {
 		return nil, fmt.Errorf("agent %s not found", step.Agent)
 	}
+	agent = e.availableAgent(execCtx, step, agent)
 
-	response, err := e.executeAgentStepWithTools(execCtx, step, agent)
+	step, promptVersion, err := resolvePrompt(execCtx, step)
 	if err != nil {
 		return nil, err
 	}
 
-	return e.parseAgentOutput(step, response)
+	result, err := e.promptAgent(execCtx, step, agent, actionPrefix)
+
+	// fallbacks can't form a cycle in valid workflows, the limit only
+	// guards against unvalidated ones
+	for range len(execCtx.Workflow.Agents) {
+		fallback, ok := e.fallbackAgent(execCtx, step, agent, err)
+		if !ok {
+			break
+		}
+
+		agent = e.availableAgent(execCtx, step, fallback)
+		result, err = e.promptAgent(execCtx, step, agent, actionPrefix)
+	}
+	if err == nil {
+		result.Prompt = promptVersion
+	}
+
+	return result, err
 }
 
-// executeAgentStepWithTools executes an agent step with tool support
-func (e *Executor) executeAgentStepWithTools(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent) (string, error) {
-	initialPrompt, err := e.buildInitialPrompt(execCtx, step)
+// promptAgent sends the prompt of an agent step to the agent, routing it to
+// a model first when the agent uses a tier.
+func (e *Executor) promptAgent(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent, actionPrefix string) (*StepResult, error) {
+	var decision *routing.Decision
+	if agent.Tier != "" {
+		var err error
+		agent, decision, err = e.routeAgent(execCtx, step, agent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, usage, err := e.executeAgentStepWithTools(execCtx, step, agent, actionPrefix)
+	e.recordSpend(agent, usage)
 	if err != nil {
-		return "", fmt.Errorf("failed to build initial prompt: %w", err)
+		return nil, err
+	}
+
+	// the summarizer's usage has been priced with its own model
+	if result.compression != nil {
+		usage.Add(result.compression.usage)
+	}
+	result.TokenUsage = usage
+	result.Routing = decision
+	result.Environment = &execcontext.StepEnvironment{
+		Provider: agent.Provider,
+		Model:    agent.Model,
 	}
+	if usage != nil {
+		result.Environment.ModelSnapshot = usage.Model
+		if e.config.ModelLock != nil {
+			e.config.ModelLock.Record(agent.Provider, agent.Model, usage.Model)
+		}
+	}
+
+	return result, nil
+}
+
+// executeAgentStepWithTools executes an agent step with tool support. When
+// the step allows repairs, responses which aren't valid JSON or don't match
+// the step's outputs are sent back to the agent with the reasons they're
+// invalid so it can correct them.
+func (e *Executor) executeAgentStepWithTools(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent, actionPrefix string) (*StepResult, *execcontext.TokenUsage, error) {
+	responseSchema := agentResponseSchema(step, agent)
 
 	// if the model is an alias, get the actual model name
 	// this is useful for users who want to use the models without certain suffixes
 	// e.g. claude-opus-4-20250514 -> claude-opus-4
-	model, err := e.modelRegistry.ModelAlias(agent.Provider, agent.Model)
-	if err == nil {
-		agent.Model = model
+	// the agent is copied as it's shared by steps which may run concurrently
+	model, err := e.resolveModel(agent)
+	if err == nil && model != agent.Model {
+		aliased := *agent
+		aliased.Model = model
+		agent = &aliased
 	}
 
-	provider, err := e.modelRegistry.GetProviderForModel(agent.Provider, model)
+	pr, err := e.modelRegistry.GetProviderForModel(agent.ProviderName(), model)
 	if err != nil {
-		return "", fmt.Errorf("failed to get provider %s for model %s: %w", agent.Provider, agent.Model, err)
+		return nil, nil, fmt.Errorf("failed to get provider %s for model %s: %w", agent.Provider, agent.Model, err)
 	}
 
-	return e.executeConversationWithTools(execCtx, provider, agent, initialPrompt, step)
-}
+	initialPrompt, compressed, err := e.buildInitialPrompt(execCtx, step, agent, responseSchema == nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build initial prompt: %w", err)
+	}
+
+	messages := []provider.Message{
+		{
+			Role: "user",
+			Content: []provider.ContentBlockParamUnion{
+				provider.NewTextBlock(initialPrompt),
+			},
+		},
+	}
+
+	// steps in a session continue its conversation, one at a time
+	var session *agentSession
+	if step.Session != "" {
+		session = e.agentSession(step.Session)
+		session.mu.Lock()
+		defer session.mu.Unlock()
+
+		log.Debug().
+			Str("step_id", step.ID).
+			Str("session", step.Session).
+			Int("messages", len(session.messages)).
+			Msg("Continuing agent session")
 
-func (e *Executor) buildInitialPrompt(execCtx *execcontext.ExecutionContext, step *ast.Step) (string, error) {
-	prompt, err := e.templateEngine.Render(step.Prompt, execCtx)
+		messages = append(slices.Clone(session.messages), messages...)
+	}
+
+	response, messages, usage, err := e.executeConversationWithTools(execCtx, pr, agent, messages, step, responseSchema, actionPrefix+"turn")
 	if err != nil {
-		return "", fmt.Errorf("failed to render prompt template: %w", err)
+		return nil, usage, err
 	}
 
-	promptString, ok := prompt.(string)
-	if !ok {
-		return "", fmt.Errorf("prompt is not a string")
+	result, violations := e.parseAgentOutput(step, response)
+	attempts := step.RepairAttempts()
+	if len(violations) == 0 || attempts == 0 {
+		if len(violations) > 0 {
+			log.Warn().
+				Str("step_id", step.ID).
+				Strs("violations", violations).
+				Msg("Agent response doesn't match the step outputs, accepting it as repair is disabled on the step")
+		}
+		session.update(messages)
+		result.compression = compressed
+		return result, usage, nil
 	}
 
-	if step.Outputs == nil {
-		return promptString, nil
+	repairUsage := &execcontext.TokenUsage{}
+	for attempt := 1; attempt <= attempts; attempt++ {
+		log.Debug().
+			Str("step_id", step.ID).
+			Int("attempt", attempt).
+			Strs("violations", violations).
+			Msg("Agent response doesn't match the step outputs, asking the agent to repair it")
+
+		messages = append(messages, provider.Message{
+			Role:    "user",
+			Content: []provider.ContentBlockParamUnion{e.repairReply(messages, responseSchema, violations)},
+		})
+
+		var attemptUsage *execcontext.TokenUsage
+		response, messages, attemptUsage, err = e.executeConversationWithTools(execCtx, pr, agent, messages, step, responseSchema, fmt.Sprintf("%srepair-%d", actionPrefix, attempt))
+		usage.Add(attemptUsage)
+		repairUsage.Add(attemptUsage)
+		if err != nil {
+			return nil, usage, fmt.Errorf("repair attempt %d failed: %w", attempt, err)
+		}
+
+		result, violations = e.parseAgentOutput(step, response)
+		if len(violations) == 0 {
+			result.Repairs = attempt
+			result.RepairUsage = repairUsage
+			result.compression = compressed
+			session.update(messages)
+			return result, usage, nil
+		}
+	}
+
+	return nil, usage, fmt.Errorf("response doesn't match the step outputs after %d repair attempts: %s", attempts, strings.Join(violations, "; "))
+}
+
+// repairFeedback asks the agent to correct a response which doesn't match
+// the step's outputs.
+func repairFeedback(violations []string) string {
+	return "Your output failed schema validation:\n- " + strings.Join(violations, "\n- ") +
+		"\n\nRespond again with the corrected JSON object, using the schema given earlier."
+}
+
+// repairReply returns the repair feedback for the last response of the
+// conversation. A response given with a forced structured output tool call
+// must be answered with the tool's result.
+func (e *Executor) repairReply(messages []provider.Message, responseSchema *provider.ResponseSchema, violations []string) provider.ContentBlockParamUnion {
+	feedback := repairFeedback(violations)
+	if responseSchema == nil || len(messages) == 0 {
+		return provider.NewTextBlock(feedback)
+	}
+
+	for _, toolUse := range e.getToolCallsFromResponseMessages(messages[len(messages)-1:]) {
+		if toolUse.Name == responseSchema.Name {
+			isError := true
+			return provider.NewToolResultBlock(toolUse.ID, feedback, &isError)
+		}
+	}
+
+	return provider.NewTextBlock(feedback)
+}
+
+// agentResponseSchema returns the structured output requested from the
+// agent of a step with outputs, so that Anthropic and OpenAI models respond
+// with an object matching the outputs. It returns nil when the outputs are
+// given in the prompt instead, for providers without structured outputs and
+// for Anthropic agents with tools or in a session: Anthropic's structured
+// outputs force a call of the schema's tool, which would stop the agent
+// using its own tools and leave a tool call without a result in the
+// session's conversation.
+func agentResponseSchema(step *ast.Step, agent *ast.Agent) *provider.ResponseSchema {
+	if len(step.Outputs) == 0 || !supportsResponseSchema(agent.Provider) {
+		return nil
+	}
+
+	if agent.Provider == "anthropic" && (len(agent.Tools) > 0 || step.Session != "") {
+		return nil
+	}
+
+	return &provider.ResponseSchema{
+		Name:        "respond_" + step.ID,
+		Description: "Respond with the outputs of the step",
+		Schema:      agentOutputSchema(step.Outputs),
+	}
+}
+
+// buildInitialPrompt renders the prompt of an agent step. When withSchema is
+// set the JSON schema of the step's outputs is added to the prompt, for
+// agents which aren't sent the outputs as a structured output.
+func (e *Executor) buildInitialPrompt(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent, withSchema bool) (string, *compression, error) {
+	var promptString string
+	var compressed *compression
+	if step.Compress != nil {
+		var err error
+		promptString, compressed, err = e.renderCompressedPrompt(execCtx, step, agent)
+		if err != nil {
+			return "", compressed, err
+		}
+	} else {
+		prompt, err := e.templateEngine.Render(step.Prompt, execCtx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to render prompt template: %w", err)
+		}
+
+		var ok bool
+		promptString, ok = prompt.(string)
+		if !ok {
+			return "", nil, fmt.Errorf("prompt is not a string")
+		}
+	}
+
+	if step.Outputs == nil || !withSchema {
+		return promptString, compressed, nil
 	}
 
 	promptString += "\n\n"
@@ -542,69 +1287,65 @@ func (e *Executor) buildInitialPrompt(execCtx *execcontext.ExecutionContext, ste
 
 	jsonSchema, err := json.Marshal(step.Outputs)
 	if err != nil {
-		return promptString, fmt.Errorf("failed to marshal step outputs: %w", err)
+		return promptString, compressed, fmt.Errorf("failed to marshal step outputs: %w", err)
 	}
 	promptString += "```json\n"
 	promptString += string(jsonSchema)
 	promptString += "\n```"
 
-	return promptString, nil
+	return promptString, compressed, nil
 }
 
-// executeConversationWithTools handles multi-turn conversation with tool calling
-func (e *Executor) executeConversationWithTools(execCtx *execcontext.ExecutionContext, pr provider.Provider, agent *ast.Agent, initialPrompt string, step *ast.Step) (string, error) {
+// executeConversationWithTools handles multi-turn conversation with tool calling,
+// continuing from messages. It returns the final response along with the
+// messages of the conversation including the response. When responseSchema
+// is set the model is asked for a structured output, a response given as a
+// call of the schema's tool is returned as the tool's JSON input.
+func (e *Executor) executeConversationWithTools(execCtx *execcontext.ExecutionContext, pr provider.Provider, agent *ast.Agent, messages []provider.Message, step *ast.Step, responseSchema *provider.ResponseSchema, actionPrefix string) (string, []provider.Message, *execcontext.TokenUsage, error) {
 	// @TODO: make this configurable in the step & or agent definition
 	maxTurns := 10
 
-	messages := []provider.Message{
-		{
-			Role: "user",
-			Content: []provider.ContentBlockParamUnion{
-				provider.NewTextBlock(initialPrompt),
-			},
-		},
-	}
+	usage := &execcontext.TokenUsage{}
 
 	// if the provider is local, don't run in a loop as these models are self contained and
 	// handle all the tool calling themselves
 	if _, ok := pr.(provider.LocalModelProvider); ok {
-		request, err := e.createModelRequestWithTools(agent, messages, pr.GetName())
+		// they also keep their own sessions, which are resumed rather than
+		// being sent the conversation again
+		sessionID, pending := resumableMessages(messages)
+		request, err := e.createModelRequestWithTools(agent, pending, agent.Provider)
 		if err != nil {
-			return "", fmt.Errorf("failed to create model request: %w", err)
+			return "", messages, nil, fmt.Errorf("failed to create model request: %w", err)
 		}
+		request.SessionID = sessionID
 
-		responseMessages, _, err := pr.Generate(provider.GenerateContext{
-			StepID:  step.ID,
-			RunID:   execCtx.RunID,
-			Context: execCtx.Context.Context,
-		}, request, e.progressChan)
+		responseMessages, attemptUsage, err := e.generate(execCtx, pr, agent, step, "", request)
+		usage.Add(attemptUsage)
 		if err != nil {
-			return "", fmt.Errorf("model generation failed: %w", err)
+			return "", messages, usage, fmt.Errorf("model generation failed: %w", err)
 		}
 
-		return getLastContentBlock(responseMessages), nil
+		return responseText(responseMessages, agent.ToolResultFormat), append(messages, responseMessages...), usage, nil
 	}
 
 	for turn := 0; turn < maxTurns; turn++ {
-		request, err := e.createModelRequestWithTools(agent, messages, pr.GetName())
+		request, err := e.createModelRequestWithTools(agent, messages, agent.Provider)
 		if err != nil {
-			return "", fmt.Errorf("failed to create model request: %w", err)
+			return "", messages, usage, fmt.Errorf("failed to create model request: %w", err)
 		}
+		request.ResponseSchema = responseSchema
 
-		actionID := fmt.Sprintf("turn-%d", turn)
+		actionID := fmt.Sprintf("%s-%d", actionPrefix, turn)
 		prompt := getLastContentBlock(messages)
 		prompt = RemoveJSONSchema(prompt)
 		e.progressChan <- events.NewPromptAgentEvent(step.ID, actionID, execCtx.RunID, prompt)
 
-		responseMessages, _, err := pr.Generate(provider.GenerateContext{
-			StepID:  step.ID,
-			RunID:   execCtx.RunID,
-			Context: execCtx.Context.Context,
-		}, request, e.progressChan)
+		responseMessages, attemptUsage, err := e.generate(execCtx, pr, agent, step, actionID, request)
+		usage.Add(attemptUsage)
 		if err != nil {
 			e.progressChan <- events.NewAgentFailedEvent(step, actionID, execCtx.RunID)
 
-			return "", fmt.Errorf("model generation failed: %w", err)
+			return "", messages, usage, fmt.Errorf("model generation failed: %w", err)
 		}
 
 		var diagnostics []string
@@ -618,13 +1359,19 @@ func (e *Executor) executeConversationWithTools(execCtx *execcontext.ExecutionCo
 		// its safe to exit with a final response from the response
 		toolCalls := e.getToolCallsFromResponseMessages(responseMessages)
 		if len(toolCalls) == 0 {
-			return getLastContentBlock(responseMessages), nil
+			return responseText(responseMessages, agent.ToolResultFormat), append(messages, responseMessages...), usage, nil
+		}
+
+		for _, toolCall := range toolCalls {
+			if responseSchema != nil && toolCall.Name == responseSchema.Name {
+				return string(toolCall.Input), append(messages, responseMessages...), usage, nil
+			}
 		}
 
 		// Execute tool calls
 		toolResults, err := e.executeToolCalls(execCtx, toolCalls, step)
 		if err != nil {
-			return "", fmt.Errorf("tool execution failed: %w", err)
+			return "", messages, usage, fmt.Errorf("tool execution failed: %w", err)
 		}
 
 		// add the response messages and the tool results to the messages
@@ -634,7 +1381,7 @@ func (e *Executor) executeConversationWithTools(execCtx *execcontext.ExecutionCo
 		messages = append(messages, toolResults...)
 	}
 
-	return "Max conversation turns reached without completion", nil
+	return "Max conversation turns reached without completion", messages, usage, nil
 }
 
 func (e *Executor) getToolCallsFromResponseMessages(responseMessages []provider.Message) []*provider.ToolUseBlockParam {
@@ -657,7 +1404,7 @@ func (e *Executor) createModelRequestWithTools(agent *ast.Agent, messages []prov
 	switch providerName {
 	case "anthropic":
 		return e.createAnthropicRequestWithTools(agent, messages)
-	case "openai":
+	case "openai", ast.OpenAICompatibleProvider:
 		return e.createOpenAIRequestWithTools(agent, messages)
 	case "local":
 		// local provider does not support tool calling
@@ -667,17 +1414,63 @@ func (e *Executor) createModelRequestWithTools(agent *ast.Agent, messages []prov
 	}
 }
 
+// renderSystemPrompt renders the agent's system prompt followed by the
+// workflow's global context.
+func (e *Executor) renderSystemPrompt(agent *ast.Agent) (string, error) {
+	rendered, err := e.templateEngine.Render(agent.SystemPrompt, e.execCtx)
+	if err != nil {
+		return "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	systemPrompt := fmt.Sprintf("%s", rendered)
+
+	workflowContext := e.execCtx.Workflow.Context
+	if workflowContext == nil {
+		return systemPrompt, nil
+	}
+
+	sections := []string{}
+	if systemPrompt != "" {
+		sections = append(sections, systemPrompt)
+	}
+
+	for _, section := range []struct {
+		title string
+		items []string
+	}{
+		{"Context", workflowContext.Facts},
+		{"Constraints", workflowContext.Constraints},
+	} {
+		if len(section.items) == 0 {
+			continue
+		}
+
+		var builder strings.Builder
+		builder.WriteString(section.title + ":")
+		for _, item := range section.items {
+			value, err := e.templateEngine.Render(item, e.execCtx)
+			if err != nil {
+				return "", fmt.Errorf("failed to render workflow context: %w", err)
+			}
+			builder.WriteString(fmt.Sprintf("\n- %s", value))
+		}
+
+		sections = append(sections, builder.String())
+	}
+
+	return strings.Join(sections, "\n\n"), nil
+}
+
 // createLocalRequest creates a local request with tools
 func (e *Executor) createLocalRequest(agent *ast.Agent, messages []provider.Message) (*provider.Request, error) {
-	systemPrompt, err := e.templateEngine.Render(agent.SystemPrompt, e.execCtx)
+	systemPrompt, err := e.renderSystemPrompt(agent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to render system prompt: %w", err)
+		return nil, err
 	}
 
 	request := &provider.Request{
 		Model:        agent.Model,
 		Messages:     messages,
-		SystemPrompt: fmt.Sprintf("%s", systemPrompt),
+		SystemPrompt: systemPrompt,
 		Temperature:  agent.Temperature,
 		MaxTokens:    agent.MaxTokens,
 		TopP:         agent.TopP,
@@ -691,15 +1484,15 @@ func (e *Executor) createLocalRequest(agent *ast.Agent, messages []provider.Mess
 
 // createAnthropicRequestWithTools creates an Anthropic request with tools
 func (e *Executor) createAnthropicRequestWithTools(agent *ast.Agent, messages []provider.Message) (*provider.Request, error) {
-	systemPrompt, err := e.templateEngine.Render(agent.SystemPrompt, e.execCtx)
+	systemPrompt, err := e.renderSystemPrompt(agent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to render system prompt: %w", err)
+		return nil, err
 	}
 
 	request := &provider.Request{
 		Model:        agent.Model,
 		Messages:     messages,
-		SystemPrompt: fmt.Sprintf("%s", systemPrompt),
+		SystemPrompt: systemPrompt,
 		Temperature:  agent.Temperature,
 		MaxTokens:    agent.MaxTokens,
 		TopP:         agent.TopP,
@@ -714,15 +1507,15 @@ func (e *Executor) createAnthropicRequestWithTools(agent *ast.Agent, messages []
 
 // createOpenAIRequestWithTools creates an OpenAI request with tools
 func (e *Executor) createOpenAIRequestWithTools(agent *ast.Agent, messages []provider.Message) (*provider.Request, error) {
-	systemPrompt, err := e.templateEngine.Render(agent.SystemPrompt, e.execCtx)
+	systemPrompt, err := e.renderSystemPrompt(agent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to render system prompt: %w", err)
+		return nil, err
 	}
 
 	request := &provider.Request{
 		Model:        agent.Model,
 		Messages:     messages,
-		SystemPrompt: fmt.Sprintf("%s", systemPrompt),
+		SystemPrompt: systemPrompt,
 		Temperature:  agent.Temperature,
 		MaxTokens:    agent.MaxTokens,
 		TopP:         agent.TopP,
@@ -743,7 +1536,9 @@ func (e *Executor) executeToolCalls(execCtx *execcontext.ExecutionContext, toolC
 		actionID := fmt.Sprintf("tool-%s", toolCall.ID)
 
 		toolCallMsg := provider.FormatToolCall(toolCall)
-		e.progressChan <- events.NewToolUseEvent(step.ID, actionID, toolCall.Name, execCtx.RunID, toolCallMsg)
+		var toolInput map[string]interface{}
+		_ = json.Unmarshal(toolCall.Input, &toolInput)
+		e.progressChan <- events.NewToolUseEvent(step.ID, actionID, toolCall.Name, execCtx.RunID, toolCallMsg, toolInput)
 
 		result, err := e.toolRegistry.ExecuteTool(execCtx, toolCall.Name, toolCall.Input)
 		if err != nil || result.Error != "" {
@@ -757,16 +1552,18 @@ func (e *Executor) executeToolCalls(execCtx *execcontext.ExecutionContext, toolC
 				Str("tool", toolCall.Name).
 				Msg("Tool execution failed")
 
+			e.metrics().toolCallFailed(toolCall.Name)
+
 			isError := true
 			results = append(results,
 				provider.Message{
 					Role: "user",
 					Content: []provider.ContentBlockParamUnion{
-						provider.NewToolResultBlock(toolCall.ID, msg, &isError),
+						provider.NewToolResultBlock(toolCall.ID, utils.SanitizeText(msg, e.config.Normalization), &isError),
 					},
 				},
 			)
-			e.progressChan <- events.NewToolUseFailedEvent(step, actionID, toolCall.Name, execCtx.RunID)
+			e.progressChan <- events.NewToolUseFailedEvent(step, actionID, toolCall.Name, execCtx.RunID, msg)
 			continue
 		}
 
@@ -814,10 +1611,20 @@ func (e *Executor) executeBlockStep(execCtx *execcontext.ExecutionContext, step
 
 	result, err := e.runner.RunWorkflow(execCtx.Context, blockPath, inputs, step.ID)
 	if err != nil {
+		var inputErr *InputValidationResult
+		if errors.As(err, &inputErr) {
+			return nil, fmt.Errorf("invalid inputs for block %s: %s", step.Uses, inputErr.Summary())
+		}
 		return nil, fmt.Errorf("block execution failed: %w", err)
 	}
 
-	return NewStepResult(result.Outputs), nil
+	stepResult := NewStepResult(result.Outputs)
+	stepResult.Environment = &execcontext.StepEnvironment{
+		Block:       step.Uses,
+		BlockDigest: fileDigest(blockPath),
+	}
+
+	return stepResult, nil
 }
 
 // executeScriptStep executes a step that runs a Go script
@@ -846,13 +1653,21 @@ func (e *Executor) executeScriptStep(execCtx *execcontext.ExecutionContext, step
 		Runtime: block.RuntimeBash,
 		Script:  script.(string),
 	}
+	if step.Resources != nil {
+		tempBlock.GPUs = step.Resources.GPUs
+	}
 
 	outputs, err := e.blockManager.ExecuteRawBlock(execCtx, tempBlock, inputs)
 	if err != nil {
 		return nil, fmt.Errorf("script execution failed: %w", err)
 	}
 
-	return NewStepResult(outputs), nil
+	stepResult := NewStepResult(outputs)
+	if len(e.runtimes) > 0 {
+		stepResult.Environment = &execcontext.StepEnvironment{Runtimes: maps.Clone(e.runtimes)}
+	}
+
+	return stepResult, nil
 }
 
 // executeContainerStep executes a step that runs a Docker container
@@ -879,6 +1694,10 @@ func (e *Executor) executeContainerStep(execCtx *execcontext.ExecutionContext, s
 		Outputs: make(map[string]block.OutputSchema),
 		Command: step.Command,
 	}
+	if step.Resources != nil {
+		tempBlock.GPUs = step.Resources.GPUs
+		tempBlock.Devices = step.Resources.Devices
+	}
 
 	for key := range inputs {
 		tempBlock.Inputs[key] = block.InputSchema{
@@ -892,7 +1711,13 @@ func (e *Executor) executeContainerStep(execCtx *execcontext.ExecutionContext, s
 		return nil, fmt.Errorf("container execution failed: %w", err)
 	}
 
-	return NewStepResult(outputs), nil
+	stepResult := NewStepResult(outputs)
+	stepResult.Environment = &execcontext.StepEnvironment{
+		Image:       step.Container,
+		ImageDigest: tempBlock.ImageDigest,
+	}
+
+	return stepResult, nil
 }
 
 // evaluateSkipCondition evaluates whether a step should be skipped
@@ -956,7 +1781,9 @@ func getRequiredProviders(workflow *ast.Workflow) map[string]map[string]interfac
 	providers := make(map[string]map[string]interface{})
 
 	for _, agent := range workflow.Agents {
-		if agent.Provider != "" {
+		if agent.Provider == ast.OpenAICompatibleProvider {
+			providers[agent.ProviderName()] = compatibleProviderConfig(agent)
+		} else if agent.Provider != "" {
 			providers[agent.Provider] = agent.Config
 		}
 	}
@@ -964,33 +1791,63 @@ func getRequiredProviders(workflow *ast.Workflow) map[string]map[string]interfac
 	return providers
 }
 
+// compatibleProviderConfig returns the config of the provider of an agent
+// using an openai-compatible provider, its endpoint along with the agent's
+// config.
+func compatibleProviderConfig(agent *ast.Agent) map[string]interface{} {
+	config := make(map[string]interface{}, len(agent.Config)+4)
+	for key, value := range agent.Config {
+		config[key] = value
+	}
+
+	config["base_url"] = agent.BaseURL
+	config["api_key_env"] = agent.APIKeyEnv
+	config["headers"] = agent.Headers
+	config["models"] = []string{agent.Model}
+
+	return config
+}
+
+// NewModelProvider creates the model provider registered under name with
+// its config, such as "anthropic" or "local".
+func NewModelProvider(name string, config map[string]interface{}) (provider.Provider, error) {
+	var pr provider.Provider
+	var err error
+
+	switch {
+	case name == "anthropic":
+		pr, err = anthropic.NewProvider(config)
+	case name == "openai":
+		pr, err = openai.NewProvider(config)
+	case name == "local":
+		pr, err = claudecode.NewProvider(config)
+	case strings.HasPrefix(name, ast.OpenAICompatibleProvider+"-"):
+		pr, err = openai.NewCompatibleProvider(name, config)
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize %s provider: %w", name, err)
+	}
+
+	return pr, nil
+}
+
 // initializeRequiredProviders initializes only the specified providers
-func initializeRequiredProviders(registry *provider.Registry, requiredProviders map[string]map[string]interface{}) error {
+func initializeRequiredProviders(ctx context.Context, registry *provider.Registry, requiredProviders map[string]map[string]interface{}) error {
 	for providerName, config := range requiredProviders {
 		if _, err := registry.GetProviderByName(providerName); err == nil {
 			log.Debug().Str("provider", providerName).Msg("Provider already registered, skipping initialization")
 			continue
 		}
 
-		var pr provider.Provider
-		var err error
-
-		switch providerName {
-		case "anthropic":
-			pr, err = anthropic.NewProvider(config)
-		case "openai":
-			pr, err = openai.NewProvider(config)
-		case "local":
-			pr, err = claudecode.NewProvider(config)
-		default:
-			return fmt.Errorf("unknown provider: %s", providerName)
-		}
-
+		pr, err := NewModelProvider(providerName, config)
 		if err != nil {
-			return fmt.Errorf("failed to initialize %s provider: %w", providerName, err)
+			return err
 		}
 
-		if err := registry.RegisterProvider(pr); err != nil {
+		if err := registry.RegisterProviderContext(ctx, pr); err != nil {
 			return fmt.Errorf("failed to register %s provider: %w", providerName, err)
 		}
 
@@ -1000,8 +1857,9 @@ func initializeRequiredProviders(registry *provider.Registry, requiredProviders
 	return nil
 }
 
-// initializeToolProviders initializes tool providers for the workflow
-func initializeToolProviders(toolRegistry *tools.Registry, workflow *ast.Workflow, cacheDir string) error {
+// initializeToolProviders initializes tool providers for the workflow, the
+// MCP servers of its agents are started with ctx
+func initializeToolProviders(ctx context.Context, toolRegistry *tools.Registry, workflow *ast.Workflow, cacheDir string, config *ExecutorConfig) error {
 	scriptProvider, err := script.NewScriptToolProvider("local", cacheDir)
 	if err != nil {
 		return fmt.Errorf("failed to create script tool provider: %w", err)
@@ -1016,10 +1874,14 @@ func initializeToolProviders(toolRegistry *tools.Registry, workflow *ast.Workflo
 		return fmt.Errorf("failed to register MCP tool provider: %w", err)
 	}
 
+	if err := toolRegistry.RegisterProvider(official.NewProvider(official.WithSearchConfig(config.Search))); err != nil {
+		return fmt.Errorf("failed to register official tool provider: %w", err)
+	}
+
 	// @TODO: register the workflow provider (block provider)
 
 	for name, agent := range workflow.Agents {
-		if err := toolRegistry.RegisterToolsForAgent(agent); err != nil {
+		if err := toolRegistry.RegisterToolsForAgent(ctx, agent); err != nil {
 			return fmt.Errorf("failed to register tools for agent %s: %w", name, err)
 		}
 	}
@@ -1027,6 +1889,44 @@ func initializeToolProviders(toolRegistry *tools.Registry, workflow *ast.Workflo
 	return nil
 }
 
+// validateAgentTools checks the tools of each agent, including the ones its
+// MCP servers list, against the constraints of the agent's provider, so that
+// tools the provider would reject are reported before the run starts.
+func validateAgentTools(workflow *ast.Workflow, registry *provider.Registry, toolRegistry *tools.Registry) error {
+	names := make([]string, 0, len(workflow.Agents))
+	for name := range workflow.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		agent := workflow.Agents[name]
+		pr, err := registry.GetProviderByName(agent.ProviderName())
+		if err != nil {
+			continue
+		}
+		constrained, ok := pr.(provider.ToolConstrainedProvider)
+		if !ok {
+			continue
+		}
+
+		for _, violation := range constrained.ToolConstraints().Check(toolRegistry.GetToolsForAgent(agent.Name)) {
+			if violation.Tool == "" {
+				problems = append(problems, fmt.Sprintf("agent %s: %s", name, violation.Reason))
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("agent %s, tool %s: %s", name, violation.Tool, violation.Reason))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("tools aren't accepted by the agents' providers:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
 // collectWorkflowOutputs collects and renders workflow-level outputs using the template engine
 func (e *Executor) collectWorkflowOutputs(execCtx *execcontext.ExecutionContext) error {
 	workflowOutputs := execCtx.Workflow.Workflow.Outputs
@@ -1047,7 +1947,12 @@ func (e *Executor) collectWorkflowOutputs(execCtx *execcontext.ExecutionContext)
 			return fmt.Errorf("failed to render output '%s': %w", key, err)
 		}
 
-		outputs[key] = expression.ValueToString(renderedValue)
+		value, err := validateOutputValue(renderedValue, execCtx.Workflow.Outputs[key])
+		if err != nil {
+			return fmt.Errorf("output '%s' does not match its declared type: %w", key, err)
+		}
+
+		outputs[key] = value
 	}
 
 	execCtx.SetWorkflowOutputs(outputs)
@@ -1060,25 +1965,42 @@ func (e *Executor) collectWorkflowOutputs(execCtx *execcontext.ExecutionContext)
 	return nil
 }
 
-func getLastContentBlock(responseMessages []provider.Message) string {
-	if len(responseMessages) == 0 {
+// getLastContentBlock returns the text of the last message, such as the
+// prompt sent to an agent.
+func getLastContentBlock(messages []provider.Message) string {
+	if len(messages) == 0 {
 		return ""
 	}
 
-	lastMessage := responseMessages[len(responseMessages)-1]
-	if len(lastMessage.Content) == 0 {
-		return ""
-	}
-
-	if lastMessage.Content[len(lastMessage.Content)-1].OfText != nil {
-		return lastMessage.Content[len(lastMessage.Content)-1].OfText.Text
-	}
+	return responseText(messages[len(messages)-1:], ast.ToolResultFormatText)
+}
 
-	if lastMessage.Content[len(lastMessage.Content)-1].OfToolResult != nil {
-		return provider.FormatToolResult(lastMessage.Content[len(lastMessage.Content)-1].OfToolResult)
+// responseText returns the text of an agent's response messages, the text
+// blocks and tool results of every message in order, separated by blank
+// lines. Tool results are formatted as the agent's tool_result_format says:
+// "text" prefixes them with "Tool result:" and "json" keeps their content,
+// as a JSON string when it isn't JSON.
+func responseText(responseMessages []provider.Message, format string) string {
+	var parts []string
+	for _, message := range responseMessages {
+		for _, block := range message.Content {
+			switch {
+			case block.OfText != nil:
+				parts = append(parts, block.OfText.Text)
+			case block.OfToolResult != nil && format == ast.ToolResultFormatJSON:
+				content := block.OfToolResult.Content
+				if !json.Valid([]byte(content)) {
+					encoded, _ := json.Marshal(content)
+					content = string(encoded)
+				}
+				parts = append(parts, content)
+			case block.OfToolResult != nil:
+				parts = append(parts, provider.FormatToolResult(block.OfToolResult))
+			}
+		}
 	}
 
-	return ""
+	return strings.Join(parts, "\n\n")
 }
 
 // RemoveJSONSchema strips JSON schema instructions from AI model prompts
@@ -2,11 +2,15 @@ package engine
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
 	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/encryption"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/kv"
 	"github.com/lacquerai/lacquer/internal/provider"
 	"github.com/lacquerai/lacquer/internal/schema"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
@@ -109,6 +113,81 @@ func collectProgressEvents() (chan pkgEvents.ExecutionEvent, *safeEventCollector
 	return eventsChan, collector
 }
 
+// testRun is how runTestWorkflow runs a workflow.
+type testRun struct {
+	providers []provider.Provider
+	config    *ExecutorConfig
+	kv        *kv.Bucket
+	state     map[string]interface{}
+}
+
+type testRunOption func(*testRun)
+
+// withProviders registers the providers the workflow's agents use.
+func withProviders(providers ...provider.Provider) testRunOption {
+	return func(r *testRun) {
+		r.providers = append(r.providers, providers...)
+	}
+}
+
+// withExecutorConfig runs the workflow with config rather than
+// DefaultExecutorConfig.
+func withExecutorConfig(config *ExecutorConfig) testRunOption {
+	return func(r *testRun) {
+		r.config = config
+	}
+}
+
+// withKV gives the workflow the values of the bucket.
+func withKV(bucket *kv.Bucket) testRunOption {
+	return func(r *testRun) {
+		r.kv = bucket
+	}
+}
+
+// withState sets the state of the workflow before it runs.
+func withState(state map[string]interface{}) testRunOption {
+	return func(r *testRun) {
+		r.state = state
+	}
+}
+
+// runTestWorkflow executes the workflow with the inputs in a temporary
+// working directory, returning its execution context, the events it sent
+// and the error it failed with.
+func runTestWorkflow(t *testing.T, workflow *ast.Workflow, inputs map[string]interface{}, opts ...testRunOption) (*execcontext.ExecutionContext, []pkgEvents.ExecutionEvent, error) {
+	t.Helper()
+
+	run := &testRun{config: DefaultExecutorConfig()}
+	for _, opt := range opts {
+		opt(run)
+	}
+
+	registry := provider.NewRegistry(false)
+	for _, pr := range run.providers {
+		require.NoError(t, registry.RegisterProvider(pr))
+	}
+
+	executor, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, run.config, workflow, registry, &Runner{})
+	require.NoError(t, err)
+
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{Context: context.Background()}, workflow, inputs, t.TempDir())
+	execCtx.KV = run.kv
+	if run.state != nil {
+		execCtx.UpdateState(run.state)
+	}
+
+	eventsChan, collector := collectProgressEvents()
+	err = executor.ExecuteWorkflow(execCtx, eventsChan)
+	close(eventsChan)
+	collector.waitForCompletion()
+
+	return execCtx, collector.getEvents(), err
+}
+
 func TestExecuteWorkflow_BasicScriptStep(t *testing.T) {
 	steps := []*ast.Step{
 		{
@@ -473,6 +552,54 @@ func TestExecuteWorkflow_WhileLoop(t *testing.T) {
 	assert.Equal(t, "After while loop 2\n\n", afterResult.Response)
 }
 
+func TestExecuteWorkflow_WhileLoopBudgetExhausted(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock("draft 1"),
+			provider.NewTextBlock("draft 2"),
+			provider.NewTextBlock("draft 3"),
+		},
+	}
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID:    "refine",
+			While: "${{ state.counter < 10 }}",
+			Steps: []*ast.Step{
+				{
+					ID:     "draft",
+					Agent:  "writer",
+					Prompt: "Improve the draft",
+					Updates: map[string]interface{}{
+						"counter": "${{ state.counter + 1 }}",
+					},
+				},
+			},
+			// each draft uses 15 tokens so the budget is spent after three
+			Budget: &ast.StepBudget{Tokens: 40},
+		},
+	})
+	workflow.Workflow.State = map[string]interface{}{"counter": 0}
+	workflow.Agents = map[string]*ast.Agent{
+		"writer": {Name: "writer", Provider: pr.name, Model: "test-model"},
+	}
+
+	execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("refine")
+	require.True(t, ok)
+	assert.Equal(t, execcontext.StepStatusCompleted, result.Status)
+
+	assert.Equal(t, true, result.Output["budget_exhausted"])
+	assert.Equal(t, "the step's budget of 40 tokens is spent", result.Output["budget_reason"])
+
+	counter, _ := execCtx.GetState("counter")
+	assert.EqualValues(t, 3, counter)
+	assert.Len(t, pr.requests, 3)
+}
+
 func TestExecuteWorkflow_ErrorHandling(t *testing.T) {
 	t.Run("Script step failure", func(t *testing.T) {
 		steps := []*ast.Step{
@@ -526,6 +653,107 @@ func TestExecuteWorkflow_ErrorHandling(t *testing.T) {
 		assert.True(t, hasWorkflowFailed, "Expected workflow failed event")
 	})
 
+	t.Run("Continue on error", func(t *testing.T) {
+		steps := []*ast.Step{
+			{
+				ID:              "failing_step",
+				Run:             "echo 'not found' >&2; exit 1",
+				ContinueOnError: true,
+			},
+			{
+				ID:  "report",
+				Run: "echo -n '${{ steps.failing_step.status }}'",
+			},
+		}
+
+		workflow := createTestWorkflow(steps)
+		execCtx := createTestExecutionContext(workflow)
+
+		executor, err := createMockExecutor(workflow)
+		require.NoError(t, err)
+
+		eventsChan, collector := collectProgressEvents()
+		err = executor.ExecuteWorkflow(execCtx, eventsChan)
+		close(eventsChan)
+		require.NoError(t, err)
+
+		failedResult, exists := execCtx.GetStepResult("failing_step")
+		require.True(t, exists)
+		assert.Equal(t, execcontext.StepStatusFailed, failedResult.Status)
+		assert.Error(t, failedResult.Error)
+
+		report, exists := execCtx.GetStepResult("report")
+		require.True(t, exists)
+		assert.Equal(t, execcontext.StepStatusCompleted, report.Status)
+		assert.Equal(t, "failed", report.Response)
+
+		collector.waitForCompletion()
+		for _, event := range collector.getEvents() {
+			assert.NotEqual(t, pkgEvents.EventWorkflowFailed, event.Type)
+		}
+	})
+
+	t.Run("On failure steps", func(t *testing.T) {
+		steps := []*ast.Step{
+			{
+				ID:  "failing_step",
+				Run: "exit 1",
+				OnFailure: []*ast.Step{
+					{ID: "fallback", Run: "echo -n 'default'"},
+				},
+			},
+			{
+				ID:  "after",
+				Run: "echo -n '${{ steps.failing_step.steps.fallback.output }}'",
+			},
+		}
+
+		workflow := createTestWorkflow(steps)
+		execCtx := createTestExecutionContext(workflow)
+
+		executor, err := createMockExecutor(workflow)
+		require.NoError(t, err)
+
+		err = executor.ExecuteWorkflow(execCtx, nil)
+		require.NoError(t, err)
+
+		failedResult, _ := execCtx.GetStepResult("failing_step")
+		assert.Equal(t, execcontext.StepStatusFailed, failedResult.Status)
+
+		after, exists := execCtx.GetStepResult("after")
+		require.True(t, exists)
+		assert.Equal(t, "default", after.Response)
+	})
+
+	t.Run("Failing on failure steps", func(t *testing.T) {
+		steps := []*ast.Step{
+			{
+				ID:  "failing_step",
+				Run: "exit 1",
+				OnFailure: []*ast.Step{
+					{ID: "notify", Run: "exit 2"},
+				},
+			},
+			{
+				ID:  "should_not_execute",
+				Run: "echo 'This should not run'",
+			},
+		}
+
+		workflow := createTestWorkflow(steps)
+		execCtx := createTestExecutionContext(workflow)
+
+		executor, err := createMockExecutor(workflow)
+		require.NoError(t, err)
+
+		err = executor.ExecuteWorkflow(execCtx, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "on_failure of step failing_step failed")
+
+		secondResult, _ := execCtx.GetStepResult("should_not_execute")
+		assert.Equal(t, execcontext.StepStatusPending, secondResult.Status)
+	})
+
 	t.Run("Unknown step type", func(t *testing.T) {
 		steps := []*ast.Step{
 			{
@@ -705,6 +933,9 @@ func TestExecuteWorkflow_BlockStep(t *testing.T) {
 	assert.Equal(t, `{greeting: "Hello, World!\n"}`, result.Response)
 
 	assert.NotEmpty(t, result.Response)
+	require.NotNil(t, result.Environment)
+	assert.Equal(t, "testdata/block.laq.yml", result.Environment.Block)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, result.Environment.BlockDigest)
 }
 
 func TestExecuteWorkflow_EmptyWorkflow(t *testing.T) {
@@ -796,3 +1027,608 @@ func TestExecuteWorkflow_AgentStepWithOutputs(t *testing.T) {
 	assert.Equal(t, execcontext.StepStatusCompleted, result.Status)
 	assert.NotEmpty(t, result.Output)
 }
+
+func TestExecuteWorkflow_StepCache(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "first", Run: "date +%s%N"},
+		{ID: "second", Run: "echo '${{ steps.first.output }}'"},
+	})
+
+	cache := NewStepCache()
+	config := DefaultExecutorConfig()
+	config.StepCache = cache
+
+	run := func() *execcontext.ExecutionContext {
+		execCtx := createTestExecutionContext(workflow)
+		executor, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, config, workflow, nil, &Runner{})
+		require.NoError(t, err)
+		require.NoError(t, executor.ExecuteWorkflow(execCtx, nil))
+		return execCtx
+	}
+
+	firstRun := run()
+	assert.Equal(t, 2, cache.Len())
+
+	secondRun := run()
+	first, _ := firstRun.GetStepResult("first")
+	second, _ := secondRun.GetStepResult("first")
+	assert.Equal(t, first.Response, second.Response, "expected cached result to be reused")
+
+	// changing a step invalidates it along with every step after it
+	workflow.Workflow.Steps[0].Run = "date +%s%N && echo changed"
+	thirdRun := run()
+	third, _ := thirdRun.GetStepResult("first")
+	assert.NotEqual(t, first.Response, third.Response)
+	assert.Equal(t, 4, cache.Len())
+}
+
+func TestExecuteWorkflow_UntilStep(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "first", Run: "echo 'first'"},
+		{ID: "second", Run: "echo 'second'"},
+	})
+	workflow.Workflow.Outputs = map[string]any{
+		"result": "${{ steps.second.output }}",
+	}
+
+	config := DefaultExecutorConfig()
+	config.UntilStep = "first"
+
+	execCtx := createTestExecutionContext(workflow)
+	executor, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, config, workflow, nil, &Runner{})
+	require.NoError(t, err)
+	require.NoError(t, executor.ExecuteWorkflow(execCtx, nil))
+
+	first, _ := execCtx.GetStepResult("first")
+	assert.Equal(t, execcontext.StepStatusCompleted, first.Status)
+	second, _ := execCtx.GetStepResult("second")
+	assert.Equal(t, execcontext.StepStatusPending, second.Status)
+	assert.Empty(t, execCtx.GetWorkflowOutputs())
+}
+
+func TestExecuteWorkflow_FromStep(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "first", Run: "exit 1"},
+		{ID: "second", Run: "echo '${{ steps.first.output }}'"},
+	})
+
+	config := DefaultExecutorConfig()
+	config.FromStep = "second"
+
+	executor, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, config, workflow, nil, &Runner{})
+	require.NoError(t, err)
+
+	err = executor.ExecuteWorkflow(createTestExecutionContext(workflow), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no previous results or fixtures for step(s): first")
+
+	config.SeedResults = map[string]*CachedStep{
+		"first": NewFixtureStep("first", "from fixture"),
+	}
+
+	execCtx := createTestExecutionContext(workflow)
+	require.NoError(t, executor.ExecuteWorkflow(execCtx, nil))
+
+	second, _ := execCtx.GetStepResult("second")
+	assert.Equal(t, "from fixture\n", second.Response)
+}
+
+func TestStepStore_SaveAndLoad(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "first", Run: "echo 'first'"},
+		{ID: "second", Run: "echo 'second'"},
+	})
+	workflow.SourceFile = filepath.Join(t.TempDir(), "workflow.laq.yaml")
+
+	execCtx := createTestExecutionContext(workflow)
+	execCtx.SetStepResult("first", &execcontext.StepResult{
+		StepID:   "first",
+		Status:   execcontext.StepStatusCompleted,
+		Output:   map[string]interface{}{"output": "first"},
+		Response: "first",
+	})
+
+	store := NewStepStore(t.TempDir(), nil)
+	require.NoError(t, store.Save(execCtx))
+
+	results, err := store.Load(workflow.SourceFile)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "first", results["first"].Response)
+}
+
+func TestStepStore_Encrypted(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "first", Run: "echo 'first'"}})
+	workflow.SourceFile = filepath.Join(t.TempDir(), "workflow.laq.yaml")
+
+	execCtx := createTestExecutionContext(workflow)
+	execCtx.SetStepResult("first", &execcontext.StepResult{
+		StepID:   "first",
+		Status:   execcontext.StepStatusCompleted,
+		Output:   map[string]interface{}{"output": "a secret"},
+		Response: "a secret",
+	})
+
+	cipher, err := encryption.NewCipher(make([]byte, encryption.KeySize))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	store := NewStepStore(dir, cipher)
+	require.NoError(t, store.Save(execCtx))
+
+	data, err := os.ReadFile(store.path(workflow.SourceFile))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "a secret")
+
+	results, err := store.Load(workflow.SourceFile)
+	require.NoError(t, err)
+	assert.Equal(t, "a secret", results["first"].Response)
+
+	_, err = NewStepStore(dir, nil).Load(workflow.SourceFile)
+	assert.ErrorIs(t, err, encryption.ErrKeyRequired)
+}
+
+func TestExecutor_RenderSystemPrompt(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "step1"}})
+	workflow.Context = &ast.WorkflowContext{
+		Facts:       []string{"The customer is ${{ inputs.customer }}"},
+		Constraints: []string{"Respond in British English", "Never share pricing"},
+	}
+
+	execCtx := execcontext.NewExecutionContext(
+		execcontext.RunContext{Context: context.Background()},
+		workflow,
+		map[string]interface{}{"customer": "Acme"},
+		"/tmp",
+	)
+
+	executor, err := createMockExecutor(workflow)
+	require.NoError(t, err)
+	e := executor.(*Executor)
+	e.execCtx = execCtx
+
+	systemPrompt, err := e.renderSystemPrompt(&ast.Agent{SystemPrompt: "You are a support agent."})
+	require.NoError(t, err)
+	assert.Equal(t, "You are a support agent.\n\nContext:\n- The customer is Acme\n\nConstraints:\n- Respond in British English\n- Never share pricing", systemPrompt)
+
+	systemPrompt, err = e.renderSystemPrompt(&ast.Agent{})
+	require.NoError(t, err)
+	assert.Equal(t, "Context:\n- The customer is Acme\n\nConstraints:\n- Respond in British English\n- Never share pricing", systemPrompt)
+
+	workflow.Context = nil
+	systemPrompt, err = e.renderSystemPrompt(&ast.Agent{SystemPrompt: "You are a support agent."})
+	require.NoError(t, err)
+	assert.Equal(t, "You are a support agent.", systemPrompt)
+}
+
+func TestExecutor_ExecuteExportStep(t *testing.T) {
+	extract := &ast.Step{
+		ID:     "extract",
+		Agent:  "test_agent",
+		Prompt: "Extract the products",
+		Outputs: map[string]schema.JSON{
+			"products": {
+				Type: "array",
+				Items: map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"name"},
+					"properties": map[string]interface{}{
+						"name":  map[string]interface{}{"type": "string"},
+						"price": map[string]interface{}{"type": "number"},
+					},
+				},
+			},
+		},
+	}
+	export := &ast.Step{
+		ID: "report",
+		Export: &ast.ExportStep{
+			From: "${{ steps.extract.outputs.products }}",
+			Path: "reports/${{ inputs.name }}.csv",
+		},
+	}
+	workflow := createTestWorkflow([]*ast.Step{extract, export})
+
+	dir := t.TempDir()
+	execCtx := execcontext.NewExecutionContext(
+		execcontext.RunContext{Context: context.Background()},
+		workflow,
+		map[string]interface{}{"name": "products"},
+		dir,
+	)
+	extracted := NewStepResult(map[string]interface{}{
+		"products": []interface{}{
+			map[string]interface{}{"price": 9.5, "name": "Widget"},
+			map[string]interface{}{"name": "Gadget"},
+		},
+	})
+	execCtx.SetStepResult("extract", &execcontext.StepResult{
+		StepID: "extract",
+		Status: execcontext.StepStatusCompleted,
+		Output: extracted.Output,
+	})
+
+	executor, err := createMockExecutor(workflow)
+	require.NoError(t, err)
+
+	result, err := executor.(*Executor).executeExportStep(execCtx, export)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"path":    "reports/products.csv",
+		"format":  "csv",
+		"rows":    2,
+		"columns": []string{"name", "price"},
+	}, result.Output["outputs"])
+
+	data, err := os.ReadFile(filepath.Join(dir, "reports", "products.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "name,price\nWidget,9.5\nGadget,\n", string(data))
+
+	export.Export.From = "${{ inputs.name }}"
+	_, err = executor.(*Executor).executeExportStep(execCtx, export)
+	assert.EqualError(t, err, "cannot export ${{ inputs.name }}: expected a list of objects, got string")
+}
+
+func TestExecuteWorkflow_StepCacheNestedExportStep(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "report", Parallel: &ast.ParallelStep{Steps: []*ast.Step{
+			{ID: "export", Export: &ast.ExportStep{From: "${{ inputs.rows }}", Path: "rows.csv"}},
+		}}},
+	})
+	inputs := map[string]interface{}{"rows": []interface{}{map[string]interface{}{"name": "Widget"}}}
+
+	config := DefaultExecutorConfig()
+	config.StepCache = NewStepCache()
+
+	// every run writes the file in its own working directory, the step
+	// wrapping the export step isn't reused from the step cache
+	for range 2 {
+		execCtx, _, err := runTestWorkflow(t, workflow, inputs, withExecutorConfig(config))
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(execCtx.Cwd, "rows.csv"))
+		require.NoError(t, err)
+		assert.Equal(t, "name\nWidget\n", string(data))
+	}
+}
+
+func TestExecutor_ExecuteExportStep_Findings(t *testing.T) {
+	export := &ast.Step{
+		ID: "report",
+		Export: &ast.ExportStep{
+			From: "${{ steps.review.outputs.findings }}",
+			Path: "review.sarif",
+			Tool: "reviewer",
+		},
+	}
+	workflow := createTestWorkflow([]*ast.Step{export})
+
+	dir := t.TempDir()
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{Context: context.Background()}, workflow, nil, dir)
+	execCtx.SetStepResult("review", &execcontext.StepResult{
+		StepID: "review",
+		Status: execcontext.StepStatusCompleted,
+		Output: NewStepResult(map[string]interface{}{
+			"findings": []interface{}{
+				map[string]interface{}{"file": "main.go", "line": 12, "severity": "error", "message": "err is ignored"},
+			},
+		}).Output,
+	})
+
+	executor, err := createMockExecutor(workflow)
+	require.NoError(t, err)
+
+	result, err := executor.(*Executor).executeExportStep(execCtx, export)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"path":     "review.sarif",
+		"format":   "sarif",
+		"findings": 1,
+	}, result.Output["outputs"])
+
+	data, err := os.ReadFile(filepath.Join(dir, "review.sarif"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"name": "reviewer"`)
+	assert.Contains(t, string(data), `"uri": "main.go"`)
+
+	export.Export.Path = "annotations.json"
+	export.Export.Format = "github"
+	_, err = executor.(*Executor).executeExportStep(execCtx, export)
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(filepath.Join(dir, "annotations.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"annotation_level": "failure"`)
+}
+
+func TestExecutor_ExecuteIngestStep(t *testing.T) {
+	step := &ast.Step{
+		ID: "docs",
+		Ingest: &ast.IngestStep{
+			From:      "${{ inputs.files }}",
+			ChunkSize: 30,
+		},
+	}
+	workflow := createTestWorkflow([]*ast.Step{step})
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "guide.html"), []byte(
+		"<html><head><title>Guide</title></head><body><h1>Setup</h1><p>Install the CLI first.</p><h1>Usage</h1><p>Run it.</p></body></html>",
+	), 0600))
+
+	execCtx := execcontext.NewExecutionContext(
+		execcontext.RunContext{Context: context.Background()},
+		workflow,
+		map[string]interface{}{"files": []interface{}{"guide.html"}},
+		dir,
+	)
+
+	executor, err := createMockExecutor(workflow)
+	require.NoError(t, err)
+
+	result, err := executor.(*Executor).executeIngestStep(execCtx, step)
+	require.NoError(t, err)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "Install the CLI first.\n\nRun it.", outputs["text"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"index": 0, "source": "guide.html", "section": "Setup", "text": "Install the CLI first."},
+		map[string]interface{}{"index": 1, "source": "guide.html", "section": "Usage", "text": "Run it."},
+	}, outputs["chunks"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"source": "guide.html", "format": "html", "title": "Guide", "pages": 0, "chunks": 2},
+	}, outputs["documents"])
+
+	step.Ingest.From = "notes.txt"
+	_, err = executor.(*Executor).executeIngestStep(execCtx, step)
+	assert.EqualError(t, err, "cannot determine the format of notes.txt, set format to one of: pdf, html, docx")
+}
+
+func repairWorkflow(pr *scriptedProvider, repair *int) *ast.Workflow {
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID:     "rate",
+			Agent:  "critic",
+			Prompt: "Rate the film",
+			Outputs: map[string]schema.JSON{
+				"score":  {Type: "integer", Description: "Score from 1 to 5"},
+				"review": {Type: "string"},
+			},
+			Repair: repair,
+		},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"critic": {Name: "critic", Provider: pr.name, Model: "test-model"},
+	}
+	return workflow
+}
+
+func TestExecuteWorkflow_AgentStepRepair(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock("It was great, I'd give it four stars"),
+			provider.NewTextBlock(`{"score": "4", "review": "Great"}`),
+			provider.NewTextBlock("```json\n{\"score\": 4, \"review\": \"Great\"}\n```"),
+		},
+	}
+
+	repair := 2
+	workflow := repairWorkflow(pr, &repair)
+	execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("rate")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"score": float64(4), "review": "Great"}, result.Output["outputs"])
+	assert.Equal(t, 2, result.Repairs)
+	assert.Equal(t, &execcontext.TokenUsage{PromptTokens: 30, CompletionTokens: 15, TotalTokens: 45}, result.TokenUsage)
+	assert.Equal(t, &execcontext.TokenUsage{PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30}, result.RepairUsage)
+
+	// every repair continues the conversation with the reasons the previous
+	// response was invalid
+	require.Len(t, pr.requests, 3)
+	assert.Len(t, pr.requests[1].Messages, 3)
+	assert.Equal(t, "Your output failed schema validation:\n- the response is not a JSON object\n\nRespond again with the corrected JSON object, using the schema given earlier.", getLastContentBlock(pr.requests[1].Messages))
+	assert.Len(t, pr.requests[2].Messages, 5)
+	assert.Contains(t, getLastContentBlock(pr.requests[2].Messages), "- score: expected integer, got string")
+}
+
+func TestExecuteWorkflow_AgentStepRepairExhausted(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock(`{"score": 4}`),
+			provider.NewTextBlock(`{"score": 4}`),
+		},
+	}
+
+	repair := 1
+	workflow := repairWorkflow(pr, &repair)
+	_, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "response doesn't match the step outputs after 1 repair attempts: missing required property review")
+}
+
+func TestExecuteWorkflow_AgentStepDefaultRepair(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock(`{"score": "four", "review": "Great"}`),
+			provider.NewTextBlock(`{"score": 4, "review": "Great"}`),
+		},
+	}
+
+	// responses which don't match the outputs are repaired without repair
+	// being set on the step
+	workflow := repairWorkflow(pr, nil)
+	execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("rate")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"score": float64(4), "review": "Great"}, result.Output["outputs"])
+	assert.Equal(t, 1, result.Repairs)
+	require.Len(t, pr.requests, 2)
+	assert.Contains(t, getLastContentBlock(pr.requests[1].Messages), "- score: expected integer, got string")
+}
+
+func TestExecuteWorkflow_AgentStepRepairDisabled(t *testing.T) {
+	pr := &scriptedProvider{
+		name:      "openai",
+		responses: []provider.ContentBlockParamUnion{provider.NewTextBlock("not JSON")},
+	}
+
+	// invalid responses are accepted as they are with repair: 0
+	repair := 0
+	workflow := repairWorkflow(pr, &repair)
+	execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("rate")
+	require.True(t, ok)
+	assert.Equal(t, 0, result.Repairs)
+	assert.Nil(t, result.RepairUsage)
+	assert.Len(t, pr.requests, 1)
+}
+
+func TestExecuteWorkflow_AgentStepStructuredOutput(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "anthropic",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewToolUseBlock("call_1", []byte(`{"score": "4"}`), "respond_rate"),
+			provider.NewToolUseBlock("call_2", []byte(`{"score": 4, "review": "Great"}`), "respond_rate"),
+		},
+	}
+
+	repair := 1
+	workflow := repairWorkflow(pr, &repair)
+	execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("rate")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"score": float64(4), "review": "Great"}, result.Output["outputs"])
+	assert.Equal(t, 1, result.Repairs)
+
+	// the outputs are requested as a structured output rather than in the prompt
+	require.Len(t, pr.requests, 2)
+	require.NotNil(t, pr.requests[0].ResponseSchema)
+	assert.Equal(t, "respond_rate", pr.requests[0].ResponseSchema.Name)
+	assert.Equal(t, []string{"review", "score"}, pr.requests[0].ResponseSchema.Schema.Required)
+	assert.Equal(t, "Rate the film", getLastContentBlock(pr.requests[0].Messages))
+
+	// the invalid structured output is answered with the tool's result
+	repairMessage := pr.requests[1].Messages[len(pr.requests[1].Messages)-1]
+	require.NotNil(t, repairMessage.Content[0].OfToolResult)
+	assert.Equal(t, "call_1", repairMessage.Content[0].OfToolResult.ToolUseID)
+	assert.Contains(t, repairMessage.Content[0].OfToolResult.Content, "- score: expected integer, got string")
+}
+
+func TestAgentResponseSchema(t *testing.T) {
+	outputs := map[string]schema.JSON{"score": {Type: "integer"}}
+	tests := []struct {
+		name   string
+		step   *ast.Step
+		agent  *ast.Agent
+		native bool
+	}{
+		{name: "anthropic", step: &ast.Step{ID: "rate", Outputs: outputs}, agent: &ast.Agent{Provider: "anthropic"}, native: true},
+		{name: "openai with tools", step: &ast.Step{ID: "rate", Outputs: outputs}, agent: &ast.Agent{Provider: "openai", Tools: []*ast.Tool{{Name: "search"}}}, native: true},
+		{name: "without outputs", step: &ast.Step{ID: "rate"}, agent: &ast.Agent{Provider: "openai"}},
+		{name: "openai-compatible", step: &ast.Step{ID: "rate", Outputs: outputs}, agent: &ast.Agent{Provider: ast.OpenAICompatibleProvider}},
+		{name: "local", step: &ast.Step{ID: "rate", Outputs: outputs}, agent: &ast.Agent{Provider: "local"}},
+		{name: "anthropic with tools", step: &ast.Step{ID: "rate", Outputs: outputs}, agent: &ast.Agent{Provider: "anthropic", Tools: []*ast.Tool{{Name: "search"}}}},
+		{name: "anthropic in a session", step: &ast.Step{ID: "rate", Outputs: outputs, Session: "review"}, agent: &ast.Agent{Provider: "anthropic"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.native, agentResponseSchema(tt.step, tt.agent) != nil)
+		})
+	}
+}
+
+func TestInitializeRequiredProviders_OpenAICompatible(t *testing.T) {
+	workflow := createTestWorkflow(nil)
+	workflow.Agents = map[string]*ast.Agent{
+		"small": {Provider: ast.OpenAICompatibleProvider, Model: "llama-3.1-8b", BaseURL: "http://localhost:8000/v1"},
+		"large": {Provider: ast.OpenAICompatibleProvider, Model: "llama-3.1-70b", BaseURL: "http://localhost:8000/v1"},
+		"other": {Provider: ast.OpenAICompatibleProvider, Model: "llama-3.1-8b", BaseURL: "http://localhost:9000/v1"},
+	}
+
+	registry := provider.NewRegistry(true)
+	require.NoError(t, initializeRequiredProviders(context.Background(), registry, getRequiredProviders(workflow)))
+
+	// each endpoint and model has a provider of its own
+	names := make(map[string]bool)
+	for name, agent := range workflow.Agents {
+		pr, err := registry.GetProviderForModel(agent.ProviderName(), agent.Model)
+		require.NoError(t, err, name)
+		names[pr.GetName()] = true
+	}
+	assert.Len(t, names, 3)
+}
+
+// constrainedProvider is a scripted provider which only accepts two tools
+// with names matching provider.ToolNamePattern.
+type constrainedProvider struct {
+	*scriptedProvider
+}
+
+func (p *constrainedProvider) ToolConstraints() provider.ToolConstraints {
+	return provider.ToolConstraints{MaxTools: 2, NamePattern: provider.ToolNamePattern, NameRule: provider.ToolNameRule}
+}
+
+func TestNewExecutor_ToolConstraints(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "answer", Agent: "researcher", Prompt: "Why?"}})
+	workflow.Agents = map[string]*ast.Agent{
+		"researcher": {Name: "researcher", Provider: "anthropic", Model: "test-model", Tools: []*ast.Tool{
+			{Name: "web.search", Script: "echo"},
+			{Name: "fetch", Script: "echo"},
+			{Name: "summarize", Script: "echo"},
+		}},
+		"writer": {Name: "writer", Provider: "anthropic", Model: "test-model", Tools: []*ast.Tool{
+			{Name: "draft", Script: "echo"},
+		}},
+	}
+
+	registry := provider.NewRegistry(false)
+	require.NoError(t, registry.RegisterProvider(&constrainedProvider{&scriptedProvider{name: "anthropic"}}))
+
+	_, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, DefaultExecutorConfig(), workflow, registry, &Runner{})
+	assert.EqualError(t, err, "tools aren't accepted by the agents' providers:\n"+
+		"  - agent researcher: 3 tools are given but at most 2 are accepted\n"+
+		"  - agent researcher, tool web.search: name "+provider.ToolNameRule)
+}
+
+func TestResponseText(t *testing.T) {
+	isError := false
+	messages := []provider.Message{
+		{Role: "assistant", Content: []provider.ContentBlockParamUnion{provider.NewThinkingBlock("sig", "hmm")}},
+		{Role: "assistant", Content: []provider.ContentBlockParamUnion{
+			provider.NewToolResultBlock("call-1", `{"rows": 3}`, &isError),
+			provider.NewToolResultBlock("call-2", "no rows", &isError),
+		}},
+		{Role: "assistant", Content: []provider.ContentBlockParamUnion{provider.NewTextBlock("The query found 3 rows.")}},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "", want: "Tool result: {\"rows\": 3}\n\nTool result: no rows\n\nThe query found 3 rows."},
+		{format: ast.ToolResultFormatText, want: "Tool result: {\"rows\": 3}\n\nTool result: no rows\n\nThe query found 3 rows."},
+		{format: ast.ToolResultFormatJSON, want: "{\"rows\": 3}\n\n\"no rows\"\n\nThe query found 3 rows."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			assert.Equal(t, tt.want, responseText(messages, tt.format))
+		})
+	}
+
+	// the last message only, all of its blocks
+	assert.Equal(t, "The query found 3 rows.", getLastContentBlock(messages))
+	assert.Equal(t, "Tool result: {\"rows\": 3}\n\nTool result: no rows", getLastContentBlock(messages[:2]))
+}
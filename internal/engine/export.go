@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/findings"
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/lacquerai/lacquer/internal/tabular"
+	"github.com/rs/zerolog/log"
+)
+
+// stepOutputRefPattern matches an expression referencing a single output of
+// a step, e.g. ${{ steps.extract.outputs.rows }}
+var stepOutputRefPattern = regexp.MustCompile(`^\$\{\{\s*steps\.([a-zA-Z0-9_-]+)\.outputs\.([a-zA-Z0-9_-]+)\s*\}\}$`)
+
+// executeExportStep writes a list of objects to a CSV or XLSX file, or a
+// list of review findings to a SARIF or GitHub annotations file.
+func (e *Executor) executeExportStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	export := step.Export
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("path", export.Path).
+		Msg("Executing export step")
+
+	value, err := e.templateEngine.Render(export.From, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render from: %w", err)
+	}
+
+	// outputs of scripts and untyped agent outputs are JSON strings
+	if s, ok := value.(string); ok {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s)), &decoded); err == nil {
+			value = decoded
+		}
+	}
+
+	rendered, err := e.templateEngine.Render(export.Path, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render path: %w", err)
+	}
+	path := expression.ValueToString(rendered)
+
+	format := export.Format
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	var buf bytes.Buffer
+	var outputs map[string]interface{}
+	switch format {
+	case tabular.FormatCSV, tabular.FormatXLSX:
+		table, err := tabular.NewTable(value, export.Columns, exportItemSchema(execCtx.Workflow, export.From))
+		if err != nil {
+			return nil, fmt.Errorf("cannot export %s: %w", export.From, err)
+		}
+
+		if format == tabular.FormatCSV {
+			err = table.WriteCSV(&buf)
+		} else {
+			err = table.WriteXLSX(&buf)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+		}
+		outputs = map[string]interface{}{
+			"rows":    len(table.Rows),
+			"columns": table.Columns,
+		}
+	case findings.FormatSARIF, findings.FormatGitHub:
+		found, err := findings.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot export %s: %w", export.From, err)
+		}
+
+		if format == findings.FormatSARIF {
+			err = findings.WriteSARIF(&buf, found, exportToolName(execCtx.Workflow, export.Tool))
+		} else {
+			err = findings.WriteGitHubAnnotations(&buf, found)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+		}
+		outputs = map[string]interface{}{
+			"findings": len(found),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported export format %q for %s, must be one of: %s", format, path, strings.Join(slices.Concat(tabular.Formats, findings.Formats), ", "))
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(execCtx.Cwd, target)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+
+	if err := os.WriteFile(target, buf.Bytes(), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	outputs["path"] = path
+	outputs["format"] = format
+
+	return NewStepResult(outputs), nil
+}
+
+// exportToolName returns the name of the reviewer in SARIF files, the
+// workflow's name unless the export step names it.
+func exportToolName(workflow *ast.Workflow, tool string) string {
+	if tool != "" {
+		return tool
+	}
+	if workflow != nil && workflow.Metadata != nil && workflow.Metadata.Name != "" {
+		return workflow.Metadata.Name
+	}
+
+	return "lacquer"
+}
+
+// exportItemSchema returns the item schema of the step output referenced by
+// an export, so that the table's columns follow the declared output.
+func exportItemSchema(workflow *ast.Workflow, from string) *schema.JSON {
+	match := stepOutputRefPattern.FindStringSubmatch(strings.TrimSpace(from))
+	if match == nil || workflow == nil || workflow.Workflow == nil {
+		return nil
+	}
+
+	step := findStep(workflow.Workflow.Steps, match[1])
+	if step == nil {
+		return nil
+	}
+
+	output, ok := step.Outputs[match[2]]
+	if !ok {
+		return nil
+	}
+
+	return tabular.ItemSchema(&output)
+}
+
+func findStep(steps []*ast.Step, id string) *ast.Step {
+	for _, step := range steps {
+		if step.ID == id {
+			return step
+		}
+		if found := findStep(step.Steps, id); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/events"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/routing"
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultStructuredRetries is the number of times a response which doesn't
+// match its schema is sent back to the agent to be corrected.
+const defaultStructuredRetries = 2
+
+// executeExtractStep asks an agent to extract the step's outputs from text.
+// Responses are validated against the outputs and invalid ones are retried
+// with the violations so the agent can correct them.
+func (e *Executor) executeExtractStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Extract
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("agent", spec.Agent).
+		Msg("Executing extract step")
+
+	agent, pr, decision, err := e.resolveStepAgent(execCtx, step, spec.Agent)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := e.buildExtractPrompt(execCtx, step)
+	if err != nil {
+		return nil, err
+	}
+
+	responseSchema := &provider.ResponseSchema{
+		Name:        "extract_" + step.ID,
+		Description: "Record the values extracted from the text",
+		Schema:      extractSchema(step.Outputs),
+	}
+
+	retries := defaultStructuredRetries
+	if spec.Retries != nil {
+		retries = *spec.Retries
+	}
+
+	outputs, usage, err := e.generateStructured(execCtx, step, pr, agent, prompt, responseSchema, retries)
+	e.recordSpend(agent, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewStepResult(outputs)
+	result.TokenUsage = usage
+	result.Routing = decision
+
+	return result, nil
+}
+
+// resolveStepAgent returns the agent used by a step which isn't an agent
+// step, such as extract, routed to a model when it uses a tier, along with
+// the provider of its model.
+func (e *Executor) resolveStepAgent(execCtx *execcontext.ExecutionContext, step *ast.Step, name string) (*ast.Agent, provider.Provider, *routing.Decision, error) {
+	agent, exists := execCtx.Workflow.GetAgent(name)
+	if !exists {
+		return nil, nil, nil, fmt.Errorf("agent %s not found", name)
+	}
+	agent = e.availableAgent(execCtx, step, agent)
+
+	var decision *routing.Decision
+	if agent.Tier != "" {
+		var err error
+		agent, decision, err = e.routeAgent(execCtx, step, agent)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	model, err := e.resolveModel(agent)
+	if err == nil && model != agent.Model {
+		aliased := *agent
+		aliased.Model = model
+		agent = &aliased
+	}
+
+	pr, err := e.modelRegistry.GetProviderForModel(agent.ProviderName(), agent.Model)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get provider %s for model %s: %w", agent.Provider, agent.Model, err)
+	}
+
+	return agent, pr, decision, nil
+}
+
+// generateStructured requests an object matching the response schema from
+// the model. Anthropic and OpenAI models are sent the schema as a structured
+// output, other providers are given it in the prompt. Responses which don't
+// match the schema are sent back with the reasons they're invalid up to
+// retries times.
+func (e *Executor) generateStructured(execCtx *execcontext.ExecutionContext, step *ast.Step, pr provider.Provider, agent *ast.Agent, prompt string, responseSchema *provider.ResponseSchema, retries int) (map[string]interface{}, *execcontext.TokenUsage, error) {
+	if !supportsResponseSchema(agent.Provider) {
+		schemaJSON, err := json.Marshal(responseSchema.Schema)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal response schema: %w", err)
+		}
+		prompt += "\n\n" + JSON_OUTPUT_SCHEMA_PREFIX + "\n```json\n" + string(schemaJSON) + "\n```"
+	}
+
+	usage := &execcontext.TokenUsage{}
+	messages := []provider.Message{
+		{
+			Role:    "user",
+			Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(prompt)},
+		},
+	}
+
+	var violations []string
+	for attempt := 0; attempt <= retries; attempt++ {
+		request, err := e.createModelRequestWithTools(agent, messages, agent.Provider)
+		if err != nil {
+			return nil, usage, fmt.Errorf("failed to create model request: %w", err)
+		}
+		// the agent's tools aren't available, extraction only needs the text
+		request.Tools = nil
+		request.ResponseSchema = responseSchema
+
+		actionID := fmt.Sprintf("%s-%d", step.GetStepType(), attempt)
+		if e.progressChan != nil {
+			e.progressChan <- events.NewPromptAgentEvent(step.ID, actionID, execCtx.RunID, RemoveJSONSchema(getLastContentBlock(messages)))
+		}
+
+		responseMessages, attemptUsage, err := e.generate(execCtx, pr, agent, step, actionID, request)
+		usage.Add(attemptUsage)
+		if err != nil {
+			if e.progressChan != nil {
+				e.progressChan <- events.NewAgentFailedEvent(step, actionID, execCtx.RunID)
+			}
+			return nil, usage, fmt.Errorf("model generation failed: %w", err)
+		}
+
+		if e.progressChan != nil {
+			e.progressChan <- events.NewAgentCompletedEvent(step, actionID, execCtx.RunID)
+		}
+
+		outputs, toolUse := e.parseExtractResponse(responseMessages, responseSchema.Name)
+		if outputs == nil {
+			violations = []string{"the response is not a JSON object"}
+		} else {
+			violations = responseSchema.Schema.Validate(outputs)
+		}
+		if len(violations) == 0 {
+			return outputs, usage, nil
+		}
+
+		log.Debug().
+			Str("step_id", step.ID).
+			Int("attempt", attempt+1).
+			Strs("violations", violations).
+			Msg("Response doesn't match the schema")
+
+		feedback := "The response doesn't match the schema:\n- " + strings.Join(violations, "\n- ") +
+			"\n\nRespond again, correcting these problems."
+
+		// a forced tool call must be answered with its result
+		reply := provider.NewTextBlock(feedback)
+		if toolUse != nil {
+			isError := true
+			reply = provider.NewToolResultBlock(toolUse.ID, feedback, &isError)
+		}
+
+		messages = append(messages, responseMessages...)
+		messages = append(messages, provider.Message{
+			Role:    "user",
+			Content: []provider.ContentBlockParamUnion{reply},
+		})
+	}
+
+	return nil, usage, fmt.Errorf("response doesn't match the schema after %d attempts: %s", retries+1, strings.Join(violations, "; "))
+}
+
+// parseExtractResponse returns the object in the response, either the input
+// of the structured output tool call or JSON in the text.
+func (e *Executor) parseExtractResponse(messages []provider.Message, toolName string) (map[string]interface{}, *provider.ToolUseBlockParam) {
+	for _, toolUse := range e.getToolCallsFromResponseMessages(messages) {
+		if toolUse.Name != toolName {
+			continue
+		}
+
+		var outputs map[string]interface{}
+		if err := json.Unmarshal(toolUse.Input, &outputs); err != nil {
+			return nil, toolUse
+		}
+		return outputs, toolUse
+	}
+
+	return e.outputParser.extractJSON(responseText(messages, ast.ToolResultFormatText)), nil
+}
+
+// buildExtractPrompt renders the instructions and the text to extract from.
+func (e *Executor) buildExtractPrompt(execCtx *execcontext.ExecutionContext, step *ast.Step) (string, error) {
+	spec := step.Extract
+
+	text, err := e.templateEngine.Render(spec.From, execCtx)
+	if err != nil {
+		return "", fmt.Errorf("failed to render from: %w", err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Extract the following fields from the text below:\n")
+
+	names := make([]string, 0, len(step.Outputs))
+	for name := range step.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		builder.WriteString("- " + name)
+		if description := step.Outputs[name].Description; description != "" {
+			builder.WriteString(": " + description)
+		}
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("\nUse only information in the text. Use null for fields the text doesn't contain, don't guess.\n")
+
+	if spec.Instructions != "" {
+		instructions, err := e.templateEngine.Render(spec.Instructions, execCtx)
+		if err != nil {
+			return "", fmt.Errorf("failed to render instructions: %w", err)
+		}
+		builder.WriteString("\n" + expression.ValueToString(instructions) + "\n")
+	}
+
+	builder.WriteString("\n<text>\n")
+	builder.WriteString(expression.ValueToString(text))
+	builder.WriteString("\n</text>")
+
+	return builder.String(), nil
+}
+
+// extractSchema builds the object schema of the step's outputs. Every output
+// is required but may be null when the text doesn't contain it.
+func extractSchema(outputs map[string]schema.JSON) schema.JSON {
+	properties := make(map[string]schema.JSON, len(outputs))
+	required := make([]string, 0, len(outputs))
+	for name, output := range outputs {
+		if t, ok := output.Type.(string); ok && t != "null" {
+			output.Type = []string{t, "null"}
+		}
+		if len(output.Enum) > 0 {
+			output.Enum = append(append([]interface{}(nil), output.Enum...), nil)
+		}
+		properties[name] = output
+		required = append(required, name)
+	}
+	sort.Strings(required)
+
+	return schema.JSON{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: false,
+	}
+}
+
+// supportsResponseSchema reports whether the provider implements
+// provider.Request.ResponseSchema.
+func supportsResponseSchema(providerName string) bool {
+	return providerName == "anthropic" || providerName == "openai"
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/schema"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider responds to each request with the next scripted message
+// and records the requests it receives.
+type scriptedProvider struct {
+	name      string
+	responses []provider.ContentBlockParamUnion
+	requests  []*provider.Request
+	mu        sync.Mutex
+	// snapshot is the model snapshot reported with the usage of responses
+	snapshot string
+}
+
+func (p *scriptedProvider) Generate(_ provider.GenerateContext, request *provider.Request, _ chan<- pkgEvents.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	response := p.responses[len(p.requests)]
+	p.requests = append(p.requests, request)
+
+	return []provider.Message{{
+		Role:    "assistant",
+		Content: []provider.ContentBlockParamUnion{response},
+	}}, &execcontext.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Model: p.snapshot}, nil
+}
+
+func (p *scriptedProvider) GetName() string { return p.name }
+
+func (p *scriptedProvider) ListModels(context.Context) ([]provider.Info, error) {
+	return []provider.Info{{ID: "test-model", Name: "Test Model"}}, nil
+}
+
+func (p *scriptedProvider) Close() error { return nil }
+
+func extractWorkflow(pr *scriptedProvider, retries *int) *ast.Workflow {
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID: "invoice",
+			Extract: &ast.ExtractStep{
+				Agent:        "extractor",
+				From:         "Invoice from ${{ inputs.vendor }}, total due EUR 120.50",
+				Instructions: "Amounts are in euros",
+				Retries:      retries,
+			},
+			Outputs: map[string]schema.JSON{
+				"vendor":   {Type: "string", Description: "The company that sent the invoice"},
+				"total":    {Type: "number"},
+				"due_date": {Type: "string"},
+			},
+		},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"extractor": {Name: "extractor", Provider: pr.name, Model: "test-model"},
+	}
+	return workflow
+}
+
+func TestExecutor_ExecuteExtractStep(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "anthropic",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewToolUseBlock("call_1", json.RawMessage(`{"vendor":"ACME","total":"120.50"}`), "extract_invoice"),
+			provider.NewToolUseBlock("call_2", json.RawMessage(`{"vendor":"ACME","total":120.5,"due_date":null}`), "extract_invoice"),
+		},
+	}
+
+	workflow := extractWorkflow(pr, nil)
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"vendor": "ACME"}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("invoice")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"vendor": "ACME", "total": 120.5, "due_date": nil}, result.Output["outputs"])
+	assert.Equal(t, 30, result.TokenUsage.TotalTokens)
+
+	require.Len(t, pr.requests, 2)
+	first := pr.requests[0]
+	require.NotNil(t, first.ResponseSchema)
+	assert.Equal(t, "extract_invoice", first.ResponseSchema.Name)
+	assert.Equal(t, []string{"due_date", "total", "vendor"}, first.ResponseSchema.Schema.Required)
+	assert.Equal(t, []string{"number", "null"}, first.ResponseSchema.Schema.Properties["total"].Type)
+	assert.Empty(t, first.Tools)
+
+	prompt := first.GetPrompt()
+	assert.Contains(t, prompt, "- vendor: The company that sent the invoice\n")
+	assert.Contains(t, prompt, "Amounts are in euros")
+	assert.Contains(t, prompt, "<text>\nInvoice from ACME, total due EUR 120.50\n</text>")
+	assert.NotContains(t, prompt, JSON_OUTPUT_SCHEMA_PREFIX)
+
+	// the invalid tool call is answered with the violations
+	retry := pr.requests[1].Messages
+	require.Len(t, retry, 3)
+	feedback := retry[2].Content[0].OfToolResult
+	require.NotNil(t, feedback)
+	assert.Equal(t, "call_1", feedback.ToolUseID)
+	assert.Contains(t, feedback.Content, "- missing required property due_date\n- total: expected number or null, got string")
+}
+
+func TestExecutor_ExecuteExtractStep_InvalidAfterRetries(t *testing.T) {
+	retries := 1
+	pr := &scriptedProvider{
+		name: "local",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock("I couldn't find an invoice"),
+			provider.NewTextBlock("```json\n{\"vendor\":\"ACME\",\"total\":1,\"due_date\":null,\"notes\":\"x\"}\n```"),
+		},
+	}
+
+	workflow := extractWorkflow(pr, &retries)
+	_, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"vendor": "ACME"}, withProviders(pr))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "response doesn't match the schema after 2 attempts: unexpected property notes")
+
+	// providers without structured outputs get the schema in the prompt
+	require.Len(t, pr.requests, 2)
+	assert.Contains(t, pr.requests[0].GetPrompt(), JSON_OUTPUT_SCHEMA_PREFIX)
+	assert.Equal(t, "The response doesn't match the schema:\n- the response is not a JSON object\n\nRespond again, correcting these problems.", pr.requests[1].Messages[2].Content[0].OfText.Text)
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/rs/zerolog/log"
+)
+
+// executeForEachStep runs the step's sub-steps, or its agent prompt, once
+// per item of its for_each list in a child context of its own. At most
+// max_parallel iterations run at the same time, once an iteration fails no
+// more are started and the step fails. The outputs of the iterations are
+// collected in the order of the items.
+func (e *Executor) executeForEachStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	items, err := e.forEachItems(execCtx, step)
+	if err != nil {
+		return nil, err
+	}
+
+	maxParallel := 1
+	if step.MaxParallel != nil && *step.MaxParallel > 0 {
+		maxParallel = *step.MaxParallel
+	}
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Int("items", len(items)).
+		Int("max_parallel", maxParallel).
+		Msg("Executing for_each step")
+
+	ctx, cancel := context.WithCancel(execCtx.Context.Context)
+	defer cancel()
+
+	outputs := make([]interface{}, len(items))
+	usages := make([]*execcontext.TokenUsage, len(items))
+
+	// the first iteration to fail stops the others, their failures are
+	// caused by it
+	var failureMu sync.Mutex
+	var failure error
+	failed := -1
+
+	sem := make(chan struct{}, maxParallel)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		iterCtx := execCtx.NewChild(step.Steps)
+		iterCtx.Context.Context = ctx
+		iterCtx.Each = map[string]interface{}{
+			"item":  item,
+			"index": i,
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var err error
+			outputs[i], usages[i], err = e.executeForEachIteration(iterCtx, step, i)
+			if err != nil {
+				failureMu.Lock()
+				if failure == nil {
+					failure, failed = err, i
+				}
+				failureMu.Unlock()
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	usage := &execcontext.TokenUsage{}
+	for _, iterationUsage := range usages {
+		usage.Add(iterationUsage)
+	}
+
+	if failure != nil {
+		return nil, fmt.Errorf("iteration %d of for_each failed: %w", failed, failure)
+	}
+	if err := execCtx.Context.Context.Err(); err != nil {
+		return nil, err
+	}
+
+	return &StepResult{
+		Output: map[string]interface{}{
+			"outputs":    outputs,
+			"output":     expression.ValueToString(outputs),
+			"iterations": len(items),
+		},
+		Response:   expression.ValueToString(outputs),
+		TokenUsage: usage,
+	}, nil
+}
+
+// executeForEachIteration runs one iteration of a for_each step, returning
+// its outputs. The outputs of an agent prompt are its outputs, or its output
+// when the step has none, the outputs of sub-steps are the output of each
+// sub-step by id.
+func (e *Executor) executeForEachIteration(iterCtx *execcontext.ExecutionContext, step *ast.Step, index int) (interface{}, *execcontext.TokenUsage, error) {
+	if step.IsAgentStep() {
+		result, err := e.executeAgentPrompt(iterCtx, step, fmt.Sprintf("item-%d-", index))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if outputs, ok := result.Output["outputs"]; ok {
+			return outputs, result.TokenUsage, nil
+		}
+		return result.Output["output"], result.TokenUsage, nil
+	}
+
+	err := e.executeSteps(iterCtx, step.Steps)
+
+	usage := &execcontext.TokenUsage{}
+	outputs := make(map[string]interface{}, len(iterCtx.StepResults))
+	for _, result := range iterCtx.StepResults {
+		usage.Add(result.TokenUsage)
+		outputs[result.StepID] = result.Output
+	}
+	if err != nil {
+		return nil, usage, err
+	}
+
+	return outputs, usage, nil
+}
+
+// forEachItems evaluates the for_each expression of the step to a list. A
+// string is decoded as a JSON array so the output of scripts can be used.
+func (e *Executor) forEachItems(execCtx *execcontext.ExecutionContext, step *ast.Step) ([]interface{}, error) {
+	value, err := e.templateEngine.Render(step.ForEach, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render for_each: %w", err)
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items, nil
+	case string:
+		var items []interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(v)), &items); err != nil {
+			return nil, fmt.Errorf("for_each must evaluate to a list, got %q", v)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("for_each must evaluate to a list, got %T", value)
+	}
+}
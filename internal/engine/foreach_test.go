@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteWorkflow_ForEachSteps(t *testing.T) {
+	maxParallel := 2
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID:          "each_file",
+			ForEach:     "${{ inputs.files }}",
+			MaxParallel: &maxParallel,
+			Steps: []*ast.Step{
+				{ID: "print", Run: "echo -n '${{ each.index }}: ${{ each.item }}'"},
+			},
+		},
+		{ID: "second", Run: "echo -n '${{ steps.each_file.outputs[1].print.output }}'"},
+	})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"files": []interface{}{"a.txt", "b.txt", "c.txt"},
+	})
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("each_file")
+	require.True(t, ok)
+	assert.Equal(t, 3, result.Output["iterations"])
+
+	outputs := result.Output["outputs"].([]interface{})
+	require.Len(t, outputs, 3)
+	for i, file := range []string{"a.txt", "b.txt", "c.txt"} {
+		print := outputs[i].(map[string]interface{})["print"].(map[string]interface{})
+		assert.Equal(t, fmt.Sprintf("%d: %s", i, file), print["output"])
+	}
+
+	second, ok := execCtx.GetStepResult("second")
+	require.True(t, ok)
+	assert.Equal(t, "1: b.txt", second.Response)
+}
+
+func TestExecuteWorkflow_ForEachAgentPrompt(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock(`{"score": 4}`),
+			provider.NewTextBlock(`{"score": 2}`),
+		},
+	}
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID:      "rate",
+			ForEach: `${{ inputs.films }}`,
+			Agent:   "critic",
+			Prompt:  "Rate ${{ each.item.title }}",
+			Outputs: map[string]schema.JSON{
+				"score": {Type: "integer"},
+			},
+		},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"critic": {Name: "critic", Provider: pr.name, Model: "test-model"},
+	}
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"films": []interface{}{
+			map[string]interface{}{"title": "Alien"},
+			map[string]interface{}{"title": "Cats"},
+		},
+	}, withProviders(pr))
+	require.NoError(t, err)
+
+	require.Len(t, pr.requests, 2)
+	assert.Contains(t, pr.requests[0].GetPrompt(), "Rate Alien")
+	assert.Contains(t, pr.requests[1].GetPrompt(), "Rate Cats")
+
+	result, ok := execCtx.GetStepResult("rate")
+	require.True(t, ok)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"score": float64(4)},
+		map[string]interface{}{"score": float64(2)},
+	}, result.Output["outputs"])
+	assert.Equal(t, 30, result.TokenUsage.TotalTokens)
+}
+
+func TestExecuteWorkflow_ForEachIterationFails(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID:      "each_code",
+			ForEach: "${{ inputs.codes }}",
+			Steps: []*ast.Step{
+				{ID: "exit", Run: "exit ${{ each.item }}"},
+			},
+		},
+	})
+
+	_, _, err := runTestWorkflow(t, workflow, map[string]interface{}{
+		"codes": "[0, 3, 0]",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "iteration 1 of for_each failed")
+}
+
+func TestForEachItems(t *testing.T) {
+	workflow := createTestWorkflow(nil)
+	executor, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, DefaultExecutorConfig(), workflow, provider.NewRegistry(false), &Runner{})
+	require.NoError(t, err)
+	e := executor.(*Executor)
+
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{Context: context.Background()}, workflow, map[string]interface{}{
+		"list":   []interface{}{"a", "b"},
+		"json":   `["a", "b"]`,
+		"number": 3,
+	}, t.TempDir())
+
+	items, err := e.forEachItems(execCtx, &ast.Step{ForEach: "${{ inputs.list }}"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, items)
+
+	items, err = e.forEachItems(execCtx, &ast.Step{ForEach: "${{ inputs.json }}"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, items)
+
+	_, err = e.forEachItems(execCtx, &ast.Step{ForEach: "${{ inputs.number }}"})
+	assert.EqualError(t, err, "for_each must evaluate to a list, got float64")
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/rs/zerolog/log"
+)
+
+// attemptResult is the outcome of a single model request.
+type attemptResult struct {
+	attempt  int
+	messages []provider.Message
+	usage    *execcontext.TokenUsage
+	err      error
+}
+
+// generate sends the request to the provider applying the agent's request
+// timeout and hedging. With hedging a second identical request is started
+// if the first hasn't responded within the hedge delay and the first
+// successful response is used, the other request is cancelled. The returned
+// usage includes every attempt which reported usage. Requests are cancelled
+// as soon as the step or run is aborted. The output of the model is streamed
+// as token deltas of actionID when it's set, unless the request is hedged as
+// the output of both requests would be interleaved, or outputs are moderated
+// as they can't be redacted once they're streamed. The newest message of the
+// request and the response are checked by the moderation policies.
+func (e *Executor) generate(execCtx *execcontext.ExecutionContext, pr provider.Provider, agent *ast.Agent, step *ast.Step, actionID string, request *provider.Request) ([]provider.Message, *execcontext.TokenUsage, error) {
+	// earlier messages were checked when they were sent
+	if len(request.Messages) > 0 {
+		if err := e.moderate(execCtx, step, actionID, ModerationPrompt, request.Messages[len(request.Messages)-1:]); err != nil {
+			return nil, &execcontext.TokenUsage{}, err
+		}
+	}
+
+	if err := e.breakers().Allow(pr.GetName()); err != nil {
+		return nil, &execcontext.TokenUsage{}, err
+	}
+
+	ctx, cancel := context.WithCancel(execCtx.Context.Context)
+	defer cancel()
+
+	hedged := agent.HedgeAfter != nil && agent.HedgeAfter.Duration > 0
+	streamID := actionID
+	if hedged || e.moderator != nil {
+		streamID = ""
+	}
+
+	results := make(chan attemptResult, 2)
+	start := func(attempt int) {
+		go func() {
+			attemptCtx := ctx
+			if agent.Timeout != nil && agent.Timeout.Duration > 0 {
+				var attemptCancel context.CancelFunc
+				attemptCtx, attemptCancel = context.WithTimeout(ctx, agent.Timeout.Duration)
+				defer attemptCancel()
+			}
+
+			requestStart := time.Now()
+			messages, usage, err := pr.Generate(provider.GenerateContext{
+				StepID:   step.ID,
+				RunID:    execCtx.RunID,
+				ActionID: streamID,
+				Context:  attemptCtx,
+			}, request, e.progressChan)
+			if err != nil && attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				err = fmt.Errorf("model request timed out after %s: %w", agent.Timeout.Duration, err)
+			}
+
+			// requests cancelled by the run or by a hedged request winning
+			// say nothing about the provider
+			outcome := err
+			if ctx.Err() != nil {
+				outcome = context.Canceled
+			}
+			e.recordProviderOutcome(pr.GetName(), outcome)
+			if ctx.Err() == nil {
+				e.metrics().observeProviderRequest(pr.GetName(), request.Model, time.Since(requestStart), usage, err)
+			}
+
+			results <- attemptResult{attempt: attempt, messages: messages, usage: usage, err: err}
+		}()
+	}
+
+	start(1)
+	running := 1
+
+	var hedge <-chan time.Time
+	if hedged {
+		timer := time.NewTimer(agent.HedgeAfter.Duration)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+
+	usage := &execcontext.TokenUsage{}
+	var winner *attemptResult
+	var lastErr error
+
+	for running > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			log.Debug().
+				Str("step_id", step.ID).
+				Dur("hedge_after", agent.HedgeAfter.Duration).
+				Msg("Model request is slow, starting hedged request")
+
+			start(2)
+			running++
+		case result := <-results:
+			running--
+			usage.Add(result.usage)
+
+			if result.err != nil {
+				lastErr = result.err
+				continue
+			}
+
+			if winner == nil {
+				winner = &result
+				hedge = nil
+
+				// cancel the slower request, still waiting for it so that
+				// any usage it reports is recorded.
+				cancel()
+			}
+		}
+	}
+
+	if winner == nil {
+		return nil, usage, lastErr
+	}
+
+	if winner.attempt > 1 {
+		log.Debug().
+			Str("step_id", step.ID).
+			Msg("Hedged model request responded first")
+	}
+
+	if err := e.moderate(execCtx, step, actionID, ModerationOutput, winner.messages); err != nil {
+		return nil, usage, err
+	}
+
+	return winner.messages, usage, nil
+}
@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// delayedProvider responds to each request after the delay configured for
+// that attempt, reporting the attempt number as the response.
+type delayedProvider struct {
+	delays   []time.Duration
+	attempts atomic.Int32
+}
+
+func (p *delayedProvider) Generate(gtx provider.GenerateContext, _ *provider.Request, _ chan<- pkgEvents.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
+	attempt := int(p.attempts.Add(1))
+	usage := &execcontext.TokenUsage{PromptTokens: 10, TotalTokens: 10}
+
+	select {
+	case <-gtx.Context.Done():
+		return nil, usage, gtx.Context.Err()
+	case <-time.After(p.delays[attempt-1]):
+	}
+
+	return []provider.Message{{
+		Role:    "assistant",
+		Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(string(rune('0' + attempt)))},
+	}}, &execcontext.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+}
+
+func (p *delayedProvider) GetName() string { return "delayed" }
+
+func (p *delayedProvider) ListModels(context.Context) ([]provider.Info, error) { return nil, nil }
+
+func (p *delayedProvider) Close() error { return nil }
+
+func TestExecutor_Generate(t *testing.T) {
+	duration := func(d time.Duration) *ast.Duration {
+		return &ast.Duration{Duration: d}
+	}
+
+	step := &ast.Step{ID: "agent_step"}
+	execCtx := createTestExecutionContext(createTestWorkflow([]*ast.Step{step}))
+	e := &Executor{}
+
+	t.Run("request timeout", func(t *testing.T) {
+		pr := &delayedProvider{delays: []time.Duration{time.Second}}
+		agent := &ast.Agent{Timeout: duration(20 * time.Millisecond)}
+
+		_, usage, err := e.generate(execCtx, pr, agent, step, "", &provider.Request{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out after 20ms")
+		assert.Equal(t, 10, usage.TotalTokens)
+	})
+
+	t.Run("hedged request responds first", func(t *testing.T) {
+		pr := &delayedProvider{delays: []time.Duration{time.Second, 10 * time.Millisecond}}
+		agent := &ast.Agent{HedgeAfter: duration(20 * time.Millisecond)}
+
+		start := time.Now()
+		messages, usage, err := e.generate(execCtx, pr, agent, step, "", &provider.Request{})
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, "2", getLastContentBlock(messages))
+		assert.Equal(t, int32(2), pr.attempts.Load())
+
+		// usage of both the winning and the cancelled request is recorded
+		assert.Equal(t, 25, usage.TotalTokens)
+	})
+
+	t.Run("no hedge when the first request is fast", func(t *testing.T) {
+		pr := &delayedProvider{delays: []time.Duration{time.Millisecond, time.Millisecond}}
+		agent := &ast.Agent{HedgeAfter: duration(time.Second)}
+
+		messages, usage, err := e.generate(execCtx, pr, agent, step, "", &provider.Request{})
+		require.NoError(t, err)
+		assert.Equal(t, "1", getLastContentBlock(messages))
+		assert.Equal(t, int32(1), pr.attempts.Load())
+		assert.Equal(t, 15, usage.TotalTokens)
+	})
+
+	t.Run("run cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelledCtx := execcontext.NewExecutionContext(execcontext.RunContext{Context: ctx}, execCtx.Workflow, map[string]interface{}{}, "/tmp")
+		pr := &delayedProvider{delays: []time.Duration{time.Second}}
+
+		time.AfterFunc(10*time.Millisecond, cancel)
+		_, _, err := e.generate(cancelledCtx, pr, &ast.Agent{}, step, "", &provider.Request{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// recordingObserver records the outcome of each observed provider request.
+type recordingObserver struct {
+	outcomes chan error
+}
+
+func (o *recordingObserver) ObserveProviderRequest(provider string, err error) {
+	o.outcomes <- err
+}
+
+func TestExecutor_Generate_ProviderObserver(t *testing.T) {
+	step := &ast.Step{ID: "agent_step"}
+	execCtx := createTestExecutionContext(createTestWorkflow([]*ast.Step{step}))
+	observer := &recordingObserver{outcomes: make(chan error, 2)}
+	e := &Executor{config: &ExecutorConfig{ProviderObserver: observer}}
+
+	pr := &delayedProvider{delays: []time.Duration{time.Second}}
+	_, _, err := e.generate(execCtx, pr, &ast.Agent{Timeout: &ast.Duration{Duration: 20 * time.Millisecond}}, step, "", &provider.Request{})
+	require.Error(t, err)
+	assert.ErrorContains(t, <-observer.outcomes, "timed out")
+
+	// the losing hedged request is cancelled and isn't observed
+	pr = &delayedProvider{delays: []time.Duration{time.Second, time.Millisecond}}
+	_, _, err = e.generate(execCtx, pr, &ast.Agent{HedgeAfter: &ast.Duration{Duration: 10 * time.Millisecond}}, step, "", &provider.Request{})
+	require.NoError(t, err)
+	assert.NoError(t, <-observer.outcomes)
+	assert.Empty(t, observer.outcomes)
+}
+
+func TestExecutor_Generate_CircuitBreaker(t *testing.T) {
+	step := &ast.Step{ID: "agent_step"}
+	execCtx := createTestExecutionContext(createTestWorkflow([]*ast.Step{step}))
+	breakers := provider.NewBreakers("")
+	breakers.Configure(provider.BreakerConfig{Failures: 2, Cooldown: time.Minute})
+	e := &Executor{config: &ExecutorConfig{Breakers: breakers}}
+	agent := &ast.Agent{Timeout: &ast.Duration{Duration: 10 * time.Millisecond}}
+
+	pr := &delayedProvider{delays: []time.Duration{time.Second, time.Second}}
+	for range 2 {
+		_, _, err := e.generate(execCtx, pr, agent, step, "", &provider.Request{})
+		require.Error(t, err)
+	}
+
+	// requests fail fast once the breaker is open
+	_, _, err := e.generate(execCtx, pr, agent, step, "", &provider.Request{})
+	var open *provider.CircuitOpenError
+	require.ErrorAs(t, err, &open)
+	assert.Equal(t, "delayed", open.Provider)
+	assert.Equal(t, int32(2), pr.attempts.Load())
+}
+
+func TestExecutor_AvailableAgent(t *testing.T) {
+	step := &ast.Step{ID: "agent_step"}
+	workflow := createTestWorkflow([]*ast.Step{step})
+	workflow.Agents = map[string]*ast.Agent{
+		"primary":   {Name: "primary", Provider: "anthropic", Model: "claude-sonnet-4", Fallback: "secondary"},
+		"secondary": {Name: "secondary", Provider: "openai", Model: "gpt-4o", Fallback: "local"},
+		"local":     {Name: "local", Provider: "local", Model: "llama3"},
+	}
+	execCtx := createTestExecutionContext(workflow)
+
+	breakers := provider.NewBreakers("")
+	breakers.Configure(provider.BreakerConfig{Failures: 1, Cooldown: time.Minute})
+	e := &Executor{config: &ExecutorConfig{Breakers: breakers}}
+
+	assert.Equal(t, "primary", e.availableAgent(execCtx, step, workflow.Agents["primary"]).Name)
+
+	breakers.Record("anthropic", assert.AnError)
+	assert.Equal(t, "secondary", e.availableAgent(execCtx, step, workflow.Agents["primary"]).Name)
+
+	// fallbacks are followed while their providers are unavailable too
+	breakers.Record("openai", assert.AnError)
+	assert.Equal(t, "local", e.availableAgent(execCtx, step, workflow.Agents["primary"]).Name)
+
+	// the last agent is used even when its provider is unavailable
+	breakers.Record("local", assert.AnError)
+	assert.Equal(t, "local", e.availableAgent(execCtx, step, workflow.Agents["primary"]).Name)
+}
+
+func TestExecutor_FallbackAgent(t *testing.T) {
+	step := &ast.Step{ID: "agent_step"}
+	workflow := createTestWorkflow([]*ast.Step{step})
+	workflow.Agents = map[string]*ast.Agent{
+		"primary":   {Name: "primary", Provider: "anthropic", Model: "claude-sonnet-4", Fallback: "secondary"},
+		"secondary": {Name: "secondary", Provider: "openai", Model: "gpt-4o"},
+	}
+	execCtx := createTestExecutionContext(workflow)
+	e := &Executor{}
+
+	apiError := func(statusCode int, message string) error {
+		return fmt.Errorf("model generation failed: %w", provider.NewAPIError("anthropic", statusCode, "", message, nil, nil))
+	}
+
+	fallback, ok := e.fallbackAgent(execCtx, step, workflow.Agents["primary"], apiError(http.StatusTooManyRequests, "slow down"))
+	require.True(t, ok)
+	assert.Equal(t, "secondary", fallback.Name)
+
+	_, ok = e.fallbackAgent(execCtx, step, workflow.Agents["primary"], apiError(http.StatusBadRequest, "prompt is too long"))
+	assert.True(t, ok)
+
+	// failures which another model won't fix abort the step
+	_, ok = e.fallbackAgent(execCtx, step, workflow.Agents["primary"], apiError(http.StatusUnauthorized, "invalid x-api-key"))
+	assert.False(t, ok)
+	_, ok = e.fallbackAgent(execCtx, step, workflow.Agents["primary"], assert.AnError)
+	assert.False(t, ok)
+	_, ok = e.fallbackAgent(execCtx, step, workflow.Agents["secondary"], apiError(http.StatusTooManyRequests, "slow down"))
+	assert.False(t, ok)
+}
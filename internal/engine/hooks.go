@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	HookPreRun   = "pre_run"
+	HookPostStep = "post_step"
+	HookPostRun  = "post_run"
+
+	// DefaultHookTimeout limits how long a hook command may run.
+	DefaultHookTimeout = 30 * time.Second
+)
+
+// Hooks are commands run at points in the lifecycle of a run, configured
+// under hooks in the Lacquer config. Each command is run by the shell and
+// receives a HookEvent as JSON on stdin. A failing pre_run hook stops the
+// run, failing post_step and post_run hooks are only logged.
+type Hooks struct {
+	PreRun   []string      `mapstructure:"pre_run"`
+	PostStep []string      `mapstructure:"post_step"`
+	PostRun  []string      `mapstructure:"post_run"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// IsEmpty reports whether no hooks are configured.
+func (h Hooks) IsEmpty() bool {
+	return len(h.PreRun) == 0 && len(h.PostStep) == 0 && len(h.PostRun) == 0
+}
+
+// HookEvent is the JSON a hook command receives on stdin.
+type HookEvent struct {
+	// Hook is the point in the lifecycle the hook is run at.
+	Hook         string `json:"hook"`
+	RunID        string `json:"run_id"`
+	Workflow     string `json:"workflow,omitempty"`
+	WorkflowFile string `json:"workflow_file,omitempty"`
+	// Inputs are the inputs of the run, for pre_run hooks.
+	Inputs map[string]interface{} `json:"inputs,omitempty"`
+	// Event is the step_completed, step_failed or step_skipped event, for
+	// post_step hooks.
+	Event *pkgEvents.Envelope `json:"event,omitempty"`
+	// Result is the result of the run, for post_run hooks.
+	Result *ExecutionResult `json:"result,omitempty"`
+}
+
+// hookRunner runs the hooks of a run. post_step hooks run in order in the
+// background so they don't hold up the run.
+type hookRunner struct {
+	hooks    Hooks
+	base     HookEvent
+	topLevel map[string]bool
+	queue    chan pkgEvents.ExecutionEvent
+	done     chan struct{}
+}
+
+func newHookRunner(hooks Hooks, execCtx *execcontext.ExecutionContext, workflow *ast.Workflow) *hookRunner {
+	if hooks.Timeout <= 0 {
+		hooks.Timeout = DefaultHookTimeout
+	}
+
+	h := &hookRunner{
+		hooks: hooks,
+		base: HookEvent{
+			RunID:        execCtx.RunID,
+			WorkflowFile: workflow.SourceFile,
+		},
+		topLevel: make(map[string]bool),
+	}
+	if workflow.Metadata != nil {
+		h.base.Workflow = workflow.Metadata.Name
+	}
+	for _, step := range workflow.GetSteps() {
+		h.topLevel[step.ID] = true
+	}
+
+	return h
+}
+
+// preRun runs the pre_run hooks, stopping at the first which fails.
+func (h *hookRunner) preRun(ctx context.Context, inputs map[string]interface{}) error {
+	event := h.base
+	event.Hook = HookPreRun
+	event.Inputs = inputs
+
+	for _, command := range h.hooks.PreRun {
+		if err := h.run(ctx, command, &event); err != nil {
+			return fmt.Errorf("pre_run hook %q failed: %w", command, err)
+		}
+	}
+
+	return nil
+}
+
+// start begins running post_step hooks for the events passed to step.
+func (h *hookRunner) start(ctx context.Context) {
+	h.queue = make(chan pkgEvents.ExecutionEvent, 100)
+	h.done = make(chan struct{})
+
+	go func() {
+		defer close(h.done)
+		for e := range h.queue {
+			envelope := e.Envelope()
+			event := h.base
+			event.Hook = HookPostStep
+			event.Event = &envelope
+
+			for _, command := range h.hooks.PostStep {
+				if err := h.run(ctx, command, &event); err != nil {
+					log.Warn().Err(err).Str("hook", command).Str("step_id", e.StepID).Msg("post_step hook failed")
+				}
+			}
+		}
+	}()
+}
+
+// step queues the post_step hooks when the event ends a top-level step.
+func (h *hookRunner) step(event pkgEvents.ExecutionEvent) {
+	if len(h.hooks.PostStep) == 0 || !h.topLevel[event.StepID] {
+		return
+	}
+
+	switch event.Type {
+	case pkgEvents.EventStepCompleted, pkgEvents.EventStepFailed, pkgEvents.EventStepSkipped:
+		h.queue <- event
+	}
+}
+
+// wait waits for the queued post_step hooks to finish.
+func (h *hookRunner) wait() {
+	close(h.queue)
+	<-h.done
+}
+
+// postRun runs the post_run hooks with the result of the run.
+func (h *hookRunner) postRun(ctx context.Context, result *ExecutionResult) {
+	event := h.base
+	event.Hook = HookPostRun
+	event.Result = result
+
+	for _, command := range h.hooks.PostRun {
+		if err := h.run(ctx, command, &event); err != nil {
+			log.Warn().Err(err).Str("hook", command).Msg("post_run hook failed")
+		}
+	}
+}
+
+// run runs the hook command with the event as JSON on stdin.
+func (h *hookRunner) run(ctx context.Context, command string, event *HookEvent) error {
+	input, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode hook event: %w", err)
+	}
+
+	// hooks still run when the run was cancelled so they can report it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.hooks.Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command) // #nosec G204 - hooks are configured by the user
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command) // #nosec G204 - hooks are configured by the user
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(),
+		"LACQUER_HOOK="+event.Hook,
+		"LACQUER_RUN_ID="+event.RunID,
+	)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// don't wait on processes started by the hook which outlive it
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", h.hooks.Timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(output.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+
+	log.Debug().
+		Str("hook", event.Hook).
+		Str("command", command).
+		Str("output", output.String()).
+		Msg("Hook completed")
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readHookEvents reads the events written by hooks appending stdin to path.
+func readHookEvents(t *testing.T, path string) []HookEvent {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var events []HookEvent
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event HookEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestRunWorkflow_Hooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in this test use sh")
+	}
+
+	out := filepath.Join(t.TempDir(), "events.jsonl")
+	record := `cat >> ` + out + ` && echo >> ` + out
+	viper.Set("hooks", map[string]interface{}{
+		"pre_run":   []string{record},
+		"post_step": []string{record},
+		"post_run":  []string{record, `test "$LACQUER_HOOK" = post_run`},
+	})
+	t.Cleanup(func() { viper.Set("hooks", nil) })
+
+	runner := NewRunner(nil, WithExecutorFunc(mockExecutorFunc([]pkgEvents.ExecutionEvent{
+		{Type: pkgEvents.EventStepStarted, StepID: "greet", StepIndex: 1},
+		{Type: pkgEvents.EventStepCompleted, StepID: "greet", Duration: 10 * time.Millisecond},
+		// nested steps don't run post_step hooks
+		{Type: pkgEvents.EventStepCompleted, StepID: "greet.inner"},
+	})))
+
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: os.Stdout, StdErr: os.Stderr}
+	result, err := runner.RunWorkflow(ctx, filepath.Join("testdata", "basic_workflow.laq.yml"), map[string]interface{}{"name": "World"})
+	require.NoError(t, err)
+
+	events := readHookEvents(t, out)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, HookPreRun, events[0].Hook)
+	assert.Equal(t, result.RunID, events[0].RunID)
+	assert.Equal(t, "World", events[0].Inputs["name"])
+	assert.Nil(t, events[0].Result)
+
+	assert.Equal(t, HookPostStep, events[1].Hook)
+	require.NotNil(t, events[1].Event)
+	assert.Equal(t, pkgEvents.KindStepCompleted, events[1].Event.Kind)
+	assert.Equal(t, "greet", events[1].Event.StepID)
+
+	assert.Equal(t, HookPostRun, events[2].Hook)
+	require.NotNil(t, events[2].Result)
+	assert.Equal(t, "completed", events[2].Result.Status)
+}
+
+func TestRunWorkflow_PreRunHookFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in this test use sh")
+	}
+
+	viper.Set("hooks", map[string]interface{}{
+		"pre_run": []string{`echo "ticket is closed" >&2; exit 3`},
+	})
+	t.Cleanup(func() { viper.Set("hooks", nil) })
+
+	runner := NewRunner(nil, WithExecutorFunc(mockExecutorFunc(nil)))
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: os.Stdout, StdErr: os.Stderr}
+	_, err := runner.RunWorkflow(ctx, filepath.Join("testdata", "basic_workflow.laq.yml"), map[string]interface{}{"name": "World"})
+	assert.EqualError(t, err, `pre_run hook "echo \"ticket is closed\" >&2; exit 3" failed: exit status 3: ticket is closed`)
+}
+
+func TestHookRunner_Timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in this test use sh")
+	}
+
+	workflow := createTestWorkflow(nil)
+	h := newHookRunner(Hooks{Timeout: 50 * time.Millisecond}, createTestExecutionContext(workflow), workflow)
+
+	err := h.run(context.Background(), "sleep 5", &HookEvent{Hook: HookPostRun})
+	assert.EqualError(t, err, "timed out after 50ms")
+}
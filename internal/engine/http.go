@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/rs/zerolog/log"
+)
+
+// maxHTTPResponseSize limits how much of the body of a response an http step
+// reads
+const maxHTTPResponseSize = 10 * 1024 * 1024
+
+// maxHTTPErrorBodySize limits how much of the body of a failed response is
+// included in the step's error
+const maxHTTPErrorBodySize = 512
+
+// httpStepClient sends the requests of http steps, which are cancelled with
+// the run or by the step's timeout
+var httpStepClient = &http.Client{}
+
+// executeHTTPStep sends the request of an http step, outputting the status,
+// headers and body of the response. JSON bodies are parsed so that their
+// fields can be used by later steps, other bodies are output as text.
+func (e *Executor) executeHTTPStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	req, err := e.newHTTPStepRequest(execCtx, step.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("method", req.Method).
+		Str("host", req.URL.Host).
+		Msg("Executing http step")
+
+	resp, err := httpStepClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(data) > maxHTTPResponseSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxHTTPResponseSize)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body := string(data)
+		if len(body) > maxHTTPErrorBodySize {
+			body = body[:maxHTTPErrorBodySize] + "..."
+		}
+		return nil, fmt.Errorf("http request failed with status %s: %s", resp.Status, strings.TrimSpace(body))
+	}
+
+	headers := make(map[string]interface{}, len(resp.Header))
+	for name, values := range resp.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+
+	var body interface{} = string(data)
+	if isJSONResponse(resp) && len(data) > 0 {
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+		}
+	}
+
+	return NewStepResult(map[string]interface{}{
+		"status":  resp.StatusCode,
+		"headers": headers,
+		"body":    body,
+	}, string(data)), nil
+}
+
+// newHTTPStepRequest renders the url, headers, body and auth of an http step
+// into a request.
+func (e *Executor) newHTTPStepRequest(execCtx *execcontext.ExecutionContext, spec *ast.HTTPStep) (*http.Request, error) {
+	url, err := e.renderString(spec.URL, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render url: %w", err)
+	}
+
+	method := http.MethodGet
+	if spec.Method != "" {
+		method = strings.ToUpper(spec.Method)
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch value := spec.Body.(type) {
+	case nil:
+	case string:
+		rendered, err := e.renderString(value, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render body: %w", err)
+		}
+		body = strings.NewReader(rendered)
+	default:
+		rendered, err := e.renderValueRecursively(value, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render body: %w", err)
+		}
+		data, err := json.Marshal(rendered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode body: %w", err)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(execCtx.Context.Context, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("url must be an http or https url, got %q", url)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range spec.Headers {
+		rendered, err := e.renderString(value, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render header %s: %w", name, err)
+		}
+		req.Header.Set(name, rendered)
+	}
+
+	if spec.Auth != nil {
+		if spec.Auth.Token != "" {
+			token, err := e.renderString(spec.Auth.Token, execCtx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render auth token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			username, err := e.renderString(spec.Auth.Username, execCtx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render auth username: %w", err)
+			}
+			password, err := e.renderString(spec.Auth.Password, execCtx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render auth password: %w", err)
+			}
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	return req, nil
+}
+
+// renderString renders a template to a string.
+func (e *Executor) renderString(template string, execCtx *execcontext.ExecutionContext) (string, error) {
+	rendered, err := e.templateEngine.Render(template, execCtx)
+	if err != nil {
+		return "", err
+	}
+	return expression.ValueToString(rendered), nil
+}
+
+// isJSONResponse reports whether the body of the response is JSON, e.g.
+// application/json or application/problem+json.
+func isJSONResponse(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
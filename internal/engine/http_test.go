@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ExecuteHTTPStep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/issues/42/comments", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "triage", r.Header.Get("X-Source"))
+
+		username, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "bot", username)
+		assert.Equal(t, "secret", password)
+
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]interface{}{"text": "Issue 42 triaged", "labels": []interface{}{"bug"}}, body)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("X-Request-Id", "req_1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 7, "url": "https://example.com/comments/7"}`))
+	}))
+	defer server.Close()
+
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{ID: "comment", HTTP: &ast.HTTPStep{
+			Method:  "post",
+			URL:     server.URL + "/issues/${{ inputs.id }}/comments",
+			Headers: map[string]string{"X-Source": "triage"},
+			Body: map[string]interface{}{
+				"text":   "Issue ${{ inputs.id }} triaged",
+				"labels": []interface{}{"bug"},
+			},
+			Auth: &ast.HTTPAuth{Username: "bot", Password: "${{ inputs.password }}"},
+		}},
+		{ID: "report", Run: "echo -n 'comment ${{ steps.comment.outputs.body.id }}'"},
+	}), map[string]interface{}{"id": "42", "password": "secret"})
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("comment")
+	require.True(t, ok)
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, http.StatusCreated, outputs["status"])
+	assert.Equal(t, "req_1", outputs["headers"].(map[string]interface{})["x-request-id"])
+	assert.Equal(t, map[string]interface{}{"id": float64(7), "url": "https://example.com/comments/7"}, outputs["body"])
+
+	report, _ := execCtx.GetStepResult("report")
+	assert.Equal(t, "comment 7", report.Response)
+}
+
+func TestExecutor_ExecuteHTTPStep_Text(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "Bearer token_1", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{ID: "ping", HTTP: &ast.HTTPStep{URL: server.URL, Auth: &ast.HTTPAuth{Token: "token_1"}}},
+	}), nil)
+	require.NoError(t, err)
+
+	result, _ := execCtx.GetStepResult("ping")
+	assert.Equal(t, "pong", result.Response)
+	assert.Equal(t, "pong", result.Output["outputs"].(map[string]interface{})["body"])
+}
+
+func TestExecutor_ExecuteHTTPStep_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "issue not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, _, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{ID: "fetch", HTTP: &ast.HTTPStep{URL: server.URL}},
+	}), nil)
+	assert.ErrorContains(t, err, "http request failed with status 404 Not Found: issue not found")
+}
+
+func TestExecuteWorkflow_StepCacheNestedHTTPStep(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "notify", Parallel: &ast.ParallelStep{Steps: []*ast.Step{
+			{ID: "post", HTTP: &ast.HTTPStep{URL: server.URL}},
+		}}},
+	})
+
+	config := DefaultExecutorConfig()
+	config.StepCache = NewStepCache()
+
+	// a step wrapping an http step is re-executed rather than reused from
+	// the step cache, so the request is sent by every run
+	for range 2 {
+		_, _, err := runTestWorkflow(t, workflow, nil, withExecutorConfig(config))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 0, config.StepCache.Len())
+}
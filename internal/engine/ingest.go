@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/ingest"
+	"github.com/rs/zerolog/log"
+)
+
+// executeIngestStep extracts the text of one or more documents and splits it
+// into chunks.
+func (e *Executor) executeIngestStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Ingest
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("from", spec.From).
+		Msg("Executing ingest step")
+
+	value, err := e.templateEngine.Render(spec.From, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render from: %w", err)
+	}
+
+	paths, err := ingestPaths(value)
+	if err != nil {
+		return nil, err
+	}
+
+	overlap := ingest.DefaultChunkOverlap
+	if spec.ChunkOverlap != nil {
+		overlap = *spec.ChunkOverlap
+	}
+
+	var texts []string
+	chunks := make([]interface{}, 0)
+	documents := make([]interface{}, 0, len(paths))
+	for _, path := range paths {
+		doc, err := readDocument(execCtx.Cwd, path, spec.Format)
+		if err != nil {
+			return nil, err
+		}
+
+		docChunks := doc.Chunks(spec.ChunkSize, overlap)
+		for _, chunk := range docChunks {
+			item := map[string]interface{}{
+				"index":  len(chunks),
+				"source": chunk.Source,
+				"text":   chunk.Text,
+			}
+			if chunk.Page > 0 {
+				item["page"] = chunk.Page
+			}
+			if chunk.Section != "" {
+				item["section"] = chunk.Section
+			}
+			chunks = append(chunks, item)
+		}
+
+		documents = append(documents, map[string]interface{}{
+			"source": doc.Source,
+			"format": doc.Format,
+			"title":  doc.Title,
+			"pages":  doc.Pages,
+			"chunks": len(docChunks),
+		})
+
+		if text := doc.Text(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	return NewStepResult(map[string]interface{}{
+		"text":      strings.Join(texts, "\n\n"),
+		"chunks":    chunks,
+		"documents": documents,
+	}), nil
+}
+
+// ingestPaths converts the rendered from value into a list of paths.
+func ingestPaths(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("no document to ingest, from is empty")
+		}
+		return []string{strings.TrimSpace(v)}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(v))
+		for _, item := range v {
+			path := strings.TrimSpace(expression.ValueToString(item))
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no document to ingest, from is an empty list")
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("from must be a path or a list of paths, got %T", value)
+	}
+}
+
+func readDocument(cwd, path, format string) (*ingest.Document, error) {
+	if format == "" {
+		format = ingest.FormatFromPath(path)
+	}
+	if format == "" {
+		return nil, fmt.Errorf("cannot determine the format of %s, set format to one of: %s", path, strings.Join(ingest.Formats, ", "))
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(cwd, target)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	doc, err := ingest.Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract text from %s: %w", path, err)
+	}
+	doc.Source = path
+
+	return doc, nil
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/issues"
+	"github.com/rs/zerolog/log"
+)
+
+// issueKeyPrefix keeps the keys of issue steps apart from the keys of kv and
+// dedupe steps in the workflow's key-value store
+const issueKeyPrefix = "issue:"
+
+// executeIssueStep creates or updates an issue in Jira or Linear. An issue
+// created with a key is remembered, later runs with the same key output it
+// rather than creating another.
+func (e *Executor) executeIssueStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Issue
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("tracker", spec.Tracker).
+		Str("action", spec.Action).
+		Msg("Executing issue step")
+
+	render := func(name, template string) (string, error) {
+		value, err := e.renderString(template, execCtx)
+		if err != nil {
+			return "", fmt.Errorf("failed to render %s: %w", name, err)
+		}
+		return value, nil
+	}
+
+	config := issues.Config{Client: httpStepClient}
+	var fields issues.Fields
+	var project, id, key string
+	for _, field := range []struct {
+		name     string
+		template string
+		value    *string
+	}{
+		{"url", spec.URL, &config.URL},
+		{"token", spec.Token, &config.Token},
+		{"email", spec.Email, &config.Email},
+		{"project", spec.Project, &project},
+		{"id", spec.ID, &id},
+		{"key", spec.Key, &key},
+		{"title", spec.Title, &fields.Title},
+		{"description", spec.Description, &fields.Description},
+		{"type", spec.Type, &fields.Type},
+		{"priority", spec.Priority, &fields.Priority},
+		{"assignee", spec.Assignee, &fields.Assignee},
+	} {
+		value, err := render(field.name, field.template)
+		if err != nil {
+			return nil, err
+		}
+		*field.value = value
+	}
+
+	for i, label := range spec.Labels {
+		value, err := render(fmt.Sprintf("labels[%d]", i), label)
+		if err != nil {
+			return nil, err
+		}
+		if value != "" {
+			fields.Labels = append(fields.Labels, value)
+		}
+	}
+
+	if len(spec.Fields) > 0 {
+		rendered, err := e.renderValueRecursively(spec.Fields, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render fields: %w", err)
+		}
+		fields.Extra, _ = rendered.(map[string]interface{})
+	}
+
+	tracker, err := issues.New(spec.Tracker, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Action == ast.IssueActionUpdate {
+		issue, err := tracker.Update(execCtx.Context.Context, id, fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s issue %s: %w", spec.Tracker, id, err)
+		}
+		return issueStepResult(spec.Tracker, issue, false), nil
+	}
+
+	storeKey := issueKeyPrefix + spec.Tracker + ":" + key
+	if key != "" {
+		if execCtx.KV == nil {
+			return nil, fmt.Errorf("issue step with a key requires a key-value store")
+		}
+
+		value, ok, err := execCtx.KV.Get(storeKey)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			var issue issues.Issue
+			if data, err := json.Marshal(value); err == nil && json.Unmarshal(data, &issue) == nil && issue.ID != "" {
+				log.Debug().
+					Str("step_id", step.ID).
+					Str("key", key).
+					Str("issue", issue.Key).
+					Msg("Issue was already created for the key")
+
+				return issueStepResult(spec.Tracker, &issue, false), nil
+			}
+		}
+	}
+
+	issue, err := tracker.Create(execCtx.Context.Context, project, fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s issue: %w", spec.Tracker, err)
+	}
+
+	if key != "" {
+		if _, _, err := execCtx.KV.Set(storeKey, map[string]interface{}{
+			"id":  issue.ID,
+			"key": issue.Key,
+			"url": issue.URL,
+		}); err != nil {
+			return nil, fmt.Errorf("created %s issue %s but failed to remember it for key %s: %w", spec.Tracker, issue.Key, key, err)
+		}
+	}
+
+	return issueStepResult(spec.Tracker, issue, true), nil
+}
+
+func issueStepResult(tracker string, issue *issues.Issue, created bool) *StepResult {
+	return NewStepResult(map[string]interface{}{
+		"tracker": tracker,
+		"id":      issue.ID,
+		"key":     issue.Key,
+		"url":     issue.URL,
+		"created": created,
+	}, issue.URL)
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ExecuteIssueStep(t *testing.T) {
+	var created []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		created = append(created, body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "10001", "key": "OPS-12"}`))
+	}))
+	defer server.Close()
+
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+	steps := []*ast.Step{
+		{ID: "ticket", Issue: &ast.IssueStep{
+			Tracker: "jira",
+			URL:     server.URL,
+			Token:   "${{ inputs.token }}",
+			Project: "OPS",
+			Title:   "Alert ${{ inputs.alert }}",
+			Labels:  []string{"triage", "${{ inputs.service }}"},
+			Key:     "${{ inputs.alert }}",
+		}},
+	}
+	inputs := map[string]interface{}{"token": "secret", "alert": "a-1", "service": "checkout"}
+
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow(steps), inputs, withKV(bucket))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("ticket")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		"tracker": "jira",
+		"id":      "10001",
+		"key":     "OPS-12",
+		"url":     server.URL + "/browse/OPS-12",
+		"created": true,
+	}, result.Output["outputs"])
+
+	require.Len(t, created, 1)
+	fields := created[0]["fields"].(map[string]interface{})
+	assert.Equal(t, "Alert a-1", fields["summary"])
+	assert.Equal(t, []interface{}{"triage", "checkout"}, fields["labels"])
+
+	// a later run with the same key outputs the issue without creating another
+	execCtx, _, err = runTestWorkflow(t, createTestWorkflow(steps), inputs, withKV(bucket))
+	require.NoError(t, err)
+
+	result, _ = execCtx.GetStepResult("ticket")
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "OPS-12", outputs["key"])
+	assert.Equal(t, false, outputs["created"])
+	assert.Len(t, created, 1)
+
+	// another key creates another issue
+	inputs["alert"] = "a-2"
+	_, _, err = runTestWorkflow(t, createTestWorkflow(steps), inputs, withKV(bucket))
+	require.NoError(t, err)
+	assert.Len(t, created, 2)
+
+	// keys need a key-value store
+	_, _, err = runTestWorkflow(t, createTestWorkflow(steps), inputs)
+	assert.ErrorContains(t, err, "issue step with a key requires a key-value store")
+}
@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/rs/zerolog/log"
+)
+
+// executeKVStep gets, sets or deletes a value the workflow keeps between
+// runs. The value output by get is the default when the key isn't set, set
+// and delete output found when the key was set before the step, so a set
+// step can tell whether an item has been seen by an earlier run.
+func (e *Executor) executeKVStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.KV
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("action", spec.Action).
+		Msg("Executing kv step")
+
+	if execCtx.KV == nil {
+		return nil, fmt.Errorf("kv step requires a key-value store")
+	}
+
+	rendered, err := e.templateEngine.Render(spec.Key, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render key: %w", err)
+	}
+	key := expression.ValueToString(rendered)
+
+	var value interface{}
+	var found bool
+	switch spec.Action {
+	case "", ast.KVActionGet:
+		value, found, err = execCtx.KV.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if !found && spec.Default != nil {
+			value, err = e.renderValueRecursively(spec.Default, execCtx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render default: %w", err)
+			}
+		}
+	case ast.KVActionSet:
+		value, err = e.renderValueRecursively(spec.Value, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render value: %w", err)
+		}
+		if _, found, err = execCtx.KV.Set(key, value); err != nil {
+			return nil, err
+		}
+	case ast.KVActionDelete:
+		if value, found, err = execCtx.KV.Delete(key); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown kv action %q", spec.Action)
+	}
+
+	return NewStepResult(map[string]interface{}{
+		"key":   key,
+		"value": value,
+		"found": found,
+	}, expression.ValueToString(value)), nil
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/kv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ExecuteKVStep_Dedupe(t *testing.T) {
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+	steps := []*ast.Step{
+		{ID: "seen", KV: &ast.KVStep{Action: ast.KVActionSet, Key: "seen_${{ inputs.id }}", Value: true}},
+		{ID: "process", Condition: "${{ !steps.seen.outputs.found }}", Run: "echo -n 'processing'"},
+	}
+
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"id": "42"}, withKV(bucket))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("seen")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"key": "seen_42", "value": true, "found": false}, result.Output["outputs"])
+
+	process, _ := execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusCompleted, process.Status)
+
+	// a later run with the same item skips it
+	execCtx, _, err = runTestWorkflow(t, createTestWorkflow(steps), map[string]interface{}{"id": "42"}, withKV(bucket))
+	require.NoError(t, err)
+
+	process, _ = execCtx.GetStepResult("process")
+	assert.Equal(t, execcontext.StepStatusSkipped, process.Status)
+}
+
+func TestExecutor_ExecuteKVStep_GetDelete(t *testing.T) {
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{ID: "load", KV: &ast.KVStep{Key: "cursor", Default: 0}},
+		{ID: "fetch", Run: "echo -n 'page ${{ kv_set(\"cursor\", steps.load.outputs.value + 1) }}'"},
+		{ID: "reload", KV: &ast.KVStep{Action: ast.KVActionGet, Key: "cursor"}},
+		{ID: "reset", KV: &ast.KVStep{Action: ast.KVActionDelete, Key: "cursor"}},
+	}), nil, withKV(bucket))
+	require.NoError(t, err)
+
+	load, _ := execCtx.GetStepResult("load")
+	assert.Equal(t, map[string]interface{}{"key": "cursor", "value": 0, "found": false}, load.Output["outputs"])
+
+	fetch, _ := execCtx.GetStepResult("fetch")
+	assert.Equal(t, "page 1", fetch.Response)
+
+	reload, _ := execCtx.GetStepResult("reload")
+	assert.Equal(t, "1", reload.Response)
+	assert.Equal(t, true, reload.Output["outputs"].(map[string]interface{})["found"])
+
+	reset, _ := execCtx.GetStepResult("reset")
+	assert.Equal(t, true, reset.Output["outputs"].(map[string]interface{})["found"])
+
+	_, found, err := bucket.Get("cursor")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestExecutor_ExecuteKVStep_NoStore(t *testing.T) {
+	_, _, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{ID: "load", KV: &ast.KVStep{Key: "cursor"}},
+	}), nil)
+	assert.ErrorContains(t, err, "requires a key-value store")
+}
+
+func TestExecuteWorkflow_StepCacheNestedKVStep(t *testing.T) {
+	bucket := kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "record", Parallel: &ast.ParallelStep{Steps: []*ast.Step{
+			{ID: "seen", KV: &ast.KVStep{Action: ast.KVActionSet, Key: "seen", Value: true}},
+		}}},
+	})
+
+	config := DefaultExecutorConfig()
+	config.StepCache = NewStepCache()
+
+	_, _, err := runTestWorkflow(t, workflow, nil, withExecutorConfig(config), withKV(bucket))
+	require.NoError(t, err)
+
+	// the value is written again by the next run rather than the parallel
+	// step being reused from the step cache
+	_, _, err = bucket.Delete("seen")
+	require.NoError(t, err)
+	_, _, err = runTestWorkflow(t, workflow, nil, withExecutorConfig(config), withKV(bucket))
+	require.NoError(t, err)
+
+	_, ok, err := bucket.Get("seen")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
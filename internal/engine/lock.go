@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the name of the file the model aliases of a workflow are
+// locked in, next to the workflow file.
+const LockFileName = "laq.lock"
+
+const lockFileHeader = "# Generated by laq, the model snapshots the agents' aliases are locked to.\n# Commit it to run agents with the same models everywhere, update it with\n# laq run --update-lock.\n"
+
+// ModelLockPath returns the path of the lock file of a workflow.
+func ModelLockPath(workflowFile string) string {
+	return filepath.Join(filepath.Dir(workflowFile), LockFileName)
+}
+
+// hasModelLock reports whether a workflow has a lock file.
+func hasModelLock(workflowFile string) bool {
+	_, err := os.Stat(ModelLockPath(workflowFile))
+	return err == nil
+}
+
+// ModelLock pins the model aliases of agents, such as claude-sonnet-4 or
+// gpt-4o, to the snapshots they resolved to when they were first used, so
+// that a workflow keeps using the same models when the aliases move on.
+type ModelLock struct {
+	// Models is the snapshot each alias is locked to, keyed by provider and
+	// alias, e.g. anthropic/claude-sonnet-4
+	Models map[string]string `yaml:"models"`
+
+	path   string
+	update bool
+	// warnings is where drifts of aliases are reported, once each
+	warnings io.Writer
+	warned   map[string]bool
+	changed  bool
+	mu       sync.Mutex
+}
+
+// LoadModelLock reads the lock file at path, an empty lock when it doesn't
+// exist yet. When update is set aliases which resolve to another snapshot
+// upstream are locked to it, rather than warned about.
+func LoadModelLock(path string, update bool) (*ModelLock, error) {
+	lock := &ModelLock{path: path, update: update}
+
+	data, err := os.ReadFile(path) // #nosec G304 - path is next to the workflow file
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if lock.Models == nil {
+		lock.Models = make(map[string]string)
+	}
+
+	return lock, nil
+}
+
+// Resolve returns the model the requests of an agent are sent to, given the
+// model the agent's alias resolves to upstream. Aliases are locked the first
+// time they resolve to a snapshot.
+func (l *ModelLock) Resolve(provider, alias, upstream string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := provider + "/" + alias
+	locked, ok := l.Models[key]
+	switch {
+	case !ok && upstream == alias:
+		return alias
+	case !ok, l.update && upstream != alias && upstream != locked:
+		l.Models[key] = upstream
+		l.changed = true
+		return upstream
+	}
+
+	// aliases which can't be resolved upstream may be locked to the
+	// snapshot the provider reported serving them
+	if upstream != alias && upstream != locked {
+		l.warn(key, fmt.Sprintf("%s now resolves to %s but is locked to %s in %s, run with --update-lock to use it", key, upstream, locked, LockFileName))
+	}
+
+	return locked
+}
+
+// Record locks an alias to the snapshot the provider reported serving it,
+// for aliases which can't be resolved upstream such as
+// claude-3-5-sonnet-latest. Aliases which are already locked are kept.
+func (l *ModelLock) Record(provider, alias, snapshot string) {
+	if snapshot == "" || snapshot == alias {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := provider + "/" + alias
+	if _, ok := l.Models[key]; !ok {
+		l.Models[key] = snapshot
+		l.changed = true
+	}
+}
+
+func (l *ModelLock) warn(key, message string) {
+	if l.warned[key] {
+		return
+	}
+	if l.warned == nil {
+		l.warned = make(map[string]bool)
+	}
+	l.warned[key] = true
+
+	log.Warn().Str("model", key).Msg(message)
+	if l.warnings != nil {
+		style.Warning(l.warnings, message)
+	}
+}
+
+// Save writes the lock file when aliases were locked since it was read.
+func (l *ModelLock) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.changed {
+		return nil
+	}
+
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", l.path, err)
+	}
+
+	if err := os.WriteFile(l.path, append([]byte(lockFileHeader), data...), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", l.path, err)
+	}
+	l.changed = false
+
+	return nil
+}
+
+// resolveModel returns the model the requests of an agent are sent to, the
+// snapshot its alias resolves to or is locked to.
+func (e *Executor) resolveModel(agent *ast.Agent) (string, error) {
+	model, err := e.modelRegistry.ModelAlias(agent.ProviderName(), agent.Model)
+	if err != nil || e.config.ModelLock == nil {
+		return model, err
+	}
+
+	return e.config.ModelLock.Resolve(agent.Provider, agent.Model, model), nil
+}
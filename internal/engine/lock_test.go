@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+
+	lock, err := LoadModelLock(path, false)
+	require.NoError(t, err)
+
+	// aliases are locked the first time they resolve to a snapshot
+	assert.Equal(t, "claude-sonnet-4-20250514", lock.Resolve("anthropic", "claude-sonnet-4", "claude-sonnet-4-20250514"))
+	// models which aren't aliases aren't locked
+	assert.Equal(t, "claude-opus-4-20250514", lock.Resolve("anthropic", "claude-opus-4-20250514", "claude-opus-4-20250514"))
+	// aliases which can't be resolved are locked to the snapshot which served them
+	lock.Record("openai", "gpt-4o", "gpt-4o-2024-08-06")
+	require.NoError(t, lock.Save())
+
+	data, err := os.ReadFile(path) // #nosec G304 - test file path is controlled
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# Generated by laq")
+
+	lock, err = LoadModelLock(path, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"anthropic/claude-sonnet-4": "claude-sonnet-4-20250514",
+		"openai/gpt-4o":             "gpt-4o-2024-08-06",
+	}, lock.Models)
+
+	var warnings bytes.Buffer
+	lock.warnings = &warnings
+
+	// the locked snapshot is used when the alias moves on, with a warning
+	assert.Equal(t, "claude-sonnet-4-20250514", lock.Resolve("anthropic", "claude-sonnet-4", "claude-sonnet-4-20250901"))
+	assert.Equal(t, "claude-sonnet-4-20250514", lock.Resolve("anthropic", "claude-sonnet-4", "claude-sonnet-4-20250901"))
+	assert.Equal(t, 1, bytes.Count(warnings.Bytes(), []byte("anthropic/claude-sonnet-4 now resolves to claude-sonnet-4-20250901 but is locked to claude-sonnet-4-20250514 in laq.lock")))
+	assert.Equal(t, "gpt-4o-2024-08-06", lock.Resolve("openai", "gpt-4o", "gpt-4o"))
+	lock.Record("openai", "gpt-4o", "gpt-4o-2024-11-20")
+	assert.Equal(t, "gpt-4o-2024-08-06", lock.Models["openai/gpt-4o"])
+
+	// updating locks the alias to the new snapshot
+	lock, err = LoadModelLock(path, true)
+	require.NoError(t, err)
+	assert.Equal(t, "claude-sonnet-4-20250901", lock.Resolve("anthropic", "claude-sonnet-4", "claude-sonnet-4-20250901"))
+	require.NoError(t, lock.Save())
+
+	lock, err = LoadModelLock(path, false)
+	require.NoError(t, err)
+	assert.Equal(t, "claude-sonnet-4-20250901", lock.Models["anthropic/claude-sonnet-4"])
+}
+
+func TestRunWorkflow_ModelLock(t *testing.T) {
+	pr := &scriptedProvider{name: "openai", snapshot: "test-model-20250101", responses: []provider.ContentBlockParamUnion{
+		provider.NewTextBlock("LGTM"),
+	}}
+
+	registry := provider.NewRegistry(false)
+	require.NoError(t, registry.RegisterProvider(pr))
+
+	dir := t.TempDir()
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "review", Agent: "reviewer", Prompt: "Review the diff"},
+	})
+	workflow.SourceFile = filepath.Join(dir, "workflow.laq.yml")
+	workflow.Agents = map[string]*ast.Agent{
+		"reviewer": {Name: "reviewer", Provider: pr.name, Model: "test-model"},
+	}
+
+	runner := NewRunner(nil, WithModelLock(false), WithExecutorFunc(func(ctx execcontext.RunContext, config *ExecutorConfig, workflow *ast.Workflow, _ *provider.Registry, runner *Runner) (WorkflowExecutor, error) {
+		return NewExecutor(ctx, config, workflow, registry, runner)
+	}))
+
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	execCtx := execcontext.NewExecutionContext(ctx, workflow, map[string]interface{}{}, dir)
+	_, err := runner.RunWorkflowRaw(execCtx, workflow, time.Now())
+	require.NoError(t, err)
+
+	lock, err := LoadModelLock(filepath.Join(dir, LockFileName), false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"openai/test-model": "test-model-20250101"}, lock.Models)
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are the Prometheus metrics of what runs do: how long their steps
+// and model requests take, the tokens they use, and the tool calls and step
+// attempts which fail. Runs record them when given with WithMetrics, a nil
+// Metrics records nothing.
+type Metrics struct {
+	stepDuration     *prometheus.HistogramVec
+	stepRetries      *prometheus.CounterVec
+	providerLatency  *prometheus.HistogramVec
+	tokens           *prometheus.CounterVec
+	toolCallFailures *prometheus.CounterVec
+}
+
+// NewMetrics creates the metrics of the engine and registers them with
+// registerer, they aren't registered when registerer is nil.
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		stepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lacquer_step_duration_seconds",
+			Help:    "Duration of executed steps in seconds by step type and status",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		}, []string{"step_type", "status"}),
+		stepRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lacquer_step_retries_total",
+			Help: "Total failed step attempts which were retried by step type",
+		}, []string{"step_type"}),
+		providerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lacquer_provider_request_duration_seconds",
+			Help:    "Duration of requests to model providers in seconds by provider, model and status",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		}, []string{"provider", "model", "status"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lacquer_model_tokens_total",
+			Help: "Total tokens used by requests to model providers by provider, model and type",
+		}, []string{"provider", "model", "type"}),
+		toolCallFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lacquer_tool_call_failures_total",
+			Help: "Total failed tool calls of agents by tool",
+		}, []string{"tool"}),
+	}
+
+	if registerer != nil {
+		registerer.MustRegister(m.stepDuration, m.stepRetries, m.providerLatency, m.tokens, m.toolCallFailures)
+	}
+
+	return m
+}
+
+// observeStep records the duration of an executed step, status is
+// "completed" or "failed".
+func (m *Metrics) observeStep(stepType, status string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.stepDuration.WithLabelValues(stepType, status).Observe(duration.Seconds())
+}
+
+// stepRetried records a failed attempt of a step which is retried.
+func (m *Metrics) stepRetried(stepType string) {
+	if m == nil {
+		return
+	}
+	m.stepRetries.WithLabelValues(stepType).Inc()
+}
+
+// observeProviderRequest records the duration of a request to a provider
+// and the tokens it used, err is nil when it succeeded.
+func (m *Metrics) observeProviderRequest(provider, model string, duration time.Duration, usage *execcontext.TokenUsage, err error) {
+	if m == nil {
+		return
+	}
+
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	m.providerLatency.WithLabelValues(provider, model, status).Observe(duration.Seconds())
+
+	if usage != nil {
+		m.tokens.WithLabelValues(provider, model, "prompt").Add(float64(usage.PromptTokens))
+		m.tokens.WithLabelValues(provider, model, "completion").Add(float64(usage.CompletionTokens))
+	}
+}
+
+// toolCallFailed records a failed call of the tool.
+func (m *Metrics) toolCallFailed(tool string) {
+	if m == nil {
+		return
+	}
+	m.toolCallFailures.WithLabelValues(tool).Inc()
+}
+
+// metrics returns the metrics runs record, nil when they don't.
+func (e *Executor) metrics() *Metrics {
+	if e.config == nil {
+		return nil
+	}
+	return e.config.Metrics
+}
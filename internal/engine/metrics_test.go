@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteWorkflow_Metrics(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "answer", Agent: "analyst", Prompt: "Look it up"},
+		{
+			ID:  "flaky",
+			Run: flakyScript(t, 2),
+			Retry: &ast.StepRetry{
+				MaxAttempts: 2,
+				Delay:       &ast.Duration{Duration: time.Millisecond},
+			},
+		},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"analyst": {Name: "analyst", Provider: "anthropic", Model: "test-model", Tools: []*ast.Tool{
+			{Name: "lookup", Script: "exit 1"},
+		}},
+	}
+
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{
+		provider.NewToolUseBlock("call-1", []byte(`{}`), "lookup"),
+		provider.NewTextBlock("42"),
+	}}
+
+	metrics := NewMetrics(prometheus.NewRegistry())
+	config := DefaultExecutorConfig()
+	config.Metrics = metrics
+
+	_, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr), withExecutorConfig(config))
+	require.NoError(t, err)
+
+	// a series for each step type and status, and model and request status
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.stepDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.providerLatency))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.stepRetries.WithLabelValues("script")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.toolCallFailures.WithLabelValues("lookup")))
+	assert.Equal(t, float64(20), testutil.ToFloat64(metrics.tokens.WithLabelValues("anthropic", "test-model", "prompt")))
+	assert.Equal(t, float64(10), testutil.ToFloat64(metrics.tokens.WithLabelValues("anthropic", "test-model", "completion")))
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var metrics *Metrics
+
+	// a nil Metrics records nothing rather than failing
+	metrics.observeStep("agent", "completed", time.Second)
+	metrics.stepRetried("agent")
+	metrics.observeProviderRequest("anthropic", "test-model", time.Second, &execcontext.TokenUsage{PromptTokens: 1}, nil)
+	metrics.toolCallFailed("lookup")
+}
@@ -0,0 +1,284 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+// Actions taken when a moderation policy matches.
+const (
+	// ModerationBlock fails the model request.
+	ModerationBlock = "block"
+	// ModerationRedact replaces the matched text with ModerationRedacted,
+	// or the whole text when it was flagged by a moderation API.
+	ModerationRedact = "redact"
+	// ModerationFlag only records the match in a moderation event.
+	ModerationFlag = "flag"
+)
+
+// Stages of a model request moderation policies apply to.
+const (
+	// ModerationPrompt is the prompt sent to the model.
+	ModerationPrompt = "prompt"
+	// ModerationOutput is the response of the model.
+	ModerationOutput = "output"
+)
+
+// ModerationRedacted replaces text redacted by a moderation policy.
+const ModerationRedacted = "[REDACTED]"
+
+// Moderation configures the policies checking the prompts sent to models
+// and their responses, configured under moderation in the Lacquer config.
+type Moderation struct {
+	Policies []ModerationPolicy `mapstructure:"policies"`
+}
+
+// ModerationPolicy matches text containing any of its keywords or patterns,
+// or flagged by the moderation API of a provider.
+type ModerationPolicy struct {
+	Name string `mapstructure:"name"`
+	// Keywords are matched case insensitively.
+	Keywords []string `mapstructure:"keywords"`
+	// Patterns are regular expressions.
+	Patterns []string `mapstructure:"patterns"`
+	// API is the provider whose moderation API checks the text, only
+	// openai has one.
+	API string `mapstructure:"api"`
+	// Action is block, redact or flag, flag by default.
+	Action string `mapstructure:"action"`
+	// Stages are the stages the policy applies to, prompt and output, both
+	// by default.
+	Stages []string `mapstructure:"stages"`
+}
+
+// ModerationError is returned for model requests blocked by a moderation
+// policy.
+type ModerationError struct {
+	Policy     string
+	Stage      string
+	Categories []string
+}
+
+func (e *ModerationError) Error() string {
+	if len(e.Categories) > 0 {
+		return fmt.Sprintf("%s blocked by moderation policy %s (%s)", e.Stage, e.Policy, strings.Join(e.Categories, ", "))
+	}
+	return fmt.Sprintf("%s blocked by moderation policy %s", e.Stage, e.Policy)
+}
+
+// moderationMatch is a policy which matched a prompt or output.
+type moderationMatch struct {
+	policy     string
+	action     string
+	categories []string
+}
+
+// moderator applies moderation policies to the text of model requests.
+type moderator struct {
+	policies []moderationPolicy
+}
+
+type moderationPolicy struct {
+	ModerationPolicy
+	pattern *regexp.Regexp
+	api     provider.Moderator
+}
+
+// apiProviders returns the providers whose moderation APIs are used by the
+// policies, which need to be initialised even when no agent uses them.
+func (m Moderation) apiProviders() []string {
+	var providers []string
+	for _, policy := range m.Policies {
+		if policy.API != "" && !slices.Contains(providers, policy.API) {
+			providers = append(providers, policy.API)
+		}
+	}
+	return providers
+}
+
+// newModerator validates the policies, returning nil when there are none.
+// Providers of moderation APIs are taken from the registry.
+func newModerator(config Moderation, registry *provider.Registry) (*moderator, error) {
+	if len(config.Policies) == 0 {
+		return nil, nil
+	}
+
+	m := &moderator{}
+	for i, policy := range config.Policies {
+		if policy.Name == "" {
+			policy.Name = fmt.Sprintf("policy-%d", i+1)
+		}
+
+		switch policy.Action {
+		case "":
+			policy.Action = ModerationFlag
+		case ModerationBlock, ModerationRedact, ModerationFlag:
+		default:
+			return nil, fmt.Errorf("moderation policy %s: invalid action %q, use block, redact or flag", policy.Name, policy.Action)
+		}
+
+		if len(policy.Stages) == 0 {
+			policy.Stages = []string{ModerationPrompt, ModerationOutput}
+		}
+		for _, stage := range policy.Stages {
+			if stage != ModerationPrompt && stage != ModerationOutput {
+				return nil, fmt.Errorf("moderation policy %s: invalid stage %q, use prompt or output", policy.Name, stage)
+			}
+		}
+
+		compiled := moderationPolicy{ModerationPolicy: policy}
+
+		var alternatives []string
+		for _, keyword := range policy.Keywords {
+			alternatives = append(alternatives, "(?i:"+regexp.QuoteMeta(keyword)+")")
+		}
+		for _, pattern := range policy.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("moderation policy %s: invalid pattern %q: %w", policy.Name, pattern, err)
+			}
+			alternatives = append(alternatives, "(?:"+pattern+")")
+		}
+		if len(alternatives) > 0 {
+			compiled.pattern = regexp.MustCompile(strings.Join(alternatives, "|"))
+		}
+
+		if policy.API != "" {
+			pr, err := registry.GetProviderByName(policy.API)
+			if err != nil {
+				return nil, fmt.Errorf("moderation policy %s: %w", policy.Name, err)
+			}
+			api, ok := pr.(provider.Moderator)
+			if !ok {
+				return nil, fmt.Errorf("moderation policy %s: provider %s has no moderation API", policy.Name, policy.API)
+			}
+			compiled.api = api
+		}
+
+		if compiled.pattern == nil && compiled.api == nil {
+			return nil, fmt.Errorf("moderation policy %s: set keywords, patterns or api", policy.Name)
+		}
+
+		m.policies = append(m.policies, compiled)
+	}
+
+	return m, nil
+}
+
+// check applies the policies of the stage to the text, returning the text
+// with any redactions and the policies which matched. A *ModerationError is
+// returned when a policy blocks the text, moderation APIs which fail fail
+// the check too.
+func (m *moderator) check(ctx context.Context, stage, text string) (string, []moderationMatch, error) {
+	var matches []moderationMatch
+	for _, policy := range m.policies {
+		if !slices.Contains(policy.Stages, stage) || strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		var categories []string
+		matched := false
+		if policy.pattern != nil && policy.pattern.MatchString(text) {
+			matched = true
+		}
+		if policy.api != nil {
+			flagged, err := policy.api.Moderate(ctx, text)
+			if err != nil {
+				return text, matches, fmt.Errorf("moderation policy %s: %w", policy.Name, err)
+			}
+			if len(flagged) > 0 {
+				matched = true
+				categories = flagged
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		matches = append(matches, moderationMatch{policy: policy.Name, action: policy.Action, categories: categories})
+
+		switch policy.Action {
+		case ModerationBlock:
+			return text, matches, &ModerationError{Policy: policy.Name, Stage: stage, Categories: categories}
+		case ModerationRedact:
+			if len(categories) > 0 || policy.pattern == nil {
+				text = ModerationRedacted
+			} else {
+				text = policy.pattern.ReplaceAllString(text, ModerationRedacted)
+			}
+		}
+	}
+
+	return text, matches, nil
+}
+
+// moderate applies the moderation policies of the stage to the text blocks
+// of the messages, redacting them in place. Every policy which matches is
+// recorded with a moderation event.
+func (e *Executor) moderate(execCtx *execcontext.ExecutionContext, step *ast.Step, actionID, stage string, messages []provider.Message) error {
+	if e.moderator == nil {
+		return nil
+	}
+
+	for _, message := range messages {
+		for _, block := range message.Content {
+			if block.OfText == nil {
+				continue
+			}
+
+			text, matches, err := e.moderator.check(execCtx.Context.Context, stage, block.OfText.Text)
+			block.OfText.Text = text
+			for _, match := range matches {
+				e.moderationEvent(execCtx, step, actionID, stage, match)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// moderationEvent records a policy matching a prompt or output, without the
+// matched content.
+func (e *Executor) moderationEvent(execCtx *execcontext.ExecutionContext, step *ast.Step, actionID, stage string, match moderationMatch) {
+	log.Warn().
+		Str("step_id", step.ID).
+		Str("policy", match.policy).
+		Str("stage", stage).
+		Str("action", match.action).
+		Strs("categories", match.categories).
+		Msg("Moderation policy matched")
+
+	if e.progressChan == nil {
+		return
+	}
+
+	metadata := map[string]interface{}{
+		pkgEvents.MetadataModerationPolicy: match.policy,
+		pkgEvents.MetadataModerationStage:  stage,
+		pkgEvents.MetadataModerationAction: match.action,
+	}
+	if len(match.categories) > 0 {
+		metadata[pkgEvents.MetadataModerationCategories] = match.categories
+	}
+
+	e.progressChan <- pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventModeration,
+		Timestamp: time.Now(),
+		RunID:     execCtx.RunID,
+		StepID:    step.ID,
+		ActionID:  actionID,
+		Metadata:  metadata,
+	}
+}
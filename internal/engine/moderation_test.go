@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// moderatedProvider flags any text containing "attack" with its moderation
+// API.
+type moderatedProvider struct {
+	delayedProvider
+}
+
+func (p *moderatedProvider) GetName() string { return "moderated" }
+
+func (p *moderatedProvider) Moderate(_ context.Context, text string) ([]string, error) {
+	if text == "attack" {
+		return []string{"violence"}, nil
+	}
+	return nil, nil
+}
+
+func TestNewModerator(t *testing.T) {
+	registry := provider.NewRegistry(false)
+	require.NoError(t, registry.RegisterProvider(&delayedProvider{}))
+
+	m, err := newModerator(Moderation{}, registry)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	tests := []struct {
+		name   string
+		policy ModerationPolicy
+		err    string
+	}{
+		{"invalid action", ModerationPolicy{Keywords: []string{"x"}, Action: "drop"}, "invalid action"},
+		{"invalid stage", ModerationPolicy{Keywords: []string{"x"}, Stages: []string{"tool"}}, "invalid stage"},
+		{"invalid pattern", ModerationPolicy{Patterns: []string{"("}}, "invalid pattern"},
+		{"nothing to match", ModerationPolicy{Name: "empty"}, "set keywords, patterns or api"},
+		{"unknown api", ModerationPolicy{API: "openai"}, "provider openai not found"},
+		{"provider without api", ModerationPolicy{API: "delayed"}, "has no moderation API"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newModerator(Moderation{Policies: []ModerationPolicy{tt.policy}}, registry)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestModerator_Check(t *testing.T) {
+	registry := provider.NewRegistry(false)
+	require.NoError(t, registry.RegisterProvider(&moderatedProvider{}))
+
+	m, err := newModerator(Moderation{Policies: []ModerationPolicy{
+		{Name: "secrets", Patterns: []string{`sk-[a-z0-9]{8}`}, Action: ModerationRedact, Stages: []string{ModerationPrompt}},
+		{Name: "codenames", Keywords: []string{"Project Falcon"}},
+		{Name: "harmful", API: "moderated", Action: ModerationBlock},
+	}}, registry)
+	require.NoError(t, err)
+
+	text, matches, err := m.check(context.Background(), ModerationPrompt, "use key sk-abcd1234 for project falcon")
+	require.NoError(t, err)
+	assert.Equal(t, "use key [REDACTED] for project falcon", text)
+	assert.Equal(t, []moderationMatch{
+		{policy: "secrets", action: ModerationRedact},
+		{policy: "codenames", action: ModerationFlag},
+	}, matches)
+
+	// policies only apply to their stages
+	text, matches, err = m.check(context.Background(), ModerationOutput, "sk-abcd1234")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-abcd1234", text)
+	assert.Empty(t, matches)
+
+	_, matches, err = m.check(context.Background(), ModerationOutput, "attack")
+	var blocked *ModerationError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, "output blocked by moderation policy harmful (violence)", blocked.Error())
+	assert.Equal(t, []moderationMatch{{policy: "harmful", action: ModerationBlock, categories: []string{"violence"}}}, matches)
+}
+
+func TestExecutor_Generate_Moderation(t *testing.T) {
+	step := &ast.Step{ID: "agent_step"}
+	execCtx := createTestExecutionContext(createTestWorkflow([]*ast.Step{step}))
+
+	m, err := newModerator(Moderation{Policies: []ModerationPolicy{
+		{Name: "emails", Patterns: []string{`\S+@example\.com`}, Action: ModerationRedact},
+		{Name: "digits", Patterns: []string{`[0-9]`}, Action: ModerationBlock, Stages: []string{ModerationOutput}},
+	}}, provider.NewRegistry(false))
+	require.NoError(t, err)
+
+	eventsChan, collector := collectProgressEvents()
+	e := &Executor{moderator: m, progressChan: eventsChan}
+
+	request := &provider.Request{Messages: []provider.Message{{
+		Role:    "user",
+		Content: []provider.ContentBlockParamUnion{provider.NewTextBlock("Reply to jane@example.com")},
+	}}}
+
+	// the delayed provider responds with the attempt number, which is
+	// blocked
+	_, _, err = e.generate(execCtx, &delayedProvider{delays: []time.Duration{0}}, &ast.Agent{}, step, "turn-0", request)
+	var blocked *ModerationError
+	require.ErrorAs(t, err, &blocked)
+	assert.Equal(t, "Reply to [REDACTED]", request.Messages[0].Content[0].OfText.Text)
+
+	close(eventsChan)
+	collector.waitForCompletion()
+
+	var moderation []pkgEvents.Payload
+	for _, event := range collector.getEvents() {
+		if event.Type == pkgEvents.EventModeration {
+			moderation = append(moderation, event.Payload())
+		}
+	}
+	assert.Equal(t, []pkgEvents.Payload{
+		&pkgEvents.Moderation{ActionID: "turn-0", Policy: "emails", Stage: ModerationPrompt, Action: ModerationRedact},
+		&pkgEvents.Moderation{ActionID: "turn-0", Policy: "digits", Stage: ModerationOutput, Action: ModerationBlock},
+	}, moderation)
+}
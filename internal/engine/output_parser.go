@@ -53,5 +53,22 @@ func (p *OutputParser) extractJSON(response string) map[string]interface{} {
 		}
 	}
 
-	return nil
+	// the response may hold several JSON objects, such as a tool result
+	// followed by the agent's commentary, in which case the last is used
+	var last map[string]interface{}
+	for i := 0; i < len(response); i++ {
+		if response[i] != '{' {
+			continue
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(response[i:]))
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			continue
+		}
+		last = object
+		i += int(decoder.InputOffset()) - 1
+	}
+
+	return last
 }
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputParser_ExtractJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     map[string]interface{}
+	}{
+		{
+			name:     "object",
+			response: `{"score": 85}`,
+			want:     map[string]interface{}{"score": float64(85)},
+		},
+		{
+			name:     "code block",
+			response: "Here are the results:\n```json\n{\"score\": 85}\n```",
+			want:     map[string]interface{}{"score": float64(85)},
+		},
+		{
+			name:     "tool result followed by commentary",
+			response: "{\"rows\": 3}\n\nThe query found {\"score\": 85} rows.",
+			want:     map[string]interface{}{"score": float64(85)},
+		},
+		{
+			name:     "nested objects",
+			response: "{\"a\": {\"b\": 1}} and then {\"c\": {\"d\": 2}} with a stray }",
+			want:     map[string]interface{}{"c": map[string]interface{}{"d": float64(2)}},
+		},
+		{
+			name:     "no JSON",
+			response: "I couldn't find {anything",
+		},
+	}
+
+	parser := NewOutputParser()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parser.extractJSON(tt.response))
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/rs/zerolog/log"
+)
+
+// executeParallelStep runs the steps of the group concurrently, each in its
+// own child context. At most max_concurrency steps run at the same time,
+// once a step fails no more are started, the steps still running are
+// cancelled and the parallel step fails. The outputs of the steps are merged
+// into the outputs of the parallel step by step id.
+func (e *Executor) executeParallelStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	steps := step.Parallel.Steps
+
+	maxConcurrency := len(steps)
+	if step.Parallel.MaxConcurrency != nil && *step.Parallel.MaxConcurrency > 0 {
+		maxConcurrency = *step.Parallel.MaxConcurrency
+	}
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Int("steps", len(steps)).
+		Int("max_concurrency", maxConcurrency).
+		Msg("Executing parallel step")
+
+	ctx, cancel := context.WithCancel(execCtx.Context.Context)
+	defer cancel()
+
+	childCtxs := make([]*execcontext.ExecutionContext, len(steps))
+
+	// the first step to fail cancels the others, their failures are caused
+	// by it
+	var failureMu sync.Mutex
+	var failure error
+	var failed *ast.Step
+
+	sem := make(chan struct{}, max(maxConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, subStep := range steps {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		childCtx := execCtx.NewChild([]*ast.Step{subStep})
+		childCtx.Context.Context = ctx
+		childCtxs[i] = childCtx
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := e.executeSteps(childCtx, []*ast.Step{subStep}); err != nil {
+				failureMu.Lock()
+				if failure == nil {
+					failure, failed = err, subStep
+				}
+				failureMu.Unlock()
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	usage := &execcontext.TokenUsage{}
+	outputs := make(map[string]interface{}, len(steps))
+	for _, childCtx := range childCtxs {
+		if childCtx == nil {
+			continue
+		}
+		for id, result := range childCtx.StepResults {
+			usage.Add(result.TokenUsage)
+			outputs[id] = result.Output
+		}
+	}
+
+	if failure != nil {
+		return nil, fmt.Errorf("step %s of parallel step failed: %w", failed.ID, failure)
+	}
+	if err := execCtx.Context.Context.Err(); err != nil {
+		return nil, err
+	}
+
+	result := NewStepResult(outputs)
+	result.TokenUsage = usage
+
+	return result, nil
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyProvider records how many requests it answered at the same
+// time, each request takes the same delay.
+type concurrencyProvider struct {
+	delay time.Duration
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (p *concurrencyProvider) Generate(ctx provider.GenerateContext, request *provider.Request, _ chan<- pkgEvents.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
+	p.mu.Lock()
+	p.active++
+	p.maxActive = max(p.maxActive, p.active)
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.active--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Context.Done():
+		return nil, nil, ctx.Context.Err()
+	}
+
+	return []provider.Message{{
+		Role:    "assistant",
+		Content: []provider.ContentBlockParamUnion{provider.NewTextBlock("done")},
+	}}, &execcontext.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+}
+
+func (p *concurrencyProvider) GetName() string { return "anthropic" }
+
+func (p *concurrencyProvider) ListModels(context.Context) ([]provider.Info, error) {
+	return []provider.Info{{ID: "test-model"}}, nil
+}
+
+func (p *concurrencyProvider) Close() error { return nil }
+
+func TestExecuteWorkflow_ParallelSteps(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID: "fetch",
+			Parallel: &ast.ParallelStep{
+				Steps: []*ast.Step{
+					{ID: "users", Run: "echo -n users"},
+					{ID: "orders", Run: "echo -n '${{ inputs.orders }}'"},
+				},
+			},
+		},
+		{ID: "report", Run: "echo -n '${{ steps.fetch.outputs.users.output }} and ${{ steps.fetch.outputs.orders.output }}'"},
+	})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"orders": "orders"})
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("fetch")
+	require.True(t, ok)
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Len(t, outputs, 2)
+
+	report, ok := execCtx.GetStepResult("report")
+	require.True(t, ok)
+	assert.Equal(t, "users and orders", report.Response)
+}
+
+func TestExecuteWorkflow_ParallelMaxConcurrency(t *testing.T) {
+	two := 2
+	for _, tt := range []struct {
+		name           string
+		maxConcurrency *int
+		want           int
+	}{
+		{name: "all steps at once", want: 4},
+		{name: "limited", maxConcurrency: &two, want: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &concurrencyProvider{delay: 50 * time.Millisecond}
+
+			steps := make([]*ast.Step, 4)
+			for i, id := range []string{"a", "b", "c", "d"} {
+				steps[i] = &ast.Step{ID: id, Agent: "assistant", Prompt: "Hello"}
+			}
+			workflow := createTestWorkflow([]*ast.Step{
+				{ID: "ask", Parallel: &ast.ParallelStep{Steps: steps, MaxConcurrency: tt.maxConcurrency}},
+			})
+			workflow.Agents = map[string]*ast.Agent{
+				"assistant": {Name: "assistant", Provider: "anthropic", Model: "test-model"},
+			}
+
+			execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, pr.maxActive)
+
+			result, ok := execCtx.GetStepResult("ask")
+			require.True(t, ok)
+			assert.Len(t, result.Output["outputs"], 4)
+			assert.Equal(t, 60, result.TokenUsage.TotalTokens)
+		})
+	}
+}
+
+func TestExecuteWorkflow_ParallelStepFails(t *testing.T) {
+	pr := &modelDelayProvider{delays: map[string]time.Duration{
+		"slow": 5 * time.Second,
+	}}
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{
+			ID: "ask",
+			Parallel: &ast.ParallelStep{
+				Steps: []*ast.Step{
+					{ID: "slow", Agent: "slow", Prompt: "Hello"},
+					{ID: "broken", Agent: "broken", Prompt: "Hello"},
+				},
+			},
+		},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"slow":   {Name: "slow", Provider: "anthropic", Model: "slow"},
+		"broken": {Name: "broken", Provider: "anthropic", Model: "broken"},
+	}
+
+	start := time.Now()
+	_, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "step broken of parallel step failed")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/pii"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	piiModeMask     = "mask"
+	piiModeTokenize = "tokenize"
+)
+
+// executePIIStep masks the personally identifiable information found in the
+// text of the step. In tokenize mode the values are kept in the run's vault
+// so that unmaskPII can restore them. The values themselves are never part of
+// the outputs, only how many of each type were found.
+func (e *Executor) executePIIStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.PII
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Msg("Executing pii step")
+
+	value, err := e.templateEngine.Render(spec.From, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render from: %w", err)
+	}
+	text := expression.ValueToString(value)
+
+	types, err := pii.ParseTypes(spec.Types)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := pii.Detect(text, types...)
+	counts := make(map[string]interface{})
+	for _, match := range matches {
+		count, _ := counts[string(match.Type)].(int)
+		counts[string(match.Type)] = count + 1
+	}
+
+	var masked string
+	switch spec.Mode {
+	case "", piiModeMask:
+		masked = pii.Mask(text, types...)
+	case piiModeTokenize:
+		if execCtx.PII == nil {
+			return nil, fmt.Errorf("tokenize mode requires an execution context with a PII vault")
+		}
+		masked = execCtx.PII.Tokenize(text, types...)
+	default:
+		return nil, fmt.Errorf("unknown pii mode %q", spec.Mode)
+	}
+
+	return NewStepResult(map[string]interface{}{
+		"text":  masked,
+		"found": len(matches) > 0,
+		"count": len(matches),
+		"types": counts,
+	}, masked), nil
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_ExecutePIIStep_Mask(t *testing.T) {
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{ID: "mask", PII: &ast.PIIStep{From: "${{ inputs.message }}"}},
+	}), map[string]interface{}{
+		"message": "I'm jane@example.com, card 4111 1111 1111 1111, call 555-123-4567 or 555-987-6543",
+	})
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("mask")
+	require.True(t, ok)
+
+	masked := "I'm [EMAIL], card [CREDIT_CARD], call [PHONE] or [PHONE]"
+	assert.Equal(t, masked, result.Response)
+	assert.Equal(t, map[string]interface{}{
+		"text":  masked,
+		"found": true,
+		"count": 4,
+		"types": map[string]interface{}{"email": 1, "credit_card": 1, "phone": 2},
+	}, result.Output["outputs"])
+}
+
+func TestExecutor_ExecutePIIStep_Tokenize(t *testing.T) {
+	execCtx, _, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{ID: "mask", PII: &ast.PIIStep{From: "${{ inputs.message }}", Types: []string{"email"}, Mode: "tokenize"}},
+		{ID: "reply", Run: "echo -n 'Hi ${{ unmaskPII(steps.mask.output) }}'"},
+	}), map[string]interface{}{
+		"message": "jane@example.com, 555-123-4567",
+	})
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("mask")
+	require.True(t, ok)
+	assert.Equal(t, "[EMAIL_1], 555-123-4567", result.Response)
+
+	reply, ok := execCtx.GetStepResult("reply")
+	require.True(t, ok)
+	assert.Equal(t, "Hi jane@example.com, 555-123-4567", reply.Response)
+}
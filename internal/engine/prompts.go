@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/rs/zerolog/log"
+)
+
+// resolvePrompt returns the agent step with the text of the version of the
+// library's prompt it references, along with the version used, e.g.
+// review@v3. Steps whose prompt is text are returned as they are.
+func resolvePrompt(execCtx *execcontext.ExecutionContext, step *ast.Step) (*ast.Step, string, error) {
+	ref, ok := execCtx.Workflow.StepPromptRef(step)
+	if !ok {
+		return step, "", nil
+	}
+
+	version := execCtx.PromptVersion(ref.Name, func(used string) string {
+		return choosePromptVersion(execCtx.RunID, ref, used)
+	})
+
+	text, ok := execCtx.Workflow.Prompts[ref.Name].Versions[version]
+	if !ok {
+		return nil, "", fmt.Errorf("prompt %q has no version %q", ref.Name, version)
+	}
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("prompt", ref.Name).
+		Str("version", version).
+		Bool("experiment", ref.IsExperiment()).
+		Msg("Using prompt from the library")
+
+	resolved := *step
+	resolved.Prompt = text
+	return &resolved, ref.Name + "@" + version, nil
+}
+
+// choosePromptVersion returns the version of the prompt a run uses. A run
+// keeps using the version it used before when the reference allows it,
+// otherwise a version of an experiment is picked by hashing the run ID, so
+// that runs are split between the versions by their weights.
+func choosePromptVersion(runID string, ref *ast.PromptRef, used string) string {
+	if slices.ContainsFunc(ref.Variants, func(variant ast.PromptVariant) bool { return variant.Version == used }) {
+		return used
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(runID + "/" + ref.Name))
+	point := int(h.Sum32() % 100)
+
+	for _, variant := range ref.Variants {
+		if point < variant.Weight {
+			return variant.Version
+		}
+		point -= variant.Weight
+	}
+
+	return ref.Variants[len(ref.Variants)-1].Version
+}
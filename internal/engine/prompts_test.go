@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func promptLibraryWorkflow(pr *scriptedProvider, prompt string) *ast.Workflow {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "first", Agent: "reviewer", Prompt: prompt},
+		{ID: "second", Agent: "reviewer", Prompt: prompt},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"reviewer": {Name: "reviewer", Provider: pr.name, Model: "test-model"},
+	}
+	workflow.Prompts = map[string]*ast.Prompt{
+		"review": {Versions: map[string]string{
+			"v3": "Review ${{ inputs.diff }}",
+			"v4": "Review ${{ inputs.diff }} carefully",
+		}},
+	}
+	return workflow
+}
+
+func TestExecuteWorkflow_PromptLibrary(t *testing.T) {
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{
+		provider.NewTextBlock("LGTM"),
+		provider.NewTextBlock("LGTM"),
+	}}
+
+	workflow := promptLibraryWorkflow(pr, "review@v4")
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"diff": "the diff"}, withProviders(pr))
+	require.NoError(t, err)
+
+	require.Len(t, pr.requests, 2)
+	assert.Equal(t, "Review the diff carefully", requestPrompt(pr.requests[0]))
+
+	result, ok := execCtx.GetStepResult("first")
+	require.True(t, ok)
+	assert.Equal(t, "review@v4", result.Prompt)
+	assert.Equal(t, map[string]string{"review": "v4"}, execCtx.GetExecutionSummary().PromptVersions)
+}
+
+func TestExecuteWorkflow_PromptExperiment(t *testing.T) {
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{
+		provider.NewTextBlock("LGTM"),
+		provider.NewTextBlock("LGTM"),
+	}}
+
+	workflow := promptLibraryWorkflow(pr, "review@50% v3, 50% v4")
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"diff": "the diff"}, withProviders(pr))
+	require.NoError(t, err)
+
+	version := execCtx.GetExecutionSummary().PromptVersions["review"]
+	require.Contains(t, []string{"v3", "v4"}, version)
+
+	// every step of the run uses the same version
+	require.Len(t, pr.requests, 2)
+	assert.Equal(t, requestPrompt(pr.requests[0]), requestPrompt(pr.requests[1]))
+	for _, stepID := range []string{"first", "second"} {
+		result, ok := execCtx.GetStepResult(stepID)
+		require.True(t, ok)
+		assert.Equal(t, "review@"+version, result.Prompt)
+	}
+}
+
+func TestChoosePromptVersion(t *testing.T) {
+	ref, _, err := ast.ParsePromptRef("review@90% v3, 10% v4")
+	require.NoError(t, err)
+
+	// the version is chosen by the run, the same run always gets the same one
+	assert.Equal(t, choosePromptVersion("run-1", ref, ""), choosePromptVersion("run-1", ref, ""))
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		counts[choosePromptVersion(string(rune('a'+i%26))+string(rune('0'+i/26)), ref, "")]++
+	}
+	assert.InDelta(t, 900, counts["v3"], 50)
+	assert.InDelta(t, 100, counts["v4"], 50)
+
+	// a version the run already used is kept
+	assert.Equal(t, "v4", choosePromptVersion("run-1", ref, "v4"))
+	assert.Contains(t, []string{"v3", "v4"}, choosePromptVersion("run-1", ref, "v1"))
+}
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	raceBranchWon       = "won"
+	raceBranchCompleted = "completed"
+	raceBranchFailed    = "failed"
+	raceBranchCancelled = "cancelled"
+)
+
+// raceBranchResult is the outcome of one branch of a race step.
+type raceBranchResult struct {
+	branch    *ast.RaceBranch
+	execCtx   *execcontext.ExecutionContext
+	duration  time.Duration
+	score     float64
+	err       error
+	cancelled bool
+}
+
+// executeRaceStep runs the branches of the step concurrently, each in its
+// own child context. Without best the first branch to succeed wins and the
+// others are cancelled, with best every branch runs to completion and the
+// branch with the highest score wins. The token usage of every step which
+// completed is added up, including the steps of the branches which lost.
+func (e *Executor) executeRaceStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Race
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Int("branches", len(spec.Branches)).
+		Msg("Executing race step")
+
+	ctx, cancel := context.WithCancel(execCtx.Context.Context)
+	defer cancel()
+
+	if spec.Timeout != nil && spec.Timeout.Duration > 0 {
+		var timeoutCancel context.CancelFunc
+		ctx, timeoutCancel = context.WithTimeout(ctx, spec.Timeout.Duration)
+		defer timeoutCancel()
+	}
+
+	start := time.Now()
+	results := make(chan *raceBranchResult, len(spec.Branches))
+	for _, branch := range spec.Branches {
+		branchCtx := execCtx.NewChild(branch.Steps)
+		branchCtx.Context.Context = ctx
+
+		go func() {
+			err := e.executeSteps(branchCtx, branch.Steps)
+			if err == nil && ctx.Err() != nil {
+				// the branch stopped before running all of its steps
+				err = ctx.Err()
+			}
+
+			results <- &raceBranchResult{
+				branch:    branch,
+				execCtx:   branchCtx,
+				duration:  time.Since(start),
+				err:       err,
+				cancelled: err != nil && ctx.Err() != nil,
+			}
+		}()
+	}
+
+	var winner *raceBranchResult
+	finished := make([]*raceBranchResult, 0, len(spec.Branches))
+	for range spec.Branches {
+		result := <-results
+		finished = append(finished, result)
+
+		if result.err != nil {
+			log.Debug().
+				Err(result.err).
+				Str("step_id", step.ID).
+				Str("branch", result.branch.ID).
+				Msg("Race branch failed")
+			continue
+		}
+
+		if spec.Best == "" {
+			if winner == nil {
+				winner = result
+				// keep waiting for the cancelled branches so that they've
+				// stopped before the workflow continues
+				cancel()
+			}
+			continue
+		}
+
+		result.score, result.err = e.evaluateScore(result.execCtx, spec.Best)
+		if result.err != nil {
+			continue
+		}
+		if winner == nil || result.score > winner.score {
+			winner = result
+		}
+	}
+
+	usage := &execcontext.TokenUsage{}
+	branches := make(map[string]interface{}, len(finished))
+	var failures []string
+	for _, result := range finished {
+		for _, stepResult := range result.execCtx.StepResults {
+			usage.Add(stepResult.TokenUsage)
+		}
+
+		status := raceBranchCompleted
+		switch {
+		case result == winner:
+			status = raceBranchWon
+		case result.cancelled:
+			status = raceBranchCancelled
+		case result.err != nil:
+			status = raceBranchFailed
+		}
+
+		branchOutput := map[string]interface{}{
+			"status":      status,
+			"duration_ms": result.duration.Milliseconds(),
+		}
+		if result.err != nil {
+			branchOutput["error"] = result.err.Error()
+			failures = append(failures, fmt.Sprintf("%s: %s", result.branch.ID, result.err))
+		}
+		if spec.Best != "" && result.err == nil {
+			branchOutput["score"] = result.score
+		}
+		branches[result.branch.ID] = branchOutput
+	}
+
+	if winner == nil {
+		if ctx.Err() == context.DeadlineExceeded && execCtx.Context.Context.Err() == nil {
+			return nil, fmt.Errorf("no branch of the race succeeded within %s: %s", spec.Timeout.Duration, strings.Join(failures, "; "))
+		}
+		return nil, fmt.Errorf("every branch of the race failed: %s", strings.Join(failures, "; "))
+	}
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("winner", winner.branch.ID).
+		Msg("Race branch won")
+
+	stepOutputs := make(map[string]interface{}, len(winner.execCtx.StepResults))
+	for id, stepResult := range winner.execCtx.StepResults {
+		stepOutputs[id] = stepResult.Output
+	}
+
+	var response string
+	last := winner.branch.Steps[len(winner.branch.Steps)-1]
+	if lastResult, ok := winner.execCtx.StepResults[last.ID]; ok {
+		response = lastResult.Response
+	}
+
+	result := NewStepResult(map[string]interface{}{
+		"winner":   winner.branch.ID,
+		"steps":    stepOutputs,
+		"branches": branches,
+	}, response)
+	result.TokenUsage = usage
+
+	return result, nil
+}
+
+// evaluateScore evaluates a best expression, such as the one of a race step
+// in the context of a branch which has completed, to a number.
+func (e *Executor) evaluateScore(execCtx *execcontext.ExecutionContext, best string) (float64, error) {
+	value, err := e.templateEngine.Render(best, execCtx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to render best: %w", err)
+	}
+
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	score, err := strconv.ParseFloat(strings.TrimSpace(expression.ValueToString(value)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("best must evaluate to a number, got %q", expression.ValueToString(value))
+	}
+
+	return score, nil
+}
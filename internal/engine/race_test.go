@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modelDelayProvider answers with the model name after the model's delay, or
+// fails when the model has no delay.
+type modelDelayProvider struct {
+	delays map[string]time.Duration
+}
+
+func (p *modelDelayProvider) Generate(ctx provider.GenerateContext, request *provider.Request, _ chan<- pkgEvents.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
+	delay, ok := p.delays[request.Model]
+	if !ok {
+		return nil, nil, errors.New("model overloaded")
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Context.Done():
+		return nil, &execcontext.TokenUsage{PromptTokens: 10, TotalTokens: 10}, ctx.Context.Err()
+	}
+
+	return []provider.Message{{
+		Role:    "assistant",
+		Content: []provider.ContentBlockParamUnion{provider.NewTextBlock("answer from " + request.Model)},
+	}}, &execcontext.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil
+}
+
+func (p *modelDelayProvider) GetName() string { return "anthropic" }
+
+func (p *modelDelayProvider) ListModels(context.Context) ([]provider.Info, error) {
+	return []provider.Info{{ID: "fast"}, {ID: "slow"}, {ID: "broken"}}, nil
+}
+
+func (p *modelDelayProvider) Close() error { return nil }
+
+// newModelDelayProvider answers for the fast model after 10ms and the slow
+// model after 5s.
+func newModelDelayProvider() *modelDelayProvider {
+	return &modelDelayProvider{delays: map[string]time.Duration{
+		"fast": 10 * time.Millisecond,
+		"slow": 5 * time.Second,
+	}}
+}
+
+// modelDelayAgents returns the agents fast, slow and broken, each using the
+// model of the same name.
+func modelDelayAgents() map[string]*ast.Agent {
+	agents := map[string]*ast.Agent{}
+	for _, model := range []string{"fast", "slow", "broken"} {
+		agents[model] = &ast.Agent{Name: model, Provider: "anthropic", Model: model}
+	}
+	return agents
+}
+
+func raceBranch(id, agent string) *ast.RaceBranch {
+	return &ast.RaceBranch{
+		ID:    id,
+		Steps: []*ast.Step{{ID: "ask", Agent: agent, Prompt: "${{ inputs.question }}"}},
+	}
+}
+
+func TestExecutor_ExecuteRaceStep_FirstSuccess(t *testing.T) {
+	start := time.Now()
+	workflow := createTestWorkflow([]*ast.Step{{ID: "answer", Race: &ast.RaceStep{
+		Branches: []*ast.RaceBranch{raceBranch("slow", "slow"), raceBranch("broken", "broken"), raceBranch("fast", "fast")},
+	}}})
+	workflow.Agents = modelDelayAgents()
+
+	execCtx, events, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "Why is the sky blue?"}, withProviders(newModelDelayProvider()))
+	require.NoError(t, err)
+
+	// failed branches don't fail the workflow
+	for _, event := range events {
+		assert.NotEqual(t, pkgEvents.EventWorkflowFailed, event.Type)
+	}
+	assert.Less(t, time.Since(start), 2*time.Second, "the slow branch should be cancelled")
+
+	result, ok := execCtx.GetStepResult("answer")
+	require.True(t, ok)
+	assert.Equal(t, "answer from fast", result.Output["output"])
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "fast", outputs["winner"])
+	assert.Equal(t, "answer from fast", outputs["steps"].(map[string]interface{})["ask"].(map[string]interface{})["output"])
+
+	branches := outputs["branches"].(map[string]interface{})
+	assert.Equal(t, "won", branches["fast"].(map[string]interface{})["status"])
+	assert.Equal(t, "cancelled", branches["slow"].(map[string]interface{})["status"])
+	assert.Equal(t, "failed", branches["broken"].(map[string]interface{})["status"])
+	assert.Contains(t, branches["broken"].(map[string]interface{})["error"], "model overloaded")
+
+	assert.Equal(t, 15, result.TokenUsage.TotalTokens)
+}
+
+func TestExecutor_ExecuteRaceStep_Best(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "answer", Race: &ast.RaceStep{
+		Branches: []*ast.RaceBranch{
+			raceBranch("short", "fast"),
+			{
+				ID: "long",
+				Steps: []*ast.Step{
+					{ID: "ask", Agent: "fast", Prompt: "${{ inputs.question }}"},
+					{ID: "expand", Agent: "fast", Prompt: "Expand on ${{ steps.ask.output }}"},
+				},
+			},
+		},
+		Best: "${{ length(steps.ask.output) + (steps.expand ? 100 : 0) }}",
+	}}})
+	workflow.Agents = modelDelayAgents()
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "Why is the sky blue?"}, withProviders(newModelDelayProvider()))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("answer")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "long", outputs["winner"])
+	assert.Equal(t, 116.0, outputs["branches"].(map[string]interface{})["long"].(map[string]interface{})["score"])
+	assert.Equal(t, 16.0, outputs["branches"].(map[string]interface{})["short"].(map[string]interface{})["score"])
+}
+
+func TestExecutor_ExecuteRaceStep_AllFail(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "answer", Race: &ast.RaceStep{
+		Branches: []*ast.RaceBranch{raceBranch("a", "broken"), raceBranch("b", "slow")},
+		Timeout:  &ast.Duration{Duration: 50 * time.Millisecond},
+	}}})
+	workflow.Agents = modelDelayAgents()
+
+	_, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"question": "Why is the sky blue?"}, withProviders(newModelDelayProvider()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no branch of the race succeeded within 50ms")
+	assert.Contains(t, err.Error(), "a: ")
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+)
+
+// maxSynthesisRepairs is how many times an invalid synthesized workflow is
+// sent back to the model with its errors before giving up.
+const maxSynthesisRepairs = 2
+
+// RecordedTurn is a prompt of a recorded session along with the model's
+// response and the tools it used to answer.
+type RecordedTurn struct {
+	Prompt   string
+	Response string
+	Tools    []RecordedToolCall
+}
+
+// RecordedToolCall is a tool the model used during a turn.
+type RecordedToolCall struct {
+	Tool  string
+	Input map[string]interface{}
+}
+
+// SessionRecorder runs an interactive session with a model and records its
+// turns, so that a reusable workflow doing the same work can be synthesized
+// from them once the session ends.
+type SessionRecorder struct {
+	provider provider.Provider
+	model    string
+	messages []provider.Message
+	turns    []RecordedTurn
+}
+
+// NewSessionRecorder creates a recorder of a session with the model of the
+// provider.
+func NewSessionRecorder(pr provider.Provider, model string) *SessionRecorder {
+	return &SessionRecorder{provider: pr, model: model}
+}
+
+// Turns returns the turns recorded so far.
+func (r *SessionRecorder) Turns() []RecordedTurn {
+	return r.turns
+}
+
+// Send sends a prompt of the session to the model and returns its response.
+// Events of the provider, such as tool calls of local models, are passed to
+// onEvent when it's set.
+func (r *SessionRecorder) Send(ctx context.Context, prompt string, onEvent func(pkgEvents.ExecutionEvent)) (string, error) {
+	messages := append(r.messages, provider.Message{
+		Role:    "user",
+		Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(prompt)},
+	})
+
+	request := &provider.Request{Model: r.model, Messages: messages}
+	// local models keep their own sessions, which are resumed rather than
+	// being sent the conversation again
+	if _, ok := r.provider.(provider.LocalModelProvider); ok {
+		request.SessionID, request.Messages = resumableMessages(messages)
+	}
+
+	turn := RecordedTurn{Prompt: prompt}
+	eventsChan := make(chan pkgEvents.ExecutionEvent, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range eventsChan {
+			if call, ok := event.Payload().(*pkgEvents.ToolCall); ok {
+				turn.Tools = append(turn.Tools, RecordedToolCall{Tool: call.Tool, Input: call.Input})
+			}
+			if onEvent != nil {
+				onEvent(event)
+			}
+		}
+	}()
+
+	responseMessages, _, err := r.provider.Generate(provider.GenerateContext{
+		StepID:  "session",
+		RunID:   "record-session",
+		Context: ctx,
+	}, request, eventsChan)
+	close(eventsChan)
+	<-done
+	if err != nil {
+		return "", fmt.Errorf("model generation failed: %w", err)
+	}
+
+	turn.Response = strings.TrimSpace(responseText(responseMessages, ""))
+	r.messages = append(messages, responseMessages...)
+	r.turns = append(r.turns, turn)
+
+	return turn.Response, nil
+}
+
+// Synthesize asks the model for a workflow doing the work of the recorded
+// session and returns it once it parses and validates. A workflow with
+// errors is sent back to the model to be fixed.
+func (r *SessionRecorder) Synthesize(ctx context.Context) ([]byte, error) {
+	if len(r.turns) == 0 {
+		return nil, fmt.Errorf("the session has no turns to build a workflow from")
+	}
+
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	messages := []provider.Message{{
+		Role:    "user",
+		Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(buildSynthesisPrompt(r.provider.GetName(), r.model, r.turns))},
+	}}
+
+	for attempt := 0; ; attempt++ {
+		responseMessages, _, err := r.provider.Generate(provider.GenerateContext{
+			StepID:  "synthesize",
+			RunID:   "record-session",
+			Context: ctx,
+		}, &provider.Request{Model: r.model, Messages: messages}, drainedEvents())
+		if err != nil {
+			return nil, fmt.Errorf("model generation failed: %w", err)
+		}
+
+		response := responseText(responseMessages, "")
+		data := []byte(extractYAMLBlock(response))
+		_, err = yamlParser.ParseBytes(data, "session.laq.yml")
+		if err == nil {
+			return data, nil
+		}
+		if attempt == maxSynthesisRepairs {
+			return nil, fmt.Errorf("the synthesized workflow is still invalid after %d attempts: %w", attempt+1, err)
+		}
+
+		messages = append(messages, responseMessages...)
+		messages = append(messages, provider.Message{
+			Role: "user",
+			Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(
+				"The workflow isn't valid:\n\n" + err.Error() + "\n\nFix the errors and respond with the whole corrected workflow in a single ```yaml block.",
+			)},
+		})
+	}
+}
+
+// drainedEvents returns an events channel whose events are discarded, for
+// providers which send events without checking the channel.
+func drainedEvents() chan<- pkgEvents.ExecutionEvent {
+	eventsChan := make(chan pkgEvents.ExecutionEvent, 100)
+	go func() {
+		for range eventsChan {
+		}
+	}()
+
+	return eventsChan
+}
+
+var yamlBlockPattern = regexp.MustCompile("(?s)```(?:ya?ml)?[ \t]*\n(.*?)```")
+
+// extractYAMLBlock returns the content of the last fenced block of the
+// response, the whole response when it has none.
+func extractYAMLBlock(response string) string {
+	matches := yamlBlockPattern.FindAllStringSubmatch(response, -1)
+	if len(matches) == 0 {
+		return strings.TrimSpace(response) + "\n"
+	}
+
+	return strings.TrimSpace(matches[len(matches)-1][1]) + "\n"
+}
+
+// buildSynthesisPrompt builds the prompt asking for a workflow doing the
+// work of the recorded turns, with agents using the session's model.
+func buildSynthesisPrompt(providerName, model string, turns []RecordedTurn) string {
+	var builder strings.Builder
+	builder.WriteString("Below is the transcript of an interactive session with an AI assistant. ")
+	builder.WriteString("Turn it into a reusable Lacquer workflow which does the same work when it's run again. ")
+	builder.WriteString("Make the values specific to this session, such as file names, topics or URLs, inputs of the workflow. ")
+	builder.WriteString("Split the work into steps the way the session did, with agents whose system prompts describe their role, ")
+	builder.WriteString("and give agents the tools the session used. ")
+	fmt.Fprintf(&builder, "Agents use the provider %s and the model %s. ", providerName, model)
+	builder.WriteString("Respond with only the workflow in a single ```yaml block.\n\n")
+	builder.WriteString(synthesisFormatReference)
+
+	builder.WriteString("\n<transcript>\n")
+	for i, turn := range turns {
+		fmt.Fprintf(&builder, "<turn index=\"%d\">\n<user>\n%s\n</user>\n", i+1, turn.Prompt)
+		for _, call := range turn.Tools {
+			input, _ := json.Marshal(call.Input)
+			fmt.Fprintf(&builder, "<tool_call name=%q>%s</tool_call>\n", call.Tool, input)
+		}
+		fmt.Fprintf(&builder, "<assistant>\n%s\n</assistant>\n</turn>\n", turn.Response)
+	}
+	builder.WriteString("</transcript>")
+
+	return builder.String()
+}
+
+const synthesisFormatReference = "A Lacquer workflow looks like this:\n\n```yaml\n" + `version: "1.0"
+metadata:
+  name: blog-writer
+  description: Researches a topic and writes a blog post about it
+
+agents:
+  researcher:
+    provider: anthropic
+    model: claude-sonnet-4-20250514
+    system_prompt: You are a meticulous researcher.
+    tools:
+      - name: search_web
+        description: Search the web for information
+        script: "go run ./scripts/web_search.go"
+        parameters:
+          type: object
+          properties:
+            query:
+              type: string
+              description: The query to search for
+  writer:
+    provider: anthropic
+    model: claude-sonnet-4-20250514
+    system_prompt: You are an expert content writer.
+
+inputs:
+  topic:
+    type: string
+    description: The topic to write about
+    required: true
+
+workflow:
+  steps:
+    - id: research
+      agent: researcher
+      prompt: Research ${{ inputs.topic }} and list the key points.
+    - id: write
+      agent: writer
+      prompt: |
+        Write a blog post about ${{ inputs.topic }} using this research:
+        ${{ steps.research.output }}
+
+  outputs:
+    post: ${{ steps.write.output }}
+` + "```\n\n" +
+	"Steps may also run a shell command with `run:` instead of an agent and prompt. " +
+	"Tools may run a script with `script:` and `parameters:` or use an MCP server with `mcp_server:`.\n"
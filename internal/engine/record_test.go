@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const recordedWorkflow = `version: "1.0"
+agents:
+  writer:
+    provider: anthropic
+    model: test-model
+inputs:
+  topic:
+    type: string
+workflow:
+  steps:
+    - id: write
+      agent: writer
+      prompt: Write about ${{ inputs.topic }}
+`
+
+func TestSessionRecorder(t *testing.T) {
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{
+		provider.NewTextBlock("Here is a post about Go."),
+		provider.NewTextBlock("Here it is, shorter."),
+		provider.NewTextBlock("```yaml\nversion: \"1.0\"\nworkflow:\n  steps: []\n```"),
+		provider.NewTextBlock("Fixed:\n```yaml\n" + recordedWorkflow + "```"),
+	}}
+	recorder := NewSessionRecorder(pr, "test-model")
+
+	response, err := recorder.Send(context.Background(), "Write a post about Go", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Here is a post about Go.", response)
+
+	_, err = recorder.Send(context.Background(), "Make it shorter", nil)
+	require.NoError(t, err)
+
+	// the conversation is sent with every prompt
+	require.Len(t, pr.requests, 2)
+	assert.Len(t, pr.requests[1].Messages, 3)
+	require.Len(t, recorder.Turns(), 2)
+	assert.Equal(t, RecordedTurn{Prompt: "Make it shorter", Response: "Here it is, shorter."}, recorder.Turns()[1])
+
+	data, err := recorder.Synthesize(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, recordedWorkflow, string(data))
+
+	// the transcript is in the prompt and the invalid workflow is sent back
+	// with its errors
+	require.Len(t, pr.requests, 4)
+	assert.Contains(t, requestPrompt(pr.requests[2]), "<user>\nMake it shorter\n</user>")
+	assert.Contains(t, requestPrompt(pr.requests[2]), "provider anthropic and the model test-model")
+	assert.Len(t, pr.requests[3].Messages, 3)
+	assert.Contains(t, pr.requests[3].Messages[2].Content[0].OfText.Text, "The workflow isn't valid")
+}
+
+func TestSessionRecorder_SynthesizeGivesUp(t *testing.T) {
+	invalid := provider.NewTextBlock("```yaml\nworkflow: {}\n```")
+	pr := &scriptedProvider{name: "anthropic", responses: []provider.ContentBlockParamUnion{
+		provider.NewTextBlock("Done."), invalid, invalid, invalid,
+	}}
+	recorder := NewSessionRecorder(pr, "test-model")
+
+	_, err := recorder.Synthesize(context.Background())
+	assert.EqualError(t, err, "the session has no turns to build a workflow from")
+
+	_, err = recorder.Send(context.Background(), "Do it", nil)
+	require.NoError(t, err)
+
+	_, err = recorder.Synthesize(context.Background())
+	assert.ErrorContains(t, err, "the synthesized workflow is still invalid after 3 attempts")
+	assert.Len(t, pr.requests, 4)
+}
+
+func TestExtractYAMLBlock(t *testing.T) {
+	assert.Equal(t, "a: 1\n", extractYAMLBlock("Here:\n```yaml\na: 1\n```\nDone"))
+	assert.Equal(t, "b: 2\n", extractYAMLBlock("```\na: 1\n```\n```yml\nb: 2\n```"))
+	assert.Equal(t, "a: 1\n", extractYAMLBlock("  a: 1\n"))
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/utils"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultRetryDelay is the delay before the first retry of a step whose
+	// retry policy has no delay
+	defaultRetryDelay = time.Second
+	// maxRetryDelay caps the delay between attempts however it's backed off
+	maxRetryDelay = 10 * time.Minute
+)
+
+// executeWithRetry runs the step with run, attempting it again according to
+// the step's retry policy when it fails. Each retry is announced with a step
+// retrying event and counted in the retries of the step's result.
+func (e *Executor) executeWithRetry(execCtx *execcontext.ExecutionContext, step *ast.Step, result *execcontext.StepResult, run func() (*StepResult, error)) (*StepResult, error) {
+	policy := step.Retry
+	if policy == nil {
+		return run()
+	}
+
+	for attempt := 1; ; attempt++ {
+		stepResult, err := run()
+		if err == nil {
+			return stepResult, nil
+		}
+
+		if attempt >= policy.MaxAttempts || execCtx.Context.Context.Err() != nil {
+			if attempt > 1 {
+				return nil, fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return nil, err
+		}
+
+		retry, condErr := e.evaluateRetryCondition(execCtx, policy, attempt, err)
+		if condErr != nil {
+			return nil, fmt.Errorf("failed to evaluate retry_if: %w", condErr)
+		}
+		if !retry {
+			return nil, err
+		}
+
+		// wait at least as long as a rate limited provider asked for
+		delay := retryDelay(policy, attempt)
+		var providerErr *provider.Error
+		if errors.As(err, &providerErr) && providerErr.RetryAfter > delay {
+			delay = min(providerErr.RetryAfter, maxRetryDelay)
+		}
+		log.Warn().
+			Err(err).
+			Str("step_id", step.ID).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("Step failed, retrying")
+
+		result.Retries = attempt
+		e.metrics().stepRetried(step.GetStepType())
+		if e.progressChan != nil {
+			e.progressChan <- pkgEvents.ExecutionEvent{
+				Type:      pkgEvents.EventStepRetrying,
+				Timestamp: time.Now(),
+				RunID:     execCtx.RunID,
+				StepID:    step.ID,
+				StepIndex: execCtx.CurrentStepIndex + 1,
+				Attempt:   attempt,
+				Error:     err.Error(),
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-execCtx.Context.Context.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// evaluateRetryCondition reports whether the step should be retried after
+// the failed attempt. Steps without a retry_if condition are retried unless
+// a model request failed in a way retrying can't fix, such as an invalid API
+// key, see provider.ErrorCategory.Retryable.
+func (e *Executor) evaluateRetryCondition(execCtx *execcontext.ExecutionContext, policy *ast.StepRetry, attempt int, err error) (bool, error) {
+	category := provider.ErrorCategoryOf(err)
+	if policy.RetryIf == "" {
+		return category.Retryable(), nil
+	}
+
+	retryCtx := execCtx.NewChild(nil)
+	retryCtx.Retry = map[string]interface{}{
+		"attempt":        attempt,
+		"error":          err.Error(),
+		"error_category": string(category),
+	}
+
+	value, err := e.templateEngine.Render(policy.RetryIf, retryCtx)
+	if err != nil {
+		return false, err
+	}
+	return utils.SafeBool(value), nil
+}
+
+// retryDelay returns how long to wait after the failed attempt before the
+// next one.
+func retryDelay(policy *ast.StepRetry, attempt int) time.Duration {
+	delay := defaultRetryDelay
+	if policy.Delay != nil {
+		delay = policy.Delay.Duration
+	}
+
+	switch policy.Backoff {
+	case "constant":
+	case "linear":
+		delay *= time.Duration(attempt)
+	default:
+		for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+			delay *= 2
+		}
+	}
+
+	return min(delay, maxRetryDelay)
+}
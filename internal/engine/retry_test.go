@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyScript fails until it has been run succeedOn times.
+func flakyScript(t *testing.T, succeedOn int) string {
+	counter := filepath.Join(t.TempDir(), "attempts")
+	return "n=$(( $(cat " + counter + " 2>/dev/null || echo 0) + 1 )); echo $n > " + counter +
+		"; if [ $n -lt " + strconv.Itoa(succeedOn) + " ]; then echo \"rate limited\" >&2; exit 1; fi; echo -n \"attempt $n\""
+}
+
+// retryEvents returns the step_retrying events of a run.
+func retryEvents(events []pkgEvents.ExecutionEvent) []pkgEvents.ExecutionEvent {
+	var retries []pkgEvents.ExecutionEvent
+	for _, event := range events {
+		if event.Type == pkgEvents.EventStepRetrying {
+			retries = append(retries, event)
+		}
+	}
+	return retries
+}
+
+func TestExecuteWorkflow_StepRetry(t *testing.T) {
+	execCtx, events, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{
+			ID:  "flaky",
+			Run: flakyScript(t, 3),
+			Retry: &ast.StepRetry{
+				MaxAttempts: 3,
+				Delay:       &ast.Duration{Duration: time.Millisecond},
+			},
+		},
+	}), nil)
+	retries := retryEvents(events)
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("flaky")
+	require.True(t, ok)
+	assert.Equal(t, execcontext.StepStatusCompleted, result.Status)
+	assert.Equal(t, "attempt 3", result.Response)
+	assert.Equal(t, 2, result.Retries)
+
+	require.Len(t, retries, 2)
+	for i, event := range retries {
+		assert.Equal(t, "flaky", event.StepID)
+		assert.Equal(t, i+1, event.Attempt)
+		assert.Contains(t, event.Error, "rate limited")
+	}
+}
+
+func TestExecuteWorkflow_StepRetryExhausted(t *testing.T) {
+	execCtx, events, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+		{
+			ID:  "flaky",
+			Run: flakyScript(t, 4),
+			Retry: &ast.StepRetry{
+				MaxAttempts: 2,
+				Delay:       &ast.Duration{Duration: time.Millisecond},
+			},
+		},
+	}), nil)
+	retries := retryEvents(events)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed after 2 attempts")
+	assert.Len(t, retries, 1)
+
+	result, ok := execCtx.GetStepResult("flaky")
+	require.True(t, ok)
+	assert.Equal(t, execcontext.StepStatusFailed, result.Status)
+	assert.Equal(t, 1, result.Retries)
+}
+
+func TestExecuteWorkflow_StepRetryIf(t *testing.T) {
+	tests := []struct {
+		name    string
+		retryIf string
+		wantErr bool
+	}{
+		{name: "matching error", retryIf: "${{ contains(retry.error, 'rate limited') }}"},
+		{name: "other error", retryIf: "${{ contains(retry.error, 'timeout') }}", wantErr: true},
+		{name: "attempt", retryIf: "${{ retry.attempt < 1 }}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, events, err := runTestWorkflow(t, createTestWorkflow([]*ast.Step{
+				{
+					ID:  "flaky",
+					Run: flakyScript(t, 2),
+					Retry: &ast.StepRetry{
+						MaxAttempts: 3,
+						Delay:       &ast.Duration{Duration: time.Millisecond},
+						RetryIf:     tt.retryIf,
+					},
+				},
+			}), nil)
+			retries := retryEvents(events)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Empty(t, retries)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, retries, 1)
+		})
+	}
+}
+
+func TestEvaluateRetryCondition_ProviderErrors(t *testing.T) {
+	execCtx := createTestExecutionContext(createTestWorkflow(nil))
+	e := &Executor{templateEngine: expression.NewTemplateEngine()}
+
+	rateLimited := fmt.Errorf("model generation failed: %w", provider.NewAPIError("anthropic", http.StatusTooManyRequests, "", "slow down", nil, nil))
+	unauthorized := fmt.Errorf("model generation failed: %w", provider.NewAPIError("anthropic", http.StatusUnauthorized, "", "invalid x-api-key", nil, nil))
+
+	retry, err := e.evaluateRetryCondition(execCtx, &ast.StepRetry{}, 1, rateLimited)
+	require.NoError(t, err)
+	assert.True(t, retry)
+
+	// retrying can't fix an invalid API key
+	retry, err = e.evaluateRetryCondition(execCtx, &ast.StepRetry{}, 1, unauthorized)
+	require.NoError(t, err)
+	assert.False(t, retry)
+
+	// unless retry_if says otherwise
+	policy := &ast.StepRetry{RetryIf: "${{ retry.error_category == 'auth' }}"}
+	retry, err = e.evaluateRetryCondition(execCtx, policy, 1, unauthorized)
+	require.NoError(t, err)
+	assert.True(t, retry)
+
+	retry, err = e.evaluateRetryCondition(execCtx, policy, 1, rateLimited)
+	require.NoError(t, err)
+	assert.False(t, retry)
+}
+
+func TestRetryDelay(t *testing.T) {
+	second := &ast.Duration{Duration: time.Second}
+
+	tests := []struct {
+		name    string
+		policy  *ast.StepRetry
+		attempt int
+		want    time.Duration
+	}{
+		{name: "default", policy: &ast.StepRetry{}, attempt: 1, want: time.Second},
+		{name: "exponential", policy: &ast.StepRetry{Delay: second}, attempt: 3, want: 4 * time.Second},
+		{name: "linear", policy: &ast.StepRetry{Delay: second, Backoff: "linear"}, attempt: 3, want: 3 * time.Second},
+		{name: "constant", policy: &ast.StepRetry{Delay: second, Backoff: "constant"}, attempt: 3, want: time.Second},
+		{name: "capped", policy: &ast.StepRetry{Delay: second}, attempt: 100, want: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retryDelay(tt.policy, tt.attempt))
+		})
+	}
+}
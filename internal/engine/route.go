@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/events"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/routing"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultCompletionTokens is the expected response size used to estimate
+// the cost of requests from agents without max_tokens.
+const defaultCompletionTokens = 1024
+
+// routeAgent chooses the model for an agent which specifies a tier rather
+// than a model. The returned agent is a copy using the chosen model so the
+// workflow's agent definition is left untouched.
+func (e *Executor) routeAgent(execCtx *execcontext.ExecutionContext, step *ast.Step, agent *ast.Agent) (*ast.Agent, *routing.Decision, error) {
+	completionTokens := defaultCompletionTokens
+	if agent.MaxTokens != nil {
+		completionTokens = *agent.MaxTokens
+	}
+
+	// roughly four characters per token, prompts are only estimated as
+	// routing happens before the prompt is rendered.
+	req := routing.Request{
+		Provider:         agent.Provider,
+		Tier:             routing.Tier(agent.Tier),
+		PromptTokens:     (len(agent.SystemPrompt) + len(step.Prompt)) / 4,
+		CompletionTokens: completionTokens,
+	}
+	if e.config.Budget > 0 {
+		remaining := e.remainingBudget()
+		req.RemainingBudget = &remaining
+	}
+
+	decision, err := e.router.Route(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to route agent %s: %w", step.Agent, err)
+	}
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("tier", agent.Tier).
+		Str("model", decision.Model).
+		Str("reason", decision.Reason).
+		Msg("Routed agent to model")
+
+	if e.progressChan != nil {
+		actionID := fmt.Sprintf("%s-route", step.ID)
+		e.progressChan <- events.NewGenericActionEvent(step.ID, actionID, execCtx.RunID, fmt.Sprintf("Routed to %s (%s)", decision.Model, decision.Reason))
+		e.progressChan <- events.NewGenericActionCompletedEvent(step.ID, actionID, execCtx.RunID)
+	}
+
+	routed := *agent
+	routed.Model = decision.Model
+
+	return &routed, &decision, nil
+}
+
+// recordSpend adds the usage's tokens to the run's spend, along with its
+// cost when the model is in the routing table.
+func (e *Executor) recordSpend(agent *ast.Agent, usage *execcontext.TokenUsage) {
+	if usage == nil {
+		return
+	}
+
+	if model, ok := e.router.Lookup(agent.Provider, agent.Model); ok {
+		usage.Cost = model.Cost(usage.PromptTokens, usage.CompletionTokens)
+	}
+
+	if e.execCtx != nil {
+		e.execCtx.AddModelUsage(agent.Provider, agent.Model, usage)
+	}
+
+	e.spendMu.Lock()
+	defer e.spendMu.Unlock()
+	e.spent += usage.Cost
+	e.tokens += usage.TotalTokens
+	e.checkRunBudget()
+}
+
+// spend returns the cost and tokens the run has spent so far.
+func (e *Executor) spend() (float64, int) {
+	e.spendMu.Lock()
+	defer e.spendMu.Unlock()
+
+	return e.spent, e.tokens
+}
+
+// remainingBudget returns the part of the run's budget which hasn't been
+// spent.
+func (e *Executor) remainingBudget() float64 {
+	e.spendMu.Lock()
+	defer e.spendMu.Unlock()
+
+	return max(e.config.Budget-e.spent, 0)
+}
@@ -0,0 +1,25 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/routing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutor_RecordSpend(t *testing.T) {
+	e := &Executor{router: routing.NewRouter(routing.DefaultModels())}
+
+	usage := &execcontext.TokenUsage{PromptTokens: 1_000_000, CompletionTokens: 100_000, TotalTokens: 1_100_000}
+	e.recordSpend(&ast.Agent{Provider: "anthropic", Model: "claude-sonnet-4-20250514"}, usage)
+	assert.InDelta(t, 4.5, usage.Cost, 1e-9)
+	assert.InDelta(t, 4.5, e.spent, 1e-9)
+
+	// the cost of models without pricing is unknown
+	unknown := &execcontext.TokenUsage{PromptTokens: 1000, TotalTokens: 1000}
+	e.recordSpend(&ast.Agent{Provider: "local", Model: "llama3"}, unknown)
+	assert.Zero(t, unknown.Cost)
+	assert.InDelta(t, 4.5, e.spent, 1e-9)
+}
@@ -7,11 +7,19 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/encryption"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/lacquerai/lacquer/internal/kv"
 	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/routing"
 	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/tools/official"
+	"github.com/lacquerai/lacquer/internal/utils"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -33,28 +41,48 @@ type ExecutionResult struct {
 	FinalState   map[string]interface{} `json:"final_state,omitempty" yaml:"final_state,omitempty"`
 	Error        string                 `json:"error,omitempty" yaml:"error,omitempty"`
 	TokenUsage   *TokenUsageSummary     `json:"token_usage,omitempty" yaml:"token_usage,omitempty"`
+	Triage       *FailureTriage         `json:"triage,omitempty" yaml:"triage,omitempty"`
+	// Prompts is the version of each prompt of the library used by the run,
+	// keyed by the name of the prompt
+	Prompts map[string]string `json:"prompts,omitempty" yaml:"prompts,omitempty"`
 }
 
 // StepExecutionResult contains the execution outcome for an individual workflow step
 // including its output, timing, retry information, and token usage.
 type StepExecutionResult struct {
-	StepID     string                 `json:"step_id" yaml:"step_id"`
-	Status     string                 `json:"status" yaml:"status"`
-	StartTime  time.Time              `json:"start_time" yaml:"start_time"`
-	EndTime    time.Time              `json:"end_time,omitempty" yaml:"end_time,omitempty"`
-	Duration   time.Duration          `json:"duration" yaml:"duration"`
-	Output     map[string]interface{} `json:"output,omitempty" yaml:"output,omitempty"`
-	Response   string                 `json:"response,omitempty" yaml:"response,omitempty"`
-	Error      string                 `json:"error,omitempty" yaml:"error,omitempty"`
-	Retries    int                    `json:"retries" yaml:"retries"`
-	TokenUsage *TokenUsage            `json:"token_usage,omitempty" yaml:"token_usage,omitempty"`
+	StepID      string                 `json:"step_id" yaml:"step_id"`
+	StepType    string                 `json:"step_type,omitempty" yaml:"step_type,omitempty"`
+	Status      string                 `json:"status" yaml:"status"`
+	StartTime   time.Time              `json:"start_time" yaml:"start_time"`
+	EndTime     time.Time              `json:"end_time,omitempty" yaml:"end_time,omitempty"`
+	Duration    time.Duration          `json:"duration" yaml:"duration"`
+	Output      map[string]interface{} `json:"output,omitempty" yaml:"output,omitempty"`
+	Response    string                 `json:"response,omitempty" yaml:"response,omitempty"`
+	Error       string                 `json:"error,omitempty" yaml:"error,omitempty"`
+	Retries     int                    `json:"retries" yaml:"retries"`
+	Repairs     int                    `json:"repairs,omitempty" yaml:"repairs,omitempty"`
+	TokenUsage  *TokenUsage            `json:"token_usage,omitempty" yaml:"token_usage,omitempty"`
+	RepairUsage *TokenUsage            `json:"repair_usage,omitempty" yaml:"repair_usage,omitempty"`
+	Routing     *routing.Decision      `json:"routing,omitempty" yaml:"routing,omitempty"`
+	CostCenter  string                 `json:"cost_center,omitempty" yaml:"cost_center,omitempty"`
+	Owner       string                 `json:"owner,omitempty" yaml:"owner,omitempty"`
+	// Prompt is the version of the library's prompt used by an agent step,
+	// e.g. review@v3
+	Prompt string `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	// Environment is the versions of the dependencies the step ran with
+	Environment *execcontext.StepEnvironment `json:"environment,omitempty" yaml:"environment,omitempty"`
 }
 
 // TokenUsageSummary aggregates token consumption metrics across all workflow steps.
 type TokenUsageSummary struct {
-	TotalTokens      int `json:"total_tokens" yaml:"total_tokens"`
-	PromptTokens     int `json:"prompt_tokens" yaml:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens" yaml:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens" yaml:"total_tokens"`
+	PromptTokens     int     `json:"prompt_tokens" yaml:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens" yaml:"completion_tokens"`
+	EstimatedCost    float64 `json:"estimated_cost" yaml:"estimated_cost"`
+	RepairTokens     int     `json:"repair_tokens,omitempty" yaml:"repair_tokens,omitempty"`
+	// Models is the usage of each model keyed by provider and model, e.g.
+	// anthropic/claude-sonnet-4-20250514
+	Models map[string]*TokenUsage `json:"models,omitempty" yaml:"models,omitempty"`
 }
 
 // TokenUsage tracks token consumption and estimated cost for a single step execution.
@@ -98,6 +126,7 @@ func (as *ActionStates) Add(action ActionState) {
 		if !strings.HasPrefix(a.text, style.SuccessIcon()) && !strings.HasPrefix(a.text, style.ErrorIcon()) {
 			a.text = style.SuccessIcon() + " " + strings.TrimSpace(strings.ReplaceAll(a.text, "...", ""))
 		}
+		a.output = ""
 
 		newActions[i] = a
 	}
@@ -109,15 +138,17 @@ func (as *ActionStates) Add(action ActionState) {
 // wrapLine splits text into multiple lines respecting word boundaries.
 // Falls back to hard breaks if no suitable word boundary is found.
 func wrapLine(line string, maxWidth int) []string {
-	if len(line) <= maxWidth {
+	// work on runes so that multi-byte characters are never split
+	runes := []rune(line)
+	if len(runes) <= maxWidth {
 		return []string{line}
 	}
 
 	var wrapped []string
-	for len(line) > maxWidth {
+	for len(runes) > maxWidth {
 		// Try to find a space to break at
 		breakPoint := maxWidth
-		for breakPoint > 0 && line[breakPoint] != ' ' {
+		for breakPoint > 0 && runes[breakPoint] != ' ' {
 			breakPoint--
 		}
 
@@ -126,12 +157,12 @@ func wrapLine(line string, maxWidth int) []string {
 			breakPoint = maxWidth
 		}
 
-		wrapped = append(wrapped, strings.TrimSpace(line[:breakPoint]))
-		line = strings.TrimSpace(line[breakPoint:])
+		wrapped = append(wrapped, strings.TrimSpace(string(runes[:breakPoint])))
+		runes = []rune(strings.TrimSpace(string(runes[breakPoint:])))
 	}
 
-	if len(line) > 0 {
-		wrapped = append(wrapped, line)
+	if len(runes) > 0 {
+		wrapped = append(wrapped, string(runes))
 	}
 
 	return wrapped
@@ -195,6 +226,10 @@ func (as ActionStates) String() string {
 			}
 		}
 
+		for _, line := range action.outputTail(100 - len(indentation) - 2) {
+			text.WriteString(fmt.Sprintf("\n%s  %s", indentation, style.MutedStyle.Render(line)))
+		}
+
 		text.WriteString("\n")
 	}
 	return text.String()
@@ -205,12 +240,80 @@ func (as ActionStates) String() string {
 type ActionState struct {
 	id   string
 	text string
+	// output is the tail of the model output streamed while the action
+	// runs, it's cleared once the action finishes
+	output string
+}
+
+const (
+	// streamedOutputLines is the number of lines of streamed model output
+	// shown under a running action.
+	streamedOutputLines = 3
+	// maxStreamedOutput is the number of bytes of streamed model output kept
+	// for an action, enough for the lines which are shown.
+	maxStreamedOutput = 2048
+)
+
+// appendOutput adds a chunk of streamed model output, keeping only its tail.
+func (a *ActionState) appendOutput(text string) {
+	a.output += text
+	if len(a.output) <= maxStreamedOutput {
+		return
+	}
+
+	cut := len(a.output) - maxStreamedOutput
+	for cut < len(a.output) && !utf8.RuneStart(a.output[cut]) {
+		cut++
+	}
+	a.output = a.output[cut:]
+}
+
+// outputTail returns the last lines of the streamed output, wrapped to
+// maxWidth.
+func (a ActionState) outputTail(maxWidth int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(a.output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, wrapLine(line, maxWidth)...)
+		}
+	}
+
+	if len(lines) > streamedOutputLines {
+		lines = lines[len(lines)-streamedOutputLines:]
+	}
+	return lines
 }
 
 // Runner orchestrates workflow execution with progress tracking capabilities.
 type Runner struct {
 	progressListener pkgEvents.Listener
 	newExecutor      ExecutorFunc
+	untilStep        string
+	fromStep         string
+	stepCache        *StepCache
+	stepStore        *StepStore
+	stepFixtures     map[string]*CachedStep
+	previewLength    int
+	plainEvents      bool
+	runLogDir        string
+	eventLogPath     string
+	runLogCipher     *encryption.Cipher
+	eventLogCipher   *encryption.Cipher
+	budget           float64
+	history          *history.Store
+	checkpoints      *CheckpointStore
+	resume           *Checkpoint
+	providerObserver ProviderObserver
+	breakers         *provider.Breakers
+	initialState     map[string]interface{}
+	kv               *kv.Store
+	providers        []provider.Provider
+	metrics          *Metrics
+	lockModels       bool
+	updateLock       bool
+	// modelLock is the lock of the top-level workflow being run, nested
+	// workflows share it
+	modelLock *ModelLock
 }
 
 // RunnerOption is a function that can be used to configure a Runner.
@@ -225,10 +328,182 @@ func WithExecutorFunc(newExecutor ExecutorFunc) RunnerOption {
 	}
 }
 
+// WithUntilStep stops execution of the top-level workflow once the step
+// with the given ID has completed.
+func WithUntilStep(stepID string) RunnerOption {
+	return func(r *Runner) {
+		r.untilStep = stepID
+	}
+}
+
+// WithStepCache reuses the results of unchanged top-level steps from the
+// given cache and stores the results of newly executed steps in it. This is
+// primarily used by watch mode to avoid re-running expensive steps that
+// haven't changed between iterations.
+func WithStepCache(cache *StepCache) RunnerOption {
+	return func(r *Runner) {
+		r.stepCache = cache
+	}
+}
+
+// WithFromStep starts execution of the top-level workflow at the step with
+// the given ID. Results for the steps before it are taken from the step
+// fixtures or, failing that, the step store.
+func WithFromStep(stepID string) RunnerOption {
+	return func(r *Runner) {
+		r.fromStep = stepID
+	}
+}
+
+// WithStepStore records the results of every top-level step to the given
+// store once the workflow finishes, and uses the stored results of the
+// previous run when starting from a later step.
+func WithStepStore(store *StepStore) RunnerOption {
+	return func(r *Runner) {
+		r.stepStore = store
+	}
+}
+
+// WithStepFixtures provides step results, keyed by step ID, which take
+// precedence over the step store when starting from a later step.
+func WithStepFixtures(fixtures map[string]*CachedStep) RunnerOption {
+	return func(r *Runner) {
+		r.stepFixtures = fixtures
+	}
+}
+
+// WithPreviewLength sets the maximum number of characters of event text
+// delivered to the progress listener, a length of zero disables truncation.
+// Defaults to DefaultPreviewLength.
+func WithPreviewLength(length int) RunnerOption {
+	return func(r *Runner) {
+		r.previewLength = length
+	}
+}
+
+// WithPlainEvents removes ANSI styling from events before they are delivered
+// to the progress listener, for listeners which don't render to a terminal.
+func WithPlainEvents() RunnerOption {
+	return func(r *Runner) {
+		r.plainEvents = true
+	}
+}
+
+// WithRunLog records every event of each run, with its full untruncated
+// text, to a run log in the given directory. Each event is encrypted with
+// the cipher, when it isn't nil.
+func WithRunLog(dir string, cipher *encryption.Cipher) RunnerOption {
+	return func(r *Runner) {
+		r.runLogDir = dir
+		r.runLogCipher = cipher
+	}
+}
+
+// WithEventLog appends every event of each run to the file at path, one JSON
+// event per line with its full text. Unlike the run log the events of
+// workflows which don't keep the data of their runs are written too, redacted
+// of their text and inputs. Each event is encrypted with the cipher, when it
+// isn't nil.
+func WithEventLog(path string, cipher *encryption.Cipher) RunnerOption {
+	return func(r *Runner) {
+		r.eventLogPath = path
+		r.eventLogCipher = cipher
+	}
+}
+
+// WithBudget sets the cost budget of each run in USD. Agents which specify a
+// tier are routed to cheaper models as the budget is spent.
+func WithBudget(budget float64) RunnerOption {
+	return func(r *Runner) {
+		r.budget = budget
+	}
+}
+
+// WithRunHistory records every top-level run, including runs which fail, to
+// the given run history store.
+func WithRunHistory(store *history.Store) RunnerOption {
+	return func(r *Runner) {
+		r.history = store
+	}
+}
+
+// WithCheckpoints records the progress of every top-level run to the given
+// checkpoint store after each step, so that a run which fails can be resumed
+// with ResumeWorkflow. The checkpoint of a run is removed once it completes.
+func WithCheckpoints(store *CheckpointStore) RunnerOption {
+	return func(r *Runner) {
+		r.checkpoints = store
+	}
+}
+
+// WithProviderObserver tells the observer the outcome of every request the
+// runs send to model providers.
+func WithProviderObserver(observer ProviderObserver) RunnerOption {
+	return func(r *Runner) {
+		r.providerObserver = observer
+	}
+}
+
+// WithCircuitBreakers fails requests to providers which keep failing fast
+// with the given breakers, configured from the circuit_breaker config, and
+// switches agents with a fallback to it while they do.
+func WithCircuitBreakers(breakers *provider.Breakers) RunnerOption {
+	return func(r *Runner) {
+		r.breakers = breakers
+	}
+}
+
+// WithInitialState seeds the state of every top-level run with the given
+// values, such as the final state of an earlier run, replacing the defaults
+// declared by the workflow.
+func WithInitialState(state map[string]interface{}) RunnerOption {
+	return func(r *Runner) {
+		r.initialState = state
+	}
+}
+
+// WithKVStore keeps the values set by kv_set and kv steps in the store,
+// each workflow having its own keys.
+func WithKVStore(store *kv.Store) RunnerOption {
+	return func(r *Runner) {
+		r.kv = store
+	}
+}
+
+// WithProviders answers the requests of agents with the given providers
+// rather than the ones configured for them, such as scripted providers in
+// tests. Agents whose provider isn't given use the configured one.
+func WithProviders(providers ...provider.Provider) RunnerOption {
+	return func(r *Runner) {
+		r.providers = providers
+	}
+}
+
+// WithMetrics records what the runs do in metrics, see Metrics.
+func WithMetrics(metrics *Metrics) RunnerOption {
+	return func(r *Runner) {
+		r.metrics = metrics
+	}
+}
+
+// WithModelLock pins the model aliases of the agents of every top-level
+// workflow to the snapshots locked in the laq.lock next to the workflow
+// file, creating it when it doesn't exist, workflows which have one always
+// use it. Aliases are locked the first time they're used. When update is set
+// aliases which resolve to another snapshot upstream are locked to it,
+// rather than warned about.
+func WithModelLock(update bool) RunnerOption {
+	return func(r *Runner) {
+		r.lockModels = true
+		r.updateLock = update
+	}
+}
+
 // NewRunner creates a workflow runner with the specified progress listener.
 func NewRunner(progressListener pkgEvents.Listener, options ...RunnerOption) *Runner {
 	r := &Runner{
 		progressListener: progressListener,
+		previewLength:    DefaultPreviewLength,
 	}
 
 	for _, option := range options {
@@ -245,22 +520,111 @@ func (r *Runner) SetProgressListener(listener pkgEvents.Listener) {
 
 // RunWorkflowRaw executes a parsed workflow with the provided execution context.
 // Returns detailed execution results including step outcomes and resource usage.
+// The results of a run which failed are returned along with its error.
 func (r *Runner) RunWorkflowRaw(execCtx *execcontext.ExecutionContext, workflow *ast.Workflow, startTime time.Time, prefix ...string) (*ExecutionResult, error) {
 	// If no executor function is set, use the default implementation
 	if r.newExecutor == nil {
 		r.newExecutor = NewExecutor
 	}
 
+	if r.kv != nil && execCtx.KV == nil {
+		execCtx.KV = r.kv.Bucket(workflow.SourceFile)
+	}
+
+	normalization, err := utils.ParseNormalization(viper.GetString("normalize"))
+	if err != nil {
+		return nil, err
+	}
+
+	var routingModels []routing.Model
+	if err := viper.UnmarshalKey("routing.models", &routingModels); err != nil {
+		return nil, fmt.Errorf("invalid routing models configuration: %w", err)
+	}
+
+	if r.breakers != nil {
+		var breakerConfig provider.BreakerConfig
+		if err := viper.UnmarshalKey("circuit_breaker", &breakerConfig); err != nil {
+			return nil, fmt.Errorf("invalid circuit breaker configuration: %w", err)
+		}
+		r.breakers.Configure(breakerConfig)
+	}
+
+	var search official.SearchConfig
+	if err := viper.UnmarshalKey("search", &search); err != nil {
+		return nil, fmt.Errorf("invalid search configuration: %w", err)
+	}
+
+	var moderation Moderation
+	if err := viper.UnmarshalKey("moderation", &moderation); err != nil {
+		return nil, fmt.Errorf("invalid moderation configuration: %w", err)
+	}
+
+	// hooks only run for the top-level workflow, a nested workflow is part
+	// of the step which runs it.
+	var hooks *hookRunner
+	if len(prefix) == 0 {
+		var config Hooks
+		if err := viper.UnmarshalKey("hooks", &config); err != nil {
+			return nil, fmt.Errorf("invalid hooks configuration: %w", err)
+		}
+		if !config.IsEmpty() {
+			hooks = newHookRunner(config, execCtx, workflow)
+		}
+	}
+
 	executorConfig := &ExecutorConfig{
 		MaxConcurrentSteps: 3,
 		DefaultTimeout:     5 * time.Minute,
 		EnableRetries:      true,
+		Normalization:      normalization,
+		Budget:             r.budget,
+		RoutingModels:      routingModels,
+		Search:             search,
+		ProviderObserver:   r.providerObserver,
+		Metrics:            r.metrics,
+		Breakers:           r.breakers,
+		Moderation:         moderation,
+	}
+
+	// step controls only apply to the top-level workflow and not to any
+	// nested workflows executed by block steps.
+	if len(prefix) == 0 {
+		if err := r.configureStepControls(executorConfig, workflow); err != nil {
+			return nil, err
+		}
+	}
+
+	// workflows with a lock file always use it
+	if len(prefix) == 0 && workflow.SourceFile != "" && (r.lockModels || hasModelLock(workflow.SourceFile)) {
+		r.modelLock, err = LoadModelLock(ModelLockPath(workflow.SourceFile), r.updateLock)
+		if err != nil {
+			return nil, err
+		}
+		r.modelLock.warnings = execCtx.Context.StdErr
 	}
-	executor, err := r.newExecutor(execCtx.Context, executorConfig, workflow, nil, r)
+	executorConfig.ModelLock = r.modelLock
+
+	var registry *provider.Registry
+	if len(r.providers) > 0 {
+		registry = provider.NewRegistry(true)
+		for _, pr := range r.providers {
+			if err := registry.RegisterProviderContext(execCtx.Context.Context, pr); err != nil {
+				return nil, fmt.Errorf("failed to register %s provider: %w", pr.GetName(), err)
+			}
+		}
+	}
+
+	executor, err := r.newExecutor(execCtx.Context, executorConfig, workflow, registry, r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create executor: %w", err)
 	}
 
+	if hooks != nil {
+		if err := hooks.preRun(execCtx.Context.Context, execCtx.Inputs); err != nil {
+			return nil, err
+		}
+	}
+
 	result := ExecutionResult{
 		WorkflowFile: workflow.SourceFile,
 		RunID:        execCtx.RunID,
@@ -270,22 +634,58 @@ func (r *Runner) RunWorkflowRaw(execCtx *execcontext.ExecutionContext, workflow
 		StepsTotal:   len(workflow.Workflow.Steps),
 	}
 
-	err = r.executeWithProgress(executor, execCtx, &result)
+	// the recent events of the run are only kept when a failure would be
+	// triaged, nested workflows fail the step of the workflow running them.
+	var recent *recentEvents
+	if workflow.Triage != nil && len(prefix) == 0 {
+		recent = &recentEvents{}
+	}
+
+	// workflows which don't keep the data of their runs aren't saved for
+	// later runs or resumed, see ast.Workflow.Persistence.
+	keepData := workflow.KeepsRunData()
+
+	err = r.executeWithProgress(executor, execCtx, hooks, recent)
+	if r.modelLock != nil && len(prefix) == 0 {
+		if saveErr := r.modelLock.Save(); saveErr != nil {
+			log.Warn().Err(saveErr).Msg("Failed to save model lock")
+		}
+	}
+	if r.stepStore != nil && len(prefix) == 0 && keepData {
+		if saveErr := r.stepStore.Save(execCtx); saveErr != nil {
+			log.Warn().Err(saveErr).Msg("Failed to save step results")
+		}
+	}
+
+	// a run cancelled between steps stops without an error, its checkpoint
+	// is kept so that it can be resumed
+	if r.checkpoints != nil && len(prefix) == 0 && keepData {
+		if err != nil {
+			err = &ResumableError{RunID: execCtx.RunID, Err: err}
+		} else if execCtx.IsCancelled() {
+			log.Info().Str("run_id", execCtx.RunID).Msg("Run was interrupted, resume it with laq run --resume " + execCtx.RunID)
+		} else if deleteErr := r.checkpoints.Delete(execCtx.RunID); deleteErr != nil {
+			log.Warn().Err(deleteErr).Msg("Failed to remove checkpoint")
+		}
+	}
+
+	result.EndTime = time.Now()
+	result.Duration = result.EndTime.Sub(result.StartTime)
 	if err != nil {
 		result.Status = "failed"
 		result.Error = err.Error()
 
+		if recent != nil {
+			result.Triage = r.triageFailure(executor, execCtx, err, recent)
+		}
+
 		log.Error().
 			Err(err).
 			Str("run_id", execCtx.RunID).
 			Dur("duration", result.Duration).
 			Msg("Workflow execution failed")
-
-		return nil, err
 	} else {
 		result.Status = "completed"
-		result.EndTime = time.Now()
-		result.Duration = result.EndTime.Sub(result.StartTime)
 		result.FinalState = execCtx.GetAllState()
 		result.Outputs = execCtx.GetWorkflowOutputs()
 
@@ -297,9 +697,107 @@ func (r *Runner) RunWorkflowRaw(execCtx *execcontext.ExecutionContext, workflow
 
 	collectExecutionResults(execCtx, &result)
 
+	if hooks != nil {
+		hooks.postRun(execCtx.Context.Context, &result)
+	}
+
+	if r.history != nil && len(prefix) == 0 && workflow.GetPersistence() != ast.PersistenceNone {
+		run := newHistoryRun(workflow, &result)
+		if !keepData {
+			run.Redact()
+		}
+		if saveErr := r.history.Save(run); saveErr != nil {
+			log.Warn().Err(saveErr).Msg("Failed to save run history")
+		}
+	}
+
+	if err != nil {
+		if result.Triage != nil {
+			return &result, &TriagedError{Err: err, Triage: result.Triage}
+		}
+		return &result, err
+	}
+
 	return &result, nil
 }
 
+// triageFailure diagnoses the failed run with the workflow's triage agent.
+// The run has already failed so a triage which fails is only logged.
+func (r *Runner) triageFailure(executor WorkflowExecutor, execCtx *execcontext.ExecutionContext, runErr error, recent *recentEvents) *FailureTriage {
+	triager, ok := executor.(failureTriager)
+	if !ok {
+		return nil
+	}
+
+	triage, err := triager.triageFailure(execCtx, runErr, recent.list())
+	if err != nil {
+		log.Warn().Err(err).Str("run_id", execCtx.RunID).Msg("Failed to triage the failed run")
+		return nil
+	}
+
+	return triage
+}
+
+// configureStepControls applies the partial execution and caching options
+// of the runner to the executor configuration of the top-level workflow.
+func (r *Runner) configureStepControls(config *ExecutorConfig, workflow *ast.Workflow) error {
+	stepIndex := func(id string) (int, error) {
+		for i, step := range workflow.GetSteps() {
+			if step.ID == id {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("step %q does not exist in workflow", id)
+	}
+
+	if r.untilStep != "" {
+		if _, err := stepIndex(r.untilStep); err != nil {
+			return err
+		}
+	}
+
+	if r.fromStep != "" {
+		from, err := stepIndex(r.fromStep)
+		if err != nil {
+			return err
+		}
+
+		if r.untilStep != "" {
+			if until, _ := stepIndex(r.untilStep); until < from {
+				return fmt.Errorf("until step %q comes before from step %q", r.untilStep, r.fromStep)
+			}
+		}
+
+		seed := make(map[string]*CachedStep)
+		if r.stepStore != nil {
+			previous, err := r.stepStore.Load(workflow.SourceFile)
+			if err != nil {
+				return err
+			}
+			seed = previous
+		}
+
+		for id, fixture := range r.stepFixtures {
+			seed[id] = fixture
+		}
+
+		config.FromStep = r.fromStep
+		config.SeedResults = seed
+	}
+
+	config.UntilStep = r.untilStep
+	config.Resume = r.resume
+
+	// the results of steps are only written to disk when the workflow keeps
+	// the data of its runs
+	if workflow.KeepsRunData() {
+		config.StepCache = r.stepCache
+		config.Checkpoints = r.checkpoints
+	}
+
+	return nil
+}
+
 // RunWorkflow parses and executes a workflow file with the given inputs.
 // Handles input validation, default value assignment, and progress tracking.
 func (r *Runner) RunWorkflow(ctx execcontext.RunContext, workflowFile string, inputs map[string]interface{}, prefix ...string) (*ExecutionResult, error) {
@@ -326,17 +824,18 @@ func (r *Runner) RunWorkflow(ctx execcontext.RunContext, workflowFile string, in
 		workflowInputs[k] = v
 	}
 
-	for k, v := range workflow.Inputs {
-		if _, ok := workflowInputs[k]; !ok && v.Default != nil {
-			workflowInputs[k] = v.Default
-		}
-	}
-
 	validationResult := ValidateWorkflowInputs(workflow, workflowInputs)
 	if !validationResult.Valid {
 		return nil, validationResult
 	}
 
+	// apply any default values, including evaluated default expressions
+	for k, v := range validationResult.ProcessedInputs {
+		if _, ok := workflowInputs[k]; !ok {
+			workflowInputs[k] = v
+		}
+	}
+
 	// Show workflow info
 	if !viper.GetBool("quiet") && viper.GetString("output") == "text" {
 		printWorkflowInfo(ctx, workflow)
@@ -345,6 +844,11 @@ func (r *Runner) RunWorkflow(ctx execcontext.RunContext, workflowFile string, in
 	// Create executor with configuration
 	wd := filepath.Dir(workflow.SourceFile)
 	execCtx := execcontext.NewExecutionContext(ctx, workflow, workflowInputs, wd)
+	if len(prefix) == 0 {
+		for k, v := range r.initialState {
+			execCtx.State[k] = v
+		}
+	}
 	if v, ok := r.progressListener.(*CLIProgressTracker); ok {
 		v.totalSteps = len(workflow.Workflow.Steps)
 	}
@@ -352,18 +856,143 @@ func (r *Runner) RunWorkflow(ctx execcontext.RunContext, workflowFile string, in
 	return r.RunWorkflowRaw(execCtx, workflow, startTime, prefix...)
 }
 
-// executeWithProgress runs the workflow executor while sending progress events to registered listeners.
-func (r *Runner) executeWithProgress(executor WorkflowExecutor, execCtx *execcontext.ExecutionContext, _ *ExecutionResult) error {
+// ResumeWorkflow continues a run which failed or was interrupted from the
+// checkpoint it recorded, with the same run ID and inputs. Completed steps
+// are restored from the checkpoint rather than executed again, unless the
+// workflow has changed since.
+func (r *Runner) ResumeWorkflow(ctx execcontext.RunContext, runID string) (*ExecutionResult, error) {
+	startTime := time.Now()
+
+	if r.checkpoints == nil {
+		return nil, fmt.Errorf("cannot resume run %s, checkpoints are not enabled", runID)
+	}
+
+	checkpoint, err := r.checkpoints.Load(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		style.Error(ctx, fmt.Sprintf("Failed to create parser: %v", err))
+		return nil, err
+	}
+
+	workflow, err := yamlParser.ParseFile(checkpoint.WorkflowFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if !viper.GetBool("quiet") && viper.GetString("output") == "text" {
+		printWorkflowInfo(ctx, workflow)
+	}
+
+	wd := filepath.Dir(workflow.SourceFile)
+	execCtx := execcontext.NewExecutionContext(ctx, workflow, checkpoint.Inputs, wd)
+	execCtx.RunID = checkpoint.RunID
+	if v, ok := r.progressListener.(*CLIProgressTracker); ok {
+		v.totalSteps = len(workflow.Workflow.Steps)
+	}
+
+	r.resume = checkpoint
+	defer func() { r.resume = nil }()
+
+	return r.RunWorkflowRaw(execCtx, workflow, startTime)
+}
+
+// executeWithProgress runs the workflow executor while sending progress events to registered listeners
+// and to the post_step hooks, when there are any, keeping the recent events when recent isn't nil.
+func (r *Runner) executeWithProgress(executor WorkflowExecutor, execCtx *execcontext.ExecutionContext, hooks *hookRunner, recent *recentEvents) error {
 	progressChan := make(chan pkgEvents.ExecutionEvent, 100)
+	listenerChan := make(chan pkgEvents.ExecutionEvent, 100)
+
+	var runLog *RunLog
+	if r.runLogDir != "" && execCtx.Workflow.KeepsRunData() {
+		var err error
+		runLog, err = OpenRunLog(r.runLogDir, execCtx.RunID, r.runLogCipher)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to open run log")
+		}
+	}
 
+	var eventLog *RunLog
+	if r.eventLogPath != "" {
+		var err error
+		eventLog, err = OpenEventLog(r.eventLogPath, r.eventLogCipher)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to open event log")
+		}
+	}
+	keepData := execCtx.Workflow.KeepsRunData()
+
+	// listeners receive every event before StopListening is called, a
+	// listener returning early doesn't block the run
+	listened := make(chan struct{})
 	if r.progressListener != nil {
-		go r.progressListener.StartListening(progressChan)
+		go func() {
+			defer close(listened)
+			r.progressListener.StartListening(listenerChan)
+			for range listenerChan {
+			}
+		}()
+	}
+
+	if hooks != nil {
+		hooks.start(execCtx.Context.Context)
 	}
 
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		defer close(listenerChan)
+
+		for event := range progressChan {
+			recent.add(event)
+
+			if runLog != nil {
+				if err := runLog.Write(event); err != nil {
+					log.Warn().Err(err).Msg("Failed to write run log")
+				}
+			}
+
+			if eventLog != nil {
+				logged := event
+				if !keepData {
+					logged = event.Redacted()
+				}
+				if err := eventLog.Write(logged); err != nil {
+					log.Warn().Err(err).Msg("Failed to write event log")
+				}
+			}
+
+			if hooks != nil {
+				hooks.step(event)
+			}
+
+			if r.progressListener != nil {
+				listenerChan <- r.prepareEvent(event, runLog)
+			}
+		}
+	}()
+
 	err := executor.ExecuteWorkflow(execCtx, progressChan)
 	close(progressChan)
+	<-forwarded
+
+	if hooks != nil {
+		hooks.wait()
+	}
+
+	if runLog != nil {
+		_ = runLog.Close()
+	}
+
+	if eventLog != nil {
+		_ = eventLog.Close()
+	}
 
 	if r.progressListener != nil {
+		<-listened
 		r.progressListener.StopListening()
 	}
 
@@ -425,6 +1054,9 @@ func (pt *CLIProgressTracker) StartListening(progressChan <-chan pkgEvents.Execu
 
 		case pkgEvents.EventStepActionFailed:
 			pt.failActionSpinner(event.StepID, event.ActionID)
+
+		case pkgEvents.EventTokenDelta:
+			pt.streamActionOutput(event.StepID, event.ActionID, event.Text)
 		}
 	}
 }
@@ -508,6 +1140,26 @@ func (pt *CLIProgressTracker) createActionSpinner(stepID string, actionID string
 	}
 }
 
+// streamActionOutput shows the model output streamed for a running action
+// under it.
+func (pt *CLIProgressTracker) streamActionOutput(stepID string, actionID string, text string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if state, exists := pt.steps[stepID]; exists {
+		state.mu.Lock()
+		for i := range state.actions {
+			if state.actions[i].id == actionID {
+				state.actions[i].appendOutput(text)
+				break
+			}
+		}
+
+		state.spinner.SetSuffix(state.String())
+		state.mu.Unlock()
+	}
+}
+
 // completeActionSpinner marks an action as completed with a success icon.
 func (pt *CLIProgressTracker) completeActionSpinner(stepID string, actionID string, diagnostics ...string) {
 	pt.mu.Lock()
@@ -520,6 +1172,7 @@ func (pt *CLIProgressTracker) completeActionSpinner(stepID string, actionID stri
 				continue
 			}
 
+			state.actions[i].output = ""
 			if len(diagnostics) > 0 {
 				state.actions[i].text = style.SuccessIcon() + " " + strings.TrimSpace(strings.ReplaceAll(action.text, "...", "")) + "\n\n"
 				for _, v := range diagnostics {
@@ -549,6 +1202,7 @@ func (pt *CLIProgressTracker) failActionSpinner(stepID string, actionID string)
 		for i, action := range state.actions {
 			if action.id == actionID {
 				state.actions[i].text = style.ErrorIcon() + " " + strings.TrimSpace(strings.ReplaceAll(action.text, "...", ""))
+				state.actions[i].output = ""
 				break
 			}
 		}
@@ -620,6 +1274,25 @@ func collectExecutionResults(execCtx *execcontext.ExecutionContext, result *Exec
 			Output:    step.Output,
 			Response:  step.Response,
 			Retries:   step.Retries,
+			Repairs:   step.Repairs,
+			Routing:   step.Routing,
+			Prompt:    step.Prompt,
+		}
+		stepResult.Environment = step.Environment
+
+		if step.RepairUsage != nil {
+			stepResult.RepairUsage = &TokenUsage{
+				PromptTokens:     step.RepairUsage.PromptTokens,
+				CompletionTokens: step.RepairUsage.CompletionTokens,
+				TotalTokens:      step.RepairUsage.TotalTokens,
+				EstimatedCost:    step.RepairUsage.Cost,
+			}
+			tokenSummary.RepairTokens += step.RepairUsage.TotalTokens
+		}
+
+		if workflowStep, ok := execCtx.Workflow.GetStep(step.StepID); ok {
+			stepResult.StepType = workflowStep.GetStepType()
+			stepResult.CostCenter, stepResult.Owner = execCtx.Workflow.StepCostLabels(workflowStep)
 		}
 
 		if step.Error != nil {
@@ -631,20 +1304,106 @@ func collectExecutionResults(execCtx *execcontext.ExecutionContext, result *Exec
 				PromptTokens:     step.TokenUsage.PromptTokens,
 				CompletionTokens: step.TokenUsage.CompletionTokens,
 				TotalTokens:      step.TokenUsage.TotalTokens,
+				EstimatedCost:    step.TokenUsage.Cost,
 			}
 
 			// Aggregate token usage
 			tokenSummary.PromptTokens += step.TokenUsage.PromptTokens
 			tokenSummary.CompletionTokens += step.TokenUsage.CompletionTokens
 			tokenSummary.TotalTokens += step.TokenUsage.TotalTokens
+			tokenSummary.EstimatedCost += step.TokenUsage.Cost
 		}
 
 		result.StepResults = append(result.StepResults, stepResult)
 	}
 
+	for key, usage := range summary.ModelUsage {
+		if tokenSummary.Models == nil {
+			tokenSummary.Models = make(map[string]*TokenUsage, len(summary.ModelUsage))
+		}
+		tokenSummary.Models[key] = &TokenUsage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+			EstimatedCost:    usage.Cost,
+		}
+	}
+
 	if tokenSummary.TotalTokens > 0 {
 		result.TokenUsage = tokenSummary
 	}
+	result.Prompts = summary.PromptVersions
+}
+
+// newHistoryRun converts the result of a top-level run into its record in
+// the run history.
+func newHistoryRun(workflow *ast.Workflow, result *ExecutionResult) *history.Run {
+	run := &history.Run{
+		RunID:        result.RunID,
+		Workflow:     getWorkflowName(workflow),
+		WorkflowFile: result.WorkflowFile,
+		Status:       result.Status,
+		StartTime:    result.StartTime,
+		EndTime:      result.EndTime,
+		Duration:     result.Duration,
+		Error:        result.Error,
+		Inputs:       result.Inputs,
+		Outputs:      result.Outputs,
+		State:        result.FinalState,
+		Prompts:      result.Prompts,
+	}
+
+	if workflow.Metadata != nil {
+		run.CostCenter = workflow.Metadata.CostCenter
+		run.Owner = workflow.Metadata.Owner
+	}
+
+	if result.Triage != nil {
+		run.Triage = &history.Triage{
+			StepID:       result.Triage.StepID,
+			Diagnosis:    result.Triage.Diagnosis,
+			SuggestedFix: result.Triage.SuggestedFix,
+		}
+	}
+
+	for _, stepResult := range result.StepResults {
+		step := history.Step{
+			StepID:     stepResult.StepID,
+			Status:     stepResult.Status,
+			Duration:   stepResult.Duration,
+			Error:      stepResult.Error,
+			CostCenter: stepResult.CostCenter,
+			Owner:      stepResult.Owner,
+			Prompt:     stepResult.Prompt,
+		}
+
+		if env := stepResult.Environment; env != nil {
+			step.Environment = &history.Environment{
+				Runtimes:      env.Runtimes,
+				Image:         env.Image,
+				ImageDigest:   env.ImageDigest,
+				Block:         env.Block,
+				BlockDigest:   env.BlockDigest,
+				Provider:      env.Provider,
+				Model:         env.Model,
+				ModelSnapshot: env.ModelSnapshot,
+			}
+		}
+
+		if stepResult.TokenUsage != nil {
+			step.Usage = history.Usage{
+				PromptTokens:     stepResult.TokenUsage.PromptTokens,
+				CompletionTokens: stepResult.TokenUsage.CompletionTokens,
+				TotalTokens:      stepResult.TokenUsage.TotalTokens,
+				Cost:             stepResult.TokenUsage.EstimatedCost,
+			}
+		}
+
+		run.Usage.Add(step.Usage)
+		run.Steps = append(run.Steps, step)
+	}
+
+	return run
 }
 
 // printWorkflowInfo displays workflow metadata including name and step count.
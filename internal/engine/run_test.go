@@ -3,15 +3,20 @@ package engine
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/gkampitakis/go-snaps/snaps"
 	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/encryption"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/history"
 	"github.com/lacquerai/lacquer/internal/provider"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 	"github.com/rs/zerolog/log"
@@ -145,3 +150,381 @@ func TestRunWorkflow_WithProgressTracker(t *testing.T) {
 
 	snaps.MatchSnapshot(t, out.String())
 }
+
+// recordingListener collects every event it receives.
+type recordingListener struct {
+	events []pkgEvents.ExecutionEvent
+	done   chan struct{}
+}
+
+func (l *recordingListener) StartListening(progressChan <-chan pkgEvents.ExecutionEvent) {
+	for event := range progressChan {
+		l.events = append(l.events, event)
+	}
+	close(l.done)
+}
+
+func (l *recordingListener) StopListening() {
+	<-l.done
+}
+
+func TestRunWorkflow_EventText(t *testing.T) {
+	longText := "\x1b[1m" + strings.Repeat("日本語", 10) + "\x1b[0m"
+
+	runEvents := []pkgEvents.ExecutionEvent{
+		{
+			Type:     pkgEvents.EventStepActionStarted,
+			StepID:   "test_step",
+			ActionID: "turn-0",
+			Text:     longText,
+		},
+		{
+			Type:     pkgEvents.EventStepActionStarted,
+			StepID:   "test_step",
+			ActionID: "turn-1",
+			Text:     "short",
+		},
+	}
+
+	ctx := execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  os.Stdout,
+		StdErr:  os.Stderr,
+	}
+	workflowFile := filepath.Join("testdata", "basic_workflow.laq.yml")
+	inputs := map[string]interface{}{
+		"name": "World",
+	}
+
+	t.Run("truncates and strips styling", func(t *testing.T) {
+		logDir := t.TempDir()
+		listener := &recordingListener{done: make(chan struct{})}
+		runner := NewRunner(listener,
+			WithExecutorFunc(mockExecutorFunc(runEvents)),
+			WithPreviewLength(10),
+			WithPlainEvents(),
+			WithRunLog(logDir, nil),
+		)
+
+		result, err := runner.RunWorkflow(ctx, workflowFile, inputs)
+		require.NoError(t, err)
+
+		require.Len(t, listener.events, 2)
+		assert.Equal(t, "日本語日本語日本語日...", listener.events[0].Text)
+		assert.Equal(t, true, listener.events[0].Metadata["truncated"])
+		assert.Equal(t, filepath.Join(logDir, result.RunID+".jsonl"), listener.events[0].Metadata["run_log"])
+		assert.Equal(t, "short", listener.events[1].Text)
+		assert.Nil(t, listener.events[1].Metadata)
+
+		data, err := os.ReadFile(filepath.Join(logDir, result.RunID+".jsonl"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), strings.Repeat("日本語", 10))
+		assert.NotContains(t, string(data), "\\u001b")
+	})
+
+	t.Run("encrypts the run log", func(t *testing.T) {
+		cipher, err := encryption.NewCipher(make([]byte, encryption.KeySize))
+		require.NoError(t, err)
+
+		logDir := t.TempDir()
+		runner := NewRunner(nil,
+			WithExecutorFunc(mockExecutorFunc(runEvents)),
+			WithRunLog(logDir, cipher),
+		)
+
+		result, err := runner.RunWorkflow(ctx, workflowFile, inputs)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(logDir, result.RunID+".jsonl"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "日本語")
+		assert.NotContains(t, string(data), "short")
+
+		var texts []string
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			opened, err := cipher.OpenLine([]byte(line))
+			require.NoError(t, err)
+
+			var event pkgEvents.ExecutionEvent
+			require.NoError(t, json.Unmarshal(opened, &event))
+			texts = append(texts, event.Text)
+		}
+		assert.Contains(t, texts, "short")
+	})
+
+	t.Run("keeps styling for terminals", func(t *testing.T) {
+		listener := &recordingListener{done: make(chan struct{})}
+		runner := NewRunner(listener,
+			WithExecutorFunc(mockExecutorFunc(runEvents)),
+			WithPreviewLength(0),
+		)
+
+		_, err := runner.RunWorkflow(ctx, workflowFile, inputs)
+		require.NoError(t, err)
+
+		require.Len(t, listener.events, 2)
+		assert.Equal(t, longText, listener.events[0].Text)
+	})
+}
+
+func TestRunWorkflow_ListenerDelivery(t *testing.T) {
+	runEvents := make([]pkgEvents.ExecutionEvent, 500)
+	for i := range runEvents {
+		runEvents[i] = pkgEvents.ExecutionEvent{Type: pkgEvents.EventStepProgress, StepID: "test_step", StepIndex: i}
+	}
+
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	workflowFile := filepath.Join("testdata", "basic_workflow.laq.yml")
+
+	t.Run("every event is handled before the run returns", func(t *testing.T) {
+		var received []int
+		sink := pkgEvents.NewCallbackSink(func(event pkgEvents.ExecutionEvent) {
+			received = append(received, event.StepIndex)
+		})
+		runner := NewRunner(sink, WithExecutorFunc(mockExecutorFunc(runEvents)))
+
+		_, err := runner.RunWorkflow(ctx, workflowFile, map[string]interface{}{"name": "World"})
+		require.NoError(t, err)
+
+		require.Len(t, received, len(runEvents))
+		for i, index := range received {
+			assert.Equal(t, i, index)
+		}
+	})
+
+	t.Run("a listener returning early doesn't block the run", func(t *testing.T) {
+		runner := NewRunner(&pkgEvents.NoopListener{}, WithExecutorFunc(mockExecutorFunc(runEvents)))
+
+		_, err := runner.RunWorkflow(ctx, workflowFile, map[string]interface{}{"name": "World"})
+		require.NoError(t, err)
+	})
+}
+
+func TestRunWorkflow_EventLog(t *testing.T) {
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+
+	readEventLog := func(t *testing.T, path string) []pkgEvents.ExecutionEvent {
+		data, err := os.ReadFile(path) // #nosec G304 - test file path is controlled
+		require.NoError(t, err)
+
+		var events []pkgEvents.ExecutionEvent
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var event pkgEvents.ExecutionEvent
+			require.NoError(t, json.Unmarshal([]byte(line), &event))
+			events = append(events, event)
+		}
+		return events
+	}
+
+	for _, persistence := range []string{ast.PersistenceFull, ast.PersistenceNone} {
+		t.Run(persistence, func(t *testing.T) {
+			dir := t.TempDir()
+			workflowFile := filepath.Join(dir, "workflow.laq.yml")
+			require.NoError(t, os.WriteFile(workflowFile, []byte(`version: "1.0"
+persistence: `+persistence+`
+inputs:
+  email:
+    type: string
+workflow:
+  steps:
+    - id: lookup
+      run: echo "no account for ${{ inputs.email }}" >&2 && exit 1
+`), 0600))
+
+			eventLog := filepath.Join(dir, "events", "runs.jsonl")
+			runner := NewRunner(nil, WithEventLog(eventLog, nil))
+
+			// the events of every run are appended to the log
+			first, err := runner.RunWorkflow(ctx, workflowFile, map[string]interface{}{"email": "jane@example.com"})
+			require.Error(t, err)
+			second, err := runner.RunWorkflow(ctx, workflowFile, map[string]interface{}{"email": "jane@example.com"})
+			require.Error(t, err)
+
+			events := readEventLog(t, eventLog)
+			runs := map[string][]pkgEvents.ExecutionEventType{}
+			for _, event := range events {
+				runs[event.RunID] = append(runs[event.RunID], event.Type)
+			}
+			require.Len(t, runs, 2)
+			assert.Equal(t, runs[first.RunID], runs[second.RunID])
+			assert.Equal(t, pkgEvents.EventWorkflowStarted, runs[first.RunID][0])
+			assert.Equal(t, pkgEvents.EventWorkflowFailed, runs[first.RunID][len(runs[first.RunID])-1])
+
+			// the errors of runs which aren't kept are redacted
+			data, err := os.ReadFile(eventLog) // #nosec G304 - test file path is controlled
+			require.NoError(t, err)
+			if persistence == ast.PersistenceNone {
+				assert.NotContains(t, string(data), "jane@example.com")
+			} else {
+				assert.Contains(t, string(data), "jane@example.com")
+			}
+		})
+	}
+}
+
+func TestRunWorkflow_RunHistory(t *testing.T) {
+	dir := t.TempDir()
+	workflowFile := filepath.Join(dir, "workflow.laq.yml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(`version: "1.0"
+metadata:
+  name: chargeback
+  cost_center: support
+  owner: cx
+inputs:
+  fail:
+    type: boolean
+    default: false
+workflow:
+  steps:
+    - id: greet
+      run: echo "hello"
+    - id: report
+      cost_center: finance
+      run: |
+        if [ "${{ inputs.fail }}" = "true" ]; then exit 1; fi
+        echo "done"
+`), 0600))
+
+	store := history.NewStore(filepath.Join(dir, "history"))
+	runner := NewRunner(nil, WithRunHistory(store))
+
+	ctx := execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+	}
+
+	result, err := runner.RunWorkflow(ctx, workflowFile, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, "finance", result.StepResults[1].CostCenter)
+	assert.Equal(t, "cx", result.StepResults[1].Owner)
+
+	_, err = runner.RunWorkflow(ctx, workflowFile, map[string]interface{}{"fail": true})
+	require.Error(t, err)
+
+	runs, err := store.List(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	assert.Equal(t, result.RunID, runs[0].RunID)
+	assert.Equal(t, "chargeback", runs[0].Workflow)
+	assert.Equal(t, "completed", runs[0].Status)
+	assert.Equal(t, "support", runs[0].CostCenter)
+	require.Len(t, runs[0].Steps, 2)
+	assert.Equal(t, history.Step{StepID: "greet", Status: "completed", Duration: runs[0].Steps[0].Duration, CostCenter: "support", Owner: "cx"}, runs[0].Steps[0])
+	assert.Equal(t, "finance", runs[0].Steps[1].CostCenter)
+
+	// failed runs are recorded as they may have spent tokens before failing
+	assert.Equal(t, "failed", runs[1].Status)
+	assert.NotEmpty(t, runs[1].Error)
+	require.Len(t, runs[1].Steps, 2)
+	assert.Equal(t, "failed", runs[1].Steps[1].Status)
+}
+
+func TestRunWorkflow_InitialState(t *testing.T) {
+	dir := t.TempDir()
+	workflowFile := filepath.Join(dir, "workflow.laq.yml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(`version: "1.0"
+workflow:
+  state:
+    runs: 0
+    last: ""
+  steps:
+    - id: count
+      run: echo "counted"
+      updates:
+        runs: ${{ state.runs + 1 }}
+`), 0600))
+
+	ctx := execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+	}
+
+	store := history.NewStore(filepath.Join(dir, "history"))
+	result, err := NewRunner(nil, WithRunHistory(store)).RunWorkflow(ctx, workflowFile, map[string]interface{}{})
+	require.NoError(t, err)
+
+	// the final state of the first run seeds the second
+	runs, err := store.List(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, result.FinalState, runs[0].State)
+
+	state := runs[0].State
+	state["last"] = result.RunID
+	result, err = NewRunner(nil, WithInitialState(state)).RunWorkflow(ctx, workflowFile, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, result.FinalState["runs"])
+	assert.Equal(t, runs[0].RunID, result.FinalState["last"])
+}
+
+func TestRunWorkflow_Persistence(t *testing.T) {
+	ctx := execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+	}
+
+	for _, persistence := range []string{ast.PersistenceMetadata, ast.PersistenceNone} {
+		t.Run(persistence, func(t *testing.T) {
+			dir := t.TempDir()
+			workflowFile := filepath.Join(dir, "workflow.laq.yml")
+			require.NoError(t, os.WriteFile(workflowFile, []byte(`version: "1.0"
+persistence: `+persistence+`
+inputs:
+  email:
+    type: string
+workflow:
+  steps:
+    - id: greet
+      run: echo "hello ${{ inputs.email }}"
+  outputs:
+    greeting: ${{ steps.greet.output }}
+`), 0600))
+
+			store := history.NewStore(filepath.Join(dir, "history"))
+			checkpoints := NewCheckpointStore(filepath.Join(dir, "checkpoints"), nil)
+			logDir := filepath.Join(dir, "logs")
+			runner := NewRunner(nil, WithRunHistory(store), WithCheckpoints(checkpoints), WithRunLog(logDir, nil))
+
+			result, err := runner.RunWorkflow(ctx, workflowFile, map[string]interface{}{"email": "jane@example.com"})
+			require.NoError(t, err)
+			assert.Contains(t, result.Outputs["greeting"], "jane@example.com")
+
+			// nothing but the metadata of the run is written to disk
+			assert.NoDirExists(t, logDir)
+			assert.NoDirExists(t, filepath.Join(dir, "checkpoints"))
+
+			runs, err := store.List(time.Time{})
+			require.NoError(t, err)
+			if persistence == ast.PersistenceNone {
+				assert.Empty(t, runs)
+				return
+			}
+
+			require.Len(t, runs, 1)
+			assert.Equal(t, result.RunID, runs[0].RunID)
+			assert.Equal(t, "completed", runs[0].Status)
+			assert.Nil(t, runs[0].Inputs)
+			assert.Nil(t, runs[0].Outputs)
+			assert.Nil(t, runs[0].State)
+			require.Len(t, runs[0].Steps, 1)
+			assert.Equal(t, "greet", runs[0].Steps[0].StepID)
+		})
+	}
+}
+
+func TestActionState_StreamedOutput(t *testing.T) {
+	action := ActionState{id: "a", text: "Running..."}
+	action.appendOutput("first\nsecond\n")
+	action.appendOutput("third\nfou")
+	action.appendOutput("rth")
+	assert.Equal(t, []string{"second", "third", "fourth"}, action.outputTail(80))
+
+	// only the tail of long output is kept, without splitting characters
+	action.appendOutput(strings.Repeat("é", maxStreamedOutput))
+	assert.LessOrEqual(t, len(action.output), maxStreamedOutput)
+	assert.True(t, strings.HasPrefix(action.output, "é"))
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/provider"
+)
+
+// agentSession is the conversation of the agent steps sharing a session.
+type agentSession struct {
+	// mu makes the steps of the session take turns so each continues the
+	// conversation where the previous one left it.
+	mu       sync.Mutex
+	messages []provider.Message
+}
+
+// agentSession returns the session with the given name, starting it when
+// no step has used it yet.
+func (e *Executor) agentSession(name string) *agentSession {
+	e.sessionsMu.Lock()
+	defer e.sessionsMu.Unlock()
+
+	if e.sessions == nil {
+		e.sessions = make(map[string]*agentSession)
+	}
+
+	session, ok := e.sessions[name]
+	if !ok {
+		session = &agentSession{}
+		e.sessions[name] = session
+	}
+
+	return session
+}
+
+// update records the conversation of a step of the session which
+// succeeded, the caller must hold the session's lock.
+func (s *agentSession) update(messages []provider.Message) {
+	if s == nil {
+		return
+	}
+
+	s.messages = messages
+}
+
+// resumableMessages returns the provider's session of the last message
+// generated in one, along with the messages sent since. Without a session
+// every message is returned.
+func resumableMessages(messages []provider.Message) (string, []provider.Message) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].SessionID != "" {
+			return messages[i].SessionID, messages[i+1:]
+		}
+	}
+
+	return "", messages
+}
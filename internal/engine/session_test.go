@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecuteWorkflow_AgentSession(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewTextBlock("func add(a, b int) int { return a + b }"),
+			provider.NewTextBlock("Reviewed"),
+			provider.NewTextBlock("func sum(nums ...int) int { ... }"),
+		},
+	}
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "write", Agent: "coder", Prompt: "Write an add function", Session: "code"},
+		{ID: "review", Agent: "coder", Prompt: "Review this code"},
+		{ID: "refactor", Agent: "coder", Prompt: "Now refactor what you just wrote", Session: "code"},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"coder": {Name: "coder", Provider: pr.name, Model: "test-model"},
+	}
+
+	_, _, err := runTestWorkflow(t, workflow, nil, withProviders(pr))
+	require.NoError(t, err)
+	require.Len(t, pr.requests, 3)
+
+	// the step outside the session starts a new conversation
+	assert.Len(t, pr.requests[1].Messages, 1)
+
+	refactor := pr.requests[2].Messages
+	require.Len(t, refactor, 3)
+	assert.Equal(t, "Write an add function", getLastContentBlock(refactor[:1]))
+	assert.Equal(t, "func add(a, b int) int { return a + b }", getLastContentBlock(refactor[1:2]))
+	assert.Equal(t, "Now refactor what you just wrote", getLastContentBlock(refactor))
+}
+
+func TestResumableMessages(t *testing.T) {
+	text := func(role, text, sessionID string) provider.Message {
+		return provider.Message{
+			Role:      role,
+			Content:   []provider.ContentBlockParamUnion{provider.NewTextBlock(text)},
+			SessionID: sessionID,
+		}
+	}
+
+	messages := []provider.Message{
+		text("user", "Write an add function", ""),
+		text("assistant", "Done", "session-1"),
+		text("user", "Now refactor it", ""),
+	}
+
+	sessionID, pending := resumableMessages(messages)
+	assert.Equal(t, "session-1", sessionID)
+	assert.Equal(t, messages[2:], pending)
+
+	sessionID, pending = resumableMessages(messages[:1])
+	assert.Empty(t, sessionID)
+	assert.Equal(t, messages[:1], pending)
+}
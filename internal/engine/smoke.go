@@ -0,0 +1,375 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/kv"
+	"github.com/lacquerai/lacquer/internal/schema"
+)
+
+// SmokeIssue is a template of a workflow which failed to render in a smoke
+// test, or an input no valid sample could be found for.
+type SmokeIssue struct {
+	// Path is where the template is in the workflow, e.g.
+	// workflow.steps[0].prompt
+	Path    string
+	Message string
+}
+
+// SmokeTest renders every prompt and expression of a workflow, without
+// running any of its steps, to catch the errors of templates static
+// validation can't, such as calls to functions with the wrong arguments or
+// an input of the wrong type. Inputs are given plausible sample values
+// following their schemas, and steps are assumed to have completed with
+// sample outputs. Templates which depend on what a step outputs at run time
+// may still fail when the workflow runs.
+func SmokeTest(workflow *ast.Workflow) ([]SmokeIssue, error) {
+	var issues []SmokeIssue
+
+	samples, err := SampleInputs(workflow)
+	if err != nil {
+		return nil, err
+	}
+	validation := ValidateWorkflowInputs(workflow, samples)
+	for _, inputErr := range validation.Errors {
+		issues = append(issues, SmokeIssue{
+			Path:    "inputs." + inputErr.Field,
+			Message: fmt.Sprintf("sample input %v is invalid: %s", inputErr.Value, inputErr.Message),
+		})
+	}
+	if len(issues) > 0 {
+		return issues, nil
+	}
+
+	kvDir, err := os.MkdirTemp("", "laq-smoke-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create smoke test store: %w", err)
+	}
+	defer os.RemoveAll(kvDir)
+
+	runCtx := execcontext.RunContext{Context: context.Background()}
+	execCtx := execcontext.NewExecutionContext(runCtx, workflow, validation.ProcessedInputs, filepath.Dir(workflow.SourceFile))
+	execCtx.KV = kv.NewStore(kvDir, nil).Bucket(workflow.SourceFile)
+	execCtx.Each = map[string]interface{}{"item": "sample item", "index": 0}
+	execCtx.Retry = map[string]interface{}{"attempt": 1, "error": "sample error"}
+
+	smoke := &smokeTest{
+		workflow:       workflow,
+		execCtx:        execCtx,
+		templateEngine: newSmokeTemplateEngine(),
+	}
+
+	root := reflect.ValueOf(workflow).Elem()
+	for _, field := range []string{"Agents", "Prompts", "Workflow"} {
+		smoke.collectSteps(root.FieldByName(field))
+	}
+	for _, field := range []string{"Agents", "Prompts", "Workflow"} {
+		structField, _ := root.Type().FieldByName(field)
+		smoke.render(root.FieldByName(field), yamlName(structField))
+	}
+
+	return append(issues, smoke.issues...), nil
+}
+
+type smokeTest struct {
+	workflow       *ast.Workflow
+	execCtx        *execcontext.ExecutionContext
+	templateEngine *expression.TemplateEngine
+	issues         []SmokeIssue
+}
+
+// newSmokeTemplateEngine returns a template engine whose functions calling
+// agents return sample values instead.
+func newSmokeTemplateEngine() *expression.TemplateEngine {
+	templateEngine := expression.NewTemplateEngine()
+	templateEngine.RegisterFunction(&expression.FunctionDefinition{
+		Name: "detectLanguage",
+		Impl: func(args []interface{}, _ *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("detectLanguage() requires 1 or 2 arguments")
+			}
+			return "en", nil
+		},
+	})
+	templateEngine.RegisterFunction(&expression.FunctionDefinition{
+		Name: "translate",
+		Impl: func(args []interface{}, _ *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("translate() requires 2 or 3 arguments")
+			}
+			return expression.ValueToString(args[0]), nil
+		},
+	})
+	return templateEngine
+}
+
+var stepType = reflect.TypeOf(ast.Step{})
+
+// collectSteps marks every step of the workflow, including sub steps, as
+// completed with sample outputs, and sets the state they update, so that
+// templates can reference them whatever their order.
+func (s *smokeTest) collectSteps(v reflect.Value) {
+	walkValue(v, "", func(v reflect.Value, _ string) {
+		if v.Type() != stepType {
+			return
+		}
+
+		step := v.Addr().Interface().(*ast.Step)
+		outputs := make(map[string]interface{}, len(step.Outputs))
+		for name, output := range step.Outputs {
+			outputs[name] = sampleSchemaValue(name, output)
+		}
+		s.execCtx.SetStepResult(step.ID, &execcontext.StepResult{
+			StepID:   step.ID,
+			Status:   execcontext.StepStatusCompleted,
+			Output:   map[string]interface{}{"output": "sample output of " + step.ID, "outputs": outputs},
+			Response: "sample output of " + step.ID,
+		})
+
+		for key := range step.Updates {
+			if _, ok := s.execCtx.GetState(key); !ok {
+				s.execCtx.UpdateState(map[string]interface{}{key: "sample " + key})
+			}
+		}
+	})
+}
+
+// render renders every template found in v, recording those which fail.
+func (s *smokeTest) render(v reflect.Value, path string) {
+	walkValue(v, path, func(v reflect.Value, path string) {
+		if v.Kind() != reflect.String || !strings.Contains(v.String(), "${{") {
+			return
+		}
+
+		if _, err := s.templateEngine.Render(v.String(), s.execCtx); err != nil {
+			s.issues = append(s.issues, SmokeIssue{Path: path, Message: err.Error()})
+		}
+	})
+}
+
+// walkValue calls visit with every value reachable from v and its path in
+// the workflow's YAML, e.g. workflow.steps[0].prompt. Map keys are visited
+// in order.
+func walkValue(v reflect.Value, path string, visit func(reflect.Value, string)) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			walkValue(v.Elem(), path, visit)
+		}
+		return
+	}
+
+	visit(v, path)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			name := yamlName(field)
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			walkValue(v.Field(i), joinPath(path, name), visit)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			walkValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, key := range keys {
+			walkValue(v.MapIndex(key), joinPath(path, fmt.Sprint(key.Interface())), visit)
+		}
+	}
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// SampleInputs returns a plausible value for each input of a workflow
+// which hasn't got a default: the first of its allowed values, a string
+// matching its pattern or a number within its bounds. Inputs whose default
+// is an expression are left out, so that the expression is evaluated.
+func SampleInputs(workflow *ast.Workflow) (map[string]interface{}, error) {
+	samples := make(map[string]interface{}, len(workflow.Inputs))
+	for name, param := range workflow.Inputs {
+		if param.Default != nil {
+			continue
+		}
+
+		sample, err := sampleInput(name, param)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", name, err)
+		}
+		samples[name] = sample
+	}
+
+	return samples, nil
+}
+
+func sampleInput(name string, param *ast.InputParam) (interface{}, error) {
+	if len(param.Enum) > 0 {
+		if param.Multiple {
+			return []interface{}{param.Enum[0].Value}, nil
+		}
+		return param.Enum[0].Value, nil
+	}
+
+	switch param.GetTypeString() {
+	case "integer":
+		value := 1.0
+		if param.Minimum != nil && value < *param.Minimum {
+			value = math.Ceil(*param.Minimum)
+		}
+		if param.Maximum != nil && value > *param.Maximum {
+			value = math.Floor(*param.Maximum)
+		}
+		return int(value), nil
+	case "boolean":
+		return true, nil
+	case "array":
+		items := 1
+		if param.MinItems != nil {
+			items = max(items, *param.MinItems)
+		}
+		if param.MaxItems != nil {
+			items = min(items, *param.MaxItems)
+		}
+		sample := make([]interface{}, items)
+		for i := range sample {
+			sample[i] = fmt.Sprintf("sample %s %d", name, i+1)
+		}
+		return sample, nil
+	case "object":
+		return map[string]interface{}{"name": "sample " + name}, nil
+	}
+
+	if param.Pattern != "" {
+		return samplePattern(param.Pattern)
+	}
+	return "sample " + name, nil
+}
+
+// samplePattern returns a string matching a regular expression, taking the
+// first alternative and the fewest repetitions of each part of it.
+func samplePattern(pattern string) (string, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+
+	var sb strings.Builder
+	writeSample(&sb, re.Simplify())
+
+	sample := sb.String()
+	if matched, _ := regexp.MatchString(pattern, sample); !matched {
+		return "", fmt.Errorf("no sample matching pattern %s could be generated", pattern)
+	}
+	return sample, nil
+}
+
+func writeSample(sb *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		sb.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		sb.WriteRune(sampleRune(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sb.WriteRune('a')
+	case syntax.OpCapture, syntax.OpPlus:
+		writeSample(sb, re.Sub[0])
+	case syntax.OpRepeat:
+		for range re.Min {
+			writeSample(sb, re.Sub[0])
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writeSample(sb, sub)
+		}
+	case syntax.OpAlternate:
+		writeSample(sb, re.Sub[0])
+	}
+}
+
+// sampleRune returns a rune of a character class, given as pairs of the
+// lowest and highest rune of its ranges, preferring letters and digits.
+func sampleRune(ranges []rune) rune {
+	for _, preferred := range "aA0" {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= preferred && preferred <= ranges[i+1] {
+				return preferred
+			}
+		}
+	}
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := ranges[i]; r <= ranges[i+1] && r < ranges[i]+128; r++ {
+			if unicode.IsPrint(r) && !unicode.IsSpace(r) {
+				return r
+			}
+		}
+	}
+	if len(ranges) > 0 {
+		return ranges[0]
+	}
+	return 'a'
+}
+
+// sampleSchemaValue returns a sample value of a JSON schema, lists have an
+// item so that they can be indexed.
+func sampleSchemaValue(name string, s schema.JSON) interface{} {
+	if len(s.Enum) > 0 {
+		return s.Enum[0]
+	}
+
+	schemaType, _ := s.Type.(string)
+	switch schemaType {
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return true
+	case "array":
+		var item schema.JSON
+		if items, ok := s.Items.(schema.JSON); ok {
+			item = items
+		} else if items, ok := s.Items.(*schema.JSON); ok && items != nil {
+			item = *items
+		}
+		return []interface{}{sampleSchemaValue(name, item)}
+	case "object":
+		object := make(map[string]interface{}, len(s.Properties))
+		for property, propertySchema := range s.Properties {
+			object[property] = sampleSchemaValue(property, propertySchema)
+		}
+		return object
+	default:
+		return "sample " + name
+	}
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleInputs(t *testing.T) {
+	minimum, maximum := 5.5, 10.0
+	minItems := 2
+	workflow := &ast.Workflow{
+		Inputs: map[string]*ast.InputParam{
+			"ticket":   {Type: "string", Pattern: `^[A-Z]{3}-\d+$`},
+			"email":    {Type: "string", Pattern: `^[\w.]+@(example|test)\.com$`},
+			"name":     {Type: "string"},
+			"tone":     {Type: "string", Enum: []ast.EnumOption{{Value: "formal"}, {Value: "casual"}}},
+			"count":    {Type: "integer", Minimum: &minimum, Maximum: &maximum},
+			"tags":     {Type: "array", MinItems: &minItems},
+			"verbose":  {Type: "boolean"},
+			"language": {Type: "string", Default: "en"},
+		},
+	}
+
+	samples, err := SampleInputs(workflow)
+	require.NoError(t, err)
+
+	assert.Regexp(t, regexp.MustCompile(`^[A-Z]{3}-\d+$`), samples["ticket"])
+	assert.Regexp(t, regexp.MustCompile(`^[\w.]+@(example|test)\.com$`), samples["email"])
+	assert.Equal(t, "sample name", samples["name"])
+	assert.Equal(t, "formal", samples["tone"])
+	assert.Equal(t, 6, samples["count"])
+	assert.Len(t, samples["tags"], 2)
+	assert.Equal(t, true, samples["verbose"])
+	assert.NotContains(t, samples, "language")
+
+	result := ValidateWorkflowInputs(workflow, samples)
+	assert.True(t, result.Valid, result.Errors)
+}
+
+func TestSmokeTest(t *testing.T) {
+	t.Run("valid templates", func(t *testing.T) {
+		workflow := &ast.Workflow{
+			Inputs: map[string]*ast.InputParam{
+				"topic": {Type: "string", Required: true},
+				"items": {Type: "array"},
+			},
+			Agents: map[string]*ast.Agent{
+				"writer": {SystemPrompt: "You write about ${{ inputs.topic }}"},
+			},
+			Workflow: &ast.WorkflowDef{
+				Steps: []*ast.Step{
+					{ID: "research", Agent: "writer", Prompt: "Research ${{ format('{0}!', inputs.topic) }}"},
+					{ID: "write", Agent: "writer", Prompt: "Write ${{ join(inputs.items, ', ') }} from ${{ steps.research.output }}"},
+				},
+				Outputs: map[string]any{"article": "${{ steps.write.output }}"},
+			},
+		}
+
+		issues, err := SmokeTest(workflow)
+		require.NoError(t, err)
+		assert.Empty(t, issues)
+	})
+
+	t.Run("invalid templates", func(t *testing.T) {
+		workflow := &ast.Workflow{
+			Inputs: map[string]*ast.InputParam{
+				"topic": {Type: "string", Required: true},
+			},
+			Workflow: &ast.WorkflowDef{
+				Steps: []*ast.Step{
+					{ID: "parse", Agent: "writer", Prompt: "Parse ${{ fromJSON(inputs.topic).title }}"},
+					{ID: "write", Agent: "writer", Prompt: "Write ${{ nosuchfunction(inputs.topic) }}"},
+				},
+			},
+		}
+
+		issues, err := SmokeTest(workflow)
+		require.NoError(t, err)
+		require.Len(t, issues, 2)
+		assert.Equal(t, "workflow.steps[0].prompt", issues[0].Path)
+		assert.Equal(t, "workflow.steps[1].prompt", issues[1].Path)
+	})
+
+	t.Run("unsatisfiable inputs", func(t *testing.T) {
+		minimum, maximum := 5.0, 4.0
+		workflow := &ast.Workflow{
+			Inputs: map[string]*ast.InputParam{
+				"count": {Type: "integer", Minimum: &minimum, Maximum: &maximum},
+			},
+			Workflow: &ast.WorkflowDef{},
+		}
+
+		issues, err := SmokeTest(workflow)
+		require.NoError(t, err)
+		require.Len(t, issues, 1)
+		assert.Equal(t, "inputs.count", issues[0].Path)
+	})
+}
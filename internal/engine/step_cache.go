@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"gopkg.in/yaml.v3"
+)
+
+// StepCache stores the results of top-level workflow steps between runs so
+// that unchanged steps can be reused instead of re-executed. Entries are keyed
+// by a fingerprint which chains the workflow inputs with the definition of
+// every step up to and including the cached step, meaning that changing a
+// step invalidates it and every step that comes after it.
+type StepCache struct {
+	entries map[string]*CachedStep
+	mu      sync.RWMutex
+}
+
+// CachedStep is a single cached step result.
+type CachedStep struct {
+	StepID   string                 `json:"step_id"`
+	Output   map[string]interface{} `json:"output"`
+	Response string                 `json:"response,omitempty"`
+}
+
+// NewStepCache creates an empty in-memory step cache.
+func NewStepCache() *StepCache {
+	return &StepCache{
+		entries: make(map[string]*CachedStep),
+	}
+}
+
+// Get returns the cached result for the given fingerprint if present.
+func (c *StepCache) Get(fingerprint string) (*CachedStep, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[fingerprint]
+	return entry, ok
+}
+
+// Set stores a step result under the given fingerprint.
+func (c *StepCache) Set(fingerprint string, entry *CachedStep) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[fingerprint] = entry
+}
+
+// Len returns the number of cached entries.
+func (c *StepCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.entries)
+}
+
+// stepFingerprints computes the chained fingerprint of every top-level step
+// in the workflow. The fingerprint of a step covers the workflow inputs,
+// the agents and prompts sections, the step definition and the contents of any local
+// files the step references, along with the fingerprint of the previous step.
+func stepFingerprints(workflow *ast.Workflow, inputs map[string]interface{}) map[string]string {
+	fingerprints := make(map[string]string)
+	if workflow == nil || workflow.Workflow == nil {
+		return fingerprints
+	}
+
+	h := sha256.New()
+	writeHashable(h, workflow.SourceFile)
+	writeHashable(h, inputs)
+	writeHashable(h, workflow.Agents)
+	writeHashable(h, workflow.Prompts)
+	prev := hex.EncodeToString(h.Sum(nil))
+
+	wd := filepath.Dir(workflow.SourceFile)
+	for _, step := range workflow.Workflow.Steps {
+		h := sha256.New()
+		h.Write([]byte(prev))
+		writeHashable(h, step)
+		for _, path := range step.LocalReferences() {
+			// #nosec G304 - paths are references declared in the workflow file
+			if data, err := os.ReadFile(filepath.Join(wd, path)); err == nil {
+				h.Write(data)
+			}
+		}
+
+		prev = hex.EncodeToString(h.Sum(nil))
+		fingerprints[step.ID] = prev
+	}
+
+	return fingerprints
+}
+
+func writeHashable(h interface{ Write([]byte) (int, error) }, v interface{}) {
+	var data []byte
+	var err error
+	switch v := v.(type) {
+	case string:
+		data = []byte(v)
+	case *ast.Step:
+		data, err = yaml.Marshal(v)
+	default:
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		return
+	}
+
+	_, _ = h.Write(data)
+}
+
+// restoreCachedStep applies a cached step result to the execution context.
+func restoreCachedStep(execCtx *execcontext.ExecutionContext, step *ast.Step, cached *CachedStep) {
+	execCtx.SetStepResult(step.ID, &execcontext.StepResult{
+		StepID:   step.ID,
+		Status:   execcontext.StepStatusCompleted,
+		Output:   cached.Output,
+		Response: cached.Response,
+	})
+}
+
+// isCacheableStep reports whether a step result can safely be reused. Steps
+// which run containers, send HTTP requests or file issues are always
+// re-executed as they commonly depend on external state that can't be
+// fingerprinted, export
+// steps are cheap and re-executed so that the file they write always exists,
+// as are diff steps which write a file, and ingest steps read documents which
+// may have changed since. Assert steps are cheap and check the results of
+// the run they're in. Steps in a session are re-executed so the conversation they add to
+// the session is there for its later steps, and kv and dedupe steps read and
+// write values which outlive the run. Steps in a prompt experiment may use
+// another version of the prompt in each run. Blocks are re-executed as the
+// steps they run aren't known here, and a step with sub steps, such as a
+// while, for_each, parallel or race step, is only cacheable when all of its
+// sub steps and on_failure handlers are.
+func isCacheableStep(step *ast.Step) bool {
+	writesFile := step.IsExportStep() || (step.IsDiffStep() && step.Diff.Path != "")
+	ref, isRef, _ := ast.ParsePromptRef(step.Prompt)
+	experiment := isRef && ref.IsExperiment()
+	if step.IsBlockStep() || step.IsContainerStep() || step.IsHTTPStep() || step.IsIssueStep() || writesFile || step.IsIngestStep() || step.IsKVStep() || step.IsDedupeStep() || step.IsAssertStep() || step.Session != "" || experiment {
+		return false
+	}
+
+	for _, steps := range subSteps(step) {
+		for _, sub := range steps {
+			if !isCacheableStep(sub) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// subSteps returns the lists of steps nested in a step.
+func subSteps(step *ast.Step) [][]*ast.Step {
+	steps := [][]*ast.Step{step.Steps, step.OnFailure}
+	if step.Parallel != nil {
+		steps = append(steps, step.Parallel.Steps)
+	}
+	if step.Race != nil {
+		for _, branch := range step.Race.Branches {
+			steps = append(steps, branch.Steps)
+		}
+	}
+	return steps
+}
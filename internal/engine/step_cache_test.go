@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+// nestedSteps returns the step in each of the ways a step can be nested in
+// another.
+func nestedSteps(step *ast.Step) map[string]*ast.Step {
+	return map[string]*ast.Step{
+		"top level":  step,
+		"while":      {ID: "loop", While: "${{ false }}", Steps: []*ast.Step{step}},
+		"for_each":   {ID: "each", ForEach: "${{ inputs.items }}", Steps: []*ast.Step{step}},
+		"parallel":   {ID: "group", Parallel: &ast.ParallelStep{Steps: []*ast.Step{{ID: "other", Run: "echo other"}, step}}},
+		"race":       {ID: "race", Race: &ast.RaceStep{Branches: []*ast.RaceBranch{{Steps: []*ast.Step{{ID: "other", Run: "echo other"}}}, {Steps: []*ast.Step{step}}}}},
+		"on_failure": {ID: "fetch", Run: "exit 1", OnFailure: []*ast.Step{step}},
+		"twice":      {ID: "outer", Steps: []*ast.Step{{ID: "inner", Parallel: &ast.ParallelStep{Steps: []*ast.Step{step}}}}},
+	}
+}
+
+func assertNotCacheable(t *testing.T, step *ast.Step) {
+	t.Helper()
+	for name, nested := range nestedSteps(step) {
+		assert.False(t, isCacheableStep(nested), "%s step nested as %s", step.ID, name)
+	}
+}
+
+func TestIsCacheableStep(t *testing.T) {
+	for name, nested := range nestedSteps(&ast.Step{ID: "script", Run: "echo hello"}) {
+		assert.True(t, isCacheableStep(nested), name)
+	}
+
+	assertNotCacheable(t, &ast.Step{ID: "container", Container: "alpine:3", Command: []string{"echo", "hello"}})
+	assertNotCacheable(t, &ast.Step{ID: "http", HTTP: &ast.HTTPStep{URL: "https://example.com"}})
+	assertNotCacheable(t, &ast.Step{ID: "block", Uses: "./blocks/notify"})
+	assertNotCacheable(t, &ast.Step{ID: "kv", KV: &ast.KVStep{Action: ast.KVActionSet, Key: "cursor", Value: 1}})
+	assertNotCacheable(t, &ast.Step{ID: "dedupe", Dedupe: &ast.DedupeStep{Key: "${{ inputs.event_id }}"}})
+}
+
+func TestIsCacheableStep_ExternalEffects(t *testing.T) {
+	// steps which reach outside the run, or check its results, are
+	// re-executed wherever they're nested
+	assertNotCacheable(t, &ast.Step{ID: "issue", Issue: &ast.IssueStep{Tracker: "linear", Token: "${{ env.LINEAR_TOKEN }}"}})
+	assertNotCacheable(t, &ast.Step{ID: "export", Export: &ast.ExportStep{From: "${{ steps.extract.outputs.rows }}", Path: "rows.csv"}})
+	assertNotCacheable(t, &ast.Step{ID: "diff", Diff: &ast.DiffStep{From: "a", To: "b", Path: "changes.diff"}})
+	assertNotCacheable(t, &ast.Step{ID: "ingest", Ingest: &ast.IngestStep{From: "docs/manual.pdf"}})
+	assertNotCacheable(t, &ast.Step{ID: "assert", Assert: []ast.Assertion{{That: "${{ steps.fetch.output != '' }}"}}})
+
+	// diffs which don't write a file are reused
+	for name, nested := range nestedSteps(&ast.Step{ID: "diff", Diff: &ast.DiffStep{From: "a", To: "b"}}) {
+		assert.True(t, isCacheableStep(nested), name)
+	}
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+)
+
+// StepStore persists the results of the most recent run of each top-level
+// step of a workflow to disk. Partial runs use these results to satisfy
+// references to steps which are not executed.
+type StepStore struct {
+	dir    string
+	cipher *encryption.Cipher
+}
+
+// NewStepStore creates a step store which keeps its files in dir, encrypted
+// with cipher unless it's nil.
+func NewStepStore(dir string, cipher *encryption.Cipher) *StepStore {
+	return &StepStore{dir: dir, cipher: cipher}
+}
+
+// Load returns the stored step results for the given workflow file. A
+// workflow that has never been run returns an empty result set.
+func (s *StepStore) Load(workflowFile string) (map[string]*CachedStep, error) {
+	results := make(map[string]*CachedStep)
+
+	data, err := os.ReadFile(s.path(workflowFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return results, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous step results: %w", err)
+	}
+
+	data, err = s.cipher.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous step results: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode previous step results: %w", err)
+	}
+
+	return results, nil
+}
+
+// Save records every completed top-level step in the execution context,
+// results of steps which didn't complete in this run are kept from the
+// previous run.
+func (s *StepStore) Save(execCtx *execcontext.ExecutionContext) error {
+	results, err := s.Load(execCtx.Workflow.SourceFile)
+	if err != nil {
+		results = make(map[string]*CachedStep)
+	}
+
+	for _, step := range execCtx.Workflow.GetSteps() {
+		result, ok := execCtx.GetStepResult(step.ID)
+		if !ok || result.Status != execcontext.StepStatusCompleted {
+			continue
+		}
+
+		results[step.ID] = &CachedStep{
+			StepID:   step.ID,
+			Output:   result.Output,
+			Response: result.Response,
+		}
+	}
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to encode step results: %w", err)
+	}
+
+	data, err = s.cipher.Seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt step results: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("failed to create step store directory: %w", err)
+	}
+
+	return os.WriteFile(s.path(execCtx.Workflow.SourceFile), data, 0600)
+}
+
+// path returns the file used to store results for the workflow, the file is
+// keyed by the absolute path of the workflow.
+func (s *StepStore) path(workflowFile string) string {
+	if abs, err := filepath.Abs(workflowFile); err == nil {
+		workflowFile = abs
+	}
+
+	sum := sha256.Sum256([]byte(workflowFile))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// NewFixtureStep converts a user provided fixture value into a cached step
+// result, using the same output shape as an executed step.
+func NewFixtureStep(stepID string, value interface{}) *CachedStep {
+	result := NewStepResult(value)
+	return &CachedStep{
+		StepID:   stepID,
+		Output:   result.Output,
+		Response: result.Response,
+	}
+}
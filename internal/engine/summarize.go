@@ -0,0 +1,338 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/events"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/ingest"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultSummaryChunkSize is the maximum number of characters summarized in
+// one request when the step doesn't set a chunk size.
+const defaultSummaryChunkSize = 12000
+
+// summaryChunk is a piece of the text being summarized along with where it
+// came from when the text was a list of ingested chunks.
+type summaryChunk struct {
+	text    string
+	source  string
+	page    int
+	section string
+}
+
+// executeSummarizeStep summarizes text with an agent. Text which fits in a
+// chunk is summarized in one request, longer text is split into chunks which
+// are summarized separately (map) and the summaries are combined, in rounds
+// when they don't fit in a chunk either, into the final summary (reduce).
+func (e *Executor) executeSummarizeStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Summarize
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("agent", spec.Agent).
+		Msg("Executing summarize step")
+
+	agent, pr, decision, err := e.resolveStepAgent(execCtx, step, spec.Agent)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := e.renderValueRecursively(spec.From, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render from: %w", err)
+	}
+
+	chunkSize := spec.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultSummaryChunkSize
+	}
+
+	chunks, err := summaryChunks(value, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("there is no text to summarize")
+	}
+
+	instructions := ""
+	if spec.Instructions != "" {
+		rendered, err := e.templateEngine.Render(spec.Instructions, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render instructions: %w", err)
+		}
+		instructions = expression.ValueToString(rendered)
+	}
+
+	usage := &execcontext.TokenUsage{}
+	defer e.recordSpend(agent, usage)
+
+	summarize := func(actionID, prompt string) (string, error) {
+		summary, promptUsage, err := e.generateText(execCtx, step, pr, agent, actionID, prompt)
+		usage.Add(promptUsage)
+		return summary, err
+	}
+
+	if len(chunks) == 1 {
+		summary, err := summarize("summarize", buildSummaryPrompt(spec, instructions, "Summarize the text below.", chunks[0].text))
+		if err != nil {
+			return nil, err
+		}
+
+		result := NewStepResult(map[string]interface{}{
+			"summary": summary,
+			"chunks":  []interface{}{},
+			"levels":  1,
+		})
+		result.TokenUsage = usage
+		result.Routing = decision
+		return result, nil
+	}
+
+	// map: summarize every chunk on its own
+	summaries := make([]string, len(chunks))
+	artifacts := make([]interface{}, len(chunks))
+	for i, chunk := range chunks {
+		prompt := buildPartialSummaryPrompt(instructions, fmt.Sprintf("part %d of %d of a longer text", i+1, len(chunks)), chunk.text)
+		summary, err := summarize(fmt.Sprintf("map-%d", i), prompt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize chunk %d: %w", i, err)
+		}
+		summaries[i] = summary
+
+		artifact := map[string]interface{}{
+			"index":      i,
+			"characters": utf8.RuneCountInString(chunk.text),
+			"summary":    summary,
+		}
+		if chunk.source != "" {
+			artifact["source"] = chunk.source
+		}
+		if chunk.page > 0 {
+			artifact["page"] = chunk.page
+		}
+		if chunk.section != "" {
+			artifact["section"] = chunk.section
+		}
+		artifacts[i] = artifact
+	}
+
+	// reduce: combine the summaries in groups until they fit in one chunk
+	levels := 2
+	for summaryLength(summaries) > chunkSize && len(summaries) > 1 {
+		groups := groupSummaries(summaries, chunkSize)
+
+		log.Debug().
+			Str("step_id", step.ID).
+			Int("summaries", len(summaries)).
+			Int("groups", len(groups)).
+			Msg("Summaries don't fit in a chunk, combining them in groups")
+
+		combined := make([]string, len(groups))
+		for i, group := range groups {
+			prompt := buildPartialSummaryPrompt(instructions, "summaries of consecutive parts of a longer text", joinSummaries(group))
+			summary, err := summarize(fmt.Sprintf("reduce-%d-%d", levels-1, i), prompt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to combine summaries: %w", err)
+			}
+			combined[i] = summary
+		}
+
+		summaries = combined
+		levels++
+	}
+
+	prompt := buildSummaryPrompt(spec, instructions, "The text below is made of summaries of consecutive parts of a longer text. Combine them into a single summary of the whole text.", joinSummaries(summaries))
+	summary, err := summarize("reduce", prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to combine summaries: %w", err)
+	}
+
+	result := NewStepResult(map[string]interface{}{
+		"summary": summary,
+		"chunks":  artifacts,
+		"levels":  levels,
+	})
+	result.TokenUsage = usage
+	result.Routing = decision
+
+	return result, nil
+}
+
+// generateText sends a single prompt to the agent, without its tools, and
+// returns the text of the response.
+func (e *Executor) generateText(execCtx *execcontext.ExecutionContext, step *ast.Step, pr provider.Provider, agent *ast.Agent, actionID, prompt string) (string, *execcontext.TokenUsage, error) {
+	messages := []provider.Message{
+		{
+			Role:    "user",
+			Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(prompt)},
+		},
+	}
+
+	request, err := e.createModelRequestWithTools(agent, messages, agent.Provider)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create model request: %w", err)
+	}
+	request.Tools = nil
+
+	if e.progressChan != nil {
+		e.progressChan <- events.NewPromptAgentEvent(step.ID, actionID, execCtx.RunID, prompt)
+	}
+
+	responseMessages, usage, err := e.generate(execCtx, pr, agent, step, actionID, request)
+	if err != nil {
+		if e.progressChan != nil {
+			e.progressChan <- events.NewAgentFailedEvent(step, actionID, execCtx.RunID)
+		}
+		return "", usage, fmt.Errorf("model generation failed: %w", err)
+	}
+
+	if e.progressChan != nil {
+		e.progressChan <- events.NewAgentCompletedEvent(step, actionID, execCtx.RunID)
+	}
+
+	return strings.TrimSpace(responseText(responseMessages, agent.ToolResultFormat)), usage, nil
+}
+
+// buildSummaryPrompt builds the prompt which writes the final summary with
+// the step's length and format.
+func buildSummaryPrompt(spec *ast.SummarizeStep, instructions, task, text string) string {
+	var builder strings.Builder
+	builder.WriteString(task + "\n")
+
+	switch spec.Format {
+	case "bullets":
+		builder.WriteString("\nWrite the summary as a Markdown bulleted list.")
+	case "outline":
+		builder.WriteString("\nWrite the summary as a Markdown outline with a heading for each topic and bullets beneath it.")
+	default:
+		builder.WriteString("\nWrite the summary as prose paragraphs.")
+	}
+	if spec.Length > 0 {
+		builder.WriteString(fmt.Sprintf(" The summary should be about %d words long.", spec.Length))
+	}
+	builder.WriteString(" Respond with only the summary.\n")
+
+	if instructions != "" {
+		builder.WriteString("\n" + instructions + "\n")
+	}
+
+	builder.WriteString("\n<text>\n" + text + "\n</text>")
+
+	return builder.String()
+}
+
+// buildPartialSummaryPrompt builds the prompt which summarizes a chunk or a
+// group of summaries, keeping the detail the final summary needs.
+func buildPartialSummaryPrompt(instructions, description, text string) string {
+	var builder strings.Builder
+	builder.WriteString("Summarize the text below, which is " + description + ". ")
+	builder.WriteString("Keep the facts, names, numbers and conclusions a summary of the whole text would need, in the order they appear. Respond with only the summary.\n")
+
+	if instructions != "" {
+		builder.WriteString("\nThe summary of the whole text has these instructions: " + instructions + "\n")
+	}
+
+	builder.WriteString("\n<text>\n" + text + "\n</text>")
+
+	return builder.String()
+}
+
+// summaryChunks splits the rendered from value into chunks of at most size
+// characters. The value may be text, a list of texts or a list of chunks
+// with a text, such as the chunks of an ingest step.
+func summaryChunks(value interface{}, size int) ([]summaryChunk, error) {
+	if s, ok := value.(string); ok {
+		return splitSummaryChunk(summaryChunk{text: s}, size), nil
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil || json.Unmarshal(data, &items) != nil {
+			return nil, fmt.Errorf("from must be text or a list of texts or chunks, got %T", value)
+		}
+	}
+
+	var chunks []summaryChunk
+	for i, item := range items {
+		var chunk summaryChunk
+		switch item := item.(type) {
+		case string:
+			chunk.text = item
+		case map[string]interface{}:
+			text, ok := item["text"].(string)
+			if !ok {
+				return nil, fmt.Errorf("from[%d] must have a text", i)
+			}
+			chunk.text = text
+			chunk.source, _ = item["source"].(string)
+			chunk.section, _ = item["section"].(string)
+			if page, ok := item["page"].(float64); ok {
+				chunk.page = int(page)
+			} else if page, ok := item["page"].(int); ok {
+				chunk.page = page
+			}
+		default:
+			return nil, fmt.Errorf("from[%d] must be a text or a chunk with a text, got %T", i, item)
+		}
+
+		chunks = append(chunks, splitSummaryChunk(chunk, size)...)
+	}
+
+	return chunks, nil
+}
+
+// splitSummaryChunk splits a chunk which is longer than size, empty chunks
+// are dropped.
+func splitSummaryChunk(chunk summaryChunk, size int) []summaryChunk {
+	texts := ingest.SplitText(chunk.text, size, 0)
+	chunks := make([]summaryChunk, 0, len(texts))
+	for _, text := range texts {
+		part := chunk
+		part.text = text
+		chunks = append(chunks, part)
+	}
+
+	return chunks
+}
+
+// groupSummaries groups consecutive summaries so that each group fits in
+// size characters. Groups have at least two summaries so that every round
+// of the reduce reduces the number of summaries.
+func groupSummaries(summaries []string, size int) [][]string {
+	var groups [][]string
+	var group []string
+	for _, summary := range summaries {
+		if len(group) >= 2 && summaryLength(append(group, summary)) > size {
+			groups = append(groups, group)
+			group = nil
+		}
+		group = append(group, summary)
+	}
+
+	// a trailing summary on its own is added to the previous group
+	if len(group) == 1 && len(groups) > 0 {
+		groups[len(groups)-1] = append(groups[len(groups)-1], group[0])
+	} else if len(group) > 0 {
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+func joinSummaries(summaries []string) string {
+	return strings.Join(summaries, "\n\n---\n\n")
+}
+
+func summaryLength(summaries []string) int {
+	return utf8.RuneCountInString(joinSummaries(summaries))
+}
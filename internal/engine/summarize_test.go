@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func summarizeWorkflow(pr *scriptedProvider, summarize *ast.SummarizeStep) *ast.Workflow {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "digest", Summarize: summarize}})
+	workflow.Agents = map[string]*ast.Agent{
+		"writer": {Name: "writer", Provider: pr.name, Model: "test-model"},
+	}
+	return workflow
+}
+
+func TestExecutor_ExecuteSummarizeStep_SingleChunk(t *testing.T) {
+	pr := &scriptedProvider{
+		name:      "openai",
+		responses: []provider.ContentBlockParamUnion{provider.NewTextBlock(" - Sales grew\n")},
+	}
+
+	workflow := summarizeWorkflow(pr, &ast.SummarizeStep{
+		Agent:        "writer",
+		From:         "${{ inputs.report }}",
+		Format:       "bullets",
+		Length:       50,
+		Instructions: "Focus on revenue",
+	})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"report": "Sales grew by 10% in the third quarter."}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("digest")
+	require.True(t, ok)
+	assert.Equal(t, "- Sales grew", result.Output["outputs"].(map[string]interface{})["summary"])
+	assert.Equal(t, 1, result.Output["outputs"].(map[string]interface{})["levels"])
+
+	require.Len(t, pr.requests, 1)
+	prompt := pr.requests[0].GetPrompt()
+	assert.Contains(t, prompt, "Write the summary as a Markdown bulleted list. The summary should be about 50 words long.")
+	assert.Contains(t, prompt, "Focus on revenue")
+	assert.Contains(t, prompt, "<text>\nSales grew by 10% in the third quarter.\n</text>")
+	assert.Empty(t, pr.requests[0].Tools)
+}
+
+func TestExecutor_ExecuteSummarizeStep_MapReduce(t *testing.T) {
+	pr := &scriptedProvider{name: "openai"}
+	for _, response := range []string{"one one one one", "two two two two", "three three three three", "four four four four", "one two", "three four", "all four"} {
+		pr.responses = append(pr.responses, provider.NewTextBlock(response))
+	}
+
+	chunks := []interface{}{
+		map[string]interface{}{"index": 0, "source": "report.pdf", "page": 1, "text": strings.Repeat("a", 50)},
+		map[string]interface{}{"index": 1, "source": "report.pdf", "page": 2, "text": strings.Repeat("b", 50)},
+		"c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c c",
+	}
+
+	workflow := summarizeWorkflow(pr, &ast.SummarizeStep{
+		Agent:     "writer",
+		From:      "${{ inputs.chunks }}",
+		ChunkSize: 60,
+	})
+
+	execCtx, _, err := runTestWorkflow(t, workflow, map[string]interface{}{"chunks": chunks}, withProviders(pr))
+	require.NoError(t, err)
+
+	result, ok := execCtx.GetStepResult("digest")
+	require.True(t, ok)
+
+	outputs := result.Output["outputs"].(map[string]interface{})
+	assert.Equal(t, "all four", outputs["summary"])
+	assert.Equal(t, 3, outputs["levels"])
+	assert.Equal(t, 7*15, result.TokenUsage.TotalTokens)
+
+	// the last text is split in two so there are four chunks, the four
+	// summaries don't fit in a chunk and are combined in pairs first
+	artifacts := outputs["chunks"].([]interface{})
+	require.Len(t, artifacts, 4)
+	assert.Equal(t, map[string]interface{}{"index": 0, "characters": 50, "summary": "one one one one", "source": "report.pdf", "page": 1}, artifacts[0])
+	assert.Equal(t, map[string]interface{}{"index": 3, "characters": 51, "summary": "four four four four"}, artifacts[3])
+
+	require.Len(t, pr.requests, 7)
+	assert.Contains(t, pr.requests[0].GetPrompt(), "which is part 1 of 4 of a longer text")
+	assert.Contains(t, pr.requests[4].GetPrompt(), "one one one one\n\n---\n\ntwo two two two")
+	assert.Contains(t, pr.requests[6].GetPrompt(), "Combine them into a single summary of the whole text.")
+	assert.Contains(t, pr.requests[6].GetPrompt(), "one two\n\n---\n\nthree four")
+}
+
+func TestGroupSummaries(t *testing.T) {
+	assert.Equal(t, [][]string{{"aa", "bb"}, {"cc", "dd", "ee"}}, groupSummaries([]string{"aa", "bb", "cc", "dd", "ee"}, 10))
+	assert.Equal(t, [][]string{{"aaaa", "bbbb"}, {"cccc", "dddd"}}, groupSummaries([]string{"aaaa", "bbbb", "cccc", "dddd"}, 4))
+}
@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+)
+
+// StepTimeoutError is returned by a step which ran longer than its timeout.
+type StepTimeoutError struct {
+	StepID  string
+	Timeout time.Duration
+}
+
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("step %s timed out after %s", e.StepID, e.Timeout)
+}
+
+// runWithTimeout runs one attempt of the step with a context which is
+// cancelled once the step's timeout is reached, cancelling its model
+// requests, scripts, containers and blocks. Steps without a timeout are run
+// with execCtx.
+func (e *Executor) runWithTimeout(execCtx *execcontext.ExecutionContext, step *ast.Step, run func(*execcontext.ExecutionContext) (*StepResult, error)) (*StepResult, error) {
+	if step.Timeout == nil {
+		return run(execCtx)
+	}
+
+	ctx, cancel := context.WithTimeout(execCtx.Context.Context, step.Timeout.Duration)
+	defer cancel()
+
+	stepCtx := execCtx.NewChild(nil)
+	stepCtx.Context.Context = ctx
+	stepCtx.CurrentStepIndex = execCtx.CurrentStepIndex
+
+	stepResult, err := run(stepCtx)
+
+	// the step only timed out when it was its own deadline which was
+	// reached rather than the run being cancelled
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && execCtx.Context.Context.Err() == nil {
+		return nil, &StepTimeoutError{StepID: step.ID, Timeout: step.Timeout.Duration}
+	}
+
+	return stepResult, err
+}
+
+// sendStepTimedOut sends the step_timed_out event of a step which failed
+// because it reached its own timeout.
+func (e *Executor) sendStepTimedOut(execCtx *execcontext.ExecutionContext, step *ast.Step, stepIndex int, err error) {
+	var timeoutErr *StepTimeoutError
+	if e.progressChan == nil || !errors.As(err, &timeoutErr) || timeoutErr.StepID != step.ID {
+		return
+	}
+
+	e.progressChan <- pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventStepTimedOut,
+		Timestamp: time.Now(),
+		RunID:     execCtx.RunID,
+		StepID:    step.ID,
+		StepIndex: stepIndex,
+		Error:     err.Error(),
+		Metadata:  map[string]interface{}{pkgEvents.MetadataTimeout: timeoutErr.Timeout},
+	}
+}
+
+// withWorkflowTimeout cancels the context of the run once the workflow's
+// timeout is reached. The returned function restores the context of the
+// run and returns the error the workflow failed with, err or the timeout.
+func (e *Executor) withWorkflowTimeout(execCtx *execcontext.ExecutionContext) func(err error) error {
+	timeout := execCtx.Workflow.Workflow.Timeout
+	if timeout == nil {
+		return func(err error) error { return err }
+	}
+
+	parent := execCtx.Context.Context
+	ctx, cancel := context.WithTimeout(parent, timeout.Duration)
+	execCtx.Context.Context = ctx
+
+	return func(err error) error {
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+		cancel()
+		execCtx.Context.Context = parent
+
+		if !timedOut {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("workflow timed out after %s: %w", timeout.Duration, err)
+		}
+
+		// the run stopped between steps, nothing has reported the failure yet
+		err = fmt.Errorf("workflow timed out after %s", timeout.Duration)
+		if e.progressChan != nil {
+			e.progressChan <- pkgEvents.ExecutionEvent{
+				Type:      pkgEvents.EventWorkflowFailed,
+				Timestamp: time.Now(),
+				RunID:     execCtx.RunID,
+				Error:     err.Error(),
+				Metadata:  runClockMetadata(execCtx),
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eventTypes(events []pkgEvents.ExecutionEvent, stepID string) []pkgEvents.ExecutionEventType {
+	var types []pkgEvents.ExecutionEventType
+	for _, event := range events {
+		if event.StepID == stepID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+func TestExecutor_StepTimeout(t *testing.T) {
+	start := time.Now()
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "ask", Agent: "slow", Prompt: "Why is the sky blue?", Timeout: &ast.Duration{Duration: 50 * time.Millisecond}},
+	})
+	workflow.Agents = modelDelayAgents()
+
+	_, events, err := runTestWorkflow(t, workflow, nil, withProviders(newModelDelayProvider()))
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	var timeoutErr *StepTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, "step ask timed out after 50ms", timeoutErr.Error())
+
+	// the step times out before it fails
+	types := eventTypes(events, "ask")
+	require.GreaterOrEqual(t, len(types), 2)
+	assert.Equal(t, []pkgEvents.ExecutionEventType{pkgEvents.EventStepTimedOut, pkgEvents.EventStepFailed}, types[len(types)-2:])
+}
+
+func TestExecutor_StepTimeout_ContinueOnError(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "ask", Agent: "slow", Prompt: "Why is the sky blue?", Timeout: &ast.Duration{Duration: 50 * time.Millisecond}, ContinueOnError: true},
+		{ID: "fallback", Agent: "fast", Prompt: "Why is the sky blue?", Timeout: &ast.Duration{Duration: time.Second}},
+	})
+	workflow.Agents = modelDelayAgents()
+
+	execCtx, _, err := runTestWorkflow(t, workflow, nil, withProviders(newModelDelayProvider()))
+	require.NoError(t, err)
+
+	ask, _ := execCtx.GetStepResult("ask")
+	assert.Equal(t, execcontext.StepStatusFailed, ask.Status)
+	assert.EqualError(t, ask.Error, "step ask timed out after 50ms")
+
+	fallback, _ := execCtx.GetStepResult("fallback")
+	assert.Equal(t, "answer from fast", fallback.Response)
+}
+
+func TestExecutor_WorkflowTimeout(t *testing.T) {
+	start := time.Now()
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "first", Agent: "fast", Prompt: "Why is the sky blue?"},
+		{ID: "second", Agent: "slow", Prompt: "Why is the sky blue?", Timeout: &ast.Duration{Duration: time.Minute}},
+	})
+	workflow.Workflow.Timeout = &ast.Duration{Duration: 100 * time.Millisecond}
+	workflow.Agents = modelDelayAgents()
+
+	execCtx, events, err := runTestWorkflow(t, workflow, nil, withProviders(newModelDelayProvider()))
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Contains(t, err.Error(), "workflow timed out after 100ms")
+
+	// the step was cancelled by the workflow rather than its own timeout
+	assert.NotContains(t, eventTypes(events, "second"), pkgEvents.EventStepTimedOut)
+
+	first, _ := execCtx.GetStepResult("first")
+	assert.Equal(t, execcontext.StepStatusCompleted, first.Status)
+	assert.NoError(t, execCtx.Context.Context.Err())
+}
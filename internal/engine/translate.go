@@ -0,0 +1,303 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/routing"
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// translation describes text to translate.
+type translation struct {
+	text         string
+	target       string
+	source       string
+	instructions string
+	glossary     map[string]string
+	retries      int
+}
+
+// executeTranslateStep translates text into the step's target language,
+// applying its glossary, and detects the language of the text.
+func (e *Executor) executeTranslateStep(execCtx *execcontext.ExecutionContext, step *ast.Step) (*StepResult, error) {
+	spec := step.Translate
+
+	log.Debug().
+		Str("step_id", step.ID).
+		Str("agent", spec.Agent).
+		Msg("Executing translate step")
+
+	request := translation{retries: defaultStructuredRetries}
+	if spec.Retries != nil {
+		request.retries = *spec.Retries
+	}
+
+	for _, field := range []struct {
+		name     string
+		template string
+		value    *string
+	}{
+		{"from", spec.From, &request.text},
+		{"to", spec.To, &request.target},
+		{"source", spec.Source, &request.source},
+		{"instructions", spec.Instructions, &request.instructions},
+	} {
+		if field.template == "" {
+			continue
+		}
+
+		rendered, err := e.templateEngine.Render(field.template, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", field.name, err)
+		}
+		*field.value = expression.ValueToString(rendered)
+	}
+
+	if spec.Glossary != nil {
+		rendered, err := e.renderValueRecursively(spec.Glossary, execCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render glossary: %w", err)
+		}
+
+		request.glossary, err = translationGlossary(rendered)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response, usage, decision, err := e.translate(execCtx, step, spec.Agent, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewStepResult(map[string]interface{}{
+		"text":            response["text"],
+		"source_language": response["source_language"],
+		"target_language": request.target,
+	})
+	result.TokenUsage = usage
+	result.Routing = decision
+
+	return result, nil
+}
+
+// translate asks the agent to translate the text, the response has the text
+// and the language it was translated from.
+func (e *Executor) translate(execCtx *execcontext.ExecutionContext, step *ast.Step, agentName string, request translation) (map[string]interface{}, *execcontext.TokenUsage, *routing.Decision, error) {
+	agent, pr, decision, err := e.resolveStepAgent(execCtx, step, agentName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	responseSchema := &provider.ResponseSchema{
+		Name:        "translate_" + step.ID,
+		Description: "Record the translation of the text",
+		Schema: schema.JSON{
+			Type: "object",
+			Properties: map[string]schema.JSON{
+				"source_language": {
+					Type:        "string",
+					Description: "The ISO 639-1 code of the language of the original text, e.g. en",
+				},
+				"text": {
+					Type:        "string",
+					Description: "The translated text",
+				},
+			},
+			Required:             []string{"source_language", "text"},
+			AdditionalProperties: false,
+		},
+	}
+
+	response, usage, err := e.generateStructured(execCtx, step, pr, agent, buildTranslatePrompt(request), responseSchema, request.retries)
+	e.recordSpend(agent, usage)
+	if err != nil {
+		return nil, usage, decision, err
+	}
+
+	return response, usage, decision, nil
+}
+
+// detectLanguage asks the agent for the ISO 639-1 code of the language of
+// the text.
+func (e *Executor) detectLanguage(execCtx *execcontext.ExecutionContext, step *ast.Step, agentName, text string) (string, error) {
+	agent, pr, _, err := e.resolveStepAgent(execCtx, step, agentName)
+	if err != nil {
+		return "", err
+	}
+
+	responseSchema := &provider.ResponseSchema{
+		Name:        "detect_language",
+		Description: "Record the language of the text",
+		Schema: schema.JSON{
+			Type: "object",
+			Properties: map[string]schema.JSON{
+				"language": {
+					Type:        "string",
+					Pattern:     "^[a-z]{2}$",
+					Description: "The ISO 639-1 code of the language, e.g. en",
+				},
+			},
+			Required:             []string{"language"},
+			AdditionalProperties: false,
+		},
+	}
+
+	prompt := "Which language is the text below written in? When it mixes languages, choose the language most of the text is in.\n\n<text>\n" + text + "\n</text>"
+	response, usage, err := e.generateStructured(execCtx, step, pr, agent, prompt, responseSchema, defaultStructuredRetries)
+	e.recordSpend(agent, usage)
+	if err != nil {
+		return "", err
+	}
+
+	return response["language"].(string), nil
+}
+
+// buildTranslatePrompt renders the target language, glossary, instructions
+// and the text to translate.
+func buildTranslatePrompt(request translation) string {
+	var builder strings.Builder
+	builder.WriteString("Translate the text below into " + request.target + ".")
+	if request.source != "" {
+		builder.WriteString(" The text is written in " + request.source + ".")
+	}
+	builder.WriteString(" Keep its meaning, tone and formatting, including Markdown, and leave code, URLs and placeholders unchanged.\n")
+
+	if len(request.glossary) > 0 {
+		terms := make([]string, 0, len(request.glossary))
+		for term := range request.glossary {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+
+		builder.WriteString("\nTranslate these terms exactly as given:\n")
+		for _, term := range terms {
+			builder.WriteString("- " + term + " → " + request.glossary[term] + "\n")
+		}
+	}
+
+	if request.instructions != "" {
+		builder.WriteString("\n" + request.instructions + "\n")
+	}
+
+	builder.WriteString("\nRespond with the ISO 639-1 code of the language of the original text and the translation.\n")
+	builder.WriteString("\n<text>\n" + request.text + "\n</text>")
+
+	return builder.String()
+}
+
+// translationGlossary converts a rendered glossary, a map of terms to their
+// translations or the same map as JSON, to a glossary.
+func translationGlossary(value interface{}) (map[string]string, error) {
+	if s, ok := value.(string); ok {
+		if strings.TrimSpace(s) == "" {
+			return nil, nil
+		}
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, fmt.Errorf("glossary must be a map of terms to translations")
+		}
+	}
+
+	terms, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("glossary must be a map of terms to translations, got %T", value)
+	}
+
+	glossary := make(map[string]string, len(terms))
+	for term, translation := range terms {
+		s, ok := translation.(string)
+		if !ok {
+			return nil, fmt.Errorf("the translation of %s must be a string, got %T", term, translation)
+		}
+		glossary[term] = s
+	}
+
+	return glossary, nil
+}
+
+// registerLanguageFunctions adds the detectLanguage and translate functions
+// to the executor's expressions. Both use the agent named by their last
+// argument, which can be left out when the workflow has a single agent.
+func (e *Executor) registerLanguageFunctions() {
+	e.templateEngine.RegisterFunction(&expression.FunctionDefinition{
+		Name:        "detectLanguage",
+		Description: "Returns the ISO 639-1 code of the language of the text, detected by an agent",
+		Args: []expression.Argument{
+			{Name: "text", Type: "string", Required: true},
+			{Name: "agent", Type: "string", Required: false},
+		},
+		Returns: "string",
+		Example: "detectLanguage('Bonjour tout le monde') → 'fr'",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("detectLanguage() requires 1 or 2 arguments")
+			}
+
+			agent, err := languageFunctionAgent(execCtx, "detectLanguage", args[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			return e.detectLanguage(execCtx, &ast.Step{ID: "detectLanguage"}, agent, expression.ValueToString(args[0]))
+		},
+	})
+
+	e.templateEngine.RegisterFunction(&expression.FunctionDefinition{
+		Name:        "translate",
+		Description: "Translates the text into the target language with an agent",
+		Args: []expression.Argument{
+			{Name: "text", Type: "string", Required: true},
+			{Name: "target", Type: "string", Required: true},
+			{Name: "agent", Type: "string", Required: false},
+		},
+		Returns: "string",
+		Example: "translate('Hello world', 'French') → 'Bonjour le monde'",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("translate() requires 2 or 3 arguments")
+			}
+
+			agent, err := languageFunctionAgent(execCtx, "translate", args[2:])
+			if err != nil {
+				return nil, err
+			}
+
+			response, _, _, err := e.translate(execCtx, &ast.Step{ID: "translate"}, agent, translation{
+				text:    expression.ValueToString(args[0]),
+				target:  expression.ValueToString(args[1]),
+				retries: defaultStructuredRetries,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			return response["text"], nil
+		},
+	})
+}
+
+// languageFunctionAgent returns the agent named by the optional agent
+// argument of a language function, or the workflow's only agent.
+func languageFunctionAgent(execCtx *execcontext.ExecutionContext, function string, args []interface{}) (string, error) {
+	if len(args) > 0 {
+		return expression.ValueToString(args[0]), nil
+	}
+
+	if execCtx.Workflow == nil || len(execCtx.Workflow.Agents) != 1 {
+		return "", fmt.Errorf("%s() requires the name of an agent as its last argument unless the workflow defines exactly one agent", function)
+	}
+
+	for name := range execCtx.Workflow.Agents {
+		return name, nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTranslateExecutor(t *testing.T, pr *scriptedProvider, steps []*ast.Step, agents ...string) (*Executor, *execcontext.ExecutionContext) {
+	t.Helper()
+
+	workflow := createTestWorkflow(steps)
+	workflow.Agents = map[string]*ast.Agent{}
+	for _, name := range agents {
+		workflow.Agents[name] = &ast.Agent{Name: name, Provider: pr.name, Model: "test-model"}
+	}
+
+	registry := provider.NewRegistry(false)
+	require.NoError(t, registry.RegisterProvider(pr))
+
+	executor, err := NewExecutor(execcontext.RunContext{Context: context.Background()}, DefaultExecutorConfig(), workflow, registry, &Runner{})
+	require.NoError(t, err)
+
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{Context: context.Background()}, workflow, map[string]interface{}{"description": "Connect the widget to the hub"}, "/tmp")
+	execCtx.UpdateState(map[string]interface{}{
+		"glossary": map[string]interface{}{"widget": "Widget", "hub": "Zentrale"},
+	})
+
+	// expressions are rendered while the workflow runs
+	e := executor.(*Executor)
+	e.execCtx = execCtx
+
+	return e, execCtx
+}
+
+func toolResponse(id, name string, input map[string]interface{}) provider.ContentBlockParamUnion {
+	data, _ := json.Marshal(input)
+	return provider.NewToolUseBlock(id, data, name)
+}
+
+func TestExecutor_ExecuteTranslateStep(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "anthropic",
+		responses: []provider.ContentBlockParamUnion{
+			toolResponse("call_1", "translate_localize", map[string]interface{}{"source_language": "en", "text": "Verbinden Sie das Widget mit der Zentrale"}),
+		},
+	}
+
+	executor, execCtx := newTranslateExecutor(t, pr, []*ast.Step{
+		{
+			ID: "localize",
+			Translate: &ast.TranslateStep{
+				Agent:        "translator",
+				From:         "${{ inputs.description }}",
+				To:           "German",
+				Glossary:     "${{ state.glossary }}",
+				Instructions: "Use the formal Sie",
+			},
+		},
+	}, "translator")
+	require.NoError(t, executor.ExecuteWorkflow(execCtx, nil))
+
+	result, ok := execCtx.GetStepResult("localize")
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		"text":            "Verbinden Sie das Widget mit der Zentrale",
+		"source_language": "en",
+		"target_language": "German",
+	}, result.Output["outputs"])
+
+	require.Len(t, pr.requests, 1)
+	prompt := pr.requests[0].GetPrompt()
+	assert.Contains(t, prompt, "Translate the text below into German.")
+	assert.Contains(t, prompt, "Translate these terms exactly as given:\n- hub → Zentrale\n- widget → Widget\n")
+	assert.Contains(t, prompt, "Use the formal Sie")
+	assert.Contains(t, prompt, "<text>\nConnect the widget to the hub\n</text>")
+}
+
+func TestExecutor_LanguageFunctions(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "anthropic",
+		responses: []provider.ContentBlockParamUnion{
+			toolResponse("call_1", "detect_language", map[string]interface{}{"language": "fr"}),
+			toolResponse("call_2", "translate_translate", map[string]interface{}{"source_language": "fr", "text": "Hello everyone"}),
+		},
+	}
+
+	executor, execCtx := newTranslateExecutor(t, pr, []*ast.Step{{ID: "noop", Run: "true"}}, "translator")
+
+	language, err := executor.templateEngine.Render("${{ detectLanguage('Bonjour à tous') }}", execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "fr", language)
+
+	translated, err := executor.templateEngine.Render("${{ translate('Bonjour à tous', 'English') }}", execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello everyone", translated)
+	assert.Contains(t, pr.requests[1].GetPrompt(), "Translate the text below into English.")
+}
+
+func TestExecutor_LanguageFunctions_RequireAgent(t *testing.T) {
+	executor, execCtx := newTranslateExecutor(t, &scriptedProvider{name: "anthropic"}, []*ast.Step{{ID: "noop", Run: "true"}}, "writer", "reviewer")
+
+	_, err := executor.templateEngine.Render("${{ translate('Hallo', 'English') }}", execCtx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "translate() requires the name of an agent as its last argument unless the workflow defines exactly one agent")
+
+	_, err = executor.templateEngine.Render("${{ detectLanguage('Hallo', 'editor') }}", execCtx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent editor not found")
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/schema"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// triageEvents is how many of the most recent events of a failed run are
+	// sent to the triage agent.
+	triageEvents = 20
+
+	// triageEventLength is the maximum characters of an event's text sent to
+	// the triage agent.
+	triageEventLength = 300
+)
+
+// FailureTriage is the diagnosis of a failed run by the workflow's triage agent.
+type FailureTriage struct {
+	StepID       string `json:"step_id,omitempty" yaml:"step_id,omitempty"`
+	Diagnosis    string `json:"diagnosis" yaml:"diagnosis"`
+	SuggestedFix string `json:"suggested_fix" yaml:"suggested_fix"`
+}
+
+// TriagedError is returned by a run which failed and was diagnosed by the
+// workflow's triage agent.
+type TriagedError struct {
+	Err    error
+	Triage *FailureTriage
+}
+
+func (e *TriagedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TriagedError) Unwrap() error {
+	return e.Err
+}
+
+// failureTriager is implemented by executors which can diagnose the failure
+// of the run they executed.
+type failureTriager interface {
+	triageFailure(execCtx *execcontext.ExecutionContext, runErr error, events []pkgEvents.ExecutionEvent) (*FailureTriage, error)
+}
+
+// recentEvents keeps the most recent events of a run.
+type recentEvents struct {
+	mu     sync.Mutex
+	events []pkgEvents.ExecutionEvent
+}
+
+func (r *recentEvents) add(event pkgEvents.ExecutionEvent) {
+	// token deltas would push out the events of the steps, which are what a
+	// failure is diagnosed from
+	if r == nil || event.Type == pkgEvents.EventTokenDelta {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+	if len(r.events) > triageEvents {
+		r.events = r.events[len(r.events)-triageEvents:]
+	}
+}
+
+func (r *recentEvents) list() []pkgEvents.ExecutionEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]pkgEvents.ExecutionEvent(nil), r.events...)
+}
+
+// triageFailure asks the workflow's triage agent to diagnose the failed run
+// from the failing step, its error and the recent events of the run.
+func (e *Executor) triageFailure(execCtx *execcontext.ExecutionContext, runErr error, events []pkgEvents.ExecutionEvent) (*FailureTriage, error) {
+	workflow := execCtx.Workflow
+
+	// the run is over and its events have been closed, the triage isn't
+	// part of the run's progress
+	e.progressChan = nil
+
+	// the triage isn't a step of the workflow but agents are resolved and
+	// requests are made on behalf of one
+	step := &ast.Step{ID: "triage", Agent: workflow.Triage.Agent}
+	agent, pr, _, err := e.resolveStepAgent(execCtx, step, workflow.Triage.Agent)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := failedStep(execCtx)
+	prompt, err := buildTriagePrompt(execCtx, failed, runErr, events)
+	if err != nil {
+		return nil, err
+	}
+
+	responseSchema := &provider.ResponseSchema{
+		Name:        "triage_failure",
+		Description: "Record the diagnosis of the failed workflow run",
+		Schema:      triageSchema(),
+	}
+
+	response, usage, err := e.generateStructured(execCtx, step, pr, agent, prompt, responseSchema, 1)
+	e.recordSpend(agent, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	triage := &FailureTriage{
+		Diagnosis:    strings.TrimSpace(fmt.Sprint(response["diagnosis"])),
+		SuggestedFix: strings.TrimSpace(fmt.Sprint(response["suggested_fix"])),
+	}
+	if failed != nil {
+		triage.StepID = failed.ID
+	}
+
+	return triage, nil
+}
+
+// failedStep returns the top-level step which failed, or the one which was
+// running when the run was stopped.
+func failedStep(execCtx *execcontext.ExecutionContext) *ast.Step {
+	var running *ast.Step
+	for _, step := range execCtx.Workflow.GetSteps() {
+		result, ok := execCtx.GetStepResult(step.ID)
+		if !ok {
+			continue
+		}
+
+		switch result.Status {
+		case execcontext.StepStatusFailed:
+			return step
+		case execcontext.StepStatusRunning:
+			running = step
+		}
+	}
+
+	return running
+}
+
+func buildTriagePrompt(execCtx *execcontext.ExecutionContext, failed *ast.Step, runErr error, events []pkgEvents.ExecutionEvent) (string, error) {
+	workflow := execCtx.Workflow
+
+	var sb strings.Builder
+	sb.WriteString("A run of the Lacquer workflow \"")
+	sb.WriteString(getWorkflowName(workflow))
+	sb.WriteString("\" failed. Diagnose the most likely cause of the failure in a few sentences and suggest a concrete fix, ")
+	sb.WriteString("such as a change to the workflow, its inputs, a script or the environment it runs in.\n")
+
+	if workflow.Triage.Instructions != "" {
+		sb.WriteString("\n")
+		sb.WriteString(workflow.Triage.Instructions)
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n<error>\n")
+	sb.WriteString(runErr.Error())
+	sb.WriteString("\n</error>\n")
+
+	if failed != nil {
+		definition, err := yaml.Marshal(failed)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode step %s: %w", failed.ID, err)
+		}
+
+		fmt.Fprintf(&sb, "\n<failed_step id=%q>\n%s</failed_step>\n", failed.ID, definition)
+
+		if result, ok := execCtx.GetStepResult(failed.ID); ok && result.Retries > 0 {
+			fmt.Fprintf(&sb, "\nThe step was retried %d times.\n", result.Retries)
+		}
+	}
+
+	if len(execCtx.Inputs) > 0 {
+		inputs, err := json.MarshalIndent(execCtx.Inputs, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode inputs: %w", err)
+		}
+
+		fmt.Fprintf(&sb, "\n<inputs>\n%s\n</inputs>\n", inputs)
+	}
+
+	if len(events) > 0 {
+		sb.WriteString("\n<recent_events>\n")
+		for _, event := range events {
+			sb.WriteString(formatTriageEvent(event))
+			sb.WriteString("\n")
+		}
+		sb.WriteString("</recent_events>\n")
+	}
+
+	return sb.String(), nil
+}
+
+// formatTriageEvent describes an event in a single line.
+func formatTriageEvent(event pkgEvents.ExecutionEvent) string {
+	parts := []string{event.Timestamp.Format("15:04:05.000"), string(event.Type)}
+	if event.StepID != "" {
+		parts = append(parts, event.StepID)
+	}
+	if event.ActionID != "" {
+		parts = append(parts, event.ActionID)
+	}
+	if event.Attempt > 0 {
+		parts = append(parts, fmt.Sprintf("attempt %d", event.Attempt))
+	}
+
+	line := strings.Join(parts, " ")
+	for _, text := range []string{event.Error, event.Text} {
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			continue
+		}
+		if utf8.RuneCountInString(text) > triageEventLength {
+			text = string([]rune(text)[:triageEventLength]) + "..."
+		}
+		line += ": " + text
+	}
+
+	return line
+}
+
+func triageSchema() schema.JSON {
+	return schema.JSON{
+		Type: "object",
+		Properties: map[string]schema.JSON{
+			"diagnosis": {
+				Type:        "string",
+				Description: "The most likely cause of the failure in a few sentences",
+			},
+			"suggested_fix": {
+				Type:        "string",
+				Description: "A concrete change which would fix the failure",
+			},
+		},
+		Required:             []string{"diagnosis", "suggested_fix"},
+		AdditionalProperties: false,
+	}
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/lacquerai/lacquer/internal/provider"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWorkflow_TriageFailure(t *testing.T) {
+	pr := &scriptedProvider{
+		name: "openai",
+		responses: []provider.ContentBlockParamUnion{
+			provider.NewToolUseBlock("call_1", json.RawMessage(`{"diagnosis":"The report file doesn't exist.","suggested_fix":"Create reports/q3.csv or pass a different path."}`), "triage_failure"),
+		},
+	}
+
+	registry := provider.NewRegistry(false)
+	require.NoError(t, registry.RegisterProvider(pr))
+
+	workflow := createTestWorkflow([]*ast.Step{
+		{ID: "prepare", Run: "echo ready"},
+		{ID: "load", Run: "cat reports/q3.csv"},
+	})
+	workflow.Agents = map[string]*ast.Agent{
+		"debugger": {Name: "debugger", Provider: pr.name, Model: "test-model"},
+	}
+	workflow.Triage = &ast.Triage{Agent: "debugger", Instructions: "Reports are exported nightly"}
+
+	store := history.NewStore(filepath.Join(t.TempDir(), "history"))
+	runner := NewRunner(nil, WithRunHistory(store), WithExecutorFunc(func(ctx execcontext.RunContext, config *ExecutorConfig, workflow *ast.Workflow, _ *provider.Registry, runner *Runner) (WorkflowExecutor, error) {
+		return NewExecutor(ctx, config, workflow, registry, runner)
+	}))
+
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	execCtx := execcontext.NewExecutionContext(ctx, workflow, map[string]interface{}{"quarter": "q3"}, t.TempDir())
+
+	_, err := runner.RunWorkflowRaw(execCtx, workflow, time.Now())
+	require.Error(t, err)
+
+	var triaged *TriagedError
+	require.True(t, errors.As(err, &triaged))
+	assert.Equal(t, &FailureTriage{
+		StepID:       "load",
+		Diagnosis:    "The report file doesn't exist.",
+		SuggestedFix: "Create reports/q3.csv or pass a different path.",
+	}, triaged.Triage)
+
+	require.Len(t, pr.requests, 1)
+	prompt := pr.requests[0].GetPrompt()
+	assert.Contains(t, prompt, `A run of the Lacquer workflow "Test Workflow" failed.`)
+	assert.Contains(t, prompt, "Reports are exported nightly")
+	assert.Contains(t, prompt, "<error>\n"+triaged.Err.Error()+"\n</error>")
+	assert.Contains(t, prompt, "<failed_step id=\"load\">\nid: load\nrun: cat reports/q3.csv\n</failed_step>")
+	assert.Contains(t, prompt, "\"quarter\": \"q3\"")
+	assert.Contains(t, prompt, "step_completed prepare")
+	assert.Contains(t, prompt, "step_failed load")
+	assert.Empty(t, pr.requests[0].Tools)
+
+	runs, err := store.List(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, &history.Triage{
+		StepID:       "load",
+		Diagnosis:    "The report file doesn't exist.",
+		SuggestedFix: "Create reports/q3.csv or pass a different path.",
+	}, runs[0].Triage)
+}
+
+func TestRunWorkflow_TriageNotConfigured(t *testing.T) {
+	workflow := createTestWorkflow([]*ast.Step{{ID: "load", Run: "exit 1"}})
+
+	runner := NewRunner(nil)
+	ctx := execcontext.RunContext{Context: context.Background(), StdOut: io.Discard, StdErr: io.Discard}
+	execCtx := execcontext.NewExecutionContext(ctx, workflow, map[string]interface{}{}, t.TempDir())
+
+	_, err := runner.RunWorkflowRaw(execCtx, workflow, time.Now())
+	require.Error(t, err)
+
+	var triaged *TriagedError
+	assert.False(t, errors.As(err, &triaged))
+}
+
+func TestRecentEvents(t *testing.T) {
+	var none *recentEvents
+	none.add(pkgEvents.ExecutionEvent{})
+
+	recent := &recentEvents{}
+	for i := 0; i < triageEvents+5; i++ {
+		recent.add(pkgEvents.ExecutionEvent{StepIndex: i})
+	}
+
+	events := recent.list()
+	require.Len(t, events, triageEvents)
+	assert.Equal(t, 5, events[0].StepIndex)
+	assert.Equal(t, triageEvents+4, events[len(events)-1].StepIndex)
+}
+
+func TestFormatTriageEvent(t *testing.T) {
+	timestamp := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	assert.Equal(t, "15:04:05.000 step_failed load attempt 2: exit status 1", formatTriageEvent(pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventStepFailed,
+		Timestamp: timestamp,
+		StepID:    "load",
+		Attempt:   2,
+		Error:     "exit status 1",
+	}))
+
+	line := formatTriageEvent(pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventStepActionStarted,
+		Timestamp: timestamp,
+		StepID:    "load",
+		ActionID:  "prompt",
+		Text:      "a\n\nb " + strings.Repeat("x", triageEventLength),
+	})
+	assert.Equal(t, "15:04:05.000 step_action_started load prompt: a b", line[:len("15:04:05.000 step_action_started load prompt: a b")])
+	assert.Contains(t, line, "...")
+}
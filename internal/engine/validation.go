@@ -1,13 +1,19 @@
 package engine
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/expression"
 )
 
 // InputValidationError represents a validation error for a specific input field
@@ -33,6 +39,17 @@ func (r *InputValidationResult) Error() string {
 	return fmt.Sprintf("Valid: %t\nErrors: %v\nProcessedInputs: %v", r.Valid, r.Errors, r.ProcessedInputs)
 }
 
+// Summary returns the validation errors as a single line, ordered by field.
+func (r *InputValidationResult) Summary() string {
+	messages := make([]string, len(r.Errors))
+	for i, err := range r.Errors {
+		messages[i] = err.Error()
+	}
+	sort.Strings(messages)
+
+	return strings.Join(messages, "; ")
+}
+
 // AddError adds a validation error
 func (r *InputValidationResult) AddError(field, message string, value any) {
 	r.Valid = false
@@ -55,7 +72,15 @@ func ValidateWorkflowInputs(workflow *ast.Workflow, providedInputs map[string]an
 		return result
 	}
 
-	for paramName, paramDef := range workflow.Inputs {
+	order, err := workflow.InputDefaultOrder()
+	if err != nil {
+		result.AddError("inputs", err.Error(), nil)
+		return result
+	}
+
+	var defaults []string
+	for _, paramName := range order {
+		paramDef := workflow.Inputs[paramName]
 		providedValue, hasValue := providedInputs[paramName]
 
 		if !hasValue {
@@ -64,8 +89,7 @@ func ValidateWorkflowInputs(workflow *ast.Workflow, providedInputs map[string]an
 				continue
 			}
 			if paramDef.Default != nil {
-				result.ProcessedInputs[paramName] = paramDef.Default
-				continue
+				defaults = append(defaults, paramName)
 			}
 			continue
 		}
@@ -78,6 +102,30 @@ func ValidateWorkflowInputs(workflow *ast.Workflow, providedInputs map[string]an
 		}
 	}
 
+	// defaults are resolved after all provided inputs, in dependency order, so
+	// that default expressions can reference any other input.
+	for _, paramName := range defaults {
+		paramDef := workflow.Inputs[paramName]
+		if !paramDef.HasExpressionDefault() {
+			result.ProcessedInputs[paramName] = paramDef.Default
+			continue
+		}
+
+		value, err := evaluateInputDefault(workflow, paramDef, result.ProcessedInputs)
+		if err != nil {
+			result.AddError(paramName, fmt.Sprintf("failed to evaluate default: %s", err), paramDef.Default)
+			continue
+		}
+
+		processedValue, err := validateInputValue(paramName, value, paramDef)
+		if err != nil {
+			result.AddError(paramName, fmt.Sprintf("invalid default: %s", err), value)
+			continue
+		}
+
+		result.ProcessedInputs[paramName] = processedValue
+	}
+
 	for inputName := range providedInputs {
 		if _, defined := workflow.Inputs[inputName]; !defined {
 			result.AddError(inputName, "unexpected input field", providedInputs[inputName])
@@ -87,8 +135,21 @@ func ValidateWorkflowInputs(workflow *ast.Workflow, providedInputs map[string]an
 	return result
 }
 
+// evaluateInputDefault evaluates an expression default value against the
+// inputs resolved so far.
+func evaluateInputDefault(workflow *ast.Workflow, param *ast.InputParam, inputs map[string]any) (any, error) {
+	runCtx := execcontext.RunContext{Context: context.Background()}
+	execCtx := execcontext.NewExecutionContext(runCtx, workflow, inputs, filepath.Dir(workflow.SourceFile))
+
+	return expression.NewTemplateEngine().Render(param.Default.(string), execCtx)
+}
+
 // validateInputValue validates a single input value against its parameter definition
 func validateInputValue(_ string, value any, param *ast.InputParam) (any, error) {
+	if param.Multiple {
+		return validateMultipleEnum(value, param)
+	}
+
 	if param.Type == "" {
 		return value, nil
 	}
@@ -114,6 +175,39 @@ func validateInputValue(_ string, value any, param *ast.InputParam) (any, error)
 	}
 }
 
+// validateOutputValue converts a rendered workflow output to the type it is
+// declared with. Arrays and objects rendered as JSON strings are decoded.
+func validateOutputValue(value any, output *ast.OutputSchema) (any, error) {
+	if output == nil || output.Type == "" {
+		return expression.ValueToString(value), nil
+	}
+
+	if value == nil {
+		return nil, fmt.Errorf("expected %s, got null", output.Type)
+	}
+
+	// rendered outputs are often strings with surrounding whitespace, e.g. the
+	// trailing newline of script output.
+	if s, ok := value.(string); ok && output.Type != "string" {
+		s = strings.TrimSpace(s)
+		value = s
+
+		if output.Type == "array" || output.Type == "object" {
+			var decoded any
+			if err := json.Unmarshal([]byte(s), &decoded); err == nil && decoded != nil {
+				value = decoded
+			}
+		}
+	}
+
+	converted, err := convertAndValidateType(value, output.Type)
+	if err != nil {
+		return nil, fmt.Errorf("expected %s: %w", output.Type, err)
+	}
+
+	return converted, nil
+}
+
 // convertAndValidateType converts and validates the basic type
 func convertAndValidateType(value any, expectedType string) (any, error) {
 	switch expectedType {
@@ -200,19 +294,63 @@ func validateStringConstraints(value string, param *ast.InputParam) (string, err
 	}
 
 	if len(param.Enum) > 0 {
-		found := false
-		for _, enumValue := range param.Enum {
-			if enumValue == value {
-				found = true
-				break
+		if err := validateEnumValue(value, param); err != nil {
+			return "", err
+		}
+	}
+
+	return value, nil
+}
+
+// validateEnumValue checks that the value is one of the allowed enum values
+func validateEnumValue(value string, param *ast.InputParam) error {
+	for _, option := range param.Enum {
+		if option.Value == value {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("value must be one of: %s", strings.Join(param.EnumValues(), ", "))
+}
+
+// validateMultipleEnum validates an input which accepts a list of enum
+// values. Values can be provided as a list or, as is common from the CLI,
+// a comma separated string.
+func validateMultipleEnum(value any, param *ast.InputParam) (any, error) {
+	var items []any
+	switch v := value.(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
 			}
 		}
-		if !found {
-			return "", fmt.Errorf("value must be one of: %s", strings.Join(param.Enum, ", "))
+	case []string:
+		for _, item := range v {
+			items = append(items, item)
 		}
+	case []any:
+		items = v
+	default:
+		return nil, fmt.Errorf("expected a list of values, got %T", value)
 	}
 
-	return value, nil
+	if _, err := validateArrayConstraints(items, param); err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("item %d: expected string, got %T", i, item)
+		}
+
+		if err := validateEnumValue(str, param); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	return items, nil
 }
 
 // validateNumericConstraints validates numeric constraints
@@ -2,6 +2,7 @@ package engine
 
 import (
 	"testing"
+	"time"
 
 	"github.com/lacquerai/lacquer/internal/ast"
 	"github.com/stretchr/testify/assert"
@@ -78,6 +79,57 @@ func TestValidateWorkflowInputs_DefaultValues(t *testing.T) {
 	assert.Equal(t, 5, result.ProcessedInputs["count"])
 }
 
+func TestValidateWorkflowInputs_ExpressionDefaults(t *testing.T) {
+	t.Setenv("LACQUER_TEST_USER", "octocat")
+
+	workflow := &ast.Workflow{
+		Inputs: map[string]*ast.InputParam{
+			"user": {
+				Type:    "string",
+				Default: "${{ env.LACQUER_TEST_USER }}",
+			},
+			"greeting": {
+				Type:    "string",
+				Default: "Hello ${{ inputs.user }}",
+			},
+			"date": {
+				Type:    "string",
+				Default: "${{ now('2006-01-02') }}",
+			},
+		},
+		Workflow: &ast.WorkflowDef{
+			Steps: []*ast.Step{{ID: "test"}},
+		},
+	}
+
+	result := ValidateWorkflowInputs(workflow, map[string]any{})
+	assert.True(t, result.Valid)
+	assert.Equal(t, "octocat", result.ProcessedInputs["user"])
+	assert.Equal(t, "Hello octocat", result.ProcessedInputs["greeting"])
+	assert.Equal(t, time.Now().Format("2006-01-02"), result.ProcessedInputs["date"])
+
+	result = ValidateWorkflowInputs(workflow, map[string]any{"user": "alice"})
+	assert.True(t, result.Valid)
+	assert.Equal(t, "Hello alice", result.ProcessedInputs["greeting"])
+}
+
+func TestValidateWorkflowInputs_DefaultCycle(t *testing.T) {
+	workflow := &ast.Workflow{
+		Inputs: map[string]*ast.InputParam{
+			"a": {Default: "${{ inputs.b }}"},
+			"b": {Default: "${{ inputs.a }}"},
+		},
+		Workflow: &ast.WorkflowDef{
+			Steps: []*ast.Step{{ID: "test"}},
+		},
+	}
+
+	result := ValidateWorkflowInputs(workflow, map[string]any{})
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "input defaults form a cycle: a -> b -> a", result.Errors[0].Message)
+}
+
 func TestValidateWorkflowInputs_TypeValidation(t *testing.T) {
 	workflow := &ast.Workflow{
 		Inputs: map[string]*ast.InputParam{
@@ -184,7 +236,7 @@ func TestValidateWorkflowInputs_StringConstraints(t *testing.T) {
 			Inputs: map[string]*ast.InputParam{
 				"size": {
 					Type: "string",
-					Enum: []string{"small", "medium", "large"},
+					Enum: []ast.EnumOption{{Value: "small"}, {Value: "medium"}, {Value: "large"}},
 				},
 			},
 			Workflow: &ast.WorkflowDef{
@@ -203,6 +255,40 @@ func TestValidateWorkflowInputs_StringConstraints(t *testing.T) {
 		assert.False(t, result.Valid)
 		assert.Contains(t, result.Errors[0].Message, "must be one of")
 	})
+
+	t.Run("Multiple enum validation", func(t *testing.T) {
+		maxItems := 2
+		workflow := &ast.Workflow{
+			Inputs: map[string]*ast.InputParam{
+				"sizes": {
+					Type:     "array",
+					Multiple: true,
+					MaxItems: &maxItems,
+					Enum:     []ast.EnumOption{{Value: "small", Label: "Small"}, {Value: "medium"}, {Value: "large"}},
+				},
+			},
+			Workflow: &ast.WorkflowDef{
+				Steps: []*ast.Step{{ID: "test"}},
+			},
+		}
+
+		result := ValidateWorkflowInputs(workflow, map[string]any{"sizes": []any{"small", "large"}})
+		assert.True(t, result.Valid)
+		assert.Equal(t, []any{"small", "large"}, result.ProcessedInputs["sizes"])
+
+		// comma separated values are accepted from the CLI
+		result = ValidateWorkflowInputs(workflow, map[string]any{"sizes": "small, medium"})
+		assert.True(t, result.Valid)
+		assert.Equal(t, []any{"small", "medium"}, result.ProcessedInputs["sizes"])
+
+		result = ValidateWorkflowInputs(workflow, map[string]any{"sizes": []any{"small", "huge"}})
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Errors[0].Message, "item 1: value must be one of")
+
+		result = ValidateWorkflowInputs(workflow, map[string]any{"sizes": []any{"small", "medium", "large"}})
+		assert.False(t, result.Valid)
+		assert.Contains(t, result.Errors[0].Message, "maximum allowed is 2")
+	})
 }
 
 func TestValidateWorkflowInputs_NumericConstraints(t *testing.T) {
@@ -326,7 +412,7 @@ func TestValidateWorkflowInputs_ComplexScenario(t *testing.T) {
 			},
 			"role": {
 				Type:    "string",
-				Enum:    []string{"user", "admin", "moderator"},
+				Enum:    []ast.EnumOption{{Value: "user"}, {Value: "admin"}, {Value: "moderator"}},
 				Default: "user",
 			},
 		},
@@ -386,3 +472,38 @@ func TestConvertAndValidateType_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateOutputValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		output   *ast.OutputSchema
+		expected any
+		err      string
+	}{
+		{name: "undeclared output is stringified", value: 42, output: nil, expected: "42"},
+		{name: "untyped output is stringified", value: true, output: &ast.OutputSchema{}, expected: "true"},
+		{name: "string", value: "hello", output: &ast.OutputSchema{Type: "string"}, expected: "hello"},
+		{name: "integer from string", value: "42", output: &ast.OutputSchema{Type: "integer"}, expected: 42},
+		{name: "boolean from string", value: "true", output: &ast.OutputSchema{Type: "boolean"}, expected: true},
+		{name: "array from JSON", value: `["a","b"]`, output: &ast.OutputSchema{Type: "array"}, expected: []any{"a", "b"}},
+		{name: "object", value: map[string]any{"a": 1}, output: &ast.OutputSchema{Type: "object"}, expected: map[string]any{"a": 1}},
+		{name: "invalid integer", value: "many", output: &ast.OutputSchema{Type: "integer"}, err: "expected integer"},
+		{name: "invalid object", value: "not json", output: &ast.OutputSchema{Type: "object"}, err: "expected object"},
+		{name: "null", value: nil, output: &ast.OutputSchema{Type: "array"}, err: "expected array, got null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := validateOutputValue(tt.value, tt.output)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
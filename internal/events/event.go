@@ -9,23 +9,31 @@ import (
 
 	"github.com/charmbracelet/lipgloss/v2"
 	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/utils"
 
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 )
 
-func NewToolUseEvent(stepID, actionID string, toolName string, runID string, text string) pkgEvents.ExecutionEvent {
-	input := text
-	if input == "" {
-		input = generateRandomUsageText(toolName)
+func NewToolUseEvent(stepID, actionID string, toolName string, runID string, text string, input map[string]interface{}) pkgEvents.ExecutionEvent {
+	if text == "" {
+		text = generateRandomUsageText(toolName)
+	}
+
+	metadata := map[string]interface{}{
+		pkgEvents.MetadataTool: toolName,
+	}
+	if input != nil {
+		metadata[pkgEvents.MetadataToolInput] = input
 	}
 
 	return pkgEvents.ExecutionEvent{
 		Type:      pkgEvents.EventStepActionStarted,
 		ActionID:  actionID,
-		Text:      input,
+		Text:      text,
 		Timestamp: time.Now(),
 		RunID:     runID,
 		StepID:    stepID,
+		Metadata:  metadata,
 	}
 }
 
@@ -37,23 +45,42 @@ func NewToolUseCompletedEvent(stepID, actionID string, toolName string, runID st
 		Timestamp: time.Now(),
 		RunID:     runID,
 		StepID:    stepID,
+		Metadata: map[string]interface{}{
+			pkgEvents.MetadataTool: toolName,
+		},
 	}
 }
 
-func NewToolUseFailedEvent(step *ast.Step, actionID string, toolName string, runID string) pkgEvents.ExecutionEvent {
+func NewToolUseFailedEvent(step *ast.Step, actionID string, toolName string, runID string, errMsg string) pkgEvents.ExecutionEvent {
 	return pkgEvents.ExecutionEvent{
 		Type:      pkgEvents.EventStepActionFailed,
 		ActionID:  actionID,
 		Timestamp: time.Now(),
 		RunID:     runID,
 		StepID:    step.ID,
+		Error:     errMsg,
+		Metadata: map[string]interface{}{
+			pkgEvents.MetadataTool: toolName,
+		},
+	}
+}
+
+func NewStateUpdatedEvent(stepID string, runID string, updates map[string]interface{}) pkgEvents.ExecutionEvent {
+	return pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventStateUpdated,
+		Timestamp: time.Now(),
+		RunID:     runID,
+		StepID:    stepID,
+		Metadata: map[string]interface{}{
+			pkgEvents.MetadataStateUpdates: updates,
+		},
 	}
 }
 
 func NewPromptAgentEvent(stepID, actionID string, runID string, prompt ...string) pkgEvents.ExecutionEvent {
 	text := generateRandomPromptingText()
 	if len(prompt) > 0 {
-		text = strings.Join(prompt, "\n")
+		text = utils.SanitizeText(strings.Join(prompt, "\n"), utils.NormalizationNone)
 	}
 	return pkgEvents.ExecutionEvent{
 		Type:      pkgEvents.EventStepActionStarted,
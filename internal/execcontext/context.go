@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/kv"
+	"github.com/lacquerai/lacquer/internal/pii"
+	"github.com/lacquerai/lacquer/internal/routing"
 	"github.com/lacquerai/lacquer/internal/utils"
 	"github.com/rs/zerolog"
 )
@@ -37,6 +41,30 @@ type ExecutionContext struct {
 	Environment map[string]string
 	Metadata    map[string]interface{}
 
+	// Each is the item and index of the for_each iteration the context runs,
+	// nil outside of for_each steps
+	Each map[string]interface{}
+
+	// Retry is the attempt and error of the failed step whose retry_if
+	// condition the context evaluates, nil otherwise
+	Retry map[string]interface{}
+
+	// PII holds the values replaced by tokens by tokenizePII and pii steps,
+	// shared by every context of the run so that the tokens can be restored
+	PII *pii.Vault
+
+	// KV is the values the workflow keeps between runs, used by kv_get,
+	// kv_set and kv steps, nil when the run has no key-value store
+	KV *kv.Bucket
+
+	// modelUsage is the usage of each model of the run keyed by provider
+	// and model, kept by the run's root context
+	modelUsage map[string]*TokenUsage
+
+	// promptVersions is the version of each prompt of the library used by
+	// the run, kept by the run's root context
+	promptVersions map[string]string
+
 	// Execution control
 	Context RunContext
 	Logger  zerolog.Logger
@@ -51,16 +79,46 @@ func (ec *ExecutionContext) Write(p []byte) (n int, err error) {
 
 // StepResult represents the result of executing a single step
 type StepResult struct {
-	StepID     string                 `json:"step_id"`
-	Status     StepStatus             `json:"status"`
-	StartTime  time.Time              `json:"start_time"`
-	EndTime    time.Time              `json:"end_time"`
-	Duration   time.Duration          `json:"duration"`
-	Output     map[string]interface{} `json:"output"`
-	Response   string                 `json:"response,omitempty"`
-	Error      error                  `json:"error,omitempty"`
-	TokenUsage *TokenUsage            `json:"token_usage,omitempty"`
-	Retries    int                    `json:"retries"`
+	StepID      string                 `json:"step_id"`
+	Status      StepStatus             `json:"status"`
+	StartTime   time.Time              `json:"start_time"`
+	EndTime     time.Time              `json:"end_time"`
+	Duration    time.Duration          `json:"duration"`
+	Output      map[string]interface{} `json:"output"`
+	Response    string                 `json:"response,omitempty"`
+	Error       error                  `json:"error,omitempty"`
+	TokenUsage  *TokenUsage            `json:"token_usage,omitempty"`
+	Routing     *routing.Decision      `json:"routing,omitempty"`
+	Retries     int                    `json:"retries"`
+	Repairs     int                    `json:"repairs,omitempty"`
+	RepairUsage *TokenUsage            `json:"repair_usage,omitempty"`
+	// Prompt is the version of the library's prompt used by an agent step,
+	// e.g. review@v3
+	Prompt string `json:"prompt,omitempty"`
+	// Environment is the versions of the dependencies the step ran with
+	Environment *StepEnvironment `json:"environment,omitempty"`
+}
+
+// StepEnvironment records the exact versions of the dependencies a step ran
+// with, so that its result can be attributed to them and reproduced.
+type StepEnvironment struct {
+	// Runtimes is the version of each runtime required by the workflow,
+	// keyed by name, for script steps
+	Runtimes map[string]string `json:"runtimes,omitempty" yaml:"runtimes,omitempty"`
+	// Image is the image of a container step, ImageDigest the digest it
+	// resolved to
+	Image       string `json:"image,omitempty" yaml:"image,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty" yaml:"image_digest,omitempty"`
+	// Block is the block used by a block step, BlockDigest the SHA-256 of
+	// its file
+	Block       string `json:"block,omitempty" yaml:"block,omitempty"`
+	BlockDigest string `json:"block_digest,omitempty" yaml:"block_digest,omitempty"`
+	// Provider and Model are the model of an agent step, ModelSnapshot the
+	// snapshot which served it when the provider reports one, e.g.
+	// gpt-4o-2024-08-06 for gpt-4o
+	Provider      string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Model         string `json:"model,omitempty" yaml:"model,omitempty"`
+	ModelSnapshot string `json:"model_snapshot,omitempty" yaml:"model_snapshot,omitempty"`
 }
 
 // StepStatus represents the execution status of a step
@@ -99,6 +157,7 @@ func NewExecutionContext(ctx RunContext, workflow *ast.Workflow, inputs map[stri
 		Cwd:         wd,
 		Environment: utils.GetEnvironmentVars(),
 		Metadata:    utils.BuildMetadata(workflow),
+		PII:         pii.NewVault(),
 		Context:     ctx,
 		Logger:      logger,
 		TotalSteps:  len(workflow.Workflow.Steps),
@@ -143,6 +202,8 @@ func (ec *ExecutionContext) NewChild(steps []*ast.Step) *ExecutionContext {
 		TotalSteps:  len(steps),
 		Environment: ec.Environment,
 		Metadata:    ec.Metadata,
+		PII:         ec.PII,
+		KV:          ec.KV,
 	}
 }
 
@@ -155,6 +216,34 @@ func (ec *ExecutionContext) GetInput(key string) (interface{}, bool) {
 	return value, exists
 }
 
+// GetEach returns the item and index of the innermost for_each iteration
+// the context runs in.
+func (ec *ExecutionContext) GetEach() (map[string]interface{}, bool) {
+	if ec.Each != nil {
+		return ec.Each, true
+	}
+
+	if ec.Parent != nil {
+		return ec.Parent.GetEach()
+	}
+
+	return nil, false
+}
+
+// GetRetry returns the attempt and error of the failed step whose retry_if
+// condition is evaluated in the context.
+func (ec *ExecutionContext) GetRetry() (map[string]interface{}, bool) {
+	if ec.Retry != nil {
+		return ec.Retry, true
+	}
+
+	if ec.Parent != nil {
+		return ec.Parent.GetRetry()
+	}
+
+	return nil, false
+}
+
 // GetState returns a state variable value
 func (ec *ExecutionContext) GetState(key string) (interface{}, bool) {
 	if ec.Parent != nil {
@@ -269,12 +358,17 @@ func (ec *ExecutionContext) GetWorkflowOutputs() map[string]interface{} {
 	return utils.CopyMap(ec.Outputs)
 }
 
-// GetStepResult returns the result of a specific step
+// GetStepResult returns the result of a specific step, sub steps can
+// reference the results of the steps of their parents
 func (ec *ExecutionContext) GetStepResult(stepID string) (*StepResult, bool) {
 	ec.mu.RLock()
-	defer ec.mu.RUnlock()
-
 	result, exists := ec.StepResults[stepID]
+	ec.mu.RUnlock()
+
+	if !exists && ec.Parent != nil {
+		return ec.Parent.GetStepResult(stepID)
+	}
+
 	return result, exists
 }
 
@@ -310,6 +404,22 @@ func (ec *ExecutionContext) GetMetadata(key string) (interface{}, bool) {
 	return value, exists
 }
 
+// GetTrigger returns what started the run and when it started, the start
+// time of the run's root context.
+func (ec *ExecutionContext) GetTrigger() (Trigger, time.Time) {
+	root := ec
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	trigger := ec.Context.Trigger
+	if trigger.Type == "" {
+		trigger.Type = TriggerCLI
+	}
+
+	return trigger, root.StartTime
+}
+
 // IncrementCurrentStep advances to the next step
 func (ec *ExecutionContext) IncrementCurrentStep() {
 	ec.mu.Lock()
@@ -336,6 +446,52 @@ func (ec *ExecutionContext) IsCancelled() bool {
 	}
 }
 
+// AddModelUsage adds the usage of a request to the usage of its model
+// across the run.
+func (ec *ExecutionContext) AddModelUsage(provider, model string, usage *TokenUsage) {
+	if usage == nil {
+		return
+	}
+
+	root := ec
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if root.modelUsage == nil {
+		root.modelUsage = make(map[string]*TokenUsage)
+	}
+	key := provider + "/" + model
+	if root.modelUsage[key] == nil {
+		root.modelUsage[key] = &TokenUsage{}
+	}
+	root.modelUsage[key].Add(usage)
+}
+
+// PromptVersion records the version of the named prompt of the library
+// used by the run, as chosen by choose, and returns it. choose is passed the
+// version the run used so far, empty when it hasn't used the prompt yet, so
+// that every step of a run can use the same version.
+func (ec *ExecutionContext) PromptVersion(name string, choose func(used string) string) string {
+	root := ec
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	root.mu.Lock()
+	defer root.mu.Unlock()
+
+	if root.promptVersions == nil {
+		root.promptVersions = make(map[string]string)
+	}
+	version := choose(root.promptVersions[name])
+	root.promptVersions[name] = version
+	return version
+}
+
 // GetExecutionSummary returns a summary of the execution
 func (ec *ExecutionContext) GetExecutionSummary() ExecutionSummary {
 	ec.mu.RLock()
@@ -365,9 +521,21 @@ func (ec *ExecutionContext) GetExecutionSummary() ExecutionSummary {
 	for _, result := range ec.StepResults {
 		if result.TokenUsage != nil {
 			summary.TotalTokens += result.TokenUsage.TotalTokens
+			summary.EstimatedCost += result.TokenUsage.Cost
 		}
 	}
 
+	if len(ec.modelUsage) > 0 {
+		summary.ModelUsage = make(map[string]TokenUsage, len(ec.modelUsage))
+		for key, usage := range ec.modelUsage {
+			summary.ModelUsage[key] = *usage
+		}
+	}
+
+	if len(ec.promptVersions) > 0 {
+		summary.PromptVersions = maps.Clone(ec.promptVersions)
+	}
+
 	return summary
 }
 
@@ -426,6 +594,12 @@ type ExecutionSummary struct {
 	Outputs       map[string]interface{} `json:"outputs,omitempty"`
 	TotalTokens   int                    `json:"total_tokens"`
 	EstimatedCost float64                `json:"estimated_cost"`
+	// ModelUsage is the usage of each model keyed by provider and model,
+	// e.g. anthropic/claude-sonnet-4-20250514
+	ModelUsage map[string]TokenUsage `json:"model_usage,omitempty"`
+	// PromptVersions is the version of each prompt of the library used by
+	// the run, keyed by the name of the prompt
+	PromptVersions map[string]string `json:"prompt_versions,omitempty"`
 }
 
 // ExecutionStatus represents the overall execution status
@@ -444,12 +618,53 @@ type TokenUsage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// Cost is the estimated cost of the usage in USD, zero when the pricing
+	// of the model is unknown
+	Cost float64 `json:"cost,omitempty"`
+	// Model is the model snapshot which served the request as reported by
+	// the provider, empty when it doesn't report one
+	Model string `json:"model,omitempty"`
+}
+
+// Add adds the usage of another model request, nil usage is ignored.
+func (u *TokenUsage) Add(other *TokenUsage) {
+	if other == nil {
+		return
+	}
+
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+	if other.Model != "" {
+		u.Model = other.Model
+	}
 }
 
 type RunContext struct {
 	Context context.Context
 	StdOut  io.Writer
 	StdErr  io.Writer
+	// Trigger is what started the run, available to expressions as trigger
+	Trigger Trigger
+}
+
+// Trigger types, what started a run
+const (
+	TriggerCLI      = "cli"
+	TriggerWatch    = "watch"
+	TriggerAPI      = "api"
+	TriggerSchedule = "schedule"
+)
+
+// Trigger describes what started a run, so that scheduled workflows can
+// adjust their behavior to the schedule they run on.
+type Trigger struct {
+	// Type is one of TriggerCLI, TriggerWatch, TriggerAPI or
+	// TriggerSchedule, TriggerCLI when it's empty
+	Type string
+	// Schedule is the cron expression scheduled runs are started on
+	Schedule string
 }
 
 func (rc RunContext) Write(p []byte) (n int, err error) {
@@ -0,0 +1,83 @@
+package expression
+
+import (
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/utils"
+)
+
+// comparisonOps are the operators whose operands are kept by assertions
+var comparisonOps = map[BinaryOpType]bool{
+	BinaryOpTypeEq:  true,
+	BinaryOpTypeNeq: true,
+	BinaryOpTypeLt:  true,
+	BinaryOpTypeGt:  true,
+	BinaryOpTypeLte: true,
+	BinaryOpTypeGte: true,
+}
+
+// Assertion is the outcome of an asserted expression. The operands of
+// comparisons such as a == b are kept so that a failed assertion can show
+// the actual and expected values.
+type Assertion struct {
+	Passed bool
+	// Op is the operator of a comparison, empty for other expressions
+	Op string
+	// Actual is the left operand of a comparison, or the value of other
+	// expressions
+	Actual interface{}
+	// Expected is the right operand of a comparison
+	Expected interface{}
+}
+
+// Assert evaluates a template asserting an expression, such as
+// ${{ steps.classify.outputs.label == "urgent" }}. Templates which aren't a
+// single comparison pass when they render to a true value, as conditions do.
+func (te *TemplateEngine) Assert(template string, execCtx *execcontext.ExecutionContext) (*Assertion, error) {
+	trimmed := strings.TrimSpace(template)
+	if match := VariablePattern.FindStringSubmatch(trimmed); match != nil && match[0] == trimmed && match[1] == "" {
+		expr, err := parseCache.parse(strings.TrimSpace(match[2]))
+		if binary, ok := expr.(*BinaryOpExpr); err == nil && ok && comparisonOps[binary.Op] {
+			return te.expressionEvaluator.compare(binary, execCtx)
+		}
+	}
+
+	value, err := te.Render(template, execCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Assertion{Passed: utils.SafeBool(value), Actual: value}, nil
+}
+
+// compare evaluates the operands of a comparison once, keeping them along
+// with its outcome.
+func (ee *ExpressionEvaluator) compare(expr *BinaryOpExpr, execCtx *execcontext.ExecutionContext) (*Assertion, error) {
+	evalCtx := &EvalContext{
+		Variables: NewVariableScope(execCtx),
+		Functions: ee.functions,
+		ExecCtx:   execCtx,
+	}
+
+	left, err := expr.Left.Eval(evalCtx)
+	if err != nil {
+		return nil, err
+	}
+	right, err := expr.Right.Eval(evalCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := (&BinaryOpExpr{Left: &LiteralExpr{Value: left}, Op: expr.Op, Right: &LiteralExpr{Value: right}}).Eval(evalCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		Passed:   ToBool(result),
+		Op:       string(expr.Op),
+		Actual:   left.GoValue(),
+		Expected: right.GoValue(),
+	}, nil
+}
@@ -0,0 +1,80 @@
+package expression
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultCacheSize is how many parsed expressions are kept, enough for the
+// distinct expressions of the workflows run by a server.
+const defaultCacheSize = 4096
+
+// parseCache keeps the parsed expressions of every evaluator, expressions
+// don't change once parsed so they can be shared.
+var parseCache = newExpressionCache(defaultCacheSize)
+
+// expressionCache is a least recently used cache of parsed expressions keyed
+// by their source, so that expressions rendered repeatedly, e.g. in the
+// prompt of a for_each or while step, are only parsed once.
+type expressionCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	mu      sync.Mutex
+}
+
+type cacheEntry struct {
+	source string
+	expr   Expression
+}
+
+func newExpressionCache(size int) *expressionCache {
+	return &expressionCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// parse returns the parsed expression of source, parsing it when it isn't
+// cached. Expressions which fail to parse aren't cached.
+func (c *expressionCache) parse(source string) (Expression, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[source]; ok {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+		return element.Value.(*cacheEntry).expr, nil
+	}
+	c.mu.Unlock()
+
+	expr, err := Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// another render may have parsed the same expression meanwhile
+	if element, ok := c.entries[source]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*cacheEntry).expr, nil
+	}
+
+	c.entries[source] = c.order.PushFront(&cacheEntry{source: source, expr: expr})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).source)
+	}
+
+	return expr, nil
+}
+
+// len returns the number of cached expressions.
+func (c *expressionCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
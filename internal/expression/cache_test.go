@@ -0,0 +1,120 @@
+package expression
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressionCache_Parse(t *testing.T) {
+	cache := newExpressionCache(2)
+
+	first, err := cache.parse("inputs.count + 1")
+	require.NoError(t, err)
+	again, err := cache.parse("inputs.count + 1")
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+	assert.Equal(t, 1, cache.len())
+
+	// expressions which fail to parse aren't cached
+	_, err = cache.parse("inputs.count +")
+	require.Error(t, err)
+	assert.Equal(t, 1, cache.len())
+}
+
+func TestExpressionCache_Evicts(t *testing.T) {
+	cache := newExpressionCache(2)
+
+	a, err := cache.parse("a")
+	require.NoError(t, err)
+	_, err = cache.parse("b")
+	require.NoError(t, err)
+
+	// using a makes b the least recently used expression
+	_, err = cache.parse("a")
+	require.NoError(t, err)
+	_, err = cache.parse("c")
+	require.NoError(t, err)
+	assert.Equal(t, 2, cache.len())
+
+	assert.Contains(t, cache.entries, "a")
+	assert.NotContains(t, cache.entries, "b")
+	assert.Contains(t, cache.entries, "c")
+
+	cached, err := cache.parse("a")
+	require.NoError(t, err)
+	assert.Same(t, a, cached)
+}
+
+func benchmarkExecutionContext() *execcontext.ExecutionContext {
+	workflow := &ast.Workflow{
+		Version:  "1.0",
+		Metadata: &ast.WorkflowMetadata{Name: "benchmark"},
+		Workflow: &ast.WorkflowDef{
+			Steps: []*ast.Step{{ID: "step1", Agent: "agent1", Prompt: "test"}},
+		},
+	}
+
+	return execcontext.NewExecutionContext(execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+	}, workflow, map[string]interface{}{"name": "Alice", "items": []interface{}{"a", "b", "c"}, "count": 3}, "")
+}
+
+// benchmarkTemplate is a prompt like those rendered for every iteration of a
+// for_each step.
+const benchmarkTemplate = `Hello ${{ inputs.name }}, summarise item ${{ inputs.count * 2 + 1 }}
+of ${{ length(inputs.items) }}: ${{ inputs.count > 2 ? 'many items' : 'few items' }}
+and ${{ contains(inputs.items, 'b') && inputs.name == 'Alice' }}`
+
+func BenchmarkTemplateEngine_Render(b *testing.B) {
+	te := NewTemplateEngine()
+	execCtx := benchmarkExecutionContext()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := te.Render(benchmarkTemplate, execCtx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkTemplateEngine_Render_Uncached renders with a cache too small to
+// keep the template's expressions, so every render parses them again.
+func BenchmarkTemplateEngine_Render_Uncached(b *testing.B) {
+	cache := parseCache
+	parseCache = newExpressionCache(0)
+	defer func() { parseCache = cache }()
+
+	te := NewTemplateEngine()
+	execCtx := benchmarkExecutionContext()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := te.Render(benchmarkTemplate, execCtx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExpressionCache_Parse(b *testing.B) {
+	cache := newExpressionCache(defaultCacheSize)
+	sources := make([]string, 100)
+	for i := range sources {
+		sources[i] = fmt.Sprintf("inputs.count * %d + length(inputs.items)", i)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := cache.parse(sources[i%len(sources)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -215,9 +215,16 @@ func NewExpressionEvaluator() *ExpressionEvaluator {
 	}
 }
 
-// Evaluate evaluates an expression
+// RegisterFunction makes a function available to the expressions evaluated
+// by this evaluator
+func (ee *ExpressionEvaluator) RegisterFunction(fn *FunctionDefinition) {
+	ee.functions.Register(fn)
+}
+
+// Evaluate evaluates an expression, parsed expressions are cached so that
+// evaluating the same expression again only evaluates it
 func (ee *ExpressionEvaluator) Evaluate(expression string, execCtx *execcontext.ExecutionContext) (interface{}, error) {
-	expr, err := Parse(expression)
+	expr, err := parseCache.parse(expression)
 	if err != nil {
 		return nil, fmt.Errorf("parse error: %w", err)
 	}
@@ -268,7 +275,7 @@ func (vs *VariableScope) Get(name string) (Value, error) {
 	parts := strings.Split(name, ".")
 	if len(parts) > 0 {
 		switch parts[0] {
-		case "inputs", "state", "steps", "metadata", "env", "workflow":
+		case "inputs", "state", "steps", "metadata", "env", "workflow", "each", "retry", "trigger":
 			resolver := &VariableResolver{}
 			val, err := resolver.ResolveVariable(name, vs.execCtx)
 			if err != nil {
@@ -8,8 +8,11 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/lacquerai/lacquer/internal/cron"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/pii"
 )
 
 // FunctionRegistry manages built-in functions for expressions
@@ -47,6 +50,10 @@ func NewFunctionRegistry() *FunctionRegistry {
 	fr.registerContextFunctions()
 	fr.registerFileFunctions()
 	fr.registerObjectFunctions()
+	fr.registerTimeFunctions()
+	fr.registerScheduleFunctions()
+	fr.registerPIIFunctions()
+	fr.registerKVFunctions()
 
 	return fr
 }
@@ -61,6 +68,13 @@ func (fr *FunctionRegistry) Call(name string, args []interface{}, execCtx *execc
 	return fn.Impl(args, execCtx)
 }
 
+// Register adds a function to the registry, replacing any function with the
+// same name. It's used for functions which need more than the execution
+// context, such as a model provider.
+func (fr *FunctionRegistry) Register(fn *FunctionDefinition) {
+	fr.functions[fn.Name] = fn
+}
+
 // GetFunctionDefinition returns the function definition for a given name
 func (fr *FunctionRegistry) GetFunctionDefinition(name string) (*FunctionDefinition, bool) {
 	fn, exists := fr.functions[name]
@@ -562,6 +576,375 @@ func (fr *FunctionRegistry) registerObjectFunctions() {
 	}
 }
 
+// registerTimeFunctions registers date and time functions
+func (fr *FunctionRegistry) registerTimeFunctions() {
+	// now(layout?) - returns the current time
+	fr.functions["now"] = &FunctionDefinition{
+		Name:        "now",
+		Description: "Returns the current time formatted as RFC 3339, or using the given Go time layout",
+		Args: []Argument{
+			{Name: "layout", Type: "string", Required: false},
+		},
+		Returns: "string",
+		Example: "now() → '2024-01-02T15:04:05Z', now('2006-01-02') → '2024-01-02'",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("now() accepts at most 1 argument")
+			}
+
+			layout := time.RFC3339
+			if len(args) == 1 {
+				layout = toString(args[0])
+			}
+
+			return time.Now().Format(layout), nil
+		},
+	}
+}
+
+// registerScheduleFunctions registers functions scheduled workflows use to
+// adjust to the day they run on. Dates default to the time the run was
+// triggered.
+func (fr *FunctionRegistry) registerScheduleFunctions() {
+	// is_weekend(date?) - returns whether the date is a Saturday or Sunday
+	fr.functions["is_weekend"] = &FunctionDefinition{
+		Name:        "is_weekend",
+		Description: "Returns true if the date, the time the run was triggered by default, is a Saturday or Sunday",
+		Args: []Argument{
+			{Name: "date", Type: "string", Required: false},
+		},
+		Returns: "boolean",
+		Example: "is_weekend('2024-01-06') → true",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("is_weekend() accepts at most 1 argument")
+			}
+
+			date, err := scheduleDate("is_weekend", args, execCtx)
+			if err != nil {
+				return nil, err
+			}
+
+			return isWeekend(date), nil
+		},
+	}
+
+	// weekday(date?) - returns the name of the day of the week
+	fr.functions["weekday"] = &FunctionDefinition{
+		Name:        "weekday",
+		Description: "Returns the lower case name of the day of the week of the date, the time the run was triggered by default",
+		Args: []Argument{
+			{Name: "date", Type: "string", Required: false},
+		},
+		Returns: "string",
+		Example: "weekday('2024-01-01') → 'monday'",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) > 1 {
+				return nil, fmt.Errorf("weekday() accepts at most 1 argument")
+			}
+
+			date, err := scheduleDate("weekday", args, execCtx)
+			if err != nil {
+				return nil, err
+			}
+
+			return strings.ToLower(date.Weekday().String()), nil
+		},
+	}
+
+	// business_days_between(from, to) - returns the number of weekdays between two dates
+	fr.functions["business_days_between"] = &FunctionDefinition{
+		Name:        "business_days_between",
+		Description: "Returns the number of Mondays to Fridays from the first date up to, but not including, the second date, negative when the second date is earlier",
+		Args: []Argument{
+			{Name: "from", Type: "string", Required: true},
+			{Name: "to", Type: "string", Required: true},
+		},
+		Returns: "number",
+		Example: "business_days_between('2024-01-05', '2024-01-09') → 2",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("business_days_between() requires exactly 2 arguments")
+			}
+
+			from, err := parseDate("business_days_between", args[0])
+			if err != nil {
+				return nil, err
+			}
+			to, err := parseDate("business_days_between", args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			return businessDaysBetween(from, to), nil
+		},
+	}
+
+	// cron_matches(expression, date?) - returns whether a cron expression fires at the date
+	fr.functions["cron_matches"] = &FunctionDefinition{
+		Name:        "cron_matches",
+		Description: "Returns true if the cron expression fires at the minute of the date, the time the run was triggered by default",
+		Args: []Argument{
+			{Name: "expression", Type: "string", Required: true},
+			{Name: "date", Type: "string", Required: false},
+		},
+		Returns: "boolean",
+		Example: "cron_matches('0 9 * * 1') → true on Mondays at 9:00",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("cron_matches() requires 1 or 2 arguments")
+			}
+
+			schedule, err := cron.Parse(toString(args[0]))
+			if err != nil {
+				return nil, err
+			}
+
+			date, err := scheduleDate("cron_matches", args[1:], execCtx)
+			if err != nil {
+				return nil, err
+			}
+
+			return schedule.Matches(date), nil
+		},
+	}
+}
+
+// scheduleDate returns the date passed to a schedule function, or the time
+// the run was triggered when it isn't passed.
+func scheduleDate(name string, args []interface{}, execCtx *execcontext.ExecutionContext) (time.Time, error) {
+	if len(args) == 0 {
+		if execCtx == nil {
+			return time.Now(), nil
+		}
+
+		_, start := execCtx.GetTrigger()
+		return start, nil
+	}
+
+	return parseDate(name, args[0])
+}
+
+// parseDate parses an RFC 3339 time or a date such as 2024-01-02, in the
+// local time zone.
+func parseDate(name string, value interface{}) (time.Time, error) {
+	text := toString(value)
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, text, time.Local); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%s() date %q must be an RFC 3339 time or a date such as 2024-01-02", name, text)
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// businessDaysBetween counts the weekdays from the day of from up to the day
+// of to.
+func businessDaysBetween(from, to time.Time) int {
+	sign := 1
+	if to.Before(from) {
+		from, to = to, from
+		sign = -1
+	}
+
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+
+	days := int(to.Sub(from).Hours() / 24)
+	count := days / 7 * 5
+	for day := from.AddDate(0, 0, days/7*7); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !isWeekend(day) {
+			count++
+		}
+	}
+
+	return sign * count
+}
+
+// registerPIIFunctions registers functions detecting and masking personally
+// identifiable information
+func (fr *FunctionRegistry) registerPIIFunctions() {
+	// detectPII(text, types?) - returns the PII found in text
+	fr.functions["detectPII"] = &FunctionDefinition{
+		Name:        "detectPII",
+		Description: "Returns the emails, phone numbers, credit card numbers and national IDs found in text, optionally only of the given types",
+		Args: []Argument{
+			{Name: "text", Type: "string", Required: true},
+			{Name: "types", Type: "array", Required: false},
+		},
+		Returns: "array",
+		Example: "detectPII('mail jane@example.com') → [{type: 'email', value: 'jane@example.com'}]",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			types, err := piiArgs("detectPII", args)
+			if err != nil {
+				return nil, err
+			}
+
+			matches := pii.Detect(toString(args[0]), types...)
+			result := make([]interface{}, len(matches))
+			for i, match := range matches {
+				result[i] = map[string]interface{}{
+					"type":  string(match.Type),
+					"value": match.Value,
+				}
+			}
+
+			return result, nil
+		},
+	}
+
+	// maskPII(text, types?) - replaces the PII in text with placeholders
+	fr.functions["maskPII"] = &FunctionDefinition{
+		Name:        "maskPII",
+		Description: "Replaces the PII found in text with a placeholder naming its type, optionally only of the given types",
+		Args: []Argument{
+			{Name: "text", Type: "string", Required: true},
+			{Name: "types", Type: "array", Required: false},
+		},
+		Returns: "string",
+		Example: "maskPII('mail jane@example.com') → 'mail [EMAIL]'",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			types, err := piiArgs("maskPII", args)
+			if err != nil {
+				return nil, err
+			}
+
+			return pii.Mask(toString(args[0]), types...), nil
+		},
+	}
+
+	// tokenizePII(text, types?) - replaces the PII in text with tokens which unmaskPII restores
+	fr.functions["tokenizePII"] = &FunctionDefinition{
+		Name:        "tokenizePII",
+		Description: "Replaces the PII found in text with tokens which unmaskPII turns back into the original values later in the run",
+		Args: []Argument{
+			{Name: "text", Type: "string", Required: true},
+			{Name: "types", Type: "array", Required: false},
+		},
+		Returns: "string",
+		Example: "tokenizePII('mail jane@example.com') → 'mail [EMAIL_1]'",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			types, err := piiArgs("tokenizePII", args)
+			if err != nil {
+				return nil, err
+			}
+			if execCtx == nil || execCtx.PII == nil {
+				return nil, fmt.Errorf("tokenizePII() requires an execution context")
+			}
+
+			return execCtx.PII.Tokenize(toString(args[0]), types...), nil
+		},
+	}
+
+	// unmaskPII(text) - restores the PII replaced by tokenizePII
+	fr.functions["unmaskPII"] = &FunctionDefinition{
+		Name:        "unmaskPII",
+		Description: "Replaces the tokens created by tokenizePII or a pii step in text with the original values",
+		Args: []Argument{
+			{Name: "text", Type: "string", Required: true},
+		},
+		Returns: "string",
+		Example: "unmaskPII('Dear [EMAIL_1]') → 'Dear jane@example.com'",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("unmaskPII() requires exactly 1 argument")
+			}
+			if execCtx == nil || execCtx.PII == nil {
+				return toString(args[0]), nil
+			}
+
+			return execCtx.PII.Restore(toString(args[0])), nil
+		},
+	}
+}
+
+// registerKVFunctions registers the functions reading and writing the values
+// the workflow keeps between runs
+func (fr *FunctionRegistry) registerKVFunctions() {
+	// kv_get(key, default?) - returns the value of key kept from an earlier run
+	fr.functions["kv_get"] = &FunctionDefinition{
+		Name:        "kv_get",
+		Description: "Returns the value of a key the workflow set in this or an earlier run, or the default when it isn't set",
+		Args: []Argument{
+			{Name: "key", Type: "string", Required: true},
+			{Name: "default", Type: "any", Required: false},
+		},
+		Returns: "any",
+		Example: "kv_get('cursor', 0) → 42",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("kv_get() requires 1 or 2 arguments")
+			}
+			if execCtx == nil || execCtx.KV == nil {
+				return nil, fmt.Errorf("kv_get() requires a key-value store")
+			}
+
+			value, ok, err := execCtx.KV.Get(toString(args[0]))
+			if err != nil {
+				return nil, fmt.Errorf("kv_get(): %w", err)
+			}
+			if !ok && len(args) == 2 {
+				return args[1], nil
+			}
+
+			return value, nil
+		},
+	}
+
+	// kv_set(key, value) - keeps value for later runs, returning it
+	fr.functions["kv_set"] = &FunctionDefinition{
+		Name:        "kv_set",
+		Description: "Sets the value of a key for later steps and runs of the workflow, returning the value",
+		Args: []Argument{
+			{Name: "key", Type: "string", Required: true},
+			{Name: "value", Type: "any", Required: true},
+		},
+		Returns: "any",
+		Example: "kv_set('cursor', 42) → 42",
+		Impl: func(args []interface{}, execCtx *execcontext.ExecutionContext) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("kv_set() requires exactly 2 arguments")
+			}
+			if execCtx == nil || execCtx.KV == nil {
+				return nil, fmt.Errorf("kv_set() requires a key-value store")
+			}
+
+			if _, _, err := execCtx.KV.Set(toString(args[0]), args[1]); err != nil {
+				return nil, fmt.Errorf("kv_set(): %w", err)
+			}
+
+			return args[1], nil
+		},
+	}
+}
+
+// piiArgs checks the arguments of the PII functions taking a text and an
+// optional list of types, returning the types
+func piiArgs(name string, args []interface{}) ([]pii.Type, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("%s() requires 1 or 2 arguments", name)
+	}
+
+	var names []string
+	if len(args) == 2 {
+		for _, item := range toArray(args[1]) {
+			names = append(names, toString(item))
+		}
+	}
+
+	types, err := pii.ParseTypes(names)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", name, err)
+	}
+
+	return types, nil
+}
+
 // Helper functions for type conversion
 
 func toString(v interface{}) string {
@@ -2,8 +2,10 @@ package expression
 
 import (
 	"testing"
+	"time"
 
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/kv"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -210,6 +212,130 @@ func TestFunctionRegistry_ContextFunctions(t *testing.T) {
 	})
 }
 
+func TestFunctionRegistry_TimeFunctions(t *testing.T) {
+	fr := NewFunctionRegistry()
+	execCtx := createTestExecutionContext()
+
+	t.Run("now function", func(t *testing.T) {
+		result, err := fr.Call("now", []interface{}{}, execCtx)
+		require.NoError(t, err)
+		_, err = time.Parse(time.RFC3339, result.(string))
+		assert.NoError(t, err)
+
+		result, err = fr.Call("now", []interface{}{"2006-01-02"}, execCtx)
+		require.NoError(t, err)
+		assert.Equal(t, time.Now().Format("2006-01-02"), result)
+
+		_, err = fr.Call("now", []interface{}{"a", "b"}, execCtx)
+		assert.Error(t, err)
+	})
+}
+
+func TestFunctionRegistry_ScheduleFunctions(t *testing.T) {
+	fr := NewFunctionRegistry()
+	execCtx := createTestExecutionContext()
+	// Saturday
+	execCtx.StartTime = time.Date(2024, 1, 6, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		function string
+		args     []interface{}
+		expected interface{}
+		err      string
+	}{
+		{name: "weekend trigger time", function: "is_weekend", expected: true},
+		{name: "weekday date", function: "is_weekend", args: []interface{}{"2024-01-08"}, expected: false},
+		{name: "RFC 3339 time", function: "is_weekend", args: []interface{}{"2024-01-07T23:00:00Z"}, expected: true},
+		{name: "invalid date", function: "is_weekend", args: []interface{}{"next monday"}, err: `is_weekend() date "next monday" must be an RFC 3339 time or a date such as 2024-01-02`},
+		{name: "weekday of trigger time", function: "weekday", expected: "saturday"},
+		{name: "business days over a weekend", function: "business_days_between", args: []interface{}{"2024-01-05", "2024-01-09"}, expected: 2},
+		{name: "business days over weeks", function: "business_days_between", args: []interface{}{"2024-01-01", "2024-01-31"}, expected: 22},
+		{name: "business days backwards", function: "business_days_between", args: []interface{}{"2024-01-09", "2024-01-05"}, expected: -2},
+		{name: "business days of the same day", function: "business_days_between", args: []interface{}{"2024-01-08", "2024-01-08T18:00:00Z"}, expected: 0},
+		{name: "business days requires 2 dates", function: "business_days_between", args: []interface{}{"2024-01-08"}, err: "business_days_between() requires exactly 2 arguments"},
+		{name: "cron matches trigger time", function: "cron_matches", args: []interface{}{"30 9 * * sat,sun"}, expected: true},
+		{name: "cron doesn't match date", function: "cron_matches", args: []interface{}{"30 9 * * 1-5", "2024-01-06T09:30:00Z"}, expected: false},
+		{name: "invalid cron", function: "cron_matches", args: []interface{}{"30 9 * *"}, err: `invalid cron expression "30 9 * *", must have 5 fields: minute hour day-of-month month day-of-week`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fr.Call(tt.function, tt.args, execCtx)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestFunctionRegistry_PIIFunctions(t *testing.T) {
+	fr := NewFunctionRegistry()
+	execCtx := createTestExecutionContext()
+	text := "Mail jane@example.com or call 555-123-4567"
+
+	t.Run("detectPII function", func(t *testing.T) {
+		result, err := fr.Call("detectPII", []interface{}{text}, execCtx)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"type": "email", "value": "jane@example.com"},
+			map[string]interface{}{"type": "phone", "value": "555-123-4567"},
+		}, result)
+
+		_, err = fr.Call("detectPII", []interface{}{text, "address"}, execCtx)
+		assert.ErrorContains(t, err, "unknown PII type")
+	})
+
+	t.Run("maskPII function", func(t *testing.T) {
+		result, err := fr.Call("maskPII", []interface{}{text}, execCtx)
+		require.NoError(t, err)
+		assert.Equal(t, "Mail [EMAIL] or call [PHONE]", result)
+
+		result, err = fr.Call("maskPII", []interface{}{text, []interface{}{"phone"}}, execCtx)
+		require.NoError(t, err)
+		assert.Equal(t, "Mail jane@example.com or call [PHONE]", result)
+	})
+
+	t.Run("tokenizePII and unmaskPII functions", func(t *testing.T) {
+		result, err := fr.Call("tokenizePII", []interface{}{text}, execCtx)
+		require.NoError(t, err)
+		assert.Equal(t, "Mail [EMAIL_1] or call [PHONE_1]", result)
+
+		// tokens are shared with the contexts of sub steps
+		child := execCtx.NewChild(nil)
+		result, err = fr.Call("unmaskPII", []interface{}{"Dear [EMAIL_1]"}, child)
+		require.NoError(t, err)
+		assert.Equal(t, "Dear jane@example.com", result)
+	})
+}
+
+func TestFunctionRegistry_KVFunctions(t *testing.T) {
+	fr := NewFunctionRegistry()
+	execCtx := createTestExecutionContext()
+
+	_, err := fr.Call("kv_get", []interface{}{"cursor"}, execCtx)
+	assert.ErrorContains(t, err, "requires a key-value store")
+
+	execCtx.KV = kv.NewStore(t.TempDir(), nil).Bucket("workflow.laq.yml")
+
+	result, err := fr.Call("kv_get", []interface{}{"cursor", 0}, execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result)
+
+	result, err = fr.Call("kv_set", []interface{}{"cursor", "page-2"}, execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "page-2", result)
+
+	// the store is shared with the contexts of sub steps
+	result, err = fr.Call("kv_get", []interface{}{"cursor", 0}, execCtx.NewChild(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "page-2", result)
+}
+
 func TestFunctionRegistry_ObjectFunctions(t *testing.T) {
 	fr := NewFunctionRegistry()
 	execCtx := createTestExecutionContext()
@@ -300,6 +426,9 @@ func TestFunctionRegistry_AllSupportedFunctions(t *testing.T) {
 		"hashFiles",
 		"glob",
 		"keys", "values", "length",
+		"now",
+		"is_weekend", "weekday", "business_days_between", "cron_matches",
+		"detectPII", "maskPII", "tokenizePII", "unmaskPII",
 	}
 
 	// Test that all functions exist (don't error on unknown function)
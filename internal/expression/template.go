@@ -13,6 +13,11 @@ import (
 // VariablePattern is a regular expression that matches variable references in a template.
 var VariablePattern = regexp.MustCompile(`(\$)?\$\{\{\s*(.*?)\s*\}\}`)
 
+// commentPattern matches a trailing comment of a template, which starts with // at the
+// start of the template or after whitespace so that urls such as https://example.com
+// aren't mistaken for comments.
+var commentPattern = regexp.MustCompile(`(^|\s)//`)
+
 // TemplateEngine handles variable interpolation and template rendering
 type TemplateEngine struct {
 	// Expression evaluator for complex expressions
@@ -26,6 +31,12 @@ func NewTemplateEngine() *TemplateEngine {
 	}
 }
 
+// RegisterFunction makes a function available to the templates rendered by
+// this engine
+func (te *TemplateEngine) RegisterFunction(fn *FunctionDefinition) {
+	te.expressionEvaluator.RegisterFunction(fn)
+}
+
 // Render renders a template string with variables from the execution context
 func (te *TemplateEngine) Render(template string, execCtx *execcontext.ExecutionContext) (interface{}, error) {
 	if template == "" {
@@ -40,8 +51,8 @@ func (te *TemplateEngine) Render(template string, execCtx *execcontext.Execution
 
 	result := template
 	// Strip trailing comments (anything after //)
-	if commentIndex := strings.Index(result, "//"); commentIndex != -1 {
-		result = strings.TrimSpace(result[:commentIndex])
+	if comment := commentPattern.FindStringIndex(result); comment != nil {
+		result = strings.TrimSpace(result[:comment[0]])
 	}
 
 	if result == "" {
@@ -191,7 +202,7 @@ func (vr *VariableResolver) ResolveVariable(varPath string, execCtx *execcontext
 			return nil, fmt.Errorf("step %s not found", stepID)
 		}
 
-		return result.Output, nil
+		return stepVariables(result), nil
 	case "metadata":
 		if len(parts) < 2 {
 			return nil, fmt.Errorf("metadata variable requires a field name")
@@ -215,11 +226,94 @@ func (vr *VariableResolver) ResolveVariable(varPath string, execCtx *execcontext
 	case "workflow":
 		return vr.resolveWorkflowVariable(parts[1:], execCtx)
 
+	case "each":
+		each, ok := execCtx.GetEach()
+		if !ok {
+			return nil, fmt.Errorf("each is only available in for_each steps")
+		}
+		if len(parts) == 1 {
+			return each, nil
+		}
+
+		value, exists := each[parts[1]]
+		if !exists {
+			return nil, fmt.Errorf("each.%s not found, use each.item or each.index", parts[1])
+		}
+		return vr.resolveNestedPath(value, parts[2:])
+
+	case "retry":
+		retry, ok := execCtx.GetRetry()
+		if !ok {
+			return nil, fmt.Errorf("retry is only available in retry_if conditions")
+		}
+		if len(parts) == 1 {
+			return retry, nil
+		}
+
+		value, exists := retry[parts[1]]
+		if !exists {
+			return nil, fmt.Errorf("retry.%s not found, use retry.error or retry.attempt", parts[1])
+		}
+		return value, nil
+
+	case "trigger":
+		trigger, start := execCtx.GetTrigger()
+		variables := map[string]interface{}{
+			"type":     trigger.Type,
+			"schedule": trigger.Schedule,
+			"time":     start.Format(time.RFC3339),
+		}
+		if len(parts) == 1 {
+			return variables, nil
+		}
+
+		value, exists := variables[parts[1]]
+		if !exists {
+			return nil, fmt.Errorf("trigger.%s not found, use trigger.type, trigger.schedule or trigger.time", parts[1])
+		}
+		return value, nil
+
 	default:
 		return nil, fmt.Errorf("unknown variable scope: %s", parts[0])
 	}
 }
 
+// stepVariables returns the outputs of a step along with how long it ran,
+// the tokens it used and its status and error, so later steps can adapt to
+// what earlier steps spent or whether they failed. Outputs of the step with
+// the same names take precedence.
+func stepVariables(result *execcontext.StepResult) map[string]interface{} {
+	tokens := map[string]interface{}{
+		"prompt":     0,
+		"completion": 0,
+		"total":      0,
+	}
+	var cost float64
+	if result.TokenUsage != nil {
+		tokens["prompt"] = result.TokenUsage.PromptTokens
+		tokens["completion"] = result.TokenUsage.CompletionTokens
+		tokens["total"] = result.TokenUsage.TotalTokens
+		cost = result.TokenUsage.Cost
+	}
+
+	var stepErr string
+	if result.Error != nil {
+		stepErr = result.Error.Error()
+	}
+
+	variables := make(map[string]interface{}, len(result.Output)+5)
+	variables["duration_ms"] = result.Duration.Milliseconds()
+	variables["tokens"] = tokens
+	variables["cost"] = cost
+	variables["status"] = string(result.Status)
+	variables["error"] = stepErr
+	for key, value := range result.Output {
+		variables[key] = value
+	}
+
+	return variables
+}
+
 // resolveWorkflowVariable resolves workflow-level variables
 func (vr *VariableResolver) resolveWorkflowVariable(parts []string, execCtx *execcontext.ExecutionContext) (interface{}, error) {
 	if len(parts) == 0 {
@@ -2,8 +2,10 @@ package expression
 
 import (
 	"context"
+	"errors"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/lacquerai/lacquer/internal/ast"
 	"github.com/lacquerai/lacquer/internal/execcontext"
@@ -141,6 +143,42 @@ func TestTemplateEngine_WorkflowVariables(t *testing.T) {
 	assert.Contains(t, result, "Run ID: run_")
 }
 
+func TestTemplateEngine_TriggerVariables(t *testing.T) {
+	te := NewTemplateEngine()
+
+	workflow := &ast.Workflow{
+		Version: "1.0",
+		Workflow: &ast.WorkflowDef{
+			Steps: []*ast.Step{
+				{ID: "step1", Agent: "agent1", Prompt: "test"},
+			},
+		},
+	}
+
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+		Trigger: execcontext.Trigger{Type: execcontext.TriggerSchedule, Schedule: "0 9 * * 1"},
+	}, workflow, nil, "")
+	execCtx.StartTime = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	result, err := te.Render("${{ trigger.type }} on ${{ trigger.schedule }} at ${{ trigger.time }}", execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "schedule on 0 9 * * 1 at 2024-01-01T09:00:00Z", result)
+
+	// child contexts use the time the run was triggered
+	result, err = te.Render("${{ cron_matches(trigger.schedule) ? 'weekly digest' : 'daily digest' }}", execCtx.NewChild(workflow.Workflow.Steps))
+	require.NoError(t, err)
+	assert.Equal(t, "weekly digest", result)
+
+	// runs default to being triggered from the CLI
+	execCtx.Context.Trigger = execcontext.Trigger{}
+	result, err = te.Render("${{ trigger.type }}", execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "cli", result)
+}
+
 func TestTemplateEngine_EnvironmentVariables(t *testing.T) {
 	te := NewTemplateEngine()
 
@@ -202,6 +240,34 @@ func TestTemplateEngine_NoVariables(t *testing.T) {
 	assert.Equal(t, "", result)
 }
 
+func TestTemplateEngine_Comments(t *testing.T) {
+	te := NewTemplateEngine()
+
+	workflow := &ast.Workflow{
+		Version: "1.0",
+		Workflow: &ast.WorkflowDef{
+			Steps: []*ast.Step{
+				{ID: "step1", Agent: "agent1", Prompt: "test"},
+			},
+		},
+	}
+
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+	}, workflow, map[string]interface{}{"id": 42}, "")
+
+	result, err := te.Render("Issue ${{ inputs.id }} // the issue to triage", execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "Issue 42", result)
+
+	// urls aren't comments
+	result, err = te.Render("https://api.example.com/issues/${{ inputs.id }}", execCtx)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/issues/42", result)
+}
+
 func TestTemplateEngine_Errors(t *testing.T) {
 	te := NewTemplateEngine()
 
@@ -572,6 +638,73 @@ func TestTemplateEngine_ComplexWorkflowScenarios(t *testing.T) {
 
 }
 
+func TestTemplateEngine_StepTelemetry(t *testing.T) {
+	te := NewTemplateEngine()
+
+	workflow := &ast.Workflow{
+		Version: "1.0",
+		Workflow: &ast.WorkflowDef{
+			Steps: []*ast.Step{
+				{ID: "research", Agent: "agent1", Prompt: "Research"},
+				{ID: "fetch", Run: "echo done"},
+			},
+		},
+	}
+
+	execCtx := execcontext.NewExecutionContext(execcontext.RunContext{
+		Context: context.Background(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+	}, workflow, nil, "")
+
+	execCtx.SetStepResult("research", &execcontext.StepResult{
+		StepID:   "research",
+		Status:   execcontext.StepStatusCompleted,
+		Duration: 1500 * time.Millisecond,
+		Output:   map[string]interface{}{"output": "Findings"},
+		TokenUsage: &execcontext.TokenUsage{
+			PromptTokens:     9000,
+			CompletionTokens: 1000,
+			TotalTokens:      10000,
+			Cost:             0.25,
+		},
+	})
+	execCtx.SetStepResult("fetch", &execcontext.StepResult{
+		StepID: "fetch",
+		Status: execcontext.StepStatusCompleted,
+		Output: map[string]interface{}{"output": "done"},
+	})
+	execCtx.SetStepResult("upload", &execcontext.StepResult{
+		StepID: "upload",
+		Status: execcontext.StepStatusFailed,
+		Error:  errors.New("connection refused"),
+	})
+
+	testCases := []struct {
+		template string
+		expected interface{}
+	}{
+		{template: "${{ steps.research.duration_ms }}", expected: float64(1500)},
+		{template: "${{ steps.research.tokens.total }}", expected: float64(10000)},
+		{template: "${{ steps.research.tokens.prompt }}", expected: float64(9000)},
+		{template: "${{ steps.research.cost }}", expected: 0.25},
+		{template: "${{ steps.research.tokens.total > 5000 ? 'cheap' : 'thorough' }}", expected: "cheap"},
+		{template: "${{ steps.research.output }}", expected: "Findings"},
+		// steps which used no tokens report zero
+		{template: "${{ steps.fetch.tokens.total }}", expected: float64(0)},
+		{template: "${{ steps.fetch.status }}", expected: "completed"},
+		{template: "${{ steps.fetch.error }}", expected: ""},
+		{template: "${{ steps.upload.status }}", expected: "failed"},
+		{template: "${{ steps.upload.error }}", expected: "connection refused"},
+	}
+
+	for _, tc := range testCases {
+		result, err := te.Render(tc.template, execCtx)
+		require.NoError(t, err, tc.template)
+		assert.Equal(t, tc.expected, result, tc.template)
+	}
+}
+
 func TestTemplateEngine_EscapeCharacters(t *testing.T) {
 	te := NewTemplateEngine()
 	workflow := &ast.Workflow{
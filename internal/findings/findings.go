@@ -0,0 +1,280 @@
+// Package findings converts the findings of a review, such as the structured
+// output of a code review agent, into SARIF and GitHub annotations so they
+// can be shown in code scanning UIs.
+package findings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatSARIF  = "sarif"
+	FormatGitHub = "github"
+)
+
+// Formats lists the supported file formats.
+var Formats = []string{FormatSARIF, FormatGitHub}
+
+// Levels of findings, as named by SARIF.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// severityLevels maps the severities reviewers commonly use to a level.
+var severityLevels = map[string]string{
+	"":           LevelWarning,
+	"critical":   LevelError,
+	"blocker":    LevelError,
+	"high":       LevelError,
+	"error":      LevelError,
+	"major":      LevelWarning,
+	"medium":     LevelWarning,
+	"moderate":   LevelWarning,
+	"warning":    LevelWarning,
+	"warn":       LevelWarning,
+	"low":        LevelNote,
+	"minor":      LevelNote,
+	"info":       LevelNote,
+	"note":       LevelNote,
+	"nit":        LevelNote,
+	"suggestion": LevelNote,
+}
+
+// Finding is a problem found at a location of a file.
+type Finding struct {
+	File    string
+	Line    int
+	EndLine int
+	Column  int
+	// Level is the severity of the finding, one of LevelError, LevelWarning
+	// or LevelNote
+	Level   string
+	Message string
+	Rule    string
+	Title   string
+}
+
+// Parse converts a list of objects into findings. Each object needs a
+// message and may have a file (or path), line (or start_line), end_line,
+// column, severity (or level), rule (or rule_id) and title.
+func Parse(value interface{}) ([]Finding, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of findings, got %T", value)
+	}
+
+	findings := make([]Finding, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("finding %d: expected an object, got %T", i, item)
+		}
+
+		finding, err := parseFinding(object)
+		if err != nil {
+			return nil, fmt.Errorf("finding %d: %w", i, err)
+		}
+		findings[i] = finding
+	}
+
+	return findings, nil
+}
+
+func parseFinding(object map[string]interface{}) (Finding, error) {
+	var finding Finding
+	var err error
+
+	finding.Message = stringField(object, "message")
+	if strings.TrimSpace(finding.Message) == "" {
+		return finding, fmt.Errorf("message is required")
+	}
+	finding.File = stringField(object, "file", "path")
+	finding.Rule = stringField(object, "rule", "rule_id")
+	finding.Title = stringField(object, "title")
+
+	if finding.Line, err = intField(object, "line", "start_line"); err != nil {
+		return finding, err
+	}
+	if finding.EndLine, err = intField(object, "end_line"); err != nil {
+		return finding, err
+	}
+	if finding.Column, err = intField(object, "column", "start_column"); err != nil {
+		return finding, err
+	}
+
+	severity := strings.ToLower(strings.TrimSpace(stringField(object, "severity", "level")))
+	level, ok := severityLevels[severity]
+	if !ok {
+		return finding, fmt.Errorf("unknown severity %q, use error, warning or note", severity)
+	}
+	finding.Level = level
+
+	return finding, nil
+}
+
+// stringField returns the first of the keys the object has as a string.
+func stringField(object map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := object[key]; ok && value != nil {
+			if s, ok := value.(string); ok {
+				return s
+			}
+			return fmt.Sprint(value)
+		}
+	}
+
+	return ""
+}
+
+// intField returns the first of the keys the object has as a positive
+// integer, 0 when it has none of them.
+func intField(object map[string]interface{}, keys ...string) (int, error) {
+	for _, key := range keys {
+		var n int
+		switch value := object[key].(type) {
+		case nil:
+			continue
+		case int:
+			n = value
+		case int64:
+			n = int(value)
+		case float64:
+			n = int(value)
+		case string:
+			parsed, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return 0, fmt.Errorf("%s must be a number, got %q", key, value)
+			}
+			n = parsed
+		default:
+			return 0, fmt.Errorf("%s must be a number, got %T", key, value)
+		}
+
+		if n < 0 {
+			return 0, fmt.Errorf("%s must be positive, got %d", key, n)
+		}
+		return n, nil
+	}
+
+	return 0, nil
+}
+
+// WriteSARIF writes the findings as a SARIF 2.1.0 log of a run of the named
+// tool, the format code scanning UIs such as GitHub's accept.
+func WriteSARIF(w io.Writer, findings []Finding, tool string) error {
+	rules := []map[string]interface{}{}
+	seen := map[string]bool{}
+	results := make([]map[string]interface{}, len(findings))
+	for i, finding := range findings {
+		rule := finding.ruleID()
+		if !seen[rule] {
+			seen[rule] = true
+			descriptor := map[string]interface{}{"id": rule}
+			if finding.Title != "" {
+				descriptor["shortDescription"] = map[string]interface{}{"text": finding.Title}
+			}
+			rules = append(rules, descriptor)
+		}
+
+		result := map[string]interface{}{
+			"ruleId":  rule,
+			"level":   finding.Level,
+			"message": map[string]interface{}{"text": finding.Message},
+		}
+		if finding.File != "" {
+			location := map[string]interface{}{
+				"artifactLocation": map[string]interface{}{"uri": finding.File},
+			}
+			if finding.Line > 0 {
+				region := map[string]interface{}{"startLine": finding.Line}
+				if finding.EndLine >= finding.Line {
+					region["endLine"] = finding.EndLine
+				}
+				if finding.Column > 0 {
+					region["startColumn"] = finding.Column
+				}
+				location["region"] = region
+			}
+			result["locations"] = []interface{}{map[string]interface{}{"physicalLocation": location}}
+		}
+		results[i] = result
+	}
+
+	log := map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":  tool,
+						"rules": rules,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+
+	return writeJSON(w, log)
+}
+
+// githubAnnotationLevels maps levels to the annotation levels of GitHub
+// check runs.
+var githubAnnotationLevels = map[string]string{
+	LevelError:   "failure",
+	LevelWarning: "warning",
+	LevelNote:    "notice",
+}
+
+// WriteGitHubAnnotations writes the findings as a list of GitHub check run
+// annotations, which can be sent with the checks API. Findings without a
+// line are annotated on the first line of their file.
+func WriteGitHubAnnotations(w io.Writer, findings []Finding) error {
+	annotations := make([]map[string]interface{}, len(findings))
+	for i, finding := range findings {
+		start := max(finding.Line, 1)
+		end := max(finding.EndLine, start)
+
+		annotation := map[string]interface{}{
+			"path":             finding.File,
+			"start_line":       start,
+			"end_line":         end,
+			"annotation_level": githubAnnotationLevels[finding.Level],
+			"message":          finding.Message,
+		}
+		// columns are only accepted on annotations of a single line
+		if finding.Column > 0 && start == end {
+			annotation["start_column"] = finding.Column
+			annotation["end_column"] = finding.Column
+		}
+		if finding.Title != "" {
+			annotation["title"] = finding.Title
+		} else if finding.Rule != "" {
+			annotation["title"] = finding.Rule
+		}
+		annotations[i] = annotation
+	}
+
+	return writeJSON(w, annotations)
+}
+
+func (f Finding) ruleID() string {
+	if f.Rule != "" {
+		return f.Rule
+	}
+
+	return "finding"
+}
+
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
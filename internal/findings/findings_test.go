@@ -0,0 +1,94 @@
+package findings
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var reviewed = []interface{}{
+	map[string]interface{}{"file": "main.go", "line": 12.0, "severity": "high", "message": "err is ignored", "rule": "unchecked-error"},
+	map[string]interface{}{"path": "util.go", "start_line": "3", "end_line": 5, "column": 2, "severity": "nit", "message": "rename x", "title": "Naming"},
+	map[string]interface{}{"message": "add a changelog entry"},
+}
+
+func TestParse(t *testing.T) {
+	found, err := Parse(reviewed)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{File: "main.go", Line: 12, Level: LevelError, Message: "err is ignored", Rule: "unchecked-error"},
+		{File: "util.go", Line: 3, EndLine: 5, Column: 2, Level: LevelNote, Message: "rename x", Title: "Naming"},
+		{Level: LevelWarning, Message: "add a changelog entry"},
+	}, found)
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		wantErr string
+	}{
+		{"not a list", "main.go:12", "expected a list of findings, got string"},
+		{"not an object", []interface{}{"main.go:12"}, "finding 0: expected an object, got string"},
+		{"no message", []interface{}{map[string]interface{}{"file": "main.go"}}, "finding 0: message is required"},
+		{"unknown severity", []interface{}{map[string]interface{}{"message": "m", "severity": "urgent"}}, `finding 0: unknown severity "urgent", use error, warning or note`},
+		{"invalid line", []interface{}{map[string]interface{}{"message": "m", "line": "twelve"}}, `finding 0: line must be a number, got "twelve"`},
+		{"negative line", []interface{}{map[string]interface{}{"message": "m", "line": -1.0}}, "finding 0: line must be positive, got -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	found, err := Parse(reviewed)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARIF(&buf, found, "reviewer"))
+	assert.JSONEq(t, `{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": [{
+			"tool": {"driver": {"name": "reviewer", "rules": [
+				{"id": "unchecked-error"},
+				{"id": "finding", "shortDescription": {"text": "Naming"}}
+			]}},
+			"results": [
+				{
+					"ruleId": "unchecked-error",
+					"level": "error",
+					"message": {"text": "err is ignored"},
+					"locations": [{"physicalLocation": {"artifactLocation": {"uri": "main.go"}, "region": {"startLine": 12}}}]
+				},
+				{
+					"ruleId": "finding",
+					"level": "note",
+					"message": {"text": "rename x"},
+					"locations": [{"physicalLocation": {"artifactLocation": {"uri": "util.go"}, "region": {"startLine": 3, "endLine": 5, "startColumn": 2}}}]
+				},
+				{
+					"ruleId": "finding",
+					"level": "warning",
+					"message": {"text": "add a changelog entry"}
+				}
+			]
+		}]
+	}`, buf.String())
+}
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	found, err := Parse(reviewed[:2])
+	require.NoError(t, err)
+	found[0].Column = 4
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteGitHubAnnotations(&buf, found))
+	assert.JSONEq(t, `[
+		{"path": "main.go", "start_line": 12, "end_line": 12, "start_column": 4, "end_column": 4, "annotation_level": "failure", "message": "err is ignored", "title": "unchecked-error"},
+		{"path": "util.go", "start_line": 3, "end_line": 5, "annotation_level": "notice", "message": "rename x", "title": "Naming"}
+	]`, buf.String())
+}
@@ -0,0 +1,127 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Comparison puts two runs side by side, step by step.
+type Comparison struct {
+	Base  *Run             `json:"base"`
+	Other *Run             `json:"other"`
+	Steps []StepComparison `json:"steps"`
+	// DurationDelta and CostDelta are how much longer and more expensive
+	// the other run was than the base run
+	DurationDelta time.Duration `json:"duration_delta"`
+	CostDelta     float64       `json:"cost_delta"`
+}
+
+// StepComparison is a step of either of two compared runs, nil for the run
+// which didn't run it.
+type StepComparison struct {
+	StepID string `json:"step_id"`
+	Base   *Step  `json:"base,omitempty"`
+	Other  *Step  `json:"other,omitempty"`
+}
+
+// Compare compares the other run to the base run. Steps are in the order of
+// the base run, followed by the steps only the other run ran.
+func Compare(base, other *Run) *Comparison {
+	comparison := &Comparison{
+		Base:          base,
+		Other:         other,
+		DurationDelta: other.Duration - base.Duration,
+		CostDelta:     other.Usage.Cost - base.Usage.Cost,
+	}
+
+	index := make(map[string]int)
+	for i := range base.Steps {
+		index[base.Steps[i].StepID] = len(comparison.Steps)
+		comparison.Steps = append(comparison.Steps, StepComparison{StepID: base.Steps[i].StepID, Base: &base.Steps[i]})
+	}
+
+	for i := range other.Steps {
+		step := &other.Steps[i]
+		if j, ok := index[step.StepID]; ok {
+			comparison.Steps[j].Other = step
+			continue
+		}
+		comparison.Steps = append(comparison.Steps, StepComparison{StepID: step.StepID, Other: step})
+	}
+
+	return comparison
+}
+
+// TrendPoint is the runs of a workflow started on a day.
+type TrendPoint struct {
+	Date   string `json:"date"`
+	Runs   int    `json:"runs"`
+	Failed int    `json:"failed"`
+	// AverageDuration is the average duration of the runs which finished
+	AverageDuration time.Duration `json:"average_duration"`
+	Usage           Usage         `json:"usage"`
+}
+
+// Trend is the daily duration and cost of the runs of a workflow.
+type Trend struct {
+	Workflow string        `json:"workflow"`
+	Points   []*TrendPoint `json:"points"`
+}
+
+// Trends returns the trend of each workflow of the runs, by the UTC day they
+// started on, sorted by workflow and then by day.
+func Trends(runs []*Run) []*Trend {
+	type day struct {
+		point    *TrendPoint
+		duration time.Duration
+		finished int
+	}
+
+	days := make(map[string]map[string]*day)
+	for _, run := range runs {
+		byDay, ok := days[run.Workflow]
+		if !ok {
+			byDay = make(map[string]*day)
+			days[run.Workflow] = byDay
+		}
+
+		date := run.StartTime.UTC().Format(time.DateOnly)
+		d, ok := byDay[date]
+		if !ok {
+			d = &day{point: &TrendPoint{Date: date}}
+			byDay[date] = d
+		}
+
+		d.point.Runs++
+		d.point.Usage.Add(run.Usage)
+		if run.Status == "failed" {
+			d.point.Failed++
+		}
+		if !run.EndTime.IsZero() {
+			d.duration += run.Duration
+			d.finished++
+		}
+	}
+
+	trends := make([]*Trend, 0, len(days))
+	for workflow, byDay := range days {
+		trend := &Trend{Workflow: workflow}
+		for _, d := range byDay {
+			if d.finished > 0 {
+				d.point.AverageDuration = d.duration / time.Duration(d.finished)
+			}
+			trend.Points = append(trend.Points, d.point)
+		}
+
+		sort.Slice(trend.Points, func(i, j int) bool {
+			return trend.Points[i].Date < trend.Points[j].Date
+		})
+		trends = append(trends, trend)
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		return trends[i].Workflow < trends[j].Workflow
+	})
+
+	return trends
+}
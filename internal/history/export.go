@@ -0,0 +1,106 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// ExportFormats lists the formats runs can be exported as.
+var ExportFormats = []string{FormatJSONL, FormatCSV}
+
+// csvHeader is the header of a CSV export, which has a row per step.
+var csvHeader = []string{
+	"run_id", "workflow", "workflow_file", "status", "start_time", "end_time", "duration_ms",
+	"step_id", "step_status", "step_duration_ms", "cost_center", "owner", "prompt",
+	"prompt_tokens", "completion_tokens", "total_tokens", "cost",
+}
+
+// Export writes the runs to w for analysis by other tools. JSONL has a line
+// with the full record of each run, CSV a row with the usage of each step of
+// each run, or of the run itself when it has no steps.
+func Export(w io.Writer, runs []*Run, format string) error {
+	switch format {
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		for _, run := range runs {
+			if err := encoder.Encode(run); err != nil {
+				return fmt.Errorf("failed to export run %s: %w", run.RunID, err)
+			}
+		}
+		return nil
+	case FormatCSV:
+		return exportCSV(w, runs)
+	default:
+		return fmt.Errorf("invalid format %q, must be one of %s", format, strings.Join(ExportFormats, ", "))
+	}
+}
+
+func exportCSV(w io.Writer, runs []*Run) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		row := []string{
+			run.RunID,
+			run.Workflow,
+			run.WorkflowFile,
+			run.Status,
+			formatTime(run.StartTime),
+			formatTime(run.EndTime),
+			strconv.FormatInt(run.Duration.Milliseconds(), 10),
+		}
+
+		if len(run.Steps) == 0 {
+			record := append(row, "", "", "", run.CostCenter, run.Owner, "")
+			if err := writer.Write(append(record, usageColumns(run.Usage)...)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, step := range run.Steps {
+			record := append(append([]string{}, row...),
+				step.StepID,
+				step.Status,
+				strconv.FormatInt(step.Duration.Milliseconds(), 10),
+				step.CostCenter,
+				step.Owner,
+				step.Prompt,
+			)
+			if err := writer.Write(append(record, usageColumns(step.Usage)...)); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func usageColumns(usage Usage) []string {
+	return []string{
+		strconv.Itoa(usage.PromptTokens),
+		strconv.Itoa(usage.CompletionTokens),
+		strconv.Itoa(usage.TotalTokens),
+		strconv.FormatFloat(usage.Cost, 'f', -1, 64),
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,327 @@
+// Package history keeps a record of every workflow run on the local machine,
+// with the cost labels and token usage of each step, for reporting.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/rs/zerolog/log"
+)
+
+// Run is the record of a workflow run.
+type Run struct {
+	RunID        string                 `json:"run_id" yaml:"run_id"`
+	Workflow     string                 `json:"workflow" yaml:"workflow"`
+	WorkflowFile string                 `json:"workflow_file" yaml:"workflow_file"`
+	Status       string                 `json:"status" yaml:"status"`
+	StartTime    time.Time              `json:"start_time" yaml:"start_time"`
+	EndTime      time.Time              `json:"end_time" yaml:"end_time"`
+	Duration     time.Duration          `json:"duration" yaml:"duration"`
+	Error        string                 `json:"error,omitempty" yaml:"error,omitempty"`
+	CostCenter   string                 `json:"cost_center,omitempty" yaml:"cost_center,omitempty"`
+	Owner        string                 `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Inputs       map[string]interface{} `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Outputs      map[string]interface{} `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	State        map[string]interface{} `json:"state,omitempty" yaml:"state,omitempty"`
+	Steps        []Step                 `json:"steps,omitempty" yaml:"steps,omitempty"`
+	Usage        Usage                  `json:"usage" yaml:"usage"`
+	Triage       *Triage                `json:"triage,omitempty" yaml:"triage,omitempty"`
+	// Prompts is the version of each prompt of the workflow's library the
+	// run used, keyed by the name of the prompt
+	Prompts map[string]string `json:"prompts,omitempty" yaml:"prompts,omitempty"`
+}
+
+// Triage is the diagnosis of a failed run by the workflow's triage agent.
+type Triage struct {
+	StepID       string `json:"step_id,omitempty" yaml:"step_id,omitempty"`
+	Diagnosis    string `json:"diagnosis" yaml:"diagnosis"`
+	SuggestedFix string `json:"suggested_fix" yaml:"suggested_fix"`
+}
+
+// Step is the record of a top-level step of a run. The labels of a step
+// default to those of its workflow.
+type Step struct {
+	StepID     string        `json:"step_id" yaml:"step_id"`
+	Status     string        `json:"status" yaml:"status"`
+	Duration   time.Duration `json:"duration" yaml:"duration"`
+	Error      string        `json:"error,omitempty" yaml:"error,omitempty"`
+	CostCenter string        `json:"cost_center,omitempty" yaml:"cost_center,omitempty"`
+	Owner      string        `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Usage      Usage         `json:"usage" yaml:"usage"`
+	// Prompt is the version of the library's prompt the step used, e.g.
+	// review@v3
+	Prompt string `json:"prompt,omitempty" yaml:"prompt,omitempty"`
+	// Environment is the versions of the dependencies the step ran with
+	Environment *Environment `json:"environment,omitempty" yaml:"environment,omitempty"`
+}
+
+// Environment is the exact versions of the dependencies a step ran with, so
+// that its result can be reproduced.
+type Environment struct {
+	// Runtimes is the version of each runtime of a script step, keyed by
+	// name
+	Runtimes map[string]string `json:"runtimes,omitempty" yaml:"runtimes,omitempty"`
+	// Image and ImageDigest are the image of a container step and the digest
+	// it resolved to
+	Image       string `json:"image,omitempty" yaml:"image,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty" yaml:"image_digest,omitempty"`
+	// Block and BlockDigest are the block of a block step and the SHA-256 of
+	// its file
+	Block       string `json:"block,omitempty" yaml:"block,omitempty"`
+	BlockDigest string `json:"block_digest,omitempty" yaml:"block_digest,omitempty"`
+	// Provider, Model and ModelSnapshot are the model of an agent step and
+	// the snapshot which served it, when the provider reports one
+	Provider      string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Model         string `json:"model,omitempty" yaml:"model,omitempty"`
+	ModelSnapshot string `json:"model_snapshot,omitempty" yaml:"model_snapshot,omitempty"`
+}
+
+// Usage is the token usage and estimated cost in USD of a run or step.
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens" yaml:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens" yaml:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens" yaml:"total_tokens"`
+	Cost             float64 `json:"cost" yaml:"cost"`
+}
+
+// Redact removes the data of the run, its inputs, outputs, state, errors and
+// diagnosis, keeping its status, timings, labels and usage.
+func (r *Run) Redact() {
+	r.Error = ""
+	r.Inputs = nil
+	r.Outputs = nil
+	r.State = nil
+
+	if r.Triage != nil {
+		r.Triage = &Triage{StepID: r.Triage.StepID}
+	}
+
+	for i := range r.Steps {
+		r.Steps[i].Error = ""
+	}
+}
+
+// Add adds other to the usage.
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+}
+
+// Store keeps each run as a JSON file named after its run ID.
+type Store struct {
+	dir       string
+	logDir    string
+	stepDir   string
+	retention Retention
+	cipher    *encryption.Cipher
+}
+
+// StoreOption configures a Store.
+type StoreOption func(*Store)
+
+// WithRetention prunes the store according to the retention policy each
+// time a run is saved.
+func WithRetention(retention Retention) StoreOption {
+	return func(s *Store) {
+		s.retention = retention
+	}
+}
+
+// WithRunLogs prunes the run logs in dir along with the records, each log
+// named after the run ID of its record.
+func WithRunLogs(dir string) StoreOption {
+	return func(s *Store) {
+		s.logDir = dir
+	}
+}
+
+// WithStepResults prunes the step results in dir along with the records.
+func WithStepResults(dir string) StoreOption {
+	return func(s *Store) {
+		s.stepDir = dir
+	}
+}
+
+// WithCipher encrypts the records of the store with the cipher. Records
+// saved before encryption was enabled can still be read.
+func WithCipher(cipher *encryption.Cipher) StoreOption {
+	return func(s *Store) {
+		s.cipher = cipher
+	}
+}
+
+// Cipher returns the cipher the records of the store are encrypted with,
+// nil when they aren't.
+func (s *Store) Cipher() *encryption.Cipher {
+	return s.cipher
+}
+
+// NewStore creates a store which keeps its files in dir.
+func NewStore(dir string, opts ...StoreOption) *Store {
+	s := &Store{dir: dir}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Save records the run, replacing any previous record with the same run ID.
+func (s *Store) Save(run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("failed to encode run %s: %w", run.RunID, err)
+	}
+
+	data, err = s.cipher.Seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt run %s: %w", run.RunID, err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("failed to create run history directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(s.dir, run.RunID+".json"), data, 0600); err != nil {
+		return err
+	}
+
+	if !s.retention.IsZero() {
+		if _, err := s.Prune(s.retention, time.Now()); err != nil {
+			log.Warn().Err(err).Msg("Failed to prune run history")
+		}
+	}
+
+	return nil
+}
+
+// Get returns the record of the run with the given ID.
+func (s *Store) Get(runID string) (*Run, error) {
+	if runID == "" || strings.ContainsAny(runID, `/\`) || strings.Contains(runID, "..") {
+		return nil, fmt.Errorf("invalid run ID %q", runID)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, runID+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no run found with ID %s", runID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", runID, err)
+	}
+
+	data, err = s.cipher.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %s: %w", runID, err)
+	}
+
+	run := &Run{}
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, fmt.Errorf("failed to decode run %s: %w", runID, err)
+	}
+
+	return run, nil
+}
+
+// List returns the runs which started at or after since, oldest first. A
+// zero since returns every run. Records which can't be read are skipped.
+func (s *Store) List(since time.Time) ([]*Run, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+
+	var runs []*Run
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(path) // #nosec G304 - path is in the store directory
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to read run record")
+			continue
+		}
+
+		data, err = s.cipher.Open(data)
+		if errors.Is(err, encryption.ErrKeyRequired) {
+			return nil, fmt.Errorf("failed to read run history: %w", err)
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to decrypt run record")
+			continue
+		}
+
+		run := &Run{}
+		if err := json.Unmarshal(data, run); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to decode run record")
+			continue
+		}
+
+		if run.StartTime.Before(since) {
+			continue
+		}
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartTime.Before(runs[j].StartTime)
+	})
+
+	return runs, nil
+}
+
+// ParseSince parses how far back to look, either a duration with a day
+// suffix such as 30d, a Go duration such as 12h, or an RFC 3339 or YYYY-MM-DD
+// date, into the time to look back to from now.
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if n, ok := parseDays(value); ok {
+		return now.AddDate(0, 0, -n), nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid since %q, use a duration such as 30d or 12h, or a date such as 2024-01-31", value)
+}
+
+// parseDays parses a number of days with a d suffix such as 30d.
+func parseDays(value string) (int, bool) {
+	days, ok := strings.CutSuffix(value, "d")
+	if !ok {
+		return 0, false
+	}
+
+	var n int
+	if _, err := fmt.Sscanf(days, "%d", &n); err != nil || fmt.Sprint(n) != days || n < 0 {
+		return 0, false
+	}
+
+	return n, true
+}
@@ -0,0 +1,381 @@
+package history
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_SaveList(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "history"))
+
+	runs, err := store.List(time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"recent", "old", "oldest"} {
+		require.NoError(t, store.Save(&Run{
+			RunID:     id,
+			Workflow:  "triage",
+			StartTime: now.AddDate(0, 0, -10*i),
+			Steps:     []Step{{StepID: "classify", CostCenter: "support", Usage: Usage{TotalTokens: 100, Cost: 0.01}}},
+		}))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(store.dir, "corrupt.json"), []byte("{"), 0600))
+
+	runs, err = store.List(now.AddDate(0, 0, -15))
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "old", runs[0].RunID)
+	assert.Equal(t, "recent", runs[1].RunID)
+	assert.Equal(t, "support", runs[1].Steps[0].CostCenter)
+	assert.Equal(t, 0.01, runs[1].Steps[0].Usage.Cost)
+}
+
+func TestStore_Get(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "history"))
+	require.NoError(t, store.Save(&Run{RunID: "run_1", State: map[string]interface{}{"seen": []interface{}{"a", "b"}}}))
+
+	run, err := store.Get("run_1")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, run.State["seen"])
+
+	_, err = store.Get("run_2")
+	assert.ErrorContains(t, err, "no run found with ID run_2")
+
+	_, err = store.Get("../run_1")
+	assert.ErrorContains(t, err, "invalid run ID")
+}
+
+func TestStore_Encrypted(t *testing.T) {
+	cipher, err := encryption.NewCipher(make([]byte, encryption.KeySize))
+	require.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "history")
+	store := NewStore(dir, WithCipher(cipher))
+	require.NoError(t, store.Save(&Run{RunID: "1", Inputs: map[string]interface{}{"prompt": "a secret"}}))
+
+	data, err := os.ReadFile(filepath.Join(dir, "1.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "a secret")
+
+	// records saved before encryption was enabled are still read
+	require.NoError(t, NewStore(dir).Save(&Run{RunID: "2"}))
+
+	runs, err := store.List(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	_, err = NewStore(dir).List(time.Time{})
+	assert.ErrorIs(t, err, encryption.ErrKeyRequired)
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "30d", want: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "12h", want: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{value: "2024-01-31", want: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{value: "2024-01-31T08:00:00Z", want: time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC)},
+		{value: "-3d", err: true},
+		{value: "last week", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSince(tt.value, now)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
+
+func TestCostReport(t *testing.T) {
+	runs := []*Run{
+		{
+			RunID:    "1",
+			Workflow: "triage",
+			Steps: []Step{
+				{StepID: "classify", CostCenter: "support", Owner: "cx", Usage: Usage{TotalTokens: 100, Cost: 0.5}, Prompt: "classify@v2"},
+				{StepID: "reply", CostCenter: "support", Owner: "cx", Usage: Usage{TotalTokens: 50, Cost: 0.25}, Prompt: "reply@v1"},
+				{StepID: "notify"},
+			},
+		},
+		{
+			RunID:    "2",
+			Workflow: "release-notes",
+			Steps: []Step{
+				{StepID: "write", CostCenter: "engineering", Owner: "platform", Usage: Usage{TotalTokens: 400, Cost: 2}},
+				{StepID: "translate", CostCenter: "support", Owner: "cx", Usage: Usage{TotalTokens: 10, Cost: 0.05}},
+			},
+		},
+	}
+
+	report, err := CostReport(runs, ByCostCenter)
+	require.NoError(t, err)
+	require.Len(t, report, 3)
+
+	assert.Equal(t, "engineering", report[0].Key)
+	assert.Equal(t, 2.0, report[0].Usage.Cost)
+
+	assert.Equal(t, "support", report[1].Key)
+	assert.Equal(t, 2, report[1].Runs)
+	assert.Equal(t, 3, report[1].Steps)
+	assert.Equal(t, 160, report[1].Usage.TotalTokens)
+	assert.InDelta(t, 0.8, report[1].Usage.Cost, 1e-9)
+
+	assert.Equal(t, Unassigned, report[2].Key)
+	assert.Equal(t, 1, report[2].Steps)
+
+	report, err = CostReport(runs, ByWorkflow)
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+	assert.Equal(t, "release-notes", report[0].Key)
+	assert.Equal(t, "triage", report[1].Key)
+	assert.Equal(t, 3, report[1].Steps)
+
+	report, err = CostReport(runs, ByPrompt)
+	require.NoError(t, err)
+	require.Len(t, report, 3)
+	assert.Equal(t, Unassigned, report[0].Key)
+	assert.Equal(t, 3, report[0].Steps)
+	assert.Equal(t, "classify@v2", report[1].Key)
+	assert.Equal(t, "reply@v1", report[2].Key)
+
+	_, err = CostReport(runs, "model")
+	assert.EqualError(t, err, `invalid grouping "model", must be one of cost_center, owner, workflow, prompt`)
+}
+
+func TestStore_Prune(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "history"))
+	now := time.Now()
+
+	for i, id := range []string{"expired", "oldest", "old", "recent"} {
+		require.NoError(t, store.Save(&Run{RunID: id, Workflow: "triage"}))
+		modTime := now.Add(-time.Duration(4-i) * time.Hour)
+		if id == "expired" {
+			modTime = now.AddDate(0, 0, -100)
+		}
+		require.NoError(t, os.Chtimes(filepath.Join(store.dir, id+".json"), modTime, modTime))
+	}
+
+	info, err := os.Stat(filepath.Join(store.dir, "recent.json"))
+	require.NoError(t, err)
+
+	removed, err := store.Prune(Retention{MaxAge: 90 * 24 * time.Hour, MaxSize: 2 * info.Size()}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	runs, err := store.List(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	// the latest record is kept even when it's larger than the limit
+	removed, err = store.Prune(Retention{MaxSize: 1}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = os.Stat(filepath.Join(store.dir, "recent.json"))
+	assert.NoError(t, err)
+}
+
+func TestStore_PruneRunLogsAndStepResults(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "logs")
+	stepDir := filepath.Join(dir, "runs")
+	require.NoError(t, os.MkdirAll(logDir, 0750))
+	require.NoError(t, os.MkdirAll(stepDir, 0750))
+
+	store := NewStore(filepath.Join(dir, "history"), WithRunLogs(logDir), WithStepResults(stepDir))
+	now := time.Now()
+	expired := now.AddDate(0, 0, -100)
+
+	for _, id := range []string{"expired", "recent"} {
+		require.NoError(t, store.Save(&Run{RunID: id, Workflow: "triage"}))
+		require.NoError(t, os.WriteFile(filepath.Join(logDir, id+".jsonl"), []byte("{}\n"), 0600))
+	}
+	// a log whose run wasn't recorded and the results of a workflow which
+	// hasn't run since
+	require.NoError(t, os.WriteFile(filepath.Join(logDir, "unrecorded.jsonl"), []byte("{}\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(stepDir, "stale.json"), []byte("{}"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(stepDir, "fresh.json"), []byte("{}"), 0600))
+
+	for _, path := range []string{
+		filepath.Join(store.dir, "expired.json"),
+		filepath.Join(logDir, "unrecorded.jsonl"),
+		filepath.Join(stepDir, "stale.json"),
+	} {
+		require.NoError(t, os.Chtimes(path, expired, expired))
+	}
+
+	removed, err := store.Prune(Retention{MaxAge: 90 * 24 * time.Hour}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	for _, path := range []string{
+		filepath.Join(logDir, "expired.jsonl"),
+		filepath.Join(logDir, "unrecorded.jsonl"),
+		filepath.Join(stepDir, "stale.json"),
+	} {
+		assert.NoFileExists(t, path)
+	}
+	assert.FileExists(t, filepath.Join(logDir, "recent.jsonl"))
+	assert.FileExists(t, filepath.Join(stepDir, "fresh.json"))
+}
+
+func TestStore_SaveWithRetention(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "history"), WithRetention(Retention{MaxSize: 1}))
+
+	require.NoError(t, store.Save(&Run{RunID: "first"}))
+	require.NoError(t, store.Save(&Run{RunID: "second"}))
+
+	entries, err := os.ReadDir(store.dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestParseMaxAge(t *testing.T) {
+	age, err := ParseMaxAge("90d")
+	require.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, age)
+
+	age, err = ParseMaxAge("72h")
+	require.NoError(t, err)
+	assert.Equal(t, 72*time.Hour, age)
+
+	age, err = ParseMaxAge("0")
+	require.NoError(t, err)
+	assert.Zero(t, age)
+
+	_, err = ParseMaxAge("forever")
+	assert.EqualError(t, err, `invalid max age "forever", use a duration such as 90d or 72h`)
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"":      0,
+		"1024":  1024,
+		"500MB": 500 << 20,
+		"1 gb":  1 << 30,
+		"64KB":  64 << 10,
+		"2048B": 2048,
+	}
+	for value, want := range tests {
+		got, err := ParseSize(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+
+	_, err := ParseSize("lots")
+	assert.EqualError(t, err, `invalid size "lots", use a size such as 500MB or 1GB`)
+}
+
+func TestExport(t *testing.T) {
+	start := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	runs := []*Run{
+		{
+			RunID:     "1",
+			Workflow:  "triage",
+			Status:    "completed",
+			StartTime: start,
+			EndTime:   start.Add(2 * time.Second),
+			Duration:  2 * time.Second,
+			Steps: []Step{
+				{StepID: "classify", Status: "completed", Duration: 1500 * time.Millisecond, CostCenter: "support", Prompt: "classify@v2", Usage: Usage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100, Cost: 0.0025}},
+			},
+		},
+		{RunID: "2", Workflow: "empty", Status: "failed", StartTime: start, Owner: "cx"},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, Export(&out, runs, FormatCSV))
+	assert.Equal(t, `run_id,workflow,workflow_file,status,start_time,end_time,duration_ms,step_id,step_status,step_duration_ms,cost_center,owner,prompt,prompt_tokens,completion_tokens,total_tokens,cost
+1,triage,,completed,2024-03-31T12:00:00Z,2024-03-31T12:00:02Z,2000,classify,completed,1500,support,,classify@v2,80,20,100,0.0025
+2,empty,,failed,2024-03-31T12:00:00Z,,0,,,,,cx,,0,0,0,0
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, Export(&out, runs, FormatJSONL))
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.Contains(t, string(lines[0]), `"run_id":"1"`)
+
+	err := Export(&out, runs, "xml")
+	assert.EqualError(t, err, `invalid format "xml", must be one of jsonl, csv`)
+}
+
+func TestCompare(t *testing.T) {
+	base := &Run{
+		RunID:    "1",
+		Duration: 3 * time.Second,
+		Usage:    Usage{Cost: 0.5},
+		Steps: []Step{
+			{StepID: "classify", Status: "completed"},
+			{StepID: "reply", Status: "completed"},
+		},
+	}
+	other := &Run{
+		RunID:    "2",
+		Duration: 2 * time.Second,
+		Usage:    Usage{Cost: 0.75},
+		Steps: []Step{
+			{StepID: "reply", Status: "failed"},
+			{StepID: "escalate", Status: "completed"},
+		},
+	}
+
+	comparison := Compare(base, other)
+	assert.Equal(t, -time.Second, comparison.DurationDelta)
+	assert.InDelta(t, 0.25, comparison.CostDelta, 1e-9)
+
+	require.Len(t, comparison.Steps, 3)
+	assert.Equal(t, "classify", comparison.Steps[0].StepID)
+	assert.Nil(t, comparison.Steps[0].Other)
+	assert.Equal(t, "reply", comparison.Steps[1].StepID)
+	assert.Equal(t, "completed", comparison.Steps[1].Base.Status)
+	assert.Equal(t, "failed", comparison.Steps[1].Other.Status)
+	assert.Equal(t, "escalate", comparison.Steps[2].StepID)
+	assert.Nil(t, comparison.Steps[2].Base)
+}
+
+func TestTrends(t *testing.T) {
+	day := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	runs := []*Run{
+		{Workflow: "triage", Status: "completed", StartTime: day, EndTime: day.Add(time.Second), Duration: time.Second, Usage: Usage{Cost: 0.25}},
+		{Workflow: "triage", Status: "failed", StartTime: day.Add(time.Hour), EndTime: day.Add(time.Hour + 3*time.Second), Duration: 3 * time.Second, Usage: Usage{Cost: 0.5}},
+		{Workflow: "triage", Status: "running", StartTime: day.Add(2 * time.Hour)},
+		{Workflow: "triage", Status: "completed", StartTime: day.Add(24 * time.Hour), EndTime: day.Add(24*time.Hour + time.Second), Duration: time.Second},
+		{Workflow: "digest", Status: "completed", StartTime: day},
+	}
+
+	trends := Trends(runs)
+	require.Len(t, trends, 2)
+	assert.Equal(t, "digest", trends[0].Workflow)
+	assert.Equal(t, "triage", trends[1].Workflow)
+
+	points := trends[1].Points
+	require.Len(t, points, 2)
+	assert.Equal(t, "2024-03-31", points[0].Date)
+	assert.Equal(t, 3, points[0].Runs)
+	assert.Equal(t, 1, points[0].Failed)
+	assert.Equal(t, 2*time.Second, points[0].AverageDuration)
+	assert.InDelta(t, 0.75, points[0].Usage.Cost, 1e-9)
+	assert.Equal(t, "2024-04-01", points[1].Date)
+}
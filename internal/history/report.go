@@ -0,0 +1,86 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	ByCostCenter = "cost_center"
+	ByOwner      = "owner"
+	ByWorkflow   = "workflow"
+	ByPrompt     = "prompt"
+
+	// Unassigned groups the steps which have no label to group by.
+	Unassigned = "(unassigned)"
+)
+
+// Groupings lists the labels a cost report can be grouped by.
+var Groupings = []string{ByCostCenter, ByOwner, ByWorkflow, ByPrompt}
+
+// CostGroup is the usage of the steps sharing a label in a cost report.
+type CostGroup struct {
+	Key   string `json:"key" yaml:"key"`
+	Runs  int    `json:"runs" yaml:"runs"`
+	Steps int    `json:"steps" yaml:"steps"`
+	Usage Usage  `json:"usage" yaml:"usage"`
+}
+
+// CostReport adds up the usage of the steps of the runs by cost center,
+// owner, workflow or the version of the library's prompt they used, to
+// compare the versions of an experiment. Groups are sorted by cost, most
+// expensive first.
+func CostReport(runs []*Run, by string) ([]*CostGroup, error) {
+	var key func(run *Run, step Step) string
+	switch by {
+	case ByCostCenter:
+		key = func(_ *Run, step Step) string { return step.CostCenter }
+	case ByOwner:
+		key = func(_ *Run, step Step) string { return step.Owner }
+	case ByWorkflow:
+		key = func(run *Run, _ Step) string { return run.Workflow }
+	case ByPrompt:
+		key = func(_ *Run, step Step) string { return step.Prompt }
+	default:
+		return nil, fmt.Errorf("invalid grouping %q, must be one of %s", by, strings.Join(Groupings, ", "))
+	}
+
+	groups := make(map[string]*CostGroup)
+	for _, run := range runs {
+		counted := make(map[string]bool)
+		for _, step := range run.Steps {
+			k := key(run, step)
+			if k == "" {
+				k = Unassigned
+			}
+
+			group, ok := groups[k]
+			if !ok {
+				group = &CostGroup{Key: k}
+				groups[k] = group
+			}
+
+			if !counted[k] {
+				group.Runs++
+				counted[k] = true
+			}
+			group.Steps++
+			group.Usage.Add(step.Usage)
+		}
+	}
+
+	report := make([]*CostGroup, 0, len(groups))
+	for _, group := range groups {
+		report = append(report, group)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Usage.Cost != report[j].Usage.Cost {
+			return report[i].Usage.Cost > report[j].Usage.Cost
+		}
+		return report[i].Key < report[j].Key
+	})
+
+	return report, nil
+}
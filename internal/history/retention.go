@@ -0,0 +1,179 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetention keeps runs for 90 days, up to 100MB of records.
+var DefaultRetention = Retention{
+	MaxAge:  90 * 24 * time.Hour,
+	MaxSize: 100 << 20,
+}
+
+// Retention limits how much of the run history is kept. A zero limit keeps
+// everything.
+type Retention struct {
+	// MaxAge removes the records of runs saved longer ago than it.
+	MaxAge time.Duration
+	// MaxSize removes the oldest records while the records of the store
+	// take up more than it in bytes. The latest record is always kept.
+	MaxSize int64
+}
+
+// IsZero reports whether the retention keeps everything.
+func (r Retention) IsZero() bool {
+	return r.MaxAge <= 0 && r.MaxSize <= 0
+}
+
+type record struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// Prune removes the records which the retention policy doesn't keep, and
+// returns how many were removed. The run log of a removed record is removed
+// with it, and the run logs and step results of the store are pruned by the
+// same policy, as they hold the prompts and outputs of the runs too.
+func (s *Store) Prune(retention Retention, now time.Time) (int, error) {
+	removed, err := pruneFiles(s.dir, ".json", retention, now)
+	if err != nil {
+		return len(removed), fmt.Errorf("failed to prune run history: %w", err)
+	}
+
+	if s.logDir != "" {
+		for _, path := range removed {
+			runID := strings.TrimSuffix(filepath.Base(path), ".json")
+			if err := os.Remove(filepath.Join(s.logDir, runID+".jsonl")); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return len(removed), fmt.Errorf("failed to remove run log: %w", err)
+			}
+		}
+
+		// logs of runs which weren't recorded, such as runs of workflows
+		// which don't persist their history
+		if _, err := pruneFiles(s.logDir, ".jsonl", retention, now); err != nil {
+			return len(removed), fmt.Errorf("failed to prune run logs: %w", err)
+		}
+	}
+
+	if s.stepDir != "" {
+		if _, err := pruneFiles(s.stepDir, ".json", retention, now); err != nil {
+			return len(removed), fmt.Errorf("failed to prune step results: %w", err)
+		}
+	}
+
+	return len(removed), nil
+}
+
+// pruneFiles removes the files of dir with the extension which the
+// retention policy doesn't keep, oldest first, and returns their paths.
+func pruneFiles(dir, ext string, retention Retention, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []record
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ext) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// the file was removed since the directory was read
+			continue
+		}
+
+		records = append(records, record{
+			path:    filepath.Join(dir, entry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		total += info.Size()
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].modTime.Before(records[j].modTime)
+	})
+
+	var removed []string
+	for i, r := range records {
+		expired := retention.MaxAge > 0 && now.Sub(r.modTime) > retention.MaxAge
+		oversize := retention.MaxSize > 0 && total > retention.MaxSize && i < len(records)-1
+		if !expired && !oversize {
+			break
+		}
+
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		total -= r.size
+		removed = append(removed, r.path)
+	}
+
+	return removed, nil
+}
+
+// ParseMaxAge parses the maximum age of a retention policy, either a number
+// of days such as 90d or a Go duration such as 72h. Zero keeps runs forever.
+func ParseMaxAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+
+	if n, ok := parseDays(value); ok {
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, nil
+	}
+
+	return 0, fmt.Errorf("invalid max age %q, use a duration such as 90d or 72h", value)
+}
+
+// ParseSize parses the maximum size of a retention policy, a number of bytes
+// with an optional KB, MB or GB suffix. Zero keeps runs of any size.
+func ParseSize(value string) (int64, error) {
+	size := strings.ToUpper(strings.TrimSpace(value))
+	if size == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if n, ok := strings.CutSuffix(size, unit.suffix); ok {
+			size = strings.TrimSpace(n)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use a size such as 500MB or 1GB", value)
+	}
+
+	return n * multiplier, nil
+}
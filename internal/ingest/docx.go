@@ -0,0 +1,128 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseDOCX extracts the paragraphs of a Word document, starting a new
+// section at every heading. Page numbers follow the explicit and last
+// rendered page breaks saved by Word, so they are approximate.
+func ParseDOCX(data []byte) (*Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a DOCX file: %w", err)
+	}
+
+	var body io.ReadCloser
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			if body, err = f.Open(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if body == nil {
+		return nil, errors.New("not a DOCX file: word/document.xml is missing")
+	}
+	defer func() { _ = body.Close() }()
+
+	doc := &Document{Format: FormatDOCX}
+	page := 1
+	section := Section{Page: page}
+	var paragraph strings.Builder
+	var style string
+	breaks := 0
+
+	flush := func() {
+		if text := normalizeText(section.Text); text != "" {
+			section.Text = text
+			doc.Sections = append(doc.Sections, section)
+		}
+		section = Section{Page: page, Heading: section.Heading}
+	}
+
+	decoder := xml.NewDecoder(body)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid document.xml: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				paragraph.Reset()
+				style = ""
+				breaks = 0
+			case "pStyle":
+				style = xmlAttr(t, "val")
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteString(" ")
+			case "br", "cr":
+				if xmlAttr(t, "type") == "page" {
+					breaks++
+				} else {
+					paragraph.WriteString("\n")
+				}
+			case "lastRenderedPageBreak":
+				breaks++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if breaks > 0 {
+					flush()
+					page += breaks
+					section.Page = page
+				}
+
+				text := paragraph.String()
+				if isHeadingStyle(style) {
+					flush()
+					section.Heading = normalizeText(text)
+					continue
+				}
+				section.Text += text + "\n\n"
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(t)
+			}
+		}
+	}
+	flush()
+	doc.Pages = page
+
+	return doc, nil
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+func isHeadingStyle(style string) bool {
+	style = strings.ToLower(style)
+	return strings.HasPrefix(style, "heading") || style == "title"
+}
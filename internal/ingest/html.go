@@ -0,0 +1,105 @@
+package ingest
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ParseHTML extracts the visible text of an HTML document, starting a new
+// section at every heading. Scripts, styles and navigation are skipped.
+func ParseHTML(data []byte) (*Document, error) {
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &htmlParser{doc: &Document{Format: FormatHTML}}
+	p.walk(root)
+	p.flush()
+
+	return p.doc, nil
+}
+
+type htmlParser struct {
+	doc     *Document
+	heading string
+	text    strings.Builder
+}
+
+func (p *htmlParser) flush() {
+	if text := normalizeText(p.text.String()); text != "" {
+		p.doc.Sections = append(p.doc.Sections, Section{Heading: p.heading, Text: text})
+	}
+	p.text.Reset()
+}
+
+func (p *htmlParser) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		p.text.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Nav, atom.Svg, atom.Iframe:
+			return
+		case atom.Title:
+			if p.doc.Title == "" {
+				p.doc.Title = normalizeText(nodeText(n))
+			}
+			return
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			p.flush()
+			p.heading = normalizeText(nodeText(n))
+			return
+		case atom.Br:
+			p.text.WriteString("\n")
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && isBlockElement(n.DataAtom)
+	if block {
+		p.text.WriteString("\n\n")
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.walk(c)
+	}
+
+	if n.Type == html.ElementNode && (n.DataAtom == atom.Td || n.DataAtom == atom.Th) {
+		p.text.WriteString(" ")
+	}
+	if block {
+		p.text.WriteString("\n\n")
+	}
+}
+
+func isBlockElement(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer,
+		atom.Aside, atom.Blockquote, atom.Pre, atom.Ul, atom.Ol, atom.Li, atom.Dl, atom.Dt,
+		atom.Dd, atom.Table, atom.Tr, atom.Figure, atom.Figcaption, atom.Hr:
+		return true
+	}
+
+	return false
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return b.String()
+}
@@ -0,0 +1,212 @@
+// Package ingest extracts text from PDF, HTML and DOCX documents and splits
+// it into chunks small enough to be passed to prompts.
+package ingest
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	FormatPDF  = "pdf"
+	FormatHTML = "html"
+	FormatDOCX = "docx"
+
+	// DefaultChunkSize is the default maximum number of characters in a chunk.
+	DefaultChunkSize = 2000
+	// DefaultChunkOverlap is the default number of characters repeated from
+	// the end of the previous chunk of the same section.
+	DefaultChunkOverlap = 200
+)
+
+// Formats lists the supported document formats.
+var Formats = []string{FormatPDF, FormatHTML, FormatDOCX}
+
+// Document is the text extracted from a document, split into sections.
+type Document struct {
+	Source   string
+	Format   string
+	Title    string
+	Pages    int
+	Sections []Section
+}
+
+// Section is a run of text on a single page or under a single heading. Page
+// is zero for formats without pages.
+type Section struct {
+	Page    int
+	Heading string
+	Text    string
+}
+
+// Chunk is a piece of a document with the metadata needed to cite it.
+type Chunk struct {
+	Index   int    `json:"index"`
+	Source  string `json:"source"`
+	Page    int    `json:"page,omitempty"`
+	Section string `json:"section,omitempty"`
+	Text    string `json:"text"`
+}
+
+// FormatFromPath returns the document format implied by a file extension.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pdf":
+		return FormatPDF
+	case ".html", ".htm", ".xhtml":
+		return FormatHTML
+	case ".docx":
+		return FormatDOCX
+	}
+
+	return ""
+}
+
+// Parse extracts the text of a document in the given format.
+func Parse(data []byte, format string) (*Document, error) {
+	switch format {
+	case FormatPDF:
+		return ParsePDF(data)
+	case FormatHTML:
+		return ParseHTML(data)
+	case FormatDOCX:
+		return ParseDOCX(data)
+	}
+
+	return nil, fmt.Errorf("unsupported format %q, must be one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// Text returns the text of all sections separated by blank lines.
+func (d *Document) Text() string {
+	texts := make([]string, 0, len(d.Sections))
+	for _, section := range d.Sections {
+		texts = append(texts, section.Text)
+	}
+
+	return strings.Join(texts, "\n\n")
+}
+
+// Chunks splits the document into chunks of at most size characters. Chunks
+// never span sections so each keeps its page and heading, and long sections
+// are split on paragraph, line, sentence or word boundaries, repeating the
+// last overlap characters of the previous chunk.
+func (d *Document) Chunks(size, overlap int) []Chunk {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []Chunk
+	for _, section := range d.Sections {
+		for _, text := range SplitText(section.Text, size, overlap) {
+			chunks = append(chunks, Chunk{
+				Index:   len(chunks),
+				Source:  d.Source,
+				Page:    section.Page,
+				Section: section.Heading,
+				Text:    text,
+			})
+		}
+	}
+
+	return chunks
+}
+
+var separators = []string{"\n\n", "\n", ". ", " "}
+
+// SplitText splits text into pieces of at most size characters on paragraph,
+// line, sentence or word boundaries, repeating the last overlap characters of
+// the previous piece.
+func SplitText(text string, size, overlap int) []string {
+	var chunks []string
+	for utf8.RuneCountInString(text) > size {
+		cut := cutPoint(text, size)
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+
+		next := cut
+		if overlap > 0 {
+			next = overlapStart(text, cut, overlap)
+		}
+		text = strings.TrimSpace(text[next:])
+	}
+
+	if text = strings.TrimSpace(text); text != "" {
+		chunks = append(chunks, text)
+	}
+
+	return chunks
+}
+
+// cutPoint returns the byte offset at which to end a chunk of at most size
+// characters, preferring the latest separator in the second half.
+func cutPoint(text string, size int) int {
+	limit := byteOffset(text, size)
+	for _, sep := range separators {
+		if i := strings.LastIndex(text[:limit], sep); i > limit/2 {
+			return i + len(sep)
+		}
+	}
+
+	return limit
+}
+
+// overlapStart returns where the chunk after cut starts so that it repeats
+// up to overlap characters, starting at a word boundary.
+func overlapStart(text string, cut, overlap int) int {
+	start := cut
+	for n := 0; n < overlap && start > 0; n++ {
+		_, width := utf8.DecodeLastRuneInString(text[:start])
+		start -= width
+	}
+
+	// skip the partial word the overlap starts in
+	if start > 0 && !strings.ContainsAny(text[start-1:start], " \n") {
+		if i := strings.IndexAny(text[start:cut], " \n"); i >= 0 {
+			start += i + 1
+		}
+	}
+
+	// make sure the text always advances
+	if start == 0 {
+		return cut
+	}
+
+	return start
+}
+
+func byteOffset(text string, runes int) int {
+	for i := range text {
+		if runes == 0 {
+			return i
+		}
+		runes--
+	}
+
+	return len(text)
+}
+
+var (
+	spacePattern     = regexp.MustCompile(`[ \t\f\v\r\x{00a0}]+`)
+	blankLinePattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// normalizeText collapses runs of whitespace, trims lines and keeps at most
+// one blank line between paragraphs.
+func normalizeText(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	text = strings.ReplaceAll(text, "\x00", "")
+	text = spacePattern.ReplaceAllString(text, " ")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(blankLinePattern.ReplaceAllString(text, "\n\n"))
+}
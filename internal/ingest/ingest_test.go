@@ -0,0 +1,188 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildPDF writes a PDF with one page per content stream, compressing the
+// streams when compress is set.
+func buildPDF(t *testing.T, compress bool, extra string, contents ...string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	kids := make([]string, len(contents))
+	for i := range contents {
+		kids[i] = fmt.Sprintf("%d 0 R", 10+i*2)
+	}
+	fmt.Fprintf(&b, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> >>\nendobj\n", strings.Join(kids, " "), len(contents))
+	b.WriteString("3 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	b.WriteString(extra)
+
+	for i, content := range contents {
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Type /Page /Parent 2 0 R /Contents %d 0 R >>\nendobj\n", 10+i*2, 11+i*2)
+
+		data := []byte(content)
+		filter := ""
+		if compress {
+			var z bytes.Buffer
+			w := zlib.NewWriter(&z)
+			_, err := w.Write(data)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			data = z.Bytes()
+			filter = " /Filter /FlateDecode"
+		}
+
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Length %d%s >>\nstream\n", 11+i*2, len(data), filter)
+		b.Write(data)
+		b.WriteString("\nendstream\nendobj\n")
+	}
+
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestParsePDF(t *testing.T) {
+	data := buildPDF(t, true, "",
+		"BT /F1 12 Tf 72 720 Td (Quarterly \\(Q3\\) report) Tj 0 -14 Td [(Rev) -50 (enue) -300 (grew)] TJ ET",
+		"BT /F1 12 Tf 72 720 Td (Second page) Tj T* (next line) Tj ET",
+	)
+
+	doc, err := ParsePDF(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, doc.Pages)
+	require.Len(t, doc.Sections, 2)
+	assert.Equal(t, Section{Page: 1, Text: "Quarterly (Q3) report\nRevenue grew"}, doc.Sections[0])
+	assert.Equal(t, Section{Page: 2, Text: "Second page\nnext line"}, doc.Sections[1])
+}
+
+func TestParsePDF_ToUnicode(t *testing.T) {
+	cmap := "begincmap\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"2 beginbfchar <0001> <0048> <0002> <0069> endbfchar\n" +
+		"1 beginbfrange <0003> <0004> <00E9> endbfrange\nendcmap"
+	extra := "4 0 obj\n<< /Type /Font /Subtype /Type0 /BaseFont /Custom /ToUnicode 5 0 R >>\nendobj\n" +
+		fmt.Sprintf("5 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(cmap), cmap)
+
+	doc, err := ParsePDF(buildPDF(t, false, extra, "BT /F2 12 Tf <000100020003> Tj ET"))
+	require.NoError(t, err)
+
+	require.Len(t, doc.Sections, 1)
+	assert.Equal(t, "Hié", doc.Sections[0].Text)
+}
+
+func TestParsePDF_Invalid(t *testing.T) {
+	_, err := ParsePDF([]byte("hello"))
+	assert.EqualError(t, err, "not a PDF file")
+
+	_, err = ParsePDF([]byte("%PDF-1.4\n%%EOF"))
+	assert.EqualError(t, err, "no pages found")
+}
+
+func TestParseHTML(t *testing.T) {
+	data := []byte(`<!doctype html>
+<html>
+<head><title>Release notes</title><style>body { color: red }</style></head>
+<body>
+  <nav><a href="/">Home</a></nav>
+  <p>Intro   paragraph.</p>
+  <h1>Features</h1>
+  <p>Faster <b>parsing</b>.</p>
+  <ul><li>one</li><li>two</li></ul>
+  <script>alert("hi")</script>
+  <h2>Fixes</h2>
+  <table><tr><td>a</td><td>b</td></tr></table>
+</body>
+</html>`)
+
+	doc, err := ParseHTML(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Release notes", doc.Title)
+	assert.Equal(t, []Section{
+		{Text: "Intro paragraph."},
+		{Heading: "Features", Text: "Faster parsing.\n\none\n\ntwo"},
+		{Heading: "Fixes", Text: "a b"},
+	}, doc.Sections)
+}
+
+func buildDOCX(t *testing.T, body string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	f, err := zw.Create("word/document.xml")
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(f, `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>%s</w:body></w:document>`, body)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return b.Bytes()
+}
+
+func TestParseDOCX(t *testing.T) {
+	data := buildDOCX(t, `
+<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t>Handbook</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Welcome </w:t></w:r><w:r><w:t>aboard.</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:br w:type="page"/><w:t>Leave</w:t></w:r></w:p>
+<w:p><w:r><w:t>Ask</w:t><w:tab/><w:t>HR.</w:t></w:r></w:p>`)
+
+	doc, err := ParseDOCX(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, doc.Pages)
+	assert.Equal(t, []Section{
+		{Page: 1, Heading: "Handbook", Text: "Welcome aboard."},
+		{Page: 2, Heading: "Leave", Text: "Ask HR."},
+	}, doc.Sections)
+}
+
+func TestParseDOCX_Invalid(t *testing.T) {
+	_, err := ParseDOCX([]byte("not a zip"))
+	assert.ErrorContains(t, err, "not a DOCX file")
+}
+
+func TestChunks(t *testing.T) {
+	doc := &Document{
+		Source: "notes.txt",
+		Sections: []Section{
+			{Page: 1, Heading: "Intro", Text: "First paragraph here.\n\nSecond paragraph is a little longer."},
+			{Page: 2, Text: "Short."},
+		},
+	}
+
+	chunks := doc.Chunks(40, 0)
+	assert.Equal(t, []Chunk{
+		{Index: 0, Source: "notes.txt", Page: 1, Section: "Intro", Text: "First paragraph here."},
+		{Index: 1, Source: "notes.txt", Page: 1, Section: "Intro", Text: "Second paragraph is a little longer."},
+		{Index: 2, Source: "notes.txt", Page: 2, Text: "Short."},
+	}, chunks)
+
+	for _, chunk := range doc.Chunks(12, 6) {
+		assert.LessOrEqual(t, len([]rune(chunk.Text)), 12, chunk.Text)
+	}
+
+	overlapping := (&Document{Sections: []Section{{Text: "alpha beta gamma delta epsilon"}}}).Chunks(17, 6)
+	require.Len(t, overlapping, 3)
+	assert.Equal(t, "alpha beta gamma", overlapping[0].Text)
+	assert.True(t, strings.HasPrefix(overlapping[1].Text, "gamma"), overlapping[1].Text)
+}
+
+func TestFormatFromPath(t *testing.T) {
+	assert.Equal(t, FormatPDF, FormatFromPath("a/report.PDF"))
+	assert.Equal(t, FormatHTML, FormatFromPath("page.htm"))
+	assert.Equal(t, FormatDOCX, FormatFromPath("memo.docx"))
+	assert.Equal(t, "", FormatFromPath("notes.txt"))
+}
@@ -0,0 +1,573 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+var pdfObjectPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// pdfDocument is a minimal PDF reader. Rather than following the cross
+// reference table it scans the file for objects, which also copes with
+// damaged or incrementally updated files, later definitions winning.
+type pdfDocument struct {
+	objects map[int]interface{}
+}
+
+// ParsePDF extracts the text of each page of a PDF. Text is read from the
+// content streams using the fonts' ToUnicode maps when present, scanned
+// documents without a text layer and encrypted files yield no text.
+func ParsePDF(data []byte) (*Document, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \r\n\t"), []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+
+	doc := &pdfDocument{objects: make(map[int]interface{})}
+	doc.scan(data)
+
+	if trailer := doc.trailer(data); trailer != nil {
+		if _, ok := trailer["Encrypt"]; ok {
+			return nil, errors.New("encrypted PDF files are not supported")
+		}
+	}
+
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, errors.New("no pages found")
+	}
+
+	result := &Document{Format: FormatPDF}
+	for i, page := range pages {
+		text := normalizeText(doc.pageText(page))
+		if text == "" {
+			continue
+		}
+		result.Sections = append(result.Sections, Section{Page: i + 1, Text: text})
+	}
+	result.Pages = len(pages)
+
+	return result, nil
+}
+
+func (d *pdfDocument) scan(data []byte) {
+	var streams []int
+	for _, match := range pdfObjectPattern.FindAllSubmatchIndex(data, -1) {
+		num := atoi(data[match[2]:match[3]])
+		l := &pdfLexer{data: data, pos: match[1]}
+		value, ok := l.object()
+		if !ok {
+			continue
+		}
+
+		if dict, ok := value.(map[string]interface{}); ok {
+			l.skipSpace()
+			if bytes.HasPrefix(data[l.pos:], []byte("stream")) {
+				value = pdfStream{dict: dict, data: d.streamData(data, l.pos+len("stream"), dict)}
+				if dict["Type"] == pdfName("ObjStm") {
+					streams = append(streams, num)
+				}
+			}
+		}
+		d.objects[num] = value
+	}
+
+	// objects packed into object streams by PDF 1.5+ writers
+	for _, num := range streams {
+		stream := d.objects[num].(pdfStream)
+		d.scanObjectStream(stream)
+	}
+}
+
+func (d *pdfDocument) streamData(data []byte, start int, dict map[string]interface{}) []byte {
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+
+	// the length may be an indirect object that hasn't been scanned yet, so
+	// only trust direct lengths and otherwise search for the end marker
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end <= len(data) && bytes.HasPrefix(bytes.TrimLeft(data[end:], " \r\n"), []byte("endstream")) {
+			return data[start:end]
+		}
+	}
+
+	end := bytes.Index(data[start:], []byte("endstream"))
+	if end < 0 {
+		return data[start:]
+	}
+
+	return bytes.TrimRight(data[start:start+end], "\r\n")
+}
+
+func (d *pdfDocument) scanObjectStream(stream pdfStream) {
+	data, err := d.decode(stream)
+	if err != nil {
+		return
+	}
+
+	n, _ := stream.dict["N"].(float64)
+	first, _ := stream.dict["First"].(float64)
+	if int(first) > len(data) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		num, ok1 := header.next()
+		offset, ok2 := header.next()
+		if !ok1 || !ok2 {
+			return
+		}
+
+		objNum, _ := num.(float64)
+		objOffset, _ := offset.(float64)
+		if _, exists := d.objects[int(objNum)]; exists {
+			continue
+		}
+
+		l := &pdfLexer{data: data, pos: int(first) + int(objOffset)}
+		if value, ok := l.object(); ok {
+			d.objects[int(objNum)] = value
+		}
+	}
+}
+
+// trailer returns the trailer dictionary, or the dictionary of the cross
+// reference stream.
+func (d *pdfDocument) trailer(data []byte) map[string]interface{} {
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 {
+		l := &pdfLexer{data: data, pos: i + len("trailer")}
+		if dict, ok := l.object(); ok {
+			if dict, ok := dict.(map[string]interface{}); ok {
+				return dict
+			}
+		}
+	}
+
+	for _, value := range d.objects {
+		if stream, ok := value.(pdfStream); ok && stream.dict["Type"] == pdfName("XRef") {
+			return stream.dict
+		}
+	}
+
+	return nil
+}
+
+func (d *pdfDocument) resolve(value interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = d.objects[ref.num]
+	}
+
+	return nil
+}
+
+func (d *pdfDocument) dict(value interface{}) map[string]interface{} {
+	switch v := d.resolve(value).(type) {
+	case map[string]interface{}:
+		return v
+	case pdfStream:
+		return v.dict
+	}
+
+	return nil
+}
+
+func (d *pdfDocument) decode(stream pdfStream) ([]byte, error) {
+	var filters []interface{}
+	switch f := d.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{f}
+	case []interface{}:
+		filters = f
+	}
+
+	data := stream.data
+	for _, filter := range filters {
+		switch d.resolve(filter) {
+		case pdfName("FlateDecode"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// truncated streams are common, keep what could be inflated
+			decoded, err := io.ReadAll(r)
+			if err != nil && len(decoded) == 0 {
+				return nil, err
+			}
+			data = decoded
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %v", filter)
+		}
+	}
+
+	return data, nil
+}
+
+// pdfPage is a page dictionary with its inherited resources.
+type pdfPage struct {
+	dict      map[string]interface{}
+	resources map[string]interface{}
+}
+
+// pages returns the pages in document order by walking the page tree, or in
+// object order when the tree can't be found.
+func (d *pdfDocument) pages() []pdfPage {
+	var root map[string]interface{}
+	for _, value := range d.objects {
+		if dict := d.dict(value); dict != nil && dict["Type"] == pdfName("Catalog") {
+			root = d.dict(dict["Pages"])
+			break
+		}
+	}
+
+	var pages []pdfPage
+	if root != nil {
+		d.walkPages(root, nil, &pages, 0)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	for _, num := range nums {
+		if dict := d.dict(d.objects[num]); dict != nil && dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: d.dict(dict["Resources"])})
+		}
+	}
+
+	return pages
+}
+
+func (d *pdfDocument) walkPages(node, resources map[string]interface{}, pages *[]pdfPage, depth int) {
+	// the depth limit guards against cycles in malformed page trees
+	if depth > 32 {
+		return
+	}
+
+	if r := d.dict(node["Resources"]); r != nil {
+		resources = r
+	}
+
+	kids, ok := d.resolve(node["Kids"]).([]interface{})
+	if !ok {
+		if node["Type"] == pdfName("Page") || node["Contents"] != nil {
+			*pages = append(*pages, pdfPage{dict: node, resources: resources})
+		}
+		return
+	}
+
+	for _, kid := range kids {
+		if child := d.dict(kid); child != nil {
+			d.walkPages(child, resources, pages, depth+1)
+		}
+	}
+}
+
+func (d *pdfDocument) pageText(page pdfPage) string {
+	var content []byte
+	var parts []interface{}
+	switch c := d.resolve(page.dict["Contents"]).(type) {
+	case pdfStream:
+		parts = []interface{}{c}
+	case []interface{}:
+		parts = c
+	}
+
+	for _, part := range parts {
+		stream, ok := d.resolve(part).(pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := d.decode(stream)
+		if err != nil {
+			continue
+		}
+		content = append(content, data...)
+		content = append(content, '\n')
+	}
+
+	fonts := make(map[string]*pdfFont)
+	if fontDict := d.dict(page.resources["Font"]); fontDict != nil {
+		for name, ref := range fontDict {
+			fonts[name] = d.font(d.dict(ref))
+		}
+	}
+
+	return extractText(content, fonts)
+}
+
+// pdfFont decodes the strings shown with a font.
+type pdfFont struct {
+	twoByte bool
+	unicode map[int]string
+}
+
+func (d *pdfDocument) font(dict map[string]interface{}) *pdfFont {
+	font := &pdfFont{}
+	if dict == nil {
+		return font
+	}
+
+	if dict["Subtype"] == pdfName("Type0") {
+		font.twoByte = true
+	}
+
+	if stream, ok := d.resolve(dict["ToUnicode"]).(pdfStream); ok {
+		if data, err := d.decode(stream); err == nil {
+			font.unicode = parseCMap(data)
+		}
+	}
+
+	return font
+}
+
+func (f *pdfFont) decode(s []byte) string {
+	if f == nil {
+		return latin1(s)
+	}
+
+	var b strings.Builder
+	if f.twoByte {
+		for i := 0; i+1 < len(s); i += 2 {
+			code := int(s[i])<<8 | int(s[i+1])
+			if u, ok := f.unicode[code]; ok {
+				b.WriteString(u)
+			}
+		}
+		return b.String()
+	}
+
+	if f.unicode == nil {
+		return latin1(s)
+	}
+
+	for _, c := range s {
+		if u, ok := f.unicode[int(c)]; ok {
+			b.WriteString(u)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+
+	return b.String()
+}
+
+func latin1(s []byte) string {
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+
+	return string(runes)
+}
+
+// parseCMap reads the bfchar and bfrange mappings of a ToUnicode CMap.
+func parseCMap(data []byte) map[int]string {
+	mapping := make(map[int]string)
+	l := &pdfLexer{data: data}
+
+	var operands []interface{}
+	for {
+		token, ok := l.next()
+		if !ok {
+			return mapping
+		}
+
+		op, isOp := token.(pdfOp)
+		if !isOp {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch op {
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					mapping[cmapCode(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := cmapCode(lo), cmapCode(hi)
+				if end-start > 0xffff {
+					continue
+				}
+
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(utf16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						r := append([]rune{}, base...)
+						r[len(r)-1] += rune(code - start)
+						mapping[code] = string(r)
+					}
+				case []interface{}:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && start+j <= end {
+							mapping[start+j] = utf16BE(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+func cmapCode(s []byte) int {
+	code := 0
+	for _, c := range s {
+		code = code<<8 | int(c)
+	}
+
+	return code
+}
+
+func utf16BE(s []byte) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// extractText interprets the text operators of a content stream, starting a
+// new line whenever the text position moves vertically.
+func extractText(content []byte, fonts map[string]*pdfFont) string {
+	var b strings.Builder
+	var font *pdfFont
+	var operands []interface{}
+	lineY := 0.0
+
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	space := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), " ") && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte(' ')
+		}
+	}
+
+	l := &pdfLexer{data: content}
+	for {
+		token, ok := l.next()
+		if !ok {
+			break
+		}
+
+		op, isOp := token.(pdfOp)
+		if !isOp {
+			operands = append(operands, token)
+			continue
+		}
+
+		switch op {
+		case "BI":
+			// inline images hold binary data up to the EI operator
+			end := bytes.Index(content[l.pos:], []byte("EI"))
+			if end < 0 {
+				return b.String()
+			}
+			l.pos += end + 2
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = fonts[string(name)]
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+					newline()
+				} else {
+					space()
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[5].(float64); ok && y != lineY {
+					lineY = y
+					newline()
+				} else {
+					space()
+				}
+			}
+		case "T*", "ET":
+			newline()
+		case "Tj":
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					b.WriteString(font.decode(s))
+				}
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].(pdfString); ok {
+					b.WriteString(font.decode(s))
+				}
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			items, _ := operands[len(operands)-1].([]interface{})
+			for _, item := range items {
+				switch v := item.(type) {
+				case pdfString:
+					b.WriteString(font.decode(v))
+				case float64:
+					// large negative adjustments separate words
+					if v < -200 {
+						space()
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+
+	return b.String()
+}
+
+func atoi(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n*10 + int(c-'0')
+	}
+
+	return n
+}
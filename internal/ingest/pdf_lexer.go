@@ -0,0 +1,275 @@
+package ingest
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// PDF objects are represented with plain Go values: dictionaries as
+// map[string]interface{}, arrays as []interface{}, numbers as float64 and
+// the types below for the rest.
+type (
+	pdfName   string
+	pdfString []byte
+	pdfOp     string
+	pdfRef    struct{ num, gen int }
+	pdfStream struct {
+		dict map[string]interface{}
+		data []byte
+	}
+)
+
+// pdfLexer reads PDF objects and content stream tokens from a buffer.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next token, references are only recognised when parsing
+// objects with object().
+func (l *pdfLexer) next() (interface{}, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		return l.name(), true
+	case c == '(':
+		return l.literalString(), true
+	case c == '<' && l.peek(1) == '<':
+		l.pos += 2
+		return l.dict(), true
+	case c == '<':
+		return l.hexString(), true
+	case c == '[':
+		l.pos++
+		return l.array(), true
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return pdfOp(string(c)), true
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+
+	if n, err := strconv.ParseFloat(word, 64); err == nil {
+		return n, true
+	}
+
+	switch word {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "null":
+		return nil, true
+	}
+
+	return pdfOp(word), true
+}
+
+func (l *pdfLexer) peek(offset int) byte {
+	if l.pos+offset < len(l.data) {
+		return l.data[l.pos+offset]
+	}
+
+	return 0
+}
+
+// object reads the next object, resolving `num gen R` into a reference.
+func (l *pdfLexer) object() (interface{}, bool) {
+	value, ok := l.next()
+	if !ok {
+		return nil, false
+	}
+
+	num, isNumber := value.(float64)
+	if !isNumber {
+		return value, true
+	}
+
+	save := l.pos
+	if gen, ok := l.next(); ok {
+		if genNum, ok := gen.(float64); ok {
+			if op, ok := l.next(); ok && op == pdfOp("R") {
+				return pdfRef{num: int(num), gen: int(genNum)}, true
+			}
+		}
+	}
+	l.pos = save
+
+	return value, true
+}
+
+func (l *pdfLexer) name() pdfName {
+	l.pos++ // skip /
+	var b []byte
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		l.pos++
+	}
+
+	return pdfName(b)
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++ // skip (
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+
+	return b
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++ // skip <
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // skip >
+
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	b := make([]byte, 0, len(digits)/2)
+	for i := 0; i+1 < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			continue
+		}
+		b = append(b, byte(v))
+	}
+
+	return b
+}
+
+func (l *pdfLexer) array() []interface{} {
+	var items []interface{}
+	for {
+		value, ok := l.object()
+		if !ok || value == pdfOp("]") {
+			return items
+		}
+		items = append(items, value)
+	}
+}
+
+func (l *pdfLexer) dict() map[string]interface{} {
+	dict := make(map[string]interface{})
+	for {
+		l.skipSpace()
+		if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return dict
+		}
+
+		key, ok := l.next()
+		if !ok {
+			return dict
+		}
+
+		name, isName := key.(pdfName)
+		if !isName {
+			continue
+		}
+
+		value, ok := l.object()
+		if !ok {
+			return dict
+		}
+		dict[string(name)] = value
+	}
+}
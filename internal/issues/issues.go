@@ -0,0 +1,141 @@
+// Package issues creates and updates issues in the Jira and Linear issue
+// trackers, so that workflows can file tickets from what they analyzed.
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	TrackerJira   = "jira"
+	TrackerLinear = "linear"
+)
+
+// Trackers lists the supported issue trackers.
+var Trackers = []string{TrackerJira, TrackerLinear}
+
+// maxErrorBodySize limits how much of the body of a failed response is
+// included in errors
+const maxErrorBodySize = 512
+
+// Fields are the fields of an issue to create or update, empty fields are
+// left out. Extra fields are sent as they are, along with the others.
+type Fields struct {
+	Title       string
+	Description string
+	Type        string
+	Priority    string
+	Labels      []string
+	Assignee    string
+	Extra       map[string]interface{}
+}
+
+// Issue is an issue which was created or updated.
+type Issue struct {
+	// ID is the tracker's identifier of the issue
+	ID string `json:"id"`
+	// Key is the identifier people use, such as OPS-12 or ENG-34
+	Key string `json:"key"`
+	// URL is the web page of the issue
+	URL string `json:"url"`
+}
+
+// Tracker creates and updates issues.
+type Tracker interface {
+	// Create files a new issue in the project, a Jira project key or a
+	// Linear team ID.
+	Create(ctx context.Context, project string, fields Fields) (*Issue, error)
+	// Update changes the fields of the issue with the given ID or key.
+	Update(ctx context.Context, issue string, fields Fields) (*Issue, error)
+}
+
+// Config configures the client of a tracker.
+type Config struct {
+	// URL is the site of a Jira instance, e.g. https://acme.atlassian.net, or
+	// the GraphQL endpoint of Linear, which defaults to its public API.
+	URL string
+	// Email is the account of a Jira Cloud API token, which is sent along
+	// with the token using basic authentication. Without it the token is
+	// sent as a bearer token, as Jira Data Center personal access tokens are.
+	Email string
+	// Token authenticates the requests, a Jira API token or a Linear API key
+	Token string
+	// Client sends the requests, http.DefaultClient when it isn't set
+	Client *http.Client
+}
+
+// New returns the client of the named tracker.
+func New(tracker string, config Config) (Tracker, error) {
+	if config.Token == "" {
+		return nil, fmt.Errorf("%s requires a token", tracker)
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	switch tracker {
+	case TrackerJira:
+		if config.URL == "" {
+			return nil, fmt.Errorf("jira requires the url of the site")
+		}
+		return &jira{config: config}, nil
+	case TrackerLinear:
+		if config.URL == "" {
+			config.URL = linearAPIURL
+		}
+		return &linear{config: config}, nil
+	default:
+		return nil, fmt.Errorf("unsupported issue tracker %q, must be one of: %s", tracker, strings.Join(Trackers, ", "))
+	}
+}
+
+// sendJSON sends body as JSON and decodes the JSON response into response
+// unless it's nil. Responses with a status of 400 or more are errors.
+func sendJSON(ctx context.Context, client *http.Client, method, url string, body interface{}, authorize func(*http.Request), response interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	authorize(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		text := strings.TrimSpace(string(data))
+		if len(text) > maxErrorBodySize {
+			text = text[:maxErrorBodySize] + "..."
+		}
+		return fmt.Errorf("request failed with status %s: %s", resp.Status, text)
+	}
+
+	if response == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
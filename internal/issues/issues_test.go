@@ -0,0 +1,181 @@
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedRequest is a request received by a test tracker
+type recordedRequest struct {
+	method        string
+	path          string
+	authorization string
+	body          map[string]interface{}
+}
+
+func newTestTracker(t *testing.T, status int, response string) (*httptest.Server, *[]recordedRequest) {
+	t.Helper()
+
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		request := recordedRequest{method: r.Method, path: r.URL.Path, authorization: r.Header.Get("Authorization")}
+		_ = json.Unmarshal(data, &request.body)
+		requests = append(requests, request)
+
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestJira_Create(t *testing.T) {
+	server, requests := newTestTracker(t, http.StatusCreated, `{"id": "10001", "key": "OPS-12", "self": "ignored"}`)
+
+	tracker, err := New(TrackerJira, Config{URL: server.URL + "/", Email: "bot@example.com", Token: "secret"})
+	require.NoError(t, err)
+
+	issue, err := tracker.Create(context.Background(), "OPS", Fields{
+		Title:       "Checkout is failing",
+		Description: "Errors since 12:00.\nSee the logs.\n\nRaised by triage.",
+		Priority:    "High",
+		Labels:      []string{"triage"},
+		Extra:       map[string]interface{}{"customfield_10010": "checkout"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Issue{ID: "10001", Key: "OPS-12", URL: server.URL + "/browse/OPS-12"}, issue)
+
+	require.Len(t, *requests, 1)
+	request := (*requests)[0]
+	assert.Equal(t, http.MethodPost, request.method)
+	assert.Equal(t, "/rest/api/3/issue", request.path)
+	assert.Equal(t, "Basic Ym90QGV4YW1wbGUuY29tOnNlY3JldA==", request.authorization)
+	assert.Equal(t, map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":   map[string]interface{}{"key": "OPS"},
+			"summary":   "Checkout is failing",
+			"issuetype": map[string]interface{}{"name": "Task"},
+			"priority":  map[string]interface{}{"name": "High"},
+			"labels":    []interface{}{"triage"},
+			"description": map[string]interface{}{
+				"type":    "doc",
+				"version": float64(1),
+				"content": []interface{}{
+					map[string]interface{}{"type": "paragraph", "content": []interface{}{
+						map[string]interface{}{"type": "text", "text": "Errors since 12:00."},
+						map[string]interface{}{"type": "hardBreak"},
+						map[string]interface{}{"type": "text", "text": "See the logs."},
+					}},
+					map[string]interface{}{"type": "paragraph", "content": []interface{}{
+						map[string]interface{}{"type": "text", "text": "Raised by triage."},
+					}},
+				},
+			},
+			"customfield_10010": "checkout",
+		},
+	}, request.body)
+}
+
+func TestJira_Update(t *testing.T) {
+	server, requests := newTestTracker(t, http.StatusNoContent, "")
+
+	tracker, err := New(TrackerJira, Config{URL: server.URL, Token: "pat"})
+	require.NoError(t, err)
+
+	issue, err := tracker.Update(context.Background(), "OPS-12", Fields{Priority: "Low"})
+	require.NoError(t, err)
+	assert.Equal(t, &Issue{ID: "OPS-12", Key: "OPS-12", URL: server.URL + "/browse/OPS-12"}, issue)
+
+	request := (*requests)[0]
+	assert.Equal(t, http.MethodPut, request.method)
+	assert.Equal(t, "/rest/api/3/issue/OPS-12", request.path)
+	assert.Equal(t, "Bearer pat", request.authorization)
+	assert.Equal(t, map[string]interface{}{"fields": map[string]interface{}{"priority": map[string]interface{}{"name": "Low"}}}, request.body)
+}
+
+func TestJira_Error(t *testing.T) {
+	server, _ := newTestTracker(t, http.StatusBadRequest, `{"errors": {"project": "project is required"}}`)
+
+	tracker, err := New(TrackerJira, Config{URL: server.URL, Token: "pat"})
+	require.NoError(t, err)
+
+	_, err = tracker.Create(context.Background(), "", Fields{Title: "t"})
+	assert.EqualError(t, err, `request failed with status 400 Bad Request: {"errors": {"project": "project is required"}}`)
+}
+
+func TestLinear_Create(t *testing.T) {
+	server, requests := newTestTracker(t, http.StatusOK, `{"data": {"issueCreate": {"success": true, "issue": {"id": "abc", "identifier": "ENG-34", "url": "https://linear.app/acme/issue/ENG-34"}}}}`)
+
+	tracker, err := New(TrackerLinear, Config{URL: server.URL, Token: "lin_api_123"})
+	require.NoError(t, err)
+
+	issue, err := tracker.Create(context.Background(), "team-1", Fields{Title: "Checkout is failing", Priority: "urgent", Labels: []string{"label-1"}})
+	require.NoError(t, err)
+	assert.Equal(t, &Issue{ID: "abc", Key: "ENG-34", URL: "https://linear.app/acme/issue/ENG-34"}, issue)
+
+	request := (*requests)[0]
+	assert.Equal(t, "lin_api_123", request.authorization)
+	assert.Contains(t, request.body["query"], "issueCreate(input: $input)")
+	assert.Equal(t, map[string]interface{}{
+		"input": map[string]interface{}{
+			"teamId":   "team-1",
+			"title":    "Checkout is failing",
+			"priority": float64(1),
+			"labelIds": []interface{}{"label-1"},
+		},
+	}, request.body["variables"])
+}
+
+func TestLinear_Update(t *testing.T) {
+	server, requests := newTestTracker(t, http.StatusOK, `{"data": {"issueUpdate": {"success": true, "issue": {"id": "abc", "identifier": "ENG-34", "url": "https://linear.app/acme/issue/ENG-34"}}}}`)
+
+	tracker, err := New(TrackerLinear, Config{URL: server.URL, Token: "oauth-token"})
+	require.NoError(t, err)
+
+	_, err = tracker.Update(context.Background(), "ENG-34", Fields{Priority: "3"})
+	require.NoError(t, err)
+
+	request := (*requests)[0]
+	assert.Equal(t, "Bearer oauth-token", request.authorization)
+	assert.Equal(t, map[string]interface{}{
+		"id":    "ENG-34",
+		"input": map[string]interface{}{"priority": float64(3)},
+	}, request.body["variables"])
+
+	_, err = tracker.Update(context.Background(), "ENG-34", Fields{Priority: "asap"})
+	assert.EqualError(t, err, `invalid linear priority "asap", must be none, urgent, high, medium, low or a number from 0 to 4`)
+}
+
+func TestLinear_Errors(t *testing.T) {
+	server, _ := newTestTracker(t, http.StatusOK, `{"data": null, "errors": [{"message": "Entity not found"}, {"message": "Argument Validation Error"}]}`)
+
+	tracker, err := New(TrackerLinear, Config{URL: server.URL, Token: "lin_api_123"})
+	require.NoError(t, err)
+
+	_, err = tracker.Create(context.Background(), "team-1", Fields{Title: "t"})
+	assert.EqualError(t, err, "issueCreate failed: Entity not found; Argument Validation Error")
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(TrackerJira, Config{Token: "t"})
+	assert.EqualError(t, err, "jira requires the url of the site")
+
+	_, err = New(TrackerLinear, Config{})
+	assert.EqualError(t, err, "linear requires a token")
+
+	_, err = New("github", Config{Token: "t"})
+	assert.EqualError(t, err, `unsupported issue tracker "github", must be one of: jira, linear`)
+
+	tracker, err := New(TrackerLinear, Config{Token: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, linearAPIURL, tracker.(*linear).config.URL)
+}
@@ -0,0 +1,119 @@
+package issues
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultJiraIssueType is the type of issues created without one
+const defaultJiraIssueType = "Task"
+
+// jira creates and updates issues with the Jira REST API, version 3.
+type jira struct {
+	config Config
+}
+
+func (j *jira) Create(ctx context.Context, project string, fields Fields) (*Issue, error) {
+	if fields.Type == "" {
+		fields.Type = defaultJiraIssueType
+	}
+
+	body := j.fields(fields)
+	body["project"] = map[string]interface{}{"key": project}
+
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := sendJSON(ctx, j.config.Client, http.MethodPost, j.apiURL("issue"), map[string]interface{}{"fields": body}, j.authorize, &created); err != nil {
+		return nil, err
+	}
+
+	return &Issue{ID: created.ID, Key: created.Key, URL: j.browseURL(created.Key)}, nil
+}
+
+func (j *jira) Update(ctx context.Context, issue string, fields Fields) (*Issue, error) {
+	if err := sendJSON(ctx, j.config.Client, http.MethodPut, j.apiURL("issue/"+url.PathEscape(issue)), map[string]interface{}{"fields": j.fields(fields)}, j.authorize, nil); err != nil {
+		return nil, err
+	}
+
+	// updates respond without a body, the issue is identified as it was
+	// given, by its ID or key
+	return &Issue{ID: issue, Key: issue, URL: j.browseURL(issue)}, nil
+}
+
+// fields converts fields to the fields of a Jira issue.
+func (j *jira) fields(fields Fields) map[string]interface{} {
+	body := map[string]interface{}{}
+	if fields.Title != "" {
+		body["summary"] = fields.Title
+	}
+	if fields.Description != "" {
+		body["description"] = jiraDocument(fields.Description)
+	}
+	if fields.Type != "" {
+		body["issuetype"] = map[string]interface{}{"name": fields.Type}
+	}
+	if fields.Priority != "" {
+		body["priority"] = map[string]interface{}{"name": fields.Priority}
+	}
+	if len(fields.Labels) > 0 {
+		body["labels"] = fields.Labels
+	}
+	if fields.Assignee != "" {
+		body["assignee"] = map[string]interface{}{"accountId": fields.Assignee}
+	}
+	for name, value := range fields.Extra {
+		body[name] = value
+	}
+
+	return body
+}
+
+func (j *jira) authorize(req *http.Request) {
+	if j.config.Email != "" {
+		req.SetBasicAuth(j.config.Email, j.config.Token)
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+j.config.Token)
+}
+
+func (j *jira) apiURL(path string) string {
+	return strings.TrimSuffix(j.config.URL, "/") + "/rest/api/3/" + path
+}
+
+func (j *jira) browseURL(key string) string {
+	return strings.TrimSuffix(j.config.URL, "/") + "/browse/" + url.PathEscape(key)
+}
+
+// jiraDocument converts text into an Atlassian Document, which Jira requires
+// descriptions to be, with a paragraph per block of text.
+func jiraDocument(text string) map[string]interface{} {
+	paragraphs := []interface{}{}
+	for _, block := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		block = strings.Trim(block, "\n")
+		if block == "" {
+			continue
+		}
+
+		var content []interface{}
+		for i, line := range strings.Split(block, "\n") {
+			if i > 0 {
+				content = append(content, map[string]interface{}{"type": "hardBreak"})
+			}
+			if line != "" {
+				content = append(content, map[string]interface{}{"type": "text", "text": line})
+			}
+		}
+		paragraphs = append(paragraphs, map[string]interface{}{"type": "paragraph", "content": content})
+	}
+
+	return map[string]interface{}{
+		"type":    "doc",
+		"version": 1,
+		"content": paragraphs,
+	}
+}
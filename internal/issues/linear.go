@@ -0,0 +1,145 @@
+package issues
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// linearAPIURL is the GraphQL endpoint of Linear's public API
+const linearAPIURL = "https://api.linear.app/graphql"
+
+// linearPriorities are the priorities of Linear issues by name
+var linearPriorities = map[string]int{
+	"none":   0,
+	"urgent": 1,
+	"high":   2,
+	"medium": 3,
+	"normal": 3,
+	"low":    4,
+}
+
+const linearIssueFields = "success issue { id identifier url }"
+
+// linear creates and updates issues with the Linear GraphQL API.
+type linear struct {
+	config Config
+}
+
+func (l *linear) Create(ctx context.Context, project string, fields Fields) (*Issue, error) {
+	input, err := l.input(fields)
+	if err != nil {
+		return nil, err
+	}
+	input["teamId"] = project
+
+	return l.mutate(ctx, "issueCreate", "mutation IssueCreate($input: IssueCreateInput!) { issueCreate(input: $input) { "+linearIssueFields+" } }", map[string]interface{}{
+		"input": input,
+	})
+}
+
+func (l *linear) Update(ctx context.Context, issue string, fields Fields) (*Issue, error) {
+	input, err := l.input(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.mutate(ctx, "issueUpdate", "mutation IssueUpdate($id: String!, $input: IssueUpdateInput!) { issueUpdate(id: $id, input: $input) { "+linearIssueFields+" } }", map[string]interface{}{
+		"id":    issue,
+		"input": input,
+	})
+}
+
+// mutate sends a mutation returning an issue, GraphQL errors are returned
+// as errors.
+func (l *linear) mutate(ctx context.Context, name, query string, variables map[string]interface{}) (*Issue, error) {
+	var response struct {
+		Data map[string]struct {
+			Success bool `json:"success"`
+			Issue   *struct {
+				ID         string `json:"id"`
+				Identifier string `json:"identifier"`
+				URL        string `json:"url"`
+			} `json:"issue"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	body := map[string]interface{}{"query": query, "variables": variables}
+	if err := sendJSON(ctx, l.config.Client, http.MethodPost, l.config.URL, body, l.authorize, &response); err != nil {
+		return nil, err
+	}
+
+	if len(response.Errors) > 0 {
+		messages := make([]string, len(response.Errors))
+		for i, e := range response.Errors {
+			messages[i] = e.Message
+		}
+		return nil, fmt.Errorf("%s failed: %s", name, strings.Join(messages, "; "))
+	}
+
+	result := response.Data[name]
+	if !result.Success || result.Issue == nil {
+		return nil, fmt.Errorf("%s failed without an error", name)
+	}
+
+	return &Issue{ID: result.Issue.ID, Key: result.Issue.Identifier, URL: result.Issue.URL}, nil
+}
+
+// input converts fields to the input of a Linear issue. Labels and the
+// assignee are the IDs of Linear labels and users.
+func (l *linear) input(fields Fields) (map[string]interface{}, error) {
+	input := map[string]interface{}{}
+	if fields.Title != "" {
+		input["title"] = fields.Title
+	}
+	if fields.Description != "" {
+		input["description"] = fields.Description
+	}
+	if fields.Priority != "" {
+		priority, err := linearPriority(fields.Priority)
+		if err != nil {
+			return nil, err
+		}
+		input["priority"] = priority
+	}
+	if len(fields.Labels) > 0 {
+		input["labelIds"] = fields.Labels
+	}
+	if fields.Assignee != "" {
+		input["assigneeId"] = fields.Assignee
+	}
+	for name, value := range fields.Extra {
+		input[name] = value
+	}
+
+	return input, nil
+}
+
+// linearPriority parses a priority given by name, such as high, or by its
+// number from 0 (none) to 4 (low).
+func linearPriority(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if priority, ok := linearPriorities[value]; ok {
+		return priority, nil
+	}
+	if priority, err := strconv.Atoi(value); err == nil && priority >= 0 && priority <= 4 {
+		return priority, nil
+	}
+
+	return 0, fmt.Errorf("invalid linear priority %q, must be none, urgent, high, medium, low or a number from 0 to 4", value)
+}
+
+// authorize sends the token as it is, as Linear expects of API keys, and
+// OAuth tokens as bearer tokens.
+func (l *linear) authorize(req *http.Request) {
+	if strings.HasPrefix(l.config.Token, "lin_api_") {
+		req.Header.Set("Authorization", l.config.Token)
+		return
+	}
+
+	req.Header.Set("Authorization", "Bearer "+l.config.Token)
+}
@@ -0,0 +1,157 @@
+// Package keychain stores secrets in the OS keychain: the macOS Keychain
+// through security, the Secret Service (libsecret) through secret-tool on
+// Linux and the Windows Credential Manager through PowerShell.
+package keychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Service is the service of every secret Lacquer keeps in the keychain,
+// secrets are told apart by their account.
+const Service = "lacquer"
+
+// ErrNotFound is returned when the keychain has no secret for an account.
+var ErrNotFound = errors.New("secret not found in the keychain")
+
+// notFoundExitCode is the exit code of the Windows scripts and the macOS
+// security tool when there is no secret for the account.
+const notFoundExitCode = 44
+
+// run runs a keychain tool with input on its stdin and returns its output
+// (can be mocked for testing).
+var run = func(input, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...) // #nosec G204 - the tools and arguments are fixed
+	cmd.Stdin = strings.NewReader(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("the keychain is not available, %s was not found", name)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == notFoundExitCode {
+			return "", ErrNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
+
+// Get returns the secret stored for the account.
+func Get(account string) (string, error) {
+	var out string
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		out, err = run("", "security", "find-generic-password", "-s", Service, "-a", account, "-w")
+	case "linux":
+		out, err = run("", "secret-tool", "lookup", "service", Service, "account", account)
+		// secret-tool fails silently when there is no secret
+		if err == nil && out == "" {
+			err = ErrNotFound
+		}
+	case "windows":
+		out, err = powershell("", fmt.Sprintf(`try { $c = $vault.Retrieve(%s, %s) } catch { exit %d }
+$c.RetrievePassword()
+[Console]::Out.Write($c.Password)`, quote(Service), quote(account), notFoundExitCode))
+	default:
+		return "", unsupported()
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to read %s from the keychain: %w", account, err)
+	}
+
+	return strings.TrimRight(out, "\r\n"), nil
+}
+
+// Set stores the secret for the account, replacing any previous secret.
+func Set(account, secret string) error {
+	if strings.ContainsAny(secret, "'\r\n") {
+		return errors.New("secrets can't contain quotes or line breaks")
+	}
+
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		// the secret is passed on stdin so it isn't visible in the process list
+		_, err = run(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", Service, account, quote(secret)), "security", "-i")
+	case "linux":
+		_, err = run(secret, "secret-tool", "store", "--label", "Lacquer "+account, "service", Service, "account", account)
+	case "windows":
+		_, err = powershell(secret, fmt.Sprintf(`$secret = [Console]::In.ReadToEnd()
+try { $vault.Remove($vault.Retrieve(%[1]s, %[2]s)) } catch {}
+$vault.Add((New-Object Windows.Security.Credentials.PasswordCredential(%[1]s, %[2]s, $secret)))`, quote(Service), quote(account)))
+	default:
+		return unsupported()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store %s in the keychain: %w", account, err)
+	}
+
+	return nil
+}
+
+// Delete removes the secret stored for the account.
+func Delete(account string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = run("", "security", "delete-generic-password", "-s", Service, "-a", account)
+	case "linux":
+		_, err = run("", "secret-tool", "clear", "service", Service, "account", account)
+	case "windows":
+		_, err = powershell("", fmt.Sprintf(`try { $vault.Remove($vault.Retrieve(%s, %s)) } catch { exit %d }`, quote(Service), quote(account), notFoundExitCode))
+	default:
+		return unsupported()
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to remove %s from the keychain: %w", account, err)
+	}
+
+	return nil
+}
+
+// powershell runs the script with $vault set to the Windows Credential
+// Manager's password vault.
+func powershell(input, script string) (string, error) {
+	script = `$ErrorActionPreference = 'Stop'
+[void][Windows.Security.Credentials.PasswordVault, Windows.Security.Credentials, ContentType = WindowsRuntime]
+$vault = New-Object Windows.Security.Credentials.PasswordVault
+` + script
+
+	return run(input, "powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+}
+
+// quote quotes a value for a PowerShell script or the macOS security tool's
+// interactive mode, both of which treat single quoted strings literally.
+// Values never contain quotes.
+func quote(value string) string {
+	return "'" + value + "'"
+}
+
+func unsupported() error {
+	return fmt.Errorf("the keychain is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,74 @@
+package keychain
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecretTool mocks secret-tool with an in memory keychain.
+func fakeSecretTool(t *testing.T) map[string]string {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("secret-tool is only used on linux")
+	}
+
+	secrets := map[string]string{}
+	original := run
+	t.Cleanup(func() { run = original })
+
+	run = func(input, name string, args ...string) (string, error) {
+		require.Equal(t, "secret-tool", name)
+		account := args[len(args)-1]
+		switch args[0] {
+		case "lookup":
+			return secrets[account], nil
+		case "store":
+			assert.Equal(t, []string{"--label", "Lacquer " + account, "service", Service, "account", account}, args[1:])
+			secrets[account] = input
+		case "clear":
+			delete(secrets, account)
+		}
+		return "", nil
+	}
+
+	return secrets
+}
+
+func TestKeychain(t *testing.T) {
+	secrets := fakeSecretTool(t)
+
+	_, err := Get("anthropic")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, Set("anthropic", "sk-ant-secret"))
+	assert.Equal(t, "sk-ant-secret", secrets["anthropic"])
+
+	secret, err := Get("anthropic")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-ant-secret", secret)
+
+	require.NoError(t, Delete("anthropic"))
+	assert.Empty(t, secrets)
+
+	err = Set("anthropic", "sk-ant-'; rm -rf")
+	assert.EqualError(t, err, "secrets can't contain quotes or line breaks")
+}
+
+func TestRun(t *testing.T) {
+	out, err := run("secret", "cat")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", out)
+
+	_, err = run("", "sh", "-c", "exit 44")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = run("", "sh", "-c", "echo 'keychain is locked' >&2; exit 1")
+	assert.EqualError(t, err, "keychain is locked")
+
+	_, err = run("", "lacquer-missing-keychain-tool")
+	assert.True(t, strings.HasPrefix(err.Error(), "the keychain is not available"))
+}
@@ -0,0 +1,194 @@
+// Package kv is a small persistent key-value store for workflows, so that
+// scheduled workflows can remember values such as cursors or the IDs of the
+// items they've already processed from one run to the next.
+package kv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+)
+
+// Store keeps the values of each workflow in a JSON file named after the
+// absolute path of the workflow.
+type Store struct {
+	dir    string
+	cipher *encryption.Cipher
+	mu     sync.Mutex
+}
+
+// NewStore creates a store which keeps its files in dir, encrypted with
+// cipher unless it's nil.
+func NewStore(dir string, cipher *encryption.Cipher) *Store {
+	return &Store{dir: dir, cipher: cipher}
+}
+
+// Bucket returns the values of the workflow in the given file.
+func (s *Store) Bucket(workflowFile string) *Bucket {
+	if abs, err := filepath.Abs(workflowFile); err == nil {
+		workflowFile = abs
+	}
+
+	sum := sha256.Sum256([]byte(workflowFile))
+	return &Bucket{store: s, path: filepath.Join(s.dir, hex.EncodeToString(sum[:8])+".json")}
+}
+
+// Bucket is the values of one workflow.
+type Bucket struct {
+	store *Store
+	path  string
+}
+
+// Get returns the value of key and whether it's set.
+func (b *Bucket) Get(key string) (interface{}, bool, error) {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	values, err := b.load()
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok := values[key]
+	return value, ok, nil
+}
+
+// Set sets key to value, returning the previous value and whether there was
+// one.
+func (b *Bucket) Set(key string, value interface{}) (interface{}, bool, error) {
+	return b.update(key, func(values map[string]interface{}) {
+		values[key] = value
+	})
+}
+
+// Delete removes key, returning its value and whether it was set.
+func (b *Bucket) Delete(key string) (interface{}, bool, error) {
+	return b.update(key, func(values map[string]interface{}) {
+		delete(values, key)
+	})
+}
+
+// CompareAndDelete removes key when its value is value, reporting whether
+// it was removed, so that a value another run has replaced since is kept.
+func (b *Bucket) CompareAndDelete(key string, value interface{}) (bool, error) {
+	deleted := false
+	_, _, err := b.update(key, func(values map[string]interface{}) {
+		if current, ok := values[key]; ok && reflect.DeepEqual(current, value) {
+			delete(values, key)
+			deleted = true
+		}
+	})
+	return deleted, err
+}
+
+// Update sets key to the value returned by change, which is given the
+// current value of key and whether it's set, leaving it as it is when change
+// returns false. Updates by concurrent runs using the same store don't
+// interleave, so change can check a value before replacing it. On unix
+// systems the file of the bucket is locked while it's updated, so this also
+// holds for runs in other processes, elsewhere it only holds within a
+// process. The previous value and whether there was one are returned.
+func (b *Bucket) Update(key string, change func(value interface{}, ok bool) (interface{}, bool)) (interface{}, bool, error) {
+	return b.update(key, func(values map[string]interface{}) {
+		current, ok := values[key]
+		if value, set := change(current, ok); set {
+			values[key] = value
+		}
+	})
+}
+
+// update applies change to the values and saves them, returning the value
+// key had before.
+func (b *Bucket) update(key string, change func(map[string]interface{})) (interface{}, bool, error) {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	if err := os.MkdirAll(b.store.dir, 0750); err != nil {
+		return nil, false, fmt.Errorf("failed to create key-value store directory: %w", err)
+	}
+
+	unlock, err := lockFile(b.path + ".lock")
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	values, err := b.load()
+	if err != nil {
+		return nil, false, err
+	}
+
+	previous, ok := values[key]
+	change(values)
+
+	if err := b.save(values); err != nil {
+		return nil, false, err
+	}
+
+	return previous, ok, nil
+}
+
+func (b *Bucket) load() (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key-value store: %w", err)
+	}
+
+	data, err = b.store.cipher.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key-value store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode key-value store: %w", err)
+	}
+
+	return values, nil
+}
+
+// save writes the values to a temporary file which replaces the file of
+// the bucket, so that other processes never read a partly written file.
+func (b *Bucket) save(values map[string]interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to encode key-value store: %w", err)
+	}
+
+	data, err = b.store.cipher.Seal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key-value store: %w", err)
+	}
+
+	if err := os.MkdirAll(b.store.dir, 0750); err != nil {
+		return fmt.Errorf("failed to create key-value store directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(b.store.dir, ".kv-*")
+	if err != nil {
+		return fmt.Errorf("failed to write key-value store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write key-value store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write key-value store: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), b.path)
+}
@@ -0,0 +1,158 @@
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_GetSetDelete(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "kv"), nil)
+	bucket := store.Bucket("workflow.laq.yml")
+
+	_, ok, err := bucket.Get("cursor")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, existed, err := bucket.Set("cursor", 10)
+	require.NoError(t, err)
+	assert.False(t, existed)
+
+	previous, existed, err := bucket.Set("cursor", 20)
+	require.NoError(t, err)
+	assert.True(t, existed)
+	assert.Equal(t, float64(10), previous)
+
+	// values are kept between stores, as they are between runs
+	value, ok, err := NewStore(store.dir, nil).Bucket("workflow.laq.yml").Get("cursor")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float64(20), value)
+
+	previous, existed, err = bucket.Delete("cursor")
+	require.NoError(t, err)
+	assert.True(t, existed)
+	assert.Equal(t, float64(20), previous)
+
+	_, ok, err = bucket.Get("cursor")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBucket_Update(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "kv"), nil)
+	bucket := store.Bucket("workflow.laq.yml")
+
+	setOnce := func(value interface{}, ok bool) (interface{}, bool) {
+		return "first", !ok
+	}
+
+	_, existed, err := bucket.Update("event", setOnce)
+	require.NoError(t, err)
+	assert.False(t, existed)
+
+	previous, existed, err := bucket.Update("event", setOnce)
+	require.NoError(t, err)
+	assert.True(t, existed)
+	assert.Equal(t, "first", previous)
+}
+
+func TestBucket_CompareAndDelete(t *testing.T) {
+	bucket := NewStore(filepath.Join(t.TempDir(), "kv"), nil).Bucket("workflow.laq.yml")
+	_, _, err := bucket.Set("claim", "run_2")
+	require.NoError(t, err)
+
+	deleted, err := bucket.CompareAndDelete("claim", "run_1")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	deleted, err = bucket.CompareAndDelete("claim", "run_2")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	_, ok, err := bucket.Get("claim")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBucket_ScopedPerWorkflow(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "kv"), nil)
+
+	_, _, err := store.Bucket("ingest.laq.yml").Set("cursor", "abc")
+	require.NoError(t, err)
+
+	_, ok, err := store.Bucket("report.laq.yml").Get("cursor")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBucket_Encrypted(t *testing.T) {
+	cipher, err := encryption.NewCipher(make([]byte, encryption.KeySize))
+	require.NoError(t, err)
+
+	store := NewStore(filepath.Join(t.TempDir(), "kv"), cipher)
+	bucket := store.Bucket("workflow.laq.yml")
+	_, _, err = bucket.Set("token", "secret-value")
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(bucket.path)
+	require.NoError(t, err)
+	assert.True(t, encryption.IsEncrypted(data))
+	assert.NotContains(t, string(data), "secret-value")
+
+	value, _, err := bucket.Get("token")
+	require.NoError(t, err)
+	assert.Equal(t, "secret-value", value)
+}
+
+func TestBucket_ConcurrentSet(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "kv"), nil)
+	bucket := store.Bucket("workflow.laq.yml")
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := bucket.Set(key, true)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		_, ok, err := bucket.Get(key)
+		require.NoError(t, err)
+		assert.True(t, ok, key)
+	}
+}
+
+func TestBucket_UpdateAcrossStores(t *testing.T) {
+	// stores of separate processes don't share a mutex, the file lock keeps
+	// their updates from interleaving
+	dir := filepath.Join(t.TempDir(), "kv")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bucket := NewStore(dir, nil).Bucket("workflow.laq.yml")
+			_, _, err := bucket.Update("count", func(value interface{}, ok bool) (interface{}, bool) {
+				count, _ := value.(float64)
+				return count + 1, true
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	value, _, err := NewStore(dir, nil).Bucket("workflow.laq.yml").Get("count")
+	require.NoError(t, err)
+	assert.Equal(t, float64(10), value)
+}
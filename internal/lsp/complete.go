@@ -0,0 +1,307 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/expression"
+)
+
+// namespaces are the variables expressions can reference
+var namespaces = []completionItem{
+	{Label: "inputs", Kind: completionKindModule, Detail: "The inputs of the workflow"},
+	{Label: "steps", Kind: completionKindModule, Detail: "The results of the steps which have run"},
+	{Label: "state", Kind: completionKindModule, Detail: "The state of the workflow, set by steps' updates"},
+	{Label: "env", Kind: completionKindModule, Detail: "The environment variables of the run"},
+	{Label: "metadata", Kind: completionKindModule, Detail: "The metadata of the workflow"},
+	{Label: "workflow", Kind: completionKindModule, Detail: "The run of the workflow"},
+	{Label: "each", Kind: completionKindModule, Detail: "The item and index of a for_each iteration"},
+	{Label: "retry", Kind: completionKindModule, Detail: "The attempt and error of a retry_if condition"},
+	{Label: "trigger", Kind: completionKindModule, Detail: "What triggered the run"},
+}
+
+// stepFields are the fields of the result of a step
+var stepFields = []completionItem{
+	{Label: "output", Kind: completionKindField, Detail: "The response of the step"},
+	{Label: "outputs", Kind: completionKindField, Detail: "The structured outputs of the step"},
+	{Label: "status", Kind: completionKindField, Detail: "Whether the step completed, failed or was skipped"},
+	{Label: "error", Kind: completionKindField, Detail: "The error of the step when it failed"},
+	{Label: "cost", Kind: completionKindField, Detail: "The cost of the step in dollars"},
+	{Label: "tokens", Kind: completionKindField, Detail: "The prompt, completion and total tokens of the step"},
+	{Label: "duration_ms", Kind: completionKindField, Detail: "How long the step took"},
+}
+
+var (
+	eachFields = []completionItem{
+		{Label: "item", Kind: completionKindField, Detail: "The item of the iteration"},
+		{Label: "index", Kind: completionKindField, Detail: "The index of the item, from 0"},
+	}
+	retryFields = []completionItem{
+		{Label: "attempt", Kind: completionKindField, Detail: "The attempt which failed, from 1"},
+		{Label: "error", Kind: completionKindField, Detail: "The error of the attempt"},
+	}
+)
+
+var (
+	stepOutputsPattern = regexp.MustCompile(`steps\.([\w-]+)\.outputs\.\w*$`)
+	stepFieldPattern   = regexp.MustCompile(`steps\.([\w-]+)\.\w*$`)
+	stepsPattern       = regexp.MustCompile(`steps\.[\w-]*$`)
+	inputsPattern      = regexp.MustCompile(`inputs\.\w*$`)
+	statePattern       = regexp.MustCompile(`state\.\w*$`)
+	eachPattern        = regexp.MustCompile(`each\.\w*$`)
+	retryPattern       = regexp.MustCompile(`retry\.\w*$`)
+	identifierPattern  = regexp.MustCompile(`(^|[^\w.])\w*$`)
+	agentPattern       = regexp.MustCompile(`^\s*(-\s+)?agent:\s*["']?[\w-]*$`)
+	dependsOnPattern   = regexp.MustCompile(`^\s*(-\s+)?depends_on:\s*\[([\w-]+,\s*)*[\w-]*$`)
+	identifierChars    = regexp.MustCompile(`\w`)
+)
+
+// complete returns the completions at a position of a document: inside an
+// expression the namespaces, functions and the names the workflow defines
+// under them, and otherwise the agents a step can use and the steps it can
+// depend on.
+func (s *Server) complete(doc *document, pos position) []completionItem {
+	prefix := linePrefix(doc, pos)
+	workflow := doc.workflow
+	if workflow == nil {
+		workflow = &ast.Workflow{}
+	}
+
+	expr, ok := expressionPrefix(prefix)
+	if !ok {
+		switch {
+		case agentPattern.MatchString(prefix):
+			return agentItems(workflow)
+		case dependsOnPattern.MatchString(prefix):
+			return stepItems(workflow)
+		}
+		return nil
+	}
+
+	if match := stepOutputsPattern.FindStringSubmatch(expr); match != nil {
+		return stepOutputItems(workflow, match[1])
+	}
+	if match := stepFieldPattern.FindStringSubmatch(expr); match != nil {
+		return stepFields
+	}
+
+	switch {
+	case stepsPattern.MatchString(expr):
+		return stepItems(workflow)
+	case inputsPattern.MatchString(expr):
+		return inputItems(workflow)
+	case statePattern.MatchString(expr):
+		return stateItems(workflow)
+	case eachPattern.MatchString(expr):
+		return eachFields
+	case retryPattern.MatchString(expr):
+		return retryFields
+	case identifierPattern.MatchString(expr):
+		return append(append([]completionItem{}, namespaces...), s.functionItems()...)
+	}
+	return nil
+}
+
+// linePrefix returns the text of the line of a position before it.
+func linePrefix(doc *document, pos position) string {
+	if pos.Line < 0 || pos.Line >= len(doc.lines) {
+		return ""
+	}
+	line := strings.TrimSuffix(doc.lines[pos.Line], "\r")
+	return line[:min(max(pos.Character, 0), len(line))]
+}
+
+// expressionPrefix returns the text of the expression a line ends in,
+// when it ends in one.
+func expressionPrefix(prefix string) (string, bool) {
+	start := strings.LastIndex(prefix, "${{")
+	if start < 0 {
+		return "", false
+	}
+
+	expr := prefix[start+len("${{"):]
+	if strings.Contains(expr, "}}") {
+		return "", false
+	}
+	return expr, true
+}
+
+func agentItems(workflow *ast.Workflow) []completionItem {
+	items := make([]completionItem, 0, len(workflow.Agents))
+	for name, agent := range workflow.Agents {
+		detail := ""
+		if agent != nil {
+			detail = strings.Trim(agent.Provider+"/"+agent.Model, "/")
+		}
+		items = append(items, completionItem{Label: name, Kind: completionKindValue, Detail: detail})
+	}
+	return sortItems(items)
+}
+
+func stepItems(workflow *ast.Workflow) []completionItem {
+	var items []completionItem
+	walkSteps(workflow.GetSteps(), func(step *ast.Step) {
+		if step.ID != "" {
+			items = append(items, completionItem{Label: step.ID, Kind: completionKindVariable, Detail: "step"})
+		}
+	})
+	return items
+}
+
+func stepOutputItems(workflow *ast.Workflow, stepID string) []completionItem {
+	var items []completionItem
+	walkSteps(workflow.GetSteps(), func(step *ast.Step) {
+		if step.ID != stepID {
+			return
+		}
+		for name, output := range step.Outputs {
+			detail, _ := output.Type.(string)
+			items = append(items, completionItem{Label: name, Kind: completionKindField, Detail: detail, Documentation: output.Description})
+		}
+	})
+	return sortItems(items)
+}
+
+func inputItems(workflow *ast.Workflow) []completionItem {
+	items := make([]completionItem, 0, len(workflow.Inputs))
+	for name, input := range workflow.Inputs {
+		item := completionItem{Label: name, Kind: completionKindVariable}
+		if input != nil {
+			item.Detail = input.GetTypeString()
+			item.Documentation = input.Description
+		}
+		items = append(items, item)
+	}
+	return sortItems(items)
+}
+
+// stateItems returns the initial state of the workflow and the state its
+// steps update.
+func stateItems(workflow *ast.Workflow) []completionItem {
+	keys := make(map[string]bool)
+	if workflow.Workflow != nil {
+		for key := range workflow.Workflow.State {
+			keys[key] = true
+		}
+	}
+	walkSteps(workflow.GetSteps(), func(step *ast.Step) {
+		for key := range step.Updates {
+			keys[key] = true
+		}
+	})
+
+	items := make([]completionItem, 0, len(keys))
+	for key := range keys {
+		items = append(items, completionItem{Label: key, Kind: completionKindVariable})
+	}
+	return sortItems(items)
+}
+
+func (s *Server) functionItems() []completionItem {
+	functions := s.functions.ListFunctions()
+	items := make([]completionItem, 0, len(functions))
+	for _, fn := range functions {
+		items = append(items, completionItem{
+			Label:         fn.Name,
+			Kind:          completionKindFunction,
+			Detail:        signature(fn),
+			Documentation: fn.Description,
+		})
+	}
+	return sortItems(items)
+}
+
+// walkSteps calls visit with every step, including the sub steps of
+// loops, parallel and race steps, in the order they are defined.
+func walkSteps(steps []*ast.Step, visit func(*ast.Step)) {
+	for _, step := range steps {
+		if step == nil {
+			continue
+		}
+		visit(step)
+		walkSteps(step.Steps, visit)
+		if step.Parallel != nil {
+			walkSteps(step.Parallel.Steps, visit)
+		}
+		if step.Race != nil {
+			for _, branch := range step.Race.Branches {
+				if branch != nil {
+					walkSteps(branch.Steps, visit)
+				}
+			}
+		}
+	}
+}
+
+func sortItems(items []completionItem) []completionItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Label < items[j].Label
+	})
+	return items
+}
+
+// hover describes the function at a position of a document.
+func (s *Server) hover(doc *document, pos position) *hover {
+	if pos.Line < 0 || pos.Line >= len(doc.lines) {
+		return nil
+	}
+	line := strings.TrimSuffix(doc.lines[pos.Line], "\r")
+	if pos.Character < 0 || pos.Character > len(line) {
+		return nil
+	}
+
+	start, end := pos.Character, pos.Character
+	for start > 0 && identifierChars.MatchString(line[start-1:start]) {
+		start--
+	}
+	for end < len(line) && identifierChars.MatchString(line[end:end+1]) {
+		end++
+	}
+	if start == end || !strings.HasPrefix(strings.TrimLeft(line[end:], " "), "(") {
+		return nil
+	}
+	if _, ok := expressionPrefix(line[:start]); !ok {
+		return nil
+	}
+
+	fn, ok := s.functions.GetFunctionDefinition(line[start:end])
+	if !ok {
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "```\n%s\n```\n\n%s", signature(fn), fn.Description)
+	if fn.Example != "" {
+		fmt.Fprintf(&sb, "\n\nExample: `%s`", fn.Example)
+	}
+
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: sb.String()},
+		Range: &lspRange{
+			Start: position{Line: pos.Line, Character: start},
+			End:   position{Line: pos.Line, Character: end},
+		},
+	}
+}
+
+// signature returns the signature of a function, e.g.
+// join(array: array, separator?: string) string
+func signature(fn *expression.FunctionDefinition) string {
+	args := make([]string, 0, len(fn.Args))
+	for _, arg := range fn.Args {
+		name := arg.Name
+		if !arg.Required {
+			name += "?"
+		}
+		args = append(args, fmt.Sprintf("%s: %s", name, arg.Type))
+	}
+
+	sig := fmt.Sprintf("%s(%s)", fn.Name, strings.Join(args, ", "))
+	if fn.Returns != "" {
+		sig += " " + fn.Returns
+	}
+	return sig
+}
@@ -0,0 +1,280 @@
+// Package lsp serves the Language Server Protocol for workflow files, so
+// that editors show the errors of a workflow as it is written, complete
+// the names it references and describe the functions of its expressions.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/expression"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// source is the source of the diagnostics the server publishes
+const source = "laq"
+
+// Server is a language server for workflow files. It keeps the documents
+// the client opened and answers the requests of one client at a time.
+type Server struct {
+	version   string
+	parser    *parser.YAMLParser
+	functions *expression.FunctionRegistry
+	documents map[string]*document
+	conn      *conn
+	shutdown  bool
+}
+
+// document is a workflow file opened in the client
+type document struct {
+	uri      string
+	filename string
+	lines    []string
+	// workflow is the last version of the document which could be read
+	// into a workflow, used to complete the names it defines while it's
+	// being edited into invalid YAML
+	workflow *ast.Workflow
+}
+
+// NewServer returns a language server reporting version as its own.
+func NewServer(version string) (*Server, error) {
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parser: %w", err)
+	}
+
+	return &Server{
+		version:   version,
+		parser:    yamlParser,
+		functions: expression.NewFunctionRegistry(),
+		documents: make(map[string]*document),
+	}, nil
+}
+
+// Serve reads requests from r and writes responses and notifications to
+// w until the client sends exit, closes r or ctx is done.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+
+	for ctx.Err() == nil {
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		var parseErr *parseError
+		if errors.As(err, &parseErr) {
+			if err := s.conn.write(&message{Error: &responseError{Code: codeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, respErr := s.handle(msg)
+		if msg.ID == nil {
+			if respErr != nil {
+				log.Debug().Str("method", msg.Method).Str("error", respErr.Message).Msg("Failed to handle notification")
+			}
+			continue
+		}
+
+		response := &message{ID: msg.ID, Result: result, Error: respErr}
+		if respErr == nil && result == nil {
+			response.Result = json.RawMessage("null")
+		}
+		if err := s.conn.write(response); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// handle handles a request or notification, returning the result of
+// requests.
+func (s *Server) handle(msg *message) (any, *responseError) {
+	if s.shutdown {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:   1,
+				CompletionProvider: &completionOptions{TriggerCharacters: []string{".", " "}},
+				HoverProvider:      true,
+			},
+			ServerInfo: serverInfo{Name: "laq", Version: s.version},
+		}, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc := &document{uri: params.TextDocument.URI, filename: filenameFromURI(params.TextDocument.URI)}
+		s.documents[doc.uri] = doc
+		return nil, s.update(doc, params.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok || len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// documents are synced in full, the last change is the whole text
+		return nil, s.update(doc, params.ContentChanges[len(params.ContentChanges)-1].Text)
+
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []diagnostic{})
+
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return completionList{Items: s.complete(doc, params.Position)}, nil
+
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		if h := s.hover(doc, params.Position); h != nil {
+			return h, nil
+		}
+		return nil, nil
+
+	case "initialized":
+		return nil, nil
+	}
+
+	if msg.ID == nil {
+		// notifications the server doesn't handle, such as $/cancelRequest,
+		// are ignored as the protocol allows
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s not found", msg.Method)}
+}
+
+// update sets the text of a document and publishes its diagnostics.
+func (s *Server) update(doc *document, text string) *responseError {
+	doc.lines = strings.Split(text, "\n")
+
+	var workflow ast.Workflow
+	if err := yaml.Unmarshal([]byte(text), &workflow); err == nil {
+		doc.workflow = &workflow
+	}
+
+	return s.publish(doc.uri, s.diagnose(doc.filename, []byte(text)))
+}
+
+func (s *Server) publish(uri string, diagnostics []diagnostic) *responseError {
+	params, err := json.Marshal(publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	if err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	if err := s.conn.write(&message{Method: "textDocument/publishDiagnostics", Params: params}); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// diagnose parses and validates a document as laq validate does,
+// returning its errors and warnings.
+func (s *Server) diagnose(filename string, text []byte) []diagnostic {
+	diagnostics := make([]diagnostic, 0)
+
+	_, err := s.parser.ParseBytes(text, filename)
+	if err == nil {
+		return diagnostics
+	}
+
+	var multiErr *parser.MultiErrorEnhanced
+	if !errors.As(err, &multiErr) {
+		return append(diagnostics, diagnostic{
+			Severity: severityError,
+			Source:   source,
+			Message:  err.Error(),
+		})
+	}
+
+	for _, d := range multiErr.Diagnostics() {
+		start := position{Line: max(d.Line-1, 0), Character: max(d.Column-1, 0)}
+		end := position{Line: start.Line, Character: start.Character + 1}
+		if d.EndColumn > 0 {
+			end.Character = d.EndColumn - 1
+		}
+
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    lspRange{Start: start, End: end},
+			Severity: lspSeverity(d.Severity),
+			Code:     d.Rule,
+			Source:   source,
+			Message:  d.Message,
+		})
+	}
+	return diagnostics
+}
+
+func lspSeverity(severity parser.ErrorSeverity) int {
+	switch severity {
+	case parser.SeverityError:
+		return severityError
+	case parser.SeverityWarning:
+		return severityWarning
+	default:
+		return severityInformation
+	}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// filenameFromURI returns the path of a file URI, or the URI itself when
+// it isn't one, e.g. for documents which haven't been saved yet.
+func filenameFromURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
@@ -0,0 +1,225 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWorkflow = `version: "1.0"
+inputs:
+  topic:
+    type: string
+    description: What to write about
+agents:
+  writer:
+    provider: anthropic
+    model: claude-3-haiku-20240307
+workflow:
+  state:
+    drafts: 0
+  steps:
+    - id: research
+      agent: writer
+      prompt: "Research ${{ inputs.topic }}"
+      outputs:
+        sources:
+          type: array
+          description: Where the research comes from
+    - id: write
+      agent:
+      prompt: "Write ${{ steps.research.outputs.sources }} ${{ join(inputs.topic) }}"
+`
+
+// client drives a server over pipes as an editor would.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	done   chan error
+	closer io.Closer
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	server, err := NewServer("test")
+	require.NoError(t, err)
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	c := &client{
+		t:      t,
+		conn:   newConn(clientReader, clientWriter),
+		done:   make(chan error, 1),
+		closer: clientWriter,
+	}
+	go func() {
+		c.done <- server.Serve(context.Background(), serverReader, serverWriter)
+		_ = serverWriter.Close()
+	}()
+	t.Cleanup(func() { _ = c.closer.Close() })
+
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	body, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(&message{Method: method, Params: body}))
+}
+
+// request sends a request and returns its response, skipping the
+// notifications sent before it.
+func (c *client) request(method string, params any) *message {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	body, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(&message{ID: &id, Method: method, Params: body}))
+
+	for {
+		msg := c.read()
+		if msg.ID != nil {
+			assert.JSONEq(c.t, string(id), string(*msg.ID))
+			return msg
+		}
+	}
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+	msg, err := c.conn.read()
+	require.NoError(c.t, err)
+	return msg
+}
+
+func (c *client) diagnostics() publishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.read()
+	require.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+
+	var params publishDiagnosticsParams
+	require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+	return params
+}
+
+func decode[T any](t *testing.T, msg *message) T {
+	t.Helper()
+	require.Nil(t, msg.Error)
+	body, err := json.Marshal(msg.Result)
+	require.NoError(t, err)
+
+	var result T
+	require.NoError(t, json.Unmarshal(body, &result))
+	return result
+}
+
+// positionAfter returns the position after the first occurrence of before
+// in text.
+func positionAfter(t *testing.T, text, before string) position {
+	t.Helper()
+	index := strings.Index(text, before)
+	require.GreaterOrEqual(t, index, 0, before)
+
+	index += len(before)
+	line := strings.Count(text[:index], "\n")
+	return position{Line: line, Character: index - strings.LastIndex(text[:index], "\n") - 1}
+}
+
+func labels(items []completionItem) []string {
+	labels := make([]string, 0, len(items))
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	return labels
+}
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+	uri := "file:///workflows/test.laq.yaml"
+
+	initialize := decode[initializeResult](t, c.request("initialize", map[string]any{}))
+	assert.Equal(t, 1, initialize.Capabilities.TextDocumentSync)
+	assert.True(t, initialize.Capabilities.HoverProvider)
+	assert.Equal(t, "test", initialize.ServerInfo.Version)
+	c.notify("initialized", map[string]any{})
+
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{URI: uri, Text: testWorkflow}})
+	diagnostics := c.diagnostics()
+	assert.Equal(t, uri, diagnostics.URI)
+	require.NotEmpty(t, diagnostics.Diagnostics)
+	assert.Equal(t, severityError, diagnostics.Diagnostics[0].Severity)
+	assert.Equal(t, "laq", diagnostics.Diagnostics[0].Source)
+
+	complete := func(before string) []string {
+		t.Helper()
+		list := decode[completionList](t, c.request("textDocument/completion", textDocumentPositionParams{
+			TextDocument: textDocumentIdentifier{URI: uri},
+			Position:     positionAfter(t, testWorkflow, before),
+		}))
+		return labels(list.Items)
+	}
+
+	t.Run("completion", func(t *testing.T) {
+		assert.Equal(t, []string{"topic"}, complete("Research ${{ inputs."))
+		assert.Contains(t, complete("Research ${{ "), "steps")
+		assert.Contains(t, complete("Research ${{ "), "join")
+		assert.Equal(t, []string{"writer"}, complete("id: write\n      agent:"))
+		assert.Equal(t, []string{"research", "write"}, complete("Write ${{ steps."))
+		assert.Contains(t, complete("Write ${{ steps.research."), "output")
+		assert.Equal(t, []string{"sources"}, complete("Write ${{ steps.research.outputs."))
+		assert.Empty(t, complete("prompt: \"Res"))
+	})
+
+	t.Run("hover", func(t *testing.T) {
+		result := decode[hover](t, c.request("textDocument/hover", textDocumentPositionParams{
+			TextDocument: textDocumentIdentifier{URI: uri},
+			Position:     positionAfter(t, testWorkflow, "${{ jo"),
+		}))
+		assert.Contains(t, result.Contents.Value, "join(array: array, separator?: string)")
+		require.NotNil(t, result.Range)
+		assert.Equal(t, positionAfter(t, testWorkflow, "Write ${{ steps.research.outputs.sources }} ${{ "), result.Range.Start)
+
+		msg := c.request("textDocument/hover", textDocumentPositionParams{
+			TextDocument: textDocumentIdentifier{URI: uri},
+			Position:     positionAfter(t, testWorkflow, "${{ inp"),
+		})
+		assert.Nil(t, msg.Error)
+		assert.Nil(t, msg.Result)
+	})
+
+	t.Run("change", func(t *testing.T) {
+		fixed := strings.Replace(testWorkflow, "id: write\n      agent:", "id: write\n      agent: writer", 1)
+		c.notify("textDocument/didChange", didChangeParams{
+			TextDocument: textDocumentIdentifier{URI: uri},
+			ContentChanges: []struct {
+				Text string `json:"text"`
+			}{{Text: fixed}},
+		})
+		assert.Empty(t, c.diagnostics().Diagnostics)
+
+		c.notify("textDocument/didClose", didCloseParams{TextDocument: textDocumentIdentifier{URI: uri}})
+		assert.Empty(t, c.diagnostics().Diagnostics)
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		msg := c.request("workspace/symbol", map[string]any{})
+		require.NotNil(t, msg.Error)
+		assert.Equal(t, codeMethodNotFound, msg.Error.Code)
+	})
+
+	msg := c.request("shutdown", nil)
+	assert.Nil(t, msg.Error)
+	c.notify("exit", nil)
+	require.NoError(t, <-c.done)
+}
@@ -0,0 +1,81 @@
+package parser
+
+// Rules of the errors reported by the parser.
+const (
+	RuleFileExtension = "file-extension"
+	RuleFileRead      = "file-read"
+	RuleFileSize      = "file-size"
+	RuleEmptyFile     = "empty-file"
+	RuleYAMLSyntax    = "yaml-syntax"
+	RuleYAMLType      = "yaml-type"
+	RuleSemantic      = "semantic"
+	RuleTemplate      = "template"
+)
+
+// Rule describes a kind of error.
+type Rule struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// Rules lists the rules of the errors reported by the parser.
+var Rules = []Rule{
+	{ID: RuleFileExtension, Description: "Workflow files must have a .laq.yaml or .laq.yml extension"},
+	{ID: RuleFileRead, Description: "Workflow files must be readable"},
+	{ID: RuleFileSize, Description: "Workflow files must be smaller than 10MB"},
+	{ID: RuleEmptyFile, Description: "Workflow files must not be empty"},
+	{ID: RuleYAMLSyntax, Description: "Workflow files must be valid YAML"},
+	{ID: RuleYAMLType, Description: "Workflow fields must have the expected types"},
+	{ID: RuleSemantic, Description: "Workflows must follow the workflow schema and reference agents, steps and inputs which exist"},
+	{ID: RuleTemplate, Description: "Prompts and expressions must render with sample inputs, checked by laq validate --smoke"},
+}
+
+// Diagnostic is an error or warning at a position of a file, in the flat
+// form consumed by editors and CI annotations.
+type Diagnostic struct {
+	File     string        `json:"file" yaml:"file"`
+	Line     int           `json:"line" yaml:"line"`
+	Column   int           `json:"column" yaml:"column"`
+	Severity ErrorSeverity `json:"severity" yaml:"severity"`
+	Rule     string        `json:"rule" yaml:"rule"`
+	Message  string        `json:"message" yaml:"message"`
+	// EndColumn is the column after the token at the position, zero when
+	// the position isn't at a token
+	EndColumn int `json:"end_column,omitempty" yaml:"end_column,omitempty"`
+}
+
+// Diagnostic returns the error as a diagnostic.
+func (e *EnhancedError) Diagnostic() Diagnostic {
+	message := e.Message
+	if message == "" {
+		message = e.Title
+	}
+
+	diagnostic := Diagnostic{
+		File:     e.Position.File,
+		Line:     e.Position.Line,
+		Column:   e.Position.Column,
+		Severity: e.Severity,
+		Rule:     e.Rule,
+		Message:  message,
+	}
+	if e.Context != nil && e.Context.Highlight.EndColumn > diagnostic.Column {
+		diagnostic.EndColumn = e.Context.Highlight.EndColumn
+	}
+
+	return diagnostic
+}
+
+// Diagnostics returns the errors and then the warnings as diagnostics.
+func (e *MultiErrorEnhanced) Diagnostics() []Diagnostic {
+	issues := e.GetAllIssues()
+	diagnostics := make([]Diagnostic, 0, len(issues))
+	for _, issue := range issues {
+		diagnostic := issue.Diagnostic()
+		if diagnostic.File == "" {
+			diagnostic.File = e.Filename
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiErrorEnhanced_Diagnostics(t *testing.T) {
+	p, err := NewYAMLParser()
+	require.NoError(t, err)
+
+	t.Run("semantic errors", func(t *testing.T) {
+		_, err := p.ParseBytes([]byte(`version: "1.0"
+workflow:
+  steps:
+    - id: greet
+      agent: missing
+      prompt: "Hello"
+`), "greet.laq.yaml")
+
+		var multiErr *MultiErrorEnhanced
+		require.True(t, errors.As(err, &multiErr))
+
+		diagnostics := multiErr.Diagnostics()
+		require.NotEmpty(t, diagnostics)
+		assert.Equal(t, Diagnostic{
+			File:      "greet.laq.yaml",
+			Line:      5,
+			Column:    14,
+			Severity:  SeverityError,
+			Rule:      RuleSemantic,
+			Message:   diagnostics[0].Message,
+			EndColumn: 21,
+		}, diagnostics[0])
+		assert.Contains(t, diagnostics[0].Message, "missing")
+	})
+
+	t.Run("yaml errors", func(t *testing.T) {
+		_, err := p.ParseBytes([]byte("version: \"1.0\"\nworkflow: [\n"), "broken.laq.yaml")
+
+		var multiErr *MultiErrorEnhanced
+		require.True(t, errors.As(err, &multiErr))
+
+		diagnostics := multiErr.Diagnostics()
+		require.Len(t, diagnostics, 1)
+		assert.Equal(t, "broken.laq.yaml", diagnostics[0].File)
+		assert.Equal(t, RuleYAMLSyntax, diagnostics[0].Rule)
+		assert.Equal(t, SeverityError, diagnostics[0].Severity)
+	})
+
+	t.Run("file errors", func(t *testing.T) {
+		_, err := p.ParseFile("workflow.yaml")
+
+		var multiErr *MultiErrorEnhanced
+		require.True(t, errors.As(err, &multiErr))
+		assert.Equal(t, []Diagnostic{{
+			File:     "workflow.yaml",
+			Line:     1,
+			Column:   1,
+			Severity: SeverityError,
+			Rule:     RuleFileExtension,
+			Message:  "Expected .laq.yaml or .laq.yml, got .yaml",
+		}}, multiErr.Diagnostics())
+	})
+}
+
+func TestRules(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, rule := range Rules {
+		assert.False(t, seen[rule.ID], rule.ID)
+		assert.NotEmpty(t, rule.Description, rule.ID)
+		seen[rule.ID] = true
+	}
+}
@@ -30,6 +30,9 @@ type EnhancedError struct {
 	Suggestion    *ErrorSuggestion `json:"suggestion,omitempty"`
 	RelatedErrors []string         `json:"related_errors,omitempty"`
 	Category      string           `json:"category"`
+	// Rule identifies the kind of error, unlike ID it doesn't depend on
+	// where the error is. See Rules.
+	Rule string `json:"rule"`
 }
 
 // ErrorContext provides source code context around the error
@@ -130,6 +133,8 @@ type ErrorReporter struct {
 	warnings []*EnhancedError
 	source   []byte
 	filename string
+	// lines indexes source, built by the first error needing its context
+	lines *lineIndex
 }
 
 // NewErrorReporter creates a new error reporter
@@ -164,6 +169,7 @@ func (r *ErrorReporter) AddError(err *EnhancedError) {
 func (r *ErrorReporter) AddSimpleError(message string, pos ast.Position, category string) {
 	err := &EnhancedError{
 		ID:         generateErrorID(category, pos),
+		Rule:       category,
 		Severity:   SeverityError,
 		Title:      message,
 		Message:    "",
@@ -216,28 +222,34 @@ func (r *ErrorReporter) ToError() error {
 	}
 }
 
+// lineIndex returns the index of the lines of the source, indexing it the
+// first time it's needed
+func (r *ErrorReporter) lineIndex() *lineIndex {
+	if r.lines == nil {
+		r.lines = newLineIndex(r.source)
+	}
+	return r.lines
+}
+
 // buildContext creates error context around the given position
 func (r *ErrorReporter) buildContext(pos ast.Position, radius int) *ErrorContext {
 	if r.source == nil {
 		return nil
 	}
 
-	lines := strings.Split(string(r.source), "\n")
-	if pos.Line < 1 || pos.Line > len(lines) {
+	lines := r.lineIndex()
+	if pos.Line < 1 || pos.Line > lines.count() {
 		return nil
 	}
 
 	start := max(1, pos.Line-radius)
-	end := min(len(lines), pos.Line+radius)
+	end := min(lines.count(), pos.Line+radius)
 
 	contextLines := make([]ContextLine, 0, end-start+1)
 	for i := start; i <= end; i++ {
-		if i > len(lines) {
-			break
-		}
 		contextLines = append(contextLines, ContextLine{
 			Number:  i,
-			Content: lines[i-1], // lines are 0-indexed, but line numbers are 1-indexed
+			Content: lines.line(i),
 			IsError: i == pos.Line,
 		})
 	}
@@ -249,8 +261,8 @@ func (r *ErrorReporter) buildContext(pos ast.Position, radius int) *ErrorContext
 	}
 
 	// Try to highlight the whole word/token if possible
-	if pos.Line <= len(lines) && pos.Column > 0 {
-		line := lines[pos.Line-1]
+	if pos.Column > 0 {
+		line := contextLines[pos.Line-start].Content
 		if pos.Column <= len(line) {
 			start := pos.Column - 1
 			end := pos.Column - 1
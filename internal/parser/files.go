@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+)
+
+// FileResult is the result of parsing one of the files given to ParseFiles.
+type FileResult struct {
+	Filename string
+	Workflow *ast.Workflow
+	Err      error
+	// Duration is how long the file took to parse and validate
+	Duration time.Duration
+}
+
+// ParseFiles parses and validates the workflow files concurrently, using
+// up to one worker per CPU, returning their results in the order of
+// filenames. The parser must be safe for concurrent use, as YAMLParser is.
+func ParseFiles(p Parser, filenames []string) []FileResult {
+	results := make([]FileResult, len(filenames))
+
+	workers := min(runtime.GOMAXPROCS(0), len(filenames))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				start := time.Now()
+				workflow, err := p.ParseFile(filenames[i])
+				results[i] = FileResult{Filename: filenames[i], Workflow: workflow, Err: err, Duration: time.Since(start)}
+			}
+		}()
+	}
+
+	for i := range filenames {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	var filenames []string
+	for i := range 20 {
+		filename := filepath.Join(dir, fmt.Sprintf("workflow-%d.laq.yml", i))
+		content := fmt.Sprintf(`version: "1.0"
+metadata:
+  name: workflow-%d
+workflow:
+  steps:
+    - id: hello
+      run: echo "hello %d"
+`, i, i)
+		if i == 7 {
+			content = "version: \"1.0\"\nworkflow:\n  steps: []\n"
+		}
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0o600))
+		filenames = append(filenames, filename)
+	}
+
+	yamlParser, err := NewYAMLParser()
+	require.NoError(t, err)
+
+	results := ParseFiles(yamlParser, filenames)
+	require.Len(t, results, len(filenames))
+
+	for i, result := range results {
+		assert.Equal(t, filenames[i], result.Filename)
+		if i == 7 {
+			assert.Error(t, result.Err)
+			assert.Nil(t, result.Workflow)
+			continue
+		}
+
+		require.NoError(t, result.Err)
+		assert.Equal(t, fmt.Sprintf("workflow-%d", i), result.Workflow.Metadata.Name)
+	}
+}
+
+func TestParseFiles_Empty(t *testing.T) {
+	yamlParser, err := NewYAMLParser()
+	require.NoError(t, err)
+
+	assert.Empty(t, ParseFiles(yamlParser, nil))
+}
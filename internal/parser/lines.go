@@ -0,0 +1,45 @@
+package parser
+
+import "bytes"
+
+// lineIndex finds the lines of a workflow's source without splitting the
+// whole source into strings, so that reporting errors in a large workflow
+// only copies the lines shown in the errors.
+type lineIndex struct {
+	source []byte
+	// starts holds the offset of the start of every line
+	starts []int
+}
+
+func newLineIndex(source []byte) *lineIndex {
+	starts := make([]int, 1, bytes.Count(source, []byte{'\n'})+1)
+	for offset := 0; ; {
+		i := bytes.IndexByte(source[offset:], '\n')
+		if i < 0 {
+			break
+		}
+		offset += i + 1
+		starts = append(starts, offset)
+	}
+
+	return &lineIndex{source: source, starts: starts}
+}
+
+// count returns the number of lines of the source.
+func (l *lineIndex) count() int {
+	return len(l.starts)
+}
+
+// line returns the content of a line, numbered from 1, without its newline.
+func (l *lineIndex) line(number int) string {
+	if number < 1 || number > len(l.starts) {
+		return ""
+	}
+
+	start := l.starts[number-1]
+	end := len(l.source)
+	if number < len(l.starts) {
+		end = l.starts[number] - 1
+	}
+	return string(l.source[start:end])
+}
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineIndex(t *testing.T) {
+	lines := newLineIndex([]byte("version: \"1.0\"\nworkflow:\n  steps: []\n"))
+
+	// the source ends with a newline, which starts an empty last line
+	assert.Equal(t, 4, lines.count())
+	assert.Equal(t, "version: \"1.0\"", lines.line(1))
+	assert.Equal(t, "  steps: []", lines.line(3))
+	assert.Equal(t, "", lines.line(4))
+	assert.Equal(t, "", lines.line(0))
+	assert.Equal(t, "", lines.line(5))
+
+	lines = newLineIndex(nil)
+	assert.Equal(t, 1, lines.count())
+	assert.Equal(t, "", lines.line(1))
+}
+
+// largeInvalidWorkflow returns a workflow with many steps, every tenth of
+// which references an undefined agent.
+func largeInvalidWorkflow(steps int) []byte {
+	var b strings.Builder
+	b.WriteString("version: \"1.0\"\nmetadata:\n  name: large\nagents:\n  assistant:\n    provider: anthropic\n    model: claude-sonnet-4\nworkflow:\n  steps:\n")
+	for i := range steps {
+		agent := "assistant"
+		if i%10 == 0 {
+			agent = "missing"
+		}
+		fmt.Fprintf(&b, "    - id: step_%d\n      agent: %s\n      prompt: \"Summarise part %d\"\n", i, agent, i)
+	}
+	return []byte(b.String())
+}
+
+func TestParseBytes_LargeWorkflowErrors(t *testing.T) {
+	yamlParser, err := NewYAMLParser()
+	require.NoError(t, err)
+
+	_, err = yamlParser.ParseBytes(largeInvalidWorkflow(1000), "large.laq.yml")
+	require.Error(t, err)
+
+	multiErr, ok := err.(*MultiErrorEnhanced)
+	require.True(t, ok)
+	require.NotEmpty(t, multiErr.Errors)
+
+	// the first invalid step is on line 11
+	first := multiErr.Errors[0]
+	assert.Equal(t, 11, first.Position.Line)
+	require.NotNil(t, first.Context)
+	assert.Equal(t, "      agent: missing", first.Context.Lines[2].Content)
+}
+
+func BenchmarkParseBytes_LargeWorkflowErrors(b *testing.B) {
+	yamlParser, err := NewYAMLParser()
+	require.NoError(b, err)
+	source := largeInvalidWorkflow(5000)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := yamlParser.ParseBytes(source, "large.laq.yml"); err == nil {
+			b.Fatal("expected validation errors")
+		}
+	}
+}
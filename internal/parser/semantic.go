@@ -99,6 +99,9 @@ func (sv *SemanticValidator) buildValidationContext(ctx *validationContext) {
 				}
 			}
 			ctx.variables[fmt.Sprintf("steps.%s.output", step.ID)] = true
+			for _, telemetry := range []string{"duration_ms", "tokens", "cost", "status", "error"} {
+				ctx.variables[fmt.Sprintf("steps.%s.%s", step.ID, telemetry)] = true
+			}
 
 			commonOutputs := []string{"result", "data", "content", "findings", "summary", "analysis"}
 			for _, output := range commonOutputs {
@@ -125,6 +128,14 @@ func (sv *SemanticValidator) validateStepDependencies(ctx *validationContext, re
 
 	for _, step := range steps {
 		deps := sv.extractStepDependencies(step)
+
+		// steps using a prompt of the library depend on what its versions
+		// reference
+		if _, ok := ctx.workflow.StepPromptRef(step); ok {
+			for _, template := range ctx.workflow.StepPromptTemplates(step) {
+				deps = append(deps, sv.extractVariableReferences(template)...)
+			}
+		}
 		dependencies[step.ID] = deps
 	}
 
@@ -159,6 +170,32 @@ func (sv *SemanticValidator) validateStepDependencies(ctx *validationContext, re
 			}
 		}
 	}
+
+	for i, step := range steps {
+		if step.Parallel != nil {
+			sv.validateParallelDependencies(fmt.Sprintf("workflow.steps[%d]", i), step.Parallel, result)
+		}
+	}
+}
+
+// validateParallelDependencies checks that the steps of a parallel step don't
+// reference each other, as they run at the same time
+func (sv *SemanticValidator) validateParallelDependencies(path string, parallel *ast.ParallelStep, result *ast.ValidationResult) {
+	siblings := make(map[string]bool, len(parallel.Steps))
+	for _, subStep := range parallel.Steps {
+		siblings[subStep.ID] = true
+	}
+
+	for i, subStep := range parallel.Steps {
+		for _, dep := range sv.extractStepDependencies(subStep) {
+			if dep != subStep.ID && siblings[dep] {
+				result.AddError(
+					fmt.Sprintf("%s.parallel.steps[%d]", path, i),
+					fmt.Sprintf("step '%s' references step '%s' which runs in parallel with it", subStep.ID, dep),
+				)
+			}
+		}
+	}
 }
 
 // extractStepDependencies extracts step IDs that this step depends on
@@ -177,6 +214,10 @@ func (sv *SemanticValidator) extractStepDependencies(step *ast.Step) []string {
 		deps = append(deps, sv.extractVariableReferences(step.SkipIf)...)
 	}
 
+	if step.Retry != nil && step.Retry.RetryIf != "" {
+		deps = append(deps, sv.extractVariableReferences(step.Retry.RetryIf)...)
+	}
+
 	if step.With != nil {
 		for _, value := range step.With {
 			if str, ok := value.(string); ok {
@@ -185,6 +226,149 @@ func (sv *SemanticValidator) extractStepDependencies(step *ast.Step) []string {
 		}
 	}
 
+	if step.Export != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Export.From)...)
+		deps = append(deps, sv.extractVariableReferences(step.Export.Path)...)
+	}
+
+	if step.Ingest != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Ingest.From)...)
+	}
+
+	if step.Extract != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Extract.From)...)
+		deps = append(deps, sv.extractVariableReferences(step.Extract.Instructions)...)
+	}
+
+	if step.Classify != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Classify.From)...)
+		deps = append(deps, sv.extractVariableReferences(step.Classify.Instructions)...)
+		if examples, ok := step.Classify.Examples.(string); ok {
+			deps = append(deps, sv.extractVariableReferences(examples)...)
+		}
+	}
+
+	if step.Summarize != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Summarize.From)...)
+		deps = append(deps, sv.extractVariableReferences(step.Summarize.Instructions)...)
+	}
+
+	if step.Translate != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Translate.From)...)
+		deps = append(deps, sv.extractVariableReferences(step.Translate.To)...)
+		deps = append(deps, sv.extractVariableReferences(step.Translate.Source)...)
+		deps = append(deps, sv.extractVariableReferences(step.Translate.Instructions)...)
+		if glossary, ok := step.Translate.Glossary.(string); ok {
+			deps = append(deps, sv.extractVariableReferences(glossary)...)
+		}
+	}
+
+	if step.Diff != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Diff.From)...)
+		deps = append(deps, sv.extractVariableReferences(step.Diff.To)...)
+		deps = append(deps, sv.extractVariableReferences(step.Diff.Path)...)
+	}
+
+	if step.PII != nil {
+		deps = append(deps, sv.extractVariableReferences(step.PII.From)...)
+	}
+
+	if step.Dedupe != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Dedupe.Key)...)
+	}
+
+	if step.HTTP != nil {
+		deps = append(deps, sv.extractVariableReferences(step.HTTP.URL)...)
+		for _, value := range step.HTTP.Headers {
+			deps = append(deps, sv.extractVariableReferences(value)...)
+		}
+		if body, ok := step.HTTP.Body.(string); ok {
+			deps = append(deps, sv.extractVariableReferences(body)...)
+		}
+		if step.HTTP.Auth != nil {
+			deps = append(deps, sv.extractVariableReferences(step.HTTP.Auth.Token)...)
+			deps = append(deps, sv.extractVariableReferences(step.HTTP.Auth.Username)...)
+			deps = append(deps, sv.extractVariableReferences(step.HTTP.Auth.Password)...)
+		}
+	}
+
+	if step.Issue != nil {
+		for _, field := range []string{step.Issue.URL, step.Issue.Token, step.Issue.Email, step.Issue.Project, step.Issue.ID, step.Issue.Title, step.Issue.Description, step.Issue.Type, step.Issue.Priority, step.Issue.Assignee, step.Issue.Key} {
+			deps = append(deps, sv.extractVariableReferences(field)...)
+		}
+		for _, label := range step.Issue.Labels {
+			deps = append(deps, sv.extractVariableReferences(label)...)
+		}
+	}
+
+	for _, assertion := range step.Assert {
+		deps = append(deps, sv.extractVariableReferences(assertion.That)...)
+	}
+
+	if step.KV != nil {
+		deps = append(deps, sv.extractVariableReferences(step.KV.Key)...)
+		if value, ok := step.KV.Value.(string); ok {
+			deps = append(deps, sv.extractVariableReferences(value)...)
+		}
+	}
+
+	if step.Race != nil {
+		// steps of a branch may reference earlier steps of the branch
+		for _, branch := range step.Race.Branches {
+			local := make(map[string]bool, len(branch.Steps))
+			for _, subStep := range branch.Steps {
+				local[subStep.ID] = true
+			}
+
+			for _, subStep := range branch.Steps {
+				for _, dep := range sv.extractStepDependencies(subStep) {
+					if !local[dep] {
+						deps = append(deps, dep)
+					}
+				}
+			}
+		}
+	}
+
+	if step.Parallel != nil {
+		// references between the steps of the group are reported by
+		// validateParallelDependencies
+		siblings := make(map[string]bool, len(step.Parallel.Steps))
+		for _, subStep := range step.Parallel.Steps {
+			siblings[subStep.ID] = true
+		}
+
+		for _, subStep := range step.Parallel.Steps {
+			for _, dep := range sv.extractStepDependencies(subStep) {
+				if !siblings[dep] {
+					deps = append(deps, dep)
+				}
+			}
+		}
+	}
+
+	if len(step.OnFailure) > 0 {
+		// handlers may reference the failed step and earlier handlers
+		local := make(map[string]bool, len(step.OnFailure))
+		for _, subStep := range step.OnFailure {
+			local[subStep.ID] = true
+		}
+
+		for _, subStep := range step.OnFailure {
+			for _, dep := range sv.extractStepDependencies(subStep) {
+				if !local[dep] {
+					deps = append(deps, dep)
+				}
+			}
+		}
+	}
+
+	if step.Ensemble != nil {
+		deps = append(deps, sv.extractVariableReferences(step.Ensemble.Prompt)...)
+		deps = append(deps, sv.extractVariableReferences(step.Ensemble.Instructions)...)
+		deps = append(deps, sv.extractVariableReferences(step.Ensemble.Best)...)
+	}
+
 	if step.Updates != nil {
 		for _, value := range step.Updates {
 			if str, ok := value.(string); ok {
@@ -267,6 +451,10 @@ func (sv *SemanticValidator) validateControlFlow(ctx *validationContext, result
 		if step.SkipIf != "" {
 			sv.validateConditionSyntax(step.SkipIf, stepPath+".skip_if", result)
 		}
+
+		if step.Retry != nil && step.Retry.RetryIf != "" {
+			sv.validateConditionSyntax(step.Retry.RetryIf, stepPath+".retry.retry_if", result)
+		}
 	}
 }
 
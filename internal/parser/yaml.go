@@ -32,6 +32,10 @@ func WithSemanticValidator(validator *SemanticValidator) ParserOption {
 	}
 }
 
+// defaultSemanticValidator is shared by the parsers created without a
+// semantic validator, it keeps no state between the workflows it validates
+var defaultSemanticValidator = NewSemanticValidator()
+
 // NewYAMLParser creates a new YAML parser with the given options
 func NewYAMLParser(opts ...ParserOption) (*YAMLParser, error) {
 	parser := &YAMLParser{}
@@ -41,7 +45,7 @@ func NewYAMLParser(opts ...ParserOption) (*YAMLParser, error) {
 	}
 
 	if parser.semanticValidator == nil {
-		parser.semanticValidator = NewSemanticValidator()
+		parser.semanticValidator = defaultSemanticValidator
 	}
 
 	return parser, nil
@@ -54,6 +58,7 @@ func (p *YAMLParser) ParseFile(filename string) (*ast.Workflow, error) {
 	if !isValidWorkflowFile(filename) {
 		reporter.AddError(&EnhancedError{
 			ID:       "file_ext_invalid",
+			Rule:     RuleFileExtension,
 			Severity: SeverityError,
 			Title:    "Invalid file extension",
 			Message:  fmt.Sprintf("Expected .laq.yaml or .laq.yml, got %s", filepath.Ext(filename)),
@@ -72,6 +77,7 @@ func (p *YAMLParser) ParseFile(filename string) (*ast.Workflow, error) {
 	if err != nil {
 		reporter.AddError(&EnhancedError{
 			ID:       "file_read_error",
+			Rule:     RuleFileRead,
 			Severity: SeverityError,
 			Title:    "Cannot read file",
 			Message:  err.Error(),
@@ -90,6 +96,7 @@ func (p *YAMLParser) ParseFile(filename string) (*ast.Workflow, error) {
 	if len(data) > 10*1024*1024 { // 10MB limit
 		reporter.AddError(&EnhancedError{
 			ID:       "file_too_large",
+			Rule:     RuleFileSize,
 			Severity: SeverityError,
 			Title:    "File too large",
 			Message:  fmt.Sprintf("File size %d bytes exceeds maximum of 10MB", len(data)),
@@ -121,6 +128,7 @@ func (p *YAMLParser) ParseBytes(data []byte, filename string) (*ast.Workflow, er
 	if len(data) == 0 {
 		reporter.AddError(&EnhancedError{
 			ID:       "empty_file",
+			Rule:     RuleEmptyFile,
 			Severity: SeverityError,
 			Title:    "Empty workflow file",
 			Message:  "Workflow file contains no content",
@@ -173,7 +181,7 @@ func (p *YAMLParser) ParseBytes(data []byte, filename string) (*ast.Workflow, er
 	}
 
 	if p.semanticValidator != nil {
-		if err := p.validateSemanticsEnhanced(&workflow, reporter); err != nil {
+		if err := p.validateSemanticsEnhanced(&workflow, &node, reporter); err != nil {
 			return nil, err
 		}
 	}
@@ -181,25 +189,21 @@ func (p *YAMLParser) ParseBytes(data []byte, filename string) (*ast.Workflow, er
 	return &workflow, nil
 }
 
-func extractPositionFromPath(path string, source []byte) ast.Position {
+// extractPositionFromPath finds the position of a path in the source using
+// the YAML tree the source was parsed into, which preserves line numbers
+func extractPositionFromPath(path string, node *yaml.Node, lines *lineIndex) ast.Position {
 	if path == "" || path == "/" {
 		return ast.Position{Line: 1, Column: 1}
 	}
 
-	// Parse the YAML into a tree structure that preserves line numbers
-	var node yaml.Node
-	if err := yaml.Unmarshal(source, &node); err != nil {
-		return ast.Position{Line: 1, Column: 1}
-	}
-
 	// Navigate through the path to find the target node
-	pos := findNodeByPath(&node, path)
+	pos := findNodeByPath(node, path)
 	if pos.Line > 0 {
 		return pos
 	}
 
-	// Fallback to the simple method if YAML parsing fails
-	return extractPositionFromPathSimple(path, source)
+	// Fallback to the simple method if the node wasn't found
+	return extractPositionFromPathSimple(path, lines)
 }
 
 // findNodeByPath navigates through a YAML node tree using a path
@@ -270,6 +274,9 @@ func findNodeByPath(node *yaml.Node, path string) ast.Position {
 	return ast.Position{Line: 1, Column: 1}
 }
 
+// indexPattern matches the index of a list in a path, e.g. [0] in workflow.steps[0].agent
+var indexPattern = regexp.MustCompile(`\[(\d+)\]`)
+
 // parsePath converts different path formats to a uniform slice of parts
 func parsePath(path string) []string {
 	if strings.HasPrefix(path, "/") {
@@ -278,27 +285,26 @@ func parsePath(path string) []string {
 
 	// Handle dot notation with square brackets like "workflow.steps[0].agent"
 	// Replace [index] with .index format first
-	re := regexp.MustCompile(`\[(\d+)\]`)
-	normalized := re.ReplaceAllString(path, ".$1")
+	normalized := indexPattern.ReplaceAllString(path, ".$1")
 
 	// Split by dots
 	return strings.Split(normalized, ".")
 }
 
 // extractPositionFromPathSimple is the fallback simple implementation
-func extractPositionFromPathSimple(path string, source []byte) ast.Position {
+func extractPositionFromPathSimple(path string, lines *lineIndex) ast.Position {
 	if path == "" || path == "/" {
 		return ast.Position{Line: 1, Column: 1}
 	}
 
 	pathParts := strings.Split(strings.TrimPrefix(path, "/"), "/")
 
-	lines := strings.Split(string(source), "\n")
-	for lineNum, line := range lines {
+	for lineNum := 1; lineNum <= lines.count(); lineNum++ {
+		line := lines.line(lineNum)
 		for _, part := range pathParts {
 			if strings.Contains(line, part+":") {
 				return ast.Position{
-					Line:   lineNum + 1,
+					Line:   lineNum,
 					Column: strings.Index(line, part) + 1,
 				}
 			}
@@ -353,6 +359,7 @@ func (p *YAMLParser) enhanceYAMLError(err error, reporter *ErrorReporter) error
 				pos := extractPositionFromMessage(errMsg)
 				reporter.AddError(&EnhancedError{
 					ID:       generateErrorID("yaml_type", pos),
+					Rule:     RuleYAMLType,
 					Severity: SeverityError,
 					Title:    "YAML type error",
 					Message:  errMsg,
@@ -365,6 +372,7 @@ func (p *YAMLParser) enhanceYAMLError(err error, reporter *ErrorReporter) error
 		pos := extractPositionFromMessage(err.Error())
 		reporter.AddError(&EnhancedError{
 			ID:       generateErrorID("yaml_parse", pos),
+			Rule:     RuleYAMLSyntax,
 			Severity: SeverityError,
 			Title:    "YAML parsing error",
 			Message:  err.Error(),
@@ -377,7 +385,7 @@ func (p *YAMLParser) enhanceYAMLError(err error, reporter *ErrorReporter) error
 }
 
 // validateSemanticsEnhanced performs semantic validation with enhanced error reporting
-func (p *YAMLParser) validateSemanticsEnhanced(workflow *ast.Workflow, reporter *ErrorReporter) error {
+func (p *YAMLParser) validateSemanticsEnhanced(workflow *ast.Workflow, node *yaml.Node, reporter *ErrorReporter) error {
 	result := p.semanticValidator.ValidateWorkflow(workflow)
 	if result.HasErrors() {
 		for _, validationErr := range result.Errors {
@@ -393,11 +401,12 @@ func (p *YAMLParser) validateSemanticsEnhanced(workflow *ast.Workflow, reporter
 				// Value which contains all the position information, this way
 				// we can extract the position from the structured value instead
 				// of the path.
-				pos = extractPositionFromPath(path, reporter.source)
+				pos = extractPositionFromPath(path, node, reporter.lineIndex())
 			}
 
 			reporter.AddError(&EnhancedError{
 				ID:       generateErrorID("semantic", pos),
+				Rule:     RuleSemantic,
 				Severity: SeverityError,
 				Title:    "Validation error",
 				Message:  validationErr.Message,
@@ -411,3 +420,36 @@ func (p *YAMLParser) validateSemanticsEnhanced(workflow *ast.Workflow, reporter
 
 	return nil
 }
+
+// PathError is an error found at a path of a workflow, e.g.
+// workflow.steps[0].prompt, by a check run after parsing
+type PathError struct {
+	Path    string
+	Message string
+}
+
+// ReportPathErrors reports errors found at paths of a parsed workflow at
+// their positions in its source, returning nil when there are none
+func ReportPathErrors(source []byte, filename, rule, title string, pathErrors []PathError) error {
+	reporter := NewErrorReporter(source, filename)
+
+	// the workflow has been parsed already, should the source not parse
+	// positions are found by the simple method
+	var node yaml.Node
+	_ = yaml.Unmarshal(source, &node)
+
+	for _, pathErr := range pathErrors {
+		pos := extractPositionFromPath(pathErr.Path, &node, reporter.lineIndex())
+		reporter.AddError(&EnhancedError{
+			ID:       generateErrorID(rule, pos),
+			Rule:     rule,
+			Severity: SeverityError,
+			Title:    title,
+			Message:  fmt.Sprintf("%s: %s", pathErr.Path, pathErr.Message),
+			Position: pos,
+			Category: rule,
+		})
+	}
+
+	return reporter.ToError()
+}
@@ -0,0 +1,266 @@
+// Package pii detects common personally identifiable information in text,
+// such as email addresses and credit card numbers, and masks it either with
+// a placeholder or with tokens which can be turned back into the original
+// values later.
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Type is a kind of personally identifiable information.
+type Type string
+
+const (
+	Email      Type = "email"
+	Phone      Type = "phone"
+	CreditCard Type = "credit_card"
+	// NationalID is a US social security number or a UK national insurance
+	// number.
+	NationalID Type = "national_id"
+)
+
+// Types lists the supported types in the order they're detected in, text
+// matched by one type isn't matched by the types after it.
+var Types = []Type{Email, CreditCard, NationalID, Phone}
+
+var patterns = map[Type]*regexp.Regexp{
+	Email:      regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	CreditCard: regexp.MustCompile(`\d(?:[ \-]?\d){12,18}`),
+	NationalID: regexp.MustCompile(`\d{3}-\d{2}-\d{4}|[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]`),
+	Phone:      regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{1,4}\)[ .\-]?)?\d{2,4}(?:[ .\-]?\d{2,4}){0,3}[ .\-]?\d{3,4}`),
+}
+
+// Match is a value of a type found in a text.
+type Match struct {
+	Type  Type
+	Value string
+	// Start and End are the byte offsets of the value in the text.
+	Start int
+	End   int
+}
+
+// ParseTypes converts type names to types, returning every type when there
+// are no names.
+func ParseTypes(names []string) ([]Type, error) {
+	if len(names) == 0 {
+		return Types, nil
+	}
+
+	types := make([]Type, 0, len(names))
+	for _, name := range names {
+		t := Type(strings.TrimSpace(name))
+		if _, ok := patterns[t]; !ok {
+			return nil, fmt.Errorf("unknown PII type %q, use %s", name, joinTypes(Types))
+		}
+		types = append(types, t)
+	}
+
+	return types, nil
+}
+
+// Detect returns the values of the types found in the text in the order
+// they appear, looking for every type when none are given.
+func Detect(text string, types ...Type) []Match {
+	if len(types) == 0 {
+		types = Types
+	}
+
+	var matches []Match
+	taken := func(start, end int) bool {
+		for _, m := range matches {
+			if start < m.End && end > m.Start {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, t := range Types {
+		if !slices.Contains(types, t) {
+			continue
+		}
+
+		for _, loc := range patterns[t].FindAllStringIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			value := text[start:end]
+			if !bounded(text, start, end) || taken(start, end) || !valid(t, value) {
+				continue
+			}
+			matches = append(matches, Match{Type: t, Value: value, Start: start, End: end})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+
+	return matches
+}
+
+// Mask replaces the values of the types found in the text with a
+// placeholder naming their type, e.g. [EMAIL].
+func Mask(text string, types ...Type) string {
+	return replace(text, Detect(text, types...), func(m Match) string {
+		return "[" + label(m.Type) + "]"
+	})
+}
+
+// Vault replaces values with tokens, e.g. [EMAIL_1], and remembers them so
+// that text containing the tokens can be restored. The same value is always
+// given the same token. It's safe for concurrent use.
+type Vault struct {
+	mu     sync.Mutex
+	values map[string]string
+	tokens map[string]string
+	counts map[Type]int
+}
+
+// NewVault creates an empty vault.
+func NewVault() *Vault {
+	return &Vault{
+		values: make(map[string]string),
+		tokens: make(map[string]string),
+		counts: make(map[Type]int),
+	}
+}
+
+// Tokenize replaces the values of the types found in the text with tokens.
+func (v *Vault) Tokenize(text string, types ...Type) string {
+	matches := Detect(text, types...)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return replace(text, matches, func(m Match) string {
+		key := string(m.Type) + ":" + m.Value
+		if token, ok := v.tokens[key]; ok {
+			return token
+		}
+
+		v.counts[m.Type]++
+		token := fmt.Sprintf("[%s_%d]", label(m.Type), v.counts[m.Type])
+		v.tokens[key] = token
+		v.values[token] = m.Value
+		return token
+	})
+}
+
+// Restore replaces the tokens of the vault found in the text with the values
+// they stand for.
+func (v *Vault) Restore(text string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if len(v.values) == 0 {
+		return text
+	}
+
+	pairs := make([]string, 0, len(v.values)*2)
+	for token, value := range v.values {
+		pairs = append(pairs, token, value)
+	}
+
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// replace replaces the matches, which must be in order, in the text.
+func replace(text string, matches []Match, replacement func(Match) string) string {
+	if len(matches) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(text[last:m.Start])
+		sb.WriteString(replacement(m))
+		last = m.End
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String()
+}
+
+// bounded reports whether the match isn't part of a longer word or number.
+func bounded(text string, start, end int) bool {
+	isWord := func(b byte) bool {
+		return b == '_' || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+	}
+
+	if start > 0 && isWord(text[start-1]) {
+		return false
+	}
+	if end < len(text) && isWord(text[end]) {
+		return false
+	}
+
+	return true
+}
+
+// valid checks what the patterns can't, such as the checksum of card
+// numbers.
+func valid(t Type, value string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, value)
+
+	switch t {
+	case CreditCard:
+		return len(digits) >= 13 && len(digits) <= 19 && luhn(digits)
+	case NationalID:
+		if strings.Contains(value, "-") {
+			// social security numbers are never issued with these parts
+			area, group, serial := digits[:3], digits[3:5], digits[5:]
+			return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+		}
+		return true
+	case Phone:
+		// numbers without a country code or separators are more often ids
+		// or amounts than phone numbers
+		separated := strings.ContainsAny(value, " .-()") || strings.HasPrefix(value, "+")
+		return separated && len(digits) >= 7 && len(digits) <= 15
+	default:
+		return true
+	}
+}
+
+// luhn validates the check digit of a card number.
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func label(t Type) string {
+	return strings.ToUpper(string(t))
+}
+
+func joinTypes(types []Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,102 @@
+package pii
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Match
+	}{
+		{
+			name: "email",
+			text: "Contact jane.doe+work@example.co.uk today",
+			want: []Match{{Type: Email, Value: "jane.doe+work@example.co.uk", Start: 8, End: 35}},
+		},
+		{
+			name: "credit card",
+			text: "Card 4111 1111 1111 1111 on file",
+			want: []Match{{Type: CreditCard, Value: "4111 1111 1111 1111", Start: 5, End: 24}},
+		},
+		{
+			name: "card number failing the checksum",
+			text: "Order 4111 1111 1111 1112",
+		},
+		{
+			name: "social security number",
+			text: "SSN: 123-45-6789.",
+			want: []Match{{Type: NationalID, Value: "123-45-6789", Start: 5, End: 16}},
+		},
+		{
+			name: "national insurance number",
+			text: "NI AB 12 34 56 C",
+			want: []Match{{Type: NationalID, Value: "AB 12 34 56 C", Start: 3, End: 16}},
+		},
+		{
+			name: "phone numbers",
+			text: "Call (555) 123-4567 or +44 20 7946 0958",
+			want: []Match{
+				{Type: Phone, Value: "(555) 123-4567", Start: 5, End: 19},
+				{Type: Phone, Value: "+44 20 7946 0958", Start: 23, End: 39},
+			},
+		},
+		{
+			name: "numbers which aren't PII",
+			text: "Invoice 12345678 dated 2024-01-15 for 1,500 units",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Detect(tt.text))
+		})
+	}
+}
+
+func TestDetect_Types(t *testing.T) {
+	text := "jane@example.com, 555-123-4567"
+
+	matches := Detect(text, Phone)
+	require.Len(t, matches, 1)
+	assert.Equal(t, Phone, matches[0].Type)
+
+	// social security numbers are never issued with an area of 000
+	assert.Empty(t, Detect("ref 000-12-3456", NationalID))
+}
+
+func TestMask(t *testing.T) {
+	text := "Email jane@example.com or call 555-123-4567, SSN 123-45-6789"
+
+	assert.Equal(t, "Email [EMAIL] or call [PHONE], SSN [NATIONAL_ID]", Mask(text))
+	assert.Equal(t, "Email [EMAIL] or call 555-123-4567, SSN 123-45-6789", Mask(text, Email))
+}
+
+func TestVault(t *testing.T) {
+	vault := NewVault()
+
+	masked := vault.Tokenize("From jane@example.com to bob@example.com, cc jane@example.com")
+	assert.Equal(t, "From [EMAIL_1] to [EMAIL_2], cc [EMAIL_1]", masked)
+
+	// tokens stay the same across texts
+	assert.Equal(t, "Reply to [EMAIL_2]", vault.Tokenize("Reply to bob@example.com"))
+
+	assert.Equal(t, "Dear jane@example.com, [EMAIL_3] is unknown", vault.Restore("Dear [EMAIL_1], [EMAIL_3] is unknown"))
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Types, types)
+
+	types, err = ParseTypes([]string{"email", " phone"})
+	require.NoError(t, err)
+	assert.Equal(t, []Type{Email, Phone}, types)
+
+	_, err = ParseTypes([]string{"address"})
+	assert.ErrorContains(t, err, `unknown PII type "address"`)
+}
@@ -2,6 +2,8 @@ package anthropic
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -195,7 +197,10 @@ func NewProvider(yamlConfig map[string]interface{}) (*Provider, error) {
 	if config.APIKey == "" && config.Platform == "" {
 		config.APIKey = GetAnthropicAPIKeyFromEnv()
 		if config.APIKey == "" {
-			return nil, fmt.Errorf("please set an ANTHROPIC_API_KEY environment variable")
+			config.APIKey = provider.APIKeyFromKeychain("anthropic")
+		}
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("please set an ANTHROPIC_API_KEY environment variable or run laq auth login anthropic")
 		}
 		options = append(options, option.WithAPIKey(config.APIKey))
 	}
@@ -227,9 +232,14 @@ func (p *Provider) Generate(gtx provider.GenerateContext, request *provider.Requ
 	}
 
 	// Make the API call with retries
-	response, err := p.client.Messages.New(gtx.Context, anthropicReq, option.WithRequestTimeout(time.Minute*10))
+	var response *anthropic.Message
+	if gtx.Streams(progressChan) {
+		response, err = p.stream(gtx, anthropicReq, progressChan)
+	} else {
+		response, err = p.client.Messages.New(gtx.Context, anthropicReq, option.WithRequestTimeout(time.Minute*10))
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("anthropic API call failed: %w", err)
+		return nil, nil, fmt.Errorf("anthropic API call failed: %w", p.apiError(err))
 	}
 
 	// Convert usage information
@@ -237,6 +247,7 @@ func (p *Provider) Generate(gtx provider.GenerateContext, request *provider.Requ
 		PromptTokens:     int(response.Usage.InputTokens),
 		CompletionTokens: int(response.Usage.OutputTokens),
 		TotalTokens:      int(response.Usage.InputTokens + response.Usage.OutputTokens),
+		Model:            string(response.Model),
 	}
 
 	var truncated bool
@@ -269,6 +280,56 @@ func (p *Provider) Generate(gtx provider.GenerateContext, request *provider.Requ
 	return content, tokenUsage, nil
 }
 
+// stream sends the request with the streaming API, sending each chunk of
+// text as a token delta event, and returns the message accumulated from the
+// stream.
+func (p *Provider) stream(gtx provider.GenerateContext, params anthropic.MessageNewParams, progressChan chan<- pkgEvents.ExecutionEvent) (*anthropic.Message, error) {
+	stream := p.client.Messages.NewStreaming(gtx.Context, params, option.WithRequestTimeout(time.Minute*10))
+	defer stream.Close()
+
+	message := &anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return nil, err
+		}
+
+		if delta, ok := event.AsAny().(anthropic.ContentBlockDeltaEvent); ok && delta.Delta.Text != "" {
+			progressChan <- gtx.TokenDelta(delta.Delta.Text)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// apiError normalises the error of a request the API responded to with an
+// error status into a *provider.Error, other errors are returned as is.
+func (p *Provider) apiError(err error) error {
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	var body struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal([]byte(apiErr.RawJSON()), &body)
+
+	var header http.Header
+	if apiErr.Response != nil {
+		header = apiErr.Response.Header
+	}
+
+	return provider.NewAPIError(p.GetName(), apiErr.StatusCode, body.Error.Type, body.Error.Message, header, err)
+}
+
 func (p *Provider) anthropicContentToModelMessage(contentBlock anthropic.ContentBlockUnion) *provider.Message {
 	switch contentBlock.AsAny().(type) {
 	case anthropic.TextBlock:
@@ -304,6 +365,14 @@ func (p *Provider) GetName() string {
 	return p.name
 }
 
+// ToolConstraints returns the limits Anthropic puts on the tools of a request
+func (p *Provider) ToolConstraints() provider.ToolConstraints {
+	return provider.ToolConstraints{
+		NamePattern: provider.ToolNamePattern,
+		NameRule:    provider.ToolNameRule,
+	}
+}
+
 var modelSuffix = regexp.MustCompile(`-\d{8}$`)
 
 // ModelAlias returns the actual model name if the model is an alias
@@ -491,6 +560,23 @@ func (p *Provider) buildAnthropicRequest(request *provider.Request) (anthropic.M
 			},
 		}
 	}
+
+	// structured output is implemented as a tool the model is forced to call,
+	// the tool input is the response object
+	if request.ResponseSchema != nil {
+		tools = append(tools, anthropic.ToolUnionParam{
+			OfTool: &anthropic.ToolParam{
+				Name:        request.ResponseSchema.Name,
+				Description: anthropic.String(request.ResponseSchema.Description),
+				InputSchema: anthropic.ToolInputSchemaParam{
+					Type:       "object",
+					Properties: request.ResponseSchema.Schema.Properties,
+					Required:   request.ResponseSchema.Schema.Required,
+				},
+			},
+		})
+	}
+
 	mp := anthropic.MessageNewParams{
 		StopSequences: request.Stop,
 		MaxTokens:     int64(maxTokens),
@@ -505,6 +591,10 @@ func (p *Provider) buildAnthropicRequest(request *provider.Request) (anthropic.M
 		mp.System = []anthropic.TextBlockParam{{Text: request.SystemPrompt}}
 	}
 
+	if request.ResponseSchema != nil {
+		mp.ToolChoice = anthropic.ToolChoiceParamOfTool(request.ResponseSchema.Name)
+	}
+
 	return mp, nil
 }
 
@@ -530,15 +620,16 @@ func (p *Provider) convertContentToAnthropicContent(content []provider.ContentBl
 	return anthropicContent
 }
 
-func GetAnthropicAPIKeyFromEnv() string {
-	// Try common environment variable names
-	envVars := []string{
-		"ANTHROPIC_API_KEY",
-		"CLAUDE_API_KEY",
-		"ANTHROPIC_KEY",
-	}
+// APIKeyEnvVars lists the environment variables the API key is read from,
+// in order of precedence.
+var APIKeyEnvVars = []string{
+	"ANTHROPIC_API_KEY",
+	"CLAUDE_API_KEY",
+	"ANTHROPIC_KEY",
+}
 
-	for _, envVar := range envVars {
+func GetAnthropicAPIKeyFromEnv() string {
+	for _, envVar := range APIKeyEnvVars {
 		if key := strings.TrimSpace(getEnvVar(envVar)); key != "" {
 			return key
 		}
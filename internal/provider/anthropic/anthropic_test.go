@@ -3,6 +3,8 @@ package anthropic
 import (
 	"testing"
 
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -248,3 +250,27 @@ func TestModelSuffixRegex(t *testing.T) {
 		})
 	}
 }
+
+func TestProvider_BuildAnthropicRequest_ResponseSchema(t *testing.T) {
+	p := &Provider{name: "anthropic"}
+
+	params, err := p.buildAnthropicRequest(&provider.Request{
+		Model:    "claude-sonnet-4",
+		Messages: []provider.Message{{Role: "user", Content: []provider.ContentBlockParamUnion{provider.NewTextBlock("hi")}}},
+		ResponseSchema: &provider.ResponseSchema{
+			Name: "extract_invoice",
+			Schema: schema.JSON{
+				Type:       "object",
+				Properties: map[string]schema.JSON{"total": {Type: "number"}},
+				Required:   []string{"total"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, params.Tools, 1)
+	assert.Equal(t, "extract_invoice", params.Tools[0].OfTool.Name)
+	assert.Equal(t, []string{"total"}, params.Tools[0].OfTool.InputSchema.Required)
+	require.NotNil(t, params.ToolChoice.OfTool)
+	assert.Equal(t, "extract_invoice", params.ToolChoice.OfTool.Name)
+}
@@ -0,0 +1,301 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// States of a provider's circuit breaker.
+const (
+	// BreakerClosed lets requests through, counting consecutive failures.
+	BreakerClosed = "closed"
+	// BreakerOpen fails requests fast until its cooldown is over.
+	BreakerOpen = "open"
+	// BreakerHalfOpen lets a single probe request through once the cooldown
+	// is over, closing the breaker if it succeeds and opening it again if it
+	// fails.
+	BreakerHalfOpen = "half-open"
+)
+
+const (
+	// DefaultBreakerFailures is the number of consecutive failed requests
+	// which opens the breaker of a provider.
+	DefaultBreakerFailures = 5
+	// DefaultBreakerCooldown is how long the breaker of a provider stays
+	// open before a probe request is let through.
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// BreakerConfig configures the circuit breakers of providers, zero values
+// use the defaults.
+type BreakerConfig struct {
+	// Failures is the number of consecutive failed requests which opens the
+	// breaker of a provider.
+	Failures int `yaml:"failures" mapstructure:"failures"`
+	// Cooldown is how long the breaker stays open before it's probed.
+	Cooldown time.Duration `yaml:"cooldown" mapstructure:"cooldown"`
+}
+
+// CircuitOpenError is returned for requests to a provider whose circuit
+// breaker is open.
+type CircuitOpenError struct {
+	Provider string
+	Until    time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("provider %s is unavailable after repeated failures, its circuit breaker is open until %s", e.Provider, e.Until.Format(time.TimeOnly))
+}
+
+// BreakerStatus is the state of the circuit breaker of a provider.
+type BreakerStatus struct {
+	Provider  string     `json:"provider" yaml:"provider"`
+	State     string     `json:"state" yaml:"state"`
+	Failures  int        `json:"failures,omitempty" yaml:"failures,omitempty"`
+	OpenUntil *time.Time `json:"open_until,omitempty" yaml:"open_until,omitempty"`
+}
+
+// breaker is the circuit breaker of a single provider.
+type breaker struct {
+	Failures  int       `json:"failures"`
+	OpenUntil time.Time `json:"open_until"`
+
+	// probing is set while the probe request of a half-open breaker is
+	// in flight
+	probing bool
+}
+
+func (b *breaker) state(now time.Time) string {
+	switch {
+	case b.OpenUntil.IsZero():
+		return BreakerClosed
+	case now.Before(b.OpenUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+// Breakers holds the circuit breakers of providers, keyed by provider name.
+// Breakers which open are saved to a file so that other processes, such as
+// later runs and laq auth status, see them until they close.
+type Breakers struct {
+	mu       sync.Mutex
+	config   BreakerConfig
+	file     string
+	loaded   bool
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+// DefaultBreakers are the circuit breakers shared by the runs of the process,
+// saved in the lacquer cache directory.
+var DefaultBreakers = NewBreakers(filepath.Join(utils.LacquerCacheDir, "breakers.json"))
+
+// NewBreakers creates circuit breakers saved to file, or only kept in memory
+// when file is empty.
+func NewBreakers(file string) *Breakers {
+	return &Breakers{
+		file:     file,
+		breakers: make(map[string]*breaker),
+		now:      time.Now,
+	}
+}
+
+// Configure sets the failure threshold and cooldown of the breakers.
+func (b *Breakers) Configure(config BreakerConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.config = config
+}
+
+// Allow returns a *CircuitOpenError when requests to the provider must fail
+// fast. Once the cooldown of an open breaker is over a single probe request
+// is allowed, the outcome of which must be recorded with Record.
+func (b *Breakers) Allow(provider string) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.load()
+
+	br, ok := b.breakers[provider]
+	if !ok {
+		return nil
+	}
+
+	switch br.state(b.now()) {
+	case BreakerOpen:
+		return &CircuitOpenError{Provider: provider, Until: br.OpenUntil}
+	case BreakerHalfOpen:
+		if br.probing {
+			return &CircuitOpenError{Provider: provider, Until: b.now().Add(b.cooldown())}
+		}
+		br.probing = true
+	}
+
+	return nil
+}
+
+// Record records the outcome of a request to the provider, err is nil when
+// it succeeded. Cancelled requests, and requests which failed because of
+// the request itself such as ones too long for the model's context, say
+// nothing about the provider and are only released.
+func (b *Breakers) Record(provider string, err error) {
+	if b == nil {
+		return
+	}
+
+	released := errors.Is(err, context.Canceled) || (err != nil && !ErrorCategoryOf(err).ProviderFault())
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.load()
+
+	br, ok := b.breakers[provider]
+	if !ok {
+		if err == nil || released {
+			return
+		}
+		br = &breaker{}
+		b.breakers[provider] = br
+	}
+
+	state := br.state(b.now())
+	probe := br.probing
+	br.probing = false
+
+	switch {
+	case released:
+		return
+	case err == nil:
+		delete(b.breakers, provider)
+		if state != BreakerClosed {
+			log.Info().Str("provider", provider).Msg("Provider recovered, closed its circuit breaker")
+			b.save()
+		}
+	case state == BreakerHalfOpen && probe:
+		br.OpenUntil = b.now().Add(b.cooldown())
+		log.Warn().Err(err).Str("provider", provider).Time("until", br.OpenUntil).Msg("Provider probe failed, reopened its circuit breaker")
+		b.save()
+	case state == BreakerClosed:
+		br.Failures++
+		if br.Failures >= b.failures() {
+			br.OpenUntil = b.now().Add(b.cooldown())
+			log.Warn().Err(err).Str("provider", provider).Int("failures", br.Failures).Time("until", br.OpenUntil).Msg("Provider failed repeatedly, opened its circuit breaker")
+			b.save()
+		}
+	}
+}
+
+// Statuses returns the state of every breaker which isn't closed or has
+// counted failures, ordered by provider.
+func (b *Breakers) Statuses() []BreakerStatus {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.load()
+
+	statuses := make([]BreakerStatus, 0, len(b.breakers))
+	for provider, br := range b.breakers {
+		status := BreakerStatus{Provider: provider, State: br.state(b.now()), Failures: br.Failures}
+		if !br.OpenUntil.IsZero() {
+			until := br.OpenUntil
+			status.OpenUntil = &until
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Provider < statuses[j].Provider })
+	return statuses
+}
+
+// Status returns the state of the breaker of the provider.
+func (b *Breakers) Status(provider string) BreakerStatus {
+	for _, status := range b.Statuses() {
+		if status.Provider == provider {
+			return status
+		}
+	}
+	return BreakerStatus{Provider: provider, State: BreakerClosed}
+}
+
+func (b *Breakers) failures() int {
+	if b.config.Failures > 0 {
+		return b.config.Failures
+	}
+	return DefaultBreakerFailures
+}
+
+func (b *Breakers) cooldown() time.Duration {
+	if b.config.Cooldown > 0 {
+		return b.config.Cooldown
+	}
+	return DefaultBreakerCooldown
+}
+
+// load reads the breakers opened by other processes the first time the
+// breakers are used.
+func (b *Breakers) load() {
+	if b.loaded || b.file == "" {
+		return
+	}
+	b.loaded = true
+
+	data, err := os.ReadFile(b.file)
+	if err != nil {
+		return
+	}
+
+	var saved map[string]*breaker
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Debug().Err(err).Str("file", b.file).Msg("Ignoring unreadable circuit breakers")
+		return
+	}
+
+	for provider, br := range saved {
+		if _, ok := b.breakers[provider]; !ok && br != nil && !br.OpenUntil.IsZero() {
+			b.breakers[provider] = br
+		}
+	}
+}
+
+// save writes the breakers which aren't closed, so that other processes
+// fail fast too. Failing to save only means they don't.
+func (b *Breakers) save() {
+	if b.file == "" {
+		return
+	}
+
+	opened := make(map[string]*breaker)
+	for provider, br := range b.breakers {
+		if !br.OpenUntil.IsZero() {
+			opened[provider] = br
+		}
+	}
+
+	data, err := json.Marshal(opened)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(b.file), 0o700); err == nil {
+			err = os.WriteFile(b.file, data, 0o600)
+		}
+	}
+	if err != nil {
+		log.Debug().Err(err).Str("file", b.file).Msg("Failed to save circuit breakers")
+	}
+}
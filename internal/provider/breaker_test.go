@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBreakers(file string, now *time.Time) *Breakers {
+	breakers := NewBreakers(file)
+	breakers.Configure(BreakerConfig{Failures: 3, Cooldown: time.Minute})
+	breakers.now = func() time.Time { return *now }
+	return breakers
+}
+
+func TestBreakers(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	breakers := newTestBreakers("", &now)
+	overloaded := errors.New("529 overloaded")
+
+	// failures only open the breaker when they are consecutive
+	breakers.Record("anthropic", overloaded)
+	breakers.Record("anthropic", overloaded)
+	breakers.Record("anthropic", nil)
+	breakers.Record("anthropic", overloaded)
+	breakers.Record("anthropic", context.Canceled)
+	breakers.Record("anthropic", NewAPIError("anthropic", 400, "", "prompt is too long", nil, nil))
+	breakers.Record("anthropic", overloaded)
+	require.NoError(t, breakers.Allow("anthropic"))
+	assert.Equal(t, BreakerStatus{Provider: "anthropic", State: BreakerClosed, Failures: 2}, breakers.Status("anthropic"))
+
+	breakers.Record("anthropic", overloaded)
+	err := breakers.Allow("anthropic")
+	var open *CircuitOpenError
+	require.ErrorAs(t, err, &open)
+	assert.Equal(t, "anthropic", open.Provider)
+	assert.Equal(t, now.Add(time.Minute), open.Until)
+	assert.Equal(t, BreakerOpen, breakers.Status("anthropic").State)
+
+	// other providers are unaffected
+	assert.NoError(t, breakers.Allow("openai"))
+
+	// once the cooldown is over a single probe is let through
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, breakers.Status("anthropic").State)
+	require.NoError(t, breakers.Allow("anthropic"))
+	assert.Error(t, breakers.Allow("anthropic"))
+
+	// a failed probe opens the breaker again
+	breakers.Record("anthropic", overloaded)
+	assert.Equal(t, BreakerOpen, breakers.Status("anthropic").State)
+
+	// and a successful one closes it
+	now = now.Add(time.Minute)
+	require.NoError(t, breakers.Allow("anthropic"))
+	breakers.Record("anthropic", nil)
+	assert.Equal(t, BreakerStatus{Provider: "anthropic", State: BreakerClosed}, breakers.Status("anthropic"))
+	assert.Empty(t, breakers.Statuses())
+}
+
+func TestBreakers_CancelledProbe(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	breakers := newTestBreakers("", &now)
+
+	for range 3 {
+		breakers.Record("openai", errors.New("timeout"))
+	}
+	now = now.Add(time.Minute)
+
+	// a cancelled probe releases the breaker for the next one
+	require.NoError(t, breakers.Allow("openai"))
+	breakers.Record("openai", context.Canceled)
+	assert.NoError(t, breakers.Allow("openai"))
+}
+
+func TestBreakers_Saved(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	file := filepath.Join(t.TempDir(), "breakers.json")
+
+	breakers := newTestBreakers(file, &now)
+	for range 3 {
+		breakers.Record("anthropic", errors.New("529 overloaded"))
+	}
+
+	// other processes fail fast too
+	other := newTestBreakers(file, &now)
+	var open *CircuitOpenError
+	require.ErrorAs(t, other.Allow("anthropic"), &open)
+	assert.Equal(t, []BreakerStatus{{Provider: "anthropic", State: BreakerOpen, Failures: 3, OpenUntil: &open.Until}}, other.Statuses())
+
+	// until the breaker closes
+	now = now.Add(time.Minute)
+	require.NoError(t, breakers.Allow("anthropic"))
+	breakers.Record("anthropic", nil)
+
+	assert.Empty(t, newTestBreakers(file, &now).Statuses())
+}
+
+func TestBreakers_Nil(t *testing.T) {
+	var breakers *Breakers
+	assert.NoError(t, breakers.Allow("anthropic"))
+	breakers.Record("anthropic", errors.New("failed"))
+	assert.Empty(t, breakers.Statuses())
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/lacquerai/lacquer/internal/execcontext"
 	"github.com/lacquerai/lacquer/internal/provider"
 	"github.com/lacquerai/lacquer/internal/style"
+	"github.com/lacquerai/lacquer/internal/utils"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 	"github.com/rs/zerolog/log"
 )
@@ -195,8 +196,9 @@ func (p *ClaudeCodeProvider) Generate(ctx provider.GenerateContext, request *pro
 
 	return []provider.Message{
 		{
-			Role:    "assistant",
-			Content: []provider.ContentBlockParamUnion{provider.NewTextBlock(content)},
+			Role:      "assistant",
+			Content:   []provider.ContentBlockParamUnion{provider.NewTextBlock(content)},
+			SessionID: response.SessionID,
 		},
 	}, nil, nil
 }
@@ -253,7 +255,8 @@ type ClaudeCodeSession struct {
 func (p *ClaudeCodeProvider) execute(ctx provider.GenerateContext, request *provider.Request) (*ClaudeCodeSession, error) {
 	prompt := request.GetPrompt()
 
-	if request.SystemPrompt != "" {
+	// a resumed session has already been given the system prompt
+	if request.SystemPrompt != "" && request.SessionID == "" {
 		prompt = fmt.Sprintf("System: %s\n\nUser: %s", request.SystemPrompt, prompt)
 	}
 
@@ -265,6 +268,10 @@ func (p *ClaudeCodeProvider) execute(ctx provider.GenerateContext, request *prov
 		prompt,
 	}
 
+	if request.SessionID != "" {
+		args = append(args, "--resume", request.SessionID)
+	}
+
 	if p.config.DangerouslySkipPermissions {
 		args = append(args, "--dangerously-skip-permissions")
 	}
@@ -289,7 +296,7 @@ func (p *ClaudeCodeProvider) execute(ctx provider.GenerateContext, request *prov
 		Str("executable", execPath).
 		Str("working_dir", p.workingDir).
 		Strs("args", args).
-		Str("prompt_preview", truncateString(prompt, 100)).
+		Str("prompt_preview", utils.TruncateText(prompt, 100)).
 		Msg("Executing Claude Code command")
 
 	cmd := exec.CommandContext(ctx.Context, execPath, args...) // #nosec G204 - execPath is validated internally
@@ -429,7 +436,7 @@ func (p *ClaudeCodeProvider) readStreamingResponse(ctx provider.GenerateContext,
 	}
 
 	log.Debug().
-		Str("content_preview", truncateString(finalResponse.Content, 100)).
+		Str("content_preview", utils.TruncateText(finalResponse.Content, 100)).
 		Msg("Successfully parsed Claude Code response")
 
 	return finalResponse, nil
@@ -492,7 +499,7 @@ func (p *ClaudeCodeProvider) processLine(ctx provider.GenerateContext, line stri
 						}
 					}
 
-					p.progressChan <- events.NewToolUseEvent(ctx.StepID, content.ID, content.Name, ctx.RunID, sb.String())
+					p.progressChan <- events.NewToolUseEvent(ctx.StepID, content.ID, content.Name, ctx.RunID, sb.String(), content.Input)
 				case "tool_result":
 					p.progressChan <- events.NewToolUseCompletedEvent(ctx.StepID, content.ID, content.Name, ctx.RunID)
 				default:
@@ -523,14 +530,6 @@ func (p *ClaudeCodeProvider) processLine(ctx provider.GenerateContext, line stri
 	return nil
 }
 
-// truncateString truncates a string to a maximum length
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
-}
-
 // detectClaudeCodeExecutable detects the Claude Code executable path
 func detectClaudeCodeExecutable(configPath string) (string, error) {
 	// Try configured path first
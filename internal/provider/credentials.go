@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lacquerai/lacquer/internal/keychain"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeyProviders lists the providers which authenticate with an API key,
+// which `laq auth login` stores in the OS keychain.
+var APIKeyProviders = []string{"anthropic", "openai"}
+
+// keychainGet reads a secret from the OS keychain (can be mocked for testing)
+var keychainGet = keychain.Get
+
+// APIKeyFromKeychain returns the API key of the provider stored in the OS
+// keychain by `laq auth login`, or an empty string when there is none.
+func APIKeyFromKeychain(name string) string {
+	key, err := keychainGet(name)
+	if err != nil {
+		if !errors.Is(err, keychain.ErrNotFound) {
+			log.Debug().Err(err).Str("provider", name).Msg("Failed to read API key from the keychain")
+		}
+		return ""
+	}
+
+	return strings.TrimSpace(key)
+}
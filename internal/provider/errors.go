@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorCategory is the kind of failure of a request to a provider, which
+// decides whether it's worth retrying the request or sending it to another
+// provider.
+type ErrorCategory string
+
+const (
+	// ErrorAuth is returned for missing, invalid or unauthorised API keys.
+	ErrorAuth ErrorCategory = "auth"
+	// ErrorRateLimit is returned while requests to the provider are
+	// throttled.
+	ErrorRateLimit ErrorCategory = "rate_limit"
+	// ErrorContextLength is returned for requests which don't fit in the
+	// context window of the model.
+	ErrorContextLength ErrorCategory = "context_length"
+	// ErrorContentFilter is returned for requests or responses blocked by
+	// the provider's content filter.
+	ErrorContentFilter ErrorCategory = "content_filter"
+	// ErrorServer is returned when the provider fails or is overloaded.
+	ErrorServer ErrorCategory = "server"
+	// ErrorUnknown is any other failure, such as a network error.
+	ErrorUnknown ErrorCategory = "unknown"
+)
+
+// Retryable reports whether the same request may succeed when it's sent
+// again. Failures which aren't known to be permanent are retryable.
+func (c ErrorCategory) Retryable() bool {
+	switch c {
+	case ErrorAuth, ErrorContextLength, ErrorContentFilter:
+		return false
+	default:
+		return true
+	}
+}
+
+// Fallback reports whether the request may succeed with the model of
+// another agent. Content filter and auth failures are never sent elsewhere,
+// they need to be fixed rather than worked around.
+func (c ErrorCategory) Fallback() bool {
+	switch c {
+	case ErrorRateLimit, ErrorServer, ErrorContextLength:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProviderFault reports whether the failure says something about the health
+// of the provider, rather than about the request.
+func (c ErrorCategory) ProviderFault() bool {
+	return c != ErrorContextLength && c != ErrorContentFilter
+}
+
+// Error is a failed request to a provider, normalised from the provider's
+// API error so that its category can be acted on whichever provider it came
+// from.
+type Error struct {
+	Provider string
+	Category ErrorCategory
+	// StatusCode is the HTTP status of the response, zero when the request
+	// failed without a response.
+	StatusCode int
+	// Message is the error message returned by the provider.
+	Message string
+	// RetryAfter is how long the provider asked to wait before retrying,
+	// zero when it didn't say.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s %s error (status %d): %s", e.Provider, e.Category, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s %s error: %s", e.Provider, e.Category, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewAPIError creates the error of a request to which the provider responded
+// with an error status. code is the provider's error type or code, such as
+// rate_limit_error or context_length_exceeded, and is used along with the
+// status and message to categorise the error.
+func NewAPIError(provider string, statusCode int, code, message string, header http.Header, err error) *Error {
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+
+	return &Error{
+		Provider:   provider,
+		Category:   categorize(statusCode, code, message),
+		StatusCode: statusCode,
+		Message:    message,
+		RetryAfter: retryAfter(header),
+		Err:        err,
+	}
+}
+
+// ErrorCategoryOf returns the category of a failed request, ErrorUnknown
+// when it isn't a provider error. Requests to providers whose circuit
+// breaker is open are server errors.
+func ErrorCategoryOf(err error) ErrorCategory {
+	var providerErr *Error
+	if errors.As(err, &providerErr) {
+		return providerErr.Category
+	}
+
+	var open *CircuitOpenError
+	if errors.As(err, &open) {
+		return ErrorServer
+	}
+
+	return ErrorUnknown
+}
+
+// categorize works out the category of an API error. Providers report
+// context length and content filter failures as bad requests, so they're
+// told apart by their code and message.
+func categorize(statusCode int, code, message string) ErrorCategory {
+	text := strings.ToLower(code + " " + message)
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrorAuth
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorRateLimit
+	case statusCode == http.StatusRequestEntityTooLarge,
+		strings.Contains(text, "context_length"),
+		strings.Contains(text, "context length"),
+		strings.Contains(text, "context window"),
+		strings.Contains(text, "prompt is too long"):
+		return ErrorContextLength
+	case strings.Contains(text, "content_filter"),
+		strings.Contains(text, "content management policy"),
+		strings.Contains(text, "content policy"):
+		return ErrorContentFilter
+	case statusCode >= http.StatusInternalServerError:
+		return ErrorServer
+	default:
+		return ErrorUnknown
+	}
+}
+
+// retryAfter returns the delay asked for by the Retry-After header, given
+// either in seconds or as a date.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+
+	return 0
+}
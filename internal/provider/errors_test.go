@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		code       string
+		message    string
+		expected   ErrorCategory
+	}{
+		{"invalid key", http.StatusUnauthorized, "authentication_error", "invalid x-api-key", ErrorAuth},
+		{"rate limited", http.StatusTooManyRequests, "rate_limit_error", "Number of requests exceeded", ErrorRateLimit},
+		{"anthropic prompt too long", http.StatusBadRequest, "invalid_request_error", "prompt is too long: 210000 tokens > 200000 maximum", ErrorContextLength},
+		{"openai context length", http.StatusBadRequest, "context_length_exceeded", "This model's maximum context length is 128000 tokens", ErrorContextLength},
+		{"azure content filter", http.StatusBadRequest, "content_filter", "The response was filtered", ErrorContentFilter},
+		{"overloaded", 529, "overloaded_error", "Overloaded", ErrorServer},
+		{"bad request", http.StatusBadRequest, "invalid_request_error", "temperature: out of range", ErrorUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewAPIError("anthropic", tt.statusCode, tt.code, tt.message, nil, assert.AnError)
+			assert.Equal(t, tt.expected, err.Category)
+			assert.ErrorIs(t, err, assert.AnError)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+func TestNewAPIError_RetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"2.5"}}
+	err := NewAPIError("openai", http.StatusTooManyRequests, "", "", header, nil)
+	assert.Equal(t, 2500*time.Millisecond, err.RetryAfter)
+	assert.Equal(t, "openai rate_limit error (status 429): Too Many Requests", err.Error())
+
+	header = http.Header{"Retry-After": []string{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}
+	err = NewAPIError("openai", http.StatusTooManyRequests, "", "", header, nil)
+	assert.InDelta(t, time.Hour.Seconds(), err.RetryAfter.Seconds(), 2)
+}
+
+func TestErrorCategoryOf(t *testing.T) {
+	wrapped := fmt.Errorf("model generation failed: %w", NewAPIError("anthropic", http.StatusUnauthorized, "", "", nil, nil))
+	assert.Equal(t, ErrorAuth, ErrorCategoryOf(wrapped))
+	assert.Equal(t, ErrorServer, ErrorCategoryOf(&CircuitOpenError{Provider: "anthropic"}))
+	assert.Equal(t, ErrorUnknown, ErrorCategoryOf(context.DeadlineExceeded))
+
+	assert.False(t, ErrorAuth.Retryable())
+	assert.False(t, ErrorAuth.Fallback())
+	assert.False(t, ErrorContextLength.Retryable())
+	assert.True(t, ErrorContextLength.Fallback())
+	assert.True(t, ErrorRateLimit.Retryable())
+	assert.True(t, ErrorUnknown.Retryable())
+	assert.False(t, ErrorUnknown.Fallback())
+}
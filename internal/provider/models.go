@@ -10,15 +10,28 @@ import (
 	"sync"
 
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/schema"
 	"github.com/lacquerai/lacquer/internal/style"
 	"github.com/lacquerai/lacquer/internal/tools"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 )
 
 type GenerateContext struct {
-	StepID  string
-	RunID   string
-	Context context.Context
+	StepID string
+	RunID  string
+	// ActionID is the action of the step the request is sent for, when set
+	// providers which support it stream the output of the model as token
+	// delta events of that action.
+	ActionID string
+	Context  context.Context
+}
+
+// Moderator is implemented by providers with a moderation API, which
+// checks text for harmful content.
+type Moderator interface {
+	// Moderate returns the categories the text is flagged for, none when
+	// it isn't flagged.
+	Moderate(ctx context.Context, text string) ([]string, error)
 }
 
 type LocalModelProvider interface {
@@ -59,6 +72,9 @@ type Message struct {
 	Role        string                   `json:"role"`
 	IsTruncated bool                     `json:"-"`
 	Content     []ContentBlockParamUnion `json:"content"`
+	// SessionID is the session of the provider the message was generated
+	// in, set by providers which keep their own sessions such as claude-code.
+	SessionID string `json:"-"`
 }
 
 // Image Source Types
@@ -229,11 +245,30 @@ type Request struct {
 	Stop         []string     `json:"stop,omitempty"`
 	Tools        []tools.Tool `json:"tools,omitempty"`
 
+	// ResponseSchema asks the model to respond with a JSON object matching the
+	// schema using the provider's structured output support. Providers without
+	// structured outputs ignore it, so the schema should also be in the prompt.
+	ResponseSchema *ResponseSchema `json:"response_schema,omitempty"`
+
+	// SessionID resumes a session kept by the provider, the messages are
+	// then only the ones sent since the session's last response. Only
+	// providers which set the SessionID of their messages use it.
+	SessionID string `json:"session_id,omitempty"`
+
 	// Additional metadata
 	RequestID string                 `json:"request_id,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// ResponseSchema describes the JSON object a model must respond with
+type ResponseSchema struct {
+	// Name identifies the schema, it's used as the tool name by providers
+	// which implement structured outputs with a forced tool call
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Schema      schema.JSON `json:"schema"`
+}
+
 // GetPrompt returns the first text prompt for the model request,
 // this is used for simple agents that don't have complex messages.
 // e.g. claude-code
@@ -272,6 +307,12 @@ func NewRegistry(disableCache bool) *Registry {
 
 // RegisterProvider registers a model provider
 func (mr *Registry) RegisterProvider(provider Provider) error {
+	return mr.RegisterProviderContext(context.Background(), provider)
+}
+
+// RegisterProviderContext registers a model provider, fetching the models it
+// supports with ctx when they aren't cached
+func (mr *Registry) RegisterProviderContext(ctx context.Context, provider Provider) error {
 	mr.mu.Lock()
 	defer mr.mu.Unlock()
 
@@ -282,7 +323,7 @@ func (mr *Registry) RegisterProvider(provider Provider) error {
 
 	mr.providers[name] = provider
 
-	models, err := mr.modelCache.GetModels(context.Background(), provider)
+	models, err := mr.modelCache.GetModels(ctx, provider)
 	if err != nil {
 		return fmt.Errorf("failed to get models: %w", err)
 	}
@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// CompatibleConfig contains the configuration of a provider for a service
+// which speaks the OpenAI chat completions API, such as Azure OpenAI,
+// Together, Groq, vLLM or a LiteLLM gateway.
+type CompatibleConfig struct {
+	BaseURL    string            `yaml:"base_url"`
+	APIKeyEnv  string            `yaml:"api_key_env"`
+	Headers    map[string]string `yaml:"headers"`
+	Models     []string          `yaml:"models"`
+	MaxRetries int               `yaml:"max_retries"`
+}
+
+// NewCompatibleProvider creates a provider named name which sends requests
+// to the chat completions API at the base URL of the config. The provider
+// never falls back to the OpenAI credentials of the environment, so they
+// aren't sent to other services.
+func NewCompatibleProvider(name string, yamlConfig map[string]interface{}) (*OpenAIProvider, error) {
+	config := &CompatibleConfig{MaxRetries: getDefaultOpenAIConfig().MaxRetries}
+	provider.MergeConfig(config, yamlConfig)
+
+	if config.BaseURL == "" {
+		return nil, fmt.Errorf("base_url is required")
+	}
+
+	options := []option.RequestOption{
+		option.WithBaseURL(config.BaseURL),
+		option.WithMaxRetries(config.MaxRetries),
+		option.WithHeaderDel("OpenAI-Organization"),
+		option.WithHeaderDel("OpenAI-Project"),
+	}
+
+	if config.APIKeyEnv != "" {
+		apiKey := os.Getenv(config.APIKeyEnv)
+		if apiKey == "" {
+			return nil, fmt.Errorf("please set the %s environment variable to the API key of %s", config.APIKeyEnv, config.BaseURL)
+		}
+		options = append(options, option.WithAPIKey(apiKey))
+	} else {
+		options = append(options, option.WithHeaderDel("authorization"))
+	}
+
+	for name, value := range config.Headers {
+		options = append(options, option.WithHeader(name, os.ExpandEnv(value)))
+	}
+
+	client := openai.NewClient(options...)
+
+	return &OpenAIProvider{
+		name:       name,
+		client:     &client,
+		config:     &OpenAIConfig{BaseURL: config.BaseURL, MaxRetries: config.MaxRetries},
+		models:     config.Models,
+		compatible: true,
+	}, nil
+}
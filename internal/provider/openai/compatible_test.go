@@ -0,0 +1,125 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompatibleServer(t *testing.T, requests chan<- *http.Request) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Clone(context.Background())
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"created": 1,
+			"model":   "llama-3.1-8b-instant",
+			"choices": []map[string]any{{
+				"index":         0,
+				"finish_reason": "stop",
+				"message":       map[string]any{"role": "assistant", "content": "hello"},
+			}},
+			"usage": map[string]any{"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestCompatibleProvider_Generate(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+	t.Setenv("GROQ_API_KEY", "gsk-groq")
+	t.Setenv("TEAM", "research")
+
+	requests := make(chan *http.Request, 1)
+	server := newCompatibleServer(t, requests)
+
+	pr, err := NewCompatibleProvider("openai-compatible-test", map[string]interface{}{
+		"base_url":    server.URL + "/v1",
+		"api_key_env": "GROQ_API_KEY",
+		"headers":     map[string]string{"X-Team": "${TEAM}"},
+		"models":      []string{"llama-3.1-8b-instant"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "openai-compatible-test", pr.GetName())
+
+	messages, usage, err := pr.Generate(provider.GenerateContext{Context: context.Background()}, &provider.Request{
+		Model: "llama-3.1-8b-instant",
+		Messages: []provider.Message{{
+			Role:    "user",
+			Content: []provider.ContentBlockParamUnion{provider.NewTextBlock("Say hello")},
+		}},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "hello", messages[0].Content[0].OfText.Text)
+	assert.Equal(t, 4, usage.TotalTokens)
+	assert.Equal(t, "llama-3.1-8b-instant", usage.Model)
+
+	request := <-requests
+	assert.Equal(t, "/v1/chat/completions", request.URL.Path)
+	assert.Equal(t, "Bearer gsk-groq", request.Header.Get("Authorization"))
+	assert.Equal(t, "research", request.Header.Get("X-Team"))
+}
+
+func TestCompatibleProvider_WithoutAPIKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+	requests := make(chan *http.Request, 1)
+	server := newCompatibleServer(t, requests)
+
+	pr, err := NewCompatibleProvider("openai-compatible-test", map[string]interface{}{
+		"base_url": server.URL,
+		"models":   []string{"llama-3.1-8b-instant"},
+	})
+	require.NoError(t, err)
+
+	_, _, err = pr.Generate(provider.GenerateContext{Context: context.Background()}, &provider.Request{
+		Model: "llama-3.1-8b-instant",
+		Messages: []provider.Message{{
+			Role:    "user",
+			Content: []provider.ContentBlockParamUnion{provider.NewTextBlock("Say hello")},
+		}},
+	}, nil)
+	require.NoError(t, err)
+
+	// the OpenAI key of the environment is never sent to other services
+	request := <-requests
+	assert.Empty(t, request.Header.Get("Authorization"))
+}
+
+func TestCompatibleProvider_MissingAPIKey(t *testing.T) {
+	t.Setenv("GROQ_API_KEY", "")
+
+	_, err := NewCompatibleProvider("openai-compatible-test", map[string]interface{}{
+		"base_url":    "https://api.groq.com/openai/v1",
+		"api_key_env": "GROQ_API_KEY",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GROQ_API_KEY")
+}
+
+func TestCompatibleProvider_ListModels(t *testing.T) {
+	pr, err := NewCompatibleProvider("openai-compatible-test", map[string]interface{}{
+		"base_url": "http://localhost:8000/v1",
+		"models":   []string{"meta-llama/Llama-3.1-8B-Instruct"},
+	})
+	require.NoError(t, err)
+
+	models, err := pr.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "meta-llama/Llama-3.1-8B-Instruct", models[0].ID)
+	assert.Equal(t, "openai-compatible-test", models[0].Provider)
+}
@@ -3,8 +3,11 @@ package openai
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
 	"github.com/rs/zerolog/log"
 )
 
@@ -21,6 +25,13 @@ type OpenAIProvider struct {
 	name   string
 	client *openai.Client
 	config *OpenAIConfig
+	// models are the models of providers for OpenAI compatible services,
+	// which are listed without asking the service as many don't list them
+	models []string
+	// compatible is set for providers of OpenAI compatible services, whose
+	// responses aren't streamed as not every service reports the usage of
+	// streamed responses
+	compatible bool
 }
 
 // OpenAIConfig contains configuration for the OpenAI provider
@@ -58,7 +69,10 @@ func NewProvider(yamlConfig map[string]interface{}) (*OpenAIProvider, error) {
 	if config.APIKey == "" {
 		config.APIKey = GetOpenAIAPIKeyFromEnv()
 		if config.APIKey == "" {
-			return nil, fmt.Errorf("please set an OPENAI_API_KEY environment variable")
+			config.APIKey = provider.APIKeyFromKeychain("openai")
+		}
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("please set an OPENAI_API_KEY environment variable or run laq auth login openai")
 		}
 		options = append(options, option.WithAPIKey(config.APIKey))
 	}
@@ -78,7 +92,7 @@ func NewProvider(yamlConfig map[string]interface{}) (*OpenAIProvider, error) {
 
 // Generate generates a response using the OpenAI API
 func (p *OpenAIProvider) Generate(ctx provider.GenerateContext, request *provider.Request, progressChan chan<- pkgEvents.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
-	tools := make([]openai.ChatCompletionToolParam, len(request.Tools), 0)
+	tools := make([]openai.ChatCompletionToolParam, 0, len(request.Tools))
 	for _, tool := range request.Tools {
 		parameters, err := json.Marshal(tool.Parameters)
 		if err != nil {
@@ -120,9 +134,30 @@ func (p *OpenAIProvider) Generate(ctx provider.GenerateContext, request *provide
 		params.TopP = openai.Float(*request.TopP)
 	}
 
-	response, err := p.client.Chat.Completions.New(ctx.Context, params)
+	if request.ResponseSchema != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:        request.ResponseSchema.Name,
+					Description: openai.String(request.ResponseSchema.Description),
+					Schema:      request.ResponseSchema.Schema,
+				},
+			},
+		}
+	}
+
+	var response *openai.ChatCompletion
+	var err error
+	if ctx.Streams(progressChan) && !p.compatible {
+		response, err = p.stream(ctx, params, progressChan)
+	} else {
+		response, err = p.client.Chat.Completions.New(ctx.Context, params)
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create OpenAI completion: %w", err)
+		return nil, nil, fmt.Errorf("failed to create OpenAI completion: %w", p.apiError(err))
+	}
+	if len(response.Choices) == 0 {
+		return nil, nil, fmt.Errorf("OpenAI completion has no choices")
 	}
 
 	// Calculate token usage and cost
@@ -130,6 +165,7 @@ func (p *OpenAIProvider) Generate(ctx provider.GenerateContext, request *provide
 		PromptTokens:     int(response.Usage.PromptTokens),
 		CompletionTokens: int(response.Usage.CompletionTokens),
 		TotalTokens:      int(response.Usage.TotalTokens),
+		Model:            response.Model,
 	}
 
 	log.Debug().
@@ -162,6 +198,87 @@ func (p *OpenAIProvider) Generate(ctx provider.GenerateContext, request *provide
 	return messages, tokenUsage, nil
 }
 
+// stream sends the request with the streaming API, sending each chunk of
+// text as a token delta event, and returns the completion accumulated from
+// the stream.
+func (p *OpenAIProvider) stream(ctx provider.GenerateContext, params openai.ChatCompletionNewParams, progressChan chan<- pkgEvents.ExecutionEvent) (*openai.ChatCompletion, error) {
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+
+	stream := p.client.Chat.Completions.NewStreaming(ctx.Context, params)
+	defer stream.Close()
+
+	acc := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		if !acc.AddChunk(chunk) {
+			return nil, fmt.Errorf("failed to accumulate streamed completion")
+		}
+
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			progressChan <- ctx.TokenDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return &acc.ChatCompletion, nil
+}
+
+// apiError normalises the error of a request the API responded to with an
+// error status into a *provider.Error, other errors are returned as is.
+func (p *OpenAIProvider) apiError(err error) error {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+
+	code := apiErr.Code
+	if code == "" {
+		code = apiErr.Type
+	}
+
+	var header http.Header
+	if apiErr.Response != nil {
+		header = apiErr.Response.Header
+	}
+
+	return provider.NewAPIError(p.GetName(), apiErr.StatusCode, code, apiErr.Message, header, err)
+}
+
+// Moderate checks the text with the moderation API, returning the
+// categories it's flagged for.
+func (p *OpenAIProvider) Moderate(ctx context.Context, text string) ([]string, error) {
+	response, err := p.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfString: openai.String(text)},
+		Model: openai.ModerationModelOmniModerationLatest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to moderate content: %w", p.apiError(err))
+	}
+
+	var flagged []string
+	for _, result := range response.Results {
+		if !result.Flagged {
+			continue
+		}
+
+		var categories map[string]bool
+		if err := json.Unmarshal([]byte(result.Categories.RawJSON()), &categories); err != nil {
+			return nil, fmt.Errorf("failed to read moderation categories: %w", err)
+		}
+		for category, ok := range categories {
+			if ok {
+				flagged = append(flagged, category)
+			}
+		}
+	}
+
+	sort.Strings(flagged)
+	return flagged, nil
+}
+
 // GetName returns the provider name
 func (p *OpenAIProvider) GetName() string {
 	if p.config.Platform != "" {
@@ -171,8 +288,38 @@ func (p *OpenAIProvider) GetName() string {
 	return p.name
 }
 
+// ToolConstraints returns the limits OpenAI puts on the tools of a request.
+// OpenAI compatible services differ too much to know theirs.
+func (p *OpenAIProvider) ToolConstraints() provider.ToolConstraints {
+	if p.compatible {
+		return provider.ToolConstraints{}
+	}
+
+	return provider.ToolConstraints{
+		MaxTools:            128,
+		NamePattern:         provider.ToolNamePattern,
+		NameRule:            provider.ToolNameRule,
+		MaxSchemaDepth:      10,
+		MaxSchemaProperties: 5000,
+	}
+}
+
 // ListModels dynamically fetches available models from the OpenAI API
 func (p *OpenAIProvider) ListModels(ctx context.Context) ([]provider.Info, error) {
+	if p.models != nil {
+		models := make([]provider.Info, len(p.models))
+		for i, model := range p.models {
+			models[i] = provider.Info{
+				ID:          model,
+				Name:        model,
+				Provider:    p.name,
+				Description: fmt.Sprintf("Model served at %s", p.config.BaseURL),
+				Features:    []string{"text-generation", "chat"},
+			}
+		}
+		return models, nil
+	}
+
 	response, err := p.client.Models.List(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list models: %w", err)
@@ -278,16 +425,17 @@ func getDefaultOpenAIConfig() *OpenAIConfig {
 	return config
 }
 
+// APIKeyEnvVars lists the environment variables the API key is read from,
+// in order of precedence.
+var APIKeyEnvVars = []string{
+	"OPENAI_API_KEY",
+	"OPENAI_KEY",
+	"OPENAI_TOKEN",
+}
+
 // GetOpenAIAPIKeyFromEnv retrieves the OpenAI API key from environment variables
 func GetOpenAIAPIKeyFromEnv() string {
-	// Try multiple environment variable names
-	envVars := []string{
-		"OPENAI_API_KEY",
-		"OPENAI_KEY",
-		"OPENAI_TOKEN",
-	}
-
-	for _, envVar := range envVars {
+	for _, envVar := range APIKeyEnvVars {
 		if apiKey := os.Getenv(envVar); apiKey != "" {
 			return apiKey
 		}
@@ -0,0 +1,25 @@
+package provider
+
+import (
+	"time"
+
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+)
+
+// Streams reports whether the output of the model should be streamed to
+// progressChan as it's generated.
+func (g GenerateContext) Streams(progressChan chan<- pkgEvents.ExecutionEvent) bool {
+	return progressChan != nil && g.ActionID != ""
+}
+
+// TokenDelta returns the event of a chunk of output streamed by the model.
+func (g GenerateContext) TokenDelta(text string) pkgEvents.ExecutionEvent {
+	return pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventTokenDelta,
+		Timestamp: time.Now(),
+		RunID:     g.RunID,
+		StepID:    g.StepID,
+		ActionID:  g.ActionID,
+		Text:      text,
+	}
+}
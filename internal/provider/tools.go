@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/lacquerai/lacquer/internal/tools"
+)
+
+// ToolNamePattern is the pattern Anthropic and OpenAI require tool names to
+// match, ToolNameRule describes it.
+var ToolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+const ToolNameRule = "may only contain letters, digits, underscores and hyphens and be at most 64 characters long"
+
+// ToolConstraints are the limits a provider puts on the tools sent with a
+// request. Zero values aren't limited.
+type ToolConstraints struct {
+	// MaxTools is the most tools a request may have.
+	MaxTools int
+	// NamePattern is the pattern tool names must match, described by
+	// NameRule.
+	NamePattern *regexp.Regexp
+	NameRule    string
+	// MaxSchemaDepth is how many levels of objects and arrays the parameters
+	// of a tool may nest, the parameters object itself is the first level.
+	MaxSchemaDepth int
+	// MaxSchemaProperties is the most properties the parameters of a tool
+	// may have, counting the properties of nested objects.
+	MaxSchemaProperties int
+}
+
+// ToolConstrainedProvider is implemented by providers which limit the tools
+// they can be sent, so that tools they would reject are reported before a
+// run starts rather than with an error from the provider.
+type ToolConstrainedProvider interface {
+	ToolConstraints() ToolConstraints
+}
+
+// ToolViolation is a constraint broken by the tools of an agent. Tool is
+// empty when the violation isn't caused by a single tool.
+type ToolViolation struct {
+	Tool   string
+	Reason string
+}
+
+// Check returns the constraints broken by the tools, none when the tools are
+// accepted.
+func (c ToolConstraints) Check(toolList []tools.Tool) []ToolViolation {
+	var violations []ToolViolation
+
+	if c.MaxTools > 0 && len(toolList) > c.MaxTools {
+		violations = append(violations, ToolViolation{
+			Reason: fmt.Sprintf("%d tools are given but at most %d are accepted", len(toolList), c.MaxTools),
+		})
+	}
+
+	for _, tool := range toolList {
+		if c.NamePattern != nil && !c.NamePattern.MatchString(tool.Name) {
+			violations = append(violations, ToolViolation{Tool: tool.Name, Reason: "name " + c.NameRule})
+		}
+
+		if c.MaxSchemaDepth == 0 && c.MaxSchemaProperties == 0 {
+			continue
+		}
+
+		data, err := json.Marshal(tool.Parameters)
+		if err != nil {
+			violations = append(violations, ToolViolation{Tool: tool.Name, Reason: fmt.Sprintf("parameters aren't a valid JSON schema: %s", err)})
+			continue
+		}
+		var parameters interface{}
+		if err := json.Unmarshal(data, &parameters); err != nil {
+			violations = append(violations, ToolViolation{Tool: tool.Name, Reason: fmt.Sprintf("parameters aren't a valid JSON schema: %s", err)})
+			continue
+		}
+
+		depth, properties := measureSchema(parameters)
+		if c.MaxSchemaDepth > 0 && depth > c.MaxSchemaDepth {
+			violations = append(violations, ToolViolation{
+				Tool:   tool.Name,
+				Reason: fmt.Sprintf("parameters nest %d levels deep but at most %d are accepted", depth, c.MaxSchemaDepth),
+			})
+		}
+		if c.MaxSchemaProperties > 0 && properties > c.MaxSchemaProperties {
+			violations = append(violations, ToolViolation{
+				Tool:   tool.Name,
+				Reason: fmt.Sprintf("parameters have %d properties but at most %d are accepted", properties, c.MaxSchemaProperties),
+			})
+		}
+	}
+
+	return violations
+}
+
+// measureSchema returns the levels of objects and arrays a JSON schema nests
+// and its number of properties. Combined schemas, such as the branches of
+// anyOf, and definitions are at the same level as the schema holding them.
+func measureSchema(value interface{}) (int, int) {
+	schema, ok := value.(map[string]interface{})
+	if !ok {
+		return 0, 0
+	}
+
+	depth, properties := 0, 0
+	nested := func(child interface{}) {
+		childDepth, childProperties := measureSchema(child)
+		depth = max(depth, childDepth)
+		properties += childProperties
+	}
+
+	if children, ok := schema["properties"].(map[string]interface{}); ok {
+		properties += len(children)
+		for _, child := range children {
+			nested(child)
+		}
+	}
+	for _, key := range []string{"items", "additionalProperties"} {
+		switch child := schema[key].(type) {
+		case map[string]interface{}:
+			nested(child)
+		case []interface{}:
+			for _, item := range child {
+				nested(item)
+			}
+		}
+	}
+
+	// combined schemas and definitions don't add a level
+	sameLevel := 0
+	alongside := func(child interface{}) {
+		childDepth, childProperties := measureSchema(child)
+		sameLevel = max(sameLevel, childDepth)
+		properties += childProperties
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		branches, _ := schema[key].([]interface{})
+		for _, branch := range branches {
+			alongside(branch)
+		}
+	}
+	if definitions, ok := schema["$defs"].(map[string]interface{}); ok {
+		for _, definition := range definitions {
+			alongside(definition)
+		}
+	}
+
+	if schema["type"] == "object" || schema["type"] == "array" || schema["properties"] != nil || schema["items"] != nil {
+		depth++
+	}
+
+	return max(depth, sameLevel), properties
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/lacquerai/lacquer/internal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolConstraints_Check(t *testing.T) {
+	constraints := ToolConstraints{
+		MaxTools:            2,
+		NamePattern:         ToolNamePattern,
+		NameRule:            ToolNameRule,
+		MaxSchemaDepth:      2,
+		MaxSchemaProperties: 3,
+	}
+
+	object := func(properties map[string]schema.JSON) schema.JSON {
+		return schema.JSON{Type: "object", Properties: properties}
+	}
+	str := schema.JSON{Type: "string"}
+
+	tests := []struct {
+		name  string
+		tools []tools.Tool
+		want  []ToolViolation
+	}{
+		{
+			name:  "accepted",
+			tools: []tools.Tool{{Name: "web_search-2", Parameters: object(map[string]schema.JSON{"query": str})}},
+		},
+		{
+			name:  "invalid name",
+			tools: []tools.Tool{{Name: "web.search"}},
+			want:  []ToolViolation{{Tool: "web.search", Reason: "name " + ToolNameRule}},
+		},
+		{
+			name:  "too many tools",
+			tools: []tools.Tool{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			want:  []ToolViolation{{Reason: "3 tools are given but at most 2 are accepted"}},
+		},
+		{
+			name: "too deep",
+			tools: []tools.Tool{{Name: "deep", Parameters: object(map[string]schema.JSON{
+				"filter": object(map[string]schema.JSON{
+					"tags": {Type: "array", Items: str},
+				}),
+			})}},
+			want: []ToolViolation{{Tool: "deep", Reason: "parameters nest 3 levels deep but at most 2 are accepted"}},
+		},
+		{
+			name: "combined schemas don't nest",
+			tools: []tools.Tool{{Name: "combined", Parameters: object(map[string]schema.JSON{
+				"filter": {AnyOf: []schema.JSON{object(map[string]schema.JSON{"tag": str}), str}},
+			})}},
+		},
+		{
+			name: "too many properties",
+			tools: []tools.Tool{{Name: "wide", Parameters: object(map[string]schema.JSON{
+				"a": str,
+				"b": str,
+				"c": object(map[string]schema.JSON{"d": str}),
+			})}},
+			want: []ToolViolation{{Tool: "wide", Reason: "parameters have 4 properties but at most 3 are accepted"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, constraints.Check(tt.tools))
+		})
+	}
+}
+
+func TestToolConstraints_CheckUnlimited(t *testing.T) {
+	toolList := make([]tools.Tool, 200)
+	for i := range toolList {
+		toolList[i] = tools.Tool{Name: fmt.Sprintf("tool %d", i)}
+	}
+
+	assert.Empty(t, ToolConstraints{}.Check(toolList))
+}
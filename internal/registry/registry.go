@@ -0,0 +1,334 @@
+// Package registry searches indexes of reusable blocks, the public Lacquer
+// block index along with any private registries, so that blocks can be
+// discovered and added to workflows.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultIndexURL is the location of the public block index.
+	DefaultIndexURL = "https://registry.lacquer.ai/blocks/index.json"
+
+	// DefaultCacheTTL is how long a downloaded index is reused before it is
+	// fetched again.
+	DefaultCacheTTL = time.Hour
+)
+
+// Block is an entry of a block index.
+type Block struct {
+	// Name is the reference of the block without a version, e.g.
+	// lacquer/http-request
+	Name        string                       `json:"name" yaml:"name"`
+	Version     string                       `json:"version" yaml:"version"`
+	Description string                       `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string                     `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Readme      string                       `json:"readme,omitempty" yaml:"readme,omitempty"`
+	Inputs      map[string]*ast.InputParam   `json:"inputs,omitempty" yaml:"inputs,omitempty"`
+	Outputs     map[string]*ast.OutputSchema `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+
+	// Registry is the name of the registry the block was found in.
+	Registry string `json:"registry,omitempty" yaml:"registry,omitempty"`
+}
+
+// Ref returns the versioned reference used to add the block to a workflow.
+func (b *Block) Ref() string {
+	if b.Version == "" {
+		return b.Name
+	}
+
+	return b.Name + "@" + b.Version
+}
+
+// Describe returns the documentation of the block's interface.
+func (b *Block) Describe() *ast.WorkflowDoc {
+	workflow := &ast.Workflow{
+		Metadata: &ast.WorkflowMetadata{
+			Name:        b.Ref(),
+			Description: b.Description,
+		},
+		Inputs:  b.Inputs,
+		Outputs: b.Outputs,
+	}
+
+	return workflow.Describe()
+}
+
+// Index is the document served by a registry.
+type Index struct {
+	Blocks []*Block `json:"blocks"`
+}
+
+// Source is a registry to read blocks from.
+type Source struct {
+	Name string `mapstructure:"name" yaml:"name"`
+	URL  string `mapstructure:"url" yaml:"url"`
+	// Token is sent as a bearer token, for private registries. Environment
+	// variables such as $REGISTRY_TOKEN are expanded.
+	Token string `mapstructure:"token" yaml:"token"`
+}
+
+// Client reads blocks from a set of registries.
+type Client struct {
+	sources  []Source
+	http     *http.Client
+	cacheDir string
+	cacheTTL time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to fetch indexes.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithCache caches downloaded indexes in dir for ttl, cached indexes are
+// also used when a registry can't be reached.
+func WithCache(dir string, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cacheDir = dir
+		c.cacheTTL = ttl
+	}
+}
+
+// NewClient creates a client for the given registries. When the same block
+// version is published by more than one registry the earlier registry wins,
+// so private registries should be listed before the public index.
+func NewClient(sources []Source, opts ...Option) *Client {
+	c := &Client{
+		sources: sources,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// List returns every block of every registry, sorted by name and newest
+// version first.
+func (c *Client) List(ctx context.Context) ([]*Block, error) {
+	seen := make(map[string]bool)
+	var blocks []*Block
+	var errs []string
+
+	for _, source := range c.sources {
+		index, err := c.fetch(ctx, source)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", source.displayName(), err))
+			continue
+		}
+
+		for _, block := range index.Blocks {
+			if block.Name == "" || seen[block.Ref()] {
+				continue
+			}
+			seen[block.Ref()] = true
+
+			block.Registry = source.displayName()
+			blocks = append(blocks, block)
+		}
+	}
+
+	if len(blocks) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("failed to read block registries: %s", strings.Join(errs, "; "))
+	}
+
+	for _, err := range errs {
+		log.Warn().Msgf("Skipping block registry %s", err)
+	}
+
+	sort.SliceStable(blocks, func(i, j int) bool {
+		if blocks[i].Name != blocks[j].Name {
+			return blocks[i].Name < blocks[j].Name
+		}
+		return compareVersions(blocks[i].Version, blocks[j].Version) > 0
+	})
+
+	return blocks, nil
+}
+
+// Search returns the latest version of every block whose name, description
+// or tags contain all of the words of the query. Blocks whose name matches
+// are listed first.
+func (c *Client) Search(ctx context.Context, query string) ([]*Block, error) {
+	blocks, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+
+	var nameMatches, otherMatches []*Block
+	seen := make(map[string]bool)
+	for _, block := range blocks {
+		if seen[block.Name] {
+			continue
+		}
+		seen[block.Name] = true
+
+		name := strings.ToLower(block.Name)
+		text := strings.ToLower(strings.Join(append([]string{block.Name, block.Description}, block.Tags...), " "))
+
+		matchesName, matchesAll := true, true
+		for _, word := range words {
+			matchesName = matchesName && strings.Contains(name, word)
+			matchesAll = matchesAll && strings.Contains(text, word)
+		}
+
+		switch {
+		case matchesName:
+			nameMatches = append(nameMatches, block)
+		case matchesAll:
+			otherMatches = append(otherMatches, block)
+		}
+	}
+
+	return append(nameMatches, otherMatches...), nil
+}
+
+// Get returns the block for a reference such as lacquer/http-request@v1.
+// Without a version the newest version is returned.
+func (c *Client) Get(ctx context.Context, ref string) (*Block, error) {
+	name, version, _ := strings.Cut(ref, "@")
+
+	blocks, err := c.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// blocks are sorted newest first so the first match is the latest
+	for _, block := range blocks {
+		if block.Name == name && (version == "" || block.Version == version) {
+			return block, nil
+		}
+	}
+
+	return nil, fmt.Errorf("block %s not found", ref)
+}
+
+// fetch returns the index of the source, using the cache when it is fresh or
+// the registry can't be reached.
+func (c *Client) fetch(ctx context.Context, source Source) (*Index, error) {
+	cachePath := c.cachePath(source)
+	if cachePath != "" {
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < c.cacheTTL {
+			if index, err := readIndex(cachePath); err == nil {
+				return index, nil
+			}
+		}
+	}
+
+	data, err := c.download(ctx, source)
+	if err != nil {
+		if cachePath != "" {
+			if index, cacheErr := readIndex(cachePath); cacheErr == nil {
+				log.Debug().Err(err).Str("registry", source.displayName()).Msg("Using cached block index")
+				return index, nil
+			}
+		}
+		return nil, err
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid block index: %w", err)
+	}
+
+	if cachePath != "" {
+		if err := os.MkdirAll(filepath.Dir(cachePath), 0750); err == nil {
+			_ = os.WriteFile(cachePath, data, 0600)
+		}
+	}
+
+	return &index, nil
+}
+
+func (c *Client) download(ctx context.Context, source Source) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if token := os.ExpandEnv(source.Token); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 20*1024*1024))
+}
+
+func (c *Client) cachePath(source Source) string {
+	if c.cacheDir == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(source.URL))
+	return filepath.Join(c.cacheDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func readIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path is derived from the registry URL
+	if err != nil {
+		return nil, err
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+
+	return &index, nil
+}
+
+func (s Source) displayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+
+	return s.URL
+}
+
+// compareVersions orders versions such as v1, v1.2 and v1.2.3 semantically,
+// falling back to a string comparison for anything else.
+func compareVersions(a, b string) int {
+	va, errA := semver.NewVersion(a)
+	vb, errB := semver.NewVersion(b)
+	if errA == nil && errB == nil {
+		return va.Compare(vb)
+	}
+
+	return strings.Compare(a, b)
+}
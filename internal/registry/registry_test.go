@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveIndex(t *testing.T, index Index, token string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(index)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestClient_ListAndSearch(t *testing.T) {
+	public := serveIndex(t, Index{Blocks: []*Block{
+		{Name: "lacquer/http-request", Version: "v1", Description: "Make an HTTP request", Tags: []string{"http", "api"}},
+		{Name: "lacquer/http-request", Version: "v2", Description: "Make an HTTP request", Tags: []string{"http", "api"}},
+		{Name: "lacquer/summarize", Version: "v1", Description: "Summarize text fetched over http"},
+	}}, "")
+	private := serveIndex(t, Index{Blocks: []*Block{
+		{Name: "lacquer/summarize", Version: "v1", Description: "Internal summarizer"},
+	}}, "secret")
+
+	t.Setenv("TEST_REGISTRY_TOKEN", "secret")
+	client := NewClient([]Source{
+		{Name: "internal", URL: private.URL, Token: "$TEST_REGISTRY_TOKEN"},
+		{URL: public.URL},
+	})
+
+	blocks, err := client.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, blocks, 3)
+	assert.Equal(t, "lacquer/http-request@v2", blocks[0].Ref())
+	assert.Equal(t, "lacquer/http-request@v1", blocks[1].Ref())
+	assert.Equal(t, "internal", blocks[2].Registry)
+	assert.Equal(t, "Internal summarizer", blocks[2].Description)
+
+	results, err := client.Search(context.Background(), "HTTP")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "lacquer/http-request@v2", results[0].Ref())
+
+	results, err = client.Search(context.Background(), "request")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	block, err := client.Get(context.Background(), "lacquer/http-request")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", block.Version)
+
+	block, err = client.Get(context.Background(), "lacquer/http-request@v1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", block.Version)
+
+	_, err = client.Get(context.Background(), "lacquer/missing")
+	assert.EqualError(t, err, "block lacquer/missing not found")
+}
+
+func TestClient_Cache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(Index{Blocks: []*Block{{Name: "lacquer/echo", Version: "v1"}}})
+	}))
+
+	dir := t.TempDir()
+	client := NewClient([]Source{{URL: server.URL}}, WithCache(dir, time.Hour))
+
+	_, err := client.List(context.Background())
+	require.NoError(t, err)
+	_, err = client.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	// an unreachable registry falls back to the stale cache
+	server.Close()
+	client = NewClient([]Source{{URL: server.URL}}, WithCache(dir, 0))
+	blocks, err := client.List(context.Background())
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, "lacquer/echo@v1", blocks[0].Ref())
+}
+
+func TestClient_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+
+	_, err := NewClient([]Source{{URL: server.URL}}).List(context.Background())
+	assert.ErrorContains(t, err, "unexpected status 404 Not Found")
+}
@@ -0,0 +1,319 @@
+// Package remote is a client of the API of laq serve, running workflows
+// on a server and streaming their events back as they run.
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/server"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+)
+
+const (
+	// maxReconnects is how many times in a row the event stream of an
+	// execution is reconnected to when it's interrupted
+	maxReconnects = 5
+	// reconnectDelay is how long to wait before reconnecting to the event
+	// stream of an execution
+	reconnectDelay = time.Second
+	// maxEventSize is the largest event read from an event stream
+	maxEventSize = 16 * 1024 * 1024
+)
+
+// Client calls the API of a lacquer server.
+type Client struct {
+	baseURL    *url.URL
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the server at baseURL, e.g.
+// http://runner:8080, authenticating with apiKey when it's set.
+func NewClient(baseURL, apiKey string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q, must be an http or https URL such as http://localhost:8080", baseURL)
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{baseURL: u, apiKey: apiKey, httpClient: httpClient}, nil
+}
+
+// Server returns the URL of the server.
+func (c *Client) Server() string {
+	return c.baseURL.String()
+}
+
+// Started is an execution the server has started.
+type Started struct {
+	RunID      string    `json:"run_id"`
+	WorkflowID string    `json:"workflow_id"`
+	Status     string    `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// Execute starts an execution of a workflow loaded by the server.
+func (c *Client) Execute(ctx context.Context, workflowID string, inputs map[string]any) (*Started, error) {
+	var started Started
+	err := c.do(ctx, http.MethodPost, "/api/v1/workflows/"+url.PathEscape(workflowID)+"/execute", map[string]any{
+		"inputs": inputs,
+	}, &started)
+	if err != nil {
+		return nil, err
+	}
+	return &started, nil
+}
+
+// Submit starts an execution of a workflow sent to the server, which must
+// allow it with laq serve --allow-submit.
+func (c *Client) Submit(ctx context.Context, filename string, source []byte, inputs map[string]any) (*Started, error) {
+	var started Started
+	err := c.do(ctx, http.MethodPost, "/api/v1/executions", map[string]any{
+		"workflow": string(source),
+		"filename": filepath.Base(filename),
+		"inputs":   inputs,
+	}, &started)
+	if err != nil {
+		return nil, err
+	}
+	return &started, nil
+}
+
+// Execution returns the status of an execution, with its outputs once it
+// has completed.
+func (c *Client) Execution(ctx context.Context, runID string) (*server.ExecutionStatus, error) {
+	var status server.ExecutionStatus
+	if err := c.do(ctx, http.MethodGet, "/api/v1/executions/"+url.PathEscape(runID), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Events sends the events of an execution to events until it has finished,
+// from its first event. An interrupted stream is reconnected to after the
+// last event received. events is closed when Events returns.
+func (c *Client) Events(ctx context.Context, runID string, events chan<- pkgEvents.ExecutionEvent) error {
+	defer close(events)
+
+	lastEventID := ""
+	failures := 0
+	for {
+		received, ended, err := c.streamEvents(ctx, runID, &lastEventID, events)
+		if ended {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// errors of the server, such as an execution it doesn't know of,
+		// won't go away by reconnecting
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			return err
+		}
+
+		if received > 0 {
+			failures = 0
+		}
+		failures++
+		if failures > maxReconnects {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("event stream of run %s was interrupted: %w", runID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// streamEvents reads the Server-Sent Events stream of an execution after
+// lastEventID, which it updates, returning how many events it received and
+// whether the stream ended.
+func (c *Client) streamEvents(ctx context.Context, runID string, lastEventID *string, events chan<- pkgEvents.ExecutionEvent) (int, bool, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/v1/executions/"+url.PathEscape(runID)+"/events", nil)
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	received := 0
+	var id, eventType string
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "id":
+				id = value
+			case "event":
+				eventType = value
+			case "data":
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(value)
+			}
+			continue
+		}
+
+		// a blank line dispatches the event read since the last one
+		switch {
+		case eventType == "end":
+			return received, true, nil
+		case data.Len() > 0:
+			var event pkgEvents.ExecutionEvent
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return received, false, fmt.Errorf("invalid event: %w", err)
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return received, false, ctx.Err()
+			}
+			received++
+			if id != "" {
+				*lastEventID = id
+			}
+		}
+		id, eventType = "", ""
+		data.Reset()
+	}
+
+	return received, false, scanner.Err()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body any, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", c.baseURL, err)
+	}
+	return nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	return req, nil
+}
+
+// StatusError is a request the server refused or failed.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server responded %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// InvalidWorkflowError is a submitted workflow the server found invalid.
+type InvalidWorkflowError struct {
+	Diagnostics []parser.Diagnostic
+}
+
+func (e *InvalidWorkflowError) Error() string {
+	messages := make([]string, 0, len(e.Diagnostics))
+	for _, diagnostic := range e.Diagnostics {
+		messages = append(messages, fmt.Sprintf("%s:%d:%d: %s", diagnostic.File, diagnostic.Line, diagnostic.Column, diagnostic.Message))
+	}
+	return "invalid workflow: " + strings.Join(messages, "; ")
+}
+
+// responseError returns the error of a response which isn't successful.
+// Invalid inputs are returned as an *engine.InputValidationResult, as a
+// local run returns them, and invalid workflows as an
+// *InvalidWorkflowError.
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+
+	var response struct {
+		Error       string                         `json:"error"`
+		Details     []*engine.InputValidationError `json:"details"`
+		Diagnostics []parser.Diagnostic            `json:"diagnostics"`
+	}
+	if json.Unmarshal(body, &response) == nil {
+		switch {
+		case len(response.Details) > 0:
+			return &engine.InputValidationResult{Valid: false, Errors: response.Details}
+		case len(response.Diagnostics) > 0:
+			return &InvalidWorkflowError{Diagnostics: response.Diagnostics}
+		case response.Error != "":
+			return &StatusError{StatusCode: resp.StatusCode, Message: response.Error}
+		}
+	}
+
+	return &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
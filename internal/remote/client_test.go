@@ -0,0 +1,143 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(server.URL+"/", "secret", server.Client())
+	require.NoError(t, err)
+	return client
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestNewClient(t *testing.T) {
+	for _, url := range []string{"", "localhost:8080", "ftp://runner", "http://"} {
+		_, err := NewClient(url, "", nil)
+		assert.Error(t, err, url)
+	}
+
+	client, err := NewClient("https://runner:8080/", "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://runner:8080", client.Server())
+}
+
+func TestClient_Submit(t *testing.T) {
+	var body map[string]any
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/executions", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		writeJSON(w, http.StatusAccepted, map[string]any{"run_id": "run_1", "workflow_id": "greet", "status": "running"})
+	}))
+
+	started, err := client.Submit(context.Background(), "/home/me/greet.laq.yaml", []byte("version: \"1.0\""), map[string]any{"name": "ada"})
+	require.NoError(t, err)
+	assert.Equal(t, "run_1", started.RunID)
+	assert.Equal(t, "greet", started.WorkflowID)
+	assert.Equal(t, map[string]any{
+		"workflow": "version: \"1.0\"",
+		"filename": "greet.laq.yaml",
+		"inputs":   map[string]any{"name": "ada"},
+	}, body)
+}
+
+func TestClient_Errors(t *testing.T) {
+	responses := map[string]struct {
+		status int
+		body   any
+	}{
+		"inputs":   {http.StatusBadRequest, map[string]any{"error": "Input validation failed", "details": []map[string]any{{"field": "name", "message": "required"}}}},
+		"invalid":  {http.StatusBadRequest, map[string]any{"error": "Invalid workflow", "diagnostics": []map[string]any{{"file": "greet.laq.yaml", "line": 3, "column": 5, "message": "unknown agent"}}}},
+		"missing":  {http.StatusNotFound, map[string]any{"error": "Workflow not found"}},
+		"internal": {http.StatusInternalServerError, "oops"},
+	}
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := responses[r.URL.Path[len("/api/v1/workflows/"):len(r.URL.Path)-len("/execute")]]
+		writeJSON(w, response.status, response.body)
+	}))
+
+	_, err := client.Execute(context.Background(), "inputs", nil)
+	var inputErr *engine.InputValidationResult
+	require.ErrorAs(t, err, &inputErr)
+	require.Len(t, inputErr.Errors, 1)
+	assert.Equal(t, "name", inputErr.Errors[0].Field)
+
+	_, err = client.Execute(context.Background(), "invalid", nil)
+	var workflowErr *InvalidWorkflowError
+	require.ErrorAs(t, err, &workflowErr)
+	assert.EqualError(t, err, "invalid workflow: greet.laq.yaml:3:5: unknown agent")
+
+	_, err = client.Execute(context.Background(), "missing", nil)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.Equal(t, "Workflow not found", statusErr.Message)
+
+	_, err = client.Execute(context.Background(), "internal", nil)
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, `"oops"`, statusErr.Message)
+}
+
+func TestClient_Events(t *testing.T) {
+	var lastEventIDs []string
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/executions/run_unknown/events" {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "Execution not found"})
+			return
+		}
+
+		assert.Equal(t, "/api/v1/executions/run_1/events", r.URL.Path)
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "retry: 1000\n\n")
+		if r.Header.Get("Last-Event-ID") == "" {
+			// the first connection is interrupted after the first event
+			_, _ = fmt.Fprint(w, "id: 1\ndata: {\"type\":\"workflow_started\",\"run_id\":\"run_1\"}\n\n")
+			_, _ = fmt.Fprint(w, ": keepalive\n\n")
+			return
+		}
+		_, _ = fmt.Fprint(w, "id: 2\ndata: {\"type\":\"step_completed\",\"run_id\":\"run_1\",\"step_id\":\"greet\"}\n\n")
+		_, _ = fmt.Fprint(w, "event: end\ndata: {}\n\n")
+	}))
+
+	events := make(chan pkgEvents.ExecutionEvent, 10)
+	require.NoError(t, client.Events(context.Background(), "run_1", events))
+
+	var received []pkgEvents.ExecutionEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	require.Len(t, received, 2)
+	assert.Equal(t, pkgEvents.EventWorkflowStarted, received[0].Type)
+	assert.Equal(t, pkgEvents.EventStepCompleted, received[1].Type)
+	assert.Equal(t, "greet", received[1].StepID)
+	assert.Equal(t, []string{"", "1"}, lastEventIDs)
+
+	err := client.Events(context.Background(), "run_unknown", make(chan pkgEvents.ExecutionEvent))
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
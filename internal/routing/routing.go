@@ -0,0 +1,198 @@
+// Package routing chooses a concrete model for agents which specify a
+// capability tier rather than a model, using a table of model pricing and
+// latency along with the remaining budget of the run.
+package routing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Tier is a capability tier an agent can request instead of a model.
+type Tier string
+
+const (
+	// TierFast prefers the lowest latency models.
+	TierFast Tier = "fast"
+	// TierBalanced balances capability against cost.
+	TierBalanced Tier = "balanced"
+	// TierBest prefers the most capable models.
+	TierBest Tier = "best"
+)
+
+// Tiers lists every tier from the most to the least capable.
+var Tiers = []Tier{TierBest, TierBalanced, TierFast}
+
+// Model is an entry of the routing table.
+type Model struct {
+	Provider string `json:"provider" yaml:"provider" mapstructure:"provider"`
+	Model    string `json:"model" yaml:"model" mapstructure:"model"`
+	Tier     Tier   `json:"tier" yaml:"tier" mapstructure:"tier"`
+	// InputPrice is the price in USD per million prompt tokens.
+	InputPrice float64 `json:"input_price" yaml:"input_price" mapstructure:"input_price"`
+	// OutputPrice is the price in USD per million completion tokens.
+	OutputPrice float64 `json:"output_price" yaml:"output_price" mapstructure:"output_price"`
+	// Latency is the typical time to the first response.
+	Latency time.Duration `json:"latency" yaml:"latency" mapstructure:"latency"`
+	// ContextWindow is the number of tokens the model accepts in a request,
+	// zero when it isn't known.
+	ContextWindow int `json:"context_window,omitempty" yaml:"context_window,omitempty" mapstructure:"context_window"`
+}
+
+// Cost returns the estimated cost in USD of a request to the model.
+func (m Model) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*m.InputPrice + float64(completionTokens)*m.OutputPrice) / 1_000_000
+}
+
+// DefaultModels returns the built-in routing table.
+func DefaultModels() []Model {
+	return []Model{
+		{Provider: "anthropic", Model: "claude-3-5-haiku-20241022", Tier: TierFast, InputPrice: 0.8, OutputPrice: 4, Latency: 700 * time.Millisecond, ContextWindow: 200_000},
+		{Provider: "anthropic", Model: "claude-sonnet-4-20250514", Tier: TierBalanced, InputPrice: 3, OutputPrice: 15, Latency: 1500 * time.Millisecond, ContextWindow: 200_000},
+		{Provider: "anthropic", Model: "claude-opus-4-20250514", Tier: TierBest, InputPrice: 15, OutputPrice: 75, Latency: 3 * time.Second, ContextWindow: 200_000},
+		{Provider: "openai", Model: "gpt-4o-mini", Tier: TierFast, InputPrice: 0.15, OutputPrice: 0.6, Latency: 500 * time.Millisecond, ContextWindow: 128_000},
+		{Provider: "openai", Model: "gpt-4.1-mini", Tier: TierBalanced, InputPrice: 0.4, OutputPrice: 1.6, Latency: 900 * time.Millisecond, ContextWindow: 1_047_576},
+		{Provider: "openai", Model: "gpt-4.1", Tier: TierBest, InputPrice: 2, OutputPrice: 8, Latency: 1500 * time.Millisecond, ContextWindow: 1_047_576},
+	}
+}
+
+// Decision records which model was chosen for a step and why.
+type Decision struct {
+	Tier          Tier    `json:"tier" yaml:"tier"`
+	Provider      string  `json:"provider" yaml:"provider"`
+	Model         string  `json:"model" yaml:"model"`
+	Reason        string  `json:"reason" yaml:"reason"`
+	EstimatedCost float64 `json:"estimated_cost" yaml:"estimated_cost"`
+}
+
+// Request describes the request being routed.
+type Request struct {
+	Provider string
+	Tier     Tier
+	// PromptTokens and CompletionTokens are the expected size of the request,
+	// used to estimate its cost.
+	PromptTokens     int
+	CompletionTokens int
+	// RemainingBudget is the budget left for the run in USD, nil when the
+	// run has no budget.
+	RemainingBudget *float64
+}
+
+// Router chooses models from a routing table.
+type Router struct {
+	models []Model
+}
+
+// NewRouter creates a router for the given routing table. Later entries
+// for the same provider and model replace earlier ones, so configured
+// models can be appended to the default table to override it.
+func NewRouter(models []Model) *Router {
+	index := make(map[string]int)
+	var table []Model
+	for _, m := range models {
+		key := m.Provider + "/" + m.Model
+		if i, ok := index[key]; ok {
+			table[i] = m
+			continue
+		}
+		index[key] = len(table)
+		table = append(table, m)
+	}
+
+	return &Router{models: table}
+}
+
+// Lookup returns the routing table entry for a provider and model.
+func (r *Router) Lookup(provider, model string) (Model, bool) {
+	for _, m := range r.models {
+		if m.Provider == provider && m.Model == model {
+			return m, true
+		}
+	}
+
+	return Model{}, false
+}
+
+// Route chooses a model for the request. The fast tier chooses the lowest
+// latency model of the tier and other tiers the cheapest. When the estimated
+// cost of the request exceeds the remaining budget progressively less
+// capable tiers are tried, falling back to the cheapest model of the
+// provider if nothing fits.
+func (r *Router) Route(req Request) (Decision, error) {
+	start := -1
+	for i, tier := range Tiers {
+		if tier == req.Tier {
+			start = i
+		}
+	}
+	if start < 0 {
+		return Decision{}, fmt.Errorf("unknown tier %q, must be one of fast, balanced or best", req.Tier)
+	}
+
+	requested, ok := r.choose(req.Provider, req.Tier)
+	if !ok {
+		return Decision{}, fmt.Errorf("no models configured for tier %q with provider %s", req.Tier, req.Provider)
+	}
+
+	decision := r.decide(req, requested, fmt.Sprintf("%s tier model", req.Tier))
+	if req.RemainingBudget == nil || decision.EstimatedCost <= *req.RemainingBudget {
+		return decision, nil
+	}
+
+	for _, tier := range Tiers[start+1:] {
+		if m, ok := r.choose(req.Provider, tier); ok {
+			d := r.decide(req, m, fmt.Sprintf("downgraded from %s tier, estimated cost exceeds remaining budget of $%.4f", req.Tier, *req.RemainingBudget))
+			if d.EstimatedCost <= *req.RemainingBudget {
+				return d, nil
+			}
+		}
+	}
+
+	cheapest := r.candidates(req.Provider, "")
+	sort.SliceStable(cheapest, func(i, j int) bool {
+		return cheapest[i].Cost(req.PromptTokens, req.CompletionTokens) < cheapest[j].Cost(req.PromptTokens, req.CompletionTokens)
+	})
+
+	return r.decide(req, cheapest[0], fmt.Sprintf("cheapest model, no model fits the remaining budget of $%.4f", *req.RemainingBudget)), nil
+}
+
+// choose returns the preferred model of the tier for the provider.
+func (r *Router) choose(provider string, tier Tier) (Model, bool) {
+	candidates := r.candidates(provider, tier)
+	if len(candidates) == 0 {
+		return Model{}, false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if tier == TierFast {
+			return candidates[i].Latency < candidates[j].Latency
+		}
+		return candidates[i].InputPrice+candidates[i].OutputPrice < candidates[j].InputPrice+candidates[j].OutputPrice
+	})
+
+	return candidates[0], true
+}
+
+// candidates returns the models of the provider in the tier, or in every
+// tier if tier is empty.
+func (r *Router) candidates(provider string, tier Tier) []Model {
+	var models []Model
+	for _, m := range r.models {
+		if m.Provider == provider && (tier == "" || m.Tier == tier) {
+			models = append(models, m)
+		}
+	}
+
+	return models
+}
+
+func (r *Router) decide(req Request, m Model, reason string) Decision {
+	return Decision{
+		Tier:          req.Tier,
+		Provider:      m.Provider,
+		Model:         m.Model,
+		Reason:        reason,
+		EstimatedCost: m.Cost(req.PromptTokens, req.CompletionTokens),
+	}
+}
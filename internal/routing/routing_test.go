@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func budget(v float64) *float64 {
+	return &v
+}
+
+func TestRouter_Route(t *testing.T) {
+	router := NewRouter(DefaultModels())
+
+	tests := []struct {
+		name   string
+		req    Request
+		model  string
+		reason string
+	}{
+		{
+			name:   "best tier without budget",
+			req:    Request{Provider: "anthropic", Tier: TierBest, PromptTokens: 1000, CompletionTokens: 1000},
+			model:  "claude-opus-4-20250514",
+			reason: "best tier model",
+		},
+		{
+			name:  "fast tier",
+			req:   Request{Provider: "openai", Tier: TierFast, PromptTokens: 1000, CompletionTokens: 1000},
+			model: "gpt-4o-mini",
+		},
+		{
+			name:  "budget covers requested tier",
+			req:   Request{Provider: "anthropic", Tier: TierBest, PromptTokens: 1000, CompletionTokens: 1000, RemainingBudget: budget(1)},
+			model: "claude-opus-4-20250514",
+		},
+		{
+			name:   "downgrades when over budget",
+			req:    Request{Provider: "anthropic", Tier: TierBest, PromptTokens: 1000, CompletionTokens: 1000, RemainingBudget: budget(0.05)},
+			model:  "claude-sonnet-4-20250514",
+			reason: "downgraded from best tier, estimated cost exceeds remaining budget of $0.0500",
+		},
+		{
+			name:   "falls back to cheapest when nothing fits",
+			req:    Request{Provider: "anthropic", Tier: TierBalanced, PromptTokens: 1000, CompletionTokens: 1000, RemainingBudget: budget(0)},
+			model:  "claude-3-5-haiku-20241022",
+			reason: "cheapest model, no model fits the remaining budget of $0.0000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := router.Route(tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.model, decision.Model)
+			assert.Equal(t, tt.req.Tier, decision.Tier)
+			if tt.reason != "" {
+				assert.Equal(t, tt.reason, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestRouter_RouteErrors(t *testing.T) {
+	router := NewRouter(DefaultModels())
+
+	_, err := router.Route(Request{Provider: "anthropic", Tier: "cheap"})
+	assert.ErrorContains(t, err, `unknown tier "cheap"`)
+
+	_, err = router.Route(Request{Provider: "local", Tier: TierFast})
+	assert.ErrorContains(t, err, "no models configured")
+}
+
+func TestNewRouter_OverridesDefaults(t *testing.T) {
+	router := NewRouter(append(DefaultModels(),
+		Model{Provider: "openai", Model: "gpt-4.1", Tier: TierFast, InputPrice: 2, OutputPrice: 8, Latency: 100 * time.Millisecond},
+	))
+
+	decision, err := router.Route(Request{Provider: "openai", Tier: TierFast})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4.1", decision.Model)
+
+	_, err = router.Route(Request{Provider: "openai", Tier: TierBest})
+	assert.Error(t, err)
+}
+
+func TestModel_Cost(t *testing.T) {
+	m := Model{InputPrice: 3, OutputPrice: 15}
+	assert.InDelta(t, 0.018, m.Cost(1000, 1000), 1e-9)
+}
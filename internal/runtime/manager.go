@@ -69,6 +69,23 @@ func (m *Manager) GetLatest(ctx context.Context, runtime string) (string, error)
 	return r.GetLatest(ctx)
 }
 
+// LatestVersion returns the version of a runtime GetLatest installs, the
+// latest stable version
+func (m *Manager) LatestVersion(ctx context.Context, runtime string) (string, error) {
+	versions, err := m.List(ctx, runtime)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range versions {
+		if v.Stable {
+			return v.Version, nil
+		}
+	}
+
+	return "", fmt.Errorf("no stable version of %s found", runtime)
+}
+
 // List returns available versions for a runtime
 func (m *Manager) List(ctx context.Context, runtime string) ([]types.Version, error) {
 	r, err := m.getRuntime(runtime)
@@ -126,12 +143,13 @@ type RuntimeInfo struct {
 	Path    string
 }
 
-// GetInstalled returns all installed runtime versions
-func (m *Manager) GetInstalled() ([]RuntimeInfo, error) {
+// GetInstalled returns all installed runtime versions, the versions of each
+// runtime are listed with ctx
+func (m *Manager) GetInstalled(ctx context.Context) ([]RuntimeInfo, error) {
 	var infos []RuntimeInfo
 
 	for _, runtimeName := range m.ListRuntimes() {
-		versions, err := m.List(context.Background(), runtimeName)
+		versions, err := m.List(ctx, runtimeName)
 		if err != nil {
 			continue
 		}
@@ -373,7 +373,7 @@ func TestManager(t *testing.T) {
 			t.Fatalf("Failed to download: %v", err)
 		}
 
-		installed, err := manager.GetInstalled()
+		installed, err := manager.GetInstalled(ctx)
 		if err != nil {
 			t.Fatalf("Failed to get installed: %v", err)
 		}
@@ -470,7 +470,7 @@ func TestCachePersistence(t *testing.T) {
 	}
 
 	// Check installed runtimes
-	installed, err := manager2.GetInstalled()
+	installed, err := manager2.GetInstalled(ctx)
 	if err != nil {
 		t.Fatalf("Failed to get installed: %v", err)
 	}
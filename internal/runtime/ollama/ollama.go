@@ -0,0 +1,370 @@
+// runtime/ollama/ollama.go
+
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultHost is the address the Ollama server listens on by default.
+	DefaultHost = "http://127.0.0.1:11434"
+
+	defaultStartTimeout = 30 * time.Second
+	pingTimeout         = 2 * time.Second
+)
+
+// Client manages models on an Ollama server, starting the server when it
+// isn't running.
+type Client struct {
+	host         string
+	http         *http.Client
+	binary       string
+	startTimeout time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to talk to the server
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithBinary sets the ollama binary used to start the server, by default it
+// is looked up in PATH
+func WithBinary(path string) Option {
+	return func(c *Client) {
+		c.binary = path
+	}
+}
+
+// WithStartTimeout sets how long to wait for a started server to be ready
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.startTimeout = timeout
+	}
+}
+
+// NewClient creates a client for the server at host, when host is empty the
+// OLLAMA_HOST environment variable or the default host is used.
+func NewClient(host string, opts ...Option) *Client {
+	if host == "" {
+		host = HostFromEnv()
+	}
+
+	c := &Client{
+		host:         strings.TrimSuffix(normalizeHost(host), "/"),
+		http:         &http.Client{},
+		binary:       "ollama",
+		startTimeout: defaultStartTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// HostFromEnv returns the server address configured by OLLAMA_HOST, the same
+// variable the ollama CLI uses.
+func HostFromEnv() string {
+	if host := os.Getenv("OLLAMA_HOST"); host != "" {
+		return normalizeHost(host)
+	}
+
+	return DefaultHost
+}
+
+// normalizeHost turns the host:port form accepted by ollama into a URL.
+func normalizeHost(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	return host
+}
+
+// Session holds the state of a prepared run so that it can be cleaned up
+// afterwards.
+type Session struct {
+	client *Client
+	server *exec.Cmd
+
+	// models are the required models which weren't loaded into memory
+	// before the run, they're unloaded when the session is closed.
+	models []string
+}
+
+// Prepare makes sure the server is running and the models are available,
+// pulling any that are missing.
+func (c *Client) Prepare(ctx context.Context, models []string) (*Session, error) {
+	session := &Session{client: c}
+
+	if err := c.ping(ctx); err != nil {
+		server, startErr := c.start(ctx)
+		if startErr != nil {
+			return nil, fmt.Errorf("ollama server at %s is not reachable (%s) and could not be started: %w", c.host, err, startErr)
+		}
+		session.server = server
+	}
+
+	loaded, err := c.Running(ctx)
+	if err != nil {
+		_ = session.Close(ctx)
+		return nil, err
+	}
+
+	for _, model := range models {
+		available, err := c.HasModel(ctx, model)
+		if err != nil {
+			_ = session.Close(ctx)
+			return nil, err
+		}
+
+		if !available {
+			log.Info().Str("model", model).Msg("Pulling ollama model")
+			if err := c.Pull(ctx, model); err != nil {
+				_ = session.Close(ctx)
+				return nil, fmt.Errorf("failed to pull ollama model %s: %w", model, err)
+			}
+
+			if available, err = c.HasModel(ctx, model); err != nil || !available {
+				_ = session.Close(ctx)
+				return nil, fmt.Errorf("ollama model %s is not available after pulling it", model)
+			}
+		}
+
+		if !loaded[model] && !loaded[model+":latest"] {
+			session.models = append(session.models, model)
+		}
+	}
+
+	return session, nil
+}
+
+// Close unloads the models the run loaded into memory and stops the server
+// when it was started for the run.
+func (s *Session) Close(ctx context.Context) error {
+	var errs []error
+	if s.server == nil {
+		for _, model := range s.models {
+			if err := s.client.Unload(ctx, model); err != nil {
+				errs = append(errs, fmt.Errorf("failed to unload ollama model %s: %w", model, err))
+			}
+		}
+	} else {
+		// stopping the server frees every model it loaded
+		errs = append(errs, stopServer(s.server))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Version returns the version of the server
+func (c *Client) Version(ctx context.Context) (string, error) {
+	var resp struct {
+		Version string `json:"version"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/version", nil, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.Version, nil
+}
+
+// HasModel reports whether the model has been pulled
+func (c *Client) HasModel(ctx context.Context, model string) (bool, error) {
+	err := c.do(ctx, http.MethodPost, "/api/show", map[string]any{"model": model}, nil)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Pull downloads the model, waiting for the download to complete
+func (c *Client) Pull(ctx context.Context, model string) error {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/pull", map[string]any{"model": model, "stream": false}, &resp); err != nil {
+		return err
+	}
+
+	if resp.Status != "success" {
+		return fmt.Errorf("unexpected pull status %q", resp.Status)
+	}
+
+	return nil
+}
+
+// Running returns the models currently loaded into memory
+func (c *Client) Running(ctx context.Context) (map[string]bool, error) {
+	var resp struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/ps", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	running := make(map[string]bool, len(resp.Models))
+	for _, model := range resp.Models {
+		running[model.Name] = true
+	}
+
+	return running, nil
+}
+
+// Unload frees the memory used by a loaded model
+func (c *Client) Unload(ctx context.Context, model string) error {
+	return c.do(ctx, http.MethodPost, "/api/generate", map[string]any{"model": model, "keep_alive": 0}, nil)
+}
+
+func (c *Client) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	_, err := c.Version(ctx)
+	return err
+}
+
+// start runs `ollama serve` and waits for the server to be ready.
+func (c *Client) start(ctx context.Context) (*exec.Cmd, error) {
+	binary, err := exec.LookPath(c.binary)
+	if err != nil {
+		return nil, fmt.Errorf("ollama is not installed, see https://ollama.com/download")
+	}
+
+	u, err := url.Parse(c.host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ollama host %s: %w", c.host, err)
+	}
+
+	// the server outlives the request context, it is stopped by Session.Close
+	cmd := exec.Command(binary, "serve") // #nosec G204 - binary is the configured ollama binary
+	cmd.Env = append(os.Environ(), "OLLAMA_HOST="+u.Host)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ollama: %w", err)
+	}
+
+	log.Info().Str("host", c.host).Msg("Started ollama server")
+
+	deadline := time.Now().Add(c.startTimeout)
+	for time.Now().Before(deadline) {
+		if err := c.ping(ctx); err == nil {
+			return cmd, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = stopServer(cmd)
+			return nil, ctx.Err()
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+
+	_ = stopServer(cmd)
+	return nil, fmt.Errorf("ollama server did not start within %s", c.startTimeout)
+}
+
+func stopServer(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		_ = cmd.Process.Kill()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		_ = cmd.Process.Kill()
+		<-done
+	}
+
+	return nil
+}
+
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+
+	return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return &statusError{code: resp.StatusCode, message: apiErr.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,109 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeServer struct {
+	mu       sync.Mutex
+	models   map[string]bool
+	running  []string
+	pulled   []string
+	unloaded []string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	model, _ := body["model"].(string)
+
+	switch r.URL.Path {
+	case "/api/version":
+		_ = json.NewEncoder(w).Encode(map[string]string{"version": "0.5.0"})
+	case "/api/ps":
+		models := []map[string]string{}
+		for _, name := range f.running {
+			models = append(models, map[string]string{"name": name})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"models": models})
+	case "/api/show":
+		if !f.models[model] {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "model '" + model + "' not found"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{})
+	case "/api/pull":
+		if model == "missing" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "pull model manifest: file does not exist"})
+			return
+		}
+		f.models[model] = true
+		f.pulled = append(f.pulled, model)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	case "/api/generate":
+		f.unloaded = append(f.unloaded, model)
+		_ = json.NewEncoder(w).Encode(map[string]any{"done": true})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient_Prepare(t *testing.T) {
+	fake := &fakeServer{
+		models:  map[string]bool{"llama3.1": true, "mistral": true},
+		running: []string{"mistral:latest"},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	session, err := client.Prepare(context.Background(), []string{"llama3.1", "mistral", "qwen2.5"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"qwen2.5"}, fake.pulled)
+
+	// models that were already loaded are left alone
+	require.NoError(t, session.Close(context.Background()))
+	assert.Equal(t, []string{"llama3.1", "qwen2.5"}, fake.unloaded)
+}
+
+func TestClient_PreparePullFails(t *testing.T) {
+	server := httptest.NewServer(&fakeServer{models: map[string]bool{}})
+	defer server.Close()
+
+	_, err := NewClient(server.URL).Prepare(context.Background(), []string{"missing"})
+	assert.EqualError(t, err, "failed to pull ollama model missing: pull model manifest: file does not exist")
+}
+
+func TestClient_PrepareNotInstalled(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := NewClient(server.URL, WithBinary("laq-test-missing-ollama"))
+	_, err := client.Prepare(context.Background(), []string{"llama3.1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ollama is not installed")
+}
+
+func TestHostFromEnv(t *testing.T) {
+	t.Setenv("OLLAMA_HOST", "")
+	assert.Equal(t, DefaultHost, HostFromEnv())
+
+	t.Setenv("OLLAMA_HOST", "0.0.0.0:8080")
+	assert.Equal(t, "http://0.0.0.0:8080", HostFromEnv())
+
+	t.Setenv("OLLAMA_HOST", "https://ollama.internal")
+	assert.Equal(t, "https://ollama.internal", HostFromEnv())
+}
@@ -0,0 +1,347 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate checks a value decoded from JSON against the schema and returns a
+// description of every violation, or nil when the value is valid. It covers
+// the keywords used by step outputs and tool parameters: type, enum, const,
+// string length and pattern, numeric bounds, object properties, arrays and
+// the allOf, anyOf and oneOf combinators. Numeric bounds of zero are ignored
+// as they can't be told apart from unset ones.
+func (s JSON) Validate(value interface{}) []string {
+	var violations []string
+	s.validate("", value, &violations)
+
+	return violations
+}
+
+func (s JSON) validate(path string, value interface{}, violations *[]string) {
+	report := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		if path != "" {
+			message = path + ": " + message
+		}
+		*violations = append(*violations, message)
+	}
+
+	if types := s.types(); len(types) > 0 && !matchesAnyType(value, types) {
+		report("expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if equalJSON(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("must be one of %s", formatJSON(s.Enum))
+		}
+	}
+
+	if s.Const != nil && !equalJSON(value, s.Const) {
+		report("must be %s", formatJSON(s.Const))
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength > 0 && length < s.MinLength {
+			report("must be at least %d characters long", s.MinLength)
+		}
+		if s.MaxLength > 0 && length > s.MaxLength {
+			report("must be at most %d characters long", s.MaxLength)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				report("must match the pattern %s", s.Pattern)
+			}
+		}
+	case map[string]interface{}:
+		s.validateObject(path, v, report, violations)
+	case []interface{}:
+		s.validateArray(path, v, report, violations)
+	default:
+		if n, ok := toFloat(value); ok {
+			s.validateNumber(n, report)
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		sub.validate(path, value, violations)
+	}
+
+	if len(s.AnyOf) > 0 && countMatches(s.AnyOf, value) == 0 {
+		report("must match at least one of the allowed schemas")
+	}
+
+	if len(s.OneOf) > 0 && countMatches(s.OneOf, value) != 1 {
+		report("must match exactly one of the allowed schemas")
+	}
+}
+
+func (s JSON) validateNumber(n float64, report func(string, ...interface{})) {
+	if s.Minimum != 0 && n < s.Minimum {
+		report("must be at least %v", s.Minimum)
+	}
+	if s.Maximum != 0 && n > s.Maximum {
+		report("must be at most %v", s.Maximum)
+	}
+	if s.ExclusiveMinimum != 0 && n <= s.ExclusiveMinimum {
+		report("must be greater than %v", s.ExclusiveMinimum)
+	}
+	if s.ExclusiveMaximum != 0 && n >= s.ExclusiveMaximum {
+		report("must be less than %v", s.ExclusiveMaximum)
+	}
+	if s.MultipleOf != 0 {
+		if quotient := n / s.MultipleOf; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			report("must be a multiple of %v", s.MultipleOf)
+		}
+	}
+}
+
+func (s JSON) validateObject(path string, v map[string]interface{}, report func(string, ...interface{}), violations *[]string) {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			report("missing required property %s", name)
+		}
+	}
+
+	if s.MinProperties > 0 && len(v) < s.MinProperties {
+		report("must have at least %d properties", s.MinProperties)
+	}
+	if s.MaxProperties > 0 && len(v) > s.MaxProperties {
+		report("must have at most %d properties", s.MaxProperties)
+	}
+
+	additional, _ := subSchema(s.AdditionalProperties)
+
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		switch {
+		case ok:
+			property.validate(joinPath(path, name), v[name], violations)
+		case s.AdditionalProperties == false:
+			report("unexpected property %s", name)
+		case additional != nil:
+			additional.validate(joinPath(path, name), v[name], violations)
+		}
+	}
+}
+
+func (s JSON) validateArray(path string, v []interface{}, report func(string, ...interface{}), violations *[]string) {
+	if s.MinItems > 0 && len(v) < s.MinItems {
+		report("must have at least %d items", s.MinItems)
+	}
+	if s.MaxItems > 0 && len(v) > s.MaxItems {
+		report("must have at most %d items", s.MaxItems)
+	}
+
+	if s.UniqueItems {
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if equalJSON(v[i], v[j]) {
+					report("items %d and %d must not be equal", i, j)
+				}
+			}
+		}
+	}
+
+	items, _ := subSchema(s.Items)
+	for i, item := range v {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i < len(s.PrefixItems):
+			s.PrefixItems[i].validate(itemPath, item, violations)
+		case items != nil:
+			items.validate(itemPath, item, violations)
+		}
+	}
+}
+
+// types returns the types allowed by the schema, which may be a single type
+// or a list of types.
+func (s JSON) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+
+	return nil
+}
+
+// subSchema converts the value of keywords such as items, which may be a
+// JSON, a decoded map or a boolean, to a JSON.
+func subSchema(value interface{}) (*JSON, bool) {
+	switch v := value.(type) {
+	case JSON:
+		return &v, true
+	case *JSON:
+		return v, v != nil
+	case map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
+		}
+		var s JSON
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, false
+		}
+		return &s, true
+	}
+
+	return nil, false
+}
+
+func countMatches(schemas []JSON, value interface{}) int {
+	matches := 0
+	for _, s := range schemas {
+		if len(s.Validate(value)) == 0 {
+			matches++
+		}
+	}
+
+	return matches
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		if matchesType(value, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesType(value interface{}, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		n, ok := toFloat(value)
+		return ok && n == math.Trunc(n)
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+
+	// unknown types are accepted rather than rejecting every value
+	return true
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	if n, ok := toFloat(value); ok {
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// equalJSON compares two values by their JSON encoding so that, for example,
+// the integer 1 from YAML equals the number 1 decoded from JSON.
+func equalJSON(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+
+	return formatJSON(a) == formatJSON(b)
+}
+
+func formatJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
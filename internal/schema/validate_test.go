@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON_Validate(t *testing.T) {
+	invoice := JSON{
+		Type: "object",
+		Properties: map[string]JSON{
+			"vendor":   {Type: "string", MinLength: 2},
+			"total":    {Type: "number", Minimum: 0.01},
+			"currency": {Type: "string", Enum: []interface{}{"EUR", "USD"}},
+			"paid":     {Type: []interface{}{"boolean", "null"}},
+			"lines": {
+				Type:     "array",
+				MaxItems: 2,
+				Items: map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"sku"},
+					"properties": map[string]interface{}{
+						"sku": map[string]interface{}{"type": "string", "pattern": "^[A-Z]+-[0-9]+$"},
+						"qty": map[string]interface{}{"type": "integer"},
+					},
+				},
+			},
+		},
+		Required:             []string{"vendor", "total"},
+		AdditionalProperties: false,
+	}
+
+	decode := func(s string) interface{} {
+		var v interface{}
+		require.NoError(t, json.Unmarshal([]byte(s), &v))
+		return v
+	}
+
+	assert.Empty(t, invoice.Validate(decode(`{"vendor":"ACME","total":12.5,"currency":"EUR","paid":null,"lines":[{"sku":"AB-1","qty":2}]}`)))
+
+	assert.Equal(t, []string{
+		"missing required property total",
+		"currency: must be one of [\"EUR\",\"USD\"]",
+		"lines: must have at most 2 items",
+		"lines[0]: missing required property sku",
+		"lines[1].qty: expected integer, got number",
+		"lines[1].sku: must match the pattern ^[A-Z]+-[0-9]+$",
+		"unexpected property note",
+		"paid: expected boolean or null, got string",
+		"vendor: must be at least 2 characters long",
+	}, invoice.Validate(decode(`{"vendor":"A","currency":"GBP","paid":"yes","note":"x","lines":[{},{"sku":"ab","qty":1.5},{"sku":"C-3"}]}`)))
+
+	assert.Equal(t, []string{"expected object, got array"}, invoice.Validate(decode(`[]`)))
+}
+
+func TestJSON_ValidateCombinators(t *testing.T) {
+	s := JSON{AnyOf: []JSON{{Type: "string"}, {Type: "integer"}}}
+	assert.Empty(t, s.Validate(3.0))
+	assert.Equal(t, []string{"must match at least one of the allowed schemas"}, s.Validate(true))
+
+	s = JSON{OneOf: []JSON{{Type: "number"}, {Type: "integer"}}}
+	assert.Empty(t, s.Validate(1.5))
+	assert.Equal(t, []string{"must match exactly one of the allowed schemas"}, s.Validate(1.0))
+
+	s = JSON{Type: "array", UniqueItems: true, Items: JSON{Const: "a"}}
+	assert.Equal(t, []string{"items 0 and 1 must not be equal", "[2]: must be \"a\""}, s.Validate([]interface{}{"a", "a", "b"}))
+}
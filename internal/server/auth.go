@@ -0,0 +1,283 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Scope is a permission granted to an API key or bearer token.
+type Scope string
+
+const (
+	// ScopeRead allows listing workflows and reading executions
+	ScopeRead Scope = "read"
+	// ScopeExecute allows executing workflows
+	ScopeExecute Scope = "execute"
+	// ScopeAdmin allows everything, including reloading workflows
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes lists every scope.
+var Scopes = []Scope{ScopeRead, ScopeExecute, ScopeAdmin}
+
+// Auth protects the API of the server with API keys and bearer tokens. The
+// API is open when neither is configured.
+type Auth struct {
+	// APIKeys are the static keys clients may authenticate with.
+	APIKeys []APIKey `yaml:"keys"`
+	// TokenSecret verifies bearer tokens, JWTs signed with HS256 whose
+	// scope claim lists their scopes separated by spaces, as in OAuth 2.
+	TokenSecret string `yaml:"-"`
+}
+
+// Enabled reports whether the API requires authentication.
+func (a Auth) Enabled() bool {
+	return len(a.APIKeys) > 0 || a.TokenSecret != ""
+}
+
+// APIKey is a static key granting its scopes.
+type APIKey struct {
+	// Name identifies the key in logs
+	Name   string  `yaml:"name"`
+	Key    string  `yaml:"key"`
+	Scopes []Scope `yaml:"scopes"`
+}
+
+// Principal is the client a request was authenticated as.
+type Principal struct {
+	// Name is the name of the API key or the subject of the bearer token
+	Name   string
+	Scopes []Scope
+}
+
+// Allows reports whether the principal has the scope.
+func (p *Principal) Allows(scope Scope) bool {
+	return slices.Contains(p.Scopes, ScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// LoadAPIKeys reads API keys from a YAML file, where environment variables in
+// the keys are expanded:
+//
+//	keys:
+//	  - name: ci
+//	    key: ${LACQUER_CI_KEY}
+//	    scopes: [execute, read]
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - path is configured by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+
+	var auth Auth
+	if err := yaml.Unmarshal(data, &auth); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys %s: %w", path, err)
+	}
+
+	for i := range auth.APIKeys {
+		key := &auth.APIKeys[i]
+		key.Key = os.ExpandEnv(key.Key)
+		if err := key.validate(); err != nil {
+			return nil, fmt.Errorf("invalid API key %d in %s: %w", i+1, path, err)
+		}
+	}
+
+	return auth.APIKeys, nil
+}
+
+func (k APIKey) validate() error {
+	if k.Name == "" {
+		return errors.New("name is required")
+	}
+	if k.Key == "" {
+		return fmt.Errorf("key %q is empty", k.Name)
+	}
+	if len(k.Scopes) == 0 {
+		return fmt.Errorf("key %q has no scopes", k.Name)
+	}
+	for _, scope := range k.Scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("key %q has invalid scope %q, must be one of read, execute, admin", k.Name, scope)
+		}
+	}
+
+	return nil
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the client a request was authenticated as,
+// false when the API isn't protected.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// requireScope wraps a handler so that it's only served to clients with the
+// scope, when the API is protected. Credentials are read from the
+// Authorization header, as a bearer token or API key, or the password of
+// basic auth so that browsers can open the web UI, or the X-API-Key header.
+func (s *Server) requireScope(scope Scope, next http.Handler) http.Handler {
+	if !s.config.Auth.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, err := s.authenticate(r, time.Now())
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", r.URL.Path).
+				Str("remote_addr", r.RemoteAddr).
+				Msg("Unauthenticated request")
+
+			w.Header().Add("WWW-Authenticate", `Bearer realm="lacquer"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="lacquer"`)
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if !principal.Allows(scope) {
+			http.Error(w, fmt.Sprintf("'%s' is not allowed the %s scope", principal.Name, scope), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+var (
+	errNoCredentials      = errors.New("no credentials")
+	errInvalidCredentials = errors.New("invalid API key or token")
+)
+
+// authenticate returns the client the credentials of the request belong to.
+func (s *Server) authenticate(r *http.Request, now time.Time) (*Principal, error) {
+	credential := r.Header.Get("X-API-Key")
+	if scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok {
+		switch strings.ToLower(scheme) {
+		case "bearer":
+			credential = strings.TrimSpace(value)
+		case "basic":
+			if _, password, ok := r.BasicAuth(); ok {
+				credential = password
+			}
+		}
+	}
+	if credential == "" {
+		return nil, errNoCredentials
+	}
+
+	if principal, ok := s.matchAPIKey(credential); ok {
+		return principal, nil
+	}
+
+	if s.config.Auth.TokenSecret != "" && strings.Count(credential, ".") == 2 {
+		return verifyToken(credential, s.config.Auth.TokenSecret, now)
+	}
+
+	return nil, errInvalidCredentials
+}
+
+// matchAPIKey returns the principal of the API key, comparing the key to
+// every configured key in constant time.
+func (s *Server) matchAPIKey(credential string) (*Principal, bool) {
+	sum := sha256.Sum256([]byte(credential))
+
+	var matched *APIKey
+	for i := range s.config.Auth.APIKeys {
+		key := &s.config.Auth.APIKeys[i]
+		keySum := sha256.Sum256([]byte(key.Key))
+		if subtle.ConstantTimeCompare(sum[:], keySum[:]) == 1 {
+			matched = key
+		}
+	}
+
+	if matched == nil {
+		return nil, false
+	}
+
+	return &Principal{Name: matched.Name, Scopes: matched.Scopes}, true
+}
+
+// tokenLeeway is the clock skew allowed when checking the expiry of bearer
+// tokens.
+const tokenLeeway = time.Minute
+
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Scope     string `json:"scope"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+}
+
+// verifyToken verifies a JWT signed with HS256 by the secret, and returns
+// the principal of its subject with the scopes of its scope claim. Tokens
+// must expire.
+func verifyToken(token, secret string, now time.Time) (*Principal, error) {
+	parts := strings.Split(token, ".")
+
+	header, err := decodeTokenPart(parts[0])
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+	var alg struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &alg); err != nil || alg.Alg != "HS256" {
+		return nil, errInvalidCredentials
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errInvalidCredentials
+	}
+
+	payload, err := decodeTokenPart(parts[1])
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errInvalidCredentials
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token doesn't expire")
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(tokenLeeway)) {
+		return nil, errors.New("token has expired")
+	}
+	if claims.NotBefore != nil && now.Add(tokenLeeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return nil, errors.New("token isn't valid yet")
+	}
+
+	principal := &Principal{Name: claims.Subject}
+	for _, scope := range strings.Fields(claims.Scope) {
+		principal.Scopes = append(principal.Scopes, Scope(scope))
+	}
+
+	return principal, nil
+}
+
+func decodeTokenPart(part string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+}
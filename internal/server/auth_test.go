@@ -0,0 +1,215 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTokenSecret = "token-secret"
+
+func signToken(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newAuthServer(t *testing.T) *Server {
+	t.Helper()
+
+	server, err := New(&Config{Auth: Auth{
+		APIKeys: []APIKey{
+			{Name: "ci", Key: "ci-key", Scopes: []Scope{ScopeExecute}},
+			{Name: "ops", Key: "ops-key", Scopes: []Scope{ScopeAdmin}},
+		},
+		TokenSecret: testTokenSecret,
+	}})
+	require.NoError(t, err)
+
+	return server
+}
+
+func TestRequireScope(t *testing.T) {
+	server := newAuthServer(t)
+	handler := server.requireScope(ScopeExecute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(principal.Name))
+	}))
+
+	now := time.Now().Unix()
+	tests := []struct {
+		name   string
+		header func(r *http.Request)
+		status int
+		body   string
+	}{
+		{name: "No credentials", header: func(*http.Request) {}, status: http.StatusUnauthorized},
+		{name: "Bearer API key", header: func(r *http.Request) { r.Header.Set("Authorization", "Bearer ci-key") }, status: http.StatusOK, body: "ci"},
+		{name: "X-API-Key header", header: func(r *http.Request) { r.Header.Set("X-API-Key", "ci-key") }, status: http.StatusOK, body: "ci"},
+		{name: "Basic auth password", header: func(r *http.Request) { r.SetBasicAuth("anyone", "ci-key") }, status: http.StatusOK, body: "ci"},
+		{name: "Admin scope allows everything", header: func(r *http.Request) { r.Header.Set("X-API-Key", "ops-key") }, status: http.StatusOK, body: "ops"},
+		{name: "Unknown API key", header: func(r *http.Request) { r.Header.Set("X-API-Key", "nope") }, status: http.StatusUnauthorized},
+		{
+			name: "Bearer token",
+			header: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+signToken(t, testTokenSecret, map[string]any{"sub": "deploy-bot", "scope": "read execute", "exp": now + 60}))
+			},
+			status: http.StatusOK,
+			body:   "deploy-bot",
+		},
+		{
+			name: "Bearer token without the scope",
+			header: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+signToken(t, testTokenSecret, map[string]any{"sub": "dashboard", "scope": "read", "exp": now + 60}))
+			},
+			status: http.StatusForbidden,
+			body:   "'dashboard' is not allowed the execute scope",
+		},
+		{
+			name: "Expired bearer token",
+			header: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+signToken(t, testTokenSecret, map[string]any{"sub": "deploy-bot", "scope": "execute", "exp": now - 3600}))
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "Bearer token signed by another secret",
+			header: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+signToken(t, "other-secret", map[string]any{"sub": "deploy-bot", "scope": "execute", "exp": now + 60}))
+			},
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/workflows/review/execute", nil)
+			tt.header(request)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tt.status, recorder.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, strings.TrimSpace(recorder.Body.String()))
+			}
+			if tt.status == http.StatusUnauthorized {
+				assert.Contains(t, recorder.Header().Values("WWW-Authenticate"), `Bearer realm="lacquer"`)
+			}
+		})
+	}
+}
+
+func TestRequireScope_Disabled(t *testing.T) {
+	server, err := New(&Config{})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.requireScope(ScopeAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := PrincipalFromContext(r.Context())
+		assert.False(t, ok)
+	})).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/workflows/reload", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestVerifyToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	_, err := verifyToken(signToken(t, testTokenSecret, map[string]any{"sub": "bot", "scope": "read"}), testTokenSecret, now)
+	assert.EqualError(t, err, "token doesn't expire")
+
+	_, err = verifyToken(signToken(t, testTokenSecret, map[string]any{"sub": "bot", "exp": now.Unix() + 3600, "nbf": now.Unix() + 600}), testTokenSecret, now)
+	assert.EqualError(t, err, "token isn't valid yet")
+
+	// expired tokens are allowed a minute of clock skew
+	principal, err := verifyToken(signToken(t, testTokenSecret, map[string]any{"sub": "bot", "scope": "read", "exp": now.Unix() - 30}), testTokenSecret, now)
+	require.NoError(t, err)
+	assert.Equal(t, []Scope{ScopeRead}, principal.Scopes)
+
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"bot","exp":9999999999}`)) + "."
+	_, err = verifyToken(unsigned, testTokenSecret, now)
+	assert.ErrorIs(t, err, errInvalidCredentials)
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	t.Setenv("LACQUER_TEST_CI_KEY", "ci-key")
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "keys.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`keys:
+  - name: ci
+    key: ${LACQUER_TEST_CI_KEY}
+    scopes: [execute, read]
+`), 0600))
+
+	keys, err := LoadAPIKeys(path)
+	require.NoError(t, err)
+	assert.Equal(t, []APIKey{{Name: "ci", Key: "ci-key", Scopes: []Scope{ScopeExecute, ScopeRead}}}, keys)
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte(`keys:
+  - name: ci
+    key: ci-key
+    scopes: [deploy]
+`), 0600))
+
+	_, err = LoadAPIKeys(invalid)
+	assert.EqualError(t, err, fmt.Sprintf(`invalid API key 1 in %s: key "ci" has invalid scope "deploy", must be one of read, execute, admin`, invalid))
+}
+
+func TestServerIntegration_Auth(t *testing.T) {
+	suite := setupTestSuite(t)
+	defer suite.cleanup(t)
+
+	suite.config.Auth = Auth{APIKeys: []APIKey{{Name: "dashboard", Key: "read-key", Scopes: []Scope{ScopeRead}}}}
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/v1/workflows", addr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/api/v1/workflows", addr), nil)
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer read-key")
+	resp, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	request, err = http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/api/v1/workflows/test-workflow/execute", addr), strings.NewReader(`{}`))
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer read-key")
+	resp, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// health checks stay open
+	resp, err = http.Get(fmt.Sprintf("http://%s/health", addr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
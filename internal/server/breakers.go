@@ -0,0 +1,49 @@
+package server
+
+import (
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// breakerStateValues are the values of the circuit state metric.
+var breakerStateValues = map[string]float64{
+	provider.BreakerClosed:   0,
+	provider.BreakerHalfOpen: 1,
+	provider.BreakerOpen:     2,
+}
+
+// breakerCollector exports the state of the circuit breakers of providers
+// which have failed, providers without a series are closed.
+type breakerCollector struct {
+	breakers *provider.Breakers
+	state    *prometheus.Desc
+	failures *prometheus.Desc
+}
+
+func newBreakerCollector(breakers *provider.Breakers) *breakerCollector {
+	return &breakerCollector{
+		breakers: breakers,
+		state: prometheus.NewDesc(
+			"lacquer_provider_circuit_state",
+			"State of the circuit breaker of a provider: 0 closed, 1 half-open, 2 open",
+			[]string{"provider"}, nil,
+		),
+		failures: prometheus.NewDesc(
+			"lacquer_provider_consecutive_failures",
+			"Consecutive failed requests counted by the circuit breaker of a provider",
+			[]string{"provider"}, nil,
+		),
+	}
+}
+
+func (c *breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.state
+	ch <- c.failures
+}
+
+func (c *breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range c.breakers.Statuses() {
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, breakerStateValues[status.State], status.Provider)
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.GaugeValue, float64(status.Failures), status.Provider)
+	}
+}
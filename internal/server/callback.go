@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// CallbackSignatureHeader carries the HMAC-SHA256 signature of a callback,
+	// see SignCallback.
+	CallbackSignatureHeader = "X-Lacquer-Signature"
+	// CallbackTimestampHeader carries the unix time a callback was signed at.
+	CallbackTimestampHeader = "X-Lacquer-Timestamp"
+
+	callbackAttempts = 3
+	callbackTimeout  = 10 * time.Second
+)
+
+// callbackBackoff is the delay before the first redelivery of a callback,
+// doubled for each redelivery after it.
+var callbackBackoff = 2 * time.Second
+
+// SignCallback returns the signature of a callback body sent at timestamp,
+// the hex encoded HMAC-SHA256 of "<timestamp>.<body>" prefixed with sha256=.
+// Receivers compute it with the shared secret and compare it to the
+// X-Lacquer-Signature header, rejecting callbacks whose timestamp is too old
+// to prevent replays.
+func SignCallback(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateCallbackURL ensures the callback URL of an execute request is an
+// absolute http or https URL.
+func validateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid callback_url '%s', must be an absolute http or https URL", raw)
+	}
+	return nil
+}
+
+// sendCallback POSTs the final status of an execution to its callback URL,
+// signed with the secret when it's set. Deliveries which fail with a network
+// error, a 429 or a 5xx response are retried with backoff, other responses
+// are not retried.
+func (s *Server) sendCallback(ctx context.Context, callbackURL, secret string, body []byte) error {
+	backoff := callbackBackoff
+
+	var err error
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		var retry bool
+		retry, err = s.deliverCallback(ctx, callbackURL, secret, body)
+		if err == nil || !retry || attempt == callbackAttempts {
+			break
+		}
+
+		log.Warn().
+			Err(err).
+			Str("callback_url", callbackURL).
+			Int("attempt", attempt).
+			Msg("Failed to deliver callback, retrying")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	return err
+}
+
+// deliverCallback makes one attempt at delivering a callback, reporting
+// whether a failed delivery should be retried.
+func (s *Server) deliverCallback(ctx context.Context, callbackURL, secret string, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, callbackTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create callback request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lacquer-server")
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(CallbackTimestampHeader, timestamp)
+		req.Header.Set(CallbackSignatureHeader, SignCallback([]byte(secret), timestamp, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send callback: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("callback responded with status %d", resp.StatusCode)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedCallback struct {
+	header http.Header
+	body   []byte
+}
+
+func TestServerIntegration_ExecuteWorkflow_Callback(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+	suite.config.CallbackSecret = "s3cret"
+
+	received := make(chan receivedCallback, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedCallback{header: r.Header, body: body}
+	}))
+	defer receiver.Close()
+
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute", addr),
+		"application/json",
+		strings.NewReader(fmt.Sprintf(`{"inputs": {}, "callback_url": %q}`, receiver.URL)),
+	)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var callback receivedCallback
+	select {
+	case callback = <-received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+
+	timestamp := callback.header.Get(CallbackTimestampHeader)
+	require.NotEmpty(t, timestamp)
+	assert.Equal(t, SignCallback([]byte("s3cret"), timestamp, callback.body), callback.header.Get(CallbackSignatureHeader))
+
+	var status ExecutionStatus
+	require.NoError(t, json.Unmarshal(callback.body, &status))
+	assert.Equal(t, "script-workflow", status.WorkflowID)
+	assert.Equal(t, "completed", status.Status)
+	assert.Equal(t, "hello", strings.TrimSpace(status.Outputs["greeting"].(string)))
+}
+
+func TestServerIntegration_ExecuteWorkflow_InvalidCallbackURL(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	addr := suite.startServerInBackground(t)
+
+	for _, callbackURL := range []string{"example.com/hook", "ftp://example.com/hook", "/hook"} {
+		resp, err := http.Post(
+			fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute", addr),
+			"application/json",
+			strings.NewReader(fmt.Sprintf(`{"callback_url": %q}`, callbackURL)),
+		)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, callbackURL)
+	}
+
+	assert.Equal(t, 0, suite.server.manager.GetActiveExecutions())
+}
+
+func TestSendCallback(t *testing.T) {
+	original := callbackBackoff
+	callbackBackoff = time.Millisecond
+	defer func() { callbackBackoff = original }()
+
+	tests := []struct {
+		name      string
+		responses []int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "delivered", responses: []int{http.StatusNoContent}, wantCalls: 1},
+		{name: "retried on server error", responses: []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK}, wantCalls: 3},
+		{name: "not retried on client error", responses: []int{http.StatusNotFound}, wantCalls: 1, wantErr: true},
+		{name: "gives up", responses: []int{500, 500, 500, 200}, wantCalls: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := calls.Add(1)
+				assert.Empty(t, r.Header.Get(CallbackSignatureHeader))
+				w.WriteHeader(tt.responses[call-1])
+			}))
+			defer receiver.Close()
+
+			s := &Server{config: DefaultConfig()}
+			err := s.sendCallback(context.Background(), receiver.URL, "", []byte(`{}`))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestSignCallback(t *testing.T) {
+	signature := SignCallback([]byte("secret"), "1700000000", []byte(`{"status":"completed"}`))
+	assert.True(t, strings.HasPrefix(signature, "sha256="))
+	assert.Len(t, signature, len("sha256=")+64)
+
+	assert.Equal(t, signature, SignCallback([]byte("secret"), "1700000000", []byte(`{"status":"completed"}`)))
+	assert.NotEqual(t, signature, SignCallback([]byte("other"), "1700000000", []byte(`{"status":"completed"}`)))
+	assert.NotEqual(t, signature, SignCallback([]byte("secret"), "1700000001", []byte(`{"status":"completed"}`)))
+}
@@ -3,16 +3,24 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/gorilla/websocket"
 	"github.com/lacquerai/lacquer/internal/ast"
 	"github.com/lacquerai/lacquer/internal/engine"
 	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/utils"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 	"github.com/rs/zerolog/log"
 )
@@ -32,7 +40,8 @@ func (s *Server) listWorkflows(w http.ResponseWriter, r *http.Request) {
 				}
 				return ""
 			}(),
-			"steps": len(workflow.Workflow.Steps),
+			"steps":  len(workflow.Workflow.Steps),
+			"inputs": workflow.Inputs,
 		}
 	}
 
@@ -42,6 +51,38 @@ func (s *Server) listWorkflows(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// reloadWorkflows reloads the workflow files
+func (s *Server) reloadWorkflows(w http.ResponseWriter, r *http.Request) {
+	result, err := s.ReloadWorkflows()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload workflows: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// executeRequest is the body of a request to execute a workflow
+type executeRequest struct {
+	Inputs      map[string]any `json:"inputs"`
+	CallbackURL string         `json:"callback_url"`
+	Mode        string         `json:"mode"`
+	// Notifications override the webhooks of the server for the run
+	Notifications *Notifications `json:"notifications"`
+}
+
+// submitRequest is the body of a request to execute a workflow sent with
+// the request
+type submitRequest struct {
+	executeRequest
+	// Workflow is the source of the workflow
+	Workflow string `json:"workflow"`
+	// Filename is the name of the workflow's file, which the workflow is
+	// identified by in the executions of the server
+	Filename string `json:"filename"`
+}
+
 // executeWorkflow starts a workflow execution
 func (s *Server) executeWorkflow(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -53,26 +94,134 @@ func (s *Server) executeWorkflow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !s.manager.CanStartExecution() {
-		http.Error(w, "Server at capacity, try again later", http.StatusServiceUnavailable)
+	if s.refuseExecution(w, workflowID) {
 		return
 	}
 
-	var req struct {
-		Inputs map[string]any `json:"inputs"`
+	var req executeRequest
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.startExecution(w, r, workflowID, workflow, req)
+}
+
+// submitWorkflow starts an execution of a workflow sent with the request
+// rather than loaded by the server, for clients such as laq run --remote
+// to run their local workflows on the server. The workflow is validated as
+// laq validate does, files it references by relative paths are resolved
+// against the working directory of the server.
+func (s *Server) submitWorkflow(w http.ResponseWriter, r *http.Request) {
+	if !s.config.AllowSubmit {
+		http.Error(w, "Submitting workflows is disabled, start the server with --allow-submit", http.StatusForbidden)
+		return
 	}
 
+	var req submitRequest
 	if r.Body != nil {
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
 			return
 		}
 	}
+	if req.Workflow == "" {
+		http.Error(w, "workflow is required", http.StatusBadRequest)
+		return
+	}
+
+	filename := filepath.Base(req.Filename)
+	if !strings.HasSuffix(filename, ".laq.yaml") && !strings.HasSuffix(filename, ".laq.yml") {
+		filename = "workflow.laq.yaml"
+	}
+	id := workflowID(filename)
+
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create parser: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	workflow, err := yamlParser.ParseBytes([]byte(req.Workflow), filename)
+	if err != nil {
+		response := map[string]any{"error": fmt.Sprintf("Invalid workflow: %v", err)}
+		var multiErr *parser.MultiErrorEnhanced
+		if errors.As(err, &multiErr) {
+			response["error"] = "Invalid workflow"
+			response["diagnostics"] = multiErr.Diagnostics()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if s.refuseExecution(w, id) {
+		return
+	}
+
+	s.startExecution(w, r, id, workflow, req.executeRequest)
+}
+
+// refuseExecution responds with 503 Service Unavailable when the server
+// can't start another execution, at capacity or shedding load, reporting
+// whether it did.
+func (s *Server) refuseExecution(w http.ResponseWriter, workflowID string) bool {
+	if !s.manager.CanStartExecution() {
+		http.Error(w, "Server at capacity, try again later", http.StatusServiceUnavailable)
+		return true
+	}
+
+	if reasons := s.shedder.reasons(); len(reasons) > 0 {
+		log.Warn().
+			Str("workflow_id", workflowID).
+			Interface("reasons", reasons).
+			Msg("Refused execution while shedding load")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(sheddingRetryAfter.Seconds())))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error":   "Server is shedding load, try again later",
+			"reasons": reasons,
+		})
+		return true
+	}
+
+	return false
+}
+
+// startExecution validates the request to execute a workflow and starts
+// the execution, responding once it has started or, when the request waits
+// for it, finished.
+func (s *Server) startExecution(w http.ResponseWriter, r *http.Request, workflowID string, workflow *ast.Workflow, req executeRequest) {
+	wait, err := parseWait(r, req.Mode, s.config.MaxWait)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if req.Inputs == nil {
 		req.Inputs = make(map[string]any)
 	}
 
+	if req.CallbackURL != "" {
+		if err := validateCallbackURL(req.CallbackURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Notifications != nil {
+		if err := req.Notifications.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("invalid notifications: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
 	validationResult := engine.ValidateWorkflowInputs(workflow, req.Inputs)
 	if !validationResult.Valid {
 		w.Header().Set("Content-Type", "application/json")
@@ -81,8 +230,37 @@ func (s *Server) executeWorkflow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	processedInputs := validationResult.ProcessedInputs
+	status, execCtx := s.prepareExecution(workflowID, workflow, validationResult.ProcessedInputs, execcontext.Trigger{Type: execcontext.TriggerAPI})
+	ctx := execCtx.Context.Context
+	runID := status.RunID
+
+	status.callbackURL = req.CallbackURL
+	if req.Notifications != nil {
+		status.webhooks = req.Notifications.Webhooks
+	}
+	s.manager.saveExecution(status)
+
+	if wait > 0 {
+		results := make(chan map[string]any, 1)
+		go s.executeWorkflowAsync(ctx, workflow, execCtx, status, results)
+		s.waitForExecution(w, r, status, results, wait)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"run_id":      runID,
+		"workflow_id": workflowID,
+		"status":      "running",
+		"started_at":  status.StartTime,
+	})
+
+	go s.executeWorkflowAsync(ctx, workflow, execCtx, status, nil)
+}
 
+// prepareExecution starts tracking the execution of a workflow with
+// validated inputs, returning its status and the context to run it with.
+func (s *Server) prepareExecution(workflowID string, workflow *ast.Workflow, inputs map[string]any, trigger execcontext.Trigger) (*ExecutionStatus, *execcontext.ExecutionContext) {
 	// use background context as hanging off the request context
 	// will cause the context to be cancelled when the request is finished.
 	ctx, cancel := context.WithCancel(context.Background())
@@ -91,39 +269,262 @@ func (s *Server) executeWorkflow(w http.ResponseWriter, r *http.Request) {
 		Context: ctx,
 		StdOut:  io.Discard,
 		StdErr:  io.Discard,
+		Trigger: trigger,
 	}
-	execCtx := execcontext.NewExecutionContext(runCtx, workflow, processedInputs, workflow.SourceFile)
-	runID := execCtx.RunID
+	execCtx := execcontext.NewExecutionContext(runCtx, workflow, inputs, filepath.Dir(workflow.SourceFile))
 
-	status := s.manager.StartExecution(runID, workflowID, cancel, processedInputs)
+	// the inputs of workflows which don't keep the data of their runs are
+	// only held by the run itself
+	kept := inputs
+	if !workflow.KeepsRunData() {
+		kept = nil
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"run_id":      runID,
-		"workflow_id": workflowID,
-		"status":      "running",
-		"started_at":  status.StartTime,
-	})
+	status := s.manager.StartExecution(execCtx.RunID, workflowID, cancel, kept)
+	status.webhooks = s.config.Notifications.Webhooks
+	status.persistence = workflow.GetPersistence()
 
-	go s.executeWorkflowAsync(ctx, workflow, execCtx, runID, workflowID)
+	return status, execCtx
 }
 
-// executeWorkflowAsync executes a workflow in the background
-func (s *Server) executeWorkflowAsync(_ context.Context, workflow *ast.Workflow, execCtx *execcontext.ExecutionContext, runID, workflowID string) {
-	runner := engine.NewRunner(s.manager)
+// Execution modes of a request to execute a workflow, sync requests wait for
+// the run to finish like requests with wait=true.
+const (
+	executionModeAsync = "async"
+	executionModeSync  = "sync"
+)
+
+// parseWait returns how long a request to execute a workflow waits for the
+// run to finish, zero when it doesn't wait. Requests with wait=true, or in
+// the sync mode, wait up to maxWait, or less when they set wait_timeout. The
+// wait parameter takes precedence over the mode.
+func parseWait(r *http.Request, mode string, maxWait time.Duration) (time.Duration, error) {
+	var wait bool
+	switch mode {
+	case "", executionModeAsync:
+	case executionModeSync:
+		wait = true
+	default:
+		return 0, fmt.Errorf("invalid mode '%s', must be sync or async", mode)
+	}
+
+	query := r.URL.Query()
+	if raw := query.Get("wait"); raw != "" {
+		var err error
+		wait, err = strconv.ParseBool(raw)
+		if err != nil {
+			return 0, fmt.Errorf("invalid wait '%s', must be true or false", raw)
+		}
+	}
+	if !wait {
+		return 0, nil
+	}
+
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+
+	if raw := query.Get("wait_timeout"); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return 0, fmt.Errorf("invalid wait_timeout '%s', must be a positive duration such as 30s", raw)
+		}
+		if timeout < maxWait {
+			return timeout, nil
+		}
+	}
+
+	return maxWait, nil
+}
+
+// waitForExecution responds with the outcome of the execution once it has
+// finished. An execution which is still running when the wait is over keeps
+// running and is responded to with 202 Accepted, its outcome can then be
+// polled or streamed.
+func (s *Server) waitForExecution(w http.ResponseWriter, r *http.Request, status *ExecutionStatus, results <-chan map[string]any, wait time.Duration) {
+	// the response is written after the run, which can take longer than the
+	// server's write timeout
+	if s.config.WriteTimeout > 0 {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + s.config.WriteTimeout))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	var result map[string]any
+	select {
+	case result = <-results:
+	case <-timer.C:
+		result = s.manager.result(status)
+	case <-r.Context().Done():
+		// the client has gone, the execution carries on without it
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if result["status"] == "running" {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// executeWorkflowAsync executes a workflow in the background, sending its
+// outcome to results when a request is waiting for it.
+func (s *Server) executeWorkflowAsync(_ context.Context, workflow *ast.Workflow, execCtx *execcontext.ExecutionContext, status *ExecutionStatus, results chan<- map[string]any) {
+	// events are streamed to clients as JSON so never contain styling, the
+	// full text of truncated events is kept in the run log.
+	options := []engine.RunnerOption{
+		engine.WithPlainEvents(),
+		engine.WithPreviewLength(s.config.PreviewLength),
+		engine.WithProviderObserver(s.shedder),
+		engine.WithCircuitBreakers(provider.DefaultBreakers),
+		engine.WithRunLog(filepath.Join(utils.LacquerCacheDir, "logs"), s.config.HistoryCipher),
+		engine.WithRunHistory(history.NewStore(filepath.Join(utils.LacquerCacheDir, "history"), history.WithRetention(s.config.HistoryRetention), history.WithRunLogs(filepath.Join(utils.LacquerCacheDir, "logs")), history.WithCipher(s.config.HistoryCipher))),
+		engine.WithKVStore(s.kv),
+		engine.WithMetrics(s.manager.engineMetrics),
+	}
+	if s.config.EventLogDir != "" {
+		options = append(options, engine.WithEventLog(filepath.Join(s.config.EventLogDir, execCtx.RunID+".jsonl"), s.config.HistoryCipher))
+	}
+	runner := engine.NewRunner(s.manager, options...)
 	result, err := runner.RunWorkflowRaw(execCtx, workflow, time.Now())
+	// runs cancelled between steps stop without an error so that they can
+	// be resumed, they didn't complete though
+	if err == nil && execCtx.IsCancelled() {
+		err = errors.New("run was cancelled")
+	}
 	var outputs map[string]any
 	if err == nil {
 		outputs = result.Outputs
 	}
 
-	s.manager.FinishExecution(runID, outputs, err)
+	s.manager.FinishExecution(status.RunID, outputs, err)
 
 	log.Info().
-		Str("run_id", runID).
-		Str("workflow_id", workflowID).
+		Str("run_id", status.RunID).
+		Str("workflow_id", status.WorkflowID).
 		Err(err).
 		Msg("Workflow execution completed")
+
+	final := s.manager.outcome(status, outputs, err)
+	if results != nil {
+		results <- s.manager.result(final)
+	}
+	if final.callbackURL != "" {
+		s.notifyCallback(final)
+	}
+	s.notifyWebhooks(final, final.webhooks)
+}
+
+// notifyCallback sends the final status of an execution to the callback URL
+// of the request which started it.
+func (s *Server) notifyCallback(status *ExecutionStatus) {
+	body, err := s.manager.encode(status)
+	if err != nil {
+		log.Error().Err(err).Str("run_id", status.RunID).Msg("Failed to encode callback")
+		return
+	}
+
+	if err := s.sendCallback(context.Background(), status.callbackURL, s.config.CallbackSecret, body); err != nil {
+		log.Error().
+			Err(err).
+			Str("run_id", status.RunID).
+			Str("callback_url", status.callbackURL).
+			Msg("Failed to deliver callback")
+		return
+	}
+
+	log.Debug().
+		Str("run_id", status.RunID).
+		Str("callback_url", status.callbackURL).
+		Msg("Callback delivered")
+}
+
+const (
+	// defaultExecutionsLimit is how many executions a page lists when the
+	// request doesn't set a limit, maxExecutionsLimit is the most it may set.
+	defaultExecutionsLimit = 50
+	maxExecutionsLimit     = 500
+)
+
+// executionStatuses are the statuses executions can be filtered by
+var executionStatuses = []string{"running", "completed", "failed"}
+
+// ExecutionList is a page of the executions listed by the server
+type ExecutionList struct {
+	Executions []*ExecutionStatus `json:"executions"`
+	Total      int                `json:"total"`
+	Offset     int                `json:"offset"`
+	Limit      int                `json:"limit"`
+	// NextOffset is the offset of the next page, unset on the last page
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+// listExecutions returns a page of the executions, newest first, filtered
+// by workflow, status and start time
+func (s *Server) listExecutions(w http.ResponseWriter, r *http.Request) {
+	filter, offset, limit, err := parseExecutionsQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	executions, total, err := s.manager.ListExecutions(filter, offset, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := ExecutionList{
+		Executions: executions,
+		Total:      total,
+		Offset:     offset,
+		Limit:      limit,
+	}
+	if next := offset + len(executions); next < total {
+		list.NextOffset = &next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list) // Ignore encoding error
+}
+
+// parseExecutionsQuery parses the filter and page of a request listing
+// executions.
+func parseExecutionsQuery(r *http.Request, now time.Time) (ExecutionFilter, int, int, error) {
+	query := r.URL.Query()
+	filter := ExecutionFilter{
+		WorkflowID: query.Get("workflow_id"),
+		Status:     query.Get("status"),
+	}
+
+	if filter.Status != "" && !slices.Contains(executionStatuses, filter.Status) {
+		return filter, 0, 0, fmt.Errorf("invalid status '%s', must be one of %s", filter.Status, strings.Join(executionStatuses, ", "))
+	}
+
+	since, err := history.ParseSince(query.Get("since"), now)
+	if err != nil {
+		return filter, 0, 0, err
+	}
+	filter.Since = since
+
+	offset := 0
+	if raw := query.Get("offset"); raw != "" {
+		offset, err = strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return filter, 0, 0, fmt.Errorf("invalid offset '%s', must be a number of at least 0", raw)
+		}
+	}
+
+	limit := defaultExecutionsLimit
+	if raw := query.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxExecutionsLimit {
+			return filter, 0, 0, fmt.Errorf("invalid limit '%s', must be a number from 1 to %d", raw, maxExecutionsLimit)
+		}
+	}
+
+	return filter, offset, limit, nil
 }
 
 // getExecution returns the status of a specific execution
@@ -141,6 +542,43 @@ func (s *Server) getExecution(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(status) // Ignore encoding error
 }
 
+// streamExecutionEvents streams the events of an execution as Server-Sent
+// Events, for clients which can't use WebSockets. Events already published
+// are replayed first, after the Last-Event-ID of a reconnecting client.
+func (s *Server) streamExecutionEvents(w http.ResponseWriter, r *http.Request) {
+	runID := mux.Vars(r)["runId"]
+
+	format := streamFormat(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		format = streamFormatEvent
+	case streamFormatEvent, streamFormatEnvelope:
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format '%s', must be one of event or envelope", format), http.StatusBadRequest)
+		return
+	}
+
+	cursor := 0
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		id, err := strconv.Atoi(lastEventID)
+		if err != nil || id < 0 {
+			http.Error(w, fmt.Sprintf("invalid Last-Event-ID '%s', must be the ID of an event", lastEventID), http.StatusBadRequest)
+			return
+		}
+		cursor = id
+	}
+
+	status, exists := s.manager.GetExecution(runID)
+	if !exists {
+		http.Error(w, fmt.Sprintf("Execution '%s' not found", runID), http.StatusNotFound)
+		return
+	}
+
+	serveSSE(r.Context(), w, status.stream.SubscribeFrom(cursor), format, func() pkgEvents.ExecutionEvent {
+		return s.manager.finalEvent(status)
+	})
+}
+
 // streamWorkflow provides WebSocket streaming for workflow execution
 func (s *Server) streamWorkflow(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -152,6 +590,16 @@ func (s *Server) streamWorkflow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format := streamFormat(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		format = streamFormatEvent
+	case streamFormatEvent, streamFormatEnvelope:
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format '%s', must be one of event or envelope", format), http.StatusBadRequest)
+		return
+	}
+
 	status, exists := s.manager.GetExecution(runID)
 	if !exists {
 		http.Error(w, fmt.Sprintf("Execution '%s' not found", runID), http.StatusNotFound)
@@ -165,55 +613,32 @@ func (s *Server) streamWorkflow(w http.ResponseWriter, r *http.Request) {
 	}
 	defer func() { _ = conn.Close() }()
 
-	status.clientsMu.Lock()
-	status.clients[conn] = true
-	status.clientsMu.Unlock()
-
-	for _, event := range status.Progress {
-		eventJSON, _ := json.Marshal(event)
-		_ = conn.WriteMessage(websocket.TextMessage, eventJSON)
-	}
-
-	if status.Status != "running" {
-		finalEvent := pkgEvents.ExecutionEvent{
-			Type:      pkgEvents.EventWorkflowCompleted,
-			Timestamp: time.Now(),
-			RunID:     runID,
-		}
-		if status.Status == "failed" {
-			finalEvent.Type = pkgEvents.EventWorkflowFailed
-			finalEvent.Error = status.Error
-		}
-		eventJSON, _ := json.Marshal(finalEvent)
-		_ = conn.WriteMessage(websocket.TextMessage, eventJSON)
-	}
-
-	for {
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			break
-		}
-
-		status, exists := s.manager.GetExecution(runID)
-		if !exists || status.Status != "running" {
-			break
-		}
-	}
-
-	status.clientsMu.Lock()
-	delete(status.clients, conn)
-	status.clientsMu.Unlock()
+	// every client reads the run's events from the start at its own pace
+	serveWebSocket(conn, status.stream.Subscribe(), format, func() pkgEvents.ExecutionEvent {
+		return s.manager.finalEvent(status)
+	})
 }
 
 // healthCheck returns server health status
 func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
+	// a server shedding load is still alive, it only refuses new executions
+	reasons := s.shedder.reasons()
+	status := "healthy"
+	if len(reasons) > 0 {
+		status = "degraded"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(map[string]any{ // Ignore encoding error
-		"status":            "healthy",
+		"status":            status,
 		"workflows_loaded":  s.registry.Count(),
 		"active_executions": s.manager.GetActiveExecutions(),
-		"timestamp":         time.Now(),
+		"load_shedding": map[string]any{
+			"accepting_executions": len(reasons) == 0,
+			"reasons":              reasons,
+		},
+		"timestamp": time.Now(),
 	})
 }
 
@@ -236,3 +661,81 @@ func formatValidationErrors(result *engine.InputValidationResult) map[string]any
 
 	return response
 }
+
+// listSchedules lists the schedules of the served workflows
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"schedules": s.scheduler.list(),
+		"enabled":   s.config.EnableScheduler,
+	})
+}
+
+// getSchedule returns the schedule of a workflow
+func (s *Server) getSchedule(w http.ResponseWriter, r *http.Request) {
+	workflowID := mux.Vars(r)["id"]
+
+	schedule, err := s.scheduler.get(workflowID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Workflow '%s' has no schedule", workflowID), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(schedule)
+}
+
+// pauseSchedule stops running a workflow on its schedule
+func (s *Server) pauseSchedule(w http.ResponseWriter, r *http.Request) {
+	s.setSchedulePaused(w, r, true)
+}
+
+// resumeSchedule runs a paused workflow on its schedule again
+func (s *Server) resumeSchedule(w http.ResponseWriter, r *http.Request) {
+	s.setSchedulePaused(w, r, false)
+}
+
+func (s *Server) setSchedulePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	workflowID := mux.Vars(r)["id"]
+
+	schedule, err := s.scheduler.setPaused(workflowID, paused)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Workflow '%s' has no schedule", workflowID), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(schedule)
+}
+
+// runSchedule runs a scheduled workflow straight away, with the inputs and
+// overlap policy of its schedule. Runs which are queued behind the previous
+// run are accepted with 202.
+func (s *Server) runSchedule(w http.ResponseWriter, r *http.Request) {
+	workflowID := mux.Vars(r)["id"]
+
+	schedule, status, err := s.scheduler.runNow(workflowID)
+	switch {
+	case errors.Is(err, errScheduleNotFound):
+		http.Error(w, fmt.Sprintf("Workflow '%s' has no schedule", workflowID), http.StatusNotFound)
+		return
+	case errors.Is(err, errPreviousRunning):
+		http.Error(w, fmt.Sprintf("The previous scheduled run of '%s' is still running", workflowID), http.StatusConflict)
+		return
+	case errors.Is(err, errServerBusy):
+		http.Error(w, "Server is busy, try again later", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]any{"schedule": schedule}
+	w.Header().Set("Content-Type", "application/json")
+	if status == nil {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		response["run_id"] = status.RunID
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// writeWait is the time allowed to write a message to a client, clients
+	// which can't keep up are disconnected.
+	writeWait = 10 * time.Second
+
+	// pongWait is the time allowed to read the next pong from a client.
+	pongWait = 60 * time.Second
+
+	// pingPeriod is how often clients are pinged, must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// maxBatchSize is the maximum number of events read by a subscription
+	// at once.
+	maxBatchSize = 100
+
+	// sseKeepAlivePeriod is how often a comment is sent to Server-Sent
+	// Events clients while there are no events, so that proxies don't close
+	// idle streams.
+	sseKeepAlivePeriod = 15 * time.Second
+)
+
+// EventStream is the ordered log of the events of a single run. Any number
+// of subscribers can read the stream concurrently, each from its own
+// cursor, so a slow subscriber never holds up the run or other subscribers.
+type EventStream struct {
+	mu     sync.Mutex
+	events []pkgEvents.ExecutionEvent
+	notify chan struct{}
+	closed bool
+}
+
+// NewEventStream creates an empty event stream.
+func NewEventStream() *EventStream {
+	return &EventStream{
+		notify: make(chan struct{}),
+	}
+}
+
+// Publish appends the event to the stream and wakes up every waiting
+// subscriber.
+func (s *EventStream) Publish(event pkgEvents.ExecutionEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	s.wake()
+}
+
+// Close marks the stream as complete, subscribers finish once they have
+// read every event.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	s.wake()
+}
+
+// wake notifies every waiting subscriber, the lock must be held.
+func (s *EventStream) wake() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// Subscribe returns a subscription which reads the stream from the first
+// event.
+func (s *EventStream) Subscribe() *Subscription {
+	return &Subscription{stream: s}
+}
+
+// SubscribeFrom returns a subscription which reads the stream after the
+// given number of events, so that a client can resume where it left off.
+func (s *EventStream) SubscribeFrom(cursor int) *Subscription {
+	return &Subscription{stream: s, cursor: max(cursor, 0)}
+}
+
+// typeBefore returns the type of the event before the cursor, empty at the
+// start of the stream.
+func (s *EventStream) typeBefore(cursor int) pkgEvents.ExecutionEventType {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cursor <= 0 || cursor > len(s.events) {
+		return ""
+	}
+	return s.events[cursor-1].Type
+}
+
+// Subscription reads an event stream from its own cursor.
+type Subscription struct {
+	stream *EventStream
+	cursor int
+}
+
+// Cursor returns the number of events of the stream before the next event
+// the subscription reads.
+func (sub *Subscription) Cursor() int {
+	return sub.cursor
+}
+
+// Next returns the events after the cursor and advances the cursor past
+// them. When there are no new events the returned channel is closed once
+// more are published. io.EOF is returned once the stream is closed and
+// every event has been read.
+func (sub *Subscription) Next() ([]pkgEvents.ExecutionEvent, <-chan struct{}, error) {
+	s := sub.stream
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sub.cursor < len(s.events) {
+		end := min(len(s.events), sub.cursor+maxBatchSize)
+		batch := make([]pkgEvents.ExecutionEvent, end-sub.cursor)
+		copy(batch, s.events[sub.cursor:end])
+		sub.cursor = end
+
+		return batch, nil, nil
+	}
+
+	if s.closed {
+		return nil, nil, io.EOF
+	}
+
+	return nil, s.notify, nil
+}
+
+// serveWebSocket sends the events of the subscription to a WebSocket
+// client until the stream ends, the client disconnects or the client fails
+// to keep up. Clients are pinged periodically and disconnected if they stop
+// responding. Once every event is sent, final is called to produce the
+// terminal event for runs whose stream didn't include one.
+func serveWebSocket(conn *websocket.Conn, sub *Subscription, format streamFormat, final func() pkgEvents.ExecutionEvent) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	// messages from the client are discarded, reading is only needed to
+	// process pongs and to notice the client going away.
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	write := func(event pkgEvents.ExecutionEvent) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteMessage(websocket.TextMessage, encodeEvent(event, format))
+	}
+
+	var last pkgEvents.ExecutionEventType
+	for {
+		batch, wait, err := sub.Next()
+		for _, event := range batch {
+			if err := write(event); err != nil {
+				log.Debug().Err(err).Msg("Disconnecting WebSocket client")
+				return
+			}
+			last = event.Type
+		}
+
+		if errors.Is(err, io.EOF) {
+			if last != pkgEvents.EventWorkflowCompleted && last != pkgEvents.EventWorkflowFailed {
+				_ = write(final())
+			}
+
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+			return
+		}
+
+		if len(batch) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait:
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveSSE sends the events of the subscription to a Server-Sent Events
+// client until the stream ends or the client disconnects. The ID of each
+// event is its position in the stream, which the client sends back as
+// Last-Event-ID to resume after reconnecting. Once every event is sent, final
+// is called to produce the terminal event for runs whose stream didn't
+// include one, followed by an end event telling the client not to
+// reconnect.
+func serveSSE(ctx context.Context, w http.ResponseWriter, sub *Subscription, format streamFormat, final func() pkgEvents.ExecutionEvent) {
+	controller := http.NewResponseController(w)
+
+	// the write deadline is extended for every write, so that the server's
+	// write timeout doesn't end streams of long runs
+	write := func(message string) error {
+		_ = controller.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := io.WriteString(w, message); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	writeEvent := func(id int, event pkgEvents.ExecutionEvent) error {
+		return write(fmt.Sprintf("id: %d\ndata: %s\n\n", id, encodeEvent(event, format)))
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := write(fmt.Sprintf("retry: %d\n\n", time.Second.Milliseconds())); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sseKeepAlivePeriod)
+	defer ticker.Stop()
+
+	// a resumed client may already have the terminal event
+	last := sub.stream.typeBefore(sub.Cursor())
+	for {
+		id := sub.Cursor()
+		batch, wait, err := sub.Next()
+		for _, event := range batch {
+			id++
+			if err := writeEvent(id, event); err != nil {
+				log.Debug().Err(err).Msg("Disconnecting Server-Sent Events client")
+				return
+			}
+			last = event.Type
+		}
+
+		if errors.Is(err, io.EOF) {
+			if last != pkgEvents.EventWorkflowCompleted && last != pkgEvents.EventWorkflowFailed {
+				if writeEvent(id+1, final()) != nil {
+					return
+				}
+			}
+
+			_ = write("event: end\ndata: {}\n\n")
+			return
+		}
+
+		if len(batch) > 0 {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait:
+		case <-ticker.C:
+			if err := write(": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStream_IndependentCursors(t *testing.T) {
+	stream := NewEventStream()
+	stream.Publish(pkgEvents.ExecutionEvent{Type: pkgEvents.EventWorkflowStarted})
+
+	first := stream.Subscribe()
+	batch, _, err := first.Next()
+	require.NoError(t, err)
+	assert.Len(t, batch, 1)
+
+	// the first subscriber is caught up and has to wait for more events
+	batch, wait, err := first.Next()
+	require.NoError(t, err)
+	assert.Empty(t, batch)
+	require.NotNil(t, wait)
+
+	stream.Publish(pkgEvents.ExecutionEvent{Type: pkgEvents.EventStepStarted, StepID: "one"})
+
+	select {
+	case <-wait:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber was not notified of new event")
+	}
+
+	// a second subscriber starts from the beginning of the stream
+	second := stream.Subscribe()
+	batch, _, err = second.Next()
+	require.NoError(t, err)
+	assert.Len(t, batch, 2)
+
+	batch, _, err = first.Next()
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, "one", batch[0].StepID)
+
+	stream.Close()
+
+	_, _, err = first.Next()
+	assert.ErrorIs(t, err, io.EOF)
+	_, _, err = second.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestServeWebSocket_MultipleClients(t *testing.T) {
+	stream := NewEventStream()
+	stream.Publish(pkgEvents.ExecutionEvent{Type: pkgEvents.EventWorkflowStarted, RunID: "run-1"})
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		serveWebSocket(conn, stream.Subscribe(), streamFormat(r.URL.Query().Get("format")), func() pkgEvents.ExecutionEvent {
+			return pkgEvents.ExecutionEvent{Type: pkgEvents.EventWorkflowCompleted, RunID: "run-1"}
+		})
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	read := func(format string) []string {
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL+"?format="+format, nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		var messages []string
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "unexpected error: %v", err)
+				return messages
+			}
+			messages = append(messages, string(data))
+		}
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]string, 2)
+	for i, format := range []string{"event", "envelope"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = read(format)
+		}()
+	}
+
+	stream.Publish(pkgEvents.ExecutionEvent{Type: pkgEvents.EventStepStarted, RunID: "run-1", StepID: "one"})
+	stream.Close()
+	wg.Wait()
+
+	for _, messages := range results {
+		require.Len(t, messages, 3)
+	}
+
+	var event pkgEvents.ExecutionEvent
+	require.NoError(t, json.Unmarshal([]byte(results[0][2]), &event))
+	assert.Equal(t, pkgEvents.EventWorkflowCompleted, event.Type)
+
+	var envelope pkgEvents.Envelope
+	require.NoError(t, json.Unmarshal([]byte(results[1][1]), &envelope))
+	assert.Equal(t, pkgEvents.KindStepStarted, envelope.Kind)
+}
+
+// readSSE reads the Server-Sent Events of a response until it ends, as
+// "<id> <data>" lines, with the end event as "end".
+func readSSE(t *testing.T, body io.Reader) []string {
+	t.Helper()
+
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var messages []string
+	for _, block := range strings.Split(strings.TrimSpace(string(data)), "\n\n") {
+		var id, payload, name string
+		for _, line := range strings.Split(block, "\n") {
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				id = value
+			case "data":
+				payload = value
+			case "event":
+				name = value
+			}
+		}
+
+		switch {
+		case name == "end":
+			messages = append(messages, "end")
+		case payload != "":
+			messages = append(messages, id+" "+payload)
+		}
+	}
+
+	return messages
+}
+
+func TestServeSSE(t *testing.T) {
+	stream := NewEventStream()
+	stream.Publish(pkgEvents.ExecutionEvent{Type: pkgEvents.EventWorkflowStarted, RunID: "run-1"})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+		serveSSE(r.Context(), w, stream.SubscribeFrom(cursor), streamFormatEvent, func() pkgEvents.ExecutionEvent {
+			return pkgEvents.ExecutionEvent{Type: pkgEvents.EventWorkflowCompleted, RunID: "run-1"}
+		})
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// events published while the client is connected are streamed live
+	stream.Publish(pkgEvents.ExecutionEvent{Type: pkgEvents.EventStepStarted, RunID: "run-1", StepID: "one"})
+	stream.Close()
+
+	messages := readSSE(t, resp.Body)
+	require.Len(t, messages, 4)
+	assert.True(t, strings.HasPrefix(messages[0], `1 {"type":"workflow_started"`), messages[0])
+	assert.True(t, strings.HasPrefix(messages[1], `2 {"type":"step_started"`), messages[1])
+	assert.True(t, strings.HasPrefix(messages[2], `3 {"type":"workflow_completed"`), messages[2])
+	assert.Equal(t, "end", messages[3])
+
+	// reconnecting clients resume after the last event they received
+	request, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	request.Header.Set("Last-Event-ID", "1")
+	resp, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	messages = readSSE(t, resp.Body)
+	require.Len(t, messages, 3)
+	assert.True(t, strings.HasPrefix(messages[0], `2 {"type":"step_started"`), messages[0])
+}
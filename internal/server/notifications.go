@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Outcomes of runs webhooks are notified of.
+const (
+	NotifyCompleted = "completed"
+	NotifyFailed    = "failed"
+)
+
+// Notifications are the webhooks notified when runs finish.
+type Notifications struct {
+	Webhooks []Webhook `mapstructure:"webhooks" json:"webhooks"`
+}
+
+// Webhook is a URL the outcome of runs is POSTed to as JSON, signed and
+// retried like callbacks.
+type Webhook struct {
+	// Name identifies the webhook in logs
+	Name string `mapstructure:"name" json:"name"`
+	URL  string `mapstructure:"url" json:"url"`
+	// Secret signs the payloads, see SignCallback. Payloads are unsigned
+	// when it isn't set.
+	Secret string `mapstructure:"secret" json:"secret"`
+	// On are the outcomes notified, completed and failed runs when empty
+	On []string `mapstructure:"on" json:"on"`
+	// Workflows limits the webhook to the runs of these workflows, it's
+	// notified of every workflow when empty
+	Workflows []string `mapstructure:"workflows" json:"workflows"`
+	// Payload are the fields of the payload, templates where ${{ field }}
+	// is replaced by a field of the execution status such as status,
+	// error or outputs.summary. The execution status is sent as is when
+	// it's empty.
+	Payload map[string]string `mapstructure:"payload" json:"payload"`
+}
+
+// Validate checks the webhooks are absolute http or https URLs notified of
+// known outcomes.
+func (n Notifications) Validate() error {
+	for i, webhook := range n.Webhooks {
+		if err := webhook.validate(); err != nil {
+			return fmt.Errorf("invalid webhook %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (w Webhook) validate() error {
+	if err := validateCallbackURL(w.URL); err != nil {
+		return fmt.Errorf("invalid url '%s', must be an absolute http or https URL", w.URL)
+	}
+	for _, on := range w.On {
+		if on != NotifyCompleted && on != NotifyFailed {
+			return fmt.Errorf("invalid on '%s', must be completed or failed", on)
+		}
+	}
+	return nil
+}
+
+// ExpandEnv expands environment variables in the URLs and secrets of the
+// webhooks, so that they can be kept out of config files.
+func (n Notifications) ExpandEnv() Notifications {
+	webhooks := make([]Webhook, len(n.Webhooks))
+	for i, webhook := range n.Webhooks {
+		webhook.URL = os.ExpandEnv(webhook.URL)
+		webhook.Secret = os.ExpandEnv(webhook.Secret)
+		webhooks[i] = webhook
+	}
+	return Notifications{Webhooks: webhooks}
+}
+
+// notifies reports whether the webhook is notified of the run of a workflow
+// which finished with the status.
+func (w Webhook) notifies(workflowID, status string) bool {
+	if len(w.Workflows) > 0 && !slices.Contains(w.Workflows, workflowID) {
+		return false
+	}
+	if len(w.On) == 0 {
+		return status == NotifyCompleted || status == NotifyFailed
+	}
+	return slices.Contains(w.On, status)
+}
+
+// name returns how the webhook is identified in logs, its URL without its
+// query, which may hold credentials, when it has no name.
+func (w Webhook) name() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	name, _, _ := strings.Cut(w.URL, "?")
+	return name
+}
+
+// notifyWebhooks sends the final status of an execution to the webhooks
+// notified of it, at the same time, and waits until they're delivered or
+// have failed.
+func (s *Server) notifyWebhooks(status *ExecutionStatus, webhooks []Webhook) {
+	var notified []Webhook
+	for _, webhook := range webhooks {
+		if webhook.notifies(status.WorkflowID, status.Status) {
+			notified = append(notified, webhook)
+		}
+	}
+	if len(notified) == 0 {
+		return
+	}
+
+	body, err := s.manager.encode(status)
+	if err != nil {
+		log.Error().Err(err).Str("run_id", status.RunID).Msg("Failed to encode notification")
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, webhook := range notified {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.notifyWebhook(status.RunID, webhook, body)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Server) notifyWebhook(runID string, webhook Webhook, status []byte) {
+	body := status
+	if len(webhook.Payload) > 0 {
+		var err error
+		if body, err = renderPayload(webhook.Payload, status); err != nil {
+			log.Error().Err(err).Str("run_id", runID).Str("webhook", webhook.name()).Msg("Failed to render notification")
+			return
+		}
+	}
+
+	if err := s.sendCallback(context.Background(), webhook.URL, webhook.Secret, body); err != nil {
+		log.Error().
+			Err(err).
+			Str("run_id", runID).
+			Str("webhook", webhook.name()).
+			Msg("Failed to deliver notification")
+		return
+	}
+
+	log.Debug().
+		Str("run_id", runID).
+		Str("webhook", webhook.name()).
+		Msg("Notification delivered")
+}
+
+var payloadFieldPattern = regexp.MustCompile(`\$\{\{\s*([\w.-]+)\s*\}\}`)
+
+// renderPayload renders the fields of a webhook payload from the JSON of an
+// execution status. Fields which are a single ${{ field }} take the value
+// of the field as is, such as the object of outputs, other fields are
+// strings with the fields replaced by their text. Missing fields are empty.
+func renderPayload(payload map[string]string, status []byte) ([]byte, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(status, &fields); err != nil {
+		return nil, err
+	}
+
+	rendered := make(map[string]any, len(payload))
+	for key, template := range payload {
+		if match := payloadFieldPattern.FindStringSubmatch(template); match != nil && match[0] == strings.TrimSpace(template) {
+			rendered[key] = lookupField(fields, match[1])
+			continue
+		}
+
+		rendered[key] = payloadFieldPattern.ReplaceAllStringFunc(template, func(ref string) string {
+			value := lookupField(fields, payloadFieldPattern.FindStringSubmatch(ref)[1])
+			switch value := value.(type) {
+			case nil:
+				return ""
+			case string:
+				return value
+			default:
+				text, _ := json.Marshal(value)
+				return string(text)
+			}
+		})
+	}
+
+	return json.Marshal(rendered)
+}
+
+// lookupField returns the value of a dotted path in the fields, nil when it
+// doesn't exist.
+func lookupField(fields map[string]any, path string) any {
+	var value any = fields
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
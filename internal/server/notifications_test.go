@@ -0,0 +1,116 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications_Validate(t *testing.T) {
+	assert.NoError(t, Notifications{Webhooks: []Webhook{{URL: "https://hooks.example.com/runs", On: []string{NotifyFailed}}}}.Validate())
+
+	err := Notifications{Webhooks: []Webhook{{URL: "https://hooks.example.com/runs"}, {URL: "hooks.example.com"}}}.Validate()
+	assert.EqualError(t, err, "invalid webhook 2: invalid url 'hooks.example.com', must be an absolute http or https URL")
+
+	err = Notifications{Webhooks: []Webhook{{URL: "https://hooks.example.com/runs", On: []string{"started"}}}}.Validate()
+	assert.EqualError(t, err, "invalid webhook 1: invalid on 'started', must be completed or failed")
+}
+
+func TestWebhook_Notifies(t *testing.T) {
+	assert.True(t, Webhook{}.notifies("triage", NotifyCompleted))
+	assert.True(t, Webhook{}.notifies("triage", NotifyFailed))
+	assert.False(t, Webhook{}.notifies("triage", "running"))
+	assert.False(t, Webhook{On: []string{NotifyFailed}}.notifies("triage", NotifyCompleted))
+	assert.True(t, Webhook{Workflows: []string{"triage"}}.notifies("triage", NotifyFailed))
+	assert.False(t, Webhook{Workflows: []string{"triage"}}.notifies("digest", NotifyFailed))
+}
+
+func TestRenderPayload(t *testing.T) {
+	status := []byte(`{"run_id":"run_1","workflow_id":"triage","status":"completed","outputs":{"summary":"All good","count":3}}`)
+
+	body, err := renderPayload(map[string]string{
+		"text":    "${{ workflow_id }} ${{status}}: ${{ outputs.summary }} (${{ outputs.count }} issues)${{ error }}",
+		"outputs": "${{ outputs }}",
+		"count":   " ${{ outputs.count }} ",
+		"missing": "${{ outputs.missing.field }}",
+	}, status)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"text": "triage completed: All good (3 issues)",
+		"outputs": {"summary": "All good", "count": 3},
+		"count": 3,
+		"missing": null
+	}`, string(body))
+}
+
+func TestServerIntegration_ExecuteWorkflow_Notifications(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	receiver := func() (*httptest.Server, chan receivedCallback) {
+		received := make(chan receivedCallback, 2)
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- receivedCallback{header: r.Header, body: body}
+		})), received
+	}
+	configured, configuredReceived := receiver()
+	defer configured.Close()
+	override, overrideReceived := receiver()
+	defer override.Close()
+
+	suite.config.Notifications = Notifications{Webhooks: []Webhook{
+		{Name: "chat", URL: configured.URL, Secret: "s3cret", Payload: map[string]string{"text": "${{ workflow_id }} ${{ status }}: ${{ outputs.greeting }}"}},
+		{URL: configured.URL, On: []string{NotifyFailed}},
+	}}
+	addr := suite.startServerInBackground(t)
+
+	execute := func(body string) {
+		resp, err := http.Post(fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute", addr), "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	wait := func(received chan receivedCallback) receivedCallback {
+		select {
+		case notification := <-received:
+			return notification
+		case <-time.After(10 * time.Second):
+			t.Fatal("notification was not delivered")
+			return receivedCallback{}
+		}
+	}
+
+	execute(`{}`)
+	notification := wait(configuredReceived)
+	timestamp := notification.header.Get(CallbackTimestampHeader)
+	assert.Equal(t, SignCallback([]byte("s3cret"), timestamp, notification.body), notification.header.Get(CallbackSignatureHeader))
+
+	var payload map[string]string
+	require.NoError(t, json.Unmarshal(notification.body, &payload))
+	assert.Equal(t, "script-workflow completed: hello", strings.TrimSpace(payload["text"]))
+
+	// the webhooks of a request replace those of the server
+	execute(fmt.Sprintf(`{"notifications": {"webhooks": [{"url": %q}]}}`, override.URL))
+	notification = wait(overrideReceived)
+	assert.Empty(t, notification.header.Get(CallbackSignatureHeader))
+
+	var status ExecutionStatus
+	require.NoError(t, json.Unmarshal(notification.body, &status))
+	assert.Equal(t, "completed", status.Status)
+	assert.Empty(t, configuredReceived)
+
+	resp, err := http.Post(fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute", addr), "application/json", strings.NewReader(`{"notifications": {"webhooks": [{"url": "/hook"}]}}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/watch"
+	"github.com/rs/zerolog/log"
+)
+
+// ReloadResult is the outcome of reloading the server's workflow files.
+type ReloadResult struct {
+	// Workflows are the IDs of the workflows served after the reload
+	Workflows []string `json:"workflows"`
+	// Errors are the files which couldn't be loaded, the workflows they
+	// held before are still served
+	Errors []ReloadError `json:"errors,omitempty"`
+}
+
+// ReloadError is a workflow file which couldn't be reloaded.
+type ReloadError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// workflowID is the ID a workflow file is served as, its name without the
+// .laq.yaml extension.
+func workflowID(file string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), ".laq")
+}
+
+// workflowFiles returns the configured workflow files along with those in
+// the workflow directory.
+func (s *Server) workflowFiles() ([]string, error) {
+	files := s.config.WorkflowFiles
+	if s.config.WorkflowDir != "" {
+		dirFiles, err := s.findWorkflowFiles(s.config.WorkflowDir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(append([]string{}, files...), dirFiles...)
+	}
+
+	return files, nil
+}
+
+// ReloadWorkflows parses the workflow files again, serving the workflows
+// of files which were added or changed and no longer serving those whose
+// files were removed. A file which can't be loaded keeps its workflow as it
+// was, so that a bad edit doesn't take a workflow down. Running executions
+// carry on with the workflow they started with.
+func (s *Server) ReloadWorkflows() (*ReloadResult, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	files, err := s.workflowFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	yamlParser, err := parser.NewYAMLParser()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{}
+	workflows := make(map[string]*ast.Workflow, len(files))
+	for _, parsed := range parser.ParseFiles(yamlParser, files) {
+		id := workflowID(parsed.Filename)
+		if parsed.Err != nil {
+			result.Errors = append(result.Errors, ReloadError{File: parsed.Filename, Error: parsed.Err.Error()})
+			if previous, ok := s.registry.Get(id); ok {
+				workflows[id] = previous
+			}
+
+			log.Error().
+				Err(parsed.Err).
+				Str("workflow_id", id).
+				Str("file", parsed.Filename).
+				Msg("Failed to reload workflow, keeping the loaded version")
+			continue
+		}
+
+		workflows[id] = parsed.Workflow
+	}
+
+	s.registry.Replace(workflows)
+
+	result.Workflows = s.registry.List()
+	sort.Strings(result.Workflows)
+
+	log.Info().
+		Int("workflows", len(result.Workflows)).
+		Int("errors", len(result.Errors)).
+		Msg("Workflows reloaded")
+
+	return result, nil
+}
+
+// newWorkflowWatcher returns a watcher of the workflow files and the
+// workflow directory, polled at the interval.
+func (s *Server) newWorkflowWatcher(interval time.Duration) *watch.Watcher {
+	paths := append([]string{}, s.config.WorkflowFiles...)
+	if s.config.WorkflowDir != "" {
+		paths = append(paths, s.config.WorkflowDir)
+	}
+
+	return watch.New(paths, watch.WithInterval(interval))
+}
+
+// watchWorkflows reloads the workflows whenever the watcher sees their files
+// change, until the context is cancelled.
+func (s *Server) watchWorkflows(ctx context.Context, watcher *watch.Watcher) {
+	for {
+		changed, err := watcher.Wait(ctx)
+		if err != nil {
+			return
+		}
+
+		log.Info().Strs("files", changed).Msg("Workflow files changed")
+		if _, err := s.ReloadWorkflows(); err != nil {
+			log.Error().Err(err).Msg("Failed to reload workflows")
+		}
+	}
+}
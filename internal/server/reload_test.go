@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWorkflowDirServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "simple.laq.yaml"), []byte(simpleWorkflowYAML), 0600))
+
+	server, err := New(&Config{
+		Host:        "127.0.0.1",
+		Port:        findAvailablePort(),
+		Concurrency: 2,
+		WorkflowDir: dir,
+	})
+	require.NoError(t, err)
+	server.manager = NewExecutionManagerWithRegistry(2, nil)
+	require.NoError(t, server.LoadWorkflows())
+
+	return server, dir
+}
+
+func TestServer_ReloadWorkflows(t *testing.T) {
+	server, dir := newWorkflowDirServer(t)
+	simple, _ := server.registry.Get("simple")
+
+	// added files are served
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.laq.yaml"), []byte(testWorkflowYAML), 0600))
+	result, err := server.ReloadWorkflows()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"simple", "test"}, result.Workflows)
+	assert.Empty(t, result.Errors)
+
+	// changed files are parsed again
+	changed := strings.Replace(simpleWorkflowYAML, "A simple test workflow", "A changed workflow", 1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "simple.laq.yaml"), []byte(changed), 0600))
+	_, err = server.ReloadWorkflows()
+	require.NoError(t, err)
+	reloaded, _ := server.registry.Get("simple")
+	assert.NotSame(t, simple, reloaded)
+	assert.Equal(t, "A changed workflow", reloaded.Metadata.Description)
+
+	// files which don't parse keep the workflow they held
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "simple.laq.yaml"), []byte(`invalid: yaml: content: [[[`), 0600))
+	result, err = server.ReloadWorkflows()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"simple", "test"}, result.Workflows)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, filepath.Join(dir, "simple.laq.yaml"), result.Errors[0].File)
+	kept, _ := server.registry.Get("simple")
+	assert.Same(t, reloaded, kept)
+
+	// removed files are no longer served
+	require.NoError(t, os.Remove(filepath.Join(dir, "test.laq.yaml")))
+	result, err = server.ReloadWorkflows()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"simple"}, result.Workflows)
+}
+
+func TestServer_WatchWorkflows(t *testing.T) {
+	server, dir := newWorkflowDirServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.watchWorkflows(ctx, server.newWorkflowWatcher(10*time.Millisecond))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.laq.yaml"), []byte(testWorkflowYAML), 0600))
+	assert.Eventually(t, func() bool {
+		_, ok := server.registry.Get("test")
+		return ok
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestServerIntegration_ReloadWorkflows(t *testing.T) {
+	server, dir := newWorkflowDirServer(t)
+	require.NoError(t, server.Start())
+	defer func() { _ = server.Stop(context.Background()) }()
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test.laq.yaml"), []byte(testWorkflowYAML), 0600))
+
+	resp, err := http.Post(fmt.Sprintf("http://%s/api/v1/workflows/reload", server.GetAddr()), "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result ReloadResult
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, []string{"simple", "test"}, result.Workflows)
+	assert.Equal(t, 2, server.GetWorkflowCount())
+}
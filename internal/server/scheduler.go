@@ -0,0 +1,378 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/cron"
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/rs/zerolog/log"
+)
+
+// schedulerInterval is how often the scheduler checks for runs which are
+// due.
+var schedulerInterval = time.Second
+
+var (
+	errScheduleNotFound = errors.New("schedule not found")
+	errPreviousRunning  = errors.New("previous scheduled run is still running")
+	errServerBusy       = errors.New("server is busy")
+)
+
+// ScheduleStatus is the schedule of a served workflow and the state of its
+// scheduled runs.
+type ScheduleStatus struct {
+	WorkflowID string         `json:"workflow_id"`
+	Cron       string         `json:"cron"`
+	Timezone   string         `json:"timezone,omitempty"`
+	Overlap    string         `json:"overlap"`
+	Inputs     map[string]any `json:"inputs,omitempty"`
+	Paused     bool           `json:"paused"`
+	// NextRun is when the workflow runs next, unset while it's paused
+	NextRun *time.Time `json:"next_run,omitempty"`
+	// LastRunID is the run ID of the last scheduled run
+	LastRunID string     `json:"last_run_id,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	// Running reports whether the last scheduled run is still running
+	Running bool `json:"running"`
+	// Queued reports whether a run is waiting for the last one to finish
+	Queued bool `json:"queued"`
+	// Skipped counts the runs which were skipped since the server started,
+	// because the previous run was still running or the server was busy
+	Skipped int `json:"skipped"`
+}
+
+// scheduleEntry is the schedule of a served workflow and its runs.
+type scheduleEntry struct {
+	workflowID string
+	schedule   *ast.Schedule
+	cron       *cron.Schedule
+	location   *time.Location
+	paused     bool
+	next       time.Time
+	last       *ExecutionStatus
+	queued     bool
+	skipped    int
+}
+
+// running reports whether the last scheduled run is still running.
+func (e *scheduleEntry) running() bool {
+	if e.last == nil {
+		return false
+	}
+
+	select {
+	case <-e.last.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (e *scheduleEntry) overlap() string {
+	if e.schedule.Overlap == "" {
+		return ast.OverlapSkip
+	}
+	return e.schedule.Overlap
+}
+
+func (e *scheduleEntry) status() *ScheduleStatus {
+	status := &ScheduleStatus{
+		WorkflowID: e.workflowID,
+		Cron:       e.schedule.Cron,
+		Timezone:   e.schedule.Timezone,
+		Overlap:    e.overlap(),
+		Inputs:     e.schedule.Inputs,
+		Paused:     e.paused,
+		Running:    e.running(),
+		Queued:     e.queued,
+		Skipped:    e.skipped,
+	}
+	if !e.paused && !e.next.IsZero() {
+		next := e.next
+		status.NextRun = &next
+	}
+	if e.last != nil {
+		status.LastRunID = e.last.RunID
+		started := e.last.StartTime
+		status.LastRunAt = &started
+	}
+
+	return status
+}
+
+// scheduler runs the served workflows which have a schedule on their cron
+// expressions. Schedules follow the workflows as they're reloaded, keeping
+// whether they're paused while their cron expression is unchanged.
+type scheduler struct {
+	server  *Server
+	now     func() time.Time
+	entries map[string]*scheduleEntry
+	mu      sync.Mutex
+}
+
+func newScheduler(server *Server) *scheduler {
+	return &scheduler{
+		server:  server,
+		now:     time.Now,
+		entries: make(map[string]*scheduleEntry),
+	}
+}
+
+// run starts the runs which are due until the context is cancelled.
+func (sc *scheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sc.tick(sc.now())
+		}
+	}
+}
+
+// tick starts the runs which are due at now, and the queued runs whose
+// previous run has finished.
+func (sc *scheduler) tick(now time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.sync(now)
+
+	for _, entry := range sc.sortedEntries() {
+		if entry.queued && !entry.running() {
+			entry.queued = false
+			_, _ = sc.start(entry)
+		}
+
+		if entry.paused || entry.next.IsZero() || now.Before(entry.next) {
+			continue
+		}
+
+		entry.next = entry.cron.Next(now.In(entry.location))
+		_, _ = sc.fire(entry)
+	}
+}
+
+// sync reconciles the schedules with the workflows being served.
+func (sc *scheduler) sync(now time.Time) {
+	served := make(map[string]bool)
+	for _, id := range sc.server.registry.List() {
+		workflow, ok := sc.server.registry.Get(id)
+		if !ok || workflow.Metadata == nil || workflow.Metadata.Schedule == nil {
+			continue
+		}
+		schedule := workflow.Metadata.Schedule
+
+		entry, exists := sc.entries[id]
+		if exists && entry.schedule.Cron == schedule.Cron && entry.schedule.Timezone == schedule.Timezone {
+			entry.schedule = schedule
+			served[id] = true
+			continue
+		}
+
+		parsed, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			log.Error().Err(err).Str("workflow_id", id).Msg("Invalid schedule")
+			continue
+		}
+		location := time.Local
+		if schedule.Timezone != "" {
+			if location, err = time.LoadLocation(schedule.Timezone); err != nil {
+				log.Error().Err(err).Str("workflow_id", id).Msg("Invalid schedule time zone")
+				continue
+			}
+		}
+
+		if !exists {
+			entry = &scheduleEntry{workflowID: id}
+			sc.entries[id] = entry
+			log.Info().Str("workflow_id", id).Str("cron", schedule.Cron).Msg("Workflow scheduled")
+		}
+		entry.schedule = schedule
+		entry.cron = parsed
+		entry.location = location
+		entry.next = parsed.Next(now.In(location))
+		served[id] = true
+	}
+
+	for id := range sc.entries {
+		if !served[id] {
+			delete(sc.entries, id)
+			log.Info().Str("workflow_id", id).Msg("Workflow unscheduled")
+		}
+	}
+}
+
+func (sc *scheduler) sortedEntries() []*scheduleEntry {
+	entries := make([]*scheduleEntry, 0, len(sc.entries))
+	for _, entry := range sc.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].workflowID < entries[j].workflowID
+	})
+	return entries
+}
+
+// fire runs a scheduled workflow, following its overlap policy when its
+// previous run is still running. It returns the status of the run, nil
+// when the run was queued or skipped.
+func (sc *scheduler) fire(entry *scheduleEntry) (*ExecutionStatus, error) {
+	if entry.running() {
+		switch entry.overlap() {
+		case ast.OverlapQueue:
+			entry.queued = true
+			return nil, nil
+		case ast.OverlapCancelPrevious:
+			log.Info().
+				Str("workflow_id", entry.workflowID).
+				Str("run_id", entry.last.RunID).
+				Msg("Cancelling previous scheduled run")
+			entry.last.cancel()
+		default:
+			entry.skipped++
+			log.Warn().
+				Str("workflow_id", entry.workflowID).
+				Str("run_id", entry.last.RunID).
+				Msg("Skipped scheduled run, the previous run is still running")
+			return nil, errPreviousRunning
+		}
+	}
+
+	return sc.start(entry)
+}
+
+// start starts a scheduled run of a workflow with the inputs of its
+// schedule. Runs are skipped while the server is at capacity or shedding
+// load.
+func (sc *scheduler) start(entry *scheduleEntry) (*ExecutionStatus, error) {
+	err := sc.startable(entry)
+	if err != nil {
+		entry.skipped++
+		log.Warn().Err(err).Str("workflow_id", entry.workflowID).Msg("Skipped scheduled run")
+		return nil, err
+	}
+
+	workflow, _ := sc.server.registry.Get(entry.workflowID)
+	validationResult := engine.ValidateWorkflowInputs(workflow, entry.schedule.Inputs)
+	if !validationResult.Valid {
+		entry.skipped++
+		var problems []string
+		for _, inputErr := range validationResult.Errors {
+			problems = append(problems, fmt.Sprintf("%s: %s", inputErr.Field, inputErr.Message))
+		}
+		err := fmt.Errorf("invalid schedule inputs: %s", strings.Join(problems, ", "))
+		log.Error().Err(err).Str("workflow_id", entry.workflowID).Msg("Skipped scheduled run")
+		return nil, err
+	}
+
+	status, execCtx := sc.server.prepareExecution(entry.workflowID, workflow, validationResult.ProcessedInputs, execcontext.Trigger{
+		Type:     execcontext.TriggerSchedule,
+		Schedule: entry.schedule.Cron,
+	})
+	sc.server.manager.saveExecution(status)
+	entry.last = status
+
+	log.Info().
+		Str("workflow_id", entry.workflowID).
+		Str("run_id", status.RunID).
+		Msg("Started scheduled run")
+
+	go sc.server.executeWorkflowAsync(execCtx.Context.Context, workflow, execCtx, status, nil)
+
+	return status, nil
+}
+
+func (sc *scheduler) startable(entry *scheduleEntry) error {
+	if _, ok := sc.server.registry.Get(entry.workflowID); !ok {
+		return errScheduleNotFound
+	}
+	if !sc.server.manager.CanStartExecution() {
+		return fmt.Errorf("%w, at capacity", errServerBusy)
+	}
+	if reasons := sc.server.shedder.reasons(); len(reasons) > 0 {
+		return fmt.Errorf("%w, shedding load: %v", errServerBusy, reasons)
+	}
+	return nil
+}
+
+// list returns the schedules of the served workflows, sorted by workflow.
+func (sc *scheduler) list() []*ScheduleStatus {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.sync(sc.now())
+
+	statuses := make([]*ScheduleStatus, 0, len(sc.entries))
+	for _, entry := range sc.sortedEntries() {
+		statuses = append(statuses, entry.status())
+	}
+	return statuses
+}
+
+// get returns the schedule of a workflow.
+func (sc *scheduler) get(workflowID string) (*ScheduleStatus, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.sync(sc.now())
+
+	entry, ok := sc.entries[workflowID]
+	if !ok {
+		return nil, errScheduleNotFound
+	}
+	return entry.status(), nil
+}
+
+// setPaused pauses or resumes the schedule of a workflow. Resumed schedules
+// next run at their next time from now, runs missed while paused aren't
+// made up.
+func (sc *scheduler) setPaused(workflowID string, paused bool) (*ScheduleStatus, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := sc.now()
+	sc.sync(now)
+
+	entry, ok := sc.entries[workflowID]
+	if !ok {
+		return nil, errScheduleNotFound
+	}
+
+	if entry.paused && !paused {
+		entry.next = entry.cron.Next(now.In(entry.location))
+	}
+	entry.paused = paused
+
+	log.Info().Str("workflow_id", workflowID).Bool("paused", paused).Msg("Schedule updated")
+
+	return entry.status(), nil
+}
+
+// runNow runs a scheduled workflow straight away, following its overlap
+// policy, whether or not its schedule is paused.
+func (sc *scheduler) runNow(workflowID string) (*ScheduleStatus, *ExecutionStatus, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.sync(sc.now())
+
+	entry, ok := sc.entries[workflowID]
+	if !ok {
+		return nil, nil, errScheduleNotFound
+	}
+
+	status, err := sc.fire(entry)
+	return entry.status(), status, err
+}
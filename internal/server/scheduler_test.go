@@ -0,0 +1,245 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduledWorkflowYAML is the script workflow run every five minutes, the
+// schedule's inputs are how long its runs sleep for.
+func scheduledWorkflowYAML(overlap, delay string) string {
+	return strings.Replace(scriptWorkflowYAML, "  name: script-workflow\n", fmt.Sprintf(`  name: script-workflow
+  schedule:
+    cron: "*/5 * * * *"
+    timezone: UTC
+    overlap: %s
+    inputs:
+      delay: "%s"
+`, overlap, delay), 1)
+}
+
+func newScheduledServer(t *testing.T, workflowYAML string) *Server {
+	t.Helper()
+
+	workflowFile := filepath.Join(t.TempDir(), "script-workflow.laq.yaml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(workflowYAML), 0600))
+
+	config := &Config{
+		Concurrency:   2,
+		Timeout:       30 * time.Second,
+		WorkflowFiles: []string{workflowFile},
+	}
+	server, err := New(config)
+	require.NoError(t, err)
+	server.manager = NewExecutionManagerWithRegistry(config.Concurrency, nil)
+	require.NoError(t, server.LoadWorkflows())
+
+	return server
+}
+
+func waitForRun(t *testing.T, status *ExecutionStatus) {
+	t.Helper()
+
+	select {
+	case <-status.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("run %s didn't finish", status.RunID)
+	}
+}
+
+func lastScheduledRun(t *testing.T, server *Server) *ExecutionStatus {
+	t.Helper()
+
+	schedule, err := server.scheduler.get("script-workflow")
+	require.NoError(t, err)
+	require.NotEmpty(t, schedule.LastRunID)
+
+	status, ok := server.manager.GetExecution(schedule.LastRunID)
+	require.True(t, ok)
+	return status
+}
+
+func TestScheduler_Tick(t *testing.T) {
+	server := newScheduledServer(t, scheduledWorkflowYAML(ast.OverlapSkip, "0"))
+	start := time.Date(2025, 1, 6, 10, 2, 0, 0, time.UTC)
+	server.scheduler.now = func() time.Time { return start }
+
+	server.scheduler.tick(start)
+	schedule, err := server.scheduler.get("script-workflow")
+	require.NoError(t, err)
+	assert.Empty(t, schedule.LastRunID)
+	require.NotNil(t, schedule.NextRun)
+	assert.Equal(t, time.Date(2025, 1, 6, 10, 5, 0, 0, time.UTC), *schedule.NextRun)
+
+	server.scheduler.tick(time.Date(2025, 1, 6, 10, 5, 0, 0, time.UTC))
+	run := lastScheduledRun(t, server)
+	waitForRun(t, run)
+	assert.Equal(t, "completed", run.Status)
+	assert.Equal(t, map[string]any{"delay": "0"}, run.Inputs)
+	assert.Equal(t, "hello", strings.TrimSpace(run.Outputs["greeting"].(string)))
+
+	// runs aren't started again until the next time of the schedule
+	server.scheduler.tick(time.Date(2025, 1, 6, 10, 6, 0, 0, time.UTC))
+	assert.Equal(t, run.RunID, lastScheduledRun(t, server).RunID)
+
+	schedule, err = server.scheduler.get("script-workflow")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 6, 10, 10, 0, 0, time.UTC), *schedule.NextRun)
+}
+
+func TestScheduler_Overlap(t *testing.T) {
+	first := time.Date(2025, 1, 6, 10, 5, 0, 0, time.UTC)
+	second := first.Add(5 * time.Minute)
+
+	t.Run("Skip", func(t *testing.T) {
+		server := newScheduledServer(t, scheduledWorkflowYAML(ast.OverlapSkip, "1"))
+		server.scheduler.tick(first.Add(-time.Minute))
+
+		server.scheduler.tick(first)
+		run := lastScheduledRun(t, server)
+		server.scheduler.tick(second)
+
+		assert.Equal(t, run.RunID, lastScheduledRun(t, server).RunID)
+		schedule, err := server.scheduler.get("script-workflow")
+		require.NoError(t, err)
+		assert.Equal(t, 1, schedule.Skipped)
+
+		waitForRun(t, run)
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		server := newScheduledServer(t, scheduledWorkflowYAML(ast.OverlapQueue, "1"))
+		server.scheduler.tick(first.Add(-time.Minute))
+
+		server.scheduler.tick(first)
+		run := lastScheduledRun(t, server)
+		server.scheduler.tick(second)
+
+		schedule, err := server.scheduler.get("script-workflow")
+		require.NoError(t, err)
+		assert.True(t, schedule.Queued)
+		assert.Equal(t, run.RunID, schedule.LastRunID)
+
+		waitForRun(t, run)
+		server.scheduler.tick(second.Add(time.Second))
+
+		queued := lastScheduledRun(t, server)
+		assert.NotEqual(t, run.RunID, queued.RunID)
+		assert.Equal(t, "completed", run.Status)
+		waitForRun(t, queued)
+	})
+
+	t.Run("Cancel previous", func(t *testing.T) {
+		server := newScheduledServer(t, scheduledWorkflowYAML(ast.OverlapCancelPrevious, "1"))
+		server.scheduler.tick(first.Add(-time.Minute))
+
+		server.scheduler.tick(first)
+		run := lastScheduledRun(t, server)
+		server.scheduler.tick(second)
+
+		waitForRun(t, run)
+		assert.Equal(t, "failed", run.Status)
+
+		next := lastScheduledRun(t, server)
+		assert.NotEqual(t, run.RunID, next.RunID)
+		waitForRun(t, next)
+		assert.Equal(t, "completed", next.Status)
+	})
+}
+
+func TestScheduler_PauseResume(t *testing.T) {
+	server := newScheduledServer(t, scheduledWorkflowYAML(ast.OverlapSkip, "0"))
+	now := time.Date(2025, 1, 6, 10, 2, 0, 0, time.UTC)
+	server.scheduler.now = func() time.Time { return now }
+
+	schedule, err := server.scheduler.setPaused("script-workflow", true)
+	require.NoError(t, err)
+	assert.True(t, schedule.Paused)
+	assert.Nil(t, schedule.NextRun)
+
+	// runs missed while paused aren't made up
+	now = time.Date(2025, 1, 6, 10, 17, 0, 0, time.UTC)
+	server.scheduler.tick(now)
+	schedule, err = server.scheduler.setPaused("script-workflow", false)
+	require.NoError(t, err)
+	assert.False(t, schedule.Paused)
+	assert.Empty(t, schedule.LastRunID)
+	assert.Equal(t, time.Date(2025, 1, 6, 10, 20, 0, 0, time.UTC), *schedule.NextRun)
+
+	_, err = server.scheduler.setPaused("missing-workflow", true)
+	assert.ErrorIs(t, err, errScheduleNotFound)
+}
+
+func TestScheduler_Sync(t *testing.T) {
+	server := newScheduledServer(t, scheduledWorkflowYAML(ast.OverlapSkip, "0"))
+	_, err := server.scheduler.setPaused("script-workflow", true)
+	require.NoError(t, err)
+
+	// schedules stay paused when their workflow is reloaded unchanged
+	workflow, _ := server.registry.Get("script-workflow")
+	server.registry.Replace(map[string]*ast.Workflow{"script-workflow": workflow})
+	schedule, err := server.scheduler.get("script-workflow")
+	require.NoError(t, err)
+	assert.True(t, schedule.Paused)
+
+	server.registry.Replace(map[string]*ast.Workflow{})
+	assert.Empty(t, server.scheduler.list())
+}
+
+func TestServerIntegration_Schedules(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	require.NoError(t, os.WriteFile(suite.workflowFiles[0], []byte(scheduledWorkflowYAML(ast.OverlapSkip, "0")), 0600))
+	require.NoError(t, suite.server.LoadWorkflows())
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/v1/schedules", addr))
+	require.NoError(t, err)
+	var list struct {
+		Schedules []ScheduleStatus `json:"schedules"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	require.Len(t, list.Schedules, 1)
+	assert.Equal(t, "script-workflow", list.Schedules[0].WorkflowID)
+	assert.Equal(t, "*/5 * * * *", list.Schedules[0].Cron)
+	assert.Equal(t, ast.OverlapSkip, list.Schedules[0].Overlap)
+
+	resp, err = http.Post(fmt.Sprintf("http://%s/api/v1/schedules/script-workflow/pause", addr), "application/json", nil)
+	require.NoError(t, err)
+	var schedule ScheduleStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&schedule))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, schedule.Paused)
+
+	// paused schedules can still be run on demand
+	resp, err = http.Post(fmt.Sprintf("http://%s/api/v1/schedules/script-workflow/run", addr), "application/json", nil)
+	require.NoError(t, err)
+	var run map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NotEmpty(t, run["run_id"])
+
+	status, ok := suite.server.manager.GetExecution(run["run_id"].(string))
+	require.True(t, ok)
+	waitForRun(t, status)
+	assert.Equal(t, "completed", status.Status)
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/api/v1/schedules/missing-workflow", addr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -3,11 +3,13 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -16,7 +18,14 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/lacquerai/lacquer/internal/kv"
 	"github.com/lacquerai/lacquer/internal/parser"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/internal/utils"
+	"github.com/lacquerai/lacquer/internal/webui"
 	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -37,21 +46,81 @@ type Config struct {
 	WriteTimeout    time.Duration
 	IdleTimeout     time.Duration
 	ShutdownTimeout time.Duration
-}
+	PreviewLength   int
+	// HistoryRetention limits the run history kept of the server's runs.
+	HistoryRetention history.Retention
+	// HistoryCipher encrypts the run history, the run and event logs and the
+	// values workflows keep between runs when it's set.
+	HistoryCipher *encryption.Cipher
+	// MaxWait limits how long a request to execute a workflow with wait=true
+	// waits for the run to finish, DefaultMaxWait when it isn't set.
+	MaxWait time.Duration
+	// CallbackSecret signs the callbacks sent to the callback_url of execute
+	// requests, callbacks are unsigned when it isn't set.
+	CallbackSecret string
+	// LoadShedding refuses new executions while the system or the model
+	// providers are unhealthy, every check is disabled by default.
+	LoadShedding LoadShedding
+	// EventLogDir is the directory every event of each run is written to,
+	// one JSON event per line in <run_id>.jsonl. No event logs are written
+	// when it isn't set.
+	EventLogDir string
+	// ExecutionStore is the URL of the database executions are persisted
+	// to, sqlite://<path> or postgres://<connection>, see
+	// OpenExecutionStore. Executions are only held in memory when it isn't
+	// set.
+	ExecutionStore string
+	// ExecutionRetention limits the executions kept by the execution store.
+	ExecutionRetention ExecutionRetention
+	// WatchWorkflows reloads the workflow files and the workflow directory
+	// when they change, see Server.ReloadWorkflows.
+	WatchWorkflows bool
+	// EnableUI serves the web UI for the run history of the server at /ui/.
+	EnableUI bool
+	// Auth protects the API and the web UI with API keys and bearer tokens,
+	// which are open to anyone when it isn't configured.
+	Auth Auth
+	// Notifications are the webhooks notified when runs complete or fail,
+	// which requests to execute a workflow may override.
+	Notifications Notifications
+	// EnableScheduler runs the workflows with a schedule on their cron
+	// expressions.
+	EnableScheduler bool
+	// AllowSubmit lets clients with the execute scope run workflows they
+	// send with the request, such as laq run --remote does, rather than
+	// only the workflows loaded by the server. Submitted workflows can run
+	// scripts and containers on the server.
+	AllowSubmit bool
+}
+
+// DefaultMaxWait is how long a request to execute a workflow with wait=true
+// waits for the run to finish at most, unless the server is configured with
+// a different limit.
+const DefaultMaxWait = 2 * time.Minute
+
+// workflowWatchInterval is how often the workflow files are checked for
+// changes when they're watched.
+const workflowWatchInterval = time.Second
 
 // DefaultConfig returns a default server configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Host:            "localhost",
-		Port:            8080,
-		Concurrency:     5,
-		Timeout:         30 * time.Minute,
-		EnableMetrics:   true,
-		EnableCORS:      true,
-		ReadTimeout:     15 * time.Second,
-		WriteTimeout:    15 * time.Second,
-		IdleTimeout:     60 * time.Second,
-		ShutdownTimeout: 30 * time.Second,
+		Host:               "localhost",
+		Port:               8080,
+		Concurrency:        5,
+		Timeout:            30 * time.Minute,
+		EnableMetrics:      true,
+		EnableCORS:         true,
+		EnableUI:           true,
+		EnableScheduler:    true,
+		ReadTimeout:        15 * time.Second,
+		WriteTimeout:       15 * time.Second,
+		IdleTimeout:        60 * time.Second,
+		ShutdownTimeout:    30 * time.Second,
+		PreviewLength:      engine.DefaultPreviewLength,
+		HistoryRetention:   history.DefaultRetention,
+		MaxWait:            DefaultMaxWait,
+		ExecutionRetention: DefaultExecutionRetention,
 	}
 }
 
@@ -75,6 +144,13 @@ func (r *WorkflowRegistry) Register(id string, workflow *ast.Workflow) {
 	r.workflows[id] = workflow
 }
 
+// Replace replaces every workflow of the registry
+func (r *WorkflowRegistry) Replace(workflows map[string]*ast.Workflow) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.workflows = workflows
+}
+
 // Get retrieves a workflow by ID
 func (r *WorkflowRegistry) Get(id string) (*ast.Workflow, bool) {
 	r.mu.RLock()
@@ -112,17 +188,54 @@ type ExecutionStatus struct {
 	Inputs     map[string]any             `json:"inputs"`
 	Outputs    map[string]any             `json:"outputs,omitempty"`
 	Error      string                     `json:"error,omitempty"`
+	Triage     *engine.FailureTriage      `json:"triage,omitempty"`
 	Progress   []pkgEvents.ExecutionEvent `json:"progress,omitempty"`
 
-	// WebSocket connections for streaming
-	clients   map[*websocket.Conn]bool
-	clientsMu sync.RWMutex
+	// stream of progress events read by streaming clients
+	stream *EventStream
+
+	// done is closed once the execution has finished
+	done chan struct{}
+
+	// callbackURL is sent the final status once the execution has finished
+	callbackURL string
+
+	// webhooks are notified of the outcome once the execution has finished
+	webhooks []Webhook
+
+	// persistence is what is kept of the execution, see ast.Workflow.Persistence.
+	// Executions which don't keep their data only hold their metadata, their
+	// outcome is handed to the request which started them and its callback.
+	persistence string
 
 	// Context for cancelling the execution
 	// @TODO handle cancelling the execution
 	cancel context.CancelFunc
 }
 
+// keepsData reports whether the inputs, outputs, errors and progress text of
+// the execution are kept.
+func (s *ExecutionStatus) keepsData() bool {
+	return s.persistence != ast.PersistenceMetadata && s.persistence != ast.PersistenceNone
+}
+
+// setOutcome sets the outputs, error and triage of a finished execution.
+func (s *ExecutionStatus) setOutcome(outputs map[string]any, err error) {
+	s.Outputs = outputs
+
+	if err != nil {
+		s.Status = "failed"
+		s.Error = err.Error()
+
+		var triaged *engine.TriagedError
+		if errors.As(err, &triaged) {
+			s.Triage = triaged.Triage
+		}
+	} else {
+		s.Status = "completed"
+	}
+}
+
 // ExecutionManager handles concurrent workflow executions
 type ExecutionManager struct {
 	executions     map[string]*ExecutionStatus
@@ -135,6 +248,15 @@ type ExecutionManager struct {
 	activeExecutions  prometheus.Gauge
 	executionDuration prometheus.HistogramVec
 	executionStatus   prometheus.CounterVec
+	stepTokens        prometheus.CounterVec
+	stepCost          prometheus.CounterVec
+	// engineMetrics are recorded by the runs of executions
+	engineMetrics *engine.Metrics
+
+	// store persists executions, finished executions are only held by it
+	// rather than in memory when it's set
+	store     ExecutionStore
+	retention ExecutionRetention
 }
 
 // NewExecutionManager creates a new execution manager
@@ -165,6 +287,15 @@ func NewExecutionManagerWithRegistry(maxConcurrency int, registerer prometheus.R
 			Name: "lacquer_execution_status_total",
 			Help: "Total executions by status",
 		}, []string{"workflow_id", "status"}),
+		stepTokens: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lacquer_step_tokens_total",
+			Help: "Total tokens used by completed steps by cost center and owner",
+		}, []string{"workflow_id", "cost_center", "owner"}),
+		stepCost: *prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lacquer_step_cost_usd_total",
+			Help: "Total estimated cost in USD of completed steps by cost center and owner",
+		}, []string{"workflow_id", "cost_center", "owner"}),
+		engineMetrics: engine.NewMetrics(registerer),
 	}
 
 	// Register metrics with the provided registerer
@@ -173,6 +304,9 @@ func NewExecutionManagerWithRegistry(maxConcurrency int, registerer prometheus.R
 		registerer.MustRegister(em.activeExecutions)
 		registerer.MustRegister(em.executionDuration)
 		registerer.MustRegister(em.executionStatus)
+		registerer.MustRegister(em.stepTokens)
+		registerer.MustRegister(em.stepCost)
+		registerer.MustRegister(newBreakerCollector(provider.DefaultBreakers))
 	}
 
 	return em
@@ -207,7 +341,8 @@ func (em *ExecutionManager) StartExecution(runID, workflowID string, cancel cont
 		StartTime:  time.Now(),
 		Inputs:     inputs,
 		Progress:   make([]pkgEvents.ExecutionEvent, 0),
-		clients:    make(map[*websocket.Conn]bool),
+		stream:     NewEventStream(),
+		done:       make(chan struct{}),
 		cancel:     cancel,
 	}
 
@@ -224,21 +359,20 @@ func (em *ExecutionManager) StartExecution(runID, workflowID string, cancel cont
 // FinishExecution marks an execution as finished
 func (em *ExecutionManager) FinishExecution(runID string, outputs map[string]any, err error) {
 	em.mu.Lock()
-	defer em.mu.Unlock()
-
 	status, exists := em.executions[runID]
 	if !exists {
+		em.mu.Unlock()
 		return
 	}
 
 	now := time.Now()
 	status.EndTime = &now
 	status.Duration = now.Sub(status.StartTime)
-	status.Outputs = outputs
 
-	if err != nil {
+	if status.keepsData() {
+		status.setOutcome(outputs, err)
+	} else if err != nil {
 		status.Status = "failed"
-		status.Error = err.Error()
 	} else {
 		status.Status = "completed"
 	}
@@ -250,20 +384,158 @@ func (em *ExecutionManager) FinishExecution(runID string, outputs map[string]any
 	em.executionDuration.WithLabelValues(status.WorkflowID, status.Status).Observe(status.Duration.Seconds())
 	em.executionStatus.WithLabelValues(status.WorkflowID, status.Status).Inc()
 
-	// Close WebSocket clients
-	status.clientsMu.Lock()
-	for client := range status.clients {
-		_ = client.Close()
+	// streaming clients finish once they have received every event
+	status.stream.Close()
+	close(status.done)
+
+	if status.persistence == ast.PersistenceNone {
+		delete(em.executions, runID)
+	}
+	em.mu.Unlock()
+
+	if em.saveExecution(status) {
+		em.mu.Lock()
+		delete(em.executions, runID)
+		em.mu.Unlock()
+
+		em.pruneStore()
 	}
-	status.clientsMu.Unlock()
 }
 
-// GetExecution retrieves an execution status
-func (em *ExecutionManager) GetExecution(runID string) (*ExecutionStatus, bool) {
+// saveExecution saves the execution to the store, and reports whether it
+// was saved. Executions which keep nothing aren't saved.
+func (em *ExecutionManager) saveExecution(status *ExecutionStatus) bool {
+	if em.store == nil || status.persistence == ast.PersistenceNone {
+		return false
+	}
+
+	em.mu.RLock()
+	saved := *status
+	em.mu.RUnlock()
+
+	if err := em.store.SaveExecution(context.Background(), &saved); err != nil {
+		log.Error().Err(err).Str("run_id", status.RunID).Msg("Failed to save execution")
+		return false
+	}
+
+	return true
+}
+
+// pruneStore removes the executions the retention of the store doesn't
+// keep.
+func (em *ExecutionManager) pruneStore() {
+	if em.retention.IsZero() {
+		return
+	}
+
+	removed, err := em.store.Prune(context.Background(), em.retention, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to prune execution store")
+		return
+	}
+	if removed > 0 {
+		log.Debug().Int("removed", removed).Msg("Pruned execution store")
+	}
+}
+
+// outcome returns a copy of the status of a finished execution with its
+// outputs, error and triage, which executions that don't keep their data
+// only hand to the request which started them and its callback.
+func (em *ExecutionManager) outcome(status *ExecutionStatus, outputs map[string]any, err error) *ExecutionStatus {
 	em.mu.RLock()
 	defer em.mu.RUnlock()
+
+	final := *status
+	final.Progress = slices.Clone(status.Progress)
+	final.setOutcome(outputs, err)
+	return &final
+}
+
+// GetExecution retrieves an execution status, from the store when it has
+// finished and the manager has one.
+func (em *ExecutionManager) GetExecution(runID string) (*ExecutionStatus, bool) {
+	em.mu.RLock()
 	status, exists := em.executions[runID]
-	return status, exists
+	em.mu.RUnlock()
+	if exists || em.store == nil {
+		return status, exists
+	}
+
+	status, exists, err := em.store.GetExecution(context.Background(), runID)
+	if err != nil {
+		log.Error().Err(err).Str("run_id", runID).Msg("Failed to read execution from store")
+		return nil, false
+	}
+	if !exists {
+		return nil, false
+	}
+
+	// streaming clients are sent the events of the finished execution
+	status.stream = NewEventStream()
+	for _, event := range status.Progress {
+		status.stream.Publish(event)
+	}
+	status.stream.Close()
+	status.done = make(chan struct{})
+	close(status.done)
+
+	return status, true
+}
+
+// ExecutionFilter selects the executions listed by ListExecutions, zero
+// values don't filter.
+type ExecutionFilter struct {
+	WorkflowID string
+	Status     string
+	// Since lists the executions started at or after it
+	Since time.Time
+}
+
+// ListExecutions returns the executions matching the filter, newest first,
+// skipping offset of them and returning at most limit, along with how many
+// match. The executions are copies without their progress, which is only
+// returned by GetExecution. They're listed from the store when the manager
+// has one.
+func (em *ExecutionManager) ListExecutions(filter ExecutionFilter, offset, limit int) ([]*ExecutionStatus, int, error) {
+	if em.store != nil {
+		return em.store.ListExecutions(context.Background(), filter, offset, limit)
+	}
+
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	var matching []*ExecutionStatus
+	for _, status := range em.executions {
+		if filter.WorkflowID != "" && status.WorkflowID != filter.WorkflowID {
+			continue
+		}
+		if filter.Status != "" && status.Status != filter.Status {
+			continue
+		}
+		if status.StartTime.Before(filter.Since) {
+			continue
+		}
+		matching = append(matching, status)
+	}
+
+	slices.SortFunc(matching, func(a, b *ExecutionStatus) int {
+		if c := b.StartTime.Compare(a.StartTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.RunID, b.RunID)
+	})
+
+	total := len(matching)
+	page := matching[min(offset, total):min(offset+limit, total)]
+
+	executions := make([]*ExecutionStatus, len(page))
+	for i, status := range page {
+		summary := *status
+		summary.Progress = nil
+		executions[i] = &summary
+	}
+
+	return executions, total, nil
 }
 
 // AddProgressEvent adds a progress event to an execution
@@ -276,18 +548,107 @@ func (em *ExecutionManager) AddProgressEvent(runID string, event pkgEvents.Execu
 		return
 	}
 
+	if event.Type == pkgEvents.EventStepCompleted {
+		em.recordStepUsage(status.WorkflowID, event)
+	}
+
 	em.mu.Lock()
-	status.Progress = append(status.Progress, event)
+	if event.Type == pkgEvents.EventTokenDelta {
+		// token deltas are only streamed, the progress of an execution lists
+		// the actions they belong to, and only model output which is kept
+		// is streamed
+		keepsData := status.keepsData()
+		em.mu.Unlock()
+
+		if keepsData {
+			status.stream.Publish(event)
+		}
+		return
+	}
+	if !status.keepsData() {
+		event = event.Redacted()
+	}
+	persisted := status.persistence != ast.PersistenceNone
+	if persisted {
+		status.Progress = append(status.Progress, event)
+	}
 	em.mu.Unlock()
 
-	// Broadcast to WebSocket clients
-	status.clientsMu.RLock()
-	defer status.clientsMu.RUnlock()
+	if persisted && em.store != nil {
+		if err := em.store.AppendEvent(context.Background(), runID, event); err != nil {
+			log.Error().Err(err).Str("run_id", runID).Msg("Failed to save execution event")
+		}
+	}
+
+	status.stream.Publish(event)
+}
+
+// recordStepUsage adds the tokens and cost of a completed top-level step to
+// the metrics of its cost center and owner.
+func (em *ExecutionManager) recordStepUsage(workflowID string, event pkgEvents.ExecutionEvent) {
+	usage, ok := event.Payload().(*pkgEvents.StepCompleted)
+	if !ok || usage.Cached || (usage.Tokens == 0 && usage.Cost == 0) {
+		return
+	}
+
+	em.stepTokens.WithLabelValues(workflowID, usage.CostCenter, usage.Owner).Add(float64(usage.Tokens))
+	em.stepCost.WithLabelValues(workflowID, usage.CostCenter, usage.Owner).Add(usage.Cost)
+}
+
+// result returns the outcome of an execution, or that it's still running,
+// for clients which waited for it to finish.
+func (em *ExecutionManager) result(status *ExecutionStatus) map[string]any {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	result := map[string]any{
+		"run_id":      status.RunID,
+		"workflow_id": status.WorkflowID,
+		"status":      status.Status,
+		"start_time":  status.StartTime,
+	}
+
+	if status.EndTime != nil {
+		result["end_time"] = *status.EndTime
+		result["duration"] = status.Duration
+	}
+	if status.Outputs != nil {
+		result["outputs"] = status.Outputs
+	}
+	if status.Error != "" {
+		result["error"] = status.Error
+	}
+	if status.Triage != nil {
+		result["triage"] = status.Triage
+	}
+
+	return result
+}
+
+// encode returns the JSON of the status of an execution.
+func (em *ExecutionManager) encode(status *ExecutionStatus) ([]byte, error) {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return json.Marshal(status)
+}
+
+// finalEvent returns the terminal event for an execution based on its
+// current status.
+func (em *ExecutionManager) finalEvent(status *ExecutionStatus) pkgEvents.ExecutionEvent {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
 
-	eventJSON, _ := json.Marshal(event)
-	for client := range status.clients {
-		_ = client.WriteMessage(websocket.TextMessage, eventJSON)
+	event := pkgEvents.ExecutionEvent{
+		Type:      pkgEvents.EventWorkflowCompleted,
+		Timestamp: time.Now(),
+		RunID:     status.RunID,
+	}
+	if status.Status == "failed" {
+		event.Type = pkgEvents.EventWorkflowFailed
+		event.Error = status.Error
 	}
+
+	return event
 }
 
 // GetActiveExecutions returns the number of active executions
@@ -304,6 +665,19 @@ type Server struct {
 	manager  *ExecutionManager
 	server   *http.Server
 	upgrader websocket.Upgrader
+	shedder  *loadShedder
+	kv       *kv.Store
+	store    ExecutionStore
+
+	// reloadMu serializes reloads of the workflows
+	reloadMu sync.Mutex
+	// stopWatching stops watching the workflow files for changes
+	stopWatching context.CancelFunc
+
+	// scheduler runs the workflows with a schedule
+	scheduler *scheduler
+	// stopScheduler stops running scheduled workflows
+	stopScheduler context.CancelFunc
 }
 
 // New creates a new Lacquer server
@@ -322,6 +696,22 @@ func New(config *Config) (*Server, error) {
 				return config.EnableCORS // Allow all origins if CORS enabled
 			},
 		},
+		shedder: newLoadShedder(config.LoadShedding, utils.LacquerCacheDir),
+		kv:      kv.NewStore(filepath.Join(utils.LacquerCacheDir, "kv"), config.HistoryCipher),
+	}
+	server.scheduler = newScheduler(server)
+
+	if config.ExecutionStore != "" {
+		store, err := OpenExecutionStore(config.ExecutionStore, config.HistoryCipher)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := failInterrupted(context.Background(), store, time.Now()); err != nil {
+			_ = store.Close()
+			return nil, fmt.Errorf("failed to read execution store: %w", err)
+		}
+		server.store = store
 	}
 
 	return server, nil
@@ -332,18 +722,20 @@ func (s *Server) initializeManager() {
 	if s.manager == nil {
 		s.manager = NewExecutionManager(s.config.Concurrency)
 	}
+
+	if s.store != nil {
+		s.manager.store = s.store
+		s.manager.retention = s.config.ExecutionRetention
+		s.manager.pruneStore()
+	}
 }
 
 // LoadWorkflows loads and validates workflows from the configuration
 func (s *Server) LoadWorkflows() error {
 	// Collect workflow files
-	workflowFiles := s.config.WorkflowFiles
-	if s.config.WorkflowDir != "" {
-		dirFiles, err := s.findWorkflowFiles(s.config.WorkflowDir)
-		if err != nil {
-			return fmt.Errorf("failed to scan workflow directory: %w", err)
-		}
-		workflowFiles = append(workflowFiles, dirFiles...)
+	workflowFiles, err := s.workflowFiles()
+	if err != nil {
+		return fmt.Errorf("failed to scan workflow directory: %w", err)
 	}
 
 	if len(workflowFiles) == 0 {
@@ -357,17 +749,17 @@ func (s *Server) LoadWorkflows() error {
 	}
 
 	log.Info().Msg("Loading and validating workflows...")
-	for _, file := range workflowFiles {
-		workflow, err := yamlParser.ParseFile(file)
-		if err != nil {
-			return fmt.Errorf("failed to parse workflow %s: %w", file, err)
+	for _, parsed := range parser.ParseFiles(yamlParser, workflowFiles) {
+		file, workflow := parsed.Filename, parsed.Workflow
+		if parsed.Err != nil {
+			return fmt.Errorf("failed to parse workflow %s: %w", file, parsed.Err)
 		}
 
-		workflowID := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), ".laq")
-		s.registry.Register(workflowID, workflow)
+		id := workflowID(file)
+		s.registry.Register(id, workflow)
 
 		log.Info().
-			Str("workflow_id", workflowID).
+			Str("workflow_id", id).
 			Str("file", file).
 			Str("version", workflow.Version).
 			Msg("Workflow loaded")
@@ -398,12 +790,23 @@ func (s *Server) Start() error {
 	api.Use(s.loggingMiddleware)
 
 	// Workflow endpoints
-	api.HandleFunc("/workflows", s.listWorkflows).Methods("GET")
-	api.HandleFunc("/workflows/{id}/execute", s.executeWorkflow).Methods("POST")
-	api.HandleFunc("/workflows/{id}/stream", s.streamWorkflow).Methods("GET")
+	api.Handle("/workflows", s.requireScope(ScopeRead, http.HandlerFunc(s.listWorkflows))).Methods("GET")
+	api.Handle("/workflows/reload", s.requireScope(ScopeAdmin, http.HandlerFunc(s.reloadWorkflows))).Methods("POST")
+	api.Handle("/workflows/{id}/execute", s.requireScope(ScopeExecute, http.HandlerFunc(s.executeWorkflow))).Methods("POST")
+	api.Handle("/workflows/{id}/stream", s.requireScope(ScopeRead, http.HandlerFunc(s.streamWorkflow))).Methods("GET")
+
+	// Schedule endpoints
+	api.Handle("/schedules", s.requireScope(ScopeRead, http.HandlerFunc(s.listSchedules))).Methods("GET")
+	api.Handle("/schedules/{id}", s.requireScope(ScopeRead, http.HandlerFunc(s.getSchedule))).Methods("GET")
+	api.Handle("/schedules/{id}/pause", s.requireScope(ScopeAdmin, http.HandlerFunc(s.pauseSchedule))).Methods("POST")
+	api.Handle("/schedules/{id}/resume", s.requireScope(ScopeAdmin, http.HandlerFunc(s.resumeSchedule))).Methods("POST")
+	api.Handle("/schedules/{id}/run", s.requireScope(ScopeExecute, http.HandlerFunc(s.runSchedule))).Methods("POST")
 
 	// Execution endpoints
-	api.HandleFunc("/executions/{runId}", s.getExecution).Methods("GET")
+	api.Handle("/executions", s.requireScope(ScopeRead, http.HandlerFunc(s.listExecutions))).Methods("GET")
+	api.Handle("/executions", s.requireScope(ScopeExecute, http.HandlerFunc(s.submitWorkflow))).Methods("POST")
+	api.Handle("/executions/{runId}", s.requireScope(ScopeRead, http.HandlerFunc(s.getExecution))).Methods("GET")
+	api.Handle("/executions/{runId}/events", s.requireScope(ScopeRead, http.HandlerFunc(s.streamExecutionEvents))).Methods("GET")
 
 	// Handle OPTIONS for CORS preflight
 	if s.config.EnableCORS {
@@ -415,6 +818,13 @@ func (s *Server) Start() error {
 		router.Handle("/metrics", promhttp.Handler())
 	}
 
+	// Web UI for the run history
+	if s.config.EnableUI {
+		store := history.NewStore(filepath.Join(utils.LacquerCacheDir, "history"), history.WithCipher(s.config.HistoryCipher))
+		router.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+		router.PathPrefix("/ui/").Handler(s.requireScope(ScopeRead, http.StripPrefix("/ui", webui.NewHandler(store, filepath.Join(utils.LacquerCacheDir, "logs")))))
+	}
+
 	// Health check
 	router.HandleFunc("/health", s.healthCheck)
 
@@ -433,8 +843,26 @@ func (s *Server) Start() error {
 		Int("workflows", s.registry.Count()).
 		Int("concurrency", s.config.Concurrency).
 		Bool("metrics", s.config.EnableMetrics).
+		Bool("ui", s.config.EnableUI).
+		Bool("auth", s.config.Auth.Enabled()).
+		Bool("watch", s.config.WatchWorkflows).
+		Bool("scheduler", s.config.EnableScheduler).
 		Msg("Starting Lacquer server")
 
+	if s.config.WatchWorkflows {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopWatching = cancel
+		// the watcher is created before returning so that no change is
+		// missed
+		go s.watchWorkflows(ctx, s.newWorkflowWatcher(workflowWatchInterval))
+	}
+
+	if s.config.EnableScheduler {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopScheduler = cancel
+		go s.scheduler.run(ctx)
+	}
+
 	// Start server
 	go func() {
 		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -452,7 +880,21 @@ func (s *Server) Stop(ctx context.Context) error {
 	}
 
 	log.Info().Msg("Shutting down server...")
-	return s.server.Shutdown(ctx)
+	if s.stopWatching != nil {
+		s.stopWatching()
+	}
+	if s.stopScheduler != nil {
+		s.stopScheduler()
+	}
+
+	err := s.server.Shutdown(ctx)
+	if s.store != nil {
+		if closeErr := s.store.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
 }
 
 // StartWithGracefulShutdown starts the server and handles graceful shutdown
@@ -534,3 +976,27 @@ func (s *Server) handleOptions(w http.ResponseWriter, r *http.Request) {
 	// CORS headers are already set by middleware
 	w.WriteHeader(http.StatusOK)
 }
+
+// streamFormat is the JSON representation of events sent to a streaming
+// client.
+type streamFormat string
+
+const (
+	// streamFormatEvent sends events as flat execution events.
+	streamFormatEvent streamFormat = "event"
+	// streamFormatEnvelope sends events as versioned envelopes with a typed
+	// payload.
+	streamFormatEnvelope streamFormat = "envelope"
+)
+
+// encodeEvent encodes the event in the given stream format.
+func encodeEvent(event pkgEvents.ExecutionEvent, format streamFormat) []byte {
+	var data []byte
+	if format == streamFormatEnvelope {
+		data, _ = json.Marshal(event.Envelope())
+	} else {
+		data, _ = json.Marshal(event)
+	}
+
+	return data
+}
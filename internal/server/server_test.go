@@ -15,8 +15,10 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/lacquerai/lacquer/internal/ast"
 	"github.com/lacquerai/lacquer/pkg/events"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -339,6 +341,339 @@ func TestServerIntegration_ExecuteWorkflow_Success(t *testing.T) {
 	assert.NotEmpty(t, execution.StartTime)
 }
 
+const scriptWorkflowYAML = `version: "1.0"
+metadata:
+  name: script-workflow
+inputs:
+  delay:
+    type: string
+    default: "0"
+workflow:
+  steps:
+    - id: greet
+      run: "sleep ${{ inputs.delay }} && echo hello"
+  outputs:
+    greeting: ${{ steps.greet.output }}
+`
+
+func setupScriptTestSuite(t *testing.T) *ServerTestSuite {
+	tempDir := t.TempDir()
+
+	workflowFile := filepath.Join(tempDir, "script-workflow.laq.yaml")
+	require.NoError(t, os.WriteFile(workflowFile, []byte(scriptWorkflowYAML), 0600))
+
+	config := &Config{
+		Host:          "127.0.0.1",
+		Port:          findAvailablePort(),
+		Concurrency:   2,
+		Timeout:       30 * time.Second,
+		WorkflowFiles: []string{workflowFile},
+		ReadTimeout:   5 * time.Second,
+		WriteTimeout:  5 * time.Second,
+		IdleTimeout:   30 * time.Second,
+		MaxWait:       10 * time.Second,
+	}
+
+	server, err := New(config)
+	require.NoError(t, err)
+	server.manager = NewExecutionManagerWithRegistry(config.Concurrency, nil)
+	require.NoError(t, server.LoadWorkflows())
+
+	return &ServerTestSuite{
+		server:        server,
+		tempDir:       tempDir,
+		workflowFiles: config.WorkflowFiles,
+		config:        config,
+	}
+}
+
+func TestServerIntegration_ExecuteWorkflow_Wait(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute?wait=true", addr),
+		"application/json",
+		strings.NewReader(`{"inputs": {}}`),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var result map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	assert.NotEmpty(t, result["run_id"])
+	assert.Equal(t, "script-workflow", result["workflow_id"])
+	assert.Equal(t, "completed", result["status"])
+	assert.Contains(t, result, "end_time")
+	require.IsType(t, map[string]any{}, result["outputs"])
+	assert.Equal(t, "hello", strings.TrimSpace(result["outputs"].(map[string]any)["greeting"].(string)))
+}
+
+func TestServerIntegration_ExecuteWorkflow_SyncMode(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute", addr),
+		"application/json",
+		strings.NewReader(`{"inputs": {}, "mode": "sync"}`),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "completed", result["status"])
+	require.IsType(t, map[string]any{}, result["outputs"])
+	assert.Equal(t, "hello", strings.TrimSpace(result["outputs"].(map[string]any)["greeting"].(string)))
+}
+
+func TestServerIntegration_ExecuteWorkflow_WaitTimeout(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute?wait=true&wait_timeout=50ms", addr),
+		"application/json",
+		strings.NewReader(`{"inputs": {"delay": "1"}}`),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the run carries on and can be polled
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var result map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "running", result["status"])
+	assert.NotContains(t, result, "outputs")
+
+	status, ok := suite.server.manager.GetExecution(result["run_id"].(string))
+	require.True(t, ok)
+	select {
+	case <-status.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("execution did not finish")
+	}
+	assert.Equal(t, "completed", suite.server.manager.result(status)["status"])
+}
+
+func TestServerIntegration_ExecuteWorkflow_Persistence(t *testing.T) {
+	for _, persistence := range []string{ast.PersistenceMetadata, ast.PersistenceNone} {
+		t.Run(persistence, func(t *testing.T) {
+			suite := setupScriptTestSuite(t)
+			defer suite.cleanup(t)
+
+			workflow, ok := suite.server.registry.Get("script-workflow")
+			require.True(t, ok)
+			workflow.Persistence = persistence
+
+			addr := suite.startServerInBackground(t)
+
+			resp, err := http.Post(
+				fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute?wait=true", addr),
+				"application/json",
+				strings.NewReader(`{"inputs": {"delay": "0"}}`),
+			)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			// the request which started the run still receives its outputs
+			var result map[string]any
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+			assert.Equal(t, "completed", result["status"])
+			require.IsType(t, map[string]any{}, result["outputs"])
+			assert.Equal(t, "hello", strings.TrimSpace(result["outputs"].(map[string]any)["greeting"].(string)))
+
+			status, ok := suite.server.manager.GetExecution(result["run_id"].(string))
+			if persistence == ast.PersistenceNone {
+				assert.False(t, ok)
+				return
+			}
+
+			require.True(t, ok)
+			body, err := suite.server.manager.encode(status)
+			require.NoError(t, err)
+			assert.NotContains(t, string(body), "hello")
+			assert.Nil(t, status.Inputs)
+			assert.Nil(t, status.Outputs)
+			assert.Equal(t, "completed", status.Status)
+			assert.NotEmpty(t, status.Progress)
+		})
+	}
+}
+
+func TestServerIntegration_ExecuteWorkflow_EventLog(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	suite.config.EventLogDir = filepath.Join(suite.tempDir, "events")
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute?wait=true", addr),
+		"application/json",
+		strings.NewReader(`{"inputs": {"delay": "0"}}`),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, "completed", result["status"])
+
+	// every event of the run is written to the run's event log
+	runID := result["run_id"].(string)
+	data, err := os.ReadFile(filepath.Join(suite.config.EventLogDir, runID+".jsonl")) // #nosec G304 - test file path is controlled
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var first, last events.ExecutionEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	assert.Equal(t, events.EventWorkflowStarted, first.Type)
+	assert.Equal(t, events.EventWorkflowCompleted, last.Type)
+	assert.Equal(t, runID, last.RunID)
+}
+
+func TestServerIntegration_ExecuteWorkflow_InvalidWait(t *testing.T) {
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+
+	addr := suite.startServerInBackground(t)
+
+	for _, query := range []string{"wait=soon", "wait=true&wait_timeout=forever", "wait=true&wait_timeout=-1s"} {
+		resp, err := http.Post(
+			fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute?%s", addr, query),
+			"application/json",
+			strings.NewReader(`{}`),
+		)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+
+	assert.Equal(t, 0, suite.server.manager.GetActiveExecutions())
+}
+
+func TestServerIntegration_SubmitWorkflow(t *testing.T) {
+	submit := func(t *testing.T, addr string, workflow string) *http.Response {
+		t.Helper()
+		body, err := json.Marshal(map[string]any{
+			"workflow": workflow,
+			"filename": "/home/me/greet.laq.yaml",
+			"inputs":   map[string]any{"delay": "0"},
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Post(fmt.Sprintf("http://%s/api/v1/executions?wait=true", addr), "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		suite := setupScriptTestSuite(t)
+		defer suite.cleanup(t)
+		addr := suite.startServerInBackground(t)
+
+		resp := submit(t, addr, scriptWorkflowYAML)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Equal(t, 0, suite.server.manager.GetActiveExecutions())
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		suite := setupScriptTestSuite(t)
+		suite.config.AllowSubmit = true
+		defer suite.cleanup(t)
+		addr := suite.startServerInBackground(t)
+
+		resp := submit(t, addr, scriptWorkflowYAML)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result map[string]any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, "greet", result["workflow_id"])
+		assert.Equal(t, "completed", result["status"])
+		require.IsType(t, map[string]any{}, result["outputs"])
+		assert.Equal(t, "hello", strings.TrimSpace(result["outputs"].(map[string]any)["greeting"].(string)))
+
+		// submitted workflows aren't loaded by the server
+		_, exists := suite.server.registry.Get("greet")
+		assert.False(t, exists)
+	})
+
+	t.Run("invalid workflow", func(t *testing.T) {
+		suite := setupScriptTestSuite(t)
+		suite.config.AllowSubmit = true
+		defer suite.cleanup(t)
+		addr := suite.startServerInBackground(t)
+
+		resp := submit(t, addr, strings.Replace(scriptWorkflowYAML, "run:", "agent: missing\n      prompt:", 1))
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		var result struct {
+			Error       string           `json:"error"`
+			Diagnostics []map[string]any `json:"diagnostics"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, "Invalid workflow", result.Error)
+		require.NotEmpty(t, result.Diagnostics)
+		assert.Equal(t, "greet.laq.yaml", result.Diagnostics[0]["file"])
+	})
+}
+
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		query    string
+		mode     string
+		expected time.Duration
+	}{
+		{query: "", expected: 0},
+		{query: "wait=false", expected: 0},
+		{query: "wait=true", expected: time.Minute},
+		{query: "wait=1&wait_timeout=10s", expected: 10 * time.Second},
+		// the wait is limited to the server's maximum
+		{query: "wait=true&wait_timeout=1h", expected: time.Minute},
+		{mode: "async", expected: 0},
+		{mode: "sync", expected: time.Minute},
+		{query: "wait_timeout=10s", mode: "sync", expected: 10 * time.Second},
+		// the wait parameter takes precedence over the mode
+		{query: "wait=false", mode: "sync", expected: 0},
+	}
+
+	for _, tt := range tests {
+		r, err := http.NewRequest(http.MethodPost, "/execute?"+tt.query, nil)
+		require.NoError(t, err)
+
+		wait, err := parseWait(r, tt.mode, time.Minute)
+		require.NoError(t, err, tt.query)
+		assert.Equal(t, tt.expected, wait, tt.query)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, "/execute?wait=true", nil)
+	require.NoError(t, err)
+	wait, err := parseWait(r, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxWait, wait)
+
+	_, err = parseWait(r, "blocking", time.Minute)
+	assert.EqualError(t, err, "invalid mode 'blocking', must be sync or async")
+}
+
 func TestServerIntegration_GetExecution_NotFound(t *testing.T) {
 	suite := setupTestSuite(t)
 	defer suite.cleanup(t)
@@ -522,6 +857,25 @@ func TestServerIntegration_PrometheusMetrics(t *testing.T) {
 	// So we'll just check for the basic metrics that are always present
 }
 
+func TestServerIntegration_WebUI(t *testing.T) {
+	suite := setupTestSuite(t)
+	defer suite.cleanup(t)
+
+	suite.config.EnableUI = true
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/ui/", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/ui/api/runs", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
 func TestServerIntegration_WorkflowDirectory(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "lacquer-server-dir-test-*")
 	require.NoError(t, err)
@@ -1253,6 +1607,7 @@ func TestExecutionManager_NewManager(t *testing.T) {
 	manager := NewExecutionManagerWithRegistry(5, registry)
 
 	assert.NotNil(t, manager)
+	assert.NotNil(t, manager.engineMetrics)
 	assert.Equal(t, 5, manager.maxConcurrency)
 	assert.Equal(t, 0, manager.currentCount)
 	assert.Equal(t, 0, manager.GetActiveExecutions())
@@ -1450,3 +1805,165 @@ func TestExecutionManager_MultipleExecutions(t *testing.T) {
 	assert.True(t, exists2)
 	assert.Equal(t, "failed", exec2.Status)
 }
+
+func TestExecutionManager_ListExecutions(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	manager := NewExecutionManagerWithRegistry(5, registry)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, workflowID := range []string{"review", "summarize", "review", "review"} {
+		status := manager.StartExecution(fmt.Sprintf("run-%d", i), workflowID, func() {}, nil)
+		status.StartTime = start.Add(time.Duration(i) * time.Minute)
+		manager.AddProgressEvent(status.RunID, events.ExecutionEvent{Type: events.EventStepStarted, RunID: status.RunID})
+	}
+	manager.FinishExecution("run-0", nil, nil)
+	manager.FinishExecution("run-2", nil, assert.AnError)
+
+	runIDs := func(executions []*ExecutionStatus) []string {
+		ids := make([]string, len(executions))
+		for i, execution := range executions {
+			ids[i] = execution.RunID
+		}
+		return ids
+	}
+
+	// newest first, without their progress
+	executions, total, err := manager.ListExecutions(ExecutionFilter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []string{"run-3", "run-2", "run-1", "run-0"}, runIDs(executions))
+	assert.Nil(t, executions[0].Progress)
+	status, _ := manager.GetExecution("run-3")
+	assert.Len(t, status.Progress, 1)
+
+	executions, total, _ = manager.ListExecutions(ExecutionFilter{}, 1, 2)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []string{"run-2", "run-1"}, runIDs(executions))
+
+	executions, total, _ = manager.ListExecutions(ExecutionFilter{}, 10, 2)
+	assert.Equal(t, 4, total)
+	assert.Empty(t, executions)
+
+	executions, total, _ = manager.ListExecutions(ExecutionFilter{WorkflowID: "review", Status: "running"}, 0, 10)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []string{"run-3"}, runIDs(executions))
+
+	executions, _, _ = manager.ListExecutions(ExecutionFilter{Since: start.Add(2 * time.Minute)}, 0, 10)
+	assert.Equal(t, []string{"run-3", "run-2"}, runIDs(executions))
+}
+
+func TestParseExecutionsQuery(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	r, err := http.NewRequest(http.MethodGet, "/executions?workflow_id=review&status=failed&since=24h&offset=20&limit=10", nil)
+	require.NoError(t, err)
+	filter, offset, limit, err := parseExecutionsQuery(r, now)
+	require.NoError(t, err)
+	assert.Equal(t, ExecutionFilter{WorkflowID: "review", Status: "failed", Since: now.Add(-24 * time.Hour)}, filter)
+	assert.Equal(t, 20, offset)
+	assert.Equal(t, 10, limit)
+
+	r, err = http.NewRequest(http.MethodGet, "/executions", nil)
+	require.NoError(t, err)
+	filter, offset, limit, err = parseExecutionsQuery(r, now)
+	require.NoError(t, err)
+	assert.Equal(t, ExecutionFilter{}, filter)
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, defaultExecutionsLimit, limit)
+
+	for query, expected := range map[string]string{
+		"status=done":  "invalid status 'done', must be one of running, completed, failed",
+		"since=soon":   `invalid since "soon", use a duration such as 30d or 12h, or a date such as 2024-01-31`,
+		"offset=-1":    "invalid offset '-1', must be a number of at least 0",
+		"limit=0":      "invalid limit '0', must be a number from 1 to 500",
+		"limit=1000":   "invalid limit '1000', must be a number from 1 to 500",
+		"limit=twenty": "invalid limit 'twenty', must be a number from 1 to 500",
+	} {
+		r, err := http.NewRequest(http.MethodGet, "/executions?"+query, nil)
+		require.NoError(t, err)
+		_, _, _, err = parseExecutionsQuery(r, now)
+		assert.EqualError(t, err, expected, query)
+	}
+}
+
+func TestServerIntegration_ListExecutions(t *testing.T) {
+	suite := setupTestSuite(t)
+	defer suite.cleanup(t)
+
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/v1/executions?limit=5", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list ExecutionList
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	assert.Equal(t, ExecutionList{Executions: []*ExecutionStatus{}, Limit: 5}, list)
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/api/v1/executions?status=done", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestExecutionManager_StepUsageMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	manager := NewExecutionManagerWithRegistry(2, registry)
+	manager.StartExecution("run-1", "triage", func() {}, map[string]any{})
+
+	completed := func(metadata map[string]any) events.ExecutionEvent {
+		return events.ExecutionEvent{Type: events.EventStepCompleted, RunID: "run-1", StepID: "classify", Metadata: metadata}
+	}
+
+	manager.AddProgressEvent("run-1", completed(map[string]any{
+		events.MetadataCostCenter: "support",
+		events.MetadataOwner:      "cx",
+		events.MetadataTokens:     150,
+		events.MetadataCost:       0.25,
+	}))
+	manager.AddProgressEvent("run-1", completed(map[string]any{
+		events.MetadataCostCenter: "support",
+		events.MetadataOwner:      "cx",
+		events.MetadataTokens:     50,
+		events.MetadataCost:       0.05,
+	}))
+	// cached steps didn't use any tokens in this run
+	manager.AddProgressEvent("run-1", completed(map[string]any{
+		events.MetadataCached:     true,
+		events.MetadataCostCenter: "support",
+		events.MetadataOwner:      "cx",
+		events.MetadataTokens:     1000,
+		events.MetadataCost:       10.0,
+	}))
+
+	assert.Equal(t, 200.0, testutil.ToFloat64(manager.stepTokens.WithLabelValues("triage", "support", "cx")))
+	assert.InDelta(t, 0.3, testutil.ToFloat64(manager.stepCost.WithLabelValues("triage", "support", "cx")), 1e-9)
+}
+
+func TestServerIntegration_ExecutionEvents(t *testing.T) {
+	suite := setupTestSuite(t)
+	defer suite.cleanup(t)
+
+	addr := suite.startServerInBackground(t)
+
+	status := suite.server.manager.StartExecution("run-sse", "test-workflow", func() {}, map[string]any{})
+	suite.server.manager.AddProgressEvent("run-sse", events.ExecutionEvent{Type: events.EventStepStarted, RunID: "run-sse", StepID: "testStep"})
+	suite.server.manager.FinishExecution("run-sse", map[string]any{"result": "done"}, nil)
+	<-status.done
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/api/v1/executions/run-sse/events?format=envelope", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	messages := readSSE(t, resp.Body)
+	require.NotEmpty(t, messages)
+	assert.Contains(t, messages[0], `"kind":"step_started"`)
+	assert.Equal(t, "end", messages[len(messages)-1])
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/api/v1/executions/run-missing/events", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
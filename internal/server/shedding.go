@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultProviderErrorWindow is the period provider error rates are
+	// measured over unless the server is configured with a different one.
+	DefaultProviderErrorWindow = 5 * time.Minute
+	// DefaultMinProviderRequests is the number of requests a provider must
+	// have received within the window before its error rate is considered.
+	DefaultMinProviderRequests = 10
+
+	// sheddingRetryAfter is how long clients refused while the server is
+	// shedding load are asked to wait before retrying.
+	sheddingRetryAfter = 30 * time.Second
+)
+
+// LoadShedding configures the checks which refuse new executions while the
+// system or the model providers are unhealthy. A zero threshold disables its
+// check.
+type LoadShedding struct {
+	// MaxMemoryUsage is the percentage of system memory in use above which
+	// new executions are refused.
+	MaxMemoryUsage float64
+	// MaxDiskUsage is the percentage of the disk holding the lacquer cache
+	// directory in use above which new executions are refused.
+	MaxDiskUsage float64
+	// MaxProviderErrorRate is the fraction, between 0 and 1, of failed
+	// requests to a model provider above which new executions are refused.
+	MaxProviderErrorRate float64
+	// ProviderErrorWindow is the period provider error rates are measured
+	// over, DefaultProviderErrorWindow when it isn't set.
+	ProviderErrorWindow time.Duration
+	// MinProviderRequests is the number of requests a provider must have
+	// received within the window before its error rate is considered,
+	// DefaultMinProviderRequests when it isn't set.
+	MinProviderRequests int
+}
+
+// IsZero reports whether every check is disabled.
+func (l LoadShedding) IsZero() bool {
+	return l.MaxMemoryUsage <= 0 && l.MaxDiskUsage <= 0 && l.MaxProviderErrorRate <= 0
+}
+
+// SheddingReason explains why new executions are refused.
+type SheddingReason struct {
+	// Check is the check which failed: memory, disk or provider_errors.
+	Check     string  `json:"check"`
+	Message   string  `json:"message"`
+	Provider  string  `json:"provider,omitempty"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+// systemUsage returns the percentages of system memory and of the disk
+// holding dir in use, it's a variable so that tests can replace it.
+var systemUsage = func(dir string) (memory, disk float64, err error) {
+	// the cache directory is created by the first run, until then the disk
+	// is that of its closest existing parent
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	memory, memErr := memoryUsage()
+	disk, diskErr := diskUsage(dir)
+	return memory, disk, errors.Join(memErr, diskErr)
+}
+
+// loadShedder decides whether new executions are accepted from the usage of
+// the system and the error rates of the model providers used by previous
+// executions.
+type loadShedder struct {
+	config   LoadShedding
+	cacheDir string
+
+	mu       sync.Mutex
+	requests map[string][]providerRequest
+
+	// warnOnce logs a failure to read the system usage only once
+	warnOnce sync.Once
+}
+
+// providerRequest is the outcome of a request sent to a model provider.
+type providerRequest struct {
+	at     time.Time
+	failed bool
+}
+
+func newLoadShedder(config LoadShedding, cacheDir string) *loadShedder {
+	if config.ProviderErrorWindow <= 0 {
+		config.ProviderErrorWindow = DefaultProviderErrorWindow
+	}
+	if config.MinProviderRequests <= 0 {
+		config.MinProviderRequests = DefaultMinProviderRequests
+	}
+
+	return &loadShedder{
+		config:   config,
+		cacheDir: cacheDir,
+		requests: make(map[string][]providerRequest),
+	}
+}
+
+// ObserveProviderRequest records the outcome of a request to a provider,
+// it implements engine.ProviderObserver.
+func (l *loadShedder) ObserveProviderRequest(provider string, err error) {
+	if l.config.MaxProviderErrorRate <= 0 {
+		return
+	}
+
+	// the cancellation of a whole run says nothing about the provider
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests[provider] = append(l.prune(provider, now), providerRequest{at: now, failed: err != nil})
+}
+
+// prune drops the requests to the provider which are older than the window.
+func (l *loadShedder) prune(provider string, now time.Time) []providerRequest {
+	requests := l.requests[provider]
+
+	cutoff := now.Add(-l.config.ProviderErrorWindow)
+	i := sort.Search(len(requests), func(i int) bool { return requests[i].at.After(cutoff) })
+	if i == len(requests) {
+		delete(l.requests, provider)
+		return nil
+	}
+
+	requests = requests[i:]
+	l.requests[provider] = requests
+	return requests
+}
+
+// reasons returns why new executions are refused, nil when they are
+// accepted.
+func (l *loadShedder) reasons() []SheddingReason {
+	if l == nil {
+		return nil
+	}
+
+	var reasons []SheddingReason
+
+	if l.config.MaxMemoryUsage > 0 || l.config.MaxDiskUsage > 0 {
+		memory, disk, err := systemUsage(l.cacheDir)
+		if err != nil {
+			l.warnOnce.Do(func() {
+				log.Warn().Err(err).Msg("Failed to read system usage, executions aren't refused for the checks it's needed by")
+			})
+		}
+
+		if l.config.MaxMemoryUsage > 0 && memory > l.config.MaxMemoryUsage {
+			reasons = append(reasons, SheddingReason{
+				Check:     "memory",
+				Message:   fmt.Sprintf("memory usage is %.1f%%, above the limit of %.1f%%", memory, l.config.MaxMemoryUsage),
+				Value:     memory,
+				Threshold: l.config.MaxMemoryUsage,
+			})
+		}
+
+		if l.config.MaxDiskUsage > 0 && disk > l.config.MaxDiskUsage {
+			reasons = append(reasons, SheddingReason{
+				Check:     "disk",
+				Message:   fmt.Sprintf("disk usage is %.1f%%, above the limit of %.1f%%", disk, l.config.MaxDiskUsage),
+				Value:     disk,
+				Threshold: l.config.MaxDiskUsage,
+			})
+		}
+	}
+
+	if l.config.MaxProviderErrorRate > 0 {
+		reasons = append(reasons, l.providerReasons(time.Now())...)
+	}
+
+	return reasons
+}
+
+// providerReasons returns a reason for each provider whose error rate within
+// the window is above the limit, ordered by provider.
+func (l *loadShedder) providerReasons(now time.Time) []SheddingReason {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	providers := make([]string, 0, len(l.requests))
+	for provider := range l.requests {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	var reasons []SheddingReason
+	for _, provider := range providers {
+		requests := l.prune(provider, now)
+		if len(requests) < l.config.MinProviderRequests {
+			continue
+		}
+
+		failed := 0
+		for _, request := range requests {
+			if request.failed {
+				failed++
+			}
+		}
+
+		rate := float64(failed) / float64(len(requests))
+		if rate > l.config.MaxProviderErrorRate {
+			reasons = append(reasons, SheddingReason{
+				Check:     "provider_errors",
+				Message:   fmt.Sprintf("%d of the last %d requests to %s failed, above the limit of %.0f%%", failed, len(requests), provider, l.config.MaxProviderErrorRate*100),
+				Provider:  provider,
+				Value:     rate,
+				Threshold: l.config.MaxProviderErrorRate,
+			})
+		}
+	}
+
+	return reasons
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package server
+
+import "errors"
+
+// memoryUsage isn't supported on this platform.
+func memoryUsage() (float64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// diskUsage isn't supported on this platform.
+func diskUsage(string) (float64, error) {
+	return 0, errors.ErrUnsupported
+}
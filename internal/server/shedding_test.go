@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubSystemUsage(t *testing.T, memory, disk float64) {
+	t.Helper()
+
+	original := systemUsage
+	systemUsage = func(string) (float64, float64, error) { return memory, disk, nil }
+	t.Cleanup(func() { systemUsage = original })
+}
+
+func TestLoadShedder_SystemUsage(t *testing.T) {
+	stubSystemUsage(t, 95, 50)
+
+	shedder := newLoadShedder(LoadShedding{MaxMemoryUsage: 90, MaxDiskUsage: 80}, t.TempDir())
+	reasons := shedder.reasons()
+	require.Len(t, reasons, 1)
+	assert.Equal(t, "memory", reasons[0].Check)
+	assert.Equal(t, float64(95), reasons[0].Value)
+	assert.Equal(t, float64(90), reasons[0].Threshold)
+
+	// checks which aren't configured never refuse executions
+	assert.Empty(t, newLoadShedder(LoadShedding{}, t.TempDir()).reasons())
+}
+
+func TestLoadShedder_ProviderErrors(t *testing.T) {
+	shedder := newLoadShedder(LoadShedding{MaxProviderErrorRate: 0.5, MinProviderRequests: 4, ProviderErrorWindow: time.Minute}, "")
+
+	shedder.ObserveProviderRequest("anthropic", nil)
+	shedder.ObserveProviderRequest("anthropic", errors.New("overloaded"))
+	shedder.ObserveProviderRequest("anthropic", errors.New("overloaded"))
+	shedder.ObserveProviderRequest("anthropic", context.Canceled)
+	shedder.ObserveProviderRequest("openai", errors.New("rate limited"))
+
+	// too few requests to judge either provider
+	assert.Empty(t, shedder.reasons())
+
+	shedder.ObserveProviderRequest("anthropic", errors.New("overloaded"))
+	reasons := shedder.reasons()
+	require.Len(t, reasons, 1)
+	assert.Equal(t, "provider_errors", reasons[0].Check)
+	assert.Equal(t, "anthropic", reasons[0].Provider)
+	assert.Equal(t, 0.75, reasons[0].Value)
+	assert.Equal(t, "3 of the last 4 requests to anthropic failed, above the limit of 50%", reasons[0].Message)
+
+	// requests older than the window are forgotten
+	assert.Empty(t, shedder.providerReasons(time.Now().Add(2*time.Minute)))
+	assert.Empty(t, shedder.requests)
+}
+
+func TestServerIntegration_ExecuteWorkflow_LoadShedding(t *testing.T) {
+	stubSystemUsage(t, 97.5, 40)
+
+	suite := setupScriptTestSuite(t)
+	defer suite.cleanup(t)
+	suite.server.shedder = newLoadShedder(LoadShedding{MaxMemoryUsage: 90}, t.TempDir())
+
+	addr := suite.startServerInBackground(t)
+
+	resp, err := http.Post(
+		fmt.Sprintf("http://%s/api/v1/workflows/script-workflow/execute", addr),
+		"application/json",
+		strings.NewReader(`{"inputs": {}}`),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "30", resp.Header.Get("Retry-After"))
+
+	var refused struct {
+		Error   string           `json:"error"`
+		Reasons []SheddingReason `json:"reasons"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&refused))
+	assert.NotEmpty(t, refused.Error)
+	require.Len(t, refused.Reasons, 1)
+	assert.Equal(t, "memory", refused.Reasons[0].Check)
+	assert.Equal(t, 0, suite.server.manager.GetActiveExecutions())
+
+	health, err := http.Get(fmt.Sprintf("http://%s/health", addr))
+	require.NoError(t, err)
+	defer health.Body.Close()
+
+	// the server is still alive while it sheds load
+	assert.Equal(t, http.StatusOK, health.StatusCode)
+
+	var status map[string]any
+	require.NoError(t, json.NewDecoder(health.Body).Decode(&status))
+	assert.Equal(t, "degraded", status["status"])
+	require.IsType(t, map[string]any{}, status["load_shedding"])
+	assert.Equal(t, false, status["load_shedding"].(map[string]any)["accepting_executions"])
+}
@@ -0,0 +1,65 @@
+//go:build unix
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// memoryUsage returns the percentage of system memory in use, read from
+// /proc/meminfo so it's only available on Linux.
+func memoryUsage() (float64, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+	defer file.Close()
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if kb, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			values[name] = kb
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read memory usage: %w", err)
+	}
+
+	total, available := values["MemTotal"], values["MemAvailable"]
+	if total == 0 {
+		return 0, fmt.Errorf("failed to read memory usage: MemTotal missing from /proc/meminfo")
+	}
+
+	return (total - available) / total * 100, nil
+}
+
+// diskUsage returns the percentage of the disk holding dir in use.
+func diskUsage(dir string) (float64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("failed to read disk usage: %w", err)
+	}
+
+	if stat.Blocks == 0 {
+		return 0, nil
+	}
+
+	return float64(stat.Blocks-stat.Bavail) / float64(stat.Blocks) * 100, nil
+}
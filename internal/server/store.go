@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+// ExecutionStore persists the executions of the server, so that their
+// status, progress and outputs outlive it. Without a store executions are
+// only held in memory.
+type ExecutionStore interface {
+	// SaveExecution creates or replaces an execution, without its progress
+	SaveExecution(ctx context.Context, status *ExecutionStatus) error
+	// AppendEvent adds an event to the progress of an execution
+	AppendEvent(ctx context.Context, runID string, event pkgEvents.ExecutionEvent) error
+	// GetExecution returns an execution with its progress, false when the
+	// store doesn't hold it
+	GetExecution(ctx context.Context, runID string) (*ExecutionStatus, bool, error)
+	// ListExecutions returns the executions matching the filter, newest
+	// first, skipping offset of them and returning at most limit without
+	// their progress, along with how many match.
+	ListExecutions(ctx context.Context, filter ExecutionFilter, offset, limit int) ([]*ExecutionStatus, int, error)
+	// Prune removes the finished executions the retention doesn't keep, and
+	// returns how many were removed.
+	Prune(ctx context.Context, retention ExecutionRetention, now time.Time) (int, error)
+	Close() error
+}
+
+// DefaultExecutionRetention keeps executions for 90 days.
+var DefaultExecutionRetention = ExecutionRetention{
+	MaxAge: 90 * 24 * time.Hour,
+}
+
+// ExecutionRetention limits the executions kept by an execution store. A
+// zero limit keeps everything, running executions are always kept.
+type ExecutionRetention struct {
+	// MaxAge removes executions started longer ago than it.
+	MaxAge time.Duration
+	// MaxExecutions removes the oldest executions while the store holds
+	// more than it.
+	MaxExecutions int
+}
+
+// IsZero reports whether the retention keeps everything.
+func (r ExecutionRetention) IsZero() bool {
+	return r.MaxAge <= 0 && r.MaxExecutions <= 0
+}
+
+// OpenExecutionStore opens the execution store at the URL, either
+// sqlite://<path> for a SQLite database file or a postgres:// connection
+// URL. The inputs, outputs, errors and progress of executions are encrypted
+// with the cipher when it's set.
+func OpenExecutionStore(url string, cipher *encryption.Cipher) (ExecutionStore, error) {
+	switch {
+	case strings.HasPrefix(url, "sqlite://"):
+		return openSQLiteStore(strings.TrimPrefix(url, "sqlite://"), cipher)
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		return openPostgresStore(url, cipher)
+	default:
+		return nil, fmt.Errorf("unsupported execution store %q, use sqlite://<path> or postgres://<connection>", redactStoreURL(url))
+	}
+}
+
+// redactStoreURL removes the password from a store URL for error messages.
+func redactStoreURL(url string) string {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return url
+	}
+
+	credentials, host, ok := strings.Cut(rest, "@")
+	if !ok {
+		return url
+	}
+	if user, _, hasPassword := strings.Cut(credentials, ":"); hasPassword {
+		return scheme + "://" + user + ":xxxxx@" + host
+	}
+
+	return url
+}
+
+// interruptedError is the error of executions which were still running
+// when the server stopped.
+const interruptedError = "the server stopped before the execution finished"
+
+// failInterrupted marks the executions the store holds as running as failed,
+// as the server which ran them has stopped, and returns how many there were.
+func failInterrupted(ctx context.Context, store ExecutionStore, now time.Time) (int, error) {
+	failed := 0
+	for {
+		// executions no longer match once they're saved as failed
+		running, _, err := store.ListExecutions(ctx, ExecutionFilter{Status: "running"}, 0, maxExecutionsLimit)
+		if err != nil || len(running) == 0 {
+			return failed, err
+		}
+
+		for _, listed := range running {
+			// listed executions don't have their progress, which saving
+			// doesn't replace
+			status := *listed
+			status.Status = "failed"
+			status.Error = interruptedError
+			status.EndTime = &now
+			status.Duration = now.Sub(status.StartTime)
+
+			if err := store.SaveExecution(ctx, &status); err != nil {
+				return failed, err
+			}
+			failed++
+
+			log.Warn().
+				Str("run_id", status.RunID).
+				Str("workflow_id", status.WorkflowID).
+				Msg("Execution was interrupted by the server stopping")
+		}
+	}
+}
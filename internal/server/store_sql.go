@@ -0,0 +1,369 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the pgx driver
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/engine"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+// sqlSchema creates the tables of an execution store. Times are stored as
+// nanoseconds since the Unix epoch, the data of executions and their events
+// as JSON which is encrypted when the store has a cipher. %s is the type of
+// binary columns.
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS executions (
+	run_id TEXT PRIMARY KEY,
+	workflow_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	start_time BIGINT NOT NULL,
+	end_time BIGINT,
+	duration BIGINT NOT NULL,
+	data %[1]s NOT NULL
+);
+CREATE INDEX IF NOT EXISTS executions_start_time ON executions (start_time);
+CREATE TABLE IF NOT EXISTS execution_events (
+	run_id TEXT NOT NULL,
+	seq BIGINT NOT NULL,
+	event %[1]s NOT NULL,
+	PRIMARY KEY (run_id, seq)
+);`
+
+// executionData is what is kept of the data of an execution, along with its
+// metadata.
+type executionData struct {
+	Inputs  map[string]any        `json:"inputs,omitempty"`
+	Outputs map[string]any        `json:"outputs,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Triage  *engine.FailureTriage `json:"triage,omitempty"`
+}
+
+// sqlStore keeps executions in a SQLite or Postgres database.
+type sqlStore struct {
+	db     *sql.DB
+	cipher *encryption.Cipher
+	// numbered uses $1 placeholders, as Postgres does, rather than ?
+	numbered bool
+}
+
+func openSQLiteStore(path string, cipher *encryption.Cipher) (*sqlStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite execution store requires the path of the database")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create execution store directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open execution store: %w", err)
+	}
+	// SQLite allows a single writer, which a single connection never waits on
+	db.SetMaxOpenConns(1)
+
+	return newSQLStore(db, cipher, false, "BLOB")
+}
+
+func openPostgresStore(url string, cipher *encryption.Cipher) (*sqlStore, error) {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open execution store: %w", err)
+	}
+
+	return newSQLStore(db, cipher, true, "BYTEA")
+}
+
+// newSQLStore creates the tables of the store unless they exist.
+func newSQLStore(db *sql.DB, cipher *encryption.Cipher, numbered bool, binaryType string) (*sqlStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, statement := range strings.Split(fmt.Sprintf(sqlSchema, binaryType), ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to create execution store tables: %w", err)
+		}
+	}
+
+	return &sqlStore{db: db, cipher: cipher, numbered: numbered}, nil
+}
+
+// query returns the query with its ? placeholders numbered when the
+// database requires it.
+func (s *sqlStore) query(query string) string {
+	if !s.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func (s *sqlStore) SaveExecution(ctx context.Context, status *ExecutionStatus) error {
+	data, err := s.seal(executionData{
+		Inputs:  status.Inputs,
+		Outputs: status.Outputs,
+		Error:   status.Error,
+		Triage:  status.Triage,
+	})
+	if err != nil {
+		return err
+	}
+
+	var endTime *int64
+	if status.EndTime != nil {
+		end := status.EndTime.UnixNano()
+		endTime = &end
+	}
+
+	_, err = s.db.ExecContext(ctx, s.query(`
+		INSERT INTO executions (run_id, workflow_id, status, start_time, end_time, duration, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (run_id) DO UPDATE SET
+			workflow_id = excluded.workflow_id,
+			status = excluded.status,
+			start_time = excluded.start_time,
+			end_time = excluded.end_time,
+			duration = excluded.duration,
+			data = excluded.data`),
+		status.RunID, status.WorkflowID, status.Status, status.StartTime.UnixNano(), endTime, int64(status.Duration), data)
+	if err != nil {
+		return fmt.Errorf("failed to save execution %s: %w", status.RunID, err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) AppendEvent(ctx context.Context, runID string, event pkgEvents.ExecutionEvent) error {
+	data, err := s.seal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.query(`
+		INSERT INTO execution_events (run_id, seq, event)
+		VALUES (?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM execution_events WHERE run_id = ?), ?)`),
+		runID, runID, data)
+	if err != nil {
+		return fmt.Errorf("failed to save event of execution %s: %w", runID, err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) GetExecution(ctx context.Context, runID string) (*ExecutionStatus, bool, error) {
+	row := s.db.QueryRowContext(ctx, s.query(`
+		SELECT run_id, workflow_id, status, start_time, end_time, duration, data
+		FROM executions WHERE run_id = ?`), runID)
+	status, err := s.scanExecution(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT event FROM execution_events WHERE run_id = ? ORDER BY seq`), runID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read events of execution %s: %w", runID, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	status.Progress = make([]pkgEvents.ExecutionEvent, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, false, fmt.Errorf("failed to read events of execution %s: %w", runID, err)
+		}
+
+		var event pkgEvents.ExecutionEvent
+		if err := s.open(data, &event); err != nil {
+			return nil, false, err
+		}
+		status.Progress = append(status.Progress, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read events of execution %s: %w", runID, err)
+	}
+
+	return status, true, nil
+}
+
+func (s *sqlStore) ListExecutions(ctx context.Context, filter ExecutionFilter, offset, limit int) ([]*ExecutionStatus, int, error) {
+	var conditions []string
+	var args []any
+	if filter.WorkflowID != "" {
+		conditions = append(conditions, "workflow_id = ?")
+		args = append(args, filter.WorkflowID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "start_time >= ?")
+		args = append(args, filter.Since.UnixNano())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, s.query("SELECT COUNT(*) FROM executions"+where), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count executions: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.query(`
+		SELECT run_id, workflow_id, status, start_time, end_time, duration, data
+		FROM executions`+where+`
+		ORDER BY start_time DESC, run_id
+		LIMIT ? OFFSET ?`), append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list executions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	executions := make([]*ExecutionStatus, 0)
+	for rows.Next() {
+		status, err := s.scanExecution(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		executions = append(executions, status)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list executions: %w", err)
+	}
+
+	return executions, total, nil
+}
+
+func (s *sqlStore) Prune(ctx context.Context, retention ExecutionRetention, now time.Time) (int, error) {
+	removed := 0
+	deleteBefore := func(startTime int64) error {
+		result, err := s.db.ExecContext(ctx, s.query(`DELETE FROM executions WHERE status <> 'running' AND start_time < ?`), startTime)
+		if err != nil {
+			return fmt.Errorf("failed to prune executions: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		removed += int(n)
+		return nil
+	}
+
+	if retention.MaxAge > 0 {
+		if err := deleteBefore(now.Add(-retention.MaxAge).UnixNano()); err != nil {
+			return removed, err
+		}
+	}
+
+	if retention.MaxExecutions > 0 {
+		// executions older than the last one kept are removed
+		var oldest int64
+		err := s.db.QueryRowContext(ctx, s.query(`
+			SELECT start_time FROM executions WHERE status <> 'running'
+			ORDER BY start_time DESC LIMIT 1 OFFSET ?`), retention.MaxExecutions-1).Scan(&oldest)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return removed, fmt.Errorf("failed to prune executions: %w", err)
+		}
+		if err == nil {
+			if err := deleteBefore(oldest); err != nil {
+				return removed, err
+			}
+		}
+	}
+
+	if removed > 0 {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM execution_events WHERE run_id NOT IN (SELECT run_id FROM executions)`); err != nil {
+			return removed, fmt.Errorf("failed to prune execution events: %w", err)
+		}
+	}
+
+	return removed, nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// scanner is a row of a query, either *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (s *sqlStore) scanExecution(row scanner) (*ExecutionStatus, error) {
+	var status ExecutionStatus
+	var startTime, duration int64
+	var endTime sql.NullInt64
+	var data []byte
+	if err := row.Scan(&status.RunID, &status.WorkflowID, &status.Status, &startTime, &endTime, &duration, &data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read execution: %w", err)
+	}
+
+	status.StartTime = time.Unix(0, startTime)
+	if endTime.Valid {
+		end := time.Unix(0, endTime.Int64)
+		status.EndTime = &end
+	}
+	status.Duration = time.Duration(duration)
+
+	var stored executionData
+	if err := s.open(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to read execution %s: %w", status.RunID, err)
+	}
+	status.Inputs = stored.Inputs
+	status.Outputs = stored.Outputs
+	status.Error = stored.Error
+	status.Triage = stored.Triage
+
+	return &status, nil
+}
+
+// seal encodes a value as JSON, encrypted when the store has a cipher.
+func (s *sqlStore) seal(value any) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode execution: %w", err)
+	}
+
+	return s.cipher.Seal(data)
+}
+
+// open decodes a value sealed by seal.
+func (s *sqlStore) open(data []byte, value any) error {
+	data, err := s.cipher.Open(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
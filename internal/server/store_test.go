@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/pkg/events"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestStore(t *testing.T, cipher *encryption.Cipher) ExecutionStore {
+	t.Helper()
+
+	store, err := OpenExecutionStore("sqlite://"+filepath.Join(t.TempDir(), "executions.db"), cipher)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testExecutionStore(t, openTestStore(t, nil))
+}
+
+// TestPostgresStore runs against the database at LACQUER_TEST_POSTGRES_URL,
+// whose tables are dropped first.
+func TestPostgresStore(t *testing.T) {
+	url := os.Getenv("LACQUER_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("LACQUER_TEST_POSTGRES_URL is not set")
+	}
+
+	db, err := sql.Open("pgx", url)
+	require.NoError(t, err)
+	_, err = db.Exec("DROP TABLE IF EXISTS executions, execution_events")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	store, err := OpenExecutionStore(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	testExecutionStore(t, store)
+}
+
+func testExecutionStore(t *testing.T, store ExecutionStore) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, workflowID := range []string{"review", "summarize", "review"} {
+		require.NoError(t, store.SaveExecution(ctx, &ExecutionStatus{
+			RunID:      "run-" + string(rune('a'+i)),
+			WorkflowID: workflowID,
+			Status:     "running",
+			StartTime:  start.Add(time.Duration(i) * time.Hour),
+			Inputs:     map[string]any{"pr": float64(i)},
+		}))
+	}
+
+	require.NoError(t, store.AppendEvent(ctx, "run-a", events.ExecutionEvent{Type: events.EventStepStarted, RunID: "run-a", StepID: "diff"}))
+	require.NoError(t, store.AppendEvent(ctx, "run-a", events.ExecutionEvent{Type: events.EventStepCompleted, RunID: "run-a", StepID: "diff"}))
+
+	end := start.Add(time.Minute)
+	require.NoError(t, store.SaveExecution(ctx, &ExecutionStatus{
+		RunID:      "run-a",
+		WorkflowID: "review",
+		Status:     "completed",
+		StartTime:  start,
+		EndTime:    &end,
+		Duration:   time.Minute,
+		Inputs:     map[string]any{"pr": float64(0)},
+		Outputs:    map[string]any{"verdict": "approve"},
+	}))
+
+	status, ok, err := store.GetExecution(ctx, "run-a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "completed", status.Status)
+	assert.True(t, start.Equal(status.StartTime))
+	assert.True(t, end.Equal(*status.EndTime))
+	assert.Equal(t, time.Minute, status.Duration)
+	assert.Equal(t, map[string]any{"pr": float64(0)}, status.Inputs)
+	assert.Equal(t, map[string]any{"verdict": "approve"}, status.Outputs)
+	require.Len(t, status.Progress, 2)
+	assert.Equal(t, events.EventStepStarted, status.Progress[0].Type)
+	assert.Equal(t, events.EventStepCompleted, status.Progress[1].Type)
+
+	_, ok, err = store.GetExecution(ctx, "run-z")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	executions, total, err := store.ListExecutions(ctx, ExecutionFilter{}, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, executions, 2)
+	assert.Equal(t, "run-c", executions[0].RunID)
+	assert.Equal(t, "run-b", executions[1].RunID)
+	assert.Nil(t, executions[0].Progress)
+
+	executions, total, err = store.ListExecutions(ctx, ExecutionFilter{WorkflowID: "review", Status: "running"}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "run-c", executions[0].RunID)
+
+	executions, _, err = store.ListExecutions(ctx, ExecutionFilter{Since: start.Add(time.Hour)}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, executions, 1)
+	assert.Equal(t, "run-b", executions[0].RunID)
+
+	// running executions are kept whatever their age
+	now := start.Add(48 * time.Hour)
+	removed, err := store.Prune(ctx, ExecutionRetention{MaxAge: 24 * time.Hour}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, ok, err = store.GetExecution(ctx, "run-a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, runID := range []string{"run-b", "run-c"} {
+		status, _, err := store.GetExecution(ctx, runID)
+		require.NoError(t, err)
+		status.Status = "failed"
+		require.NoError(t, store.SaveExecution(ctx, status))
+	}
+
+	removed, err = store.Prune(ctx, ExecutionRetention{MaxExecutions: 1}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	executions, total, err = store.ListExecutions(ctx, ExecutionFilter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "run-c", executions[0].RunID)
+}
+
+func TestSQLiteStore_Encrypted(t *testing.T) {
+	cipher, err := encryption.NewCipher(make([]byte, encryption.KeySize))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "executions.db")
+	store, err := OpenExecutionStore("sqlite://"+path, cipher)
+	require.NoError(t, err)
+	defer func() { _ = store.Close() }()
+
+	ctx := context.Background()
+	require.NoError(t, store.SaveExecution(ctx, &ExecutionStatus{RunID: "run-1", WorkflowID: "review", Status: "running", StartTime: time.Now(), Inputs: map[string]any{"secret": "hunter2"}}))
+	require.NoError(t, store.AppendEvent(ctx, "run-1", events.ExecutionEvent{Type: events.EventStepStarted, RunID: "run-1", Text: "hunter2"}))
+
+	status, _, err := store.GetExecution(ctx, "run-1")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", status.Inputs["secret"])
+	assert.Equal(t, "hunter2", status.Progress[0].Text)
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	var data, event []byte
+	require.NoError(t, db.QueryRow("SELECT data FROM executions").Scan(&data))
+	require.NoError(t, db.QueryRow("SELECT event FROM execution_events").Scan(&event))
+	assert.True(t, encryption.IsEncrypted(data))
+	assert.True(t, encryption.IsEncrypted(event))
+}
+
+func TestExecutionManager_Store(t *testing.T) {
+	store := openTestStore(t, nil)
+
+	manager := NewExecutionManagerWithRegistry(5, prometheus.NewRegistry())
+	manager.store = store
+
+	status := manager.StartExecution("run-1", "review", func() {}, map[string]any{"pr": "42"})
+	manager.saveExecution(status)
+	manager.AddProgressEvent("run-1", events.ExecutionEvent{Type: events.EventStepStarted, RunID: "run-1", StepID: "diff"})
+
+	executions, total, err := manager.ListExecutions(ExecutionFilter{Status: "running"}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "run-1", executions[0].RunID)
+
+	manager.FinishExecution("run-1", map[string]any{"verdict": "approve"}, nil)
+
+	// finished executions are only held by the store
+	manager.mu.RLock()
+	assert.Empty(t, manager.executions)
+	manager.mu.RUnlock()
+
+	// and outlive the manager
+	restarted := NewExecutionManagerWithRegistry(5, prometheus.NewRegistry())
+	restarted.store = store
+
+	finished, ok := restarted.GetExecution("run-1")
+	require.True(t, ok)
+	assert.Equal(t, "completed", finished.Status)
+	assert.Equal(t, map[string]any{"verdict": "approve"}, finished.Outputs)
+	require.Len(t, finished.Progress, 1)
+
+	// streaming clients are sent the stored events
+	batch, _, err := finished.stream.Subscribe().Next()
+	require.NoError(t, err)
+	assert.Equal(t, finished.Progress, batch)
+	<-finished.done
+}
+
+func TestFailInterrupted(t *testing.T) {
+	store := openTestStore(t, nil)
+	ctx := context.Background()
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.SaveExecution(ctx, &ExecutionStatus{RunID: "run-1", WorkflowID: "review", Status: "running", StartTime: start}))
+	require.NoError(t, store.AppendEvent(ctx, "run-1", events.ExecutionEvent{Type: events.EventStepStarted, RunID: "run-1"}))
+	require.NoError(t, store.SaveExecution(ctx, &ExecutionStatus{RunID: "run-2", WorkflowID: "review", Status: "completed", StartTime: start}))
+
+	failed, err := failInterrupted(ctx, store, start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+
+	status, _, err := store.GetExecution(ctx, "run-1")
+	require.NoError(t, err)
+	assert.Equal(t, "failed", status.Status)
+	assert.Equal(t, interruptedError, status.Error)
+	assert.Equal(t, time.Minute, status.Duration)
+	assert.Len(t, status.Progress, 1)
+
+	status, _, err = store.GetExecution(ctx, "run-2")
+	require.NoError(t, err)
+	assert.Equal(t, "completed", status.Status)
+}
+
+func TestOpenExecutionStore_Unsupported(t *testing.T) {
+	_, err := OpenExecutionStore("mysql://lacquer:hunter2@db/lacquer", nil)
+	assert.EqualError(t, err, `unsupported execution store "mysql://lacquer:xxxxx@db/lacquer", use sqlite://<path> or postgres://<connection>`)
+
+	_, err = OpenExecutionStore("sqlite://", nil)
+	assert.EqualError(t, err, "sqlite execution store requires the path of the database")
+}
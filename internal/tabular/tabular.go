@@ -0,0 +1,175 @@
+// Package tabular converts lists of objects, such as the structured output of
+// an agent step, into tables and writes them as CSV or XLSX files.
+package tabular
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/lacquerai/lacquer/internal/schema"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Formats lists the supported file formats.
+var Formats = []string{FormatCSV, FormatXLSX}
+
+// Table is a list of rows with a header.
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// NewTable converts a list of objects into a table. When no columns are given
+// they are derived from the item schema if there is one, otherwise from the
+// keys of the objects.
+func NewTable(value interface{}, columns []string, itemSchema *schema.JSON) (*Table, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of objects, got %T", value)
+	}
+
+	objects := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("item %d: expected an object, got %T", i, item)
+		}
+		objects[i] = object
+	}
+
+	if len(columns) == 0 {
+		columns = SchemaColumns(itemSchema)
+	}
+	if len(columns) == 0 {
+		columns = keyColumns(objects)
+	}
+
+	table := &Table{Columns: columns, Rows: make([][]interface{}, len(objects))}
+	for i, object := range objects {
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			row[j] = object[column]
+		}
+		table.Rows[i] = row
+	}
+
+	return table, nil
+}
+
+// SchemaColumns returns the columns described by an object schema, the
+// required properties in the order they're listed followed by the remaining
+// properties sorted by name.
+func SchemaColumns(s *schema.JSON) []string {
+	if s == nil || len(s.Properties) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(s.Properties))
+	var columns []string
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; ok && !seen[name] {
+			seen[name] = true
+			columns = append(columns, name)
+		}
+	}
+
+	var rest []string
+	for name := range s.Properties {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(columns, rest...)
+}
+
+// ItemSchema returns the schema of the items of an array schema.
+func ItemSchema(s *schema.JSON) *schema.JSON {
+	if s == nil || s.Items == nil {
+		return nil
+	}
+
+	// items are decoded from YAML as generic maps
+	data, err := json.Marshal(s.Items)
+	if err != nil {
+		return nil
+	}
+
+	var items schema.JSON
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+
+	return &items
+}
+
+func keyColumns(objects []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, object := range objects {
+		for key := range object {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+// WriteCSV writes the table as CSV with a header row.
+func (t *Table) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(t.Columns); err != nil {
+		return err
+	}
+
+	for _, row := range t.Rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = cellString(cell)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// cellString formats a value for a text cell, nested values are written as
+// JSON.
+func cellString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
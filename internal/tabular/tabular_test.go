@@ -0,0 +1,95 @@
+package tabular
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rows = []interface{}{
+	map[string]interface{}{"name": "Widget", "price": 9.5, "in_stock": true, "tags": []interface{}{"a", "b"}},
+	map[string]interface{}{"name": "Gadget, large", "price": 12, "notes": "fragile"},
+}
+
+func TestNewTable_Columns(t *testing.T) {
+	table, err := NewTable(rows, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"in_stock", "name", "notes", "price", "tags"}, table.Columns)
+
+	table, err = NewTable(rows, []string{"price", "name"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{9.5, "Widget"}, table.Rows[0])
+
+	itemSchema := ItemSchema(&schema.JSON{
+		Type: "array",
+		Items: map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"name", "price"},
+			"properties": map[string]interface{}{
+				"price":    map[string]interface{}{"type": "number"},
+				"name":     map[string]interface{}{"type": "string"},
+				"in_stock": map[string]interface{}{"type": "boolean"},
+			},
+		},
+	})
+	table, err = NewTable(rows, nil, itemSchema)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "price", "in_stock"}, table.Columns)
+
+	_, err = NewTable("not a list", nil, nil)
+	assert.EqualError(t, err, "expected a list of objects, got string")
+
+	_, err = NewTable([]interface{}{"x"}, nil, nil)
+	assert.EqualError(t, err, "item 0: expected an object, got string")
+}
+
+func TestTable_WriteCSV(t *testing.T) {
+	table, err := NewTable(rows, []string{"name", "price", "in_stock", "tags"}, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, table.WriteCSV(&buf))
+	assert.Equal(t, `name,price,in_stock,tags
+Widget,9.5,true,"[""a"",""b""]"
+"Gadget, large",12,,
+`, buf.String())
+}
+
+func TestTable_WriteXLSX(t *testing.T) {
+	table, err := NewTable(rows, []string{"name", "price", "in_stock"}, nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, table.WriteXLSX(&buf))
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var sheet string
+	for _, f := range reader.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			sheet = string(data)
+		}
+	}
+
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">name</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="B2"><v>9.5</v></c>`)
+	assert.Contains(t, sheet, `<c r="C2" t="b"><v>1</v></c>`)
+	assert.Contains(t, sheet, `<t xml:space="preserve">Gadget, large</t>`)
+}
+
+func TestCellRef(t *testing.T) {
+	assert.Equal(t, "A1", cellRef(0, 1))
+	assert.Equal(t, "Z3", cellRef(25, 3))
+	assert.Equal(t, "AA10", cellRef(26, 10))
+	assert.Equal(t, "BA2", cellRef(52, 2))
+}
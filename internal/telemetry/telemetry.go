@@ -0,0 +1,304 @@
+// Package telemetry collects anonymous usage metrics, which commands and
+// step types are used, when the user has opted in. No workflow content,
+// inputs, outputs, prompts, file names or errors are ever collected.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultEndpoint receives the usage reports.
+	DefaultEndpoint = "https://api.lacquer.ai/v1/telemetry"
+
+	// ReportInterval is how often usage is reported.
+	ReportInterval = 24 * time.Hour
+)
+
+// Report is the usage sent to the maintainers, exactly as it's sent.
+type Report struct {
+	// InstallID is random and generated when telemetry is enabled, it
+	// tells reports from the same installation apart and nothing more.
+	InstallID string         `json:"install_id" yaml:"install_id"`
+	Version   string         `json:"version" yaml:"version"`
+	OS        string         `json:"os" yaml:"os"`
+	Arch      string         `json:"arch" yaml:"arch"`
+	Since     time.Time      `json:"since" yaml:"since"`
+	Commands  map[string]int `json:"commands,omitempty" yaml:"commands,omitempty"`
+	StepTypes map[string]int `json:"step_types,omitempty" yaml:"step_types,omitempty"`
+}
+
+// IsEmpty reports whether no usage has been recorded.
+func (r *Report) IsEmpty() bool {
+	return len(r.Commands) == 0 && len(r.StepTypes) == 0
+}
+
+// State is the opt-in and the usage recorded since the last report.
+type State struct {
+	Enabled  bool      `json:"enabled"`
+	LastSent time.Time `json:"last_sent,omitempty"`
+	Pending  Report    `json:"pending"`
+}
+
+// Collector records usage to a local file until it's reported.
+type Collector struct {
+	path     string
+	endpoint string
+	version  string
+	client   *http.Client
+	mu       sync.Mutex
+}
+
+// NewCollector creates a collector keeping its state at path.
+func NewCollector(path, endpoint, version string) *Collector {
+	return &Collector{
+		path:     path,
+		endpoint: endpoint,
+		version:  version,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// DisabledByEnv reports whether telemetry is disabled by the DO_NOT_TRACK
+// or LACQUER_TELEMETRY environment variables, regardless of the opt-in.
+func DisabledByEnv() bool {
+	if value := os.Getenv("DO_NOT_TRACK"); value != "" && value != "0" {
+		return true
+	}
+
+	switch strings.ToLower(os.Getenv("LACQUER_TELEMETRY")) {
+	case "0", "false", "off", "no":
+		return true
+	}
+
+	return false
+}
+
+// Load returns the state, telemetry is disabled until it's enabled.
+func (c *Collector) Load() (*State, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.load()
+}
+
+// Enabled reports whether usage is recorded and reported.
+func (c *Collector) Enabled() bool {
+	state, err := c.Load()
+	return err == nil && state.Enabled && !DisabledByEnv()
+}
+
+// SetEnabled opts in or out. Opting out discards any usage which hasn't
+// been reported, opting in starts recording with a new install ID.
+func (c *Collector) SetEnabled(enabled bool) error {
+	return c.update(func(state *State) bool {
+		if state.Enabled == enabled {
+			return false
+		}
+
+		state.Enabled = enabled
+		state.Pending = Report{}
+		if enabled {
+			state.Pending = c.newReport(newInstallID(), time.Now())
+		}
+		return true
+	})
+}
+
+// RecordCommand counts a use of the command, such as "runs export". Nothing
+// is recorded unless telemetry is enabled.
+func (c *Collector) RecordCommand(command string) error {
+	return c.record(func(report *Report) {
+		report.addCommand(command, 1)
+	})
+}
+
+// RecordStepTypes counts the steps of a run by type. Nothing is recorded
+// unless telemetry is enabled.
+func (c *Collector) RecordStepTypes(types []string) error {
+	if len(types) == 0 {
+		return nil
+	}
+
+	return c.record(func(report *Report) {
+		for _, stepType := range types {
+			report.addStepType(stepType, 1)
+		}
+	})
+}
+
+// Flush sends the recorded usage once ReportInterval has passed since the
+// last report, and starts a new report when it's been received.
+func (c *Collector) Flush(ctx context.Context, now time.Time) error {
+	if DisabledByEnv() {
+		return nil
+	}
+
+	state, err := c.Load()
+	if err != nil || !state.Enabled || state.Pending.IsEmpty() || now.Sub(state.LastSent) < ReportInterval {
+		return err
+	}
+
+	report := state.Pending
+	if err := c.send(ctx, &report); err != nil {
+		return err
+	}
+
+	return c.update(func(state *State) bool {
+		// usage recorded while the report was sent is carried over
+		next := c.newReport(report.InstallID, now)
+		for command, count := range state.Pending.Commands {
+			if remaining := count - report.Commands[command]; remaining > 0 {
+				next.addCommand(command, remaining)
+			}
+		}
+		for stepType, count := range state.Pending.StepTypes {
+			if remaining := count - report.StepTypes[stepType]; remaining > 0 {
+				next.addStepType(stepType, remaining)
+			}
+		}
+
+		state.Pending = next
+		state.LastSent = now
+		return true
+	})
+}
+
+func (c *Collector) send(ctx context.Context, report *Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create usage report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send usage report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to send usage report: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (c *Collector) record(fn func(report *Report)) error {
+	if DisabledByEnv() {
+		return nil
+	}
+
+	return c.update(func(state *State) bool {
+		if !state.Enabled {
+			return false
+		}
+
+		fn(&state.Pending)
+		return true
+	})
+}
+
+// update changes the state, saving it when fn reports a change.
+func (c *Collector) update(fn func(state *State) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	if !fn(state) {
+		return nil
+	}
+
+	return c.save(state)
+}
+
+func (c *Collector) load() (*State, error) {
+	state := &State{}
+
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telemetry state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		// a corrupt state is treated as disabled rather than failing commands
+		return &State{}, nil
+	}
+
+	return state, nil
+}
+
+func (c *Collector) save(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0750); err != nil {
+		return fmt.Errorf("failed to create telemetry directory: %w", err)
+	}
+
+	// written to a temporary file first so concurrent commands never read a
+	// partially written state
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write telemetry state: %w", err)
+	}
+
+	return os.Rename(tmp, c.path)
+}
+
+func (c *Collector) newReport(installID string, now time.Time) Report {
+	return Report{
+		InstallID: installID,
+		Version:   c.version,
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Since:     now.UTC(),
+	}
+}
+
+func (r *Report) addCommand(command string, count int) {
+	if r.Commands == nil {
+		r.Commands = make(map[string]int)
+	}
+	r.Commands[command] += count
+}
+
+func (r *Report) addStepType(stepType string, count int) {
+	if r.StepTypes == nil {
+		r.StepTypes = make(map[string]int)
+	}
+	r.StepTypes[stepType] += count
+}
+
+func newInstallID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,120 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector_OptIn(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("LACQUER_TELEMETRY", "")
+
+	c := NewCollector(filepath.Join(t.TempDir(), "telemetry.json"), "http://localhost", "v1.2.3")
+
+	// nothing is recorded until telemetry is enabled
+	require.NoError(t, c.RecordCommand("run"))
+	state, err := c.Load()
+	require.NoError(t, err)
+	assert.False(t, state.Enabled)
+	assert.True(t, state.Pending.IsEmpty())
+	assert.False(t, c.Enabled())
+
+	require.NoError(t, c.SetEnabled(true))
+	assert.True(t, c.Enabled())
+
+	require.NoError(t, c.RecordCommand("run"))
+	require.NoError(t, c.RecordCommand("run"))
+	require.NoError(t, c.RecordCommand("validate"))
+	require.NoError(t, c.RecordStepTypes([]string{"agent", "agent", "script"}))
+
+	state, err = c.Load()
+	require.NoError(t, err)
+	assert.Len(t, state.Pending.InstallID, 32)
+	assert.Equal(t, "v1.2.3", state.Pending.Version)
+	assert.Equal(t, map[string]int{"run": 2, "validate": 1}, state.Pending.Commands)
+	assert.Equal(t, map[string]int{"agent": 2, "script": 1}, state.Pending.StepTypes)
+
+	t.Setenv("DO_NOT_TRACK", "1")
+	assert.False(t, c.Enabled())
+	require.NoError(t, c.RecordCommand("run"))
+	state, err = c.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 2, state.Pending.Commands["run"])
+
+	// opting out discards the usage which wasn't reported
+	require.NoError(t, c.SetEnabled(false))
+	state, err = c.Load()
+	require.NoError(t, err)
+	assert.True(t, state.Pending.IsEmpty())
+}
+
+func TestCollector_Flush(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("LACQUER_TELEMETRY", "")
+
+	var received []Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report Report
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+		received = append(received, report)
+	}))
+	defer server.Close()
+
+	c := NewCollector(filepath.Join(t.TempDir(), "telemetry.json"), server.URL, "v1.2.3")
+	require.NoError(t, c.SetEnabled(true))
+
+	now := time.Now()
+
+	// nothing to report
+	require.NoError(t, c.Flush(context.Background(), now))
+	assert.Empty(t, received)
+
+	require.NoError(t, c.RecordCommand("run"))
+	require.NoError(t, c.Flush(context.Background(), now))
+	require.Len(t, received, 1)
+	assert.Equal(t, map[string]int{"run": 1}, received[0].Commands)
+
+	state, err := c.Load()
+	require.NoError(t, err)
+	assert.True(t, state.Pending.IsEmpty())
+	assert.Equal(t, received[0].InstallID, state.Pending.InstallID)
+
+	// usage is reported at most once per interval
+	require.NoError(t, c.RecordCommand("validate"))
+	require.NoError(t, c.Flush(context.Background(), now.Add(time.Hour)))
+	assert.Len(t, received, 1)
+
+	require.NoError(t, c.Flush(context.Background(), now.Add(ReportInterval)))
+	require.Len(t, received, 2)
+	assert.Equal(t, map[string]int{"validate": 1}, received[1].Commands)
+}
+
+func TestCollector_FlushFailure(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("LACQUER_TELEMETRY", "")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewCollector(filepath.Join(t.TempDir(), "telemetry.json"), server.URL, "v1.2.3")
+	require.NoError(t, c.SetEnabled(true))
+	require.NoError(t, c.RecordCommand("run"))
+
+	err := c.Flush(context.Background(), time.Now())
+	assert.EqualError(t, err, "failed to send usage report: 503 Service Unavailable")
+
+	// the usage is kept to be reported later
+	state, err := c.Load()
+	require.NoError(t, err)
+	assert.Equal(t, 1, state.Pending.Commands["run"])
+}
@@ -31,7 +31,7 @@ func (p *MCPToolProvider) GetType() ast.ToolType {
 }
 
 // AddToolDefinition adds an MCP tool to the provider
-func (p *MCPToolProvider) AddToolDefinition(tool *ast.Tool) ([]tools.Tool, error) {
+func (p *MCPToolProvider) AddToolDefinition(ctx context.Context, tool *ast.Tool) ([]tools.Tool, error) {
 	if tool.MCPServer == nil {
 		return nil, fmt.Errorf("MCP server configuration is required for MCP tools")
 	}
@@ -40,7 +40,7 @@ func (p *MCPToolProvider) AddToolDefinition(tool *ast.Tool) ([]tools.Tool, error
 		return nil, fmt.Errorf("invalid MCP server configuration: %w", err)
 	}
 
-	server, err := p.getOrCreateServer(tool)
+	server, err := p.getOrCreateServer(ctx, tool)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MCP server: %w", err)
 	}
@@ -119,17 +119,18 @@ func (p *MCPToolProvider) Close() error {
 	return nil
 }
 
-// getOrCreateServer gets an existing server or creates a new one
-func (p *MCPToolProvider) getOrCreateServer(tool *ast.Tool) (*Server, error) {
+// getOrCreateServer gets an existing server or creates a new one, local
+// servers are stopped when ctx is cancelled
+func (p *MCPToolProvider) getOrCreateServer(ctx context.Context, tool *ast.Tool) (*Server, error) {
 	config := tool.MCPServer
 
 	server := NewServer(config)
 
-	if err := server.Initialize(context.Background()); err != nil {
+	if err := server.Initialize(ctx); err != nil {
 		return nil, fmt.Errorf("failed to initialize MCP server: %w", err)
 	}
 
-	tools, err := server.DiscoverTools(context.Background())
+	tools, err := server.DiscoverTools(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover tools: %w", err)
 	}
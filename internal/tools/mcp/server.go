@@ -117,7 +117,10 @@ func (s *Server) initializeLocal(ctx context.Context) error {
 		}
 	}()
 
-	if err := s.client.Initialize(ctx); err != nil {
+	initCtx, cancel := s.initContext(ctx)
+	defer cancel()
+
+	if err := s.client.Initialize(initCtx); err != nil {
 		_ = cmd.Process.Kill()
 		return fmt.Errorf("failed to initialize MCP client: %w", err)
 	}
@@ -129,20 +132,16 @@ func (s *Server) initializeLocal(ctx context.Context) error {
 
 // initializeRemote connects to a remote MCP server
 func (s *Server) initializeRemote(ctx context.Context) error {
-	transport, err := CreateTransportFromURL(s.config.URL, s.config.Auth)
+	initCtx, cancel := s.initContext(ctx)
+	defer cancel()
+
+	transport, err := CreateTransportFromURL(initCtx, s.config.URL, s.config.Auth)
 	if err != nil {
 		return fmt.Errorf("failed to create transport: %w", err)
 	}
 
 	s.client = NewMCPClient(transport)
 
-	initCtx := ctx
-	if s.config.Timeout != nil {
-		var cancel context.CancelFunc
-		initCtx, cancel = context.WithTimeout(ctx, s.config.Timeout.Duration)
-		defer cancel()
-	}
-
 	if err := s.client.Initialize(initCtx); err != nil {
 		return fmt.Errorf("failed to initialize MCP client: %w", err)
 	}
@@ -150,6 +149,16 @@ func (s *Server) initializeRemote(ctx context.Context) error {
 	return nil
 }
 
+// initContext returns the context connecting to the server is limited to,
+// which is cancelled once the server's timeout is reached.
+func (s *Server) initContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.config.Timeout == nil {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.config.Timeout.Duration)
+}
+
 // DiscoverTools discovers available tools from the MCP server
 func (s *Server) DiscoverTools(ctx context.Context) ([]Tool, error) {
 	s.mu.RLock()
@@ -314,7 +323,7 @@ func (t *StdioTransport) Close() error {
 }
 
 // CreateTransportFromURL creates a transport based on the URL scheme
-func CreateTransportFromURL(serverURL string, auth *ast.MCPAuthConfig) (MCPTransport, error) {
+func CreateTransportFromURL(ctx context.Context, serverURL string, auth *ast.MCPAuthConfig) (MCPTransport, error) {
 	u, err := url.Parse(serverURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
@@ -333,7 +342,7 @@ func CreateTransportFromURL(serverURL string, auth *ast.MCPAuthConfig) (MCPTrans
 		httpTransport := NewHTTPMCPTransport(serverURL, timeout)
 
 		if authProvider != nil {
-			authHeader, err := authProvider.GetAuthHeader(context.Background())
+			authHeader, err := authProvider.GetAuthHeader(ctx)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get auth header: %w", err)
 			}
@@ -348,7 +357,7 @@ func CreateTransportFromURL(serverURL string, auth *ast.MCPAuthConfig) (MCPTrans
 		wsTransport := NewWebSocketMCPTransport(serverURL, timeout)
 
 		if authProvider != nil {
-			authHeader, err := authProvider.GetAuthHeader(context.Background())
+			authHeader, err := authProvider.GetAuthHeader(ctx)
 			if err != nil {
 				return nil, fmt.Errorf("failed to get auth header: %w", err)
 			}
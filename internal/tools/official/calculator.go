@@ -0,0 +1,950 @@
+package official
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/lacquerai/lacquer/internal/schema"
+)
+
+const (
+	defaultCalculatorMaxLength = 2000
+	// maxCalculatorDepth limits the nesting of parentheses, calls and
+	// operators so that deeply nested expressions can't exhaust the stack.
+	maxCalculatorDepth = 64
+	// maxCalculatorListSize limits the lists created by variables and range.
+	maxCalculatorListSize = 10000
+	// calculatorPrecision is the number of significant digits of results,
+	// so that 0.1 + 0.2 is 0.3 rather than 0.30000000000000004.
+	calculatorPrecision = 15
+)
+
+// calculator evaluates arithmetic expressions over numbers and lists of
+// numbers. It has no access to the file system, network or workflow state
+// and every expression is evaluated in a bounded number of steps.
+type calculator struct {
+	maxLength int
+}
+
+type calculatorParams struct {
+	Expression string                     `json:"expression"`
+	Variables  map[string]json.RawMessage `json:"variables"`
+}
+
+type calculatorResult struct {
+	Expression string      `json:"expression"`
+	Result     interface{} `json:"result"`
+}
+
+func newCalculator(_ *Provider, config map[string]interface{}) (tool, error) {
+	c := &calculator{
+		maxLength: defaultCalculatorMaxLength,
+	}
+
+	for key, value := range config {
+		switch key {
+		case "max_length":
+			n, ok := value.(int)
+			if !ok || n <= 0 {
+				return nil, fmt.Errorf("config.max_length must be a positive integer")
+			}
+			c.maxLength = n
+		}
+	}
+
+	return c, nil
+}
+
+func (c *calculator) description() string {
+	return "Evaluate an arithmetic expression exactly instead of calculating it yourself. " +
+		"Supports + - * / % ^, parentheses, lists such as [1, 2, 3] with element-wise arithmetic, " +
+		"the constants pi and e and the functions " + strings.Join(calculatorFunctionNames(), ", ") + "."
+}
+
+func (c *calculator) parameters() schema.JSON {
+	return schema.JSON{
+		Type: "object",
+		Properties: map[string]schema.JSON{
+			"expression": {
+				Type:        "string",
+				Description: "The expression to evaluate, e.g. round(mean(prices) * 1.2, 2)",
+			},
+			"variables": {
+				Type:        "object",
+				Description: "Numbers or lists of numbers referenced by name in the expression, e.g. {\"prices\": [9.5, 12, 7.25]}",
+			},
+		},
+		Required: []string{"expression"},
+	}
+}
+
+func (c *calculator) execute(_ context.Context, parameters json.RawMessage) (interface{}, error) {
+	var params calculatorParams
+	if err := json.Unmarshal(parameters, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	expression := strings.TrimSpace(params.Expression)
+	if expression == "" {
+		return nil, fmt.Errorf("expression must not be empty")
+	}
+	if len(expression) > c.maxLength {
+		return nil, fmt.Errorf("expression is longer than the maximum of %d characters", c.maxLength)
+	}
+
+	variables := make(map[string]calcValue, len(params.Variables))
+	for name, raw := range params.Variables {
+		value, err := parseCalcVariable(raw)
+		if err != nil {
+			return nil, fmt.Errorf("variables.%s %w", name, err)
+		}
+		variables[name] = value
+	}
+
+	value, err := evaluateCalculation(expression, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &calculatorResult{Expression: expression}
+	if value.isList {
+		list := make([]float64, len(value.list))
+		for i, n := range value.list {
+			list[i] = roundSignificant(n)
+		}
+		result.Result = list
+	} else {
+		result.Result = roundSignificant(value.num)
+	}
+
+	return result, nil
+}
+
+func parseCalcVariable(raw json.RawMessage) (calcValue, error) {
+	var num float64
+	if err := json.Unmarshal(raw, &num); err == nil {
+		return calcNumber(num), nil
+	}
+
+	var list []float64
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return calcValue{}, fmt.Errorf("must be a number or a list of numbers")
+	}
+	if len(list) > maxCalculatorListSize {
+		return calcValue{}, fmt.Errorf("has more than the maximum of %d items", maxCalculatorListSize)
+	}
+
+	return calcList(list), nil
+}
+
+func roundSignificant(n float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(n, 'g', calculatorPrecision, 64), 64)
+	if err != nil {
+		return n
+	}
+
+	return rounded
+}
+
+// calcValue is either a number or a list of numbers.
+type calcValue struct {
+	num    float64
+	list   []float64
+	isList bool
+}
+
+func calcNumber(n float64) calcValue {
+	return calcValue{num: n}
+}
+
+func calcList(list []float64) calcValue {
+	return calcValue{list: list, isList: true}
+}
+
+// evaluateCalculation parses and evaluates an expression in a single pass.
+func evaluateCalculation(expression string, variables map[string]calcValue) (calcValue, error) {
+	tokens, err := tokenizeCalculation(expression)
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	p := &calcParser{tokens: tokens, variables: variables}
+	value, err := p.parseExpression()
+	if err != nil {
+		return calcValue{}, err
+	}
+	if tok := p.peek(); tok.kind != calcEOF {
+		return calcValue{}, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos+1)
+	}
+
+	if err := checkFinite(value); err != nil {
+		return calcValue{}, err
+	}
+
+	return value, nil
+}
+
+func checkFinite(v calcValue) error {
+	values := v.list
+	if !v.isList {
+		values = []float64{v.num}
+	}
+
+	for _, n := range values {
+		if math.IsNaN(n) {
+			return fmt.Errorf("result is not a number")
+		}
+		if math.IsInf(n, 0) {
+			return fmt.Errorf("result is too large")
+		}
+	}
+
+	return nil
+}
+
+type calcTokenKind int
+
+const (
+	calcEOF calcTokenKind = iota
+	calcNum
+	calcIdent
+	calcOp
+)
+
+type calcToken struct {
+	kind calcTokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func tokenizeCalculation(s string) ([]calcToken, error) {
+	var tokens []calcToken
+
+	for i := 0; i < len(s); {
+		ch := rune(s[i])
+
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case unicode.IsDigit(ch) || (ch == '.' && i+1 < len(s) && unicode.IsDigit(rune(s[i+1]))):
+			start := i
+			for i < len(s) && (unicode.IsDigit(rune(s[i])) || s[i] == '.' || s[i] == '_') {
+				i++
+			}
+			if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+				j := i + 1
+				if j < len(s) && (s[j] == '+' || s[j] == '-') {
+					j++
+				}
+				if j < len(s) && unicode.IsDigit(rune(s[j])) {
+					i = j
+					for i < len(s) && unicode.IsDigit(rune(s[i])) {
+						i++
+					}
+				}
+			}
+			text := s[start:i]
+			num, err := strconv.ParseFloat(strings.ReplaceAll(text, "_", ""), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start+1)
+			}
+			tokens = append(tokens, calcToken{kind: calcNum, text: text, num: num, pos: start})
+		case ch == '_' || unicode.IsLetter(ch):
+			start := i
+			for i < len(s) && (s[i] == '_' || unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i]))) {
+				i++
+			}
+			tokens = append(tokens, calcToken{kind: calcIdent, text: s[start:i], pos: start})
+		case strings.HasPrefix(s[i:], "**"):
+			tokens = append(tokens, calcToken{kind: calcOp, text: "^", pos: i})
+			i += 2
+		case strings.ContainsRune("+-*/%^(),[]", ch):
+			tokens = append(tokens, calcToken{kind: calcOp, text: string(ch), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", ch, i+1)
+		}
+	}
+
+	return append(tokens, calcToken{kind: calcEOF, text: "end of expression", pos: len(s)}), nil
+}
+
+// calcParser is a recursive descent parser which evaluates as it parses.
+type calcParser struct {
+	tokens    []calcToken
+	pos       int
+	depth     int
+	variables map[string]calcValue
+}
+
+func (p *calcParser) peek() calcToken {
+	return p.tokens[p.pos]
+}
+
+func (p *calcParser) next() calcToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != calcEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *calcParser) acceptOp(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != calcOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *calcParser) expectOp(op string) error {
+	if _, ok := p.acceptOp(op); !ok {
+		tok := p.peek()
+		return fmt.Errorf("expected %q at position %d, got %q", op, tok.pos+1, tok.text)
+	}
+
+	return nil
+}
+
+func (p *calcParser) enter() error {
+	p.depth++
+	if p.depth > maxCalculatorDepth {
+		return fmt.Errorf("expression is nested too deeply")
+	}
+
+	return nil
+}
+
+// parseExpression parses additions and subtractions.
+func (p *calcParser) parseExpression() (calcValue, error) {
+	if err := p.enter(); err != nil {
+		return calcValue{}, err
+	}
+	defer func() { p.depth-- }()
+
+	left, err := p.parseTerm()
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	for {
+		op, ok := p.acceptOp("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseTerm()
+		if err != nil {
+			return calcValue{}, err
+		}
+		if left, err = applyBinary(op, left, right); err != nil {
+			return calcValue{}, err
+		}
+	}
+}
+
+// parseTerm parses multiplications, divisions and remainders.
+func (p *calcParser) parseTerm() (calcValue, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	for {
+		op, ok := p.acceptOp("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return calcValue{}, err
+		}
+		if left, err = applyBinary(op, left, right); err != nil {
+			return calcValue{}, err
+		}
+	}
+}
+
+// parseUnary parses signs, which bind less tightly than powers so that
+// -2^2 is -4.
+func (p *calcParser) parseUnary() (calcValue, error) {
+	if op, ok := p.acceptOp("-", "+"); ok {
+		if err := p.enter(); err != nil {
+			return calcValue{}, err
+		}
+		defer func() { p.depth-- }()
+
+		value, err := p.parseUnary()
+		if err != nil || op == "+" {
+			return value, err
+		}
+		return mapValue(value, func(n float64) float64 { return -n }), nil
+	}
+
+	return p.parsePower()
+}
+
+// parsePower parses right associative powers.
+func (p *calcParser) parsePower() (calcValue, error) {
+	base, err := p.parsePostfix()
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	if _, ok := p.acceptOp("^"); !ok {
+		return base, nil
+	}
+
+	if err := p.enter(); err != nil {
+		return calcValue{}, err
+	}
+	defer func() { p.depth-- }()
+
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	return applyBinary("^", base, exponent)
+}
+
+// parsePostfix parses list indexing such as values[0] or values[-1].
+func (p *calcParser) parsePostfix() (calcValue, error) {
+	value, err := p.parsePrimary()
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	for {
+		tok := p.peek()
+		if _, ok := p.acceptOp("["); !ok {
+			return value, nil
+		}
+		index, err := p.parseExpression()
+		if err != nil {
+			return calcValue{}, err
+		}
+		if err := p.expectOp("]"); err != nil {
+			return calcValue{}, err
+		}
+
+		if !value.isList {
+			return calcValue{}, fmt.Errorf("cannot index a number at position %d", tok.pos+1)
+		}
+		if index.isList || index.num != math.Trunc(index.num) {
+			return calcValue{}, fmt.Errorf("list index at position %d must be a whole number", tok.pos+1)
+		}
+		i := int(index.num)
+		if i < 0 {
+			i += len(value.list)
+		}
+		if i < 0 || i >= len(value.list) {
+			return calcValue{}, fmt.Errorf("list index %d is out of range for a list of %d items", int(index.num), len(value.list))
+		}
+		value = calcNumber(value.list[i])
+	}
+}
+
+func (p *calcParser) parsePrimary() (calcValue, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case calcNum:
+		return calcNumber(tok.num), nil
+	case calcIdent:
+		if _, ok := p.acceptOp("("); ok {
+			return p.parseCall(tok)
+		}
+		if value, ok := p.variables[tok.text]; ok {
+			return value, nil
+		}
+		switch tok.text {
+		case "pi":
+			return calcNumber(math.Pi), nil
+		case "e":
+			return calcNumber(math.E), nil
+		}
+		return calcValue{}, fmt.Errorf("unknown variable %s", tok.text)
+	case calcOp:
+		switch tok.text {
+		case "(":
+			value, err := p.parseExpression()
+			if err != nil {
+				return calcValue{}, err
+			}
+			return value, p.expectOp(")")
+		case "[":
+			args, err := p.parseList("]")
+			if err != nil {
+				return calcValue{}, err
+			}
+			list, err := flattenArgs(args)
+			if err != nil {
+				return calcValue{}, err
+			}
+			return calcList(list), nil
+		}
+	}
+
+	return calcValue{}, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos+1)
+}
+
+// parseList parses comma separated expressions up to the closing token.
+func (p *calcParser) parseList(closing string) ([]calcValue, error) {
+	var values []calcValue
+	if _, ok := p.acceptOp(closing); ok {
+		return values, nil
+	}
+
+	for {
+		value, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		if _, ok := p.acceptOp(closing); ok {
+			return values, nil
+		}
+		if err := p.expectOp(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *calcParser) parseCall(name calcToken) (calcValue, error) {
+	fn, ok := calculatorFunctions[name.text]
+	if !ok {
+		return calcValue{}, fmt.Errorf("unknown function %s, must be one of: %s", name.text, strings.Join(calculatorFunctionNames(), ", "))
+	}
+
+	args, err := p.parseList(")")
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	value, err := fn(args)
+	if err != nil {
+		return calcValue{}, fmt.Errorf("%s: %w", name.text, err)
+	}
+
+	return value, nil
+}
+
+// mapValue applies f to a number or to each item of a list.
+func mapValue(v calcValue, f func(float64) float64) calcValue {
+	if !v.isList {
+		return calcNumber(f(v.num))
+	}
+
+	list := make([]float64, len(v.list))
+	for i, n := range v.list {
+		list[i] = f(n)
+	}
+
+	return calcList(list)
+}
+
+// applyBinary applies an operator to two numbers, element-wise to two lists
+// of the same length, or to each item of a list and a number.
+func applyBinary(op string, left, right calcValue) (calcValue, error) {
+	apply := func(a, b float64) (float64, error) {
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/":
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return a / b, nil
+		case "%":
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return math.Mod(a, b), nil
+		default:
+			return math.Pow(a, b), nil
+		}
+	}
+
+	if !left.isList && !right.isList {
+		n, err := apply(left.num, right.num)
+		return calcNumber(n), err
+	}
+
+	if left.isList && right.isList && len(left.list) != len(right.list) {
+		return calcValue{}, fmt.Errorf("cannot apply %s to lists of %d and %d items", op, len(left.list), len(right.list))
+	}
+
+	size := len(left.list)
+	if !left.isList {
+		size = len(right.list)
+	}
+
+	list := make([]float64, size)
+	for i := range list {
+		a, b := left.num, right.num
+		if left.isList {
+			a = left.list[i]
+		}
+		if right.isList {
+			b = right.list[i]
+		}
+
+		n, err := apply(a, b)
+		if err != nil {
+			return calcValue{}, err
+		}
+		list[i] = n
+	}
+
+	return calcList(list), nil
+}
+
+type calcFunction func(args []calcValue) (calcValue, error)
+
+// calculatorFunctions are the functions available to expressions.
+var calculatorFunctions = map[string]calcFunction{
+	"abs":   unaryFunc(math.Abs),
+	"ceil":  unaryFunc(math.Ceil),
+	"floor": unaryFunc(math.Floor),
+	"trunc": unaryFunc(math.Trunc),
+	"sqrt":  unaryFunc(math.Sqrt),
+	"cbrt":  unaryFunc(math.Cbrt),
+	"exp":   unaryFunc(math.Exp),
+	"ln":    unaryFunc(math.Log),
+	"log2":  unaryFunc(math.Log2),
+	"log10": unaryFunc(math.Log10),
+	"sin":   unaryFunc(math.Sin),
+	"cos":   unaryFunc(math.Cos),
+	"tan":   unaryFunc(math.Tan),
+	"asin":  unaryFunc(math.Asin),
+	"acos":  unaryFunc(math.Acos),
+	"atan":  unaryFunc(math.Atan),
+	"sign": unaryFunc(func(n float64) float64 {
+		switch {
+		case n > 0:
+			return 1
+		case n < 0:
+			return -1
+		}
+		return 0
+	}),
+	"round":      calcRound,
+	"log":        calcLog,
+	"pow":        calcPow,
+	"atan2":      calcAtan2,
+	"sum":        aggregateFunc(calcSum),
+	"product":    aggregateFunc(calcProduct),
+	"mean":       aggregateFunc(calcMean),
+	"avg":        aggregateFunc(calcMean),
+	"median":     aggregateFunc(calcMedian),
+	"min":        aggregateFunc(calcMin),
+	"max":        aggregateFunc(calcMax),
+	"variance":   aggregateFunc(calcVariance),
+	"stddev":     aggregateFunc(func(values []float64) float64 { return math.Sqrt(calcVariance(values)) }),
+	"count":      calcCount,
+	"percentile": calcPercentile,
+	"sort":       listFunc(calcSort),
+	"reverse":    listFunc(calcReverse),
+	"cumsum":     listFunc(calcCumsum),
+	"range":      calcRange,
+}
+
+func calculatorFunctionNames() []string {
+	names := make([]string, 0, len(calculatorFunctions))
+	for name := range calculatorFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// unaryFunc applies f to a number or to each item of a list.
+func unaryFunc(f func(float64) float64) calcFunction {
+	return func(args []calcValue) (calcValue, error) {
+		if len(args) != 1 {
+			return calcValue{}, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+
+		return mapValue(args[0], f), nil
+	}
+}
+
+// aggregateFunc reduces its arguments, which may be numbers or lists, to a
+// single number.
+func aggregateFunc(f func([]float64) float64) calcFunction {
+	return func(args []calcValue) (calcValue, error) {
+		values, err := flattenArgs(args)
+		if err != nil {
+			return calcValue{}, err
+		}
+		if len(values) == 0 {
+			return calcValue{}, fmt.Errorf("expected at least 1 value")
+		}
+
+		return calcNumber(f(values)), nil
+	}
+}
+
+// listFunc transforms a copy of a single list argument.
+func listFunc(f func([]float64) []float64) calcFunction {
+	return func(args []calcValue) (calcValue, error) {
+		if len(args) != 1 || !args[0].isList {
+			return calcValue{}, fmt.Errorf("expected a list")
+		}
+
+		return calcList(f(append([]float64(nil), args[0].list...))), nil
+	}
+}
+
+func flattenArgs(args []calcValue) ([]float64, error) {
+	var values []float64
+	for _, arg := range args {
+		if arg.isList {
+			values = append(values, arg.list...)
+		} else {
+			values = append(values, arg.num)
+		}
+		if len(values) > maxCalculatorListSize {
+			return nil, fmt.Errorf("lists can have at most %d items", maxCalculatorListSize)
+		}
+	}
+
+	return values, nil
+}
+
+// numberArgs checks that args are between min and max numbers.
+func numberArgs(args []calcValue, min, max int) ([]float64, error) {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return nil, fmt.Errorf("expected %d arguments, got %d", min, len(args))
+		}
+		return nil, fmt.Errorf("expected %d to %d arguments, got %d", min, max, len(args))
+	}
+
+	values := make([]float64, len(args))
+	for i, arg := range args {
+		if arg.isList {
+			return nil, fmt.Errorf("argument %d must be a number, got a list", i+1)
+		}
+		values[i] = arg.num
+	}
+
+	return values, nil
+}
+
+func calcRound(args []calcValue) (calcValue, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return calcValue{}, fmt.Errorf("expected 1 to 2 arguments, got %d", len(args))
+	}
+
+	scale := 1.0
+	if len(args) == 2 {
+		if args[1].isList || args[1].num != math.Trunc(args[1].num) {
+			return calcValue{}, fmt.Errorf("digits must be a whole number")
+		}
+		scale = math.Pow(10, args[1].num)
+	}
+
+	return mapValue(args[0], func(n float64) float64 {
+		return math.Round(n*scale) / scale
+	}), nil
+}
+
+func calcLog(args []calcValue) (calcValue, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return calcValue{}, fmt.Errorf("expected 1 to 2 arguments, got %d", len(args))
+	}
+	if len(args) == 1 {
+		return mapValue(args[0], math.Log10), nil
+	}
+	if args[1].isList {
+		return calcValue{}, fmt.Errorf("base must be a number")
+	}
+
+	base := math.Log(args[1].num)
+	return mapValue(args[0], func(n float64) float64 { return math.Log(n) / base }), nil
+}
+
+func calcPow(args []calcValue) (calcValue, error) {
+	if len(args) != 2 {
+		return calcValue{}, fmt.Errorf("expected 2 arguments, got %d", len(args))
+	}
+
+	return applyBinary("^", args[0], args[1])
+}
+
+func calcAtan2(args []calcValue) (calcValue, error) {
+	values, err := numberArgs(args, 2, 2)
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	return calcNumber(math.Atan2(values[0], values[1])), nil
+}
+
+func calcCount(args []calcValue) (calcValue, error) {
+	values, err := flattenArgs(args)
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	return calcNumber(float64(len(values))), nil
+}
+
+func calcPercentile(args []calcValue) (calcValue, error) {
+	if len(args) != 2 || !args[0].isList || args[1].isList {
+		return calcValue{}, fmt.Errorf("expected a list and a percentile between 0 and 100")
+	}
+	if len(args[0].list) == 0 {
+		return calcValue{}, fmt.Errorf("expected at least 1 value")
+	}
+
+	p := args[1].num
+	if p < 0 || p > 100 {
+		return calcValue{}, fmt.Errorf("percentile must be between 0 and 100")
+	}
+
+	values := calcSort(append([]float64(nil), args[0].list...))
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return calcNumber(values[lower] + (values[upper]-values[lower])*(rank-float64(lower))), nil
+}
+
+func calcRange(args []calcValue) (calcValue, error) {
+	values, err := numberArgs(args, 1, 3)
+	if err != nil {
+		return calcValue{}, err
+	}
+
+	start, end, step := 0.0, values[0], 1.0
+	if len(values) > 1 {
+		start, end = values[0], values[1]
+	}
+	if len(values) > 2 {
+		step = values[2]
+	}
+	if step == 0 {
+		return calcValue{}, fmt.Errorf("step must not be zero")
+	}
+
+	size := math.Ceil((end - start) / step)
+	if math.IsNaN(size) || size < 0 {
+		size = 0
+	}
+	if size > maxCalculatorListSize {
+		return calcValue{}, fmt.Errorf("lists can have at most %d items", maxCalculatorListSize)
+	}
+
+	list := []float64{}
+	for i := 0; i < int(size); i++ {
+		list = append(list, start+float64(i)*step)
+	}
+
+	return calcList(list), nil
+}
+
+func calcSum(values []float64) float64 {
+	var sum float64
+	for _, n := range values {
+		sum += n
+	}
+
+	return sum
+}
+
+func calcProduct(values []float64) float64 {
+	product := 1.0
+	for _, n := range values {
+		product *= n
+	}
+
+	return product
+}
+
+func calcMean(values []float64) float64 {
+	return calcSum(values) / float64(len(values))
+}
+
+func calcMedian(values []float64) float64 {
+	sorted := calcSort(append([]float64(nil), values...))
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+func calcMin(values []float64) float64 {
+	min := values[0]
+	for _, n := range values[1:] {
+		min = math.Min(min, n)
+	}
+
+	return min
+}
+
+func calcMax(values []float64) float64 {
+	max := values[0]
+	for _, n := range values[1:] {
+		max = math.Max(max, n)
+	}
+
+	return max
+}
+
+// calcVariance returns the population variance.
+func calcVariance(values []float64) float64 {
+	mean := calcMean(values)
+
+	var sum float64
+	for _, n := range values {
+		sum += (n - mean) * (n - mean)
+	}
+
+	return sum / float64(len(values))
+}
+
+func calcSort(values []float64) []float64 {
+	sort.Float64s(values)
+	return values
+}
+
+func calcReverse(values []float64) []float64 {
+	for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+		values[i], values[j] = values[j], values[i]
+	}
+
+	return values
+}
+
+func calcCumsum(values []float64) []float64 {
+	for i := 1; i < len(values); i++ {
+		values[i] += values[i-1]
+	}
+
+	return values
+}
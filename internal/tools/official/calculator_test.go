@@ -0,0 +1,143 @@
+package official
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateCalculation(t *testing.T) {
+	variables := map[string]calcValue{
+		"prices": calcList([]float64{9.5, 12, 7.25}),
+		"rate":   calcNumber(0.2),
+		"e":      calcNumber(10),
+	}
+
+	tests := []struct {
+		expression string
+		want       interface{}
+	}{
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"2 ^ 3 ^ 2", 512.0},
+		{"2 ** 10", 1024.0},
+		{"-2 ^ 2", -4.0},
+		{"--3", 3.0},
+		{"7 % 4", 3.0},
+		{"1_000_000 / 4", 250000.0},
+		{"1.5e3 + .5", 1500.5},
+		{"round(pi, 2)", 3.14},
+		{"e * 2", 20.0}, // variables shadow constants
+		{"sqrt(16) + abs(-4)", 8.0},
+		{"log(1000)", 3.0},
+		{"log(8, 2)", 3.0},
+		{"sum(prices)", 28.75},
+		{"max(prices, 20)", 20.0},
+		{"mean(1, 2, 3, 4)", 2.5},
+		{"median([5, 1, 3, 2])", 2.5},
+		{"stddev([2, 4, 4, 4, 5, 5, 7, 9])", 2.0},
+		{"percentile(range(1, 11), 90)", 9.1},
+		{"count(range(0, 1, 0.25))", 4.0},
+		{"prices[-1]", 7.25},
+		{"prices * (1 + rate)", []float64{11.4, 14.4, 8.7}},
+		{"[1, 2, 3] + [10, 20, 30]", []float64{11, 22, 33}},
+		{"round(prices / 3, 1)", []float64{3.2, 4, 2.4}},
+		{"sort(prices)", []float64{7.25, 9.5, 12}},
+		{"cumsum(reverse([1, 2, 3]))", []float64{3, 5, 6}},
+		{"range(3, 0, -1)", []float64{3, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			value, err := evaluateCalculation(tt.expression, variables)
+			require.NoError(t, err)
+
+			if want, ok := tt.want.([]float64); ok {
+				require.True(t, value.isList)
+				assert.InDeltaSlice(t, want, value.list, 1e-9)
+				return
+			}
+			require.False(t, value.isList)
+			assert.InDelta(t, tt.want, value.num, 1e-9)
+		})
+	}
+
+	// prices must not be changed by sort
+	assert.Equal(t, []float64{9.5, 12, 7.25}, variables["prices"].list)
+}
+
+func TestEvaluateCalculation_Errors(t *testing.T) {
+	tests := map[string]string{
+		"1 / 0":             "division by zero",
+		"sqrt(-1)":          "result is not a number",
+		"10 ^ 400":          "result is too large",
+		"x + 1":             "unknown variable x",
+		"1 +":               `unexpected "end of expression" at position 4`,
+		"(1 + 2":            `expected ")" at position 7, got "end of expression"`,
+		"1 2":               `unexpected "2" at position 3`,
+		"1 & 2":             `unexpected character '&' at position 3`,
+		"[1, 2] + [1]":      "cannot apply + to lists of 2 and 1 items",
+		"[1, 2][2]":         "list index 2 is out of range for a list of 2 items",
+		"3[0]":              "cannot index a number at position 2",
+		"sum()":             "sum: expected at least 1 value",
+		"atan2([1], 2)":     "atan2: argument 1 must be a number, got a list",
+		"range(0, 1e9)":     "range: lists can have at most 10000 items",
+		"percentile(1, 50)": "percentile: expected a list and a percentile between 0 and 100",
+		strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100): "expression is nested too deeply",
+	}
+
+	for expression, want := range tests {
+		_, err := evaluateCalculation(expression, nil)
+		assert.EqualError(t, err, want, expression)
+	}
+
+	_, err := evaluateCalculation("open(1)", nil)
+	assert.ErrorContains(t, err, "unknown function open, must be one of: abs, acos,")
+}
+
+func TestProvider_Calculator(t *testing.T) {
+	provider := NewProvider()
+	defs, err := provider.AddToolDefinition(context.Background(), &ast.Tool{
+		Name:   "calculate",
+		Uses:   "lacquer/calculator@v1",
+		Config: map[string]interface{}{"max_length": 30},
+	})
+	require.NoError(t, err)
+	require.Len(t, defs, 1)
+	assert.Equal(t, []string{"expression"}, defs[0].Parameters.Required)
+
+	execute := func(params map[string]interface{}) (*calculatorResult, string) {
+		data, _ := json.Marshal(params)
+		result, err := provider.ExecuteTool(newTestExecutionContext(), "calculate", data)
+		require.NoError(t, err)
+		if !result.Success {
+			return nil, result.Error
+		}
+		return result.Output.(*calculatorResult), ""
+	}
+
+	result, errMsg := execute(map[string]interface{}{"expression": "0.1 + 0.2"})
+	require.Empty(t, errMsg)
+	assert.Equal(t, 0.3, result.Result)
+
+	result, errMsg = execute(map[string]interface{}{
+		"expression": "total * share",
+		"variables":  map[string]interface{}{"total": 1200, "share": []float64{0.5, 0.3, 0.2}},
+	})
+	require.Empty(t, errMsg)
+	assert.Equal(t, []float64{600, 360, 240}, result.Result)
+
+	_, errMsg = execute(map[string]interface{}{
+		"expression": "x",
+		"variables":  map[string]interface{}{"x": "ten"},
+	})
+	assert.Equal(t, "variables.x must be a number or a list of numbers", errMsg)
+
+	_, errMsg = execute(map[string]interface{}{"expression": strings.Repeat("1+", 20) + "1"})
+	assert.Equal(t, "expression is longer than the maximum of 30 characters", errMsg)
+}
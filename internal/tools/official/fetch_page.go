@@ -0,0 +1,362 @@
+package official
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lacquerai/lacquer/internal/schema"
+	"golang.org/x/net/html"
+)
+
+const (
+	// fetchPageUserAgent is sent with every request, its product token is
+	// matched against the user-agent lines of robots.txt.
+	fetchPageUserAgent = "lacquer/1.0 (+https://lacquer.ai)"
+	robotsProduct      = "lacquer"
+
+	defaultFetchDelay     = time.Second
+	defaultFetchTimeout   = 30 * time.Second
+	defaultFetchMaxLength = 20000
+	maxFetchBytes         = 10 << 20
+)
+
+// fetchPage downloads web pages for agents. It checks robots.txt before
+// fetching a page, waits between requests to the same host and caches
+// every page for the lifetime of the provider, i.e. a single run.
+type fetchPage struct {
+	client    *http.Client
+	userAgent string
+	delay     time.Duration
+	maxLength int
+
+	mu     sync.Mutex
+	pages  map[string]*fetchPageResult
+	robots map[string]*robotsRules
+	hosts  map[string]*hostLimiter
+}
+
+// hostLimiter spaces requests to a single host.
+type hostLimiter struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+type fetchPageParams struct {
+	URL       string `json:"url"`
+	MaxLength int    `json:"max_length,omitempty"`
+}
+
+type fetchPageResult struct {
+	URL         string            `json:"url"`
+	Status      int               `json:"status"`
+	ContentType string            `json:"content_type,omitempty"`
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Content     string            `json:"content"`
+	Truncated   bool              `json:"truncated,omitempty"`
+	Links       []pageLink        `json:"links,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+func newFetchPage(_ *Provider, config map[string]interface{}) (tool, error) {
+	f := &fetchPage{
+		client:    &http.Client{Timeout: defaultFetchTimeout},
+		userAgent: fetchPageUserAgent,
+		delay:     defaultFetchDelay,
+		maxLength: defaultFetchMaxLength,
+		pages:     make(map[string]*fetchPageResult),
+		robots:    make(map[string]*robotsRules),
+		hosts:     make(map[string]*hostLimiter),
+	}
+
+	f.client.CheckRedirect = f.checkRedirect
+
+	for key, value := range config {
+		switch key {
+		case "user_agent":
+			s, ok := value.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("config.user_agent must be a string")
+			}
+			f.userAgent = s
+		case "delay", "timeout":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("config.%s must be a duration such as 1s", key)
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("config.%s must be a duration such as 1s", key)
+			}
+			if key == "delay" {
+				f.delay = d
+			} else {
+				f.client.Timeout = d
+			}
+		case "max_length":
+			n, ok := value.(int)
+			if !ok || n <= 0 {
+				return nil, fmt.Errorf("config.max_length must be a positive integer")
+			}
+			f.maxLength = n
+		}
+	}
+
+	return f, nil
+}
+
+func (f *fetchPage) description() string {
+	return "Fetch a web page and return its content as markdown along with its title, description, links and metadata. " +
+		"Pages disallowed by the site's robots.txt can't be fetched."
+}
+
+func (f *fetchPage) parameters() schema.JSON {
+	return schema.JSON{
+		Type: "object",
+		Properties: map[string]schema.JSON{
+			"url": {
+				Type:        "string",
+				Description: "The http or https URL of the page to fetch",
+			},
+			"max_length": {
+				Type:        "integer",
+				Description: "Maximum number of characters of content to return",
+			},
+		},
+		Required: []string{"url"},
+	}
+}
+
+func (f *fetchPage) execute(ctx context.Context, parameters json.RawMessage) (interface{}, error) {
+	var params fetchPageParams
+	if err := json.Unmarshal(parameters, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	u, err := url.Parse(strings.TrimSpace(params.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url must be an absolute http or https URL, got %q", params.URL)
+	}
+	u.Fragment = ""
+
+	result, err := f.fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	maxLength := f.maxLength
+	if params.MaxLength > 0 && params.MaxLength < maxLength {
+		maxLength = params.MaxLength
+	}
+
+	// copy so that truncating doesn't change the cached page
+	page := *result
+	if utf8.RuneCountInString(page.Content) > maxLength {
+		page.Content = string([]rune(page.Content)[:maxLength])
+		page.Truncated = true
+	}
+
+	return &page, nil
+}
+
+func (f *fetchPage) fetch(ctx context.Context, u *url.URL) (*fetchPageResult, error) {
+	key := u.String()
+
+	f.mu.Lock()
+	cached, ok := f.pages[key]
+	f.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	rules, err := f.checkRobots(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.get(ctx, u, rules.crawlDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", key, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("failed to fetch %s: %s", key, resp.Status)
+	}
+
+	result := &fetchPageResult{
+		URL:         resp.Request.URL.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(result.ContentType)
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml" || (mediaType == "" && looksLikeHTML(body)):
+		root, err := html.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+
+		page := convertHTML(root, resp.Request.URL)
+		result.Title = page.Title
+		result.Description = page.Metadata["description"]
+		if result.Description == "" {
+			result.Description = page.Metadata["og:description"]
+		}
+		result.Content = page.Markdown
+		result.Links = page.Links
+		result.Metadata = page.Metadata
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") || strings.HasSuffix(mediaType, "xml"):
+		result.Content = strings.ToValidUTF8(string(body), "")
+	default:
+		return nil, fmt.Errorf("cannot read %s, unsupported content type %s", key, mediaType)
+	}
+
+	f.mu.Lock()
+	f.pages[key] = result
+	f.mu.Unlock()
+
+	return result, nil
+}
+
+// checkRobots returns an error when robots.txt disallows fetching the URL.
+func (f *fetchPage) checkRobots(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	rules, err := f.robotsRules(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !rules.allowed(path) {
+		return nil, fmt.Errorf("fetching %s is disallowed by %s://%s/robots.txt", u, u.Scheme, u.Host)
+	}
+
+	return rules, nil
+}
+
+// checkRedirect applies robots.txt to every URL a page redirects to.
+func (f *fetchPage) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+
+	_, err := f.checkRobots(req.Context(), req.URL)
+	return err
+}
+
+// robotsRules returns the robots.txt rules of the URL's host. A missing file
+// allows everything while a file which can't be fetched disallows
+// everything, as required by RFC 9309.
+func (f *fetchPage) robotsRules(ctx context.Context, u *url.URL) (*robotsRules, error) {
+	origin := u.Scheme + "://" + u.Host
+
+	f.mu.Lock()
+	rules, ok := f.robots[origin]
+	f.mu.Unlock()
+	if ok {
+		return rules, nil
+	}
+
+	// robots.txt is fetched without waiting for the host's delay as it's
+	// requested while the limiter is held when following redirects, and
+	// its own redirects are followed without checking robots.txt again
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	client := *f.client
+	client.CheckRedirect = nil
+	resp, err := client.Do(req)
+	switch {
+	case err != nil:
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		rules = disallowAll
+	case resp.StatusCode >= 500:
+		rules = disallowAll
+	case resp.StatusCode >= 400:
+		rules = allowAll
+	default:
+		rules = parseRobots(resp.Body, robotsProduct)
+	}
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+
+	f.mu.Lock()
+	f.robots[origin] = rules
+	f.mu.Unlock()
+
+	return rules, nil
+}
+
+// get sends a request once the delay since the last request to the host has
+// passed. The crawl delay of robots.txt is used when it's longer.
+func (f *fetchPage) get(ctx context.Context, u *url.URL, crawlDelay time.Duration) (*http.Response, error) {
+	f.mu.Lock()
+	limiter, ok := f.hosts[u.Host]
+	if !ok {
+		limiter = &hostLimiter{}
+		f.hosts[u.Host] = limiter
+	}
+	f.mu.Unlock()
+
+	delay := f.delay
+	if crawlDelay > delay {
+		delay = crawlDelay
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if wait := time.Until(limiter.last.Add(delay)); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	defer func() { limiter.last = time.Now() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.8")
+
+	return f.client.Do(req)
+}
+
+func looksLikeHTML(body []byte) bool {
+	head := bytes.ToLower(bytes.TrimSpace(body[:min(len(body), 512)]))
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+}
@@ -0,0 +1,125 @@
+package official
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFetchPageServer(t *testing.T, hits *int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		assert.Equal(t, fetchPageUserAgent, r.UserAgent())
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html><head><title>Article</title><meta name="description" content="About things"></head>
+<body><h1>Things</h1><p>Some <a href="/private/more">more</a> text about things.</p></body></html>`))
+	})
+	mux.HandleFunc("/data.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/private/page", http.StatusFound)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newTestExecutionContext() *execcontext.ExecutionContext {
+	return &execcontext.ExecutionContext{
+		Context: execcontext.RunContext{Context: context.Background()},
+	}
+}
+
+func TestProvider_FetchPage(t *testing.T) {
+	var hits int32
+	server := newFetchPageServer(t, &hits)
+
+	provider := NewProvider()
+	defs, err := provider.AddToolDefinition(context.Background(), &ast.Tool{
+		Name:   "fetch_page",
+		Uses:   "lacquer/fetch-page@v1",
+		Config: map[string]interface{}{"delay": "0s"},
+	})
+	require.NoError(t, err)
+	require.Len(t, defs, 1)
+	assert.Equal(t, "fetch_page", defs[0].Name)
+	assert.Equal(t, []string{"url"}, defs[0].Parameters.Required)
+
+	execute := func(params map[string]interface{}) (*fetchPageResult, string) {
+		data, _ := json.Marshal(params)
+		result, err := provider.ExecuteTool(newTestExecutionContext(), "fetch_page", data)
+		require.NoError(t, err)
+		if !result.Success {
+			return nil, result.Error
+		}
+		return result.Output.(*fetchPageResult), ""
+	}
+
+	page, errMsg := execute(map[string]interface{}{"url": server.URL + "/article#top"})
+	require.Empty(t, errMsg)
+	assert.Equal(t, server.URL+"/article", page.URL)
+	assert.Equal(t, 200, page.Status)
+	assert.Equal(t, "Article", page.Title)
+	assert.Equal(t, "About things", page.Description)
+	assert.Equal(t, "# Things\n\nSome [more]("+server.URL+"/private/more) text about things.", page.Content)
+	assert.Equal(t, []pageLink{{Text: "more", URL: server.URL + "/private/more"}}, page.Links)
+
+	// pages are cached for the run and truncated per call
+	page, errMsg = execute(map[string]interface{}{"url": server.URL + "/article", "max_length": 8})
+	require.Empty(t, errMsg)
+	assert.Equal(t, "# Things", page.Content)
+	assert.True(t, page.Truncated)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	page, errMsg = execute(map[string]interface{}{"url": server.URL + "/data.json"})
+	require.Empty(t, errMsg)
+	assert.Equal(t, `{"ok":true}`, page.Content)
+
+	_, errMsg = execute(map[string]interface{}{"url": server.URL + "/private/page"})
+	assert.Equal(t, "fetching "+server.URL+"/private/page is disallowed by "+server.URL+"/robots.txt", errMsg)
+
+	_, errMsg = execute(map[string]interface{}{"url": server.URL + "/moved"})
+	assert.Contains(t, errMsg, "is disallowed by "+server.URL+"/robots.txt")
+
+	_, errMsg = execute(map[string]interface{}{"url": server.URL + "/missing"})
+	assert.Equal(t, "failed to fetch "+server.URL+"/missing: 404 Not Found", errMsg)
+
+	_, errMsg = execute(map[string]interface{}{"url": "file:///etc/passwd"})
+	assert.Equal(t, `url must be an absolute http or https URL, got "file:///etc/passwd"`, errMsg)
+}
+
+func TestProvider_AddToolDefinition(t *testing.T) {
+	provider := NewProvider()
+
+	_, err := provider.AddToolDefinition(context.Background(), &ast.Tool{Name: "browse", Uses: "lacquer/browser@v1"})
+	assert.EqualError(t, err, "unknown official tool lacquer/browser@v1, must be one of: calculator, fetch-page, web-search")
+
+	// the validator's list must match the available tools
+	assert.Equal(t, ast.ValidOfficialTools, Names())
+
+	_, err = provider.AddToolDefinition(context.Background(), &ast.Tool{
+		Name:   "fetch_page",
+		Uses:   "lacquer/fetch-page",
+		Config: map[string]interface{}{"delay": "soon"},
+	})
+	assert.EqualError(t, err, "invalid lacquer/fetch-page config: config.delay must be a duration such as 1s")
+}
@@ -0,0 +1,316 @@
+package official
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// pageLink is a link found on a page, resolved against the page URL.
+type pageLink struct {
+	Text string `json:"text,omitempty"`
+	URL  string `json:"url"`
+}
+
+// page is the content of an HTML page converted to markdown.
+type page struct {
+	Title    string
+	Markdown string
+	Links    []pageLink
+	Metadata map[string]string
+}
+
+// convertHTML converts an HTML document into markdown, collecting the title,
+// meta tags and links on the way. Scripts, styles, forms and navigation are
+// left out of the markdown but their links are still collected.
+func convertHTML(root *html.Node, base *url.URL) *page {
+	c := &converter{
+		base: base,
+		page: &page{Metadata: make(map[string]string)},
+		seen: make(map[string]bool),
+	}
+	c.walk(root, &c.out)
+
+	c.page.Markdown = tidyMarkdown(c.out.String())
+	return c.page
+}
+
+type converter struct {
+	base *url.URL
+	page *page
+	out  strings.Builder
+	seen map[string]bool
+	pre  bool
+}
+
+func (c *converter) walk(n *html.Node, out *strings.Builder) {
+	switch n.Type {
+	case html.TextNode:
+		if c.pre {
+			out.WriteString(n.Data)
+		} else {
+			out.WriteString(collapseSpace(n.Data))
+		}
+		return
+	case html.DocumentNode:
+		c.children(n, out)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe, atom.Button, atom.Select:
+		return
+	case atom.Head:
+		c.head(n)
+		return
+	case atom.Nav, atom.Form:
+		// keep their links without rendering them
+		c.children(n, &strings.Builder{})
+		return
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		text := strings.TrimSpace(collapseSpace(c.inline(n)))
+		if text != "" {
+			out.WriteString("\n\n" + strings.Repeat("#", level) + " " + text + "\n\n")
+		}
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Aside,
+		atom.Figure, atom.Figcaption, atom.Table, atom.Dl:
+		out.WriteString("\n\n")
+		c.children(n, out)
+		out.WriteString("\n\n")
+	case atom.Br:
+		out.WriteString("\n")
+	case atom.Hr:
+		out.WriteString("\n\n---\n\n")
+	case atom.Strong, atom.B:
+		c.wrap(n, out, "**")
+	case atom.Em, atom.I:
+		c.wrap(n, out, "_")
+	case atom.Code:
+		if c.pre {
+			c.children(n, out)
+		} else {
+			c.wrap(n, out, "`")
+		}
+	case atom.Pre:
+		c.pre = true
+		var code strings.Builder
+		c.children(n, &code)
+		c.pre = false
+		out.WriteString("\n\n```\n" + strings.Trim(code.String(), "\n") + "\n```\n\n")
+	case atom.Blockquote:
+		var quote strings.Builder
+		c.children(n, &quote)
+		out.WriteString("\n\n")
+		for _, line := range strings.Split(tidyMarkdown(quote.String()), "\n") {
+			out.WriteString("> " + line + "\n")
+		}
+		out.WriteString("\n")
+	case atom.Ul, atom.Ol:
+		out.WriteString("\n\n")
+		index := 1
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+				continue
+			}
+			marker := "- "
+			if n.DataAtom == atom.Ol {
+				marker = strconv.Itoa(index) + ". "
+			}
+			c.listItem(child, out, marker)
+			index++
+		}
+		out.WriteString("\n")
+	case atom.Tr:
+		var cells []string
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && (child.DataAtom == atom.Td || child.DataAtom == atom.Th) {
+				cells = append(cells, strings.TrimSpace(collapseSpace(c.inline(child))))
+			}
+		}
+		out.WriteString("\n| " + strings.Join(cells, " | ") + " |")
+	case atom.A:
+		c.link(n, out)
+	case atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			out.WriteString(alt)
+		}
+	default:
+		c.children(n, out)
+	}
+}
+
+func (c *converter) children(n *html.Node, out *strings.Builder) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.walk(child, out)
+	}
+}
+
+// inline renders the children of a node into a string.
+func (c *converter) inline(n *html.Node) string {
+	var b strings.Builder
+	c.children(n, &b)
+	return b.String()
+}
+
+func (c *converter) wrap(n *html.Node, out *strings.Builder, marker string) {
+	text := c.inline(n)
+	if strings.TrimSpace(text) == "" {
+		out.WriteString(text)
+		return
+	}
+	out.WriteString(marker + strings.TrimSpace(text) + marker)
+}
+
+// listItem writes a list item, indenting its following lines such as nested
+// lists below the marker.
+func (c *converter) listItem(n *html.Node, out *strings.Builder, marker string) {
+	lines := strings.Split(tidyMarkdown(c.inline(n)), "\n")
+	out.WriteString(marker + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		if line != "" {
+			out.WriteString(strings.Repeat(" ", len(marker)) + line + "\n")
+		}
+	}
+}
+
+func (c *converter) link(n *html.Node, out *strings.Builder) {
+	text := strings.TrimSpace(collapseSpace(c.inline(n)))
+	href := c.resolve(attr(n, "href"))
+	if href == "" {
+		out.WriteString(text)
+		return
+	}
+
+	if !c.seen[href] {
+		c.seen[href] = true
+		c.page.Links = append(c.page.Links, pageLink{Text: text, URL: href})
+	}
+
+	if text == "" {
+		return
+	}
+	out.WriteString("[" + text + "](" + href + ")")
+}
+
+// resolve returns the absolute URL of a link, ignoring fragments and
+// non-web schemes such as javascript: and mailto:.
+func (c *converter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	u.Fragment = ""
+
+	return u.String()
+}
+
+func (c *converter) head(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+
+		switch child.DataAtom {
+		case atom.Title:
+			if c.page.Title == "" {
+				c.page.Title = strings.TrimSpace(collapseSpace(textContent(child)))
+			}
+		case atom.Meta:
+			name := attr(child, "name")
+			if name == "" {
+				name = attr(child, "property")
+			}
+			content := strings.TrimSpace(attr(child, "content"))
+			if name != "" && content != "" {
+				c.page.Metadata[strings.ToLower(name)] = content
+			}
+		case atom.Link:
+			if strings.EqualFold(attr(child, "rel"), "canonical") {
+				if href := c.resolve(attr(child, "href")); href != "" {
+					c.page.Metadata["canonical"] = href
+				}
+			}
+		case atom.Base:
+			if href := attr(child, "href"); href != "" && c.base != nil {
+				if u, err := c.base.Parse(href); err == nil {
+					c.base = u
+				}
+			}
+		}
+	}
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+
+	return b.String()
+}
+
+var (
+	spacePattern      = regexp.MustCompile(`[ \t\r\n\f]+`)
+	listItemPattern   = regexp.MustCompile(`^(- |\d+\. )`)
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+func collapseSpace(s string) string {
+	return spacePattern.ReplaceAllString(s, " ")
+}
+
+// tidyMarkdown trims lines and collapses runs of blank lines, keeping the
+// indentation of nested list items and code blocks.
+func tidyMarkdown(s string) string {
+	lines := strings.Split(s, "\n")
+	code := false
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "```") {
+			code = !code
+			line = trimmed
+		} else if !code && !listItemPattern.MatchString(trimmed) {
+			line = trimmed
+		}
+		lines[i] = line
+	}
+
+	return strings.Trim(blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"), "\n")
+}
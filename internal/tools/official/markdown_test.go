@@ -0,0 +1,68 @@
+package official
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestConvertHTML(t *testing.T) {
+	doc := `<!doctype html>
+<html>
+<head>
+  <title> Example   Page </title>
+  <meta name="description" content="An example page">
+  <meta property="og:type" content="article">
+  <link rel="canonical" href="/canonical">
+  <script>var x = 1;</script>
+</head>
+<body>
+  <nav><a href="/home">Home</a></nav>
+  <h1>Welcome</h1>
+  <p>Read the <a href="docs/guide#intro">guide</a> or <strong>contact</strong> <em>us</em>.</p>
+  <ul>
+    <li>First</li>
+    <li>Second
+      <ol><li>Nested</li></ol>
+    </li>
+  </ul>
+  <pre><code>line 1
+  indented</code></pre>
+  <blockquote>Quoted text</blockquote>
+  <table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></table>
+  <a href="mailto:me@example.com">mail</a>
+  <a href="javascript:void(0)">js</a>
+</body>
+</html>`
+
+	root, err := html.Parse(strings.NewReader(doc))
+	require.NoError(t, err)
+
+	base, _ := url.Parse("https://example.com/blog/post")
+	page := convertHTML(root, base)
+
+	assert.Equal(t, "Example Page", page.Title)
+	assert.Equal(t, map[string]string{
+		"description": "An example page",
+		"og:type":     "article",
+		"canonical":   "https://example.com/canonical",
+	}, page.Metadata)
+	assert.Equal(t, []pageLink{
+		{Text: "Home", URL: "https://example.com/home"},
+		{Text: "guide", URL: "https://example.com/blog/docs/guide"},
+	}, page.Links)
+
+	assert.Equal(t, "# Welcome\n\n"+
+		"Read the [guide](https://example.com/blog/docs/guide) or **contact** _us_.\n\n"+
+		"- First\n"+
+		"- Second\n"+
+		"  1. Nested\n\n"+
+		"```\nline 1\n  indented\n```\n\n"+
+		"> Quoted text\n\n"+
+		"| a | b |\n| 1 | 2 |\n\n"+
+		"mail js", page.Markdown)
+}
@@ -0,0 +1,167 @@
+// Package official provides the built-in tools which agents use with
+// `uses: lacquer/<name>`, such as lacquer/fetch-page, lacquer/web-search and lacquer/calculator.
+package official
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/schema"
+	"github.com/lacquerai/lacquer/internal/tools"
+)
+
+// tool is a built-in tool implementation.
+type tool interface {
+	description() string
+	parameters() schema.JSON
+	execute(ctx context.Context, parameters json.RawMessage) (interface{}, error)
+}
+
+// factories creates the built-in tools from the tool's config, keyed by the
+// name used in `uses: lacquer/<name>`.
+var factories = map[string]func(p *Provider, config map[string]interface{}) (tool, error){
+	"calculator": newCalculator,
+	"fetch-page": newFetchPage,
+	"web-search": newWebSearch,
+}
+
+// Names returns the names of the official tools.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Provider implements the tools.Provider interface for the official tools.
+type Provider struct {
+	tools  map[string]tool
+	search SearchConfig
+	mu     sync.RWMutex
+}
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithSearchConfig sets the backends available to the web-search tool.
+func WithSearchConfig(config SearchConfig) Option {
+	return func(p *Provider) {
+		p.search = config
+	}
+}
+
+// NewProvider creates a new official tool provider.
+func NewProvider(opts ...Option) *Provider {
+	p := &Provider{
+		tools: make(map[string]tool),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+func (p *Provider) GetType() ast.ToolType {
+	return ast.ToolTypeOfficial
+}
+
+// AddToolDefinition creates the official tool referenced by the definition.
+// The description and parameters default to the tool's own.
+func (p *Provider) AddToolDefinition(_ context.Context, def *ast.Tool) ([]tools.Tool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.tools[def.Name]; exists {
+		return nil, fmt.Errorf("tool %s already exists", def.Name)
+	}
+
+	name, _, _ := strings.Cut(strings.TrimPrefix(def.Uses, "lacquer/"), "@")
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown official tool %s, must be one of: %s", def.Uses, strings.Join(Names(), ", "))
+	}
+
+	impl, err := factory(p, def.Config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s config: %w", def.Uses, err)
+	}
+	p.tools[def.Name] = impl
+
+	description := def.Description
+	if description == "" {
+		description = impl.description()
+	}
+
+	return []tools.Tool{
+		{
+			Name:        def.Name,
+			Description: description,
+			Parameters:  impl.parameters(),
+		},
+	}, nil
+}
+
+// ExecuteTool executes an official tool
+func (p *Provider) ExecuteTool(execCtx *execcontext.ExecutionContext, toolName string, parameters json.RawMessage) (*tools.Result, error) {
+	p.mu.RLock()
+	impl, exists := p.tools[toolName]
+	p.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("official tool %s not found", toolName)
+	}
+
+	startTime := time.Now()
+	output, err := impl.execute(execCtx.Context.Context, parameters)
+	duration := time.Since(startTime)
+
+	if err != nil {
+		return &tools.Result{
+			ToolName: toolName,
+			Success:  false,
+			Error:    err.Error(),
+			Duration: duration,
+		}, nil
+	}
+
+	return &tools.Result{
+		ToolName: toolName,
+		Success:  true,
+		Output:   output,
+		Duration: duration,
+	}, nil
+}
+
+// searchAPIKey returns the API key of a search backend from the config, or
+// from the backend's environment variables.
+func (p *Provider) searchAPIKey(name string) string {
+	if key := os.ExpandEnv(p.search.APIKeys[name]); key != "" {
+		return key
+	}
+
+	if backend, ok := searchBackends[name]; ok {
+		for _, envVar := range backend.envVars {
+			if key := os.Getenv(envVar); key != "" {
+				return key
+			}
+		}
+	}
+
+	return ""
+}
+
+// Close cleans up resources
+func (p *Provider) Close() error {
+	return nil
+}
@@ -0,0 +1,176 @@
+package official
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules are the rules of a robots.txt file that apply to a crawler,
+// following RFC 9309.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// allowAll and disallowAll are used when robots.txt is missing or can't be
+// fetched respectively.
+var (
+	allowAll    = &robotsRules{}
+	disallowAll = &robotsRules{rules: []robotsRule{{allow: false, pattern: "/"}}}
+)
+
+// parseRobots reads the groups of a robots.txt file and returns the rules of
+// the group matching the product token, falling back to the * group.
+func parseRobots(r io.Reader, product string) *robotsRules {
+	product = strings.ToLower(product)
+
+	type group struct {
+		agents []string
+		rules  robotsRules
+	}
+
+	var groups []*group
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, 512*1024))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// consecutive user-agent lines share a group
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || (key == "disallow" && value == "") {
+				continue
+			}
+			current.rules.rules = append(current.rules.rules, robotsRule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.rules.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	var fallback *robotsRules
+	var matched *robotsRules
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			switch {
+			case agent == "*":
+				if fallback == nil {
+					fallback = &robotsRules{}
+				}
+				merge(fallback, &g.rules)
+			case strings.HasPrefix(product, agent):
+				if matched == nil {
+					matched = &robotsRules{}
+				}
+				merge(matched, &g.rules)
+			}
+		}
+	}
+
+	switch {
+	case matched != nil:
+		return matched
+	case fallback != nil:
+		return fallback
+	default:
+		return allowAll
+	}
+}
+
+// merge combines groups for the same user agent as required by RFC 9309.
+func merge(dst, src *robotsRules) {
+	dst.rules = append(dst.rules, src.rules...)
+	if src.crawlDelay > dst.crawlDelay {
+		dst.crawlDelay = src.crawlDelay
+	}
+}
+
+// allowed reports whether a path, including its query, may be fetched. The
+// longest matching rule wins and allow wins ties.
+func (r *robotsRules) allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+
+	allow := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allow = rule.allow
+		}
+	}
+
+	return allow
+}
+
+// matchRobotsPattern matches a path against a rule supporting the * wildcard
+// and the $ end anchor.
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		last := i == len(parts)-2
+		if last && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+
+	if anchored && len(parts) == 1 {
+		return rest == ""
+	}
+
+	return true
+}
@@ -0,0 +1,65 @@
+package official
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRobots(t *testing.T) {
+	robots := `
+# comments are ignored
+User-agent: *
+Disallow: /private
+Crawl-delay: 5
+
+User-agent: Lacquer
+User-agent: other-bot
+Disallow: /admin
+Allow: /admin/public
+Disallow: /*.pdf$
+Crawl-delay: 2
+`
+
+	rules := parseRobots(strings.NewReader(robots), "lacquer")
+	assert.Equal(t, 2*time.Second, rules.crawlDelay)
+
+	tests := map[string]bool{
+		"/":                     true,
+		"/private":              true, // only the * group disallows it
+		"/admin":                false,
+		"/admin/users":          false,
+		"/admin/public/page":    true,
+		"/files/report.pdf":     false,
+		"/files/report.pdf?x=1": true,
+		"/robots.txt":           true,
+	}
+	for path, allowed := range tests {
+		assert.Equal(t, allowed, rules.allowed(path), path)
+	}
+
+	fallback := parseRobots(strings.NewReader(robots), "another")
+	assert.False(t, fallback.allowed("/private/data"))
+	assert.True(t, fallback.allowed("/admin"))
+	assert.Equal(t, 5*time.Second, fallback.crawlDelay)
+}
+
+func TestParseRobots_NoGroups(t *testing.T) {
+	rules := parseRobots(strings.NewReader("Sitemap: https://example.com/sitemap.xml\n"), "lacquer")
+	assert.True(t, rules.allowed("/anything"))
+
+	rules = parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"), "lacquer")
+	assert.True(t, rules.allowed("/anything"))
+}
+
+func TestMatchRobotsPattern(t *testing.T) {
+	assert.True(t, matchRobotsPattern("/a", "/abc"))
+	assert.True(t, matchRobotsPattern("/a*c", "/abbbc/d"))
+	assert.True(t, matchRobotsPattern("/a$", "/a"))
+	assert.False(t, matchRobotsPattern("/a$", "/ab"))
+	assert.True(t, matchRobotsPattern("/*/x$", "/dir/x"))
+	assert.False(t, matchRobotsPattern("/*/x$", "/dir/x/y"))
+	assert.False(t, matchRobotsPattern("/b", "/abc"))
+}
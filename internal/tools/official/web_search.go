@@ -0,0 +1,418 @@
+package official
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/schema"
+)
+
+const (
+	defaultSearchResults    = 5
+	maxSearchResults        = 20
+	defaultSearchMaxQueries = 20
+	searchTimeout           = 30 * time.Second
+)
+
+// SearchConfig configures the backends of the web-search tool, it's read
+// from the search section of the Lacquer config file.
+type SearchConfig struct {
+	// Provider is the backend used when a tool doesn't set one, defaults to
+	// the first backend with an API key.
+	Provider string `json:"provider" yaml:"provider" mapstructure:"provider"`
+	// APIKeys maps backend names to their API keys, environment variables
+	// such as ${TAVILY_API_KEY} are expanded.
+	APIKeys map[string]string `json:"api_keys" yaml:"api_keys" mapstructure:"api_keys"`
+}
+
+// SearchResult is a single result, normalized across backends.
+type SearchResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Snippet     string `json:"snippet,omitempty"`
+	PublishedAt string `json:"published_at,omitempty"`
+}
+
+// searchBackend queries a search API.
+type searchBackend struct {
+	// envVars are checked for an API key when none is configured
+	envVars []string
+	search  func(ctx context.Context, client *http.Client, endpoint, apiKey, query string, count int) ([]SearchResult, error)
+	// endpoint is the URL of the API
+	endpoint string
+}
+
+// searchBackends lists the supported backends in order of preference.
+var searchBackends = map[string]*searchBackend{
+	"tavily": {
+		envVars:  []string{"TAVILY_API_KEY"},
+		search:   searchTavily,
+		endpoint: "https://api.tavily.com/search",
+	},
+	"bing": {
+		envVars:  []string{"BING_SEARCH_API_KEY", "BING_API_KEY"},
+		search:   searchBing,
+		endpoint: "https://api.bing.microsoft.com/v7.0/search",
+	},
+	"serpapi": {
+		envVars:  []string{"SERPAPI_API_KEY"},
+		search:   searchSerpAPI,
+		endpoint: "https://serpapi.com/search.json",
+	},
+}
+
+var searchBackendOrder = []string{"tavily", "bing", "serpapi"}
+
+// webSearch searches the web with one of the configured backends. Results
+// are cached for the run and every other query counts towards the run's
+// query budget.
+type webSearch struct {
+	client     *http.Client
+	backend    *searchBackend
+	name       string
+	apiKey     string
+	maxResults int
+	maxQueries int
+
+	mu      sync.Mutex
+	queries int
+	cache   map[string][]SearchResult
+}
+
+type webSearchParams struct {
+	Query      string `json:"query"`
+	MaxResults int    `json:"max_results,omitempty"`
+}
+
+type webSearchResult struct {
+	Query    string         `json:"query"`
+	Provider string         `json:"provider"`
+	Results  []SearchResult `json:"results"`
+}
+
+func newWebSearch(p *Provider, config map[string]interface{}) (tool, error) {
+	s := &webSearch{
+		client:     &http.Client{Timeout: searchTimeout},
+		maxResults: defaultSearchResults,
+		maxQueries: defaultSearchMaxQueries,
+		cache:      make(map[string][]SearchResult),
+	}
+
+	s.name = p.search.Provider
+	for key, value := range config {
+		switch key {
+		case "provider":
+			name, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("config.provider must be one of: %s", strings.Join(searchBackendOrder, ", "))
+			}
+			s.name = name
+		case "api_key":
+			apiKey, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("config.api_key must be a string")
+			}
+			s.apiKey = os.ExpandEnv(apiKey)
+		case "max_results":
+			n, ok := value.(int)
+			if !ok || n <= 0 || n > maxSearchResults {
+				return nil, fmt.Errorf("config.max_results must be between 1 and %d", maxSearchResults)
+			}
+			s.maxResults = n
+		case "max_queries":
+			n, ok := value.(int)
+			if !ok || n <= 0 {
+				return nil, fmt.Errorf("config.max_queries must be a positive integer")
+			}
+			s.maxQueries = n
+		}
+	}
+
+	if s.name == "" {
+		for _, name := range searchBackendOrder {
+			if s.apiKey != "" || p.searchAPIKey(name) != "" {
+				s.name = name
+				break
+			}
+		}
+	}
+	if s.name == "" {
+		return nil, fmt.Errorf("no search provider is configured, set an API key for one of %s in the search.api_keys section of the config file or with %s",
+			strings.Join(searchBackendOrder, ", "), searchEnvVars())
+	}
+
+	backend, ok := searchBackends[s.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown search provider %s, must be one of: %s", s.name, strings.Join(searchBackendOrder, ", "))
+	}
+	s.backend = backend
+
+	if s.apiKey == "" {
+		s.apiKey = p.searchAPIKey(s.name)
+	}
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("no API key for search provider %s, set search.api_keys.%s in the config file or %s", s.name, s.name, backend.envVars[0])
+	}
+
+	return s, nil
+}
+
+func searchEnvVars() string {
+	vars := make([]string, 0, len(searchBackendOrder))
+	for _, name := range searchBackendOrder {
+		vars = append(vars, searchBackends[name].envVars[0])
+	}
+
+	return strings.Join(vars, ", ")
+}
+
+func (s *webSearch) description() string {
+	return "Search the web and return the title, URL, snippet and publication date of the top results. " +
+		"Use fetch_page, when available, to read a result in full."
+}
+
+func (s *webSearch) parameters() schema.JSON {
+	return schema.JSON{
+		Type: "object",
+		Properties: map[string]schema.JSON{
+			"query": {
+				Type:        "string",
+				Description: "The search query",
+			},
+			"max_results": {
+				Type:        "integer",
+				Description: fmt.Sprintf("Maximum number of results to return, at most %d", s.maxResults),
+			},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (s *webSearch) execute(ctx context.Context, parameters json.RawMessage) (interface{}, error) {
+	var params webSearchParams
+	if err := json.Unmarshal(parameters, &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	query := strings.Join(strings.Fields(params.Query), " ")
+	if query == "" {
+		return nil, fmt.Errorf("query must not be empty")
+	}
+
+	count := s.maxResults
+	if params.MaxResults > 0 && params.MaxResults < count {
+		count = params.MaxResults
+	}
+
+	s.mu.Lock()
+	results, cached := s.cache[strings.ToLower(query)]
+	if !cached {
+		if s.queries >= s.maxQueries {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("the search budget of %d queries for this run has been used, answer with the results found so far", s.maxQueries)
+		}
+		s.queries++
+	}
+	s.mu.Unlock()
+
+	if !cached {
+		var err error
+		results, err = s.backend.search(ctx, s.client, s.backend.endpoint, s.apiKey, query, s.maxResults)
+		if err != nil {
+			return nil, fmt.Errorf("%s search failed: %w", s.name, err)
+		}
+
+		s.mu.Lock()
+		s.cache[strings.ToLower(query)] = results
+		s.mu.Unlock()
+	}
+
+	if len(results) > count {
+		results = results[:count]
+	}
+
+	return &webSearchResult{Query: query, Provider: s.name, Results: results}, nil
+}
+
+func searchTavily(ctx context.Context, client *http.Client, endpoint, apiKey, query string, count int) ([]SearchResult, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":       query,
+		"max_results": count,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	var resp struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"published_date"`
+		} `json:"results"`
+	}
+	if err := doSearchRequest(client, req, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content, PublishedAt: normalizeDate(r.PublishedDate)})
+	}
+
+	return results, nil
+}
+
+func searchBing(ctx context.Context, client *http.Client, endpoint, apiKey, query string, count int) ([]SearchResult, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("q", query)
+	q.Set("count", strconv.Itoa(count))
+	q.Set("responseFilter", "Webpages")
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", apiKey)
+
+	var resp struct {
+		WebPages struct {
+			Value []struct {
+				Name          string `json:"name"`
+				URL           string `json:"url"`
+				Snippet       string `json:"snippet"`
+				DatePublished string `json:"datePublished"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	if err := doSearchRequest(client, req, &resp); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(resp.WebPages.Value))
+	for _, r := range resp.WebPages.Value {
+		results = append(results, SearchResult{Title: r.Name, URL: r.URL, Snippet: r.Snippet, PublishedAt: normalizeDate(r.DatePublished)})
+	}
+
+	return results, nil
+}
+
+func searchSerpAPI(ctx context.Context, client *http.Client, endpoint, apiKey, query string, count int) ([]SearchResult, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("engine", "google")
+	q.Set("q", query)
+	q.Set("num", strconv.Itoa(count))
+	q.Set("api_key", apiKey)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		OrganicResults []struct {
+			Position int    `json:"position"`
+			Title    string `json:"title"`
+			Link     string `json:"link"`
+			Snippet  string `json:"snippet"`
+			Date     string `json:"date"`
+		} `json:"organic_results"`
+	}
+	if err := doSearchRequest(client, req, &resp); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(resp.OrganicResults, func(i, j int) bool {
+		return resp.OrganicResults[i].Position < resp.OrganicResults[j].Position
+	})
+
+	results := make([]SearchResult, 0, len(resp.OrganicResults))
+	for _, r := range resp.OrganicResults {
+		results = append(results, SearchResult{Title: r.Title, URL: r.Link, Snippet: r.Snippet, PublishedAt: normalizeDate(r.Date)})
+	}
+
+	return results, nil
+}
+
+func doSearchRequest(client *http.Client, req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		// the request URL may contain the API key
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		message := strings.TrimSpace(string(data))
+		if len(message) > 200 {
+			message = message[:200]
+		}
+		return fmt.Errorf("%s: %s", resp.Status, message)
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// dateLayouts are the publication date formats returned by the backends.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.0000000Z",
+	"2006-01-02",
+	time.RFC1123,
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+}
+
+// normalizeDate converts a publication date to RFC 3339, dates which can't
+// be parsed, such as "3 days ago", are left out.
+func normalizeDate(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,168 @@
+package official
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withSearchEndpoint points a backend at a test server for the duration of
+// the test.
+func withSearchEndpoint(t *testing.T, name string, handler http.HandlerFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	backend := searchBackends[name]
+	original := backend.endpoint
+	backend.endpoint = server.URL
+
+	t.Cleanup(func() {
+		backend.endpoint = original
+		server.Close()
+	})
+}
+
+func executeSearch(t *testing.T, p *Provider, name string, params map[string]interface{}) (*webSearchResult, string) {
+	t.Helper()
+
+	data, _ := json.Marshal(params)
+	result, err := p.ExecuteTool(newTestExecutionContext(), name, data)
+	require.NoError(t, err)
+	if !result.Success {
+		return nil, result.Error
+	}
+
+	return result.Output.(*webSearchResult), ""
+}
+
+func TestWebSearch_Backends(t *testing.T) {
+	for _, name := range searchBackendOrder {
+		t.Setenv(searchBackends[name].envVars[0], "")
+	}
+
+	withSearchEndpoint(t, "tavily", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer tvly-key", r.Header.Get("Authorization"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "go generics", body["query"])
+
+		_, _ = w.Write([]byte(`{"results":[{"title":"Generics","url":"https://go.dev/doc/tutorial/generics","content":"A tutorial","published_date":"2022-03-15"}]}`))
+	})
+	withSearchEndpoint(t, "bing", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bing-key", r.Header.Get("Ocp-Apim-Subscription-Key"))
+		assert.Equal(t, "go generics", r.URL.Query().Get("q"))
+
+		_, _ = w.Write([]byte(`{"webPages":{"value":[{"name":"Generics","url":"https://go.dev","snippet":"Go","datePublished":"2022-03-15T08:00:00.0000000Z"}]}}`))
+	})
+	withSearchEndpoint(t, "serpapi", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "serp-key", r.URL.Query().Get("api_key"))
+		assert.Equal(t, "google", r.URL.Query().Get("engine"))
+
+		_, _ = w.Write([]byte(`{"organic_results":[
+			{"position":2,"title":"Second","link":"https://b.example","snippet":"b","date":"3 days ago"},
+			{"position":1,"title":"First","link":"https://a.example","snippet":"a","date":"Mar 15, 2022"}
+		]}`))
+	})
+
+	p := NewProvider(WithSearchConfig(SearchConfig{
+		APIKeys: map[string]string{"tavily": "tvly-key", "bing": "bing-key", "serpapi": "serp-key"},
+	}))
+
+	tests := []struct {
+		provider string
+		want     []SearchResult
+	}{
+		{"tavily", []SearchResult{{Title: "Generics", URL: "https://go.dev/doc/tutorial/generics", Snippet: "A tutorial", PublishedAt: "2022-03-15T00:00:00Z"}}},
+		{"bing", []SearchResult{{Title: "Generics", URL: "https://go.dev", Snippet: "Go", PublishedAt: "2022-03-15T08:00:00Z"}}},
+		{"serpapi", []SearchResult{
+			{Title: "First", URL: "https://a.example", Snippet: "a", PublishedAt: "2022-03-15T00:00:00Z"},
+			{Title: "Second", URL: "https://b.example", Snippet: "b"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			_, err := p.AddToolDefinition(context.Background(), &ast.Tool{
+				Name:   "search_" + tt.provider,
+				Uses:   "lacquer/web-search@v1",
+				Config: map[string]interface{}{"provider": tt.provider},
+			})
+			require.NoError(t, err)
+
+			result, errMsg := executeSearch(t, p, "search_"+tt.provider, map[string]interface{}{"query": "  go   generics "})
+			require.Empty(t, errMsg)
+			assert.Equal(t, "go generics", result.Query)
+			assert.Equal(t, tt.provider, result.Provider)
+			assert.Equal(t, tt.want, result.Results)
+		})
+	}
+}
+
+func TestWebSearch_BudgetAndCache(t *testing.T) {
+	var requests int32
+	withSearchEndpoint(t, "tavily", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(`{"results":[{"title":"a","url":"https://a.example"},{"title":"b","url":"https://b.example"}]}`))
+	})
+
+	t.Setenv("TAVILY_API_KEY", "tvly-env")
+	p := NewProvider()
+	_, err := p.AddToolDefinition(context.Background(), &ast.Tool{
+		Name:   "web_search",
+		Uses:   "lacquer/web-search",
+		Config: map[string]interface{}{"max_queries": 2},
+	})
+	require.NoError(t, err)
+
+	result, errMsg := executeSearch(t, p, "web_search", map[string]interface{}{"query": "one", "max_results": 1})
+	require.Empty(t, errMsg)
+	assert.Len(t, result.Results, 1)
+
+	// repeated queries are served from the cache and don't use the budget
+	result, errMsg = executeSearch(t, p, "web_search", map[string]interface{}{"query": "ONE"})
+	require.Empty(t, errMsg)
+	assert.Len(t, result.Results, 2)
+
+	_, errMsg = executeSearch(t, p, "web_search", map[string]interface{}{"query": "two"})
+	require.Empty(t, errMsg)
+
+	_, errMsg = executeSearch(t, p, "web_search", map[string]interface{}{"query": "three"})
+	assert.Equal(t, "the search budget of 2 queries for this run has been used, answer with the results found so far", errMsg)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestWebSearch_Errors(t *testing.T) {
+	for _, name := range searchBackendOrder {
+		t.Setenv(searchBackends[name].envVars[0], "")
+	}
+	t.Setenv("BING_API_KEY", "")
+
+	p := NewProvider()
+	_, err := p.AddToolDefinition(context.Background(), &ast.Tool{Name: "web_search", Uses: "lacquer/web-search"})
+	assert.EqualError(t, err, "invalid lacquer/web-search config: no search provider is configured, set an API key for one of tavily, bing, serpapi in the search.api_keys section of the config file or with TAVILY_API_KEY, BING_SEARCH_API_KEY, SERPAPI_API_KEY")
+
+	_, err = p.AddToolDefinition(context.Background(), &ast.Tool{Name: "web_search", Uses: "lacquer/web-search", Config: map[string]interface{}{"provider": "bing"}})
+	assert.EqualError(t, err, "invalid lacquer/web-search config: no API key for search provider bing, set search.api_keys.bing in the config file or BING_SEARCH_API_KEY")
+
+	_, err = p.AddToolDefinition(context.Background(), &ast.Tool{Name: "web_search", Uses: "lacquer/web-search", Config: map[string]interface{}{"provider": "altavista"}})
+	assert.EqualError(t, err, "invalid lacquer/web-search config: unknown search provider altavista, must be one of: tavily, bing, serpapi")
+
+	withSearchEndpoint(t, "serpapi", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"Invalid API key"}`, http.StatusUnauthorized)
+	})
+	t.Setenv("SERPAPI_API_KEY", "bad")
+	_, err = p.AddToolDefinition(context.Background(), &ast.Tool{Name: "web_search", Uses: "lacquer/web-search"})
+	require.NoError(t, err)
+
+	_, errMsg := executeSearch(t, p, "web_search", map[string]interface{}{"query": "x"})
+	assert.Equal(t, `serpapi search failed: 401 Unauthorized: {"error":"Invalid API key"}`, errMsg)
+}
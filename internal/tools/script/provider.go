@@ -1,6 +1,7 @@
 package script
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -72,7 +73,7 @@ func (stp *ScriptToolProvider) GetName() string {
 }
 
 // AddToolDefinition adds a tool to the provider
-func (stp *ScriptToolProvider) AddToolDefinition(tool *ast.Tool) ([]tools.Tool, error) {
+func (stp *ScriptToolProvider) AddToolDefinition(_ context.Context, tool *ast.Tool) ([]tools.Tool, error) {
 	stp.mu.Lock()
 	defer stp.mu.Unlock()
 	if _, exists := stp.tools[tool.Name]; exists {
@@ -49,8 +49,9 @@ type Provider interface {
 
 	// AddToolDefinition adds a tool definition to the provider
 	// and returns the tools available from the definition.
-	// This could return multiple tools if the definition is a MCP server.
-	AddToolDefinition(tool *ast.Tool) ([]Tool, error)
+	// This could return multiple tools if the definition is a MCP server,
+	// which is started or connected to with ctx.
+	AddToolDefinition(ctx context.Context, tool *ast.Tool) ([]Tool, error)
 
 	// ExecuteTool executes a tool with the given parameters
 	ExecuteTool(execCtx *execcontext.ExecutionContext, toolName string, parameters json.RawMessage) (*Result, error)
@@ -92,7 +93,7 @@ func (tr *Registry) RegisterProvider(provider Provider) error {
 
 // RegisterToolsForAgent registers tools for an agent
 // This will add the tools to the tool registry and return the tools available from the agent.
-func (tr *Registry) RegisterToolsForAgent(agent *ast.Agent) error {
+func (tr *Registry) RegisterToolsForAgent(ctx context.Context, agent *ast.Agent) error {
 	tr.mu.RLock()
 	defer tr.mu.RUnlock()
 
@@ -102,7 +103,7 @@ func (tr *Registry) RegisterToolsForAgent(agent *ast.Agent) error {
 			return fmt.Errorf("provider for tool type %s not found", tool.Type())
 		}
 
-		tools, err := provider.AddToolDefinition(tool)
+		tools, err := provider.AddToolDefinition(ctx, tool)
 		if err != nil {
 			return fmt.Errorf("failed to add tool %s: %w", tool.Name, err)
 		}
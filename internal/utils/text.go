@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a unicode normalization form applied to text produced by
+// models, tools and scripts.
+type Normalization string
+
+const (
+	// NormalizationNone leaves text as is, only invalid UTF-8 is repaired.
+	NormalizationNone Normalization = ""
+	// NormalizationNFC composes characters, e.g. "e" + combining accent becomes "é".
+	NormalizationNFC Normalization = "nfc"
+	// NormalizationNFKC composes characters and replaces compatibility
+	// characters, e.g. full-width letters and ligatures, with their canonical form.
+	NormalizationNFKC Normalization = "nfkc"
+)
+
+// ParseNormalization parses a normalization form, accepting "none" as an
+// alias for no normalization.
+func ParseNormalization(s string) (Normalization, error) {
+	switch n := Normalization(strings.ToLower(strings.TrimSpace(s))); n {
+	case NormalizationNone, NormalizationNFC, NormalizationNFKC:
+		return n, nil
+	case "none":
+		return NormalizationNone, nil
+	default:
+		return NormalizationNone, fmt.Errorf("unsupported unicode normalization %q, must be one of none, nfc or nfkc", s)
+	}
+}
+
+// SanitizeText repairs invalid UTF-8, replacing invalid byte sequences with
+// the unicode replacement character, removes NUL bytes and byte order marks
+// and then applies the given normalization form.
+func SanitizeText(s string, form Normalization) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+
+	if strings.ContainsAny(s, "\x00\ufeff") {
+		s = strings.NewReplacer("\x00", "", "\ufeff", "").Replace(s)
+	}
+
+	switch form {
+	case NormalizationNFC:
+		s = norm.NFC.String(s)
+	case NormalizationNFKC:
+		s = norm.NFKC.String(s)
+	}
+
+	return s
+}
+
+// SanitizeValue applies SanitizeText to every string within a value
+// decoded from JSON or YAML, returning a sanitized copy.
+func SanitizeValue(v interface{}, form Normalization) interface{} {
+	switch val := v.(type) {
+	case string:
+		return SanitizeText(val, form)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = SanitizeValue(item, form)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = SanitizeValue(item, form)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// TruncateText shortens s to at most maxRunes characters, appending an
+// ellipsis when the text was cut. Truncation happens on rune boundaries so
+// multi-byte characters are never split.
+func TruncateText(s string, maxRunes int) string {
+	if maxRunes <= 0 || utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+
+	var n int
+	for i := range s {
+		if n == maxRunes {
+			return s[:i] + "..."
+		}
+		n++
+	}
+
+	return s
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeText(t *testing.T) {
+	t.Run("repairs invalid UTF-8", func(t *testing.T) {
+		out := SanitizeText("caf\xc3 ok \xff", NormalizationNone)
+		assert.True(t, utf8.ValidString(out))
+		assert.Equal(t, "caf� ok �", out)
+	})
+
+	t.Run("strips NUL bytes and byte order marks", func(t *testing.T) {
+		assert.Equal(t, "hello", SanitizeText("\ufeffhel\x00lo", NormalizationNone))
+	})
+
+	t.Run("normalizes", func(t *testing.T) {
+		decomposed := "cafe\u0301"
+		assert.Equal(t, decomposed, SanitizeText(decomposed, NormalizationNone))
+		assert.Equal(t, "caf\u00e9", SanitizeText(decomposed, NormalizationNFC))
+		assert.Equal(t, "fi", SanitizeText("\ufb01", NormalizationNFKC))
+		assert.Equal(t, "\ufb01", SanitizeText("\ufb01", NormalizationNFC))
+	})
+}
+
+func TestSanitizeValue(t *testing.T) {
+	in := map[string]interface{}{
+		"text":  "a\xffb",
+		"list":  []interface{}{"c\xffd", 1},
+		"count": 2,
+	}
+
+	out := SanitizeValue(in, NormalizationNone)
+	assert.Equal(t, map[string]interface{}{
+		"text":  "a�b",
+		"list":  []interface{}{"c�d", 1},
+		"count": 2,
+	}, out)
+	assert.Equal(t, "a\xffb", in["text"], "input should not be modified")
+}
+
+func TestTruncateText(t *testing.T) {
+	assert.Equal(t, "hello", TruncateText("hello", 10))
+	assert.Equal(t, "hello", TruncateText("hello", 5))
+	assert.Equal(t, "hel...", TruncateText("hello", 3))
+	assert.Equal(t, "hello", TruncateText("hello", 0))
+
+	out := TruncateText("日本語のテキスト", 3)
+	assert.Equal(t, "日本語...", out)
+	assert.True(t, utf8.ValidString(out))
+
+	out = TruncateText("👍🏽👍🏽👍🏽", 1)
+	assert.True(t, utf8.ValidString(out))
+}
+
+func TestParseNormalization(t *testing.T) {
+	for in, want := range map[string]Normalization{
+		"":     NormalizationNone,
+		"none": NormalizationNone,
+		"NFC":  NormalizationNFC,
+		"nfkc": NormalizationNFKC,
+	} {
+		got, err := ParseNormalization(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseNormalization("nfd")
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileState is the subset of file information used to detect changes.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watcher detects changes to a set of files and directories by polling their
+// modification times. Polling is used rather than OS notifications as editors
+// frequently replace files on save which native watchers handle inconsistently.
+type Watcher struct {
+	paths    []string
+	interval time.Duration
+	debounce time.Duration
+	snapshot map[string]fileState
+	mu       sync.Mutex
+}
+
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// WithInterval sets how often the watched paths are polled for changes.
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// WithDebounce sets how long the watched paths must remain unchanged before
+// a change is reported, so that a burst of writes results in a single event.
+func WithDebounce(debounce time.Duration) Option {
+	return func(w *Watcher) {
+		w.debounce = debounce
+	}
+}
+
+// New creates a watcher for the given paths. Directories are watched
+// recursively.
+func New(paths []string, opts ...Option) *Watcher {
+	w := &Watcher{
+		interval: 250 * time.Millisecond,
+		debounce: 300 * time.Millisecond,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.SetPaths(paths)
+
+	return w
+}
+
+// SetPaths replaces the set of watched paths and takes a fresh snapshot of
+// their current state.
+func (w *Watcher) SetPaths(paths []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.paths = paths
+	w.snapshot = scan(paths)
+}
+
+// Paths returns the currently watched paths.
+func (w *Watcher) Paths() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.paths
+}
+
+// Wait blocks until one or more of the watched paths change and then remain
+// unchanged for the debounce period. It returns the sorted list of changed
+// files, or the context error if the context is cancelled first.
+func (w *Watcher) Wait(ctx context.Context) ([]string, error) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	changed := make(map[string]bool)
+	var lastChange time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case now := <-ticker.C:
+			w.mu.Lock()
+			current := scan(w.paths)
+			diff := compare(w.snapshot, current)
+			w.snapshot = current
+			w.mu.Unlock()
+
+			if len(diff) > 0 {
+				for _, path := range diff {
+					changed[path] = true
+				}
+				lastChange = now
+				continue
+			}
+
+			if len(changed) > 0 && now.Sub(lastChange) >= w.debounce {
+				files := make([]string, 0, len(changed))
+				for path := range changed {
+					files = append(files, path)
+				}
+				sort.Strings(files)
+
+				return files, nil
+			}
+		}
+	}
+}
+
+// scan records the state of every file under the given paths, missing
+// paths are ignored so that files which are created later are picked up.
+func scan(paths []string) map[string]fileState {
+	states := make(map[string]fileState)
+
+	for _, root := range paths {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if d.IsDir() {
+				if path != root && d.Name()[0] == '.' {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil
+			}
+
+			states[path] = fileState{
+				modTime: info.ModTime(),
+				size:    info.Size(),
+			}
+
+			return nil
+		})
+	}
+
+	return states
+}
+
+// compare returns every path which was added, removed or modified between
+// the two snapshots.
+func compare(previous, current map[string]fileState) []string {
+	var diff []string
+
+	for path, state := range current {
+		if prev, ok := previous[path]; !ok || prev != state {
+			diff = append(diff, path)
+		}
+	}
+
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			diff = append(diff, path)
+		}
+	}
+
+	return diff
+}
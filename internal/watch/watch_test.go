@@ -0,0 +1,43 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher_DetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "workflow.laq.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("version: \"1.0\""), 0600))
+
+	w := New([]string{dir}, WithInterval(10*time.Millisecond), WithDebounce(30*time.Millisecond))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.WriteFile(file, []byte("version: \"1.0\"\n# changed"), 0600)
+		_ = os.WriteFile(filepath.Join(dir, "script.sh"), []byte("echo hi"), 0600)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	changed, err := w.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "script.sh"), file}, changed)
+}
+
+func TestWatcher_Cancelled(t *testing.T) {
+	w := New([]string{t.TempDir()}, WithInterval(10*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := w.Wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
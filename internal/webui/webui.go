@@ -0,0 +1,255 @@
+// Package webui serves a web UI for browsing the run history: the recorded
+// runs, their steps and transcripts, two runs side by side, and the daily
+// duration and cost of each workflow.
+package webui
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/history"
+	pkgEvents "github.com/lacquerai/lacquer/pkg/events"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed static
+var static embed.FS
+
+// defaultLimit is how many runs are listed when the request doesn't set a
+// limit.
+const defaultLimit = 100
+
+// Handler serves the web UI and the API it reads the run history from.
+type Handler struct {
+	store     *history.Store
+	runLogDir string
+	mux       *http.ServeMux
+	now       func() time.Time
+}
+
+// NewHandler returns the web UI for the runs of the store, whose
+// transcripts are read from the run logs in runLogDir.
+func NewHandler(store *history.Store, runLogDir string) *Handler {
+	h := &Handler{
+		store:     store,
+		runLogDir: runLogDir,
+		mux:       http.NewServeMux(),
+		now:       time.Now,
+	}
+
+	assets, _ := fs.Sub(static, "static")
+	h.mux.Handle("GET /", http.FileServerFS(assets))
+	h.mux.HandleFunc("GET /api/runs", h.listRuns)
+	h.mux.HandleFunc("GET /api/runs/{id}", h.getRun)
+	h.mux.HandleFunc("GET /api/runs/{id}/events", h.getEvents)
+	h.mux.HandleFunc("GET /api/compare", h.compareRuns)
+	h.mux.HandleFunc("GET /api/trends", h.getTrends)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// listRuns lists the runs, newest first, without their inputs, outputs and
+// state, along with how many match. They can be filtered by workflow and
+// status, and limited to those started since a duration ago or a date.
+func (h *Handler) listRuns(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	runs, err := h.runsSince(query.Get("since"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultLimit
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit %q, must be a number of at least 1", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	workflow, status := query.Get("workflow"), query.Get("status")
+	listed := make([]*history.Run, 0, min(limit, len(runs)))
+	total := 0
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if (workflow != "" && run.Workflow != workflow) || (status != "" && run.Status != status) {
+			continue
+		}
+
+		total++
+		if len(listed) < limit {
+			run.Inputs, run.Outputs, run.State = nil, nil, nil
+			listed = append(listed, run)
+		}
+	}
+
+	writeJSON(w, map[string]any{
+		"runs":  listed,
+		"total": total,
+	})
+}
+
+// getRun returns a run with its steps, inputs and outputs.
+func (h *Handler) getRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := h.run(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, run)
+}
+
+// getEvents returns the transcript of a run, every event of its run log.
+// Runs without a run log have no events.
+func (h *Handler) getEvents(w http.ResponseWriter, r *http.Request) {
+	run, ok := h.run(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	events, err := readRunLog(filepath.Join(h.runLogDir, run.RunID+".jsonl"), h.store.Cipher())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"events": events,
+	})
+}
+
+// compareRuns compares the run other to the run base.
+func (h *Handler) compareRuns(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	base, ok := h.run(w, query.Get("base"))
+	if !ok {
+		return
+	}
+	other, ok := h.run(w, query.Get("other"))
+	if !ok {
+		return
+	}
+
+	writeJSON(w, history.Compare(base, other))
+}
+
+// getTrends returns the daily duration and cost of each workflow, of the
+// runs started since a duration ago or a date, 30 days by default.
+func (h *Handler) getTrends(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since := query.Get("since")
+	if since == "" {
+		since = "30d"
+	}
+
+	runs, err := h.runsSince(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trends := history.Trends(runs)
+	if workflow := query.Get("workflow"); workflow != "" {
+		filtered := trends[:0]
+		for _, trend := range trends {
+			if trend.Workflow == workflow {
+				filtered = append(filtered, trend)
+			}
+		}
+		trends = filtered
+	}
+
+	writeJSON(w, map[string]any{
+		"trends": trends,
+	})
+}
+
+func (h *Handler) runsSince(value string) ([]*history.Run, error) {
+	since, err := history.ParseSince(value, h.now())
+	if err != nil {
+		return nil, err
+	}
+
+	return h.store.List(since)
+}
+
+// run returns the run with the ID, writing the error when it can't be read.
+func (h *Handler) run(w http.ResponseWriter, runID string) (*history.Run, bool) {
+	if runID == "" {
+		http.Error(w, "run ID is required", http.StatusBadRequest)
+		return nil, false
+	}
+
+	run, err := h.store.Get(runID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+
+	return run, true
+}
+
+// readRunLog reads the events of a run log, oldest first, decrypting them
+// with the cipher. Lines which can't be decoded are skipped.
+func readRunLog(path string, cipher *encryption.Cipher) ([]pkgEvents.ExecutionEvent, error) {
+	file, err := os.Open(path) // #nosec G304 - path is a run log of a recorded run
+	if errors.Is(err, os.ErrNotExist) {
+		return []pkgEvents.ExecutionEvent{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	events := []pkgEvents.ExecutionEvent{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line, err := cipher.OpenLine(scanner.Bytes())
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to decrypt run log event")
+			continue
+		}
+
+		var event pkgEvents.ExecutionEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("Failed to decode run log event")
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read run log: %w", err)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	return events, nil
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,172 @@
+package webui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lacquerai/lacquer/internal/encryption"
+	"github.com/lacquerai/lacquer/internal/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+
+	dir := t.TempDir()
+	store := history.NewStore(filepath.Join(dir, "history"))
+	start := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	for i, run := range []*history.Run{
+		{RunID: "run-1", Workflow: "triage", Status: "completed", Duration: time.Second, Inputs: map[string]interface{}{"ticket": "42"},
+			Steps: []history.Step{{StepID: "classify", Status: "completed"}}},
+		{RunID: "run-2", Workflow: "triage", Status: "failed", Duration: 2 * time.Second,
+			Steps: []history.Step{{StepID: "classify", Status: "failed"}}},
+		{RunID: "run-3", Workflow: "digest", Status: "completed"},
+	} {
+		run.StartTime = start.Add(time.Duration(i) * time.Hour)
+		run.EndTime = run.StartTime.Add(run.Duration)
+		require.NoError(t, store.Save(run))
+	}
+
+	logDir := filepath.Join(dir, "logs")
+	require.NoError(t, os.MkdirAll(logDir, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(logDir, "run-1.jsonl"), []byte(
+		`{"type":"step_started","timestamp":"2024-03-31T12:00:00Z","run_id":"run-1","step_id":"classify"}`+"\n"+
+			`not json`+"\n"+
+			`{"type":"token_delta","timestamp":"2024-03-31T12:00:01Z","run_id":"run-1","step_id":"classify","text":"bug"}`+"\n"), 0600))
+
+	h := NewHandler(store, logDir)
+	h.now = func() time.Time { return start.Add(24 * time.Hour) }
+
+	return h
+}
+
+func get(t *testing.T, h http.Handler, path string, value any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if value != nil && recorder.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), value))
+	}
+
+	return recorder
+}
+
+func TestHandler_Index(t *testing.T) {
+	recorder := get(t, newTestHandler(t), "/", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "<title>Lacquer runs</title>")
+}
+
+func TestHandler_ListRuns(t *testing.T) {
+	h := newTestHandler(t)
+
+	var list struct {
+		Runs  []*history.Run `json:"runs"`
+		Total int            `json:"total"`
+	}
+	get(t, h, "/api/runs", &list)
+	assert.Equal(t, 3, list.Total)
+	require.Len(t, list.Runs, 3)
+	assert.Equal(t, "run-3", list.Runs[0].RunID)
+	assert.Nil(t, list.Runs[2].Inputs)
+
+	get(t, h, "/api/runs?workflow=triage&limit=1", &list)
+	assert.Equal(t, 2, list.Total)
+	require.Len(t, list.Runs, 1)
+	assert.Equal(t, "run-2", list.Runs[0].RunID)
+
+	get(t, h, "/api/runs?status=failed", &list)
+	assert.Equal(t, 1, list.Total)
+
+	recorder := get(t, h, "/api/runs?limit=0", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, `invalid limit "0", must be a number of at least 1`, strings.TrimSpace(recorder.Body.String()))
+}
+
+func TestHandler_GetRun(t *testing.T) {
+	h := newTestHandler(t)
+
+	var run history.Run
+	get(t, h, "/api/runs/run-1", &run)
+	assert.Equal(t, map[string]interface{}{"ticket": "42"}, run.Inputs)
+
+	recorder := get(t, h, "/api/runs/run-9", nil)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestHandler_GetEvents(t *testing.T) {
+	h := newTestHandler(t)
+
+	var transcript struct {
+		Events []map[string]any `json:"events"`
+	}
+	get(t, h, "/api/runs/run-1/events", &transcript)
+	require.Len(t, transcript.Events, 2)
+	assert.Equal(t, "step_started", transcript.Events[0]["type"])
+	assert.Equal(t, "bug", transcript.Events[1]["text"])
+
+	// runs without a run log have an empty transcript
+	get(t, h, "/api/runs/run-2/events", &transcript)
+	assert.Empty(t, transcript.Events)
+}
+
+func TestHandler_GetEvents_Encrypted(t *testing.T) {
+	cipher, err := encryption.NewCipher(make([]byte, encryption.KeySize))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	store := history.NewStore(filepath.Join(dir, "history"), history.WithCipher(cipher))
+	require.NoError(t, store.Save(&history.Run{RunID: "run-1", Workflow: "triage", Status: "completed"}))
+
+	line, err := cipher.SealLine([]byte(`{"type":"token_delta","timestamp":"2024-03-31T12:00:01Z","run_id":"run-1","text":"secret"}`))
+	require.NoError(t, err)
+	logDir := filepath.Join(dir, "logs")
+	require.NoError(t, os.MkdirAll(logDir, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(logDir, "run-1.jsonl"), append(line, '\n'), 0600))
+
+	var transcript struct {
+		Events []map[string]any `json:"events"`
+	}
+	get(t, NewHandler(store, logDir), "/api/runs/run-1/events", &transcript)
+	require.Len(t, transcript.Events, 1)
+	assert.Equal(t, "secret", transcript.Events[0]["text"])
+}
+
+func TestHandler_CompareRuns(t *testing.T) {
+	h := newTestHandler(t)
+
+	var comparison history.Comparison
+	get(t, h, "/api/compare?base=run-1&other=run-2", &comparison)
+	assert.Equal(t, time.Second, comparison.DurationDelta)
+	require.Len(t, comparison.Steps, 1)
+	assert.Equal(t, "failed", comparison.Steps[0].Other.Status)
+
+	recorder := get(t, h, "/api/compare?base=run-1", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestHandler_Trends(t *testing.T) {
+	h := newTestHandler(t)
+
+	var trends struct {
+		Trends []*history.Trend `json:"trends"`
+	}
+	get(t, h, "/api/trends?workflow=triage", &trends)
+	require.Len(t, trends.Trends, 1)
+	require.Len(t, trends.Trends[0].Points, 1)
+	assert.Equal(t, 2, trends.Trends[0].Points[0].Runs)
+
+	get(t, h, "/api/trends?since=1h", &trends)
+	assert.Empty(t, trends.Trends)
+
+	recorder := get(t, h, "/api/trends?since=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
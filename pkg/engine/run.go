@@ -28,7 +28,9 @@ type Option func(*engine.Runner)
 // The provided listener will receive execution events throughout the workflow
 // lifecycle, including workflow start/completion, step progress, errors, and
 // retry attempts. This enables real-time monitoring and logging of workflow
-// execution progress.
+// execution progress. The events package provides listeners for the common
+// cases, such as events.NewCallbackSink, and describes the order events are
+// delivered in.
 //
 // Parameters:
 //   - listener: An implementation of events.Listener that will receive execution events
@@ -0,0 +1,71 @@
+package events
+
+// Filter reports whether an event should be passed on, sinks created with
+// filters only receive the events matched by all of them.
+type Filter func(event ExecutionEvent) bool
+
+// OfType matches events of any of the given types.
+func OfType(types ...ExecutionEventType) Filter {
+	return func(event ExecutionEvent) bool {
+		for _, t := range types {
+			if event.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ForRun matches the events of a single run.
+func ForRun(runID string) Filter {
+	return func(event ExecutionEvent) bool {
+		return event.RunID == runID
+	}
+}
+
+// ForStep matches the events of any of the given steps, including the
+// events of their actions and tool calls. Workflow events have no step and
+// are never matched.
+func ForStep(stepIDs ...string) Filter {
+	return func(event ExecutionEvent) bool {
+		for _, id := range stepIDs {
+			if event.StepID == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the events which filter doesn't match.
+func Not(filter Filter) Filter {
+	return func(event ExecutionEvent) bool {
+		return !filter(event)
+	}
+}
+
+// All matches the events matched by every filter, with no filters every
+// event is matched.
+func All(filters ...Filter) Filter {
+	return func(event ExecutionEvent) bool {
+		for _, filter := range filters {
+			if !filter(event) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches the events matched by at least one filter, with no filters no
+// event is matched.
+func Any(filters ...Filter) Filter {
+	return func(event ExecutionEvent) bool {
+		for _, filter := range filters {
+			if filter(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -5,6 +5,31 @@
 // The core types allow for real-time progress monitoring of Lacquer workflow executions,
 // providing detailed information about each step's execution state, timing, and any
 // errors that occur during the process.
+//
+// # Delivery
+//
+// A Listener receives the events of a run on a single channel, in the order
+// they happened: a step's started event comes before its actions and tool
+// calls, which come before its completion or failure, and the run's
+// completion or failure event comes after the events of all its steps.
+// Events of steps run in parallel are interleaved. StartListening is called
+// in its own goroutine when the run starts, the channel is closed once the
+// run ends and StopListening is called after StartListening returns, so
+// that every event has been handled by the time the run returns. A listener
+// returning early misses the remaining events without blocking the run.
+//
+// The channel is buffered, a listener which can't keep up eventually slows
+// down the run rather than losing events.
+//
+// ChannelSink, CallbackSink and JSONWriterSink implement Listener for the
+// common ways of consuming events, and Filter restricts which events they
+// receive:
+//
+//	sink := events.NewCallbackSink(func(event events.ExecutionEvent) {
+//		fmt.Printf("%s failed: %s\n", event.StepID, event.Error)
+//	}, events.OfType(events.EventStepFailed))
+//
+//	err := engine.RunWorkflow(ctx, "workflow.laq.yml", inputs, &outputs, engine.WithProgressListener(sink))
 package events
 
 import (
@@ -38,6 +63,10 @@ const (
 	// EventStepFailed is emitted when a step fails during execution.
 	EventStepFailed ExecutionEventType = "step_failed"
 
+	// EventStepTimedOut is emitted when a step is cancelled because it ran
+	// longer than its timeout, before its step_failed event.
+	EventStepTimedOut ExecutionEventType = "step_timed_out"
+
 	// EventStepSkipped is emitted when a step is skipped due to conditions.
 	EventStepSkipped ExecutionEventType = "step_skipped"
 
@@ -52,6 +81,20 @@ const (
 
 	// EventStepActionFailed is emitted when a specific action within a step fails.
 	EventStepActionFailed ExecutionEventType = "step_action_failed"
+
+	// EventTokenDelta is emitted for each chunk of output streamed by a model.
+	EventTokenDelta ExecutionEventType = "token_delta"
+
+	// EventStateUpdated is emitted when a step updates the workflow state.
+	EventStateUpdated ExecutionEventType = "state_updated"
+
+	// EventModeration is emitted when a moderation policy matches a prompt
+	// sent to a model or its response, for auditing.
+	EventModeration ExecutionEventType = "moderation"
+
+	// EventPromptCompressed is emitted when the prompt of a step is
+	// compressed to fit in the context window of its model.
+	EventPromptCompressed ExecutionEventType = "prompt_compressed"
 )
 
 // ExecutionEvent represents a single event that occurred during workflow execution.
@@ -70,8 +113,13 @@ type ExecutionEvent struct {
 	ActionID string `json:"action_id,omitempty"`
 	// StepIndex is the zero-based index of the step in the workflow (optional).
 	StepIndex int `json:"step_index,omitempty"`
-	// Duration represents how long the operation took (for completion events).
+	// Duration represents how long the operation took (for completion events),
+	// measured with the monotonic clock so that it isn't skewed by changes
+	// to the wall clock.
 	Duration time.Duration `json:"duration,omitempty"`
+	// StartTime is when the operation started (for step completion and
+	// failure events), Timestamp being when it ended.
+	StartTime time.Time `json:"start_time,omitzero"`
 	// Error contains the error message if the event represents a failure.
 	Error string `json:"error,omitempty"`
 	// Attempt indicates which retry attempt this event represents (1-based).
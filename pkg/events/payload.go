@@ -0,0 +1,574 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of the event envelope and payload schema. It
+// is incremented whenever a field is removed or changes meaning, adding new
+// payload kinds or fields does not change the version.
+const SchemaVersion = 1
+
+// Metadata keys used by events which carry data for typed payloads.
+const (
+	// MetadataTool is the name of the tool used by a tool call action.
+	MetadataTool = "tool"
+	// MetadataToolInput is the input the tool was called with.
+	MetadataToolInput = "tool_input"
+	// MetadataStateUpdates contains the state values updated by a step.
+	MetadataStateUpdates = "updates"
+	// MetadataCached is set on step completion events when the step result
+	// was reused from a previous run instead of being executed.
+	MetadataCached = "cached"
+	// MetadataCostCenter and MetadataOwner are the cost labels of a
+	// top-level step, set on its completion event.
+	MetadataCostCenter = "cost_center"
+	MetadataOwner      = "owner"
+	// MetadataTokens is the number of tokens used by a top-level step and
+	// MetadataCost their estimated cost in USD, set on its completion event.
+	MetadataTokens = "tokens"
+	MetadataCost   = "cost"
+	// MetadataErrorCategory is the category of the provider error a step
+	// failed with, such as rate_limit or context_length, set on its failure
+	// event.
+	MetadataErrorCategory = "error_category"
+	// MetadataTimeout is the timeout of a step, set on its timed out event.
+	MetadataTimeout = "timeout"
+	// MetadataClockSkew is how far the wall clock moved against the
+	// monotonic clock during a run, e.g. because it was adjusted by NTP, set
+	// on the run's completion or failure event when it exceeds a second.
+	// Timestamps of events before and after the adjustment can't be
+	// compared, their durations are measured with the monotonic clock.
+	MetadataClockSkew = "clock_skew"
+	// MetadataModerationPolicy, MetadataModerationStage and
+	// MetadataModerationAction are the moderation policy which matched,
+	// whether it matched a prompt or an output and the action taken, set on
+	// moderation events. MetadataModerationCategories are the categories
+	// flagged by a moderation API.
+	MetadataModerationPolicy     = "policy"
+	MetadataModerationStage      = "stage"
+	MetadataModerationAction     = "action"
+	MetadataModerationCategories = "categories"
+	// MetadataPromptTokens and MetadataCompressedTokens are the estimated
+	// tokens of a prompt before and after it was compressed to fit in
+	// MetadataContextWindow, set on prompt compressed events along with the
+	// MetadataDroppedSections and the MetadataSummarized expressions.
+	MetadataPromptTokens     = "prompt_tokens"
+	MetadataCompressedTokens = "compressed_tokens"
+	MetadataContextWindow    = "context_window"
+	MetadataDroppedSections  = "dropped_sections"
+	MetadataSummarized       = "summarized"
+)
+
+// PayloadKind identifies the type of payload carried by an Envelope.
+type PayloadKind string
+
+const (
+	KindWorkflowStarted   PayloadKind = "workflow_started"
+	KindWorkflowCompleted PayloadKind = "workflow_completed"
+	KindWorkflowFailed    PayloadKind = "workflow_failed"
+	KindStepStarted       PayloadKind = "step_started"
+	KindStepProgress      PayloadKind = "step_progress"
+	KindStepCompleted     PayloadKind = "step_completed"
+	KindStepFailed        PayloadKind = "step_failed"
+	KindStepTimedOut      PayloadKind = "step_timed_out"
+	KindStepSkipped       PayloadKind = "step_skipped"
+	KindStepRetrying      PayloadKind = "step_retrying"
+	KindActionStarted     PayloadKind = "action_started"
+	KindActionCompleted   PayloadKind = "action_completed"
+	KindActionFailed      PayloadKind = "action_failed"
+	KindToolCall          PayloadKind = "tool_call"
+	KindToolCallCompleted PayloadKind = "tool_call_completed"
+	KindToolCallFailed    PayloadKind = "tool_call_failed"
+	KindTokenDelta        PayloadKind = "token_delta"
+	KindStateUpdated      PayloadKind = "state_updated"
+	KindModeration        PayloadKind = "moderation"
+	KindPromptCompressed  PayloadKind = "prompt_compressed"
+)
+
+// Payload is the typed data of an event, every payload kind has its own
+// concrete type.
+type Payload interface {
+	Kind() PayloadKind
+}
+
+// WorkflowStarted is the payload of a workflow_started event.
+type WorkflowStarted struct{}
+
+// WorkflowCompleted is the payload of a workflow_completed event.
+type WorkflowCompleted struct {
+	ClockSkew time.Duration `json:"clock_skew,omitempty"`
+}
+
+// WorkflowFailed is the payload of a workflow_failed event.
+type WorkflowFailed struct {
+	Error     string        `json:"error"`
+	ClockSkew time.Duration `json:"clock_skew,omitempty"`
+}
+
+// StepStarted is the payload of a step_started event.
+type StepStarted struct {
+	StepIndex int `json:"step_index"`
+}
+
+// StepProgress is the payload of a step_progress event.
+type StepProgress struct {
+	ActionID string `json:"action_id,omitempty"`
+	Text     string `json:"text"`
+}
+
+// StepCompleted is the payload of a step_completed event.
+type StepCompleted struct {
+	StepIndex  int           `json:"step_index"`
+	Duration   time.Duration `json:"duration"`
+	StartedAt  time.Time     `json:"started_at,omitzero"`
+	EndedAt    time.Time     `json:"ended_at,omitzero"`
+	Cached     bool          `json:"cached"`
+	CostCenter string        `json:"cost_center,omitempty"`
+	Owner      string        `json:"owner,omitempty"`
+	Tokens     int           `json:"tokens,omitempty"`
+	Cost       float64       `json:"cost,omitempty"`
+}
+
+// StepFailed is the payload of a step_failed event.
+type StepFailed struct {
+	StepIndex     int           `json:"step_index"`
+	Duration      time.Duration `json:"duration"`
+	StartedAt     time.Time     `json:"started_at,omitzero"`
+	EndedAt       time.Time     `json:"ended_at,omitzero"`
+	Error         string        `json:"error"`
+	ErrorCategory string        `json:"error_category,omitempty"`
+}
+
+// StepTimedOut is the payload of a step_timed_out event.
+type StepTimedOut struct {
+	StepIndex int           `json:"step_index"`
+	Timeout   time.Duration `json:"timeout"`
+	Error     string        `json:"error"`
+}
+
+// StepSkipped is the payload of a step_skipped event.
+type StepSkipped struct {
+	StepIndex int `json:"step_index"`
+}
+
+// StepRetrying is the payload of a step_retrying event.
+type StepRetrying struct {
+	Attempt int    `json:"attempt"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ActionStarted is the payload of an action within a step starting, such as
+// an agent being prompted.
+type ActionStarted struct {
+	ActionID string `json:"action_id"`
+	Text     string `json:"text"`
+}
+
+// ActionCompleted is the payload of an action within a step completing.
+type ActionCompleted struct {
+	ActionID    string   `json:"action_id"`
+	Diagnostics []string `json:"diagnostics,omitempty"`
+}
+
+// ActionFailed is the payload of an action within a step failing.
+type ActionFailed struct {
+	ActionID string `json:"action_id"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ToolCall is the payload of a tool being called by an agent.
+type ToolCall struct {
+	ActionID string                 `json:"action_id"`
+	Tool     string                 `json:"tool"`
+	Input    map[string]interface{} `json:"input,omitempty"`
+	Text     string                 `json:"text"`
+}
+
+// ToolCallCompleted is the payload of a tool call completing successfully.
+type ToolCallCompleted struct {
+	ActionID string `json:"action_id"`
+	Tool     string `json:"tool"`
+}
+
+// ToolCallFailed is the payload of a tool call failing.
+type ToolCallFailed struct {
+	ActionID string `json:"action_id"`
+	Tool     string `json:"tool"`
+	Error    string `json:"error,omitempty"`
+}
+
+// TokenDelta is the payload of a chunk of streamed model output.
+type TokenDelta struct {
+	ActionID string `json:"action_id,omitempty"`
+	Text     string `json:"text"`
+}
+
+// StateUpdated is the payload of a step updating the workflow state.
+type StateUpdated struct {
+	Updates map[string]interface{} `json:"updates"`
+}
+
+// Moderation is the payload of a moderation policy matching a prompt or
+// output, it never includes the matched content.
+type Moderation struct {
+	ActionID   string   `json:"action_id,omitempty"`
+	Policy     string   `json:"policy"`
+	Stage      string   `json:"stage"`
+	Action     string   `json:"action"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// PromptCompressed is the payload of a prompt compressed to fit in the
+// context window of its model, listing the sections dropped from it and the
+// expressions whose values were summarized.
+type PromptCompressed struct {
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompressedTokens int      `json:"compressed_tokens"`
+	ContextWindow    int      `json:"context_window"`
+	DroppedSections  []string `json:"dropped_sections,omitempty"`
+	Summarized       []string `json:"summarized,omitempty"`
+}
+
+// Unknown is the payload of a kind which is not known to this version of
+// the package, the raw payload is kept so that it can be decoded by the
+// consumer.
+type Unknown struct {
+	PayloadKind PayloadKind     `json:"-"`
+	Raw         json.RawMessage `json:"-"`
+}
+
+func (WorkflowStarted) Kind() PayloadKind   { return KindWorkflowStarted }
+func (WorkflowCompleted) Kind() PayloadKind { return KindWorkflowCompleted }
+func (WorkflowFailed) Kind() PayloadKind    { return KindWorkflowFailed }
+func (StepStarted) Kind() PayloadKind       { return KindStepStarted }
+func (StepProgress) Kind() PayloadKind      { return KindStepProgress }
+func (StepCompleted) Kind() PayloadKind     { return KindStepCompleted }
+func (StepFailed) Kind() PayloadKind        { return KindStepFailed }
+func (StepTimedOut) Kind() PayloadKind      { return KindStepTimedOut }
+func (StepSkipped) Kind() PayloadKind       { return KindStepSkipped }
+func (StepRetrying) Kind() PayloadKind      { return KindStepRetrying }
+func (ActionStarted) Kind() PayloadKind     { return KindActionStarted }
+func (ActionCompleted) Kind() PayloadKind   { return KindActionCompleted }
+func (ActionFailed) Kind() PayloadKind      { return KindActionFailed }
+func (ToolCall) Kind() PayloadKind          { return KindToolCall }
+func (ToolCallCompleted) Kind() PayloadKind { return KindToolCallCompleted }
+func (ToolCallFailed) Kind() PayloadKind    { return KindToolCallFailed }
+func (TokenDelta) Kind() PayloadKind        { return KindTokenDelta }
+func (StateUpdated) Kind() PayloadKind      { return KindStateUpdated }
+func (Moderation) Kind() PayloadKind        { return KindModeration }
+func (PromptCompressed) Kind() PayloadKind  { return KindPromptCompressed }
+func (u Unknown) Kind() PayloadKind         { return u.PayloadKind }
+
+// MarshalJSON writes the raw payload.
+func (u Unknown) MarshalJSON() ([]byte, error) {
+	if u.Raw == nil {
+		return []byte("null"), nil
+	}
+	return u.Raw, nil
+}
+
+// Envelope is the stable JSON representation of an execution event. The
+// fields common to every event are at the top level and the data specific
+// to the kind of event is in the payload, consumers should switch on Kind
+// and decode the payload into the matching type.
+type Envelope struct {
+	// Version is the SchemaVersion the envelope was produced with.
+	Version int `json:"version"`
+	// Kind identifies the type of the payload.
+	Kind PayloadKind `json:"kind"`
+	// Timestamp indicates when the event occurred.
+	Timestamp time.Time `json:"timestamp"`
+	// RunID is the unique identifier for the workflow execution run.
+	RunID string `json:"run_id"`
+	// StepID is the identifier of the step associated with this event, if any.
+	StepID string `json:"step_id,omitempty"`
+	// Payload contains the data specific to the kind of event.
+	Payload Payload `json:"payload"`
+}
+
+// UnmarshalJSON decodes the envelope, decoding the payload into the concrete
+// type for its kind.
+func (e *Envelope) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Version   int             `json:"version"`
+		Kind      PayloadKind     `json:"kind"`
+		Timestamp time.Time       `json:"timestamp"`
+		RunID     string          `json:"run_id"`
+		StepID    string          `json:"step_id,omitempty"`
+		Payload   json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	payload, err := decodePayload(raw.Kind, raw.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s payload: %w", raw.Kind, err)
+	}
+
+	*e = Envelope{
+		Version:   raw.Version,
+		Kind:      raw.Kind,
+		Timestamp: raw.Timestamp,
+		RunID:     raw.RunID,
+		StepID:    raw.StepID,
+		Payload:   payload,
+	}
+
+	return nil
+}
+
+func decodePayload(kind PayloadKind, data json.RawMessage) (Payload, error) {
+	var payload Payload
+	switch kind {
+	case KindWorkflowStarted:
+		payload = &WorkflowStarted{}
+	case KindWorkflowCompleted:
+		payload = &WorkflowCompleted{}
+	case KindWorkflowFailed:
+		payload = &WorkflowFailed{}
+	case KindStepStarted:
+		payload = &StepStarted{}
+	case KindStepProgress:
+		payload = &StepProgress{}
+	case KindStepCompleted:
+		payload = &StepCompleted{}
+	case KindStepFailed:
+		payload = &StepFailed{}
+	case KindStepTimedOut:
+		payload = &StepTimedOut{}
+	case KindStepSkipped:
+		payload = &StepSkipped{}
+	case KindStepRetrying:
+		payload = &StepRetrying{}
+	case KindActionStarted:
+		payload = &ActionStarted{}
+	case KindActionCompleted:
+		payload = &ActionCompleted{}
+	case KindActionFailed:
+		payload = &ActionFailed{}
+	case KindToolCall:
+		payload = &ToolCall{}
+	case KindToolCallCompleted:
+		payload = &ToolCallCompleted{}
+	case KindToolCallFailed:
+		payload = &ToolCallFailed{}
+	case KindTokenDelta:
+		payload = &TokenDelta{}
+	case KindStateUpdated:
+		payload = &StateUpdated{}
+	case KindModeration:
+		payload = &Moderation{}
+	case KindPromptCompressed:
+		payload = &PromptCompressed{}
+	default:
+		return Unknown{PayloadKind: kind, Raw: data}, nil
+	}
+
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return payload, nil
+}
+
+// Redacted returns a copy of the event without the data of the run, its
+// text, errors, diagnostics, tool inputs and state updates. The tool name,
+// cost labels and usage are kept.
+func (e ExecutionEvent) Redacted() ExecutionEvent {
+	e.Text = ""
+	e.Error = ""
+	e.Diagnostics = nil
+
+	if e.Metadata != nil {
+		metadata := make(map[string]interface{}, len(e.Metadata))
+		for key, value := range e.Metadata {
+			if key != MetadataToolInput && key != MetadataStateUpdates {
+				metadata[key] = value
+			}
+		}
+		e.Metadata = metadata
+	}
+
+	return e
+}
+
+// Envelope converts the event into its stable envelope representation.
+func (e ExecutionEvent) Envelope() Envelope {
+	payload := e.Payload()
+	return Envelope{
+		Version:   SchemaVersion,
+		Kind:      payload.Kind(),
+		Timestamp: e.Timestamp,
+		RunID:     e.RunID,
+		StepID:    e.StepID,
+		Payload:   payload,
+	}
+}
+
+// Payload returns the typed payload for the event.
+func (e ExecutionEvent) Payload() Payload {
+	tool, _ := e.Metadata[MetadataTool].(string)
+
+	// step completion and failure events which don't know when the step
+	// started have no start or end time
+	var startedAt, endedAt time.Time
+	if !e.StartTime.IsZero() {
+		startedAt, endedAt = e.StartTime, e.Timestamp
+	}
+	clockSkew := e.metadataDuration(MetadataClockSkew)
+
+	switch e.Type {
+	case EventWorkflowStarted:
+		return &WorkflowStarted{}
+	case EventWorkflowCompleted:
+		return &WorkflowCompleted{ClockSkew: clockSkew}
+	case EventWorkflowFailed:
+		return &WorkflowFailed{Error: e.Error, ClockSkew: clockSkew}
+	case EventStepStarted:
+		return &StepStarted{StepIndex: e.StepIndex}
+	case EventStepProgress:
+		return &StepProgress{ActionID: e.ActionID, Text: e.Text}
+	case EventStepCompleted:
+		cached, _ := e.Metadata[MetadataCached].(bool)
+		costCenter, _ := e.Metadata[MetadataCostCenter].(string)
+		owner, _ := e.Metadata[MetadataOwner].(string)
+		tokens := e.metadataInt(MetadataTokens)
+		cost := e.metadataFloat(MetadataCost)
+		return &StepCompleted{
+			StepIndex:  e.StepIndex,
+			Duration:   e.Duration,
+			StartedAt:  startedAt,
+			EndedAt:    endedAt,
+			Cached:     cached,
+			CostCenter: costCenter,
+			Owner:      owner,
+			Tokens:     tokens,
+			Cost:       cost,
+		}
+	case EventStepFailed:
+		category, _ := e.Metadata[MetadataErrorCategory].(string)
+		return &StepFailed{StepIndex: e.StepIndex, Duration: e.Duration, StartedAt: startedAt, EndedAt: endedAt, Error: e.Error, ErrorCategory: category}
+	case EventStepTimedOut:
+		timeout := e.metadataDuration(MetadataTimeout)
+		return &StepTimedOut{StepIndex: e.StepIndex, Timeout: timeout, Error: e.Error}
+	case EventStepSkipped:
+		return &StepSkipped{StepIndex: e.StepIndex}
+	case EventStepRetrying:
+		return &StepRetrying{Attempt: e.Attempt, Error: e.Error}
+	case EventStepActionStarted:
+		if tool != "" {
+			input, _ := e.Metadata[MetadataToolInput].(map[string]interface{})
+			return &ToolCall{ActionID: e.ActionID, Tool: tool, Input: input, Text: e.Text}
+		}
+		return &ActionStarted{ActionID: e.ActionID, Text: e.Text}
+	case EventStepActionCompleted:
+		if tool != "" {
+			return &ToolCallCompleted{ActionID: e.ActionID, Tool: tool}
+		}
+		return &ActionCompleted{ActionID: e.ActionID, Diagnostics: e.Diagnostics}
+	case EventStepActionFailed:
+		if tool != "" {
+			return &ToolCallFailed{ActionID: e.ActionID, Tool: tool, Error: e.Error}
+		}
+		return &ActionFailed{ActionID: e.ActionID, Error: e.Error}
+	case EventTokenDelta:
+		return &TokenDelta{ActionID: e.ActionID, Text: e.Text}
+	case EventStateUpdated:
+		updates, _ := e.Metadata[MetadataStateUpdates].(map[string]interface{})
+		return &StateUpdated{Updates: updates}
+	case EventModeration:
+		policy, _ := e.Metadata[MetadataModerationPolicy].(string)
+		stage, _ := e.Metadata[MetadataModerationStage].(string)
+		action, _ := e.Metadata[MetadataModerationAction].(string)
+		categories := e.metadataStrings(MetadataModerationCategories)
+		return &Moderation{ActionID: e.ActionID, Policy: policy, Stage: stage, Action: action, Categories: categories}
+	case EventPromptCompressed:
+		promptTokens := e.metadataInt(MetadataPromptTokens)
+		compressedTokens := e.metadataInt(MetadataCompressedTokens)
+		contextWindow := e.metadataInt(MetadataContextWindow)
+		dropped := e.metadataStrings(MetadataDroppedSections)
+		summarized := e.metadataStrings(MetadataSummarized)
+		return &PromptCompressed{
+			PromptTokens:     promptTokens,
+			CompressedTokens: compressedTokens,
+			ContextWindow:    contextWindow,
+			DroppedSections:  dropped,
+			Summarized:       summarized,
+		}
+	default:
+		data, _ := json.Marshal(e)
+		return Unknown{PayloadKind: PayloadKind(e.Type), Raw: data}
+	}
+}
+
+// metadataInt reads an integer from the metadata of the event. Numbers are
+// float64, or json.Number, once the event has been decoded from JSON.
+func (e ExecutionEvent) metadataInt(key string) int {
+	switch v := e.Metadata[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Float64()
+		return int(n)
+	}
+	return 0
+}
+
+// metadataFloat reads a number from the metadata of the event.
+func (e ExecutionEvent) metadataFloat(key string) float64 {
+	switch v := e.Metadata[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case json.Number:
+		n, _ := v.Float64()
+		return n
+	}
+	return 0
+}
+
+// metadataDuration reads a duration from the metadata of the event, which
+// is a number of nanoseconds once the event has been decoded from JSON.
+func (e ExecutionEvent) metadataDuration(key string) time.Duration {
+	switch v := e.Metadata[key].(type) {
+	case time.Duration:
+		return v
+	case int64:
+		return time.Duration(v)
+	case float64:
+		return time.Duration(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return time.Duration(n)
+	}
+	return 0
+}
+
+// metadataStrings reads a list of strings from the metadata of the event,
+// which is a []interface{} once the event has been decoded from JSON.
+func (e ExecutionEvent) metadataStrings(key string) []string {
+	switch v := e.Metadata[key].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+	return nil
+}
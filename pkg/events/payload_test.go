@@ -0,0 +1,190 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutionEvent_Payload(t *testing.T) {
+	tests := []struct {
+		name  string
+		event ExecutionEvent
+		want  Payload
+	}{
+		{
+			name:  "step started",
+			event: ExecutionEvent{Type: EventStepStarted, StepIndex: 2},
+			want:  &StepStarted{StepIndex: 2},
+		},
+		{
+			name:  "cached step completed",
+			event: ExecutionEvent{Type: EventStepCompleted, StepIndex: 1, Metadata: map[string]interface{}{MetadataCached: true}},
+			want:  &StepCompleted{StepIndex: 1, Cached: true},
+		},
+		{
+			name:  "step completed",
+			event: ExecutionEvent{Type: EventStepCompleted, Timestamp: time.Unix(1700000002, 0), StepIndex: 1, Duration: 2 * time.Second, StartTime: time.Unix(1700000000, 0)},
+			want:  &StepCompleted{StepIndex: 1, Duration: 2 * time.Second, StartedAt: time.Unix(1700000000, 0), EndedAt: time.Unix(1700000002, 0)},
+		},
+		{
+			name:  "workflow completed after a clock adjustment",
+			event: ExecutionEvent{Type: EventWorkflowCompleted, Metadata: map[string]interface{}{MetadataClockSkew: -time.Hour}},
+			want:  &WorkflowCompleted{ClockSkew: -time.Hour},
+		},
+		{
+			name:  "step timed out",
+			event: ExecutionEvent{Type: EventStepTimedOut, StepIndex: 3, Error: "step fetch timed out after 30s", Metadata: map[string]interface{}{MetadataTimeout: 30 * time.Second}},
+			want:  &StepTimedOut{StepIndex: 3, Timeout: 30 * time.Second, Error: "step fetch timed out after 30s"},
+		},
+		{
+			name:  "prompt action",
+			event: ExecutionEvent{Type: EventStepActionStarted, ActionID: "turn-0", Text: "Summarize"},
+			want:  &ActionStarted{ActionID: "turn-0", Text: "Summarize"},
+		},
+		{
+			name: "tool call",
+			event: ExecutionEvent{Type: EventStepActionStarted, ActionID: "tool-1", Text: "Using tool search", Metadata: map[string]interface{}{
+				MetadataTool:      "search",
+				MetadataToolInput: map[string]interface{}{"query": "lacquer"},
+			}},
+			want: &ToolCall{ActionID: "tool-1", Tool: "search", Input: map[string]interface{}{"query": "lacquer"}, Text: "Using tool search"},
+		},
+		{
+			name:  "tool call failed",
+			event: ExecutionEvent{Type: EventStepActionFailed, ActionID: "tool-1", Error: "boom", Metadata: map[string]interface{}{MetadataTool: "search"}},
+			want:  &ToolCallFailed{ActionID: "tool-1", Tool: "search", Error: "boom"},
+		},
+		{
+			name:  "state updated",
+			event: ExecutionEvent{Type: EventStateUpdated, Metadata: map[string]interface{}{MetadataStateUpdates: map[string]interface{}{"count": 1}}},
+			want:  &StateUpdated{Updates: map[string]interface{}{"count": 1}},
+		},
+		{
+			name: "moderation",
+			event: ExecutionEvent{Type: EventModeration, ActionID: "turn-0", Metadata: map[string]interface{}{
+				MetadataModerationPolicy:     "harmful",
+				MetadataModerationStage:      "output",
+				MetadataModerationAction:     "block",
+				MetadataModerationCategories: []string{"violence"},
+			}},
+			want: &Moderation{ActionID: "turn-0", Policy: "harmful", Stage: "output", Action: "block", Categories: []string{"violence"}},
+		},
+		{
+			name: "prompt compressed",
+			event: ExecutionEvent{Type: EventPromptCompressed, Metadata: map[string]interface{}{
+				MetadataPromptTokens:     1200,
+				MetadataCompressedTokens: 800,
+				MetadataContextWindow:    1000,
+				MetadataDroppedSections:  []string{"chat"},
+			}},
+			want: &PromptCompressed{PromptTokens: 1200, CompressedTokens: 800, ContextWindow: 1000, DroppedSections: []string{"chat"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.event.Payload())
+		})
+	}
+}
+
+func TestExecutionEvent_PayloadAfterJSON(t *testing.T) {
+	events := []ExecutionEvent{
+		{Type: EventWorkflowFailed, Error: "boom", Metadata: map[string]interface{}{MetadataClockSkew: -time.Hour}},
+		{Type: EventStepCompleted, StepIndex: 1, Metadata: map[string]interface{}{MetadataTokens: 120, MetadataCost: 0.002}},
+		{Type: EventStepTimedOut, StepIndex: 3, Metadata: map[string]interface{}{MetadataTimeout: 30 * time.Second}},
+		{Type: EventModeration, Metadata: map[string]interface{}{MetadataModerationCategories: []string{"violence"}}},
+		{Type: EventPromptCompressed, Metadata: map[string]interface{}{
+			MetadataPromptTokens:     1200,
+			MetadataCompressedTokens: 800,
+			MetadataContextWindow:    1000,
+			MetadataDroppedSections:  []string{"chat"},
+		}},
+	}
+
+	for _, event := range events {
+		t.Run(string(event.Type), func(t *testing.T) {
+			data, err := json.Marshal(event)
+			require.NoError(t, err)
+
+			// metadata is decoded as float64 numbers and []interface{} lists
+			var decoded ExecutionEvent
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, event.Payload(), decoded.Payload())
+		})
+	}
+}
+
+func TestExecutionEvent_Redacted(t *testing.T) {
+	event := ExecutionEvent{
+		Type:        EventStepActionStarted,
+		StepID:      "lookup",
+		ActionID:    "tool-1",
+		Text:        "Using tool search",
+		Error:       "boom",
+		Diagnostics: []string{"customer@example.com"},
+		Metadata: map[string]interface{}{
+			MetadataTool:      "search",
+			MetadataToolInput: map[string]interface{}{"query": "customer@example.com"},
+			MetadataTokens:    12,
+		},
+	}
+
+	redacted := event.Redacted()
+	assert.Equal(t, ExecutionEvent{
+		Type:     EventStepActionStarted,
+		StepID:   "lookup",
+		ActionID: "tool-1",
+		Metadata: map[string]interface{}{MetadataTool: "search", MetadataTokens: 12},
+	}, redacted)
+
+	// the original event is left untouched
+	assert.Contains(t, event.Metadata, MetadataToolInput)
+	assert.Equal(t, "Using tool search", event.Text)
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	event := ExecutionEvent{
+		Type:      EventStepActionStarted,
+		Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		RunID:     "run-1",
+		StepID:    "research",
+		ActionID:  "tool-1",
+		Text:      "Using tool search",
+		Metadata: map[string]interface{}{
+			MetadataTool:      "search",
+			MetadataToolInput: map[string]interface{}{"query": "lacquer"},
+		},
+	}
+
+	data, err := json.Marshal(event.Envelope())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"kind": "tool_call",
+		"timestamp": "2025-01-02T03:04:05Z",
+		"run_id": "run-1",
+		"step_id": "research",
+		"payload": {"action_id": "tool-1", "tool": "search", "input": {"query": "lacquer"}, "text": "Using tool search"}
+	}`, string(data))
+
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal(data, &envelope))
+	assert.Equal(t, SchemaVersion, envelope.Version)
+	assert.Equal(t, KindToolCall, envelope.Kind)
+	assert.Equal(t, &ToolCall{ActionID: "tool-1", Tool: "search", Input: map[string]interface{}{"query": "lacquer"}, Text: "Using tool search"}, envelope.Payload)
+}
+
+func TestEnvelope_UnknownKind(t *testing.T) {
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal([]byte(`{"version": 2, "kind": "something_new", "run_id": "run-1", "payload": {"value": 1}}`), &envelope))
+
+	unknown, ok := envelope.Payload.(Unknown)
+	require.True(t, ok)
+	assert.Equal(t, PayloadKind("something_new"), unknown.Kind())
+	assert.JSONEq(t, `{"value": 1}`, string(unknown.Raw))
+}
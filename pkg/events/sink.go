@@ -0,0 +1,109 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// ChannelSink is a Listener forwarding the events of a run to a channel, so
+// that they can be consumed alongside other channels in a select.
+//
+// The channel is not closed when the run ends, as a sink can be reused for
+// later runs. A full channel blocks the run until the event is received.
+type ChannelSink struct {
+	ch     chan<- ExecutionEvent
+	filter Filter
+}
+
+// NewChannelSink creates a sink forwarding the events matched by all of the
+// filters to ch.
+func NewChannelSink(ch chan<- ExecutionEvent, filters ...Filter) *ChannelSink {
+	return &ChannelSink{ch: ch, filter: All(filters...)}
+}
+
+// StartListening forwards the events until the run ends.
+func (s *ChannelSink) StartListening(progressChan <-chan ExecutionEvent) {
+	for event := range progressChan {
+		if s.filter(event) {
+			s.ch <- event
+		}
+	}
+}
+
+// StopListening implements the Listener interface, every event has been
+// forwarded by the time it is called.
+func (s *ChannelSink) StopListening() {}
+
+// CallbackSink is a Listener calling a function with each event of a run.
+// The function is called for one event at a time, in the order the events
+// happened, and a slow function slows down the run.
+type CallbackSink struct {
+	fn     func(ExecutionEvent)
+	filter Filter
+}
+
+// NewCallbackSink creates a sink calling fn with the events matched by all
+// of the filters.
+func NewCallbackSink(fn func(ExecutionEvent), filters ...Filter) *CallbackSink {
+	return &CallbackSink{fn: fn, filter: All(filters...)}
+}
+
+// StartListening calls the function with the events until the run ends.
+func (s *CallbackSink) StartListening(progressChan <-chan ExecutionEvent) {
+	for event := range progressChan {
+		if s.filter(event) {
+			s.fn(event)
+		}
+	}
+}
+
+// StopListening implements the Listener interface, the function has been
+// called with every event by the time it is called.
+func (s *CallbackSink) StopListening() {}
+
+// JSONWriterSink is a Listener writing the events of a run to a writer as
+// JSON lines, one Envelope per line, the same format as the server's
+// envelope event streams.
+//
+// Writing stops at the first error, which is returned by Err, the remaining
+// events of the run are discarded.
+type JSONWriterSink struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	filter Filter
+	err    error
+}
+
+// NewJSONWriterSink creates a sink writing the events matched by all of the
+// filters to w.
+func NewJSONWriterSink(w io.Writer, filters ...Filter) *JSONWriterSink {
+	return &JSONWriterSink{enc: json.NewEncoder(w), filter: All(filters...)}
+}
+
+// StartListening writes the events until the run ends.
+func (s *JSONWriterSink) StartListening(progressChan <-chan ExecutionEvent) {
+	for event := range progressChan {
+		if !s.filter(event) {
+			continue
+		}
+
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = s.enc.Encode(event.Envelope())
+		}
+		s.mu.Unlock()
+	}
+}
+
+// StopListening implements the Listener interface, every event has been
+// written by the time it is called.
+func (s *JSONWriterSink) StopListening() {}
+
+// Err returns the error writing an event failed with, if any.
+func (s *JSONWriterSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
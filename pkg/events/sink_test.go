@@ -0,0 +1,150 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sinkEvents = []ExecutionEvent{
+	{Type: EventWorkflowStarted, RunID: "run-1"},
+	{Type: EventStepStarted, RunID: "run-1", StepID: "fetch"},
+	{Type: EventStepCompleted, RunID: "run-1", StepID: "fetch"},
+	{Type: EventStepStarted, RunID: "run-1", StepID: "summarize"},
+	{Type: EventStepFailed, RunID: "run-1", StepID: "summarize", Error: "rate limited"},
+	{Type: EventWorkflowFailed, RunID: "run-1", Error: "rate limited"},
+}
+
+// listen runs the listener the way the runner does for a run with events.
+func listen(listener Listener, events []ExecutionEvent) {
+	progressChan := make(chan ExecutionEvent, len(events))
+	for _, event := range events {
+		progressChan <- event
+	}
+	close(progressChan)
+
+	listener.StartListening(progressChan)
+	listener.StopListening()
+}
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []ExecutionEventType
+	}{
+		{
+			name:   "of type",
+			filter: OfType(EventStepFailed, EventWorkflowFailed),
+			want:   []ExecutionEventType{EventStepFailed, EventWorkflowFailed},
+		},
+		{
+			name:   "for step",
+			filter: ForStep("fetch"),
+			want:   []ExecutionEventType{EventStepStarted, EventStepCompleted},
+		},
+		{
+			name:   "for another run",
+			filter: ForRun("run-2"),
+		},
+		{
+			name:   "not",
+			filter: Not(ForStep("fetch", "summarize")),
+			want:   []ExecutionEventType{EventWorkflowStarted, EventWorkflowFailed},
+		},
+		{
+			name:   "all",
+			filter: All(ForRun("run-1"), ForStep("summarize"), OfType(EventStepStarted)),
+			want:   []ExecutionEventType{EventStepStarted},
+		},
+		{
+			name:   "any",
+			filter: Any(OfType(EventWorkflowStarted), ForStep("summarize")),
+			want:   []ExecutionEventType{EventWorkflowStarted, EventStepStarted, EventStepFailed},
+		},
+		{
+			name:   "all without filters",
+			filter: All(),
+			want:   []ExecutionEventType{EventWorkflowStarted, EventStepStarted, EventStepCompleted, EventStepStarted, EventStepFailed, EventWorkflowFailed},
+		},
+		{
+			name:   "any without filters",
+			filter: Any(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []ExecutionEventType
+			for _, event := range sinkEvents {
+				if tt.filter(event) {
+					got = append(got, event.Type)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestChannelSink(t *testing.T) {
+	ch := make(chan ExecutionEvent, len(sinkEvents))
+	listen(NewChannelSink(ch, ForStep("summarize")), sinkEvents)
+
+	// the channel belongs to the caller and is left open
+	require.Len(t, ch, 2)
+	assert.Equal(t, EventStepStarted, (<-ch).Type)
+	assert.Equal(t, EventStepFailed, (<-ch).Type)
+}
+
+func TestCallbackSink(t *testing.T) {
+	var got []ExecutionEvent
+	listen(NewCallbackSink(func(event ExecutionEvent) {
+		got = append(got, event)
+	}), sinkEvents)
+
+	assert.Equal(t, sinkEvents, got)
+}
+
+func TestJSONWriterSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONWriterSink(&buf, OfType(EventStepFailed, EventWorkflowFailed))
+	listen(sink, sinkEvents)
+	require.NoError(t, sink.Err())
+
+	var envelopes []Envelope
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var envelope Envelope
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &envelope))
+		envelopes = append(envelopes, envelope)
+	}
+
+	require.Len(t, envelopes, 2)
+	assert.Equal(t, KindStepFailed, envelopes[0].Kind)
+	assert.Equal(t, "summarize", envelopes[0].StepID)
+	assert.Equal(t, "rate limited", envelopes[0].Payload.(*StepFailed).Error)
+	assert.Equal(t, KindWorkflowFailed, envelopes[1].Kind)
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("disk full")
+}
+
+func TestJSONWriterSink_WriteError(t *testing.T) {
+	w := &failingWriter{}
+	sink := NewJSONWriterSink(w)
+	listen(sink, sinkEvents)
+
+	assert.EqualError(t, sink.Err(), "disk full")
+	assert.Equal(t, 1, w.writes)
+}
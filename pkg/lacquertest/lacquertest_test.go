@@ -0,0 +1,99 @@
+package lacquertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lacquerai/lacquer/pkg/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const triageWorkflow = `version: "1.0"
+metadata:
+  name: triage
+inputs:
+  ticket:
+    type: string
+    required: true
+agents:
+  triager:
+    provider: anthropic
+    model: test-model
+    system_prompt: You triage support tickets.
+    tools:
+      - name: lookup_customer
+        description: Looks up the plan of a customer
+        script: "echo '{\"plan\": \"pro\"}'"
+        parameters:
+          type: object
+          properties:
+            email:
+              type: string
+workflow:
+  steps:
+    - id: triage
+      agent: triager
+      prompt: "Triage this ticket: ${{ inputs.ticket }}"
+      outputs:
+        severity:
+          type: string
+  outputs:
+    severity: ${{ steps.triage.outputs.severity }}
+`
+
+func TestRun(t *testing.T) {
+	pr := NewProvider("anthropic", []string{"test-model"},
+		Call("lookup_customer", map[string]interface{}{"email": "jane@example.com"}),
+		Response{Text: `{"severity": "high"}`, PromptTokens: 10, CompletionTokens: 5},
+	)
+	recorder := NewRecorder()
+
+	result := Run(t, WriteWorkflow(t, triageWorkflow), map[string]interface{}{"ticket": "The site is down"},
+		WithProviders(pr), WithListener(recorder))
+
+	require.NoError(t, result.Err)
+	assert.Equal(t, "completed", result.Status)
+	assert.Equal(t, "high", result.Outputs["severity"])
+	assert.Equal(t, "completed", result.Steps["triage"].Status)
+
+	requests := pr.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, "test-model", requests[0].Model)
+	assert.Equal(t, "You triage support tickets.", requests[0].SystemPrompt)
+	assert.Contains(t, requests[0].Prompt, "Triage this ticket: The site is down")
+	assert.Equal(t, []string{"lookup_customer"}, requests[0].Tools)
+	// the tool was run and its result sent with the next request
+	assert.Contains(t, requests[1].Prompt, "pro")
+
+	assert.Len(t, recorder.Events(events.OfType(events.EventStepActionCompleted), events.ForStep("triage")), 3)
+	types := recorder.Types("triage")
+	assert.Equal(t, events.EventStepStarted, types[0])
+	assert.Equal(t, events.EventStepCompleted, types[len(types)-1])
+}
+
+func TestRun_ProviderFails(t *testing.T) {
+	pr := NewProvider("anthropic", []string{"test-model"}, Fail(errors.New("overloaded")))
+
+	result := Run(t, WriteWorkflow(t, triageWorkflow), map[string]interface{}{"ticket": "The site is down"}, WithProviders(pr))
+
+	require.Error(t, result.Err)
+	assert.Contains(t, result.Err.Error(), "overloaded")
+	assert.Equal(t, "failed", result.Steps["triage"].Status)
+}
+
+func TestRun_NoResponseLeft(t *testing.T) {
+	pr := NewProvider("anthropic", []string{"test-model"})
+
+	result := Run(t, WriteWorkflow(t, triageWorkflow), map[string]interface{}{"ticket": "The site is down"}, WithProviders(pr))
+
+	require.Error(t, result.Err)
+	assert.Contains(t, result.Err.Error(), "provider anthropic has no response for request 1, only 0 are scripted")
+}
+
+func TestRun_InvalidInputs(t *testing.T) {
+	result := Run(t, WriteWorkflow(t, triageWorkflow), nil)
+
+	require.Error(t, result.Err)
+	assert.Empty(t, result.Steps)
+}
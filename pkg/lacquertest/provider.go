@@ -0,0 +1,177 @@
+package lacquertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/pkg/events"
+)
+
+// Response is a scripted response of an agent. The agent's tools are called
+// for each of ToolCalls and their results sent with the next request, like
+// a model would have them called.
+type Response struct {
+	// Text is the text the agent responds with
+	Text string
+	// ToolCalls are the tools the agent calls
+	ToolCalls []ToolCall
+	// Err fails the request instead, e.g. with a provider error
+	Err error
+	// PromptTokens and CompletionTokens are the usage reported for the
+	// request
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ToolCall is a call of a tool of the agent with the given input.
+type ToolCall struct {
+	Name  string
+	Input map[string]interface{}
+}
+
+// Text returns a response with the given text, for the outputs of a step
+// the text holds their JSON object.
+func Text(text string) Response {
+	return Response{Text: text}
+}
+
+// Call returns a response calling the named tool with the given input.
+func Call(name string, input map[string]interface{}) Response {
+	return Response{ToolCalls: []ToolCall{{Name: name, Input: input}}}
+}
+
+// Fail returns a response failing the request with err.
+func Fail(err error) Response {
+	return Response{Err: err}
+}
+
+// Request is a request an agent sent to a Provider.
+type Request struct {
+	Model        string
+	SystemPrompt string
+	// Prompt is the text of the last message of the request, the rendered
+	// prompt of the step or the results of the tools called by the previous
+	// response
+	Prompt string
+	// Tools are the names of the tools available to the agent
+	Tools []string
+}
+
+// Provider is a model provider which responds to each request with the
+// next of its scripted responses and records the requests it receives. It
+// is safe for use by steps run in parallel, though which step receives
+// which response then depends on the order they send their requests.
+type Provider struct {
+	name      string
+	models    []string
+	responses []Response
+	requests  []Request
+	mu        sync.Mutex
+}
+
+// NewProvider creates a provider named after the provider of the agents it
+// replaces, such as "anthropic" or "openai", which supports the given models
+// and responds with responses in order.
+func NewProvider(name string, models []string, responses ...Response) *Provider {
+	return &Provider{
+		name:      name,
+		models:    models,
+		responses: responses,
+	}
+}
+
+// Requests returns the requests received so far.
+func (p *Provider) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Request(nil), p.requests...)
+}
+
+// Generate responds to the request with the next scripted response, failing
+// once there are none left.
+func (p *Provider) Generate(_ provider.GenerateContext, request *provider.Request, _ chan<- events.ExecutionEvent) ([]provider.Message, *execcontext.TokenUsage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	index := len(p.requests)
+	p.requests = append(p.requests, newRequest(request))
+	if index >= len(p.responses) {
+		return nil, nil, fmt.Errorf("provider %s has no response for request %d, only %d are scripted", p.name, index+1, len(p.responses))
+	}
+
+	response := p.responses[index]
+	usage := &execcontext.TokenUsage{
+		PromptTokens:     response.PromptTokens,
+		CompletionTokens: response.CompletionTokens,
+		TotalTokens:      response.PromptTokens + response.CompletionTokens,
+	}
+	if response.Err != nil {
+		return nil, usage, response.Err
+	}
+
+	var content []provider.ContentBlockParamUnion
+	if response.Text != "" || len(response.ToolCalls) == 0 {
+		content = append(content, provider.NewTextBlock(response.Text))
+	}
+	for i, call := range response.ToolCalls {
+		input, err := json.Marshal(call.Input)
+		if err != nil {
+			return nil, usage, fmt.Errorf("invalid input of tool call %s: %w", call.Name, err)
+		}
+		content = append(content, provider.NewToolUseBlock(fmt.Sprintf("call-%d-%d", index, i), input, call.Name))
+	}
+
+	return []provider.Message{{Role: "assistant", Content: content}}, usage, nil
+}
+
+// GetName returns the name of the provider.
+func (p *Provider) GetName() string {
+	return p.name
+}
+
+// ListModels returns the models the provider was created with.
+func (p *Provider) ListModels(context.Context) ([]provider.Info, error) {
+	models := make([]provider.Info, len(p.models))
+	for i, model := range p.models {
+		models[i] = provider.Info{ID: model, Name: model, Provider: p.name}
+	}
+
+	return models, nil
+}
+
+// Close does nothing, the provider holds no resources.
+func (p *Provider) Close() error {
+	return nil
+}
+
+func newRequest(request *provider.Request) Request {
+	recorded := Request{
+		Model:        request.Model,
+		SystemPrompt: request.SystemPrompt,
+	}
+
+	for _, tool := range request.Tools {
+		recorded.Tools = append(recorded.Tools, tool.Name)
+	}
+
+	if len(request.Messages) > 0 {
+		var parts []string
+		for _, block := range request.Messages[len(request.Messages)-1].Content {
+			switch {
+			case block.OfText != nil:
+				parts = append(parts, block.OfText.Text)
+			case block.OfToolResult != nil:
+				parts = append(parts, block.OfToolResult.Content)
+			}
+		}
+		recorded.Prompt = strings.Join(parts, "\n\n")
+	}
+
+	return recorded
+}
@@ -0,0 +1,74 @@
+package lacquertest
+
+import (
+	"sync"
+
+	"github.com/lacquerai/lacquer/pkg/events"
+)
+
+// Recorder is an events.Listener keeping every event of the runs it listens
+// to, so that tests can check what happened during a run.
+type Recorder struct {
+	events []events.ExecutionEvent
+	mu     sync.Mutex
+	done   chan struct{}
+}
+
+// NewRecorder creates a recorder without events.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// StartListening records the events of a run until the channel is closed.
+func (r *Recorder) StartListening(progressChan <-chan events.ExecutionEvent) {
+	r.mu.Lock()
+	done := make(chan struct{})
+	r.done = done
+	r.mu.Unlock()
+	defer close(done)
+
+	for event := range progressChan {
+		r.mu.Lock()
+		r.events = append(r.events, event)
+		r.mu.Unlock()
+	}
+}
+
+// StopListening waits for the events of the run to be recorded.
+func (r *Recorder) StopListening() {
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// Events returns the recorded events matching every filter, in the order
+// they happened.
+func (r *Recorder) Events(filters ...events.Filter) []events.ExecutionEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := events.All(filters...)
+	var matching []events.ExecutionEvent
+	for _, event := range r.events {
+		if match(event) {
+			matching = append(matching, event)
+		}
+	}
+
+	return matching
+}
+
+// Types returns the types of the recorded events of a step, in the order
+// they happened, for checking the course of the step at a glance.
+func (r *Recorder) Types(stepID string) []events.ExecutionEventType {
+	var types []events.ExecutionEventType
+	for _, event := range r.Events(events.ForStep(stepID)) {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
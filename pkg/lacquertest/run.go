@@ -0,0 +1,164 @@
+// Package lacquertest helps test workflows and the blocks, tools and
+// plugins they use without calling real model providers. Agents are
+// answered by scripted providers, a Recorder keeps the events of the run
+// and Run executes a workflow with both, the way Lacquer's own tests do:
+//
+//	func TestTriage(t *testing.T) {
+//		pr := lacquertest.NewProvider("anthropic", []string{"claude-sonnet-4-20250514"},
+//			lacquertest.Call("lookup_customer", map[string]interface{}{"email": "jane@example.com"}),
+//			lacquertest.Text(`{"severity": "high"}`),
+//		)
+//		recorder := lacquertest.NewRecorder()
+//
+//		result := lacquertest.Run(t, "triage.laq.yml", map[string]interface{}{"ticket": "The site is down"},
+//			lacquertest.WithProviders(pr), lacquertest.WithListener(recorder))
+//
+//		require.NoError(t, result.Err)
+//		assert.Equal(t, "high", result.Outputs["severity"])
+//		assert.Contains(t, pr.Requests()[0].Prompt, "The site is down")
+//	}
+//
+// Tools called by scripted responses are run for real, so that a tool can
+// be tested with the agent using it.
+package lacquertest
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacquerai/lacquer/internal/engine"
+	"github.com/lacquerai/lacquer/internal/execcontext"
+	"github.com/lacquerai/lacquer/internal/provider"
+	"github.com/lacquerai/lacquer/pkg/events"
+)
+
+// Option configures a run started with Run.
+type Option func(*runConfig)
+
+type runConfig struct {
+	providers []provider.Provider
+	listener  events.Listener
+	state     map[string]interface{}
+}
+
+// WithProviders answers the agents whose provider has the name of one of
+// the given providers with it. Runs of agents using other providers call
+// the real provider.
+func WithProviders(providers ...*Provider) Option {
+	return func(c *runConfig) {
+		for _, pr := range providers {
+			c.providers = append(c.providers, pr)
+		}
+	}
+}
+
+// WithListener sends the events of the run to listener, such as a
+// Recorder.
+func WithListener(listener events.Listener) Option {
+	return func(c *runConfig) {
+		c.listener = listener
+	}
+}
+
+// WithState starts the run with the given state rather than the defaults of
+// the workflow.
+func WithState(state map[string]interface{}) Option {
+	return func(c *runConfig) {
+		c.state = state
+	}
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	RunID   string
+	Status  string
+	Outputs map[string]interface{}
+	State   map[string]interface{}
+	// Steps are the results of the steps which ran, keyed by step ID
+	Steps map[string]StepResult
+	// Err is the error the run failed with, Result only holds what was done
+	// before when the run failed to start, e.g. because of invalid inputs
+	Err error
+}
+
+// StepResult is the outcome of a step of a run.
+type StepResult struct {
+	Status string
+	// Output holds the step's outputs, Response the raw response of an
+	// agent step
+	Output   map[string]interface{}
+	Response string
+	Error    string
+	Retries  int
+}
+
+// Run executes the workflow file with inputs and returns its result, the
+// run is cancelled when the test ends.
+func Run(t testing.TB, workflowFile string, inputs map[string]interface{}, options ...Option) *Result {
+	t.Helper()
+
+	config := &runConfig{}
+	for _, option := range options {
+		option(config)
+	}
+
+	runnerOptions := []engine.RunnerOption{engine.WithProviders(config.providers...)}
+	if config.state != nil {
+		runnerOptions = append(runnerOptions, engine.WithInitialState(config.state))
+	}
+	runner := engine.NewRunner(config.listener, runnerOptions...)
+
+	executionResult, err := runner.RunWorkflow(execcontext.RunContext{
+		Context: t.Context(),
+		StdOut:  io.Discard,
+		StdErr:  io.Discard,
+	}, workflowFile, inputs)
+
+	result := &Result{Err: err, Steps: make(map[string]StepResult)}
+	if executionResult == nil {
+		return result
+	}
+
+	result.RunID = executionResult.RunID
+	result.Status = executionResult.Status
+	result.Outputs = executionResult.Outputs
+	result.State = executionResult.FinalState
+	for _, step := range executionResult.StepResults {
+		result.Steps[step.StepID] = StepResult{
+			Status:   step.Status,
+			Output:   step.Output,
+			Response: step.Response,
+			Error:    step.Error,
+			Retries:  step.Retries,
+		}
+	}
+
+	return result
+}
+
+// WriteWorkflow writes the workflow definition to a file in a temporary
+// directory of the test and returns its path, for workflows defined in the
+// test itself. Files the workflow refers to relative to itself can be
+// written next to it with WriteFile.
+func WriteWorkflow(t testing.TB, definition string) string {
+	t.Helper()
+
+	return WriteFile(t, filepath.Join(t.TempDir(), "workflow.laq.yml"), definition)
+}
+
+// WriteFile writes content to path, creating its directory, and returns
+// the path.
+func WriteFile(t testing.TB, path string, content string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create directory of %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+
+	return path
+}